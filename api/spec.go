@@ -0,0 +1,10 @@
+// Package api holds the OpenAPI specification of the langdag server, which
+// the server serves at /openapi.json.
+package api
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI 3 document describing the REST API, in YAML.
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
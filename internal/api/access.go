@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"langdag.com/langdag/internal/conversation"
+	"langdag.com/langdag/types"
+)
+
+// publicRoutes are the routes clients without an API key may use on
+// public-readonly DAGs.
+var publicRoutes = map[string]bool{
+	"GET /nodes/{id}":          true,
+	"GET /nodes/{id}/tree":     true,
+	"GET /nodes/{id}/comments": true,
+}
+
+type apiKeyContextKey struct{}
+
+// withAPIKey returns r for a request made with the stored key: the DAGs it
+// starts are owned by the key, and it only reaches private DAGs the key
+// owns.
+func withAPIKey(r *http.Request, key *types.APIKey) *http.Request {
+	ctx := context.WithValue(r.Context(), apiKeyContextKey{}, key)
+	if key.Scope != types.APIKeyScopeTools {
+		ctx = conversation.ContextWithoutBuiltinTools(ctx)
+	}
+	return r.WithContext(conversation.ContextWithAPIKey(ctx, key.ID))
+}
+
+// requestKey returns the stored API key r was made with, or nil for the
+// server key, guests and servers without authentication.
+func requestKey(r *http.Request) *types.APIKey {
+	key, _ := r.Context().Value(apiKeyContextKey{}).(*types.APIKey)
+	return key
+}
+
+// dagRoot returns the root of the DAG containing the node id resolves to,
+// or nil if there is no such node. Archived DAGs are not restored: their
+// stub root is returned.
+func (s *Server) dagRoot(ctx context.Context, id string) (*types.Node, error) {
+	node, err := s.convMgr.LookupNode(ctx, id)
+	if err != nil || node == nil {
+		return nil, err
+	}
+	if node.RootID == "" || node.RootID == node.ID {
+		return node, nil
+	}
+	return s.store.GetNode(ctx, node.RootID)
+}
+
+// checkVisible reports whether the DAG of the node in r's path may be
+// reached by r. If not, a 404 has been written, so that private DAGs can't
+// be probed.
+func (s *Server) checkVisible(w http.ResponseWriter, r *http.Request) bool {
+	id := r.PathValue("id")
+	if id == "" || !strings.Contains(r.Pattern, " /nodes/{id}") {
+		return true
+	}
+	root, err := s.dagRoot(r.Context(), id)
+	if err != nil {
+		writeServerError(w, err)
+		return false
+	}
+	if root != nil && !conversation.VisibleTo(r.Context(), root) {
+		writeError(w, http.StatusNotFound, "node not found")
+		return false
+	}
+	return true
+}
+
+// admitPublic serves r, made without an API key, if it reads a
+// public-readonly DAG.
+func (s *Server) admitPublic(r *http.Request) (*http.Request, bool) {
+	if !publicRoutes[r.Pattern] {
+		return nil, false
+	}
+	root, err := s.dagRoot(r.Context(), r.PathValue("id"))
+	if err != nil || root == nil || conversation.Visibility(root) != types.VisibilityPublicReadOnly {
+		return nil, false
+	}
+	return r.WithContext(conversation.ContextWithAPIKey(r.Context(), "")), true
+}
+
+// commentAuthor names the author of comments posted with r: the name (or
+// ID) of its stored API key, else "admin".
+func commentAuthor(r *http.Request) string {
+	if key := requestKey(r); key != nil {
+		if key.Name != "" {
+			return key.Name
+		}
+		return key.ID
+	}
+	return "admin"
+}
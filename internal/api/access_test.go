@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/apikeys"
+	"langdag.com/langdag/internal/archive"
+	"langdag.com/langdag/types"
+)
+
+func TestDAGVisibilityAndComments(t *testing.T) {
+	s, mux := testServer(t, "")
+	ctx := context.Background()
+	aliceSecret, alice, err := apikeys.Create(ctx, s.store, "alice", types.APIKeyScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobSecret, _, err := apikeys.Create(ctx, s.store, "bob", types.APIKeyScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.keysCheckedAt = time.Time{}
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/prompt", aliceSecret, `{"message":"Review this prompt"}`)
+	var prompt PromptResponse
+	json.Unmarshal(w.Body.Bytes(), &prompt)
+	var root NodeResponse
+	json.Unmarshal(do("GET", "/nodes/"+prompt.NodeID, aliceSecret, "").Body.Bytes(), &root)
+	json.Unmarshal(do("GET", "/nodes/"+root.RootID, aliceSecret, "").Body.Bytes(), &root)
+	if root.Owner != alice.ID || root.Visibility != types.VisibilityTeam {
+		t.Fatalf("root owner = %q, visibility = %q", root.Owner, root.Visibility)
+	}
+
+	// Team DAGs are reachable with any key; only the owner makes them private.
+	if w := do("GET", "/nodes/"+root.ID, bobSecret, ""); w.Code != http.StatusOK {
+		t.Errorf("team DAG, other key: status = %d", w.Code)
+	}
+	if w := do("PATCH", "/nodes/"+root.ID, bobSecret, `{"visibility":"private"}`); w.Code != http.StatusForbidden {
+		t.Errorf("visibility change by other key: status = %d, want 403", w.Code)
+	}
+	if w := do("PATCH", "/nodes/"+root.ID, aliceSecret, `{"visibility":"secret"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid visibility: status = %d, want 400", w.Code)
+	}
+	if w := do("PATCH", "/nodes/"+root.ID, aliceSecret, `{"visibility":"private"}`); w.Code != http.StatusOK {
+		t.Fatalf("private by owner: status = %d: %s", w.Code, w.Body)
+	}
+	for _, path := range []string{"/nodes/" + prompt.NodeID, "/nodes/" + root.ID + "/tree", "/nodes/" + root.ID + "/comments"} {
+		if w := do("GET", path, bobSecret, ""); w.Code != http.StatusNotFound {
+			t.Errorf("private DAG, GET %s by other key: status = %d, want 404", path, w.Code)
+		}
+	}
+	if body := do("GET", "/nodes", bobSecret, "").Body.String(); strings.Contains(body, root.ID) {
+		t.Error("private DAG listed for another key")
+	}
+	if body := do("GET", "/nodes", aliceSecret, "").Body.String(); !strings.Contains(body, root.ID) {
+		t.Error("private DAG not listed for its owner")
+	}
+
+	// Public DAGs may be read, not continued, without a key.
+	do("PATCH", "/nodes/"+root.ID, aliceSecret, `{"visibility":"public-readonly"}`)
+	if w := do("GET", "/nodes/"+root.ID+"/tree", "", ""); w.Code != http.StatusOK {
+		t.Errorf("public DAG read without key: status = %d", w.Code)
+	}
+	if w := do("POST", "/nodes/"+root.ID+"/prompt", "", `{"message":"Hi"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("public DAG prompt without key: status = %d, want 401", w.Code)
+	}
+
+	w = do("POST", "/nodes/"+prompt.NodeID+"/comments", bobSecret, `{"body":"Too verbose"}`)
+	var first types.Comment
+	json.Unmarshal(w.Body.Bytes(), &first)
+	if w.Code != http.StatusCreated || first.Author != "bob" || first.NodeID != prompt.NodeID {
+		t.Fatalf("comment: status = %d: %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/nodes/"+prompt.NodeID+"/comments", aliceSecret, `{"body":"Agreed","reply_to":"`+first.ID+`"}`); w.Code != http.StatusCreated {
+		t.Errorf("reply: status = %d: %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/nodes/"+root.ID+"/comments", aliceSecret, `{"body":"x","reply_to":"`+first.ID+`"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("reply on another node: status = %d, want 400", w.Code)
+	}
+	if w := do("POST", "/nodes/"+root.ID+"/comments", aliceSecret, `{"body":"  "}`); w.Code != http.StatusBadRequest {
+		t.Errorf("empty comment: status = %d, want 400", w.Code)
+	}
+
+	var comments []types.Comment
+	json.Unmarshal(do("GET", "/nodes/"+root.ID+"/comments", "", "").Body.Bytes(), &comments)
+	if len(comments) != 2 || comments[1].ReplyTo != first.ID || comments[1].Author != "alice" {
+		t.Errorf("comments = %+v", comments)
+	}
+}
+
+func TestAccessChecksKeepDAGsArchived(t *testing.T) {
+	s, mux := testServer(t, "")
+	ctx := context.Background()
+	aliceSecret, _, err := apikeys.Create(ctx, s.store, "alice", types.APIKeyScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobSecret, _, err := apikeys.Create(ctx, s.store, "bob", types.APIKeyScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.keysCheckedAt = time.Time{}
+	archiveStore, err := archive.Open(ctx, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.convMgr.SetArchiveStore(archiveStore)
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	var prompt PromptResponse
+	json.Unmarshal(do("POST", "/prompt", aliceSecret, `{"message":"Keep this"}`).Body.Bytes(), &prompt)
+	reply, err := s.store.GetNode(ctx, prompt.NodeID)
+	if err != nil || reply == nil {
+		t.Fatalf("reply: %v", err)
+	}
+	if w := do("PATCH", "/nodes/"+reply.RootID, aliceSecret, `{"visibility":"private"}`); w.Code != http.StatusOK {
+		t.Fatalf("make private: status = %d", w.Code)
+	}
+	if n, err := s.convMgr.Archive(ctx, time.Now().Add(time.Hour)); err != nil || n != 1 {
+		t.Fatalf("Archive = %d, %v", n, err)
+	}
+
+	archived := func() bool {
+		t.Helper()
+		root, err := s.store.GetNode(ctx, reply.RootID)
+		if err != nil || root == nil {
+			t.Fatalf("root: %v", err)
+		}
+		return root.ArchivedURI != ""
+	}
+	if w := do("GET", "/nodes/"+reply.RootID, bobSecret, ""); w.Code != http.StatusNotFound {
+		t.Errorf("private DAG, other key: status = %d, want 404", w.Code)
+	}
+	if w := do("GET", "/nodes/"+reply.RootID, "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("private DAG, no key: status = %d, want 401", w.Code)
+	}
+	if !archived() {
+		t.Fatal("a denied request restored the archived DAG")
+	}
+	if w := do("GET", "/nodes/"+reply.RootID, aliceSecret, ""); w.Code != http.StatusOK {
+		t.Errorf("private DAG, owner: status = %d, want 200", w.Code)
+	}
+	if archived() {
+		t.Error("the owner's request did not restore the DAG")
+	}
+}
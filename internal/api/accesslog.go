@@ -0,0 +1,250 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"langdag.com/langdag/internal/config"
+	"langdag.com/langdag/internal/logging"
+)
+
+// accessLogBodyLimit is the largest request body the access log records.
+const accessLogBodyLimit = 16 << 10
+
+// defaultRedactFields are the JSON fields hidden in logged request bodies
+// when access_log.redact is not set: everything that carries message text.
+var defaultRedactFields = []string{"message", "content", "system", "system_prompt", "text", "question", "input"}
+
+// accessLogEntry is one line of the access log.
+type accessLogEntry struct {
+	Time         string          `json:"time"`
+	Method       string          `json:"method"`
+	Path         string          `json:"path"`
+	Status       int             `json:"status"`
+	DurationMs   float64         `json:"duration_ms"`
+	RequestID    string          `json:"request_id,omitempty"`
+	KeyID        string          `json:"key_id,omitempty"`
+	RequestBytes int64           `json:"request_bytes"`
+	Bytes        int64           `json:"bytes"`
+	RequestBody  json.RawMessage `json:"request_body,omitempty"`
+}
+
+// accessLogger writes one JSON line per API request, apart from the
+// application log.
+type accessLogger struct {
+	mu     sync.Mutex
+	out    io.Writer
+	closer io.Closer // nil for stdout and stderr
+	sample float64
+	bodies bool
+	redact map[string]bool
+}
+
+// newAccessLogger opens the access log configured by cfg, or returns nil
+// when it is disabled.
+func newAccessLogger(cfg config.AccessLogConfig) (*accessLogger, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		return nil, fmt.Errorf("access_log.sample_rate must be between 0 and 1, got %v", cfg.SampleRate)
+	}
+	l := &accessLogger{sample: cfg.SampleRate, bodies: cfg.Bodies, redact: make(map[string]bool)}
+	switch cfg.Output {
+	case "", "stdout":
+		l.out = os.Stdout
+	case "stderr":
+		l.out = os.Stderr
+	default:
+		f, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open access log: %w", err)
+		}
+		l.out, l.closer = f, f
+	}
+	redact := cfg.Redact
+	if len(redact) == 0 {
+		redact = defaultRedactFields
+	}
+	for _, field := range redact {
+		l.redact[strings.ToLower(field)] = true
+	}
+	return l, nil
+}
+
+// Close closes the access log file, if any.
+func (l *accessLogger) Close() error {
+	if l == nil || l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// middleware logs requests handled by next. Requests are sampled at the
+// configured rate, except failed ones (status 400 and up), which are always
+// logged.
+func (l *accessLogger) middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		var body *bodyRecorder
+		if l.bodies && r.Body != nil {
+			body = &bodyRecorder{ReadCloser: r.Body}
+			r.Body = body
+		}
+		rw := &accessLogWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		if rw.status < 400 && l.sample > 0 && l.sample < 1 && rand.Float64() >= l.sample {
+			return
+		}
+		entry := accessLogEntry{
+			Time:         start.UTC().Format(time.RFC3339Nano),
+			Method:       r.Method,
+			Path:         r.URL.Path,
+			Status:       rw.status,
+			DurationMs:   float64(time.Since(start).Microseconds()) / 1000,
+			RequestID:    logging.RequestID(r.Context()),
+			KeyID:        keyID(requestAPIKey(r)),
+			RequestBytes: r.ContentLength,
+			Bytes:        rw.bytes,
+		}
+		if body != nil {
+			if entry.RequestBytes < 0 {
+				entry.RequestBytes = body.n
+			}
+			entry.RequestBody = l.redactBody(body)
+		}
+		l.write(entry)
+	})
+}
+
+func (l *accessLogger) write(entry accessLogEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(append(data, '\n'))
+}
+
+// redactBody returns the recorded body with the values of redacted fields
+// replaced. Bodies that are not JSON, or were cut at accessLogBodyLimit, are
+// replaced by a placeholder rather than logged.
+func (l *accessLogger) redactBody(body *bodyRecorder) json.RawMessage {
+	if body.n == 0 {
+		return nil
+	}
+	placeholder := func(reason string) json.RawMessage {
+		data, _ := json.Marshal(fmt.Sprintf("[%d bytes, %s]", body.n, reason))
+		return data
+	}
+	if body.truncated {
+		return placeholder("too large")
+	}
+	var v interface{}
+	if err := json.Unmarshal(body.buf.Bytes(), &v); err != nil {
+		return placeholder("not JSON")
+	}
+	data, err := json.Marshal(redactJSON(v, l.redact))
+	if err != nil {
+		return placeholder("not JSON")
+	}
+	return data
+}
+
+// redactJSON replaces the values of fields named in redact, at any depth.
+func redactJSON(v interface{}, redact map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if redact[strings.ToLower(k)] {
+				v[k] = "[redacted]"
+			} else {
+				v[k] = redactJSON(val, redact)
+			}
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = redactJSON(val, redact)
+		}
+	}
+	return v
+}
+
+// keyID identifies the API key of a request in the access log without
+// revealing it: the first 8 hex digits of its SHA-256.
+func keyID(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:4])
+}
+
+// bodyRecorder keeps the first accessLogBodyLimit bytes read from a request
+// body.
+type bodyRecorder struct {
+	io.ReadCloser
+	buf       bytes.Buffer
+	n         int64
+	truncated bool
+}
+
+func (b *bodyRecorder) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if room := accessLogBodyLimit - b.buf.Len(); room < n {
+		b.buf.Write(p[:max(room, 0)])
+		b.truncated = true
+	} else {
+		b.buf.Write(p[:n])
+	}
+	return n, err
+}
+
+// accessLogWriter records the status and size of a response. It forwards
+// Flush so SSE streams keep working.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *accessLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"langdag.com/langdag/internal/config"
+)
+
+func readAccessLog(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []map[string]interface{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		var e map[string]interface{}
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("invalid access log line %q: %v", sc.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestAccessLogRecordsRequests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	l, err := newAccessLogger(config.AccessLogConfig{Enabled: true, Output: path, Bodies: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, mux := testServer(t, "secret")
+	handler := l.middleware(mux)
+
+	body := `{"message":"my private question","model":"mock-fast"}`
+	req := httptest.NewRequest("POST", "/prompt", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	req = httptest.NewRequest("GET", "/nodes", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readAccessLog(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	e := entries[0]
+	if e["method"] != "POST" || e["path"] != "/prompt" || e["status"] != float64(http.StatusOK) {
+		t.Errorf("unexpected entry: %v", e)
+	}
+	if e["key_id"] != keyID("secret") || strings.Contains(e["key_id"].(string), "secret") {
+		t.Errorf("key_id = %v", e["key_id"])
+	}
+	if e["bytes"].(float64) <= 0 || e["request_bytes"] != float64(len(body)) {
+		t.Errorf("unexpected sizes: %v", e)
+	}
+	logged, _ := json.Marshal(e["request_body"])
+	if strings.Contains(string(logged), "private") || !strings.Contains(string(logged), "mock-fast") {
+		t.Errorf("request body not redacted: %s", logged)
+	}
+	if entries[1]["status"] != float64(http.StatusUnauthorized) || entries[1]["key_id"] != nil {
+		t.Errorf("unexpected entry: %v", entries[1])
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	l, err := newAccessLogger(config.AccessLogConfig{Enabled: true, Output: path, SampleRate: 0.000001})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, mux := testServer(t, "")
+	handler := l.middleware(mux)
+	for i := 0; i < 20; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+	}
+	// Failures are logged whatever the sample rate.
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nodes/missing", nil))
+	l.Close()
+
+	entries := readAccessLog(t, path)
+	if len(entries) != 1 || entries[0]["status"] != float64(http.StatusNotFound) {
+		t.Errorf("unexpected entries: %v", entries)
+	}
+
+	if _, err := newAccessLogger(config.AccessLogConfig{Enabled: true, SampleRate: 2}); err == nil {
+		t.Error("expected an error for a sample rate above 1")
+	}
+	if l, err := newAccessLogger(config.AccessLogConfig{}); l != nil || err != nil {
+		t.Errorf("disabled access log = %v, %v", l, err)
+	}
+}
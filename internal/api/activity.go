@@ -0,0 +1,191 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"langdag.com/langdag/internal/conversation"
+	"langdag.com/langdag/types"
+)
+
+const (
+	// activityWindow is how far back GET /activity looks when computing
+	// throughput and provider latency.
+	activityWindow = 5 * time.Minute
+
+	// maxRecentErrors is the number of generation errors GET /activity keeps.
+	maxRecentErrors = 20
+)
+
+// ActivityResponse is a snapshot of what the server is doing, for operator
+// views such as `langdag top`.
+type ActivityResponse struct {
+	Active          []ActiveGeneration `json:"active"`
+	TokensPerMinute float64            `json:"tokens_per_minute"`
+	Providers       []ProviderActivity `json:"providers"`
+	RecentErrors    []ActivityError    `json:"recent_errors"`
+}
+
+// ActiveGeneration is a generation currently streaming.
+type ActiveGeneration struct {
+	RootID    string `json:"root_id"`
+	ParentID  string `json:"parent_id"`
+	Model     string `json:"model,omitempty"`
+	StartedAt string `json:"started_at"`
+	ElapsedMs int64  `json:"elapsed_ms"`
+}
+
+// ProviderActivity summarizes a provider's recent completed generations.
+type ProviderActivity struct {
+	Provider     string `json:"provider"`
+	Generations  int    `json:"generations"`
+	AvgLatencyMs int    `json:"avg_latency_ms"`
+}
+
+// ActivityError is a recent generation failure.
+type ActivityError struct {
+	Time    string `json:"time"`
+	Message string `json:"message"`
+}
+
+// completion is one finished generation kept for the activity window.
+type completion struct {
+	at        time.Time
+	provider  string
+	tokens    int
+	latencyMs int
+}
+
+// activityLog keeps recent completions and errors in memory.
+type activityLog struct {
+	mu          sync.Mutex
+	started     time.Time
+	completions []completion // oldest first, trimmed to activityWindow
+	errors      []ActivityError
+}
+
+func newActivityLog() *activityLog {
+	return &activityLog{started: time.Now()}
+}
+
+// recordCompletion adds a saved assistant node.
+func (a *activityLog) recordCompletion(node *types.Node) {
+	if a == nil || node == nil || node.NodeType != types.NodeTypeAssistant {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	a.trim(now)
+	a.completions = append(a.completions, completion{
+		at:        now,
+		provider:  node.Provider,
+		tokens:    node.TokensIn + node.TokensOut,
+		latencyMs: node.LatencyMs,
+	})
+}
+
+// recordError adds a generation failure.
+func (a *activityLog) recordError(message string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.errors = append(a.errors, ActivityError{Time: time.Now().UTC().Format(time.RFC3339), Message: message})
+	if len(a.errors) > maxRecentErrors {
+		a.errors = a.errors[len(a.errors)-maxRecentErrors:]
+	}
+}
+
+// trim drops completions older than activityWindow. Callers hold a.mu.
+func (a *activityLog) trim(now time.Time) {
+	cutoff := now.Add(-activityWindow)
+	i := 0
+	for i < len(a.completions) && a.completions[i].at.Before(cutoff) {
+		i++
+	}
+	a.completions = a.completions[i:]
+}
+
+// snapshot summarizes the activity window. Throughput is averaged over the
+// window, or over the server's uptime when that is shorter.
+func (a *activityLog) snapshot(now time.Time) (float64, []ProviderActivity, []ActivityError) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.trim(now)
+
+	span := activityWindow
+	if uptime := now.Sub(a.started); uptime < span {
+		span = uptime
+	}
+	if span < time.Minute {
+		span = time.Minute
+	}
+
+	var tokens int
+	byProvider := make(map[string]*ProviderActivity)
+	latency := make(map[string]int)
+	for _, c := range a.completions {
+		tokens += c.tokens
+		p := byProvider[c.provider]
+		if p == nil {
+			p = &ProviderActivity{Provider: c.provider}
+			byProvider[c.provider] = p
+		}
+		p.Generations++
+		latency[c.provider] += c.latencyMs
+	}
+
+	providers := make([]ProviderActivity, 0, len(byProvider))
+	for name, p := range byProvider {
+		p.AvgLatencyMs = latency[name] / p.Generations
+		providers = append(providers, *p)
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Provider < providers[j].Provider })
+
+	errors := make([]ActivityError, len(a.errors))
+	copy(errors, a.errors)
+	return float64(tokens) / span.Minutes(), providers, errors
+}
+
+// handleActivity returns active generations, recent throughput, provider
+// latency and recent errors. Generations in DAGs hidden from the request
+// are left out.
+func (s *Server) handleActivity(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	active := []ActiveGeneration{}
+	for _, run := range s.convMgr.ActiveRuns() {
+		root, err := s.store.GetNode(r.Context(), run.RootID)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		if root != nil && !conversation.VisibleTo(r.Context(), root) {
+			continue
+		}
+		active = append(active, ActiveGeneration{
+			RootID:    run.RootID,
+			ParentID:  run.ParentID,
+			Model:     run.Model,
+			StartedAt: run.StartedAt.UTC().Format(time.RFC3339),
+			ElapsedMs: now.Sub(run.StartedAt).Milliseconds(),
+		})
+	}
+
+	tokensPerMinute, providers, errors := s.activity.snapshot(now)
+	writeJSON(w, http.StatusOK, ActivityResponse{
+		Active:          active,
+		TokensPerMinute: tokensPerMinute,
+		Providers:       providers,
+		RecentErrors:    errors,
+	})
+}
+
+// recordCompletion updates metrics and activity for a saved assistant node.
+func (s *Server) recordCompletion(r *http.Request, node *types.Node) {
+	s.metrics.record(apiKeyLabel(r), node)
+	s.activity.recordCompletion(node)
+}
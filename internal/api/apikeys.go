@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"langdag.com/langdag/internal/apikeys"
+	"langdag.com/langdag/types"
+)
+
+const (
+	// storedKeysTTL is how long the server trusts its answer to "are there
+	// stored API keys?", so keys created with `langdag apikey create` take
+	// effect without a restart.
+	storedKeysTTL = 5 * time.Second
+
+	// touchInterval bounds how often a key's last use is written.
+	touchInterval = time.Minute
+)
+
+// authenticate checks the API key of r. It returns the request to serve
+// and whether it may proceed; if not, the error response has been written.
+// Requests without a key may read public-readonly DAGs, and are otherwise
+// served as guests when guest mode allows. Stored keys only reach the
+// private DAGs they own.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	presented := requestAPIKey(r)
+	if s.apiKey != "" && presented == s.apiKey {
+		return r, true
+	}
+	key, err := apikeys.Lookup(r.Context(), s.store, presented)
+	if err != nil {
+		writeServerError(w, err)
+		return nil, false
+	}
+	if key != nil {
+		if !apikeys.Allows(key.Scope, r.Method) {
+			writeError(w, http.StatusForbidden, "API key "+key.ID+" is read-only")
+			return nil, false
+		}
+		s.touchAPIKey(r.Context(), key)
+		r = withAPIKey(r, key)
+		return r, s.checkVisible(w, r)
+	}
+	if s.apiKey == "" && !s.hasStoredKeys(r.Context()) {
+		return r, true
+	}
+	if presented == "" {
+		if r, ok := s.admitPublic(r); ok {
+			return r, true
+		}
+		return s.admitGuest(w, r)
+	}
+	writeError(w, http.StatusUnauthorized, "unauthorized")
+	return nil, false
+}
+
+// hasStoredKeys reports whether any stored API key is active, in which case
+// every request must be authenticated.
+func (s *Server) hasStoredKeys(ctx context.Context) bool {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+	if time.Since(s.keysCheckedAt) < storedKeysTTL {
+		return s.haveKeys
+	}
+	keys, err := s.store.ListAPIKeys(ctx)
+	if err != nil {
+		// Fail closed: without the list, requests must carry a key.
+		slog.WarnContext(ctx, "api: failed to list API keys", "error", err)
+		return true
+	}
+	s.haveKeys = false
+	for _, k := range keys {
+		if k.RevokedAt == nil {
+			s.haveKeys = true
+			break
+		}
+	}
+	s.keysCheckedAt = time.Now()
+	return s.haveKeys
+}
+
+// touchAPIKey records the use of key, at most once per touchInterval.
+func (s *Server) touchAPIKey(ctx context.Context, key *types.APIKey) {
+	now := time.Now().UTC()
+	if key.LastUsedAt != nil && now.Sub(*key.LastUsedAt) < touchInterval {
+		return
+	}
+	if err := s.store.TouchAPIKey(ctx, key.ID, now); err != nil {
+		slog.WarnContext(ctx, "api: failed to record API key use", "key_id", key.ID, "error", err)
+	}
+}
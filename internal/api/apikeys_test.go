@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/apikeys"
+	"langdag.com/langdag/internal/toolbox"
+	"langdag.com/langdag/types"
+)
+
+func TestStoredAPIKeys(t *testing.T) {
+	s, mux := testServer(t, "")
+	ctx := context.Background()
+
+	do := func(method, path, key string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"message":"Hi"}`))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := do("GET", "/nodes", ""); code != http.StatusOK {
+		t.Fatalf("no keys: status = %d, want 200", code)
+	}
+
+	readSecret, readKey, err := apikeys.Create(ctx, s.store, "dashboard", types.APIKeyScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSecret, _, err := apikeys.Create(ctx, s.store, "ci", types.APIKeyScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	toolsSecret, _, err := apikeys.Create(ctx, s.store, "agent", types.APIKeyScopeTools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.keysCheckedAt = time.Time{} // don't wait for storedKeysTTL
+
+	if code := do("GET", "/nodes", ""); code != http.StatusUnauthorized {
+		t.Errorf("no key once keys exist: status = %d, want 401", code)
+	}
+	if code := do("GET", "/nodes", readSecret); code != http.StatusOK {
+		t.Errorf("read key, GET: status = %d, want 200", code)
+	}
+	if code := do("POST", "/prompt", readSecret); code != http.StatusForbidden {
+		t.Errorf("read key, POST: status = %d, want 403", code)
+	}
+	if code := do("POST", "/prompt", writeSecret); code != http.StatusOK {
+		t.Errorf("write key, POST: status = %d, want 200", code)
+	}
+
+	withShell := func(key string) int {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/prompt", strings.NewReader(`{"message":"Hi","tools":[{"name":"shell"}]}`))
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := withShell(writeSecret); code != http.StatusForbidden {
+		t.Errorf("write key, built-in tool: status = %d, want 403", code)
+	}
+	// The tools key gets past the scope check; shell is not enabled here.
+	if code := withShell(toolsSecret); code != http.StatusBadRequest {
+		t.Errorf("tools key, built-in tool: status = %d, want 400", code)
+	}
+
+	// A DAG whose default tools include shell: regenerating without tools
+	// uses them, so the write key is refused there too.
+	tb, err := toolbox.New(toolbox.Options{Shell: toolbox.ShellOptions{AllowedCommands: []string{"echo"}, Dir: t.TempDir()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.convMgr.SetToolbox(tb)
+	meta, _ := json.Marshal(types.UserNodeMetadata{Tools: []types.ToolDefinition{{Name: "shell"}}})
+	root := &types.Node{ID: "tools-root", RootID: "tools-root", NodeType: types.NodeTypeUser, Content: "Hi", Metadata: meta, CreatedAt: time.Now()}
+	reply := &types.Node{ID: "tools-reply", ParentID: root.ID, RootID: root.ID, NodeType: types.NodeTypeAssistant, Content: "Hello", Sequence: 1, CreatedAt: time.Now()}
+	for _, n := range []*types.Node{root, reply} {
+		if err := s.store.CreateNode(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, body := range []string{`{}`, `{"stream":true}`} {
+		req := httptest.NewRequest("POST", "/nodes/"+reply.ID+"/regenerate", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+writeSecret)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("write key, regenerate %s with the DAG's shell: status = %d, want 403; body = %s", body, w.Code, w.Body.String())
+		}
+	}
+
+	used, err := s.store.GetAPIKeyByHash(ctx, apikeys.Hash(readSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used.LastUsedAt == nil {
+		t.Error("last use of the read key was not recorded")
+	}
+
+	if err := s.store.RevokeAPIKey(ctx, readKey.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if code := do("GET", "/nodes", readSecret); code != http.StatusUnauthorized {
+		t.Errorf("revoked key: status = %d, want 401", code)
+	}
+}
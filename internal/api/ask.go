@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"langdag.com/langdag/internal/conversation"
+	"langdag.com/langdag/types"
+)
+
+// AskRequest is a question about stored conversations.
+type AskRequest struct {
+	Question string `json:"question"`
+	// DAGID limits the question to the DAG containing this node. Without it,
+	// the question is answered from every DAG.
+	DAGID    string                 `json:"dag_id,omitempty"`
+	Model    string                 `json:"model,omitempty"`
+	Metadata *types.RequestMetadata `json:"metadata,omitempty"`
+}
+
+// AskResponse is the answer to an AskRequest.
+type AskResponse struct {
+	NodeID string `json:"node_id"` // the stored answer
+	Answer string `json:"answer"`
+	// Sources are the nodes given to the model (questions across all DAGs
+	// only). The answer cites them by their first 8 ID characters.
+	Sources []SearchMatchResponse `json:"sources,omitempty"`
+}
+
+// handleAsk answers a question about one DAG or about all of them.
+func (s *Server) handleAsk(w http.ResponseWriter, r *http.Request) {
+	var req AskRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Question) == "" {
+		writeError(w, http.StatusBadRequest, "question is required")
+		return
+	}
+	ctx := conversation.ContextWithRequestMetadata(r.Context(), req.Metadata)
+
+	var sources []conversation.SearchMatch
+	var events <-chan types.StreamEvent
+	var err error
+	if req.DAGID != "" {
+		node, err := s.convMgr.ResolveNode(ctx, req.DAGID)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		if node == nil {
+			writeError(w, http.StatusNotFound, "node not found")
+			return
+		}
+		root, err := s.dagRoot(ctx, node.ID)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		if root != nil && !conversation.VisibleTo(ctx, root) {
+			writeError(w, http.StatusNotFound, "node not found")
+			return
+		}
+		events, err = s.convMgr.Ask(ctx, node.ID, req.Question, req.Model)
+		if err != nil {
+			s.activity.recordError(err.Error())
+			writeServerError(w, err)
+			return
+		}
+	} else {
+		if req.Model == "" {
+			req.Model = "claude-sonnet-4-20250514"
+		}
+		sources, events, err = s.convMgr.AskAll(ctx, req.Question, req.Model)
+		if errors.Is(err, conversation.ErrNoSources) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			s.activity.recordError(err.Error())
+			writeServerError(w, err)
+			return
+		}
+	}
+
+	answer, nodeID, err := collectEvents(events)
+	if err != nil {
+		s.activity.recordError(err.Error())
+		writeServerError(w, err)
+		return
+	}
+	node, _ := s.convMgr.ResolveNode(ctx, nodeID)
+	s.recordCompletion(r, node)
+
+	resp := AskResponse{NodeID: nodeID, Answer: answer}
+	for _, m := range sources {
+		resp.Sources = append(resp.Sources, SearchMatchResponse{
+			Node:    toNodeResponse(m.Node),
+			Path:    m.Path,
+			Snippet: m.Snippet,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
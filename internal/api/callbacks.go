@@ -0,0 +1,235 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"langdag.com/langdag/internal/config"
+	"langdag.com/langdag/internal/conversation"
+	"langdag.com/langdag/types"
+)
+
+// callbackSignatureHeader carries the hex HMAC-SHA256 of a callback body,
+// keyed with the configured secret, as "sha256=<hex>".
+const callbackSignatureHeader = "X-Langdag-Signature-256"
+
+const (
+	callbackAttempts = 5
+	callbackTimeout  = 10 * time.Second
+)
+
+// CallbackAcceptedResponse answers a prompt sent with a callback_url: the
+// generation runs in the background and its result is POSTed to the URL.
+type CallbackAcceptedResponse struct {
+	CallbackID string `json:"callback_id"`
+}
+
+// CallbackPayload is the body POSTed to a callback URL once a generation
+// ends. Status is "completed" or "failed"; the reply fields are set when a
+// reply was saved, which a failed generation may still do (e.g. on
+// timeout).
+type CallbackPayload struct {
+	CallbackID string `json:"callback_id"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	RootID     string `json:"root_id,omitempty"`
+	*PromptResponse
+}
+
+// callbackSender delivers signed callbacks to allowed hosts, retrying with
+// exponential backoff.
+type callbackSender struct {
+	allowed []string
+	secret  []byte
+	client  *http.Client
+	backoff time.Duration // before the second attempt, doubled after each
+
+	wg sync.WaitGroup // deliveries in progress
+}
+
+// newCallbackSender returns the callback sender configured by cfg, or nil
+// when callbacks are disabled.
+func newCallbackSender(cfg config.CallbacksConfig) (*callbackSender, error) {
+	if len(cfg.AllowedHosts) == 0 {
+		return nil, nil
+	}
+	if cfg.Secret == "" {
+		return nil, fmt.Errorf("server.callbacks.secret is required with allowed_hosts")
+	}
+	return &callbackSender{
+		allowed: cfg.AllowedHosts,
+		secret:  []byte(cfg.Secret),
+		client: &http.Client{
+			Timeout: callbackTimeout,
+			// A redirect could send the signed payload to a host that is
+			// not allowed: the 3xx is taken as the answer.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		backoff: time.Second,
+	}, nil
+}
+
+// check returns an error unless rawURL is an http(s) URL of an allowed
+// host.
+func (c *callbackSender) check(rawURL string) error {
+	if c == nil {
+		return errors.New("callbacks are not enabled")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("callback_url must be an http or https URL")
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range c.allowed {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("callback host %q is not allowed", host)
+}
+
+// send POSTs payload to rawURL in the background.
+func (c *callbackSender) send(ctx context.Context, rawURL string, payload CallbackPayload) {
+	body, _ := json.Marshal(payload)
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		if err := c.deliver(ctx, rawURL, body); err != nil {
+			slog.WarnContext(ctx, "callbacks: delivery failed", "callback_id", payload.CallbackID, "error", err)
+		}
+	}()
+}
+
+// deliver POSTs body until the receiver answers 2xx, another 4xx than 429,
+// or the attempts are used up.
+func (c *callbackSender) deliver(ctx context.Context, rawURL string, body []byte) error {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	var err error
+	delay := c.backoff
+	for attempt := 1; attempt <= callbackAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			delay *= 2
+		}
+		var retry bool
+		retry, err = c.post(ctx, rawURL, body, signature)
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying.
+func (c *callbackSender) post(ctx context.Context, rawURL string, body []byte, signature string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(callbackSignatureHeader, signature)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("callback receiver answered %s", resp.Status)
+}
+
+// wait blocks until the deliveries in progress are done.
+func (c *callbackSender) wait() {
+	if c != nil {
+		c.wg.Wait()
+	}
+}
+
+// checkCallback validates the callback_url of a prompt request, if any.
+// Guests can't use callbacks: they would make the server POST to hosts
+// on their behalf.
+func (s *Server) checkCallback(ctx context.Context, callbackURL string, stream bool) error {
+	if callbackURL == "" {
+		return nil
+	}
+	if conversation.IsGuestContext(ctx) {
+		return errors.New("callback_url is not available to guests")
+	}
+	if stream {
+		return errors.New("callback_url cannot be combined with stream")
+	}
+	return s.callbacks.check(callbackURL)
+}
+
+// startWithCallback starts a generation that outlives the request and
+// answers 202 at once; the reply, or the error, is POSTed to callbackURL
+// when the generation ends.
+func (s *Server) startWithCallback(w http.ResponseWriter, r *http.Request, callbackURL string, start func(context.Context) (<-chan types.StreamEvent, error)) {
+	ctx := context.WithoutCancel(r.Context())
+	events, err := start(ctx)
+	if err != nil {
+		s.activity.recordError(err.Error())
+		writePromptError(w, err)
+		return
+	}
+
+	id := uuid.NewString()
+	// Tracked from now on, so that shutdown waits for the generation and
+	// not only for the delivery.
+	s.callbacks.wg.Add(1)
+	go func() {
+		defer s.callbacks.wg.Done()
+		payload := CallbackPayload{CallbackID: id, Status: "completed"}
+		content, nodeID, err := collectEvents(events)
+		if err != nil {
+			s.activity.recordError(err.Error())
+			payload.Status = "failed"
+			payload.Error = err.Error()
+		}
+		if nodeID != "" {
+			node, _ := s.convMgr.ResolveNode(ctx, nodeID)
+			if err == nil {
+				s.recordCompletion(r, node)
+			}
+			if node != nil {
+				payload.RootID = node.RootID
+				switch node.Status {
+				case "cancelled", "timeout", "interrupted":
+					// The partial reply was saved; report why it stopped.
+					payload.Status = "failed"
+					payload.Error = "generation stopped: " + node.Status
+				}
+			}
+			resp := promptResponseFromNode(nodeID, content, node)
+			payload.PromptResponse = &resp
+		}
+		s.callbacks.send(ctx, callbackURL, payload)
+	}()
+	writeJSON(w, http.StatusAccepted, CallbackAcceptedResponse{CallbackID: id})
+}
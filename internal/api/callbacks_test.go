@@ -0,0 +1,200 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/config"
+	mockprovider "langdag.com/langdag/internal/provider/mock"
+)
+
+func TestPromptCallback(t *testing.T) {
+	s, mux := testServer(t, "")
+
+	var attempts atomic.Int32
+	delivered := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		// Fail the first attempt to exercise the retry.
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		mac := hmac.New(sha256.New, []byte("hook-secret"))
+		mac.Write(body)
+		if got, want := r.Header.Get(callbackSignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		delivered <- body
+	}))
+	defer receiver.Close()
+
+	callbacks, err := newCallbackSender(config.CallbacksConfig{AllowedHosts: []string{"127.0.0.1"}, Secret: "hook-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	callbacks.backoff = time.Millisecond
+	s.callbacks = callbacks
+
+	req := httptest.NewRequest("POST", "/prompt", strings.NewReader(`{"message":"Hi","callback_url":"`+receiver.URL+`/done"}`))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202; body = %s", w.Code, w.Body.String())
+	}
+	var accepted CallbackAcceptedResponse
+	json.NewDecoder(w.Body).Decode(&accepted)
+
+	var payload struct {
+		CallbackID string `json:"callback_id"`
+		Status     string `json:"status"`
+		RootID     string `json:"root_id"`
+		NodeID     string `json:"node_id"`
+		Content    string `json:"content"`
+	}
+	select {
+	case body := <-delivered:
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback not delivered")
+	}
+	if payload.CallbackID != accepted.CallbackID || payload.Status != "completed" {
+		t.Errorf("payload = %+v, want callback %s completed", payload, accepted.CallbackID)
+	}
+	if payload.Content != "Mock response." || payload.NodeID == "" || payload.RootID == "" {
+		t.Errorf("payload = %+v, want the saved reply", payload)
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("attempts = %d, want 2", n)
+	}
+}
+
+func TestPromptCallbackValidation(t *testing.T) {
+	s, mux := testServer(t, "")
+
+	post := func(body string) int {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/prompt", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := post(`{"message":"Hi","callback_url":"https://hooks.example.com/x"}`); code != http.StatusBadRequest {
+		t.Errorf("callbacks disabled: status = %d, want 400", code)
+	}
+
+	callbacks, err := newCallbackSender(config.CallbacksConfig{AllowedHosts: []string{"*.example.com"}, Secret: "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.callbacks = callbacks
+	for _, body := range []string{
+		`{"message":"Hi","callback_url":"https://evil.test/x"}`,
+		`{"message":"Hi","callback_url":"ftp://hooks.example.com/x"}`,
+		`{"message":"Hi","stream":true,"callback_url":"https://hooks.example.com/x"}`,
+	} {
+		if code := post(body); code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, code)
+		}
+	}
+
+	if _, err := newCallbackSender(config.CallbacksConfig{AllowedHosts: []string{"example.com"}}); err == nil {
+		t.Error("expected an error without a secret")
+	}
+}
+
+func TestCallbackWaitCoversGeneration(t *testing.T) {
+	s, mux := testServerWithMock(t, "", mockprovider.Config{Mode: "fixed", FixedResponse: "Late.", Delay: 200 * time.Millisecond})
+
+	delivered := make(chan struct{}, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered <- struct{}{}
+	}))
+	defer receiver.Close()
+
+	callbacks, err := newCallbackSender(config.CallbacksConfig{AllowedHosts: []string{"127.0.0.1"}, Secret: "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.callbacks = callbacks
+
+	req := httptest.NewRequest("POST", "/prompt", strings.NewReader(`{"message":"Hi","callback_url":"`+receiver.URL+`/done"}`))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202; body = %s", w.Code, w.Body.String())
+	}
+
+	// The generation is still running: wait must not return before the
+	// callback went out.
+	callbacks.wait()
+	select {
+	case <-delivered:
+	default:
+		t.Error("wait returned before the callback was delivered")
+	}
+}
+
+func TestGuestCallbackRejected(t *testing.T) {
+	s, mux := testServer(t, "secret")
+	guest, err := newGuestMode(config.GuestConfig{Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.guest = guest
+	callbacks, err := newCallbackSender(config.CallbacksConfig{AllowedHosts: []string{"*.example.com"}, Secret: "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.callbacks = callbacks
+
+	req := httptest.NewRequest("POST", "/prompt", strings.NewReader(`{"message":"Hi","callback_url":"https://hooks.example.com/x"}`))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("guest prompt with callback_url: status = %d, want 400; body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCallbackRedirectNotFollowed(t *testing.T) {
+	s, mux := testServer(t, "")
+
+	var followed atomic.Bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed.Store(true)
+	}))
+	defer target.Close()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/internal", http.StatusTemporaryRedirect)
+	}))
+	defer receiver.Close()
+
+	callbacks, err := newCallbackSender(config.CallbacksConfig{AllowedHosts: []string{"127.0.0.1"}, Secret: "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.callbacks = callbacks
+
+	req := httptest.NewRequest("POST", "/prompt", strings.NewReader(`{"message":"Hi","callback_url":"`+receiver.URL+`/done"}`))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202; body = %s", w.Code, w.Body.String())
+	}
+	callbacks.wait()
+	if followed.Load() {
+		t.Error("the callback followed a redirect")
+	}
+}
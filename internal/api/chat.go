@@ -1,21 +1,158 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
+	"langdag.com/langdag/internal/conversation"
 	"langdag.com/langdag/types"
 )
 
 // PromptRequest represents a request to start a new tree or continue from a node.
 type PromptRequest struct {
-	Message      string                 `json:"message"`
-	Model        string                 `json:"model,omitempty"`
-	SystemPrompt string                 `json:"system_prompt,omitempty"`
-	Stream       bool                   `json:"stream,omitempty"`
-	Tools        []types.ToolDefinition `json:"tools,omitempty"`
+	Message          string                 `json:"message"`
+	Model            string                 `json:"model,omitempty"`
+	SystemPrompt     string                 `json:"system_prompt,omitempty"`
+	Stream           bool                   `json:"stream,omitempty"`
+	Tools            []types.ToolDefinition `json:"tools,omitempty"`
+	Metadata         *types.RequestMetadata `json:"metadata,omitempty"`
+	MaxTokens        int                    `json:"max_tokens,omitempty"`
+	Temperature      *float64               `json:"temperature,omitempty"`
+	StopSequences    []string               `json:"stop_sequences,omitempty"`
+	Preset           string                 `json:"preset,omitempty"`
+	Language         string                 `json:"language,omitempty"`          // language of the reply; kept by new DAGs
+	ConfirmInjection bool                   `json:"confirm_injection,omitempty"` // send even if tool results were flagged
+	StreamOptions    *StreamOptions         `json:"stream_options,omitempty"`
+	CallbackURL      string                 `json:"callback_url,omitempty"` // answer 202 and POST the reply there
+}
+
+// maxFlushInterval bounds StreamOptions.FlushIntervalMs.
+const maxFlushInterval = 10 * time.Second
+
+// StreamOptions coalesces the delta events of a stream: deltas are held
+// and sent as one once FlushBytes of text are pending or FlushIntervalMs
+// have passed since the first. Without either, each delta is sent as it
+// arrives. With Suggestions, a suggestions event with follow-up questions
+// to the reply follows the done event.
+type StreamOptions struct {
+	FlushIntervalMs int  `json:"flush_interval_ms,omitempty"`
+	FlushBytes      int  `json:"flush_bytes,omitempty"`
+	Suggestions     bool `json:"suggestions,omitempty"`
+}
+
+func (o *StreamOptions) validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.FlushIntervalMs < 0 || time.Duration(o.FlushIntervalMs)*time.Millisecond > maxFlushInterval {
+		return fmt.Errorf("stream_options.flush_interval_ms must be between 0 and %d", maxFlushInterval.Milliseconds())
+	}
+	if o.FlushBytes < 0 {
+		return fmt.Errorf("stream_options.flush_bytes must not be negative")
+	}
+	return nil
+}
+
+// maxStopSequences is the most stop sequences a request may set, the
+// lowest limit among providers.
+const maxStopSequences = 4
+
+// withStopSequences returns r carrying stops, after checking them.
+func withStopSequences(r *http.Request, stops []string) (*http.Request, error) {
+	if len(stops) == 0 {
+		return r, nil
+	}
+	if len(stops) > maxStopSequences {
+		return nil, fmt.Errorf("at most %d stop_sequences are allowed", maxStopSequences)
+	}
+	for _, stop := range stops {
+		if stop == "" {
+			return nil, fmt.Errorf("stop_sequences must not be empty strings")
+		}
+	}
+	return r.WithContext(conversation.ContextWithStopSequences(r.Context(), stops)), nil
+}
+
+// applyPreset fills fields left unset in req from its named preset, checks
+// its tools and returns r with the request's sampling temperature, stop
+// sequences and language attached.
+func (s *Server) applyPreset(r *http.Request, req *PromptRequest) (*http.Request, error) {
+	if req.Preset != "" {
+		p, ok := s.convMgr.Preset(req.Preset)
+		if !ok {
+			return nil, fmt.Errorf("unknown preset: %s", req.Preset)
+		}
+		if req.Model == "" {
+			req.Model = p.Model
+		}
+		if req.SystemPrompt == "" {
+			req.SystemPrompt = p.SystemPrompt
+		}
+		if req.Temperature == nil {
+			req.Temperature = p.Temperature
+		}
+	}
+	if err := s.convMgr.CheckTools(r.Context(), req.Tools); err != nil {
+		return nil, err
+	}
+	if req.Temperature != nil {
+		r = r.WithContext(conversation.ContextWithTemperature(r.Context(), *req.Temperature))
+	}
+	if req.Language != "" {
+		r = r.WithContext(conversation.ContextWithLanguage(r.Context(), req.Language))
+	}
+	return withStopSequences(r, req.StopSequences)
+}
+
+// decodePrompt reads a PromptRequest from r's body. It writes a 400 and
+// returns false when the body is invalid or has no message.
+func decodePrompt(w http.ResponseWriter, r *http.Request) (*PromptRequest, bool) {
+	var req PromptRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return nil, false
+	}
+	if req.Message == "" {
+		writeError(w, http.StatusBadRequest, "message is required")
+		return nil, false
+	}
+	return &req, true
+}
+
+// prepareGeneration checks the options shared by requests that generate a
+// reply, applies req's preset and returns r carrying the request's
+// sampling options, metadata and injection confirmation. When req is
+// invalid it writes the error and returns false.
+func (s *Server) prepareGeneration(w http.ResponseWriter, r *http.Request, req *PromptRequest) (*http.Request, bool) {
+	if req.MaxTokens < 0 {
+		writeError(w, http.StatusBadRequest, "max_tokens must not be negative")
+		return nil, false
+	}
+	if err := req.StreamOptions.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	if err := s.checkCallback(r.Context(), req.CallbackURL, req.Stream); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	r, err := s.applyPreset(r, req)
+	if err != nil {
+		writeRequestError(w, err)
+		return nil, false
+	}
+	r = r.WithContext(conversation.ContextWithRequestMetadata(r.Context(), req.Metadata))
+	if req.ConfirmInjection {
+		r = r.WithContext(conversation.ContextWithInjectionConfirmed(r.Context()))
+	}
+	return r, true
 }
 
 // PromptResponse represents a prompt response.
@@ -35,14 +172,12 @@ type PromptResponse struct {
 
 // handlePrompt starts a new conversation tree.
 func (s *Server) handlePrompt(w http.ResponseWriter, r *http.Request) {
-	var req PromptRequest
-	if err := decodeJSON(r, &req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	req, ok := decodePrompt(w, r)
+	if !ok {
 		return
 	}
-
-	if req.Message == "" {
-		writeError(w, http.StatusBadRequest, "message is required")
+	r, ok = s.prepareGeneration(w, r, req)
+	if !ok {
 		return
 	}
 	if req.Model == "" {
@@ -50,23 +185,33 @@ func (s *Server) handlePrompt(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if req.Stream {
-		s.streamPromptResponse(w, r, "", req.Message, req.Model, req.SystemPrompt, req.Tools)
+		s.streamPromptResponse(w, r, req.StreamOptions, "", req.Message, req.Model, req.SystemPrompt, req.Tools, req.MaxTokens)
+		return
+	}
+
+	if req.CallbackURL != "" {
+		s.startWithCallback(w, r, req.CallbackURL, func(ctx context.Context) (<-chan types.StreamEvent, error) {
+			return s.convMgr.Prompt(ctx, req.Message, req.Model, req.SystemPrompt, req.Tools, nil, req.MaxTokens, 0)
+		})
 		return
 	}
 
-	events, err := s.convMgr.Prompt(r.Context(), req.Message, req.Model, req.SystemPrompt, req.Tools, nil, 0, 0)
+	events, err := s.convMgr.Prompt(r.Context(), req.Message, req.Model, req.SystemPrompt, req.Tools, nil, req.MaxTokens, 0)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		s.activity.recordError(err.Error())
+		writePromptError(w, err)
 		return
 	}
 
 	content, nodeID, err := collectEvents(events)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		s.activity.recordError(err.Error())
+		writeServerError(w, err)
 		return
 	}
 
 	node, _ := s.convMgr.ResolveNode(r.Context(), nodeID)
+	s.recordCompletion(r, node)
 	writeJSON(w, http.StatusOK, promptResponseFromNode(nodeID, content, node))
 }
 
@@ -74,49 +219,255 @@ func (s *Server) handlePrompt(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleNodePrompt(w http.ResponseWriter, r *http.Request) {
 	nodeID := r.PathValue("id")
 
-	var req PromptRequest
-	if err := decodeJSON(r, &req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	req, ok := decodePrompt(w, r)
+	if !ok {
 		return
 	}
-
-	if req.Message == "" {
-		writeError(w, http.StatusBadRequest, "message is required")
+	r, ok = s.prepareGeneration(w, r, req)
+	if !ok {
 		return
 	}
 
 	// Resolve node ID (support prefix matching)
 	node, err := s.convMgr.ResolveNode(r.Context(), nodeID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeServerError(w, err)
+		return
+	}
+	if node == nil {
+		writeError(w, http.StatusNotFound, "node not found")
+		return
+	}
+	if err := s.convMgr.CheckDAGTools(r.Context(), node, req.Tools); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	if req.Stream {
+		s.streamPromptResponse(w, r, req.StreamOptions, node.ID, req.Message, req.Model, "", req.Tools, req.MaxTokens)
+		return
+	}
+
+	if req.CallbackURL != "" {
+		s.startWithCallback(w, r, req.CallbackURL, func(ctx context.Context) (<-chan types.StreamEvent, error) {
+			return s.convMgr.PromptFrom(ctx, node.ID, req.Message, req.Model, req.Tools, nil, req.MaxTokens, 0)
+		})
+		return
+	}
+
+	events, err := s.convMgr.PromptFrom(r.Context(), node.ID, req.Message, req.Model, req.Tools, nil, req.MaxTokens, 0)
+	if err != nil {
+		s.activity.recordError(err.Error())
+		writePromptError(w, err)
+		return
+	}
+
+	content, respNodeID, err := collectEvents(events)
+	if err != nil {
+		s.activity.recordError(err.Error())
+		writeServerError(w, err)
+		return
+	}
+
+	respNode, _ := s.convMgr.ResolveNode(r.Context(), respNodeID)
+	s.recordCompletion(r, respNode)
+	writeJSON(w, http.StatusOK, promptResponseFromNode(respNodeID, content, respNode))
+}
+
+// handleEdit sends a new version of a user message as a sibling branch and
+// returns the reply.
+func (s *Server) handleEdit(w http.ResponseWriter, r *http.Request) {
+	nodeID := r.PathValue("id")
+
+	req, ok := decodePrompt(w, r)
+	if !ok {
+		return
+	}
+	r, ok = s.prepareGeneration(w, r, req)
+	if !ok {
+		return
+	}
+
+	node, err := s.convMgr.ResolveNode(r.Context(), nodeID)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	if node == nil {
+		writeError(w, http.StatusNotFound, "node not found")
+		return
+	}
+	if node.NodeType != types.NodeTypeUser {
+		writeError(w, http.StatusBadRequest, conversation.ErrNotEditable.Error())
+		return
+	}
+	if err := s.convMgr.CheckDAGTools(r.Context(), node, req.Tools); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	start := func(ctx context.Context) (<-chan types.StreamEvent, error) {
+		return s.convMgr.Edit(ctx, node.ID, req.Message, req.Model, "", req.Tools, nil, req.MaxTokens, 0)
+	}
+	if req.Stream {
+		s.streamEvents(w, r, req.StreamOptions, func(ctx context.Context) (<-chan types.StreamEvent, error) {
+			events, err := start(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return withToolResults(req.Message, events), nil
+		})
+		return
+	}
+
+	if req.CallbackURL != "" {
+		s.startWithCallback(w, r, req.CallbackURL, start)
+		return
+	}
+
+	events, err := start(r.Context())
+	if err != nil {
+		s.activity.recordError(err.Error())
+		writePromptError(w, err)
+		return
+	}
+
+	content, respNodeID, err := collectEvents(events)
+	if err != nil {
+		s.activity.recordError(err.Error())
+		writeServerError(w, err)
+		return
+	}
+
+	respNode, _ := s.convMgr.ResolveNode(r.Context(), respNodeID)
+	s.recordCompletion(r, respNode)
+	writeJSON(w, http.StatusOK, promptResponseFromNode(respNodeID, content, respNode))
+}
+
+// RegenerateRequest asks for a new reply next to an existing one. Without
+// a model, the model of the original reply is used.
+type RegenerateRequest struct {
+	Model         string                 `json:"model,omitempty"`
+	Temperature   *float64               `json:"temperature,omitempty"`
+	StopSequences []string               `json:"stop_sequences,omitempty"`
+	Language      string                 `json:"language,omitempty"`
+	MaxTokens     int                    `json:"max_tokens,omitempty"`
+	Tools         []types.ToolDefinition `json:"tools,omitempty"`
+	Stream        bool                   `json:"stream,omitempty"`
+	StreamOptions *StreamOptions         `json:"stream_options,omitempty"`
+	Metadata      *types.RequestMetadata `json:"metadata,omitempty"`
+	CallbackURL   string                 `json:"callback_url,omitempty"`
+}
+
+// promptRequest returns req as a PromptRequest without a message, for the
+// checks it shares with prompts.
+func (req *RegenerateRequest) promptRequest() *PromptRequest {
+	return &PromptRequest{
+		Model:         req.Model,
+		Stream:        req.Stream,
+		Tools:         req.Tools,
+		Metadata:      req.Metadata,
+		MaxTokens:     req.MaxTokens,
+		Temperature:   req.Temperature,
+		StopSequences: req.StopSequences,
+		Language:      req.Language,
+		StreamOptions: req.StreamOptions,
+		CallbackURL:   req.CallbackURL,
+	}
+}
+
+// handleRegenerate re-runs the prompt answered by an assistant node and
+// returns the new reply, a sibling of that node. On a user node it returns
+// another reply to it.
+func (s *Server) handleRegenerate(w http.ResponseWriter, r *http.Request) {
+	nodeID := r.PathValue("id")
+
+	var req RegenerateRequest
+	// The body is optional.
+	if err := decodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	r, ok := s.prepareGeneration(w, r, req.promptRequest())
+	if !ok {
+		return
+	}
+
+	node, err := s.convMgr.ResolveNode(r.Context(), nodeID)
+	if err != nil {
+		writeServerError(w, err)
 		return
 	}
 	if node == nil {
 		writeError(w, http.StatusNotFound, "node not found")
 		return
 	}
+	if err := s.convMgr.CheckDAGTools(r.Context(), node, req.Tools); err != nil {
+		writeRequestError(w, err)
+		return
+	}
 
+	start := func(ctx context.Context) (<-chan types.StreamEvent, error) {
+		return s.convMgr.Regenerate(ctx, node.ID, req.Model, "", req.Tools, nil, req.MaxTokens, 0)
+	}
 	if req.Stream {
-		s.streamPromptResponse(w, r, node.ID, req.Message, req.Model, "", req.Tools)
+		s.streamEvents(w, r, req.StreamOptions, start)
 		return
 	}
 
-	events, err := s.convMgr.PromptFrom(r.Context(), node.ID, req.Message, req.Model, req.Tools, nil, 0, 0)
+	if req.CallbackURL != "" {
+		s.startWithCallback(w, r, req.CallbackURL, start)
+		return
+	}
+
+	events, err := start(r.Context())
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		s.activity.recordError(err.Error())
+		writePromptError(w, err)
 		return
 	}
 
 	content, respNodeID, err := collectEvents(events)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		s.activity.recordError(err.Error())
+		writeServerError(w, err)
 		return
 	}
 
 	respNode, _ := s.convMgr.ResolveNode(r.Context(), respNodeID)
+	s.recordCompletion(r, respNode)
 	writeJSON(w, http.StatusOK, promptResponseFromNode(respNodeID, content, respNode))
 }
 
+// writeRequestError writes err from checking a prompt request: 403 for
+// built-in tools the caller may not run, 400 otherwise.
+func writeRequestError(w http.ResponseWriter, err error) {
+	var denied *conversation.ToolDeniedError
+	if errors.As(err, &denied) {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	writeError(w, http.StatusBadRequest, err.Error())
+}
+
+// writePromptError writes err from starting a prompt. Messages rejected by
+// the prompt-injection scanner are reported as 422 so the client can
+// resubmit with confirm_injection, and built-in tools the caller may not
+// run, such as those listed by the DAG, as 403.
+func writePromptError(w http.ResponseWriter, err error) {
+	var injection *conversation.InjectionError
+	if errors.As(err, &injection) {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	var denied *conversation.ToolDeniedError
+	if errors.As(err, &denied) {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	writeServerError(w, err)
+}
+
 // collectEvents drains an events channel and returns the collected content and node ID.
 func collectEvents(events <-chan types.StreamEvent) (string, string, error) {
 	var content string
@@ -135,70 +486,254 @@ func collectEvents(events <-chan types.StreamEvent) (string, string, error) {
 }
 
 // streamPromptResponse streams the response via SSE.
-func (s *Server) streamPromptResponse(w http.ResponseWriter, r *http.Request, parentNodeID, message, model, systemPrompt string, tools []types.ToolDefinition) {
-	ctx := r.Context()
+func (s *Server) streamPromptResponse(w http.ResponseWriter, r *http.Request, opts *StreamOptions, parentNodeID, message, model, systemPrompt string, tools []types.ToolDefinition, maxTokens int) {
+	s.streamEvents(w, r, opts, func(ctx context.Context) (events <-chan types.StreamEvent, err error) {
+		if parentNodeID == "" {
+			events, err = s.convMgr.Prompt(ctx, message, model, systemPrompt, tools, nil, maxTokens, 0)
+		} else {
+			events, err = s.convMgr.PromptFrom(ctx, parentNodeID, message, model, tools, nil, maxTokens, 0)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return withToolResults(message, events), nil
+	})
+}
 
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
+// ToolCallEvent is the data of a tool_call SSE event: a tool the model
+// asked to call. The reply stops with stop_reason "tool_use" once its calls
+// are streamed; the results are sent with the next prompt.
+type ToolCallEvent struct {
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// ToolResultEvent is the data of a tool_result SSE event: a tool result
+// carried by the message being answered.
+type ToolResultEvent struct {
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content,omitempty"` // a string, or structured content
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+// withToolResults returns events preceded by a content_done event for each
+// tool_result block of message, which relayEvents sends as tool_result
+// events before the reply.
+func withToolResults(message string, events <-chan types.StreamEvent) <-chan types.StreamEvent {
+	trimmed := strings.TrimSpace(message)
+	if trimmed == "" || trimmed[0] != '[' {
+		return events
+	}
+	var blocks []types.ContentBlock
+	if json.Unmarshal([]byte(trimmed), &blocks) != nil {
+		return events
+	}
+	var results []types.ContentBlock
+	for _, b := range blocks {
+		if b.Type == "tool_result" {
+			results = append(results, b)
+		}
+	}
+	if len(results) == 0 {
+		return events
+	}
+	out := make(chan types.StreamEvent)
+	go func() {
+		defer close(out)
+		for i := range results {
+			out <- types.StreamEvent{Type: types.StreamEventContentDone, ContentBlock: &results[i]}
+		}
+		for event := range events {
+			out <- event
+		}
+	}()
+	return out
+}
+
+// toolEventFrame formats the SSE event for a tool_use or tool_result block,
+// or returns nil for other blocks.
+func toolEventFrame(block *types.ContentBlock) []byte {
+	var name string
+	var payload any
+	switch {
+	case block == nil:
+		return nil
+	case block.Type == "tool_use":
+		name, payload = "tool_call", ToolCallEvent{ID: block.ID, Name: block.Name, Input: block.Input}
+	case block.Type == "tool_result":
+		content := block.ContentJSON
+		if len(content) == 0 {
+			content, _ = json.Marshal(block.Content)
+		}
+		name, payload = "tool_result", ToolResultEvent{ToolUseID: block.ToolUseID, Content: content, IsError: block.IsError}
+	default:
+		return nil
+	}
+	data, _ := json.Marshal(payload)
+	return []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", name, data))
+}
+
+// streamEvents streams the events returned by start via SSE, coalescing
+// deltas as opts says. The events are buffered: a client that lost its
+// connection can send the request again with a Last-Event-ID header, or
+// GET /streams/{id}, to get the events it missed instead of starting a
+// new generation.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request, opts *StreamOptions, start func(context.Context) (<-chan types.StreamEvent, error)) {
+	if last := r.Header.Get(lastEventIDHeader); last != "" {
+		streamID, index, ok := parseEventID(last)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "invalid Last-Event-ID")
+			return
+		}
+		s.resumeStream(w, r, streamID, index+1)
+		return
+	}
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		writeError(w, http.StatusInternalServerError, "streaming not supported")
 		return
 	}
+	setSSEHeaders(w)
 
-	var events <-chan types.StreamEvent
-	var err error
-
-	if parentNodeID == "" {
-		events, err = s.convMgr.Prompt(ctx, message, model, systemPrompt, tools, nil, 0, 0)
-	} else {
-		events, err = s.convMgr.PromptFrom(ctx, parentNodeID, message, model, tools, nil, 0, 0)
-	}
+	// The generation outlives the connection, for the client to reconnect;
+	// the stream stops it when no client comes back.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	events, err := start(ctx)
 	if err != nil {
+		cancel()
+		s.activity.recordError(err.Error())
 		writeSSEError(w, flusher, err.Error())
 		return
 	}
 
-	fmt.Fprintf(w, "event: start\ndata: {}\n\n")
-	flusher.Flush()
+	st := s.streams.open(cancel)
+	go s.relayEvents(ctx, r, st, opts, events)
+	st.serve(r.Context(), w, flusher, 0)
+}
+
+// relayEvents writes the events of a generation to st as SSE events.
+func (s *Server) relayEvents(ctx context.Context, r *http.Request, st *sseStream, opts *StreamOptions, events <-chan types.StreamEvent) {
+	defer s.streams.finish(st)
+
+	st.write([]byte("event: start\ndata: {}\n\n"))
+
+	var content, pending strings.Builder
+	var flushTimer <-chan time.Time
+	writeDelta := func() {
+		if pending.Len() == 0 {
+			return
+		}
+		data, _ := json.Marshal(map[string]string{"content": pending.String()})
+		st.write([]byte(fmt.Sprintf("event: delta\ndata: %s\n\n", data)))
+		pending.Reset()
+		flushTimer = nil
+	}
+	var interval time.Duration
+	var flushBytes int
+	if opts != nil {
+		interval = time.Duration(opts.FlushIntervalMs) * time.Millisecond
+		flushBytes = opts.FlushBytes
+	}
+
+	for {
+		var event types.StreamEvent
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				writeDelta()
+				return
+			}
+			event = ev
+		case <-flushTimer:
+			writeDelta()
+			continue
+		}
+		if event.Type != types.StreamEventDelta {
+			writeDelta()
+		}
 
-	var content strings.Builder
-	for event := range events {
 		switch event.Type {
 		case types.StreamEventDelta:
 			content.WriteString(event.Content)
-			data, _ := json.Marshal(map[string]string{"content": event.Content})
-			fmt.Fprintf(w, "event: delta\ndata: %s\n\n", data)
-			flusher.Flush()
+			pending.WriteString(event.Content)
+			switch {
+			case interval == 0 && flushBytes == 0,
+				flushBytes > 0 && pending.Len() >= flushBytes:
+				writeDelta()
+			case interval > 0 && flushTimer == nil:
+				flushTimer = time.After(interval)
+			}
+
+		case types.StreamEventContentDone:
+			if frame := toolEventFrame(event.ContentBlock); frame != nil {
+				st.write(frame)
+			}
 
 		case types.StreamEventNodeSaved:
 			node, _ := s.convMgr.ResolveNode(ctx, event.NodeID)
+			s.recordCompletion(r, node)
 			data, _ := json.Marshal(promptResponseFromNode(event.NodeID, content.String(), node))
-			fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
-			flusher.Flush()
+			st.write([]byte(fmt.Sprintf("event: done\ndata: %s\n\n", data)))
+			if opts != nil && opts.Suggestions && node != nil && node.StopReason != "tool_use" {
+				s.writeSuggestions(ctx, st, node.ID)
+			}
+
+		case types.StreamEventTimeout:
+			data, _ := json.Marshal(map[string]string{"node_id": event.NodeID, "error": conversation.ErrGenerationTimeout.Error()})
+			st.write([]byte(fmt.Sprintf("event: timeout\ndata: %s\n\n", data)))
 
 		case types.StreamEventError:
 			errMsg := "unknown error"
 			if event.Error != nil {
 				errMsg = event.Error.Error()
 			}
-			writeSSEError(w, flusher, errMsg)
+			s.activity.recordError(errMsg)
+			st.write(sseErrorFrame(errMsg))
 		}
 	}
 }
 
-// writeSSEError writes an SSE error event, properly handling multi-line
-// messages by writing each line with its own "data:" prefix per the SSE spec.
+// writeSuggestions writes a suggestions event with follow-up questions to
+// the reply nodeID. Failures are logged and send no event.
+func (s *Server) writeSuggestions(ctx context.Context, st *sseStream, nodeID string) {
+	suggestions, err := s.convMgr.SuggestFollowUps(ctx, nodeID)
+	if err != nil {
+		slog.WarnContext(ctx, "api: failed to suggest follow-ups", "node_id", nodeID, "error", err)
+		return
+	}
+	if len(suggestions) == 0 {
+		return
+	}
+	data, _ := json.Marshal(SuggestionsResponse{NodeID: nodeID, Suggestions: suggestions})
+	st.write([]byte(fmt.Sprintf("event: suggestions\ndata: %s\n\n", data)))
+}
+
+// setSSEHeaders sets the headers of an SSE response.
+func setSSEHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+}
+
+// writeSSEError writes an SSE error event.
 func writeSSEError(w http.ResponseWriter, flusher http.Flusher, message string) {
-	fmt.Fprintf(w, "event: error\n")
+	w.Write(sseErrorFrame(message))
+	flusher.Flush()
+}
+
+// sseErrorFrame formats an SSE error event, properly handling multi-line
+// messages by writing each line with its own "data:" prefix per the SSE spec.
+func sseErrorFrame(message string) []byte {
+	var b strings.Builder
+	b.WriteString("event: error\n")
 	for _, line := range strings.Split(message, "\n") {
-		fmt.Fprintf(w, "data: %s\n", line)
+		fmt.Fprintf(&b, "data: %s\n", line)
 	}
-	fmt.Fprintf(w, "\n")
-	flusher.Flush()
+	b.WriteString("\n")
+	return []byte(b.String())
 }
 
 func promptResponseFromNode(nodeID, content string, node *types.Node) PromptResponse {
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"langdag.com/langdag/internal/conversation"
+	"langdag.com/langdag/types"
+)
+
+// CommentRequest is the request body for commenting on a node.
+type CommentRequest struct {
+	Body    string `json:"body"`
+	ReplyTo string `json:"reply_to,omitempty"` // ID of the comment answered
+}
+
+// handleListComments returns the comments on a node and its descendants,
+// oldest first: on a root, every comment of the DAG.
+func (s *Server) handleListComments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	node, err := s.convMgr.ResolveNode(ctx, r.PathValue("id"))
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	if node == nil {
+		writeError(w, http.StatusNotFound, "node not found")
+		return
+	}
+	comments, err := s.convMgr.Comments(ctx, node.ID)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	if comments == nil {
+		comments = []*types.Comment{}
+	}
+	writeJSON(w, http.StatusOK, comments)
+}
+
+// handleCreateComment leaves a comment on a node, signed with the name of
+// the request's API key.
+func (s *Server) handleCreateComment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req CommentRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	node, err := s.convMgr.ResolveNode(ctx, r.PathValue("id"))
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	if node == nil {
+		writeError(w, http.StatusNotFound, "node not found")
+		return
+	}
+	comment, err := s.convMgr.AddComment(ctx, node.ID, req.ReplyTo, commentAuthor(r), req.Body)
+	if errors.Is(err, conversation.ErrInvalidComment) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, comment)
+}
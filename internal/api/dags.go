@@ -1,8 +1,16 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"langdag.com/langdag/internal/conversation"
 	"langdag.com/langdag/types"
 )
 
@@ -27,24 +35,37 @@ type NodeResponse struct {
 	Status              string                       `json:"status,omitempty"`
 	Title               string                       `json:"title,omitempty"`
 	SystemPrompt        string                       `json:"system_prompt,omitempty"`
+	ArchivedURI         string                       `json:"archived_uri,omitempty"`
+	ForkedFromDAG       string                       `json:"forked_from_dag,omitempty"`
+	ForkedFromNode      string                       `json:"forked_from_node,omitempty"`
+	Tags                []string                     `json:"tags,omitempty"`
 	CreatedAt           string                       `json:"created_at"`
 	Metadata            *types.AssistantNodeMetadata `json:"metadata,omitempty"`
 	Cost                *types.CostResult            `json:"cost,omitempty"`
+	InjectionWarnings   []string                     `json:"injection_warnings,omitempty"`
+	Meta                bool                         `json:"meta,omitempty"`
+	Glossary            []string                     `json:"glossary,omitempty"`
+	Owner               string                       `json:"owner,omitempty"`
+	Visibility          string                       `json:"visibility,omitempty"`
 }
 
-// handleListNodes returns all root nodes ("list DAGs").
+// handleListNodes returns all root nodes ("list DAGs") visible to the
+// request, optionally only those with the tag given in ?tag=.
 func (s *Server) handleListNodes(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	roots, err := s.convMgr.ListRoots(ctx)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeServerError(w, err)
 		return
 	}
 
-	response := make([]NodeResponse, len(roots))
-	for i, n := range roots {
-		response[i] = toNodeResponse(n)
+	tag := r.URL.Query().Get("tag")
+	response := make([]NodeResponse, 0, len(roots))
+	for _, n := range roots {
+		if (tag == "" || conversation.HasTag(n, tag)) && conversation.VisibleTo(ctx, n) {
+			response = append(response, toNodeResponse(n))
+		}
 	}
 
 	writeJSON(w, http.StatusOK, response)
@@ -57,7 +78,7 @@ func (s *Server) handleGetNode(w http.ResponseWriter, r *http.Request) {
 
 	node, err := s.convMgr.ResolveNode(ctx, nodeID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeServerError(w, err)
 		return
 	}
 	if node == nil {
@@ -76,7 +97,7 @@ func (s *Server) handleGetTree(w http.ResponseWriter, r *http.Request) {
 
 	node, err := s.convMgr.ResolveNode(ctx, nodeID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeServerError(w, err)
 		return
 	}
 	if node == nil {
@@ -92,7 +113,7 @@ func (s *Server) handleGetTree(w http.ResponseWriter, r *http.Request) {
 
 	nodes, err := s.convMgr.GetSubtree(ctx, rootID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeServerError(w, err)
 		return
 	}
 
@@ -101,9 +122,333 @@ func (s *Server) handleGetTree(w http.ResponseWriter, r *http.Request) {
 		response[i] = toNodeResponse(n)
 	}
 
+	// The ETag lets clients polling a tree skip unchanged ones.
+	data, err := json.Marshal(response)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(data, '\n'))
+}
+
+// etagMatches reports whether an If-None-Match header lists etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchMatchResponse represents a node matched by a search.
+type SearchMatchResponse struct {
+	Node    NodeResponse `json:"node"`
+	Path    []string     `json:"path"`
+	Snippet string       `json:"snippet"`
+	Score   float64      `json:"score,omitempty"` // semantic search only
+}
+
+// handleSearchTree searches the content of every node in the DAG containing
+// the given node.
+func (s *Server) handleSearchTree(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	nodeID := r.PathValue("id")
+	query := r.URL.Query().Get("q")
+
+	if strings.TrimSpace(query) == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+
+	node, err := s.convMgr.ResolveNode(ctx, nodeID)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	if node == nil {
+		writeError(w, http.StatusNotFound, "node not found")
+		return
+	}
+
+	matches, err := s.convMgr.SearchTree(ctx, node.ID, query)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+
+	response := make([]SearchMatchResponse, len(matches))
+	for i, m := range matches {
+		response[i] = SearchMatchResponse{
+			Node:    toNodeResponse(m.Node),
+			Path:    m.Path,
+			Snippet: m.Snippet,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleSearch searches the content of nodes in every DAG.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	matches, err := s.convMgr.Search(r.Context(), query, limit)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+
+	response := make([]SearchMatchResponse, len(matches))
+	for i, m := range matches {
+		response[i] = SearchMatchResponse{
+			Node:    toNodeResponse(m.Node),
+			Path:    m.Path,
+			Snippet: m.Snippet,
+		}
+	}
+
 	writeJSON(w, http.StatusOK, response)
 }
 
+// handleSemanticSearch finds the nodes in every DAG closest in meaning to
+// the query, using the embeddings of their content.
+func (s *Server) handleSemanticSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	matches, err := s.convMgr.SemanticSearch(r.Context(), query, limit)
+	if errors.Is(err, conversation.ErrEmbeddingsDisabled) || errors.Is(err, types.ErrEmbeddingsNotSupported) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+
+	response := make([]SearchMatchResponse, len(matches))
+	for i, m := range matches {
+		response[i] = SearchMatchResponse{
+			Node:    toNodeResponse(m.Node),
+			Path:    m.Path,
+			Snippet: m.Snippet,
+			Score:   m.Score,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// CancelResponse reports the result of cancelling a DAG's generations.
+type CancelResponse struct {
+	RootID    string `json:"root_id"`
+	Cancelled int    `json:"cancelled"`
+}
+
+// handleCancelTree stops all generations running in the DAG containing the
+// given node. Partial output is saved with status "cancelled".
+func (s *Server) handleCancelTree(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	nodeID := r.PathValue("id")
+
+	node, err := s.convMgr.ResolveNode(ctx, nodeID)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	if node == nil {
+		writeError(w, http.StatusNotFound, "node not found")
+		return
+	}
+
+	n, err := s.convMgr.CancelTree(ctx, node.ID)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+
+	rootID := node.RootID
+	if rootID == "" {
+		rootID = node.ID
+	}
+	writeJSON(w, http.StatusOK, CancelResponse{RootID: rootID, Cancelled: n})
+}
+
+// CloneResponse is the response for cloning a conversation into a new DAG.
+type CloneResponse struct {
+	Root NodeResponse `json:"root"` // root of the new DAG
+	Node NodeResponse `json:"node"` // copy of the node cloned from
+}
+
+// handleClone copies the path from the root to a node into a new DAG.
+func (s *Server) handleClone(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	nodeID := r.PathValue("id")
+
+	node, err := s.convMgr.ResolveNode(ctx, nodeID)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	if node == nil {
+		writeError(w, http.StatusNotFound, "node not found")
+		return
+	}
+
+	path, err := s.convMgr.Clone(ctx, node.ID)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, CloneResponse{
+		Root: toNodeResponse(path[0]),
+		Node: toNodeResponse(path[len(path)-1]),
+	})
+}
+
+// SummarizeRequest is the optional body of a summarize request. Without a
+// model, the DAG's model is used.
+type SummarizeRequest struct {
+	Model    string                 `json:"model,omitempty"`
+	Metadata *types.RequestMetadata `json:"metadata,omitempty"`
+}
+
+// handleSummarize summarizes the DAG (on a root) or the branch ending at a
+// node and returns the stored summary node.
+func (s *Server) handleSummarize(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	nodeID := r.PathValue("id")
+
+	var req SummarizeRequest
+	// The body is optional.
+	if err := decodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	node, err := s.convMgr.ResolveNode(ctx, nodeID)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	if node == nil {
+		writeError(w, http.StatusNotFound, "node not found")
+		return
+	}
+
+	ctx = conversation.ContextWithRequestMetadata(ctx, req.Metadata)
+	summary, err := s.convMgr.Summarize(ctx, node.ID, req.Model)
+	if err != nil {
+		s.activity.recordError(err.Error())
+		writeServerError(w, err)
+		return
+	}
+	s.recordCompletion(r, summary)
+	writeJSON(w, http.StatusCreated, toNodeResponse(summary))
+}
+
+// UpdateDAGRequest is the request body for updating a DAG. Omitted fields
+// are left unchanged.
+type UpdateDAGRequest struct {
+	Title        *string                 `json:"title,omitempty"`
+	SystemPrompt *string                 `json:"system_prompt,omitempty"`
+	Model        *string                 `json:"model,omitempty"`
+	Tools        *[]types.ToolDefinition `json:"tools,omitempty"`
+	Language     *string                 `json:"language,omitempty"`
+	Visibility   *string                 `json:"visibility,omitempty"`
+}
+
+// handleUpdateDAG changes the title, system prompt, default model, default
+// tools, reply language or visibility of the DAG containing a node and
+// returns its root. Only the owner's key (or the server key) may change
+// the visibility of a DAG.
+func (s *Server) handleUpdateDAG(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	nodeID := r.PathValue("id")
+
+	var req UpdateDAGRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	node, err := s.convMgr.ResolveNode(ctx, nodeID)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	if node == nil {
+		writeError(w, http.StatusNotFound, "node not found")
+		return
+	}
+
+	if key := requestKey(r); key != nil && req.Visibility != nil {
+		root, err := s.dagRoot(ctx, node.ID)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		if conversation.Owner(root) != key.ID {
+			writeError(w, http.StatusForbidden, "only the owner of the DAG may change its visibility")
+			return
+		}
+	}
+
+	root, err := s.convMgr.UpdateDAG(ctx, node.ID, conversation.DAGUpdate{
+		Title:        req.Title,
+		SystemPrompt: req.SystemPrompt,
+		Model:        req.Model,
+		Tools:        req.Tools,
+		Language:     req.Language,
+		Visibility:   req.Visibility,
+	})
+	if errors.Is(err, conversation.ErrInvalidUpdate) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toNodeResponse(root))
+}
+
 // handleDeleteNode deletes a node and its subtree.
 func (s *Server) handleDeleteNode(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -111,7 +456,7 @@ func (s *Server) handleDeleteNode(w http.ResponseWriter, r *http.Request) {
 
 	node, err := s.convMgr.ResolveNode(ctx, nodeID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeServerError(w, err)
 		return
 	}
 	if node == nil {
@@ -120,7 +465,7 @@ func (s *Server) handleDeleteNode(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := s.convMgr.DeleteNode(ctx, node.ID); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeServerError(w, err)
 		return
 	}
 
@@ -135,7 +480,7 @@ func (s *Server) handleCreateAlias(w http.ResponseWriter, r *http.Request) {
 
 	node, err := s.convMgr.ResolveNode(ctx, nodeID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeServerError(w, err)
 		return
 	}
 	if node == nil {
@@ -158,7 +503,7 @@ func (s *Server) handleListAliases(w http.ResponseWriter, r *http.Request) {
 
 	node, err := s.convMgr.ResolveNode(ctx, nodeID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeServerError(w, err)
 		return
 	}
 	if node == nil {
@@ -168,7 +513,7 @@ func (s *Server) handleListAliases(w http.ResponseWriter, r *http.Request) {
 
 	aliases, err := s.convMgr.ListAliases(ctx, node.ID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeServerError(w, err)
 		return
 	}
 
@@ -184,7 +529,7 @@ func (s *Server) handleDeleteAlias(w http.ResponseWriter, r *http.Request) {
 	alias := r.PathValue("alias")
 
 	if err := s.convMgr.DeleteAlias(r.Context(), alias); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeServerError(w, err)
 		return
 	}
 
@@ -193,6 +538,19 @@ func (s *Server) handleDeleteAlias(w http.ResponseWriter, r *http.Request) {
 
 func toNodeResponse(n *types.Node) NodeResponse {
 	metadata := nodeMetadata(n)
+	var injectionWarnings []string
+	var meta bool
+	var glossary []string
+	var owner, visibility string
+	if userMeta := types.UserMetadataFromNode(n); userMeta != nil {
+		injectionWarnings = userMeta.InjectionWarnings
+		meta = userMeta.Meta
+		glossary = userMeta.Glossary
+		owner = userMeta.Owner
+	}
+	if n.ParentID == "" {
+		visibility = conversation.Visibility(n)
+	}
 	return NodeResponse{
 		ID:                  n.ID,
 		ParentID:            n.ParentID,
@@ -213,9 +571,18 @@ func toNodeResponse(n *types.Node) NodeResponse {
 		Status:              n.Status,
 		Title:               n.Title,
 		SystemPrompt:        n.SystemPrompt,
+		ArchivedURI:         n.ArchivedURI,
+		ForkedFromDAG:       n.ForkedFromDAG,
+		ForkedFromNode:      n.ForkedFromNode,
+		Tags:                n.Tags,
 		CreatedAt:           n.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		Metadata:            metadata,
 		Cost:                costFromMetadata(metadata),
+		InjectionWarnings:   injectionWarnings,
+		Meta:                meta,
+		Glossary:            glossary,
+		Owner:               owner,
+		Visibility:          visibility,
 	}
 }
 
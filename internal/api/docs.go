@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	apispec "langdag.com/langdag/api"
+)
+
+var (
+	openAPIOnce sync.Once
+	openAPIJSON []byte
+	openAPIErr  error
+)
+
+// openAPIDocument returns the embedded OpenAPI document as JSON.
+func openAPIDocument() ([]byte, error) {
+	openAPIOnce.Do(func() {
+		var doc map[string]any
+		if openAPIErr = yaml.Unmarshal(apispec.OpenAPI, &doc); openAPIErr != nil {
+			return
+		}
+		openAPIJSON, openAPIErr = json.Marshal(doc)
+	})
+	return openAPIJSON, openAPIErr
+}
+
+// handleOpenAPI serves the OpenAPI document of the API. Like /schema/tool,
+// it needs no API key, so clients and code generators can fetch it.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	doc, err := openAPIDocument()
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(doc)
+}
+
+// docsPage renders /openapi.json with Swagger UI, loaded from a CDN.
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>LangDAG API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>
+SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`
+
+// handleDocs serves an API explorer for the OpenAPI document.
+func (s *Server) handleDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(docsPage))
+}
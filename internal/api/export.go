@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"langdag.com/langdag/internal/conversation"
+)
+
+// exportContentTypes maps export formats to response content types.
+var exportContentTypes = map[string]string{
+	conversation.ExportJSON:     "application/json",
+	conversation.ExportYAML:     "application/yaml",
+	conversation.ExportMarkdown: "text/markdown; charset=utf-8",
+}
+
+// handleExport returns the whole DAG containing a node as JSON (default),
+// YAML or Markdown, selected with ?format=.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	nodeID := r.PathValue("id")
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = conversation.ExportJSON
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "format must be json, yaml or markdown")
+		return
+	}
+
+	node, err := s.convMgr.ResolveNode(ctx, nodeID)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	if node == nil {
+		writeError(w, http.StatusNotFound, "node not found")
+		return
+	}
+
+	dag, err := s.convMgr.Export(ctx, node.ID)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	conversation.EncodeExport(w, dag, format)
+}
+
+// handleImport stores a JSON or YAML DAG export and returns its root node.
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	dag, err := conversation.DecodeExport(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	root, err := s.convMgr.Import(r.Context(), dag)
+	if errors.Is(err, conversation.ErrDAGExists) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, conversation.ErrInvalidExport) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toNodeResponse(root))
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net/http"
+
+	"langdag.com/langdag/internal/config"
+	"langdag.com/langdag/internal/provider"
+)
+
+// FeaturesResponse lists the capabilities of this server so clients can
+// adapt their UI instead of probing with requests that fail.
+type FeaturesResponse struct {
+	// Providers are the providers (or catalog deployments) the server can
+	// route generations to. Unavailable ones are skipped at startup.
+	Providers []string `json:"providers"`
+
+	// Auth is "api_key" when requests must carry the server API key or a
+	// stored key, or "none".
+	Auth string `json:"auth"`
+
+	// Guest reports whether clients without an API key may start DAGs,
+	// tagged "guest", and use those.
+	Guest bool `json:"guest"`
+
+	// Speculation reports whether replies to likely follow-ups are
+	// generated ahead of time (GET /nodes/{id}/suggestions lists them).
+	Speculation bool `json:"speculation"`
+
+	// Storage is the storage driver: "sqlite" or "memory".
+	Storage string `json:"storage"`
+
+	// Tools reports whether at least one served model accepts client-defined
+	// function tools.
+	Tools bool `json:"tools"`
+
+	// Vision reports whether at least one served model accepts images.
+	Vision bool `json:"vision"`
+
+	// JSONMode reports whether at least one served model can constrain its
+	// output to valid JSON.
+	JSONMode bool `json:"json_mode"`
+
+	// MaxContext is the largest context window, in tokens, among the served
+	// models, or 0 when unknown.
+	MaxContext int `json:"max_context,omitempty"`
+
+	// BuiltinTools are the built-in tools enabled in the server config,
+	// which requests list by name and the server runs itself.
+	BuiltinTools []string `json:"builtin_tools"`
+
+	// Presets are the names of the configured prompt presets.
+	Presets []string `json:"presets"`
+
+	// InjectionScan reports whether tool results are scanned for prompt
+	// injection.
+	InjectionScan bool `json:"injection_scan"`
+
+	// Callbacks reports whether prompt requests accept a callback_url.
+	Callbacks bool `json:"callbacks"`
+
+	// Embeddings reports whether node content is embedded, enabling
+	// semantic search (GET /search/semantic).
+	Embeddings bool `json:"embeddings"`
+
+	// Capabilities this server does not provide. They are listed explicitly
+	// so clients can hide the corresponding UI.
+	MultiUser bool `json:"multi_user"`
+	Workflows bool `json:"workflows"`
+}
+
+// newFeatures describes the server built from cfg, appConfig and prov.
+func newFeatures(cfg *Config, appConfig *config.Config, prov provider.Provider) FeaturesResponse {
+	f := FeaturesResponse{
+		Providers:     providerNames(prov),
+		Auth:          "none",
+		Storage:       "sqlite",
+		Presets:       sortedKeys(appConfig.Presets),
+		BuiltinTools:  []string{},
+		InjectionScan: appConfig.Safety.InjectionScan.Enabled,
+		Guest:         appConfig.Server.Guest.Enabled,
+		Speculation:   appConfig.Speculation.Enabled,
+		Embeddings:    appConfig.Embeddings.Enabled,
+		Callbacks:     len(appConfig.Server.Callbacks.AllowedHosts) > 0,
+	}
+	if cfg.APIKey != "" {
+		f.Auth = "api_key"
+	}
+	if cfg.Ephemeral || appConfig.Storage.Driver == "memory" {
+		f.Storage = "memory"
+	}
+	caps := prov.Capabilities()
+	f.Tools, f.Vision, f.JSONMode, f.MaxContext = caps.Tools, caps.Vision, caps.JSONMode, caps.MaxContext
+	return f
+}
+
+// providerNames returns the providers behind prov, looking through the
+// routers built by createProvider.
+func providerNames(prov provider.Provider) []string {
+	switch p := prov.(type) {
+	case *provider.Router:
+		return p.Providers()
+	case *provider.DeploymentRouter:
+		return p.Deployments()
+	default:
+		return []string{prov.Name()}
+	}
+}
+
+// handleFeatures returns the server's capabilities.
+func (s *Server) handleFeatures(w http.ResponseWriter, r *http.Request) {
+	f := s.features
+	if f.Auth == "none" && s.hasStoredKeys(r.Context()) {
+		f.Auth = "api_key"
+	}
+	writeJSON(w, http.StatusOK, f)
+}
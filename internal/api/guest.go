@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"langdag.com/langdag/internal/config"
+	"langdag.com/langdag/internal/conversation"
+)
+
+const (
+	defaultGuestTTL               = 24 * time.Hour
+	defaultGuestRequestsPerMinute = 10
+)
+
+// guestRoutes are the routes guests may use: starting a DAG, and reading
+// and continuing guest DAGs.
+var guestRoutes = map[string]bool{
+	"POST /prompt":            true,
+	"POST /nodes/{id}/prompt": true,
+	"GET /nodes/{id}":         true,
+	"GET /nodes/{id}/tree":    true,
+}
+
+// guestMode lets clients without an API key use guest DAGs, with a
+// per-address request limit.
+type guestMode struct {
+	ttl   time.Duration
+	limit int // requests per minute per client address
+
+	mu      sync.Mutex
+	windows map[string]*guestWindow // client address -> current minute
+}
+
+type guestWindow struct {
+	start time.Time
+	count int
+}
+
+// newGuestMode returns the guest mode configured by cfg, or nil when it is
+// disabled.
+func newGuestMode(cfg config.GuestConfig) (*guestMode, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	g := &guestMode{
+		ttl:     defaultGuestTTL,
+		limit:   defaultGuestRequestsPerMinute,
+		windows: make(map[string]*guestWindow),
+	}
+	if cfg.TTL != "" {
+		d, err := time.ParseDuration(cfg.TTL)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid server.guest.ttl %q", cfg.TTL)
+		}
+		g.ttl = d
+	}
+	if cfg.RequestsPerMinute < 0 {
+		return nil, fmt.Errorf("invalid server.guest.requests_per_minute %d", cfg.RequestsPerMinute)
+	}
+	if cfg.RequestsPerMinute > 0 {
+		g.limit = cfg.RequestsPerMinute
+	}
+	return g, nil
+}
+
+// allow counts a request from addr and reports whether it is within the
+// limit, or else how long until the next one is.
+func (g *guestMode) allow(addr string, now time.Time) (time.Duration, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	w := g.windows[addr]
+	if w == nil || now.Sub(w.start) >= time.Minute {
+		if len(g.windows) >= 10000 {
+			g.dropExpired(now)
+		}
+		w = &guestWindow{start: now}
+		g.windows[addr] = w
+	}
+	if w.count >= g.limit {
+		return w.start.Add(time.Minute).Sub(now), false
+	}
+	w.count++
+	return 0, true
+}
+
+func (g *guestMode) dropExpired(now time.Time) {
+	for addr, w := range g.windows {
+		if now.Sub(w.start) >= time.Minute {
+			delete(g.windows, addr)
+		}
+	}
+}
+
+// admitGuest serves r as a guest request if guest mode allows it. It
+// returns the request to serve, with new DAGs marked as guest DAGs and
+// built-in tools denied, and whether it may proceed; if not, the error response has been written.
+func (s *Server) admitGuest(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if s.guest == nil || !guestRoutes[r.Pattern] {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return nil, false
+	}
+	if wait, ok := s.guest.allow(clientAddr(r), time.Now()); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		writeError(w, http.StatusTooManyRequests, "guest rate limit exceeded, retry later")
+		return nil, false
+	}
+	if id := r.PathValue("id"); id != "" {
+		root, err := s.dagRoot(r.Context(), id)
+		if err != nil {
+			writeServerError(w, err)
+			return nil, false
+		}
+		// Other DAGs are reported missing, so guests can't probe IDs.
+		if root == nil || !conversation.IsGuest(root) {
+			writeError(w, http.StatusNotFound, "node not found")
+			return nil, false
+		}
+	}
+	ctx := conversation.ContextWithAPIKey(r.Context(), "")
+	ctx = conversation.ContextWithoutBuiltinTools(ctx)
+	return r.WithContext(conversation.ContextAsGuest(ctx)), true
+}
+
+// clientAddr returns the IP address of the client of r.
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// purgeGuests deletes expired guest DAGs every tenth of the guest TTL
+// (between a minute and an hour) until ctx is done.
+func (s *Server) purgeGuests(ctx context.Context) {
+	interval := min(max(s.guest.ttl/10, time.Minute), time.Hour)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.convMgr.PurgeGuests(ctx, time.Now().Add(-s.guest.ttl))
+			if err != nil {
+				slog.WarnContext(ctx, "api: failed to purge guest DAGs", "error", err)
+			} else if n > 0 {
+				slog.InfoContext(ctx, "api: purged guest DAGs", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"langdag.com/langdag/internal/config"
+	"langdag.com/langdag/internal/conversation"
+)
+
+func TestGuestAccess(t *testing.T) {
+	s, mux := testServer(t, "secret")
+	guest, err := newGuestMode(config.GuestConfig{Enabled: true, RequestsPerMinute: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.guest = guest
+
+	do := func(method, path, key string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"message":"Hi"}`))
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/prompt", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("guest prompt: status = %d; body = %s", w.Code, w.Body.String())
+	}
+	var guestReply PromptResponse
+	json.NewDecoder(w.Body).Decode(&guestReply)
+	node, err := s.store.GetNode(context.Background(), guestReply.NodeID)
+	if err != nil || node == nil {
+		t.Fatalf("reply node: %v, %v", node, err)
+	}
+	root, _ := s.store.GetNode(context.Background(), node.RootID)
+	if !conversation.IsGuest(root) || !conversation.HasTag(root, conversation.GuestTag) {
+		t.Errorf("guest DAG: metadata %s, tags %v; want a tagged guest DAG", root.Metadata, root.Tags)
+	}
+
+	w = do("POST", "/prompt", "secret")
+	var memberReply PromptResponse
+	json.NewDecoder(w.Body).Decode(&memberReply)
+
+	if w := do("GET", "/nodes/"+guestReply.NodeID+"/tree", ""); w.Code != http.StatusOK {
+		t.Errorf("guest tree: status = %d", w.Code)
+	}
+	if w := do("GET", "/nodes/"+memberReply.NodeID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("other DAG as guest: status = %d, want 404", w.Code)
+	}
+	if w := do("GET", "/nodes", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("list as guest: status = %d, want 401", w.Code)
+	}
+	if w := do("GET", "/nodes/"+guestReply.NodeID, "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong key: status = %d, want 401", w.Code)
+	}
+
+	// 3 guest requests so far; the 4th is allowed, the 5th is limited.
+	if w := do("GET", "/nodes/"+guestReply.NodeID, ""); w.Code != http.StatusOK {
+		t.Errorf("guest get: status = %d", w.Code)
+	}
+	w = do("GET", "/nodes/"+guestReply.NodeID, "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("over the limit: status = %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := do("GET", "/nodes", "secret"); w.Code != http.StatusOK {
+		t.Errorf("keyed requests are not limited: status = %d", w.Code)
+	}
+}
+
+func TestGuestBuiltinToolsDenied(t *testing.T) {
+	s, mux := testServer(t, "secret")
+	guest, err := newGuestMode(config.GuestConfig{Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.guest = guest
+
+	req := httptest.NewRequest("POST", "/prompt", strings.NewReader(`{"message":"Hi","tools":[{"name":"shell"}]}`))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("guest prompt with shell: status = %d, want 403; body = %s", w.Code, w.Body.String())
+	}
+}
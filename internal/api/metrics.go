@@ -0,0 +1,155 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"langdag.com/langdag/types"
+)
+
+// usageLabels identifies one series of usage counters.
+type usageLabels struct {
+	apiKey   string // hashed, see apiKeyLabel
+	model    string
+	provider string
+}
+
+// usageCounters are the counters kept for one label set.
+type usageCounters struct {
+	generations int64
+	tokens      map[string]int64   // by token type
+	cost        map[string]float64 // by currency
+}
+
+// usageMetrics accumulates token and cost counters for completed
+// generations, exported in Prometheus text format at GET /metrics.
+// Counters live in memory and reset when the server restarts.
+type usageMetrics struct {
+	mu     sync.Mutex
+	series map[usageLabels]*usageCounters
+}
+
+func newUsageMetrics() *usageMetrics {
+	return &usageMetrics{series: make(map[usageLabels]*usageCounters)}
+}
+
+// record adds the usage of a saved assistant node.
+func (m *usageMetrics) record(apiKey string, node *types.Node) {
+	if m == nil || node == nil || node.NodeType != types.NodeTypeAssistant {
+		return
+	}
+	labels := usageLabels{apiKey: apiKey, model: node.Model, provider: node.Provider}
+	var cost *types.CostResult
+	if metadata := nodeMetadata(node); metadata != nil {
+		cost = costFromMetadata(metadata)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := m.series[labels]
+	if c == nil {
+		c = &usageCounters{tokens: make(map[string]int64), cost: make(map[string]float64)}
+		m.series[labels] = c
+	}
+	c.generations++
+	c.tokens["input"] += int64(node.TokensIn)
+	c.tokens["output"] += int64(node.TokensOut)
+	c.tokens["cache_read"] += int64(node.TokensCacheRead)
+	c.tokens["cache_creation"] += int64(node.TokensCacheCreation)
+	c.tokens["reasoning"] += int64(node.TokensReasoning)
+	if cost != nil && cost.Total > 0 && cost.Currency != "" &&
+		(cost.Status == types.CostStatusKnown || cost.Status == types.CostStatusPartial) {
+		c.cost[cost.Currency] += cost.Total
+	}
+}
+
+// writeTo renders the counters in Prometheus text exposition format,
+// sorted so output is stable between scrapes.
+func (m *usageMetrics) writeTo(b *strings.Builder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]usageLabels, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, c := keys[i], keys[j]
+		if a.apiKey != c.apiKey {
+			return a.apiKey < c.apiKey
+		}
+		if a.provider != c.provider {
+			return a.provider < c.provider
+		}
+		return a.model < c.model
+	})
+
+	b.WriteString("# HELP langdag_generations_total Completed generations.\n")
+	b.WriteString("# TYPE langdag_generations_total counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(b, "langdag_generations_total{%s} %d\n", k.format(), m.series[k].generations)
+	}
+
+	b.WriteString("# HELP langdag_tokens_total Tokens used by completed generations, by token type.\n")
+	b.WriteString("# TYPE langdag_tokens_total counter\n")
+	for _, k := range keys {
+		tokens := m.series[k].tokens
+		for _, typ := range sortedKeys(tokens) {
+			fmt.Fprintf(b, "langdag_tokens_total{%s,type=%s} %d\n", k.format(), labelValue(typ), tokens[typ])
+		}
+	}
+
+	b.WriteString("# HELP langdag_cost_total Estimated cost of completed generations with known pricing.\n")
+	b.WriteString("# TYPE langdag_cost_total counter\n")
+	for _, k := range keys {
+		cost := m.series[k].cost
+		for _, currency := range sortedKeys(cost) {
+			fmt.Fprintf(b, "langdag_cost_total{%s,currency=%s} %g\n", k.format(), labelValue(currency), cost[currency])
+		}
+	}
+}
+
+func (l usageLabels) format() string {
+	return fmt.Sprintf("api_key=%s,model=%s,provider=%s", labelValue(l.apiKey), labelValue(l.model), labelValue(l.provider))
+}
+
+// labelValue quotes v as a Prometheus label value.
+func labelValue(v string) string {
+	v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+	return `"` + v + `"`
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// apiKeyLabel identifies the API key a request authenticated with by the
+// first 12 hex characters of its SHA-256, so keys never appear in metrics.
+// It is "none" when the request carried no key.
+func apiKeyLabel(r *http.Request) string {
+	key := requestAPIKey(r)
+	if key == "" {
+		return "none"
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// handleMetrics serves usage counters in Prometheus text format.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	s.metrics.writeTo(&b)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(b.String()))
+}
@@ -2,6 +2,8 @@ package api
 
 import (
 	"os"
+	"regexp"
+	"strings"
 	"testing"
 
 	"gopkg.in/yaml.v3"
@@ -59,7 +61,28 @@ func TestOpenAPIOutputGroupIDContract(t *testing.T) {
 	}
 }
 
-func openAPISchemas(t *testing.T) map[string]any {
+// TestOpenAPICoversRoutes keeps the document in sync with the routes
+// registered by New.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	src, err := os.ReadFile("server.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := mapValue(t, openAPIYAML(t), "paths")
+	routes := regexp.MustCompile(`mux\.HandleFunc\("([A-Z]+) ([^"]+)"`).FindAllStringSubmatch(string(src), -1)
+	if len(routes) == 0 {
+		t.Fatal("no routes found in server.go")
+	}
+	for _, route := range routes {
+		method, path := strings.ToLower(route[1]), route[2]
+		ops, ok := paths[path].(map[string]any)
+		if !ok || ops[method] == nil {
+			t.Errorf("%s %s is not documented in api/openapi.yaml", route[1], path)
+		}
+	}
+}
+
+func openAPIYAML(t *testing.T) map[string]any {
 	t.Helper()
 	data, err := os.ReadFile("../../api/openapi.yaml")
 	if err != nil {
@@ -69,7 +92,12 @@ func openAPISchemas(t *testing.T) map[string]any {
 	if err := yaml.Unmarshal(data, &doc); err != nil {
 		t.Fatalf("parse openapi: %v", err)
 	}
-	return mapValue(t, mapValue(t, doc, "components"), "schemas")
+	return doc
+}
+
+func openAPISchemas(t *testing.T) map[string]any {
+	t.Helper()
+	return mapValue(t, mapValue(t, openAPIYAML(t), "components"), "schemas")
 }
 
 func schemaMap(t *testing.T, schemas map[string]any, name string) map[string]any {
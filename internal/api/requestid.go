@@ -0,0 +1,65 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"langdag.com/langdag/internal/logging"
+)
+
+// requestIDHeader carries the ID of a request, set by the client or
+// generated by the server, and is echoed in the response.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDMiddleware gives each request an ID, so the log records of a
+// request can be told apart, and logs the method, path, status and
+// duration of each request once it is done.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		ctx := logging.WithRequestID(r.Context(), id)
+
+		start := time.Now()
+		rw := &accessLogWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		switch {
+		case rw.status < 400 && r.URL.Path == "/health":
+			level = slog.LevelDebug // keep probes out of the log
+		case rw.status >= 500:
+			level = slog.LevelError
+		case rw.status >= 400:
+			level = slog.LevelWarn
+		}
+		slog.Log(ctx, level, "request", "method", r.Method, "path", r.URL.Path,
+			"status", rw.status, "duration", time.Since(start))
+	})
+}
+
+// validRequestID accepts client IDs of up to 128 printable ASCII
+// characters, so they can't forge log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"langdag.com/langdag/internal/logging"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	handler := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+	}))
+
+	// A client-supplied ID is kept.
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set(requestIDHeader, "client-id-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if seen != "client-id-1" || rec.Header().Get(requestIDHeader) != "client-id-1" {
+		t.Errorf("request ID = %q, header %q, want client-id-1", seen, rec.Header().Get(requestIDHeader))
+	}
+
+	// A missing or unsafe ID is replaced.
+	for _, id := range []string{"", "bad id\nforged=1", strings.Repeat("x", 129)} {
+		req := httptest.NewRequest("GET", "/health", nil)
+		req.Header.Set(requestIDHeader, id)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if len(seen) != 16 || seen == id || rec.Header().Get(requestIDHeader) != seen {
+			t.Errorf("for %q: request ID = %q, header %q", id, seen, rec.Header().Get(requestIDHeader))
+		}
+	}
+}
+
+func TestRequestIDMiddleware_ErrorResponse(t *testing.T) {
+	handler := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "node not found")
+	}))
+	req := httptest.NewRequest("GET", "/nodes/missing", nil)
+	req.Header.Set(requestIDHeader, "abc123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var resp map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp["error"] != "node not found" || resp["request_id"] != "abc123" {
+		t.Errorf("error response = %v", resp)
+	}
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lastEventIDHeader is sent by SSE clients reconnecting to a stream, with
+// the ID of the last event they received.
+const lastEventIDHeader = "Last-Event-ID"
+
+const (
+	// resumeWindow is how long a streamed generation keeps running after
+	// its client disconnected, waiting for it to reconnect.
+	resumeWindow = 30 * time.Second
+	// streamRetention is how long the events of a finished stream are
+	// kept for clients reconnecting late.
+	streamRetention = time.Minute
+)
+
+// sseStream buffers the events of a streamed generation, so that a client
+// that lost its connection can reconnect and get the events it missed.
+// Event IDs are "<stream ID>.<index>".
+type sseStream struct {
+	id     string
+	cancel context.CancelFunc // stops the generation
+
+	mu      sync.Mutex
+	frames  [][]byte // "event: ...\ndata: ...\n\n", without the id line
+	done    bool
+	wake    chan struct{} // closed when frames or done change
+	clients int
+	idle    *time.Timer // cancels the generation when no client came back
+}
+
+// streamRegistry holds the streams that can be resumed.
+type streamRegistry struct {
+	mu      sync.Mutex
+	streams map[string]*sseStream
+}
+
+// open registers a new stream whose generation is stopped by cancel.
+func (g *streamRegistry) open(cancel context.CancelFunc) *sseStream {
+	var b [16]byte
+	rand.Read(b[:])
+	st := &sseStream{id: hex.EncodeToString(b[:]), cancel: cancel, wake: make(chan struct{})}
+	g.mu.Lock()
+	if g.streams == nil {
+		g.streams = make(map[string]*sseStream)
+	}
+	g.streams[st.id] = st
+	g.mu.Unlock()
+	return st
+}
+
+func (g *streamRegistry) get(id string) *sseStream {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.streams[id]
+}
+
+// finish marks st done and forgets it after streamRetention.
+func (g *streamRegistry) finish(st *sseStream) {
+	st.mu.Lock()
+	st.done = true
+	if st.idle != nil {
+		st.idle.Stop()
+	}
+	close(st.wake)
+	st.wake = make(chan struct{})
+	st.mu.Unlock()
+	st.cancel()
+
+	time.AfterFunc(streamRetention, func() {
+		g.mu.Lock()
+		delete(g.streams, st.id)
+		g.mu.Unlock()
+	})
+}
+
+// write adds an event to st.
+func (st *sseStream) write(frame []byte) {
+	st.mu.Lock()
+	st.frames = append(st.frames, frame)
+	close(st.wake)
+	st.wake = make(chan struct{})
+	st.mu.Unlock()
+}
+
+// serve sends the events of st from index from to w until the stream is
+// done or the client disconnects. Without a client for resumeWindow, the
+// generation is stopped.
+func (st *sseStream) serve(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, from int) {
+	st.mu.Lock()
+	st.clients++
+	if st.idle != nil {
+		st.idle.Stop()
+	}
+	st.mu.Unlock()
+	defer func() {
+		st.mu.Lock()
+		st.clients--
+		if st.clients == 0 && !st.done {
+			st.idle = time.AfterFunc(resumeWindow, st.cancel)
+		}
+		st.mu.Unlock()
+	}()
+
+	for {
+		st.mu.Lock()
+		frames := st.frames[min(from, len(st.frames)):]
+		done, wake := st.done, st.wake
+		st.mu.Unlock()
+
+		for _, frame := range frames {
+			fmt.Fprintf(w, "id: %s.%d\n", st.id, from)
+			w.Write(frame)
+			from++
+		}
+		if len(frames) > 0 {
+			flusher.Flush()
+		}
+		if done {
+			return
+		}
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// parseEventID splits an event ID into its stream ID and event index.
+func parseEventID(id string) (stream string, index int, ok bool) {
+	stream, n, found := strings.Cut(strings.TrimSpace(id), ".")
+	index, err := strconv.Atoi(n)
+	if !found || err != nil || index < 0 {
+		return "", 0, false
+	}
+	return stream, index, true
+}
+
+// resumeStream replays the events of a stream following the one whose ID
+// the client last received, then follows the stream.
+func (s *Server) resumeStream(w http.ResponseWriter, r *http.Request, streamID string, from int) {
+	st := s.streams.get(streamID)
+	if st == nil {
+		writeError(w, http.StatusNotFound, "stream not found or expired")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	setSSEHeaders(w)
+	st.serve(r.Context(), w, flusher, from)
+}
+
+// handleResumeStream reconnects to a stream, for EventSource clients. It
+// replays the stream from the event after the Last-Event-ID header, or
+// from the start without it.
+func (s *Server) handleResumeStream(w http.ResponseWriter, r *http.Request) {
+	from := 0
+	if stream, index, ok := parseEventID(r.Header.Get(lastEventIDHeader)); ok && stream == r.PathValue("id") {
+		from = index + 1
+	}
+	s.resumeStream(w, r, r.PathValue("id"), from)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mockprovider "langdag.com/langdag/internal/provider/mock"
+)
+
+func TestStreamResumesWithLastEventID(t *testing.T) {
+	s, mux := testServerWithMock(t, "", mockprovider.Config{
+		Mode:          "fixed",
+		FixedResponse: "one two three four five six",
+		ChunkDelay:    20 * time.Millisecond,
+	})
+	mux.HandleFunc("GET /streams/{id}", s.authMiddleware(s.handleResumeStream))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/prompt", "application/json", strings.NewReader(`{"message":"Hello","stream":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Read up to the first delta, then drop the connection.
+	var lastID, content string
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended before a delta: %v", err)
+		}
+		if id, ok := strings.CutPrefix(line, "id: "); ok {
+			lastID = strings.TrimSpace(id)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok && strings.Contains(data, `"content"`) {
+			var delta struct{ Content string }
+			json.Unmarshal([]byte(data), &delta)
+			content = delta.Content
+			break
+		}
+	}
+	resp.Body.Close()
+
+	streamID, _, ok := parseEventID(lastID)
+	if !ok {
+		t.Fatalf("event ID %q is not resumable", lastID)
+	}
+	req, _ := http.NewRequest("GET", srv.URL+"/streams/"+streamID, nil)
+	req.Header.Set(lastEventIDHeader, lastID)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("resume status = %d: %s", resp.StatusCode, body)
+	}
+
+	var done PromptResponse
+	for _, e := range parseSSEEvents(string(body)) {
+		switch e.Type {
+		case "start":
+			t.Error("resumed stream replayed events the client had")
+		case "delta":
+			var delta struct{ Content string }
+			json.Unmarshal([]byte(e.Data), &delta)
+			content += delta.Content
+		case "done":
+			json.Unmarshal([]byte(e.Data), &done)
+		}
+	}
+	if done.NodeID == "" {
+		t.Fatalf("resumed stream has no done event: %s", body)
+	}
+	if content != done.Content {
+		t.Errorf("content = %q, want %q", content, done.Content)
+	}
+
+	// Resending the request with the header replays rather than prompting again.
+	req, _ = http.NewRequest("POST", srv.URL+"/prompt", strings.NewReader(`{"message":"Hello","stream":true}`))
+	req.Header.Set(lastEventIDHeader, streamID+".0")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), done.NodeID) {
+		t.Errorf("replay does not end with the saved node: %s", body)
+	}
+
+	req, _ = http.NewRequest("GET", srv.URL+"/streams/unknown", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown stream status = %d, want 404", resp.StatusCode)
+	}
+}
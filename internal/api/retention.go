@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"langdag.com/langdag/internal/config"
+	"langdag.com/langdag/internal/conversation"
+)
+
+// retention is the parsed server.retention config.
+type retention struct {
+	maxAge   time.Duration
+	statuses []string
+}
+
+// newRetention parses cfg, or returns nil when retention is off.
+func newRetention(cfg config.RetentionConfig) (*retention, error) {
+	if cfg.MaxAge == "" {
+		return nil, nil
+	}
+	d, err := time.ParseDuration(cfg.MaxAge)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("invalid server.retention.max_age %q", cfg.MaxAge)
+	}
+	return &retention{maxAge: d, statuses: cfg.Statuses}, nil
+}
+
+// pruneStale deletes the DAGs selected by r every tenth of its max age
+// (between a minute and an hour) until ctx is done.
+func (s *Server) pruneStale(ctx context.Context, r *retention) {
+	ticker := time.NewTicker(min(max(r.maxAge/10, time.Minute), time.Hour))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pruned, err := s.convMgr.Prune(ctx, conversation.PruneOptions{
+				Before:   time.Now().Add(-r.maxAge),
+				Statuses: r.statuses,
+			})
+			if err != nil {
+				slog.WarnContext(ctx, "api: failed to prune stale DAGs", "error", err)
+			}
+			if len(pruned) > 0 {
+				slog.InfoContext(ctx, "api: pruned stale DAGs", "count", len(pruned))
+			}
+		}
+	}
+}
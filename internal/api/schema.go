@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+)
+
+// toolSchema is the JSON Schema of a tool definition (types.ToolDefinition)
+// as accepted in the tools array of prompt requests.
+const toolSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://langdag.com/schema/tool.json",
+  "title": "LangDAG tool definition",
+  "description": "A tool the model can call. Use the name web_search, without an input schema, for the provider's built-in web search.",
+  "type": "object",
+  "required": ["name"],
+  "properties": {
+    "name": {
+      "type": "string",
+      "minLength": 1,
+      "description": "Tool name, unique within a request"
+    },
+    "description": {
+      "type": "string",
+      "description": "What the tool does and when the model should use it"
+    },
+    "input_schema": {
+      "type": "object",
+      "description": "JSON Schema of the tool input; providers expect an object schema",
+      "properties": {
+        "type": {"const": "object"}
+      }
+    }
+  },
+  "additionalProperties": false
+}
+`
+
+// handleToolSchema serves the JSON Schema of tool definitions. It needs no
+// API key so editors can fetch it for validation and autocomplete.
+func (s *Server) handleToolSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(toolSchema))
+}
@@ -6,11 +6,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"langdag.com/langdag/internal/archive"
 	"langdag.com/langdag/internal/config"
 	"langdag.com/langdag/internal/conversation"
 	"langdag.com/langdag/internal/models"
@@ -19,61 +21,234 @@ import (
 	geminiprovider "langdag.com/langdag/internal/provider/gemini"
 	mockprovider "langdag.com/langdag/internal/provider/mock"
 	openaiprovider "langdag.com/langdag/internal/provider/openai"
+	"langdag.com/langdag/internal/storage"
+	"langdag.com/langdag/internal/storage/memory"
 	"langdag.com/langdag/internal/storage/sqlite"
+	"langdag.com/langdag/internal/toolbox"
+	"langdag.com/langdag/internal/tracing"
 )
 
 // Server represents the HTTP API server.
 type Server struct {
 	httpServer *http.Server
-	store      *sqlite.SQLiteStorage
+	store      storage.Storage
 	convMgr    *conversation.Manager
 	apiKey     string
+	metrics    *usageMetrics
+	activity   *activityLog
+	accessLog  *accessLogger // nil when disabled
+	features   FeaturesResponse
+
+	keysMu        sync.Mutex
+	keysCheckedAt time.Time
+	haveKeys      bool // whether stored API keys exist, see hasStoredKeys
+
+	guest          *guestMode // nil when disabled
+	stopGuestPurge context.CancelFunc
+
+	stopSpeculationPurge context.CancelFunc // nil when speculation is disabled
+	stopRetention        context.CancelFunc // nil when retention is disabled
+
+	callbacks *callbackSender // nil when disabled
+
+	streams streamRegistry // streams clients can reconnect to
+
+	// stopTracing flushes and stops span export.
+	stopTracing func(context.Context) error
 }
 
 // Config holds server configuration.
 type Config struct {
-	Addr   string
-	APIKey string // Optional API key for authentication
+	Addr      string
+	APIKey    string // Optional API key for authentication
+	Ephemeral bool   // Keep all data in memory instead of SQLite
 }
 
 // New creates a new API server.
-func New(cfg *Config, appConfig *config.Config) (*Server, error) {
+func New(cfg *Config, appConfig *config.Config) (_ *Server, err error) {
 	ctx := context.Background()
 
 	// Initialize storage
-	storagePath := appConfig.Storage.Path
-	if storagePath == "./langdag.db" {
-		storagePath = config.GetDefaultStoragePath()
+	store, err := openStorage(ctx, cfg, appConfig)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := config.EnsureStorageDir(storagePath); err != nil {
+	// Create provider (may return a Router when routing is configured)
+	prov, err := createProvider(ctx, appConfig)
+	if err != nil {
+		store.Close()
 		return nil, err
 	}
 
-	store, err := sqlite.New(storagePath)
+	stopTracing, err := tracing.Setup(ctx, appConfig.Tracing)
 	if err != nil {
+		store.Close()
 		return nil, err
 	}
+	defer func() {
+		if err != nil {
+			stopTracing(ctx)
+		}
+	}()
+	managerStore, managerProv := storage.Storage(store), prov
+	if tracing.Enabled(appConfig.Tracing) {
+		managerStore = storage.WithTracing(managerStore)
+		managerProv = provider.WithTracing(managerProv)
+	}
 
-	if err := store.Init(ctx); err != nil {
+	// Create managers. Storage calls go through a guard that retries
+	// transient failures and reports sustained outages as 503s.
+	convMgr := conversation.NewManager(newGuardedStorage(managerStore, defaultStorageGuardConfig()), managerProv)
+	convMgr.SetMetadataOptions(conversation.MetadataOptions{
+		HashUserIDs: appConfig.Metadata.HashUserIDs,
+		UserIDSalt:  appConfig.Metadata.UserIDSalt,
+	})
+	convMgr.SetMaxTokensOptions(conversation.MaxTokensOptions{
+		Default:  appConfig.Defaults.MaxTokens,
+		PerModel: appConfig.Defaults.ModelMaxTokens,
+	})
+	convMgr.SetPresets(presetsFromConfig(appConfig.Presets))
+	convMgr.SetGlobalSystemPrompt(appConfig.Defaults.SystemPrompt)
+	convMgr.SetDefaultLanguage(appConfig.Defaults.Language)
+	if err := convMgr.SetGlossary(glossaryFromConfig(appConfig.Glossary)); err != nil {
 		store.Close()
 		return nil, err
 	}
-
-	// Create provider (may return a Router when routing is configured)
-	prov, err := createProvider(ctx, appConfig)
+	if err := convMgr.SetInjectionScanOptions(conversation.InjectionScanOptions{
+		Enabled:             appConfig.Safety.InjectionScan.Enabled,
+		RequireConfirmation: appConfig.Safety.InjectionScan.RequireConfirmation,
+		Patterns:            appConfig.Safety.InjectionScan.Patterns,
+	}); err != nil {
+		store.Close()
+		return nil, err
+	}
+	convMgr.SetClassifierOptions(conversation.ClassifierOptions{
+		Enabled: appConfig.Classifier.Enabled,
+		Rules:   appConfig.Classifier.Rules,
+		Model:   appConfig.Classifier.Model,
+	})
+	convMgr.SetTitleOptions(conversation.TitleOptions{
+		Generate: appConfig.Titles.Generate,
+		Model:    appConfig.Titles.Model,
+	})
+	convMgr.SetPricing(pricingFromConfig(appConfig.Pricing))
+	if t := appConfig.Server.GenerationTimeout; t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil || d < 0 {
+			store.Close()
+			return nil, fmt.Errorf("invalid server.generation_timeout %q", t)
+		}
+		convMgr.SetGenerationTimeout(d)
+	}
+	if appConfig.Trash.Enabled {
+		opts := conversation.TrashOptions{Dir: appConfig.Trash.Dir}
+		if opts.Dir == "" {
+			opts.Dir = config.GetDefaultTrashDir()
+		}
+		if r := appConfig.Trash.Retention; r != "" {
+			d, err := time.ParseDuration(r)
+			if err != nil || d <= 0 {
+				store.Close()
+				return nil, fmt.Errorf("invalid trash.retention %q", r)
+			}
+			opts.Retention = d
+		}
+		convMgr.SetTrashOptions(opts)
+	}
+	if sc := appConfig.Speculation; sc.Enabled {
+		opts := conversation.SpeculationOptions{
+			Enabled:          true,
+			Suggestions:      sc.Suggestions,
+			Model:            sc.Model,
+			MaxTokens:        sc.MaxTokens,
+			DailyTokenBudget: sc.DailyTokenBudget,
+		}
+		if sc.TTL != "" {
+			d, err := time.ParseDuration(sc.TTL)
+			if err != nil || d <= 0 {
+				store.Close()
+				return nil, fmt.Errorf("invalid speculation.ttl %q", sc.TTL)
+			}
+			opts.TTL = d
+		}
+		convMgr.SetSpeculationOptions(opts)
+	}
+	if err := convMgr.SetEmbeddingOptions(conversation.EmbeddingOptions{
+		Enabled: appConfig.Embeddings.Enabled,
+		Model:   appConfig.Embeddings.Model,
+	}); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("invalid embeddings config: %w", err)
+	}
+	builtinTools, err := toolboxFromConfig(appConfig.Tools)
 	if err != nil {
 		store.Close()
 		return nil, err
 	}
+	convMgr.SetToolbox(builtinTools)
+	if err := convMgr.SetIDFormat(appConfig.IDs.Format); err != nil {
+		store.Close()
+		return nil, err
+	}
+	if appConfig.Archive.Location != "" {
+		archiveStore, err := archive.Open(ctx, appConfig.Archive.Location)
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+		convMgr.SetArchiveStore(archiveStore)
+	}
 
-	// Create managers
-	convMgr := conversation.NewManager(store, prov)
+	accessLog, err := newAccessLogger(appConfig.Server.AccessLog)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	guest, err := newGuestMode(appConfig.Server.Guest)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	callbacks, err := newCallbackSender(appConfig.Server.Callbacks)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	retention, err := newRetention(appConfig.Server.Retention)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
 
 	s := &Server{
-		store:   store,
-		convMgr: convMgr,
-		apiKey:  cfg.APIKey,
+		store:     store,
+		convMgr:   convMgr,
+		apiKey:    cfg.APIKey,
+		metrics:   newUsageMetrics(),
+		activity:  newActivityLog(),
+		accessLog: accessLog,
+		features:  newFeatures(cfg, appConfig, prov),
+		guest:     guest,
+		callbacks: callbacks,
+
+		stopTracing: stopTracing,
+	}
+	s.features.BuiltinTools = builtinTools.Names()
+	if guest != nil {
+		purgeCtx, stop := context.WithCancel(context.Background())
+		s.stopGuestPurge = stop
+		go s.purgeGuests(purgeCtx)
+	}
+	if appConfig.Speculation.Enabled {
+		purgeCtx, stop := context.WithCancel(context.Background())
+		s.stopSpeculationPurge = stop
+		go s.purgeSpeculation(purgeCtx)
+	}
+	if retention != nil {
+		pruneCtx, stop := context.WithCancel(context.Background())
+		s.stopRetention = stop
+		go s.pruneStale(pruneCtx, retention)
 	}
 
 	// Setup routes
@@ -81,25 +256,52 @@ func New(cfg *Config, appConfig *config.Config) (*Server, error) {
 
 	// Health check
 	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("GET /metrics", s.authMiddleware(s.handleMetrics))
+	mux.HandleFunc("GET /activity", s.authMiddleware(s.handleActivity))
+	mux.HandleFunc("GET /usage", s.authMiddleware(s.handleUsage))
+	mux.HandleFunc("GET /features", s.authMiddleware(s.handleFeatures))
+	mux.HandleFunc("GET /schema/tool", s.handleToolSchema)
+	mux.HandleFunc("GET /openapi.json", s.handleOpenAPI)
+	mux.HandleFunc("GET /docs", s.handleDocs)
 
 	// Prompt endpoints
 	mux.HandleFunc("POST /prompt", s.authMiddleware(s.handlePrompt))
 	mux.HandleFunc("POST /nodes/{id}/prompt", s.authMiddleware(s.handleNodePrompt))
+	mux.HandleFunc("POST /nodes/{id}/edit", s.authMiddleware(s.handleEdit))
+	mux.HandleFunc("POST /nodes/{id}/regenerate", s.authMiddleware(s.handleRegenerate))
+	mux.HandleFunc("GET /streams/{id}", s.authMiddleware(s.handleResumeStream))
 
 	// Node endpoints
 	mux.HandleFunc("GET /nodes", s.authMiddleware(s.handleListNodes))
 	mux.HandleFunc("GET /nodes/{id}", s.authMiddleware(s.handleGetNode))
 	mux.HandleFunc("GET /nodes/{id}/tree", s.authMiddleware(s.handleGetTree))
+	mux.HandleFunc("GET /nodes/{id}/suggestions", s.authMiddleware(s.handleSuggestions))
+	mux.HandleFunc("GET /nodes/{id}/search", s.authMiddleware(s.handleSearchTree))
+	mux.HandleFunc("GET /search", s.authMiddleware(s.handleSearch))
+	mux.HandleFunc("GET /search/semantic", s.authMiddleware(s.handleSemanticSearch))
+	mux.HandleFunc("POST /ask", s.authMiddleware(s.handleAsk))
+	mux.HandleFunc("GET /nodes/{id}/export", s.authMiddleware(s.handleExport))
+	mux.HandleFunc("POST /nodes/import", s.authMiddleware(s.handleImport))
+	mux.HandleFunc("POST /nodes/{id}/cancel", s.authMiddleware(s.handleCancelTree))
+	mux.HandleFunc("POST /nodes/{id}/clone", s.authMiddleware(s.handleClone))
+	mux.HandleFunc("POST /nodes/{id}/summarize", s.authMiddleware(s.handleSummarize))
+	mux.HandleFunc("PATCH /nodes/{id}", s.authMiddleware(s.handleUpdateDAG))
 	mux.HandleFunc("DELETE /nodes/{id}", s.authMiddleware(s.handleDeleteNode))
+	mux.HandleFunc("GET /nodes/{id}/comments", s.authMiddleware(s.handleListComments))
+	mux.HandleFunc("POST /nodes/{id}/comments", s.authMiddleware(s.handleCreateComment))
 
 	// Alias endpoints
 	mux.HandleFunc("PUT /nodes/{id}/aliases/{alias}", s.authMiddleware(s.handleCreateAlias))
 	mux.HandleFunc("GET /nodes/{id}/aliases", s.authMiddleware(s.handleListAliases))
 	mux.HandleFunc("DELETE /aliases/{alias}", s.authMiddleware(s.handleDeleteAlias))
 
+	handler := s.corsMiddleware(mux)
+	if tracing.Enabled(appConfig.Tracing) {
+		handler = tracingMiddleware(handler)
+	}
 	s.httpServer = &http.Server{
 		Addr:         cfg.Addr,
-		Handler:      s.corsMiddleware(mux),
+		Handler:      requestIDMiddleware(s.accessLog.middleware(handler)),
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 0, // Disable for SSE streaming
 		IdleTimeout:  120 * time.Second,
@@ -108,16 +310,136 @@ func New(cfg *Config, appConfig *config.Config) (*Server, error) {
 	return s, nil
 }
 
+// openStorage opens the storage backend selected by cfg.Ephemeral or
+// storage.driver ("sqlite" or "memory").
+func openStorage(ctx context.Context, cfg *Config, appConfig *config.Config) (storage.Storage, error) {
+	if cfg.Ephemeral || appConfig.Storage.Driver == "memory" {
+		return memory.New(), nil
+	}
+
+	storagePath := appConfig.Storage.Path
+	if storagePath == "./langdag.db" {
+		storagePath = config.GetDefaultStoragePath()
+	}
+
+	if err := config.EnsureStorageDir(storagePath); err != nil {
+		return nil, err
+	}
+
+	store, err := sqlite.New(storagePath)
+	if err != nil {
+		return nil, err
+	}
+	store.SetFileThreshold(appConfig.Storage.FileThreshold)
+
+	if err := store.Init(ctx); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// presetsFromConfig converts configured presets for the conversation manager.
+func presetsFromConfig(in map[string]config.PresetConfig) map[string]conversation.Preset {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[string]conversation.Preset, len(in))
+	for name, p := range in {
+		out[name] = conversation.Preset{
+			Model:        p.Model,
+			SystemPrompt: p.System,
+			Temperature:  p.Temperature,
+		}
+	}
+	return out
+}
+
+// pricingFromConfig converts configured model prices for the conversation
+// manager.
+func pricingFromConfig(in map[string]config.PriceConfig) map[string]conversation.ModelPrice {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[string]conversation.ModelPrice, len(in))
+	for model, p := range in {
+		out[model] = conversation.ModelPrice{
+			InputPer1M:     p.Input,
+			OutputPer1M:    p.Output,
+			CacheReadPer1M: p.CacheRead,
+			Currency:       p.Currency,
+		}
+	}
+	return out
+}
+
+// toolboxFromConfig returns the built-in tools enabled by cfg.
+func toolboxFromConfig(cfg config.ToolsConfig) (*toolbox.Toolbox, error) {
+	opts := toolbox.Options{
+		HTTPFetch:      toolbox.HTTPFetchOptions{AllowedHosts: cfg.HTTPFetch.AllowedHosts},
+		Shell:          toolbox.ShellOptions{AllowedCommands: cfg.Shell.AllowedCommands, Dir: cfg.Shell.Dir},
+		Files:          toolbox.FilesOptions{Root: cfg.Files.Root, Write: cfg.Files.Write},
+		MaxOutputBytes: cfg.MaxOutputBytes,
+	}
+	for _, t := range []struct {
+		key, value string
+		dst        *time.Duration
+	}{
+		{"tools.http_fetch.timeout", cfg.HTTPFetch.Timeout, &opts.HTTPFetch.Timeout},
+		{"tools.shell.timeout", cfg.Shell.Timeout, &opts.Shell.Timeout},
+	} {
+		if t.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(t.value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid %s %q", t.key, t.value)
+		}
+		*t.dst = d
+	}
+	return toolbox.New(opts)
+}
+
+// glossaryFromConfig converts the configured glossary for the conversation
+// manager.
+func glossaryFromConfig(in []config.GlossaryEntryConfig) []conversation.GlossaryEntry {
+	var out []conversation.GlossaryEntry
+	for _, e := range in {
+		out = append(out, conversation.GlossaryEntry{Term: e.Term, Definition: e.Definition})
+	}
+	return out
+}
+
 // Start starts the HTTP server.
 func (s *Server) Start() error {
-	log.Printf("Starting API server on %s", s.httpServer.Addr)
+	slog.Info("starting API server", "addr", s.httpServer.Addr)
 	return s.httpServer.ListenAndServe()
 }
 
 // Shutdown gracefully shuts down the server.
 func (s *Server) Shutdown(ctx context.Context) error {
+	if s.stopGuestPurge != nil {
+		s.stopGuestPurge()
+	}
+	if s.stopSpeculationPurge != nil {
+		s.stopSpeculationPurge()
+	}
+	if s.stopRetention != nil {
+		s.stopRetention()
+	}
+	// Requests are drained first: they may start generations and
+	// callbacks, which must be done before the store is closed.
+	err := s.httpServer.Shutdown(ctx)
+	s.convMgr.Wait()
+	s.callbacks.wait()
 	s.store.Close()
-	return s.httpServer.Shutdown(ctx)
+	s.accessLog.Close()
+	if s.stopTracing != nil {
+		if terr := s.stopTracing(ctx); terr != nil && err == nil {
+			err = terr
+		}
+	}
+	return err
 }
 
 // Addr returns the server address.
@@ -125,32 +447,34 @@ func (s *Server) Addr() string {
 	return s.httpServer.Addr
 }
 
-// authMiddleware checks for API key authentication if configured.
+// authMiddleware checks for API key authentication if configured: when
+// the server has an API key or stored keys exist, requests must carry one
+// of them, and read-only keys may only make GET requests.
 func (s *Server) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if s.apiKey != "" {
-			auth := r.Header.Get("Authorization")
-			if auth == "" {
-				auth = r.Header.Get("X-API-Key")
-			} else {
-				auth = strings.TrimPrefix(auth, "Bearer ")
-			}
-
-			if auth != s.apiKey {
-				writeError(w, http.StatusUnauthorized, "unauthorized")
-				return
-			}
+		r, ok := s.authenticate(w, r)
+		if !ok {
+			return
 		}
 		next(w, r)
 	}
 }
 
+// requestAPIKey returns the API key presented in the Authorization (Bearer)
+// or X-API-Key header.
+func requestAPIKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return r.Header.Get("X-API-Key")
+}
+
 // corsMiddleware adds CORS headers.
 func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Request-ID, Last-Event-ID")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusNoContent)
@@ -174,8 +498,14 @@ func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 }
 
 // writeError writes a JSON error response.
+// The request ID, set by requestIDMiddleware, is included so clients can
+// quote it when reporting the failure.
 func writeError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, map[string]string{"error": message})
+	resp := map[string]string{"error": message}
+	if id := w.Header().Get(requestIDHeader); id != "" {
+		resp["request_id"] = id
+	}
+	writeJSON(w, status, resp)
 }
 
 // decodeJSON decodes JSON from the request body.
@@ -323,7 +653,7 @@ func createProvider(ctx context.Context, appConfig *config.Config) (provider.Pro
 		return nil, err
 	}
 
-	log.Printf("Using provider: %s", name)
+	slog.Info("using provider", "provider", name)
 	return provider.WithRetry(provider.WithServerToolFilter(prov), globalRetry), nil
 }
 
@@ -343,7 +673,7 @@ func createDeploymentAwareProvider(ctx context.Context, appConfig *config.Config
 		adapter, err := createDeploymentAdapter(ctx, deploymentID, appConfig, globalRetry)
 		if err != nil {
 			adapterErrors = append(adapterErrors, fmt.Errorf("%s: %w", deploymentID, err))
-			log.Printf("Skipping unavailable deployment: %s: %v", deploymentID, err)
+			slog.Warn("skipping unavailable deployment", "deployment", deploymentID, "error", err)
 			continue
 		}
 		adapters[deploymentID] = adapter
@@ -592,14 +922,14 @@ func createRouter(ctx context.Context, appConfig *config.Config, globalRetry pro
 			return nil, err
 		}
 		if p == nil {
-			log.Printf("Skipping unavailable provider in routing: %s", re.Provider)
+			slog.Warn("skipping unavailable provider in routing", "provider", re.Provider)
 			continue
 		}
 		// Wrap with server tool filter and per-provider retry
 		retryCfg := parseRetryConfig(re.Retry, globalRetry)
 		wrapped := provider.WithRetry(provider.WithServerToolFilter(p), retryCfg)
 		entries = append(entries, provider.RouteEntry{Provider: wrapped, Weight: re.Weight})
-		log.Printf("Routing: %s (weight=%d)", re.Provider, re.Weight)
+		slog.Info("routing", "provider", re.Provider, "weight", re.Weight)
 	}
 
 	// Build fallback chain
@@ -610,12 +940,12 @@ func createRouter(ctx context.Context, appConfig *config.Config, globalRetry pro
 			return nil, err
 		}
 		if p == nil {
-			log.Printf("Skipping unavailable provider in fallback: %s", name)
+			slog.Warn("skipping unavailable provider in fallback", "provider", name)
 			continue
 		}
 		wrapped := provider.WithRetry(provider.WithServerToolFilter(p), globalRetry)
 		fallbackProviders = append(fallbackProviders, wrapped)
-		log.Printf("Fallback: %s", name)
+		slog.Info("fallback", "provider", name)
 	}
 
 	return provider.NewRouter(entries, fallbackProviders)
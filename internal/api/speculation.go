@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// SuggestionsResponse lists follow-ups to a reply: those answered ahead of
+// time (GET /nodes/{id}/suggestions), or the questions of a suggestions
+// SSE event.
+type SuggestionsResponse struct {
+	NodeID      string   `json:"node_id,omitempty"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// handleSuggestions returns the suggested follow-ups to a reply whose
+// replies are ready. Prompting one of them from the reply returns at once.
+func (s *Server) handleSuggestions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	node, err := s.convMgr.ResolveNode(ctx, r.PathValue("id"))
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	if node == nil {
+		writeError(w, http.StatusNotFound, "node not found")
+		return
+	}
+	suggestions, err := s.convMgr.Suggestions(ctx, node.ID)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, SuggestionsResponse{Suggestions: suggestions})
+}
+
+// purgeSpeculation deletes the unused speculative branches older than the
+// speculation TTL every tenth of it (between a minute and an hour) until
+// ctx is done.
+func (s *Server) purgeSpeculation(ctx context.Context) {
+	ttl := s.convMgr.SpeculationTTL()
+	ticker := time.NewTicker(min(max(ttl/10, time.Minute), time.Hour))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.convMgr.PurgeSpeculative(ctx, time.Now().Add(-ttl))
+			if err != nil {
+				slog.WarnContext(ctx, "api: failed to purge speculative branches", "error", err)
+			} else if n > 0 {
+				slog.InfoContext(ctx, "api: purged speculative branches", "count", n)
+			}
+		}
+	}
+}
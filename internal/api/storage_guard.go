@@ -0,0 +1,386 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"langdag.com/langdag/internal/storage"
+	"langdag.com/langdag/types"
+)
+
+// storageGuardConfig configures retries and circuit breaking around storage
+// calls made by the API server.
+type storageGuardConfig struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	// FailureThreshold is the number of consecutive calls that must exhaust
+	// their retries before the circuit opens.
+	FailureThreshold int
+	// Cooldown is how long the circuit stays open. While open, calls fail
+	// immediately with a storageUnavailableError.
+	Cooldown time.Duration
+}
+
+// defaultStorageGuardConfig returns the default storage guard configuration.
+func defaultStorageGuardConfig() storageGuardConfig {
+	return storageGuardConfig{
+		MaxRetries:       3,
+		BaseDelay:        50 * time.Millisecond,
+		MaxDelay:         1 * time.Second,
+		FailureThreshold: 5,
+		Cooldown:         10 * time.Second,
+	}
+}
+
+// storageUnavailableError reports that storage is temporarily unavailable,
+// either because retries were exhausted or because the circuit is open.
+type storageUnavailableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *storageUnavailableError) Error() string {
+	return fmt.Sprintf("storage temporarily unavailable: %v", e.err)
+}
+
+func (e *storageUnavailableError) Unwrap() error { return e.err }
+
+// RetryAfter returns how long clients should wait before retrying.
+func (e *storageUnavailableError) RetryAfter() time.Duration { return e.retryAfter }
+
+var errCircuitOpen = errors.New("circuit open after repeated failures")
+
+// guardedStorage wraps a Storage with retry/backoff for transient errors and
+// a circuit breaker that fails fast while storage is down.
+type guardedStorage struct {
+	inner  storage.Storage
+	config storageGuardConfig
+	now    func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// newGuardedStorage wraps s with the given guard configuration.
+func newGuardedStorage(s storage.Storage, cfg storageGuardConfig) *guardedStorage {
+	return &guardedStorage{inner: s, config: cfg, now: time.Now}
+}
+
+// do runs op, retrying transient failures with exponential backoff.
+func (g *guardedStorage) do(ctx context.Context, op func() error) error {
+	if wait := g.openFor(); wait > 0 {
+		return &storageUnavailableError{err: errCircuitOpen, retryAfter: wait}
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= g.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(g.backoff(attempt)):
+			}
+		}
+
+		err := op()
+		if err == nil {
+			g.recordSuccess()
+			return nil
+		}
+		if !isTransientStorageError(err) {
+			return err
+		}
+		lastErr = err
+	}
+
+	return &storageUnavailableError{err: lastErr, retryAfter: g.recordFailure(ctx)}
+}
+
+// openFor returns how long the circuit remains open, or 0 when closed.
+func (g *guardedStorage) openFor() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if wait := g.openUntil.Sub(g.now()); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+func (g *guardedStorage) recordSuccess() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures = 0
+	g.openUntil = time.Time{}
+}
+
+// recordFailure counts a call that exhausted its retries, opening the
+// circuit once the threshold is reached. It returns the suggested
+// Retry-After delay.
+func (g *guardedStorage) recordFailure(ctx context.Context) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures++
+	if g.failures >= g.config.FailureThreshold {
+		g.openUntil = g.now().Add(g.config.Cooldown)
+		slog.WarnContext(ctx, "storage: circuit open", "cooldown", g.config.Cooldown, "failures", g.failures)
+		return g.config.Cooldown
+	}
+	return g.config.MaxDelay
+}
+
+// backoff calculates the delay for a given retry attempt using exponential backoff with jitter.
+func (g *guardedStorage) backoff(attempt int) time.Duration {
+	delay := float64(g.config.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if delay > float64(g.config.MaxDelay) {
+		delay = float64(g.config.MaxDelay)
+	}
+	// Add jitter: 0.5x to 1.5x
+	jitter := 0.5 + rand.Float64()
+	return time.Duration(delay * jitter)
+}
+
+// isTransientStorageError reports whether err looks like a temporary storage
+// outage (locked SQLite file, dropped connection, database failover) rather
+// than a query or constraint error.
+func isTransientStorageError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+
+	lower := strings.ToLower(err.Error())
+	for _, s := range []string{
+		"database is locked",
+		"database table is locked",
+		"sqlite_busy",
+		"connection refused",
+		"connection reset",
+		"broken pipe",
+		"bad connection",
+		"i/o timeout",
+		"too many connections",
+		"the database system is starting up",
+		"the database system is shutting down",
+		"read-only transaction",
+	} {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeServerError writes err as a JSON error response. Storage outages
+// become 503 with a Retry-After header, ID prefixes matching several nodes
+// 409, and everything else is a 500.
+func writeServerError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrAmbiguousPrefix) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	var unavailable *storageUnavailableError
+	if errors.As(err, &unavailable) {
+		seconds := int(math.Ceil(unavailable.RetryAfter().Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		writeError(w, http.StatusServiceUnavailable, "storage temporarily unavailable, retry later")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// =============================================================================
+// storage.Storage implementation
+// =============================================================================
+
+func (g *guardedStorage) Init(ctx context.Context) error {
+	return g.do(ctx, func() error { return g.inner.Init(ctx) })
+}
+
+func (g *guardedStorage) Close() error { return g.inner.Close() }
+
+func (g *guardedStorage) CreateNode(ctx context.Context, node *types.Node) error {
+	return g.do(ctx, func() error { return g.inner.CreateNode(ctx, node) })
+}
+
+func (g *guardedStorage) GetNode(ctx context.Context, id string) (node *types.Node, err error) {
+	err = g.do(ctx, func() error {
+		node, err = g.inner.GetNode(ctx, id)
+		return err
+	})
+	return node, err
+}
+
+func (g *guardedStorage) GetNodeByPrefix(ctx context.Context, prefix string) (node *types.Node, err error) {
+	err = g.do(ctx, func() error {
+		node, err = g.inner.GetNodeByPrefix(ctx, prefix)
+		return err
+	})
+	return node, err
+}
+
+func (g *guardedStorage) GetNodeChildren(ctx context.Context, parentID string) (nodes []*types.Node, err error) {
+	err = g.do(ctx, func() error {
+		nodes, err = g.inner.GetNodeChildren(ctx, parentID)
+		return err
+	})
+	return nodes, err
+}
+
+func (g *guardedStorage) GetSubtree(ctx context.Context, nodeID string) (nodes []*types.Node, err error) {
+	err = g.do(ctx, func() error {
+		nodes, err = g.inner.GetSubtree(ctx, nodeID)
+		return err
+	})
+	return nodes, err
+}
+
+func (g *guardedStorage) GetAncestors(ctx context.Context, nodeID string) (nodes []*types.Node, err error) {
+	err = g.do(ctx, func() error {
+		nodes, err = g.inner.GetAncestors(ctx, nodeID)
+		return err
+	})
+	return nodes, err
+}
+
+func (g *guardedStorage) ListRootNodes(ctx context.Context) (nodes []*types.Node, err error) {
+	err = g.do(ctx, func() error {
+		nodes, err = g.inner.ListRootNodes(ctx)
+		return err
+	})
+	return nodes, err
+}
+
+func (g *guardedStorage) SearchNodes(ctx context.Context, query string, limit int) (nodes []*types.Node, err error) {
+	err = g.do(ctx, func() error {
+		nodes, err = g.inner.SearchNodes(ctx, query, limit)
+		return err
+	})
+	return nodes, err
+}
+
+func (g *guardedStorage) UpdateNode(ctx context.Context, node *types.Node) error {
+	return g.do(ctx, func() error { return g.inner.UpdateNode(ctx, node) })
+}
+
+func (g *guardedStorage) DeleteNode(ctx context.Context, id string) error {
+	return g.do(ctx, func() error { return g.inner.DeleteNode(ctx, id) })
+}
+
+func (g *guardedStorage) CreateAlias(ctx context.Context, nodeID, alias string) error {
+	return g.do(ctx, func() error { return g.inner.CreateAlias(ctx, nodeID, alias) })
+}
+
+func (g *guardedStorage) DeleteAlias(ctx context.Context, alias string) error {
+	return g.do(ctx, func() error { return g.inner.DeleteAlias(ctx, alias) })
+}
+
+func (g *guardedStorage) GetNodeByAlias(ctx context.Context, alias string) (node *types.Node, err error) {
+	err = g.do(ctx, func() error {
+		node, err = g.inner.GetNodeByAlias(ctx, alias)
+		return err
+	})
+	return node, err
+}
+
+func (g *guardedStorage) ListAliases(ctx context.Context, nodeID string) (aliases []string, err error) {
+	err = g.do(ctx, func() error {
+		aliases, err = g.inner.ListAliases(ctx, nodeID)
+		return err
+	})
+	return aliases, err
+}
+
+func (g *guardedStorage) IndexToolIDs(ctx context.Context, nodeID string, toolIDs []string, role string) error {
+	return g.do(ctx, func() error { return g.inner.IndexToolIDs(ctx, nodeID, toolIDs, role) })
+}
+
+func (g *guardedStorage) GetOrphanedToolUses(ctx context.Context, ancestorIDs []string) (orphans map[string][]string, err error) {
+	err = g.do(ctx, func() error {
+		orphans, err = g.inner.GetOrphanedToolUses(ctx, ancestorIDs)
+		return err
+	})
+	return orphans, err
+}
+
+func (g *guardedStorage) CreateAPIKey(ctx context.Context, key *types.APIKey) error {
+	return g.do(ctx, func() error { return g.inner.CreateAPIKey(ctx, key) })
+}
+
+func (g *guardedStorage) GetAPIKeyByHash(ctx context.Context, hash string) (key *types.APIKey, err error) {
+	err = g.do(ctx, func() error {
+		key, err = g.inner.GetAPIKeyByHash(ctx, hash)
+		return err
+	})
+	return key, err
+}
+
+func (g *guardedStorage) ListAPIKeys(ctx context.Context) (keys []*types.APIKey, err error) {
+	err = g.do(ctx, func() error {
+		keys, err = g.inner.ListAPIKeys(ctx)
+		return err
+	})
+	return keys, err
+}
+
+func (g *guardedStorage) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	return g.do(ctx, func() error { return g.inner.RevokeAPIKey(ctx, id, at) })
+}
+
+func (g *guardedStorage) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	return g.do(ctx, func() error { return g.inner.TouchAPIKey(ctx, id, at) })
+}
+
+func (g *guardedStorage) CreateComment(ctx context.Context, comment *types.Comment) error {
+	return g.do(ctx, func() error { return g.inner.CreateComment(ctx, comment) })
+}
+
+func (g *guardedStorage) ListComments(ctx context.Context, rootID string) (comments []*types.Comment, err error) {
+	err = g.do(ctx, func() error {
+		comments, err = g.inner.ListComments(ctx, rootID)
+		return err
+	})
+	return comments, err
+}
+
+func (g *guardedStorage) SaveEmbedding(ctx context.Context, nodeID, model string, vector []float32) error {
+	return g.do(ctx, func() error { return g.inner.SaveEmbedding(ctx, nodeID, model, vector) })
+}
+
+func (g *guardedStorage) SearchEmbeddings(ctx context.Context, model string, vector []float32, limit int) (matches []types.EmbeddingMatch, err error) {
+	err = g.do(ctx, func() error {
+		matches, err = g.inner.SearchEmbeddings(ctx, model, vector, limit)
+		return err
+	})
+	return matches, err
+}
+
+func (g *guardedStorage) ListUnembeddedNodes(ctx context.Context, model string, limit int) (nodes []*types.Node, err error) {
+	err = g.do(ctx, func() error {
+		nodes, err = g.inner.ListUnembeddedNodes(ctx, model, limit)
+		return err
+	})
+	return nodes, err
+}
+
+// WithTx retries the whole transaction, since a failed one saved nothing.
+func (g *guardedStorage) WithTx(ctx context.Context, fn func(tx storage.NodeWriter) error) error {
+	return g.do(ctx, func() error { return g.inner.WithTx(ctx, fn) })
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/conversation"
+	mockprovider "langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/internal/storage/memory"
+	"langdag.com/langdag/types"
+)
+
+// flakyStorage fails ListRootNodes with err for the first failures calls.
+type flakyStorage struct {
+	*memory.MemoryStorage
+	failures atomic.Int32
+	calls    atomic.Int32
+	err      error
+}
+
+func (f *flakyStorage) ListRootNodes(ctx context.Context) ([]*types.Node, error) {
+	f.calls.Add(1)
+	if f.failures.Add(-1) >= 0 {
+		return nil, f.err
+	}
+	return f.MemoryStorage.ListRootNodes(ctx)
+}
+
+func newFlakyStorage(failures int, err error) *flakyStorage {
+	f := &flakyStorage{MemoryStorage: memory.New(), err: err}
+	f.failures.Store(int32(failures))
+	return f
+}
+
+func testGuardConfig() storageGuardConfig {
+	return storageGuardConfig{
+		MaxRetries:       2,
+		BaseDelay:        time.Millisecond,
+		MaxDelay:         2 * time.Millisecond,
+		FailureThreshold: 2,
+		Cooldown:         time.Minute,
+	}
+}
+
+func TestGuardedStorageRetriesTransientErrors(t *testing.T) {
+	inner := newFlakyStorage(2, errors.New("database is locked (5) (SQLITE_BUSY)"))
+	g := newGuardedStorage(inner, testGuardConfig())
+
+	if _, err := g.ListRootNodes(context.Background()); err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if got := inner.calls.Load(); got != 3 {
+		t.Errorf("calls = %d, want 3", got)
+	}
+}
+
+func TestGuardedStorageDoesNotRetryPermanentErrors(t *testing.T) {
+	inner := newFlakyStorage(1, errors.New("UNIQUE constraint failed: nodes.id"))
+	g := newGuardedStorage(inner, testGuardConfig())
+
+	_, err := g.ListRootNodes(context.Background())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	var unavailable *storageUnavailableError
+	if errors.As(err, &unavailable) {
+		t.Errorf("permanent error should not be reported as unavailable: %v", err)
+	}
+	if got := inner.calls.Load(); got != 1 {
+		t.Errorf("calls = %d, want 1", got)
+	}
+}
+
+func TestGuardedStorageOpensCircuit(t *testing.T) {
+	inner := newFlakyStorage(1000, errors.New("dial tcp: connection refused"))
+	g := newGuardedStorage(inner, testGuardConfig())
+	now := time.Now()
+	g.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := g.ListRootNodes(ctx); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	callsBefore := inner.calls.Load()
+
+	_, err := g.ListRootNodes(ctx)
+	var unavailable *storageUnavailableError
+	if !errors.As(err, &unavailable) {
+		t.Fatalf("expected storageUnavailableError, got %v", err)
+	}
+	if unavailable.RetryAfter() != time.Minute {
+		t.Errorf("RetryAfter = %v, want 1m", unavailable.RetryAfter())
+	}
+	if inner.calls.Load() != callsBefore {
+		t.Error("open circuit should not call the underlying storage")
+	}
+
+	// After the cooldown, calls go through again and a success closes it.
+	now = now.Add(2 * time.Minute)
+	inner.failures.Store(0)
+	if _, err := g.ListRootNodes(ctx); err != nil {
+		t.Fatalf("expected success after cooldown, got %v", err)
+	}
+	if g.openFor() != 0 {
+		t.Error("circuit should be closed after a success")
+	}
+}
+
+func TestListNodesStorageUnavailableReturns503(t *testing.T) {
+	inner := newFlakyStorage(1000, errors.New("database is locked"))
+	prov := mockprovider.New(mockprovider.Config{Mode: "fixed", FixedResponse: "ok"})
+	s := &Server{
+		store:   inner,
+		convMgr: conversation.NewManager(newGuardedStorage(inner, testGuardConfig()), prov),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /nodes", s.handleListNodes)
+
+	req := httptest.NewRequest("GET", "/nodes", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if got := w.Header().Get("Retry-After"); got == "" {
+		t.Error("expected Retry-After header")
+	}
+}
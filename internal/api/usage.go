@@ -0,0 +1,116 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"langdag.com/langdag/internal/conversation"
+)
+
+// defaultUsagePeriod is how far back GET /usage looks without ?since.
+const defaultUsagePeriod = 7 * 24 * time.Hour
+
+// UsageResponse is a usage report: token and cost totals of the replies
+// generated since a time, grouped by DAG, day or model.
+type UsageResponse struct {
+	Since   string     `json:"since"`
+	GroupBy string     `json:"group_by"`
+	Rows    []UsageRow `json:"rows"`
+	Total   UsageRow   `json:"total"`
+}
+
+// UsageRow is the usage of one DAG, day or model.
+type UsageRow struct {
+	Key                 string             `json:"key"`
+	Title               string             `json:"title,omitempty"`
+	Generations         int                `json:"generations"`
+	TokensIn            int64              `json:"tokens_in"`
+	TokensOut           int64              `json:"tokens_out"`
+	TokensCacheRead     int64              `json:"tokens_cache_read"`
+	TokensCacheCreation int64              `json:"tokens_cache_creation"`
+	TokensReasoning     int64              `json:"tokens_reasoning"`
+	Cost                map[string]float64 `json:"cost,omitempty"`
+	Unpriced            int                `json:"unpriced,omitempty"`
+}
+
+func usageRowResponse(row conversation.UsageRow) UsageRow {
+	return UsageRow{
+		Key:                 row.Key,
+		Title:               row.Title,
+		Generations:         row.Generations,
+		TokensIn:            row.TokensIn,
+		TokensOut:           row.TokensOut,
+		TokensCacheRead:     row.TokensCacheRead,
+		TokensCacheCreation: row.TokensCacheCreation,
+		TokensReasoning:     row.TokensReasoning,
+		Cost:                row.Cost,
+		Unpriced:            row.Unpriced,
+	}
+}
+
+// handleUsage reports usage since ?since (an RFC 3339 time, a duration such
+// as 24h, or a number of days such as 7d), grouped by ?by (model, day or
+// dag).
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	since := time.Now().Add(-defaultUsagePeriod)
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := parseSince(v, time.Now())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid since: "+err.Error())
+			return
+		}
+		since = t
+	}
+	groupBy := r.URL.Query().Get("by")
+	switch groupBy {
+	case "", conversation.UsageByDAG, conversation.UsageByDay, conversation.UsageByModel:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid by %q (want model, day or dag)", groupBy))
+		return
+	}
+
+	report, err := s.convMgr.Usage(r.Context(), since, groupBy)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	resp := UsageResponse{
+		Since:   report.Since.UTC().Format(time.RFC3339),
+		GroupBy: report.GroupBy,
+		Rows:    make([]UsageRow, len(report.Rows)),
+		Total:   usageRowResponse(report.Total),
+	}
+	for i, row := range report.Rows {
+		resp.Rows[i] = usageRowResponse(row)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parseSince parses an RFC 3339 time, or an age before now given as a Go
+// duration or a whole number of days ("7d").
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	var age time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("not a time or duration: %s", s)
+		}
+		age = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("not a time or duration: %s", s)
+		}
+		age = d
+	}
+	if age <= 0 {
+		return time.Time{}, fmt.Errorf("duration must be positive")
+	}
+	return now.Add(-age), nil
+}
@@ -0,0 +1,76 @@
+// Package apikeys creates and checks the API keys stored for the server,
+// in addition to the single key given with --api-key.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"langdag.com/langdag/internal/storage"
+	"langdag.com/langdag/types"
+)
+
+// secretPrefix starts every generated secret, so leaked keys are easy to
+// recognize.
+const secretPrefix = "ldk_"
+
+// Create generates a key with the given name and scope ("read", "write"
+// or "tools") and stores its hash. The secret is returned once; only its hash
+// is kept.
+func Create(ctx context.Context, store storage.Storage, name, scope string) (string, *types.APIKey, error) {
+	if scope != types.APIKeyScopeRead && scope != types.APIKeyScopeWrite && scope != types.APIKeyScopeTools {
+		return "", nil, fmt.Errorf("invalid scope %q (want read, write or tools)", scope)
+	}
+	secret := secretPrefix + randomHex(24)
+	key := &types.APIKey{
+		ID:        randomHex(4),
+		Name:      strings.TrimSpace(name),
+		Hash:      Hash(secret),
+		Scope:     scope,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := store.CreateAPIKey(ctx, key); err != nil {
+		return "", nil, err
+	}
+	return secret, key, nil
+}
+
+// Lookup returns the active key with the given secret, or nil if there is
+// none or it was revoked.
+func Lookup(ctx context.Context, store storage.Storage, secret string) (*types.APIKey, error) {
+	if secret == "" {
+		return nil, nil
+	}
+	key, err := store.GetAPIKeyByHash(ctx, Hash(secret))
+	if err != nil || key == nil || key.RevokedAt != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Hash returns the stored form of a secret: its hex SHA-256.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Allows reports whether a key with the given scope may make a request
+// with the given method. Read keys may only make GET and HEAD requests.
+func Allows(scope, method string) bool {
+	if scope == types.APIKeyScopeWrite || scope == types.APIKeyScopeTools {
+		return true
+	}
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,68 @@
+// Package archive stores archived DAGs in cold storage: a local directory,
+// Amazon S3 (or an S3-compatible service) or Google Cloud Storage.
+package archive
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Store reads and writes archive objects.
+type Store interface {
+	// Put writes data under key and returns the object's URI.
+	Put(ctx context.Context, key string, data []byte) (string, error)
+	// Get reads the object at a URI returned by Put.
+	Get(ctx context.Context, uri string) ([]byte, error)
+}
+
+// Open returns the store for location:
+//
+//	s3://bucket/prefix   Amazon S3, using the default AWS credential chain.
+//	                     AWS_ENDPOINT_URL_S3 selects an S3-compatible service.
+//	gs://bucket/prefix   Google Cloud Storage, using application default
+//	                     credentials.
+//	file:///path, /path  A local directory.
+func Open(ctx context.Context, location string) (Store, error) {
+	switch {
+	case strings.HasPrefix(location, "s3://"):
+		bucket, prefix := splitBucket(strings.TrimPrefix(location, "s3://"))
+		return newS3Store(ctx, bucket, prefix)
+	case strings.HasPrefix(location, "gs://"):
+		bucket, prefix := splitBucket(strings.TrimPrefix(location, "gs://"))
+		return newGCSStore(ctx, bucket, prefix)
+	case strings.HasPrefix(location, "file://"):
+		return newDirStore(strings.TrimPrefix(location, "file://"))
+	case strings.Contains(location, "://"):
+		return nil, fmt.Errorf("unsupported archive location: %s", location)
+	case location == "":
+		return nil, fmt.Errorf("archive location is required")
+	default:
+		return newDirStore(location)
+	}
+}
+
+// splitBucket splits "bucket/some/prefix" into its bucket and prefix. The
+// prefix has no leading or trailing slash.
+func splitBucket(s string) (bucket, prefix string) {
+	bucket, prefix, _ = strings.Cut(s, "/")
+	return bucket, strings.Trim(prefix, "/")
+}
+
+// objectName joins a store prefix and key.
+func objectName(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "/" + key
+}
+
+// objectFromURI returns the object name in uri, which must belong to the
+// given scheme and bucket.
+func objectFromURI(uri, scheme, bucket string) (string, error) {
+	rest, ok := strings.CutPrefix(uri, scheme+"://"+bucket+"/")
+	if !ok || rest == "" {
+		return "", fmt.Errorf("archive URI %s is not in %s://%s", uri, scheme, bucket)
+	}
+	return rest, nil
+}
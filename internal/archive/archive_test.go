@@ -0,0 +1,105 @@
+package archive
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDirStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uri, err := store.Put(ctx, "abc.json.gz", []byte("payload"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if !strings.HasPrefix(uri, "file://") {
+		t.Errorf("uri = %q, want file://", uri)
+	}
+	data, err := store.Get(ctx, uri)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(data) != "payload" {
+		t.Errorf("Get = %q, want payload", data)
+	}
+
+	if _, err := store.Get(ctx, "file:///etc/passwd"); err == nil {
+		t.Error("expected error for a URI outside the directory")
+	}
+}
+
+func TestOpenRejectsUnknownScheme(t *testing.T) {
+	for _, location := range []string{"", "ftp://host/dir", "s3://"} {
+		if _, err := Open(context.Background(), location); err == nil {
+			t.Errorf("Open(%q): expected error", location)
+		}
+	}
+}
+
+func TestS3StoreSignsRequests(t *testing.T) {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDTEST/") {
+			http.Error(w, "unsigned", http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = body
+		case http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(body)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ENDPOINT_URL_S3", srv.URL)
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+
+	ctx := context.Background()
+	store, err := Open(ctx, "s3://bucket/langdag/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, err := store.Put(ctx, "abc.json.gz", []byte("payload"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if uri != "s3://bucket/langdag/abc.json.gz" {
+		t.Errorf("uri = %q", uri)
+	}
+	if _, ok := objects["/bucket/langdag/abc.json.gz"]; !ok {
+		t.Errorf("object not stored at the path-style URL: %v", objects)
+	}
+	data, err := store.Get(ctx, uri)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(data) != "payload" {
+		t.Errorf("Get = %q, want payload", data)
+	}
+	if _, err := store.Get(ctx, "s3://other/abc.json.gz"); err == nil {
+		t.Error("expected error for a URI in another bucket")
+	}
+}
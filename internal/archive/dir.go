@@ -0,0 +1,48 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dirStore keeps archive objects as files in a local directory.
+type dirStore struct {
+	dir string
+}
+
+func newDirStore(dir string) (*dirStore, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &dirStore{dir: abs}, nil
+}
+
+func (s *dirStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write archive: %w", err)
+	}
+	return "file://" + filepath.ToSlash(path), nil
+}
+
+func (s *dirStore) Get(ctx context.Context, uri string) ([]byte, error) {
+	path := filepath.FromSlash(strings.TrimPrefix(uri, "file://"))
+	if !strings.HasPrefix(uri, "file://") || !strings.HasPrefix(path, s.dir+string(filepath.Separator)) {
+		return nil, fmt.Errorf("archive URI %s is not in %s", uri, s.dir)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,80 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2/google"
+)
+
+// gcsBaseURL is the Cloud Storage JSON API endpoint.
+const gcsBaseURL = "https://storage.googleapis.com"
+
+// gcsStore keeps archive objects in a Cloud Storage bucket, authenticated
+// with application default credentials.
+type gcsStore struct {
+	bucket string
+	prefix string
+	client *http.Client
+}
+
+func newGCSStore(ctx context.Context, bucket, prefix string) (*gcsStore, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("gs archive location needs a bucket")
+	}
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/devstorage.read_write")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Google credentials: %w", err)
+	}
+	return &gcsStore{bucket: bucket, prefix: prefix, client: client}, nil
+}
+
+func (s *gcsStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	name := objectName(s.prefix, key)
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s",
+		gcsBaseURL, url.PathEscape(s.bucket), url.QueryEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	if _, err := s.do(req); err != nil {
+		return "", err
+	}
+	return "gs://" + s.bucket + "/" + name, nil
+}
+
+func (s *gcsStore) Get(ctx context.Context, uri string) ([]byte, error) {
+	name, err := objectFromURI(uri, "gs", s.bucket)
+	if err != nil {
+		return nil, err
+	}
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media",
+		gcsBaseURL, url.PathEscape(s.bucket), url.PathEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.do(req)
+}
+
+func (s *gcsStore) do(req *http.Request) ([]byte, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gcs %s: %w", req.Method, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("gcs %s: %s: %s", req.Method, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
@@ -0,0 +1,112 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// s3Store keeps archive objects in an S3 bucket. Requests are signed with
+// SigV4 using the default AWS credential chain.
+type s3Store struct {
+	bucket   string
+	prefix   string
+	region   string
+	endpoint string // base URL for path-style requests; empty for AWS
+	creds    aws.CredentialsProvider
+	signer   *v4.Signer
+	client   *http.Client
+}
+
+func newS3Store(ctx context.Context, bucket, prefix string) (*s3Store, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("s3 archive location needs a bucket")
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &s3Store{
+		bucket:   bucket,
+		prefix:   prefix,
+		region:   region,
+		endpoint: strings.TrimRight(os.Getenv("AWS_ENDPOINT_URL_S3"), "/"),
+		creds:    cfg.Credentials,
+		signer:   v4.NewSigner(),
+		client:   &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, data []byte) (string, error) {
+	name := objectName(s.prefix, key)
+	if _, err := s.do(ctx, http.MethodPut, name, data); err != nil {
+		return "", err
+	}
+	return "s3://" + s.bucket + "/" + name, nil
+}
+
+func (s *s3Store) Get(ctx context.Context, uri string) ([]byte, error) {
+	name, err := objectFromURI(uri, "s3", s.bucket)
+	if err != nil {
+		return nil, err
+	}
+	return s.do(ctx, http.MethodGet, name, nil)
+}
+
+// objectURL returns the URL of an object: virtual-hosted style on AWS,
+// path style on a custom endpoint.
+func (s *s3Store) objectURL(name string) string {
+	escaped := (&url.URL{Path: name}).EscapedPath()
+	if s.endpoint != "" {
+		return s.endpoint + "/" + s.bucket + "/" + escaped
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, escaped)
+}
+
+func (s *s3Store) do(ctx context.Context, method, name string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(name), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	creds, err := s.creds.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	if err := s.signer.SignHTTP(ctx, creds, req, payloadHash, "s3", s.region, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to sign S3 request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s %s: %w", method, name, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", method, name, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
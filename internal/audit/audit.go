@@ -0,0 +1,162 @@
+// Package audit exports stored conversation data as a tamper-evident log.
+//
+// Each line of an export is a JSON object holding one entry and its hash.
+// An entry's hash covers the entry's exact bytes, including the previous
+// entry's hash, so editing, removing or reordering any line breaks the chain
+// from that point on. When a key is given, hashes are HMAC-SHA256 so that
+// only holders of the key can produce a valid chain.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"time"
+
+	"langdag.com/langdag/internal/storage"
+	"langdag.com/langdag/types"
+)
+
+// Event types recorded in an export.
+const (
+	// EventMessage is a node stored from client input: a user message,
+	// tool result or system node.
+	EventMessage = "message"
+	// EventCompletion is an assistant node, i.e. one call to an LLM
+	// provider. Its parent chain is what was sent to the provider.
+	EventCompletion = "completion"
+)
+
+// Entry is one record of an export.
+type Entry struct {
+	Seq      int         `json:"seq"`
+	Time     time.Time   `json:"time"`
+	Event    string      `json:"event"`
+	Node     *types.Node `json:"node"`
+	PrevHash string      `json:"prev_hash"`
+}
+
+// line is the on-disk form of an entry. Entry is kept as raw bytes so that
+// verification hashes exactly what was written.
+type line struct {
+	Hash  string          `json:"hash"`
+	Entry json.RawMessage `json:"entry"`
+}
+
+// Export writes every node created in [from, to) to w, oldest first, as a
+// hash-chained JSONL log. A zero from or to leaves that end open. It returns
+// the number of entries written.
+//
+// Exports are built from stored nodes: nodes deleted before the export
+// are not included, and later edits to a node are reflected in its entry.
+func Export(ctx context.Context, store storage.Storage, from, to time.Time, key []byte, w io.Writer) (int, error) {
+	roots, err := store.ListRootNodes(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list conversations: %w", err)
+	}
+	var nodes []*types.Node
+	for _, root := range roots {
+		subtree, err := store.GetSubtree(ctx, root.ID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read conversation %s: %w", root.ID, err)
+		}
+		for _, n := range subtree {
+			if !from.IsZero() && n.CreatedAt.Before(from) {
+				continue
+			}
+			if !to.IsZero() && !n.CreatedAt.Before(to) {
+				continue
+			}
+			nodes = append(nodes, n)
+		}
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if !nodes[i].CreatedAt.Equal(nodes[j].CreatedAt) {
+			return nodes[i].CreatedAt.Before(nodes[j].CreatedAt)
+		}
+		return nodes[i].ID < nodes[j].ID
+	})
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	prev := ""
+	for i, n := range nodes {
+		event := EventMessage
+		if n.NodeType == types.NodeTypeAssistant {
+			event = EventCompletion
+		}
+		entry, err := json.Marshal(Entry{
+			Seq:      i + 1,
+			Time:     n.CreatedAt.UTC(),
+			Event:    event,
+			Node:     n,
+			PrevHash: prev,
+		})
+		if err != nil {
+			return i, err
+		}
+		prev = entryHash(key, entry)
+		if err := enc.Encode(line{Hash: prev, Entry: entry}); err != nil {
+			return i, err
+		}
+	}
+	return len(nodes), bw.Flush()
+}
+
+// Verify checks the hash chain of an export read from r and returns the
+// number of entries. It fails on the first entry whose hash, sequence
+// number or link to the previous entry does not match.
+func Verify(r io.Reader, key []byte) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	prev := ""
+	n := 0
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		n++
+		var l line
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return n - 1, fmt.Errorf("entry %d: invalid JSON: %w", n, err)
+		}
+		var e Entry
+		if err := json.Unmarshal(l.Entry, &e); err != nil {
+			return n - 1, fmt.Errorf("entry %d: invalid entry: %w", n, err)
+		}
+		if e.Seq != n {
+			return n - 1, fmt.Errorf("entry %d: sequence is %d", n, e.Seq)
+		}
+		if e.PrevHash != prev {
+			return n - 1, fmt.Errorf("entry %d: does not follow the previous entry", n)
+		}
+		if !hmac.Equal([]byte(entryHash(key, l.Entry)), []byte(l.Hash)) {
+			return n - 1, fmt.Errorf("entry %d: hash mismatch", n)
+		}
+		prev = l.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// entryHash returns the hex SHA-256 of entry, keyed with HMAC when key is
+// set.
+func entryHash(key, entry []byte) string {
+	var h hash.Hash
+	if len(key) > 0 {
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+	h.Write(entry)
+	return hex.EncodeToString(h.Sum(nil))
+}
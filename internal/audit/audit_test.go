@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/storage/memory"
+	"langdag.com/langdag/types"
+)
+
+func seedStore(t *testing.T) *memory.MemoryStorage {
+	t.Helper()
+	store := memory.New()
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, n := range []*types.Node{
+		{ID: "root", Sequence: 0, NodeType: types.NodeTypeUser, Content: "hello", CreatedAt: base},
+		{ID: "a1", ParentID: "root", RootID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant, Content: "hi", Provider: "anthropic", Model: "m", CreatedAt: base.Add(time.Second)},
+		{ID: "later", Sequence: 0, NodeType: types.NodeTypeUser, Content: "next day", CreatedAt: base.Add(24 * time.Hour)},
+	} {
+		if err := store.CreateNode(context.Background(), n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestExportAndVerify(t *testing.T) {
+	store := seedStore(t)
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	var buf bytes.Buffer
+	n, err := Export(context.Background(), store, from, to, nil, &buf)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("exported %d entries, want 2", n)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `"event":"message"`) || !strings.Contains(out, `"event":"completion"`) {
+		t.Errorf("missing event types in:\n%s", out)
+	}
+	if strings.Contains(out, "next day") {
+		t.Error("export includes a node outside the period")
+	}
+
+	if got, err := Verify(strings.NewReader(out), nil); err != nil || got != 2 {
+		t.Fatalf("Verify = %d, %v; want 2, nil", got, err)
+	}
+
+	tampered := strings.Replace(out, `"content":"hi"`, `"content":"HI"`, 1)
+	if _, err := Verify(strings.NewReader(tampered), nil); err == nil || !strings.Contains(err.Error(), "entry 2") {
+		t.Errorf("expected entry 2 to fail verification, got %v", err)
+	}
+
+	lines := strings.SplitAfter(out, "\n")
+	if _, err := Verify(strings.NewReader(lines[1]), nil); err == nil {
+		t.Error("expected a log with its first entry removed to fail verification")
+	}
+}
+
+func TestExportWithKey(t *testing.T) {
+	store := seedStore(t)
+
+	var buf bytes.Buffer
+	if _, err := Export(context.Background(), store, time.Time{}, time.Time{}, []byte("secret"), &buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if got, err := Verify(bytes.NewReader(buf.Bytes()), []byte("secret")); err != nil || got != 3 {
+		t.Fatalf("Verify = %d, %v; want 3, nil", got, err)
+	}
+	if _, err := Verify(bytes.NewReader(buf.Bytes()), []byte("wrong")); err == nil {
+		t.Error("expected verification with the wrong key to fail")
+	}
+	if _, err := Verify(bytes.NewReader(buf.Bytes()), nil); err == nil {
+		t.Error("expected verification without the key to fail")
+	}
+}
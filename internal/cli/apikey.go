@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"langdag.com/langdag/internal/apikeys"
+)
+
+var (
+	apikeyScope     string
+	apikeyName      string
+	apikeyRevokeYes bool
+)
+
+var apikeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "Manage API keys of the server",
+	Long: `Manage the API keys accepted by 'langdag serve', stored in the
+database next to the conversations.
+
+Once a key exists, every API request must carry a key, in the
+Authorization (Bearer) or X-API-Key header. Read keys may only make GET
+requests; write keys may make any request except running the built-in
+server tools, which takes a tools key. The key given with --api-key keeps
+working and has full access.`,
+}
+
+var apikeyCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an API key and print its secret",
+	Long: `Create an API key and print its secret. The secret is shown only
+once; the database keeps its SHA-256.
+
+Examples:
+  langdag apikey create --scope read --name dashboard
+  langdag apikey create --scope write --name ci
+  langdag apikey create --scope tools --name agent`,
+	Args: cobra.NoArgs,
+	RunE: runAPIKeyCreate,
+}
+
+var apikeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API keys with their scope and last use",
+	Args:  cobra.NoArgs,
+	RunE:  runAPIKeyList,
+}
+
+var apikeyRevokeCmd = &cobra.Command{
+	Use:   "revoke <id>",
+	Short: "Revoke an API key",
+	Long: `Revoke an API key, after confirmation. Requests made with it are
+rejected from then on; revoking cannot be undone.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAPIKeyRevoke,
+}
+
+func init() {
+	apikeyCreateCmd.Flags().StringVar(&apikeyScope, "scope", "write", "scope of the key: read, write or tools")
+	apikeyCreateCmd.Flags().StringVar(&apikeyName, "name", "", "name describing the key's holder")
+	apikeyRevokeCmd.Flags().BoolVarP(&apikeyRevokeYes, "yes", "y", false, "revoke without asking for confirmation")
+
+	apikeyCmd.AddCommand(apikeyCreateCmd)
+	apikeyCmd.AddCommand(apikeyListCmd)
+	apikeyCmd.AddCommand(apikeyRevokeCmd)
+	rootCmd.AddCommand(apikeyCmd)
+}
+
+func runAPIKeyCreate(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	secret, key, err := apikeys.Create(ctx, client.Storage(), apikeyName, apikeyScope)
+	if err != nil {
+		return err
+	}
+	if printFormatted(struct {
+		ID     string `json:"id" yaml:"id"`
+		Name   string `json:"name,omitempty" yaml:"name,omitempty"`
+		Scope  string `json:"scope" yaml:"scope"`
+		Secret string `json:"secret" yaml:"secret"`
+	}{key.ID, key.Name, key.Scope, secret}) {
+		return nil
+	}
+	fmt.Printf("Created %s key %s\n", key.Scope, key.ID)
+	fmt.Println(secret)
+	fmt.Fprintln(os.Stderr, "Store the secret now: it cannot be shown again.")
+	return nil
+}
+
+func runAPIKeyList(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	keys, err := client.Storage().ListAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+	if printFormatted(keys) {
+		return nil
+	}
+	if len(keys) == 0 {
+		fmt.Println("No API keys")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tNAME\tSCOPE\tCREATED\tLAST USED\tSTATUS\n")
+	for _, k := range keys {
+		lastUsed, status := "never", "active"
+		if k.LastUsedAt != nil {
+			lastUsed = k.LastUsedAt.Local().Format(time.DateTime)
+		}
+		if k.RevokedAt != nil {
+			status = "revoked " + k.RevokedAt.Local().Format(time.DateOnly)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, k.Scope,
+			k.CreatedAt.Local().Format(time.DateTime), lastUsed, status)
+	}
+	return w.Flush()
+}
+
+func runAPIKeyRevoke(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	ok, err := confirm(fmt.Sprintf("Revoke API key %s? Clients using it will be rejected.", args[0]), apikeyRevokeYes)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Aborted")
+		return nil
+	}
+	if err := client.Storage().RevokeAPIKey(ctx, args[0], time.Now().UTC()); err != nil {
+		return err
+	}
+	fmt.Printf("Revoked API key %s\n", args[0])
+	return nil
+}
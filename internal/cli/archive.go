@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"langdag.com/langdag/internal/config"
+)
+
+var archiveOlderThan string
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Move old DAGs to cold storage",
+	Long: `Move every DAG with no node newer than --older-than to the archive
+location configured as archive.location (or LANGDAG_ARCHIVE_LOCATION):
+an s3://bucket/prefix, gs://bucket/prefix or local directory.
+
+Each DAG is stored as gzipped JSON. Its root node stays as a stub with an
+archived_uri and no content, so it is still listed. Opening the root (by
+ID, prefix or alias) restores the whole DAG; IDs of other nodes in an
+archived DAG do not resolve until then.
+
+Run it periodically (e.g. from cron) as an archival job.
+
+Examples:
+  langdag archive --older-than 90d
+  LANGDAG_ARCHIVE_LOCATION=s3://my-bucket/langdag langdag archive --older-than 720h`,
+	RunE: runArchive,
+}
+
+func init() {
+	archiveCmd.Flags().StringVar(&archiveOlderThan, "older-than", "90d", "archive DAGs idle for this long (e.g. 90d, 720h)")
+	rootCmd.AddCommand(archiveCmd)
+}
+
+func runArchive(cmd *cobra.Command, args []string) error {
+	age, err := parseAge(archiveOlderThan)
+	if err != nil {
+		return fmt.Errorf("invalid --older-than: %w", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Archive.Location == "" {
+		return fmt.Errorf("no archive location: set archive.location or LANGDAG_ARCHIVE_LOCATION")
+	}
+
+	ctx := context.Background()
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	n, err := client.Archive(ctx, time.Now().Add(-age))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Archived %d DAG(s) to %s\n", n, cfg.Archive.Location)
+	return nil
+}
+
+// parseAge parses a Go duration or a whole number of days ("90d").
+func parseAge(s string) (time.Duration, error) {
+	var age time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number of days: %s", s)
+		}
+		age = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+		age = d
+	}
+	if age <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return age, nil
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"langdag.com/langdag"
+)
+
+var (
+	askModel string
+	askAll   bool
+)
+
+var askCmd = &cobra.Command{
+	Use:   "ask <dag-id> <question>",
+	Short: "Ask a question about past conversations",
+	Long: `Ask the model a question about a conversation (any node ID, prefix or
+alias in it). The model sees the conversation, condensed to the messages
+most related to the question when it is long, and cites the node IDs it
+relies on.
+
+The question and answer are saved as a side branch off the root, marked
+as meta so later questions ignore them.
+
+With --all, the question is answered from every conversation: the
+messages matching the most words of the question are given to the model,
+and listed after the answer. The question and answer are saved as a new
+conversation, marked as meta.
+
+Examples:
+  langdag ask abc123 "what did we decide about the cache?"
+  langdag ask abc123 -m claude-haiku-4-5 "list the open questions"
+  langdag ask --all "which conversations mention the staging cluster?"`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if askAll {
+			return cobra.MinimumNArgs(1)(cmd, args)
+		}
+		return cobra.MinimumNArgs(2)(cmd, args)
+	},
+	Run: runAsk,
+}
+
+func init() {
+	askCmd.Flags().StringVarP(&askModel, "model", "m", "", "model to use (default: the conversation's)")
+	askCmd.Flags().BoolVar(&askAll, "all", false, "answer from every conversation")
+	rootCmd.AddCommand(askCmd)
+}
+
+func runAsk(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		exitError("%v", err)
+	}
+	defer client.Close()
+
+	var opts []langdag.PromptOption
+	if askModel != "" {
+		opts = append(opts, langdag.WithModel(askModel))
+	}
+	var result *langdag.PromptResult
+	var sources []langdag.SearchMatch
+	if askAll {
+		sources, result, err = client.AskAll(ctx, strings.Join(args, " "), opts...)
+	} else {
+		result, err = client.Ask(ctx, args[0], strings.Join(args[1:], " "), opts...)
+	}
+	if err != nil {
+		exitError("ask failed: %v", err)
+	}
+	for chunk := range result.Stream {
+		if chunk.Error != nil {
+			fmt.Printf("\nError: %v\n", chunk.Error)
+			return
+		}
+		if chunk.Done {
+			fmt.Printf("\n\n(node: %s)\n", shortID(chunk.NodeID))
+		} else {
+			fmt.Print(chunk.Content)
+		}
+	}
+	if len(sources) > 0 {
+		fmt.Println("\nSources:")
+		for _, m := range sources {
+			fmt.Printf("  [%s] in %s  %s\n", shortID(m.Node.ID), shortID(m.Path[0]), m.Snippet)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"langdag.com/langdag/internal/audit"
+)
+
+var (
+	auditFrom   string
+	auditTo     string
+	auditOutput string
+	auditKeyEnv string
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Export and verify audit logs",
+}
+
+var auditExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a tamper-evident log of stored nodes",
+	Long: `Export every node created in a period as hash-chained JSONL.
+
+Assistant nodes are recorded as "completion" events (one per provider call);
+user, tool result and system nodes as "message" events. Each entry's hash
+covers the previous entry's hash, so any edit, removal or reordering is
+detected by 'langdag audit verify'.
+
+If the environment variable named by --key-env is set, hashes are
+HMAC-SHA256 with its value as the key, so the log can only be produced
+(and verified) by holders of the key.
+
+--from and --to accept RFC 3339 timestamps or dates (YYYY-MM-DD). A date
+passed to --to includes that whole day.
+
+Examples:
+  langdag audit export --from 2026-01-01 --to 2026-03-31 -o q1.jsonl
+  LANGDAG_AUDIT_KEY=secret langdag audit export --from 2026-01-01`,
+	RunE: runAuditExport,
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify <file>",
+	Short: "Verify the hash chain of an exported audit log",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runAuditVerify,
+}
+
+func init() {
+	auditExportCmd.Flags().StringVar(&auditFrom, "from", "", "start of the period (inclusive)")
+	auditExportCmd.Flags().StringVar(&auditTo, "to", "", "end of the period")
+	auditExportCmd.Flags().StringVarP(&auditOutput, "output", "o", "", "output file (default stdout)")
+	auditCmd.PersistentFlags().StringVar(&auditKeyEnv, "key-env", "LANGDAG_AUDIT_KEY", "environment variable holding the HMAC key")
+
+	auditCmd.AddCommand(auditExportCmd)
+	auditCmd.AddCommand(auditVerifyCmd)
+	rootCmd.AddCommand(auditCmd)
+}
+
+func runAuditExport(cmd *cobra.Command, args []string) error {
+	from, _, err := parseAuditTime(auditFrom)
+	if err != nil {
+		return fmt.Errorf("invalid --from: %w", err)
+	}
+	to, dateOnly, err := parseAuditTime(auditTo)
+	if err != nil {
+		return fmt.Errorf("invalid --to: %w", err)
+	}
+	if dateOnly {
+		to = to.AddDate(0, 0, 1)
+	}
+
+	ctx := context.Background()
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	var w io.Writer = os.Stdout
+	if auditOutput != "" {
+		f, err := os.OpenFile(auditOutput, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	n, err := audit.Export(ctx, client.Storage(), from, to, auditKey(), w)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d entries\n", n)
+	return nil
+}
+
+func runAuditVerify(cmd *cobra.Command, args []string) error {
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := audit.Verify(f, auditKey())
+	if err != nil {
+		return fmt.Errorf("verification failed after %d valid entries: %w", n, err)
+	}
+	fmt.Printf("OK: %d entries verified\n", n)
+	return nil
+}
+
+// auditKey returns the HMAC key from the environment, or nil.
+func auditKey() []byte {
+	if auditKeyEnv == "" {
+		return nil
+	}
+	if key := os.Getenv(auditKeyEnv); key != "" {
+		return []byte(key)
+	}
+	return nil
+}
+
+// parseAuditTime parses an RFC 3339 timestamp or a YYYY-MM-DD date (UTC).
+// An empty value yields the zero time. dateOnly reports whether s was a date.
+func parseAuditTime(s string) (t time.Time, dateOnly bool, err error) {
+	if s == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, s)
+	return t, false, err
+}
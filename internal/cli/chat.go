@@ -1,20 +1,30 @@
 package cli
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 
+	"github.com/chzyer/readline"
 	"github.com/spf13/cobra"
 	"langdag.com/langdag"
 	"langdag.com/langdag/internal/config"
+	"langdag.com/langdag/internal/logging"
+	"langdag.com/langdag/types"
 )
 
 var (
 	promptModel        string
 	promptSystemPrompt string
+	promptPreset       string
+	promptLanguage     string
+	promptStdin        bool
+	promptQuiet        bool
 )
 
 // promptCmd handles prompting — new conversations or continuing from a node.
@@ -26,19 +36,36 @@ var promptCmd = &cobra.Command{
 Examples:
   langdag prompt "What is LangDAG?"                  # new conversation
   langdag prompt <node-id> "Tell me more"            # continue from node
+  langdag prompt -m <model> <node-id> "Go deeper"    # switch models from node
   langdag prompt                                     # interactive mode (new)
-  langdag prompt <node-id>                           # interactive mode from node`,
+  langdag prompt <node-id>                           # interactive mode from node
+  langdag prompt --preset reviewer "Review this"     # use a preset from config
+  cat notes.txt | langdag prompt --stdin -q "Summarize this"  # in a pipeline
+
+In interactive mode, /help lists the commands, e.g. /branch <node-id> to
+continue from another node, /fork, /model <name> and /system <prompt>.`,
 	Run: runPrompt,
 }
 
 func init() {
-	promptCmd.Flags().StringVarP(&promptModel, "model", "m", "claude-sonnet-4-20250514", "model to use")
+	promptCmd.Flags().StringVarP(&promptModel, "model", "m", "claude-sonnet-4-20250514", "model to use (when continuing, default: the branch's model)")
 	promptCmd.Flags().StringVarP(&promptSystemPrompt, "system", "s", "", "system prompt")
+	promptCmd.Flags().StringVar(&promptPreset, "preset", "", "named preset from config (model, system prompt, temperature)")
+	promptCmd.Flags().StringVar(&promptLanguage, "language", "", "language of the replies (e.g. fr or French)")
+	promptCmd.Flags().BoolVar(&promptStdin, "stdin", false, "append the text piped to stdin to the message")
+	promptCmd.Flags().BoolVarP(&promptQuiet, "quiet", "q", false, "print only the reply (one-shot prompts)")
 }
 
 func runPrompt(cmd *cobra.Command, args []string) {
 	ctx := context.Background()
 
+	if remote, err := newRemoteClient(); err != nil {
+		exitError("%v", err)
+	} else if remote != nil {
+		runRemotePrompt(ctx, cmd, remote, args)
+		return
+	}
+
 	client, err := newLibraryClient(ctx)
 	if err != nil {
 		exitError("%v", err)
@@ -69,12 +96,38 @@ func runPrompt(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	promptOpts := []langdag.PromptOption{
-		langdag.WithModel(promptModel),
+	var promptOpts []langdag.PromptOption
+	if promptPreset != "" {
+		promptOpts = append(promptOpts, langdag.WithPreset(promptPreset))
+	}
+	// With a preset, only an explicit --model overrides the preset's model;
+	// continuations keep the model of the branch unless --model is given.
+	if (promptPreset == "" && nodeID == "") || cmd.Flags().Changed("model") {
+		promptOpts = append(promptOpts, langdag.WithModel(promptModel))
 	}
 	if promptSystemPrompt != "" {
 		promptOpts = append(promptOpts, langdag.WithSystemPrompt(promptSystemPrompt))
 	}
+	if promptLanguage != "" {
+		promptOpts = append(promptOpts, langdag.WithLanguage(promptLanguage))
+	}
+
+	if promptStdin {
+		message, err = readStdinMessage(os.Stdin, message)
+		if err != nil {
+			exitError("failed to read stdin: %v", err)
+		}
+		if message == "" {
+			exitError("no message given, and nothing was piped to stdin")
+		}
+	}
+	if message == "" && promptQuiet {
+		exitError("--quiet needs a message: interactive mode is not quiet")
+	}
+
+	if message == "" && nonInteractive {
+		exitError("no message given, and interactive mode is disabled by --non-interactive")
+	}
 
 	if nodeID != "" {
 		if message != "" {
@@ -82,7 +135,7 @@ func runPrompt(cmd *cobra.Command, args []string) {
 			sendAndPrint(ctx, client, nodeID, message, promptOpts...)
 		} else {
 			// Interactive from node
-			fmt.Printf("Continuing from node %s\n", nodeID[:8])
+			fmt.Printf("Continuing from node %s\n", shortID(nodeID))
 			fmt.Println()
 			runInteractive(ctx, client, nodeID, promptOpts...)
 		}
@@ -108,6 +161,9 @@ func newLibraryClient(ctx context.Context) (*langdag.Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
+	if err := logging.Setup(cfg.Logging); err != nil {
+		return nil, err
+	}
 
 	storagePath := cfg.Storage.Path
 	if storagePath == "./langdag.db" {
@@ -115,8 +171,9 @@ func newLibraryClient(ctx context.Context) (*langdag.Client, error) {
 	}
 
 	libCfg := langdag.Config{
-		StoragePath: storagePath,
-		Provider:    cfg.Providers.Default,
+		StoragePath:   storagePath,
+		FileThreshold: cfg.Storage.FileThreshold,
+		Provider:      cfg.Providers.Default,
 		APIKeys: map[string]string{
 			"anthropic": cfg.Providers.Anthropic.APIKey,
 			"openai":    cfg.Providers.OpenAI.APIKey,
@@ -186,6 +243,69 @@ func newLibraryClient(ctx context.Context) (*langdag.Client, error) {
 		libCfg.Routing = append(libCfg.Routing, entry)
 	}
 	libCfg.FallbackOrder = cfg.Providers.FallbackOrder
+	libCfg.DefaultMaxTokens = cfg.Defaults.MaxTokens
+	libCfg.ModelMaxTokens = cfg.Defaults.ModelMaxTokens
+	libCfg.GlobalSystemPrompt = cfg.Defaults.SystemPrompt
+	libCfg.Language = cfg.Defaults.Language
+	libCfg.ArchiveLocation = cfg.Archive.Location
+	libCfg.IDFormat = cfg.IDs.Format
+	if cfg.Trash.Enabled {
+		libCfg.TrashDir = cfg.Trash.Dir
+		if libCfg.TrashDir == "" {
+			libCfg.TrashDir = config.GetDefaultTrashDir()
+		}
+		if r := cfg.Trash.Retention; r != "" {
+			d, err := time.ParseDuration(r)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid trash.retention %q", r)
+			}
+			libCfg.TrashRetention = d
+		}
+	}
+	for model, p := range cfg.Pricing {
+		if libCfg.Pricing == nil {
+			libCfg.Pricing = make(map[string]langdag.ModelPrice)
+		}
+		libCfg.Pricing[model] = langdag.ModelPrice{
+			InputPer1M:     p.Input,
+			OutputPer1M:    p.Output,
+			CacheReadPer1M: p.CacheRead,
+			Currency:       p.Currency,
+		}
+	}
+	if cfg.Titles.Generate {
+		libCfg.Titles = &langdag.TitleConfig{Generate: true, Model: cfg.Titles.Model}
+	}
+	if e := cfg.Embeddings; e.Enabled {
+		libCfg.Embeddings = &langdag.EmbeddingConfig{Enabled: true, Model: e.Model}
+	}
+	if cfg.Classifier.Enabled || cfg.Classifier.Model != "" || len(cfg.Classifier.Rules) > 0 {
+		libCfg.Classifier = &langdag.ClassifierConfig{
+			Enabled: cfg.Classifier.Enabled,
+			Rules:   cfg.Classifier.Rules,
+			Model:   cfg.Classifier.Model,
+		}
+	}
+	if scan := cfg.Safety.InjectionScan; scan.Enabled {
+		libCfg.InjectionScan = &langdag.InjectionScanConfig{
+			Enabled:             true,
+			RequireConfirmation: scan.RequireConfirmation,
+			Patterns:            scan.Patterns,
+		}
+	}
+	for _, e := range cfg.Glossary {
+		libCfg.Glossary = append(libCfg.Glossary, langdag.GlossaryEntry{Term: e.Term, Definition: e.Definition})
+	}
+	if len(cfg.Presets) > 0 {
+		libCfg.Presets = make(map[string]langdag.Preset, len(cfg.Presets))
+		for name, p := range cfg.Presets {
+			libCfg.Presets[name] = langdag.Preset{
+				Model:        p.Model,
+				SystemPrompt: p.System,
+				Temperature:  p.Temperature,
+			}
+		}
+	}
 
 	return langdag.New(libCfg)
 }
@@ -224,17 +344,7 @@ func sendAndPrintNew(ctx context.Context, client *langdag.Client, message string
 	if err != nil {
 		exitError("prompt failed: %v", err)
 	}
-	for chunk := range result.Stream {
-		if chunk.Error != nil {
-			fmt.Printf("\nError: %v\n", chunk.Error)
-			return
-		}
-		if chunk.Done {
-			fmt.Printf("\n\n(node: %s)\n", chunk.NodeID[:8])
-		} else {
-			fmt.Print(chunk.Content)
-		}
-	}
+	printReply(result)
 }
 
 // sendAndPrint continues from a node and prints the response.
@@ -243,33 +353,92 @@ func sendAndPrint(ctx context.Context, client *langdag.Client, parentNodeID, mes
 	if err != nil {
 		exitError("prompt failed: %v", err)
 	}
+	printReply(result)
+}
+
+// printReply prints a streamed response followed by its node ID. With
+// --quiet, only the reply is printed, and an error ends the command.
+func printReply(result *langdag.PromptResult) {
 	for chunk := range result.Stream {
 		if chunk.Error != nil {
+			if promptQuiet {
+				exitError("%v", chunk.Error)
+			}
 			fmt.Printf("\nError: %v\n", chunk.Error)
 			return
 		}
-		if chunk.Done {
-			fmt.Printf("\n\n(node: %s)\n", chunk.NodeID[:8])
-		} else {
+		switch {
+		case chunk.Done && promptQuiet:
+			fmt.Println()
+		case chunk.Done:
+			fmt.Printf("\n\n(node: %s)\n", shortID(chunk.NodeID))
+		default:
 			fmt.Print(chunk.Content)
 		}
 	}
 }
 
+// readStdinMessage adds the text piped to stdin to message, after a blank
+// line, or returns it alone when message is empty.
+func readStdinMessage(r io.Reader, message string) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimRight(string(data), "\r\n")
+	switch {
+	case text == "":
+		return message, nil
+	case message == "":
+		return text, nil
+	}
+	return message + "\n\n" + text, nil
+}
+
 // runInteractiveNew runs interactive mode for a new conversation.
 func runInteractiveNew(ctx context.Context, client *langdag.Client, opts ...langdag.PromptOption) {
-	reader := bufio.NewReader(os.Stdin)
-	var currentNodeID string
+	runInteractive(ctx, client, "", opts...)
+}
+
+// runInteractive runs interactive mode, continuing from startNodeID when it
+// is set. Ctrl-C cancels an in-flight response (keeping the partial output);
+// at the prompt it exits.
+func runInteractive(ctx context.Context, client *langdag.Client, startNodeID string, opts ...langdag.PromptOption) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	var in *chatInput
+	if readline.IsTerminal(int(os.Stdin.Fd())) {
+		in, _ = newTerminalChatInput(defaultDraftPath())
+	}
+	if in == nil {
+		in = newChatInput(os.Stdin, interrupt, defaultDraftPath())
+	}
+	defer in.Close()
+	in.setHistoryPath(dagHistoryPath(ctx, client, startNodeID))
+
+	if in.hasDraft() {
+		fmt.Println("You have an unsent draft. Use /edit to resume it.")
+		fmt.Println()
+	}
+	currentNodeID := startNodeID
 
 	for {
-		fmt.Print("You> ")
-		input, err := reader.ReadString('\n')
+		input, err := in.read()
+		if errors.Is(err, errInterrupted) {
+			fmt.Println()
+			if in.hasDraft() {
+				fmt.Println("Draft saved. Use /edit to resume it.")
+			}
+			fmt.Println("Goodbye!")
+			return
+		}
 		if err != nil {
 			fmt.Println()
 			return
 		}
 
-		input = strings.TrimSpace(input)
 		if input == "" {
 			continue
 		}
@@ -278,81 +447,296 @@ func runInteractiveNew(ctx context.Context, client *langdag.Client, opts ...lang
 			return
 		}
 		if input == "/help" {
-			fmt.Println("\nCommands: /quit, /help")
+			fmt.Println("\nCommands: /edit [text], /regenerate, /retry, /branch <node-id>, /fork,")
+			fmt.Println("/model <name>, /system <prompt>, /find <text>, /quit, /help")
+			fmt.Println("/edit resumes an unsent draft, or rewrites your last message in $EDITOR;")
+			fmt.Println("the edited message and /regenerate start a new branch next to the old one.")
+			fmt.Println("/retry sends a message that failed again, or regenerates the last reply.")
+			fmt.Println("/branch continues from another node of this conversation; /fork copies")
+			fmt.Println("the current branch into a new conversation and continues there.")
+			fmt.Println("/model switches the model of the next replies; /system sets the system")
+			fmt.Println("prompt of the conversation.")
+			fmt.Println("Start and end a multi-line message with " + multiLineFence + ".")
+			fmt.Println("Up/Down recall earlier messages in this conversation; Ctrl-R searches them.")
+			fmt.Println("Ctrl-C stops a response in progress; at the prompt it exits.")
 			fmt.Println()
 			continue
 		}
-
-		fmt.Print("\nAssistant> ")
-		var result *langdag.PromptResult
-		if currentNodeID == "" {
-			result, err = client.Prompt(ctx, input, opts...)
-		} else {
-			result, err = client.PromptFrom(ctx, currentNodeID, input, opts...)
-		}
-		if err != nil {
-			fmt.Printf("\nError: %v\n", err)
+		if query, ok := strings.CutPrefix(input, "/find "); ok {
+			printSearch(ctx, client, currentNodeID, query)
 			continue
 		}
-		for chunk := range result.Stream {
-			if chunk.Error != nil {
-				fmt.Printf("\nError: %v\n", chunk.Error)
-				break
+		if cmd, arg, ok := chatCommand(input); ok {
+			switch nodeID, err := runChatCommand(ctx, client, cmd, arg, currentNodeID, &opts); {
+			case err != nil:
+				fmt.Printf("\nError: %v\n\n", err)
+			case nodeID != currentNodeID:
+				currentNodeID = nodeID
+				in.setHistoryPath(dagHistoryPath(ctx, client, currentNodeID))
 			}
-			if chunk.Done {
-				currentNodeID = chunk.NodeID
+			continue
+		}
+		if input == "/retry" {
+			if in.hasDraft() {
+				input = in.loadDraft()
 			} else {
-				fmt.Print(chunk.Content)
+				input = "/regenerate"
 			}
 		}
-		fmt.Println()
-	}
-}
+		if input == "/regenerate" {
+			if currentNodeID == "" {
+				fmt.Println("\nNothing to regenerate yet.")
+				fmt.Println()
+				continue
+			}
+			nodeID, _ := streamResult(ctx, interrupt, func(ctx context.Context) (*langdag.PromptResult, error) {
+				return client.Regenerate(ctx, currentNodeID, opts...)
+			})
+			if nodeID != "" {
+				currentNodeID = nodeID
+			}
+			continue
+		}
 
-// runInteractive runs interactive mode from an existing node.
-func runInteractive(ctx context.Context, client *langdag.Client, startNodeID string, opts ...langdag.PromptOption) {
-	reader := bufio.NewReader(os.Stdin)
-	currentNodeID := startNodeID
+		// editedID is the user message that input replaces, if any.
+		var editedID string
+		if input == "/edit" || strings.HasPrefix(input, "/edit ") {
+			text := strings.TrimSpace(strings.TrimPrefix(input, "/edit"))
+			switch last := lastUserMessage(ctx, client, currentNodeID); {
+			case text != "":
+				if last == nil {
+					fmt.Println("\nNo message to edit yet.")
+					fmt.Println()
+					continue
+				}
+				editedID = last.ID
+			case in.hasDraft() || last == nil:
+				text, err = in.edit(in.loadDraft())
+			default:
+				editedID = last.ID
+				text, err = in.edit(last.Content)
+			}
+			if err != nil {
+				fmt.Printf("\nError: %v\n\n", err)
+				continue
+			}
+			if text == "" {
+				fmt.Println("Empty message, nothing sent.")
+				fmt.Println()
+				continue
+			}
+			input = text
+		}
 
-	for {
-		fmt.Print("You> ")
-		input, err := reader.ReadString('\n')
+		var nodeID string
+		if editedID != "" {
+			nodeID, err = streamResult(ctx, interrupt, func(ctx context.Context) (*langdag.PromptResult, error) {
+				return client.Edit(ctx, editedID, input, opts...)
+			})
+		} else {
+			nodeID, err = streamReply(ctx, client, currentNodeID, input, interrupt, opts...)
+		}
+		if nodeID != "" {
+			currentNodeID = nodeID
+			in.setHistoryPath(dagHistoryPath(ctx, client, currentNodeID))
+		}
 		if err != nil {
+			in.saveDraft(input)
+			fmt.Println("Message saved as draft. Use /edit to retry.")
 			fmt.Println()
-			return
+			continue
 		}
+		in.clearDraft()
+	}
+}
 
-		input = strings.TrimSpace(input)
-		if input == "" {
-			continue
+// chatCommand splits a /branch, /fork, /model or /system command into its
+// name and argument.
+func chatCommand(input string) (cmd, arg string, ok bool) {
+	cmd, arg, _ = strings.Cut(input, " ")
+	switch cmd {
+	case "/branch", "/fork", "/model", "/system":
+		return cmd, strings.TrimSpace(arg), true
+	}
+	return "", "", false
+}
+
+// runChatCommand runs a command split by chatCommand on the conversation
+// at currentNodeID, adding the options it sets to opts, and returns the
+// node to continue from.
+func runChatCommand(ctx context.Context, client *langdag.Client, cmd, arg, currentNodeID string, opts *[]langdag.PromptOption) (string, error) {
+	switch cmd {
+	case "/branch":
+		if arg == "" {
+			return currentNodeID, fmt.Errorf("usage: /branch <node-id>")
 		}
-		if input == "/quit" || input == "/exit" {
-			fmt.Println("Goodbye!")
-			return
+		node, err := client.GetNode(ctx, arg)
+		if err != nil {
+			return currentNodeID, err
 		}
-		if input == "/help" {
-			fmt.Println("\nCommands: /quit, /help")
-			fmt.Println()
-			continue
+		if node == nil {
+			return currentNodeID, fmt.Errorf("node not found: %s", arg)
+		}
+		if current, _ := client.GetNode(ctx, currentNodeID); current != nil && dagRootID(current) != dagRootID(node) {
+			return currentNodeID, fmt.Errorf("node %s is in another conversation", shortID(node.ID))
 		}
+		fmt.Printf("\nContinuing from node %s [%s]: %s\n\n", shortID(node.ID), node.NodeType, truncate(node.Content, 60))
+		return node.ID, nil
 
-		fmt.Print("\nAssistant> ")
-		result, err := client.PromptFrom(ctx, currentNodeID, input, opts...)
+	case "/fork":
+		if currentNodeID == "" {
+			return currentNodeID, fmt.Errorf("nothing to fork yet")
+		}
+		node, err := client.Clone(ctx, currentNodeID)
 		if err != nil {
-			fmt.Printf("\nError: %v\n", err)
-			continue
+			return currentNodeID, err
 		}
-		for chunk := range result.Stream {
-			if chunk.Error != nil {
-				fmt.Printf("\nError: %v\n", chunk.Error)
-				break
-			}
-			if chunk.Done {
-				currentNodeID = chunk.NodeID
-			} else {
-				fmt.Print(chunk.Content)
-			}
+		fmt.Printf("\nForked into a new conversation %s; continuing from node %s.\n\n", shortID(dagRootID(node)), shortID(node.ID))
+		return node.ID, nil
+
+	case "/model":
+		if arg == "" {
+			return currentNodeID, fmt.Errorf("usage: /model <name>")
+		}
+		*opts = append(*opts, langdag.WithModel(arg))
+		fmt.Printf("\nNext replies use %s.\n\n", arg)
+		return currentNodeID, nil
+
+	case "/system":
+		if currentNodeID == "" {
+			// Set on the root when the conversation starts.
+			*opts = append(*opts, langdag.WithSystemPrompt(arg))
+		} else if _, err := client.UpdateDAG(ctx, currentNodeID, langdag.DAGUpdate{SystemPrompt: &arg}); err != nil {
+			return currentNodeID, err
+		}
+		if arg == "" {
+			fmt.Println("\nSystem prompt removed.")
+		} else {
+			fmt.Println("\nSystem prompt set.")
 		}
 		fmt.Println()
+		return currentNodeID, nil
+	}
+	return currentNodeID, fmt.Errorf("unknown command %s", cmd)
+}
+
+// dagRootID returns the ID of the root of the DAG containing node.
+func dagRootID(node *types.Node) string {
+	if node.RootID != "" {
+		return node.RootID
+	}
+	return node.ID
+}
+
+// streamReply sends message (continuing from parentNodeID when set) and
+// prints the streamed response. A signal on interrupt cancels the response;
+// whatever was streamed so far is kept and its node ID returned. The error
+// is non-nil only when the message could not be answered.
+func streamReply(ctx context.Context, client *langdag.Client, parentNodeID, message string, interrupt <-chan os.Signal, opts ...langdag.PromptOption) (string, error) {
+	return streamResult(ctx, interrupt, func(ctx context.Context) (*langdag.PromptResult, error) {
+		if parentNodeID == "" {
+			return client.Prompt(ctx, message, opts...)
+		}
+		return client.PromptFrom(ctx, parentNodeID, message, opts...)
+	})
+}
+
+// streamResult prints the response started by start, like streamReply.
+func streamResult(ctx context.Context, interrupt <-chan os.Signal, start func(context.Context) (*langdag.PromptResult, error)) (string, error) {
+	genCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-stop:
+		}
+	}()
+
+	fmt.Print("\nAssistant> ")
+	result, err := start(genCtx)
+	if err != nil {
+		fmt.Printf("\nError: %v\n", err)
+		return "", err
+	}
+
+	var nodeID string
+	for chunk := range result.Stream {
+		if chunk.Error != nil {
+			err = chunk.Error
+			break
+		}
+		if chunk.Done {
+			nodeID = chunk.NodeID
+		} else {
+			fmt.Print(chunk.Content)
+		}
+	}
+
+	if genCtx.Err() != nil && ctx.Err() == nil {
+		fmt.Println("\n[interrupted]")
+		return nodeID, nil
+	}
+	if err != nil {
+		fmt.Printf("\nError: %v\n", err)
+		return nodeID, err
+	}
+	fmt.Println()
+	return nodeID, nil
+}
+
+// lastUserMessage returns the latest user message on the branch ending at
+// nodeID, or nil if there is none.
+func lastUserMessage(ctx context.Context, client *langdag.Client, nodeID string) *types.Node {
+	if nodeID == "" {
+		return nil
+	}
+	ancestors, err := client.GetAncestors(ctx, nodeID)
+	if err != nil {
+		return nil
+	}
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		if ancestors[i].NodeType == types.NodeTypeUser {
+			return ancestors[i]
+		}
+	}
+	return nil
+}
+
+// printSearch prints nodes in the current conversation that contain query,
+// along with the branch each match lives on.
+func printSearch(ctx context.Context, client *langdag.Client, nodeID, query string) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		fmt.Println("\nUsage: /find <text>")
+		fmt.Println()
+		return
+	}
+	if nodeID == "" {
+		fmt.Println("\nNothing to search yet.")
+		fmt.Println()
+		return
+	}
+
+	matches, err := client.SearchTree(ctx, nodeID, query)
+	if err != nil {
+		fmt.Printf("\nError: %v\n\n", err)
+		return
+	}
+	fmt.Println()
+	if len(matches) == 0 {
+		fmt.Printf("No matches for %q.\n\n", query)
+		return
+	}
+	for _, m := range matches {
+		branch := make([]string, len(m.Path))
+		for i, id := range m.Path {
+			branch[i] = shortID(id)
+		}
+		fmt.Printf("[%s] %s  %s\n", m.Node.NodeType, shortID(m.Node.ID), m.Snippet)
+		fmt.Printf("    branch: %s\n", strings.Join(branch, " > "))
 	}
+	fmt.Println()
 }
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"langdag.com/langdag"
+	"langdag.com/langdag/types"
+)
+
+// historyDir returns the directory holding per-DAG chat input history.
+func historyDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".config", "langdag", "history")
+}
+
+// dagHistoryPath returns the input history file for the DAG containing
+// nodeID, seeding it from the DAG's user messages the first time so that
+// history search also covers conversations started elsewhere.
+func dagHistoryPath(ctx context.Context, client *langdag.Client, nodeID string) string {
+	dir := historyDir()
+	if dir == "" || nodeID == "" {
+		return ""
+	}
+	node, err := client.GetNode(ctx, nodeID)
+	if err != nil || node == nil {
+		return ""
+	}
+	rootID := node.RootID
+	if rootID == "" {
+		rootID = node.ID
+	}
+
+	path := filepath.Join(dir, rootID)
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	nodes, err := client.GetSubtree(ctx, rootID)
+	if err != nil {
+		return path
+	}
+	var lines []string
+	for _, n := range nodes {
+		if line, ok := historyLine(n); ok {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > 0 && os.MkdirAll(dir, 0755) == nil {
+		_ = os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+	}
+	return path
+}
+
+// historyLine returns the text of a user message as a single history entry.
+// Tool results and other structured content are skipped.
+func historyLine(n *types.Node) (string, bool) {
+	if n.NodeType != types.NodeTypeUser {
+		return "", false
+	}
+	content := strings.TrimSpace(n.Content)
+	if strings.HasPrefix(content, "[") && json.Valid([]byte(content)) {
+		return "", false
+	}
+	line := strings.Join(strings.Fields(content), " ")
+	return line, line != ""
+}
@@ -0,0 +1,345 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/chzyer/readline"
+)
+
+// multiLineFence starts and ends a multi-line message in interactive chat.
+const multiLineFence = "```"
+
+// errInterrupted is returned by chatInput.read when Ctrl-C is pressed at the
+// prompt.
+var errInterrupted = errors.New("interrupted")
+
+// lineReader reads single lines for chatInput. ReadLine returns
+// errInterrupted (with any partially typed text) when Ctrl-C is pressed.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+	// SetHistoryPath switches to the history persisted at path.
+	SetHistoryPath(path string)
+	// AddHistory appends a submitted message to the history.
+	AddHistory(line string)
+	// Suspend releases the terminal (e.g. while $EDITOR runs); Resume
+	// reclaims it.
+	Suspend()
+	Resume() error
+	Close() error
+}
+
+// chatInput reads messages for the interactive chat loop.
+type chatInput struct {
+	lr          lineReader
+	draftPath   string
+	historyPath string
+}
+
+// newChatInput reads plain lines from r, without line editing. interrupt
+// delivers Ctrl-C signals; draftPath is where unsent input is saved (empty
+// disables drafts).
+func newChatInput(r io.Reader, interrupt <-chan os.Signal, draftPath string) *chatInput {
+	return &chatInput{lr: newPlainLineReader(r, interrupt), draftPath: draftPath}
+}
+
+// newTerminalChatInput reads from the terminal with line editing, history
+// navigation and Ctrl-R search.
+func newTerminalChatInput(draftPath string) (*chatInput, error) {
+	lr, err := newTerminalLineReader()
+	if err != nil {
+		return nil, err
+	}
+	return &chatInput{lr: lr, draftPath: draftPath}, nil
+}
+
+// Close releases the underlying reader.
+func (in *chatInput) Close() error {
+	return in.lr.Close()
+}
+
+// read prompts for and returns the next message. A line containing only
+// ``` starts a multi-line message that ends at the next ``` line. If Ctrl-C
+// is pressed with input part way typed, that input is saved as a draft
+// before errInterrupted is returned.
+func (in *chatInput) read() (string, error) {
+	line, err := in.lr.ReadLine("You> ")
+	if err != nil {
+		if errors.Is(err, errInterrupted) && strings.TrimSpace(line) != "" {
+			in.saveDraft(line)
+		}
+		return "", err
+	}
+	if strings.TrimSpace(line) != multiLineFence {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			in.lr.AddHistory(line)
+		}
+		return line, nil
+	}
+
+	var buf []string
+	for {
+		line, err := in.lr.ReadLine("... ")
+		if err != nil {
+			if errors.Is(err, errInterrupted) {
+				if line != "" {
+					buf = append(buf, line)
+				}
+				if len(buf) > 0 {
+					in.saveDraft(strings.Join(buf, "\n"))
+				}
+			}
+			return "", err
+		}
+		if strings.TrimSpace(line) == multiLineFence {
+			return strings.TrimSpace(strings.Join(buf, "\n")), nil
+		}
+		buf = append(buf, line)
+	}
+}
+
+// setHistoryPath switches to the input history stored at path. Messages
+// already entered in this session are carried over.
+func (in *chatInput) setHistoryPath(path string) {
+	if path == in.historyPath {
+		return
+	}
+	in.historyPath = path
+	in.lr.SetHistoryPath(path)
+}
+
+// plainLineReader reads lines on a background goroutine so that a Ctrl-C
+// signal can be handled while a read is pending. The goroutine only reads
+// when asked to, so nothing competes with $EDITOR for stdin during /edit.
+type plainLineReader struct {
+	want      chan struct{}
+	lines     chan lineResult
+	pending   bool
+	interrupt <-chan os.Signal
+}
+
+type lineResult struct {
+	line string
+	err  error
+}
+
+func newPlainLineReader(r io.Reader, interrupt <-chan os.Signal) *plainLineReader {
+	p := &plainLineReader{
+		want:      make(chan struct{}),
+		lines:     make(chan lineResult),
+		interrupt: interrupt,
+	}
+	go func() {
+		reader := bufio.NewReader(r)
+		for range p.want {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				err = nil
+			}
+			p.lines <- lineResult{line: strings.TrimRight(line, "\r\n"), err: err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// ReadLine waits for the next line or a Ctrl-C.
+func (p *plainLineReader) ReadLine(prompt string) (string, error) {
+	fmt.Print(prompt)
+	if !p.pending {
+		p.want <- struct{}{}
+		p.pending = true
+	}
+	select {
+	case res := <-p.lines:
+		p.pending = false
+		return res.line, res.err
+	case <-p.interrupt:
+		return "", errInterrupted
+	}
+}
+
+func (p *plainLineReader) SetHistoryPath(string) {}
+func (p *plainLineReader) AddHistory(string)     {}
+func (p *plainLineReader) Suspend()              {}
+func (p *plainLineReader) Resume() error         { return nil }
+func (p *plainLineReader) Close() error          { return nil }
+
+// terminalLineReader provides line editing via readline: arrow keys move
+// through history and Ctrl-R searches it.
+type terminalLineReader struct {
+	rl          *readline.Instance
+	historyPath string
+	session     []string // messages entered since startup, in order
+}
+
+func newTerminalLineReader() (*terminalLineReader, error) {
+	t := &terminalLineReader{}
+	if err := t.Resume(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ReadLine reads a line with editing. On Ctrl-C the partially typed line is
+// returned along with errInterrupted.
+func (t *terminalLineReader) ReadLine(prompt string) (string, error) {
+	t.rl.SetPrompt(prompt)
+	line, err := t.rl.Readline()
+	if errors.Is(err, readline.ErrInterrupt) {
+		return line, errInterrupted
+	}
+	return line, err
+}
+
+// SetHistoryPath loads the history at path and appends this session's
+// messages that aren't persisted there yet.
+func (t *terminalLineReader) SetHistoryPath(path string) {
+	t.historyPath = path
+	if path != "" {
+		_ = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	t.rl.SetHistoryPath(path)
+	for _, line := range t.session {
+		_ = t.rl.SaveHistory(line)
+	}
+}
+
+func (t *terminalLineReader) AddHistory(line string) {
+	t.session = append(t.session, line)
+	_ = t.rl.SaveHistory(line)
+}
+
+// Suspend closes readline, restoring the terminal for another program.
+func (t *terminalLineReader) Suspend() {
+	if t.rl != nil {
+		t.rl.Close()
+		t.rl = nil
+	}
+}
+
+// Resume (re)opens readline with the current history file.
+func (t *terminalLineReader) Resume() error {
+	if t.rl != nil {
+		return nil
+	}
+	rl, err := readline.NewEx(&readline.Config{
+		HistoryFile:            t.historyPath,
+		HistorySearchFold:      true,
+		DisableAutoSaveHistory: true,
+	})
+	if err != nil {
+		return err
+	}
+	t.rl = rl
+	return nil
+}
+
+func (t *terminalLineReader) Close() error {
+	t.Suspend()
+	return nil
+}
+
+// edit opens $EDITOR (falling back to vi) pre-filled with initial and
+// returns the edited text. The result is saved as the new draft so it
+// survives a failed send.
+func (in *chatInput) edit(initial string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	f, err := os.CreateTemp("", "langdag-message-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(initial); err != nil {
+		f.Close()
+		return "", err
+	}
+	f.Close()
+
+	// $EDITOR may include arguments (e.g. "code --wait").
+	parts := strings.Fields(editor)
+	cmd := exec.Command(parts[0], append(parts[1:], f.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	in.lr.Suspend()
+	runErr := cmd.Run()
+	if err := in.lr.Resume(); err != nil {
+		return "", err
+	}
+	if runErr != nil {
+		return "", fmt.Errorf("editor %q failed: %w", editor, runErr)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(string(data))
+	in.saveDraft(text)
+	return text, nil
+}
+
+// loadDraft returns the saved draft, or "" if there is none.
+func (in *chatInput) loadDraft() string {
+	if in.draftPath == "" {
+		return ""
+	}
+	data, err := os.ReadFile(in.draftPath)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// saveDraft stores text as the draft. Empty text clears it.
+func (in *chatInput) saveDraft(text string) {
+	if in.draftPath == "" {
+		return
+	}
+	if strings.TrimSpace(text) == "" {
+		in.clearDraft()
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(in.draftPath), 0755); err != nil {
+		return
+	}
+	_ = os.WriteFile(in.draftPath, []byte(text), 0600)
+}
+
+// clearDraft removes the saved draft.
+func (in *chatInput) clearDraft() {
+	if in.draftPath != "" {
+		_ = os.Remove(in.draftPath)
+	}
+}
+
+// hasDraft reports whether a draft is saved.
+func (in *chatInput) hasDraft() bool {
+	return strings.TrimSpace(in.loadDraft()) != ""
+}
+
+// defaultDraftPath returns where unsent chat input is saved.
+func defaultDraftPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".config", "langdag", "chat_draft.txt")
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"langdag.com/langdag"
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/internal/storage/memory"
+	"langdag.com/langdag/types"
+)
+
+func TestChatInputReadsSingleAndMultiLineMessages(t *testing.T) {
+	r := strings.NewReader("hello\n```\nline one\n\n  line two\n```\nbye")
+	in := newChatInput(r, nil, "")
+
+	want := []string{"hello", "line one\n\n  line two", "bye"}
+	for _, w := range want {
+		got, err := in.read()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if got != w {
+			t.Errorf("read = %q, want %q", got, w)
+		}
+	}
+	if _, err := in.read(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF at end of input, got %v", err)
+	}
+}
+
+// stallingReader serves data, then closes reached and blocks forever,
+// simulating a user who stops typing.
+type stallingReader struct {
+	data    *strings.Reader
+	reached chan struct{}
+}
+
+func (r *stallingReader) Read(p []byte) (int, error) {
+	if r.data.Len() > 0 {
+		return r.data.Read(p)
+	}
+	close(r.reached)
+	select {}
+}
+
+func TestChatInputInterruptSavesMultiLineDraft(t *testing.T) {
+	draftPath := filepath.Join(t.TempDir(), "draft.txt")
+	r := &stallingReader{data: strings.NewReader("```\nfirst\nsecond\n"), reached: make(chan struct{})}
+	interrupt := make(chan os.Signal, 1)
+	in := newChatInput(r, interrupt, draftPath)
+
+	// Interrupt once both lines have been consumed and the reader is
+	// waiting for more input.
+	go func() {
+		<-r.reached
+		interrupt <- os.Interrupt
+	}()
+
+	if _, err := in.read(); !errors.Is(err, errInterrupted) {
+		t.Fatalf("read: got %v, want errInterrupted", err)
+	}
+	if got := in.loadDraft(); got != "first\nsecond" {
+		t.Errorf("draft = %q, want %q", got, "first\nsecond")
+	}
+
+	in.clearDraft()
+	if in.hasDraft() {
+		t.Error("expected draft to be cleared")
+	}
+}
+
+func TestDAGHistoryPathSeedsFromUserMessages(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	store := memory.New()
+	client := langdag.NewWithDeps(store, mock.New(mock.Config{Mode: "fixed", FixedResponse: "ok"}))
+
+	nodes := []*types.Node{
+		{ID: "root", NodeType: types.NodeTypeUser, Content: "first\nquestion", CreatedAt: time.Now()},
+		{ID: "a1", ParentID: "root", RootID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant, Content: "answer", CreatedAt: time.Now()},
+		{ID: "u2", ParentID: "a1", RootID: "root", Sequence: 2, NodeType: types.NodeTypeUser, Content: `[{"type":"tool_result","tool_use_id":"t1","content":"x"}]`, CreatedAt: time.Now()},
+		{ID: "u3", ParentID: "a1", RootID: "root", Sequence: 3, NodeType: types.NodeTypeUser, Content: "follow up", CreatedAt: time.Now()},
+	}
+	for _, n := range nodes {
+		if err := store.CreateNode(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := dagHistoryPath(ctx, client, "u3")
+	if filepath.Base(path) != "root" {
+		t.Fatalf("history path = %q, want file named after the root", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read history: %v", err)
+	}
+	if got, want := string(data), "first question\nfollow up\n"; got != want {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+
+	// An existing history file is left alone.
+	if err := os.WriteFile(path, []byte("mine\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dagHistoryPath(ctx, client, "root")
+	if data, _ := os.ReadFile(path); string(data) != "mine\n" {
+		t.Errorf("history was overwritten: %q", data)
+	}
+}
@@ -7,12 +7,31 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"langdag.com/langdag"
 	"langdag.com/langdag/internal/config"
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/internal/storage/sqlite"
 )
 
+// newMockLibraryClient returns a client on a new database whose replies
+// are all response.
+func newMockLibraryClient(t *testing.T, response string) *langdag.Client {
+	t.Helper()
+	store, err := sqlite.New(filepath.Join(t.TempDir(), "cli.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	client := langdag.NewWithDeps(store, mock.New(mock.Config{Mode: "fixed", FixedResponse: response}))
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
 func TestConvertRoutingStagesPreservesExplicitEmptyDefault(t *testing.T) {
 	stages := convertRoutingStages([]config.RoutingStage{})
 	if stages == nil || len(stages) != 0 {
@@ -79,3 +98,89 @@ data: {"type":"response.completed","response":{"id":"resp-cache-cli","model":%q,
 		t.Fatalf("request model = %q, want embedded catalog native id %q", requestedModel, nativeID)
 	}
 }
+
+func TestRunChatCommand(t *testing.T) {
+	ctx := context.Background()
+	client := newMockLibraryClient(t, "ok")
+	reply := func(parentID, message string) string {
+		t.Helper()
+		var result *langdag.PromptResult
+		var err error
+		if parentID == "" {
+			result, err = client.Prompt(ctx, message)
+		} else {
+			result, err = client.PromptFrom(ctx, parentID, message)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for chunk := range result.Stream {
+			if chunk.Done {
+				return chunk.NodeID
+			}
+		}
+		t.Fatal("no reply saved")
+		return ""
+	}
+	first := reply("", "one")
+	second := reply(first, "two")
+	other := reply("", "elsewhere")
+
+	var opts []langdag.PromptOption
+	run := func(input, current string) (string, error) {
+		t.Helper()
+		cmd, arg, ok := chatCommand(input)
+		if !ok {
+			t.Fatalf("%q is not a command", input)
+		}
+		return runChatCommand(ctx, client, cmd, arg, current, &opts)
+	}
+
+	if _, _, ok := chatCommand("/modelx"); ok {
+		t.Error("/modelx should not be a command")
+	}
+	if id, err := run("/branch "+first[:8], second); err != nil || id != first {
+		t.Errorf("/branch = %q, %v; want %q", id, err, first)
+	}
+	if id, err := run("/branch "+other, second); err == nil || id != second {
+		t.Errorf("/branch to another DAG = %q, %v; want an error", id, err)
+	}
+
+	forked, err := run("/fork", second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstNode, _ := client.GetNode(ctx, first)
+	node, _ := client.GetNode(ctx, forked)
+	if forked == second || node == nil || node.Content != "ok" || dagRootID(node) == dagRootID(firstNode) {
+		t.Errorf("/fork continued from %+v, want a copy of the reply in a new DAG", node)
+	}
+
+	if _, err := run("/system Be brief.", second); err != nil {
+		t.Fatal(err)
+	}
+	root, _ := client.GetNode(ctx, dagRootID(firstNode))
+	if !strings.Contains(root.SystemPrompt, "Be brief.") {
+		t.Errorf("root system prompt = %q", root.SystemPrompt)
+	}
+
+	if _, err := run("/model mock-fast", second); err != nil || len(opts) != 1 {
+		t.Errorf("/model: err = %v, %d options, want 1", err, len(opts))
+	}
+	if _, err := run("/model", second); err == nil {
+		t.Error("/model without a name should fail")
+	}
+}
+
+func TestReadStdinMessage(t *testing.T) {
+	for _, tc := range []struct{ message, stdin, want string }{
+		{"Summarize this", "line 1\nline 2\n", "Summarize this\n\nline 1\nline 2"},
+		{"", "just stdin\r\n", "just stdin"},
+		{"Hi", "", "Hi"},
+	} {
+		got, err := readStdinMessage(strings.NewReader(tc.stdin), tc.message)
+		if err != nil || got != tc.want {
+			t.Errorf("readStdinMessage(%q, %q) = %q, %v; want %q", tc.stdin, tc.message, got, err, tc.want)
+		}
+	}
+}
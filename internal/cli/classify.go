@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"langdag.com/langdag/types"
+)
+
+var classifyAll bool
+
+var classifyCmd = &cobra.Command{
+	Use:   "classify [dag-id...]",
+	Short: "Tag conversations by topic",
+	Long: `Tag conversations by topic with the classifier configured in the
+'classifier' section of the config: keyword rules, and optionally a model
+suggesting up to 3 more tags. Previous tags are replaced.
+
+With classifier.enabled, new conversations are tagged in the background
+after their first reply; use this command for existing ones. Browse by tag
+with 'langdag ls --tag <tag>'.
+
+Examples:
+  langdag classify abc123
+  langdag classify --all`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if classifyAll {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	Run: runClassify,
+}
+
+func init() {
+	classifyCmd.Flags().BoolVar(&classifyAll, "all", false, "tag every conversation")
+	rootCmd.AddCommand(classifyCmd)
+}
+
+func runClassify(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		exitError("%v", err)
+	}
+	defer client.Close()
+
+	ids := args
+	if classifyAll {
+		roots, err := client.ListConversations(ctx)
+		if err != nil {
+			exitError("failed to list conversations: %v", err)
+		}
+		for _, root := range roots {
+			if root.ArchivedURI == "" {
+				ids = append(ids, root.ID)
+			}
+		}
+	}
+
+	var tagged []*types.Node
+	for _, id := range ids {
+		root, err := client.Classify(ctx, id)
+		if err != nil {
+			exitError("classify %s failed: %v", id, err)
+		}
+		tagged = append(tagged, root)
+		if !outputJSON && !outputYAML {
+			tags := strings.Join(root.Tags, ", ")
+			if tags == "" {
+				tags = "(no tags)"
+			}
+			fmt.Printf("%s  %s\n", shortID(root.ID), tags)
+		}
+	}
+	printFormatted(tagged)
+}
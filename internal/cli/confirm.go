@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// nonInteractive makes commands fail instead of prompting (--non-interactive).
+var nonInteractive bool
+
+// errNeedsConfirmation is returned by confirm in non-interactive mode.
+var errNeedsConfirmation = errors.New("confirmation required: pass --yes to proceed without prompting")
+
+// confirm asks question on stderr and reports whether the answer read from
+// stdin is yes. It answers yes without asking when yes is set, and fails in
+// non-interactive mode.
+func confirm(question string, yes bool) (bool, error) {
+	return confirmFrom(os.Stdin, os.Stderr, question, yes, nonInteractive)
+}
+
+// confirmFrom is confirm reading the answer from in and asking on out.
+func confirmFrom(in io.Reader, out io.Writer, question string, yes, nonInteractive bool) (bool, error) {
+	if yes {
+		return true, nil
+	}
+	if nonInteractive {
+		return false, errNeedsConfirmation
+	}
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(out)
+		return false, nil // no answer (e.g. stdin closed) is no
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
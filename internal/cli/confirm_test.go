@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConfirmFrom(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{" Yes \n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+	} {
+		var out bytes.Buffer
+		got, err := confirmFrom(strings.NewReader(tt.input), &out, "Delete?", false, false)
+		if err != nil || got != tt.want {
+			t.Errorf("answer %q: got %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+		if !strings.HasPrefix(out.String(), "Delete? [y/N] ") {
+			t.Errorf("prompt = %q", out.String())
+		}
+	}
+
+	if ok, err := confirmFrom(strings.NewReader(""), &bytes.Buffer{}, "Delete?", true, true); !ok || err != nil {
+		t.Errorf("--yes: got %v, %v", ok, err)
+	}
+	var out bytes.Buffer
+	if _, err := confirmFrom(strings.NewReader("y\n"), &out, "Delete?", false, true); !errors.Is(err, errNeedsConfirmation) {
+		t.Errorf("non-interactive: err = %v, want errNeedsConfirmation", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("non-interactive mode prompted: %q", out.String())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	"langdag.com/langdag/types"
@@ -16,33 +17,104 @@ var lsCmd = &cobra.Command{
 	Use:     "ls",
 	Aliases: []string{"list"},
 	Short:   "List all conversations",
-	Long:    `List all root nodes (conversations).`,
-	Run:     runNodeList,
+	Long: `List all root nodes (conversations).
+
+With --tag, list only conversations with that topic tag (see 'langdag
+classify'). With --summary, add the start of each conversation's latest
+summary (see 'langdag summarize').`,
+	Run: runNodeList,
 }
 
 // showCmd shows a node tree.
 var showCmd = &cobra.Command{
 	Use:   "show <id>",
 	Short: "Show node tree",
-	Long:  `Show the details and node tree from a given node.`,
-	Args:  cobra.ExactArgs(1),
-	Run:   runNodeShow,
+	Long: `Show the details and node tree from a given node.
+
+With --format dot or --format mermaid, print the tree as a Graphviz or
+Mermaid graph instead, with every branch as a fork:
+
+  langdag show abc123 --format dot | dot -Tsvg > tree.svg
+  langdag show abc123 --format mermaid`,
+	Args: cobra.ExactArgs(1),
+	Run:  runNodeShow,
 }
 
+var showFormat string
+
+var (
+	lsSummary bool
+	lsTag     string
+	rmYes     bool
+)
+
 // rmCmd deletes a node and its subtree.
 var rmCmd = &cobra.Command{
 	Use:     "rm <id>",
 	Aliases: []string{"delete"},
 	Short:   "Delete a node and its subtree",
-	Long:    `Delete a node and all its descendant nodes.`,
-	Args:    cobra.ExactArgs(1),
-	Run:     runNodeDelete,
+	Long: `Delete a node and all its descendant nodes, after confirmation.
+
+Examples:
+  langdag rm 3f2a9c1e
+  langdag rm 3f2a9c1e --yes    # no confirmation, e.g. in scripts`,
+	Args: cobra.ExactArgs(1),
+	Run:  runNodeDelete,
+}
+
+// cloneCmd copies a conversation path into a new DAG.
+var cloneCmd = &cobra.Command{
+	Use:   "clone <id>",
+	Short: "Copy a conversation into a new DAG",
+	Long: `Copy the path from the root down to a node into a new conversation.
+Sibling branches are not copied. Continue the new conversation with
+'langdag prompt <new-node-id> <message>'.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runNodeClone,
+}
+
+// renameCmd sets the title of a conversation.
+var renameCmd = &cobra.Command{
+	Use:   "rename <id> <title>",
+	Short: "Rename a conversation",
+	Long: `Set the title of the conversation containing a node. A title set this
+way is never replaced by a generated one.`,
+	Args: cobra.MinimumNArgs(2),
+	Run:  runNodeRename,
+}
+
+var (
+	searchLimit    int
+	searchSemantic bool
+)
+
+// searchCmd searches node content across all conversations.
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search all conversations",
+	Long: `Search the content of every node in every conversation. A node matches
+when it contains all the words of the query (case-insensitive).
+
+With --semantic, find the nodes closest in meaning to the query instead,
+using the embeddings configured in the 'embeddings' section of the config.
+Run 'langdag embed' to embed nodes created before embeddings were enabled.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runNodeSearch,
+}
+
+func init() {
+	lsCmd.Flags().BoolVar(&lsSummary, "summary", false, "show each conversation's latest summary")
+	lsCmd.Flags().StringVar(&lsTag, "tag", "", "list only conversations with this tag")
+	rmCmd.Flags().BoolVarP(&rmYes, "yes", "y", false, "delete without asking for confirmation")
+	showCmd.Flags().StringVar(&showFormat, "format", "", "graph output format: dot or mermaid")
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 20, "maximum number of matches")
+	searchCmd.Flags().BoolVar(&searchSemantic, "semantic", false, "search by meaning using embeddings")
 }
 
 func runNodeList(cmd *cobra.Command, args []string) {
 	ctx := context.Background()
 
-	client, err := newLibraryClient(ctx)
+	client, err := newDAGReader(ctx)
 	if err != nil {
 		exitError("%v", err)
 	}
@@ -52,6 +124,19 @@ func runNodeList(cmd *cobra.Command, args []string) {
 	if err != nil {
 		exitError("failed to list nodes: %v", err)
 	}
+	hasTags := false
+	if lsTag != "" {
+		var filtered []*types.Node
+		for _, root := range roots {
+			if slices.Contains(root.Tags, strings.ToLower(lsTag)) {
+				filtered = append(filtered, root)
+			}
+		}
+		roots = filtered
+	}
+	for _, root := range roots {
+		hasTags = hasTags || len(root.Tags) > 0
+	}
 
 	if len(roots) == 0 {
 		if outputJSON || outputYAML {
@@ -67,7 +152,14 @@ func runNodeList(cmd *cobra.Command, args []string) {
 	}
 
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"ID", "Title", "Model", "Status", "Created"})
+	header := []string{"ID", "Title", "Model", "Status", "Created"}
+	if hasTags {
+		header = append(header, "Tags")
+	}
+	if lsSummary {
+		header = append(header, "Summary")
+	}
+	table.SetHeader(header)
 	table.SetBorder(false)
 	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
@@ -92,22 +184,84 @@ func runNodeList(cmd *cobra.Command, args []string) {
 			model = model[:27] + "..."
 		}
 
-		table.Append([]string{
-			node.ID[:8],
+		row := []string{
+			shortID(node.ID),
 			title,
 			model,
 			node.Status,
 			node.CreatedAt.Format("2006-01-02 15:04"),
-		})
+		}
+		if hasTags {
+			row = append(row, strings.Join(node.Tags, ", "))
+		}
+		if lsSummary {
+			preview := ""
+			if summary, err := client.LatestSummary(ctx, node.ID); err == nil && summary != nil {
+				preview = strings.Join(strings.Fields(summary.Content), " ")
+				if len(preview) > 60 {
+					preview = preview[:57] + "..."
+				}
+			}
+			row = append(row, preview)
+		}
+		table.Append(row)
 	}
 	table.Render()
 }
 
+func runNodeSearch(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	query := strings.Join(args, " ")
+
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		exitError("%v", err)
+	}
+	defer client.Close()
+
+	search := client.Search
+	if searchSemantic {
+		search = client.SemanticSearch
+	}
+	matches, err := search(ctx, query, searchLimit)
+	if err != nil {
+		exitError("search failed: %v", err)
+	}
+
+	if len(matches) == 0 {
+		if outputJSON || outputYAML {
+			fmt.Println("[]")
+		} else {
+			fmt.Printf("No matches for %q.\n", query)
+		}
+		return
+	}
+
+	if printFormatted(matches) {
+		return
+	}
+
+	for _, m := range matches {
+		branch := make([]string, len(m.Path))
+		for i, id := range m.Path {
+			branch[i] = shortID(id)
+		}
+		fmt.Printf("[%s] %s  %s\n", m.Node.NodeType, shortID(m.Node.ID), m.Snippet)
+		fmt.Printf("    branch: %s\n", strings.Join(branch, " > "))
+	}
+}
+
 func runNodeShow(cmd *cobra.Command, args []string) {
 	ctx := context.Background()
 	nodeID := args[0]
 
-	client, err := newLibraryClient(ctx)
+	switch showFormat {
+	case "", "dot", "mermaid":
+	default:
+		exitError("invalid --format %q: want dot or mermaid", showFormat)
+	}
+
+	client, err := newDAGReader(ctx)
 	if err != nil {
 		exitError("%v", err)
 	}
@@ -127,6 +281,15 @@ func runNodeShow(cmd *cobra.Command, args []string) {
 		exitError("failed to get tree: %v", err)
 	}
 
+	switch showFormat {
+	case "dot":
+		writeDOT(os.Stdout, nodes)
+		return
+	case "mermaid":
+		writeMermaid(os.Stdout, nodes)
+		return
+	}
+
 	if outputJSON || outputYAML {
 		printFormatted(nodes)
 		return
@@ -149,7 +312,7 @@ func runNodeShow(cmd *cobra.Command, args []string) {
 			if err == nil && len(ancestors) > 1 {
 				// ancestors is root-first and includes the node itself
 				root := ancestors[0]
-				fmt.Printf("├─ %s (root)\n", root.ID[:8])
+				fmt.Printf("├─ %s (root)\n", shortID(root.ID))
 				// Skipped nodes = ancestors minus root and the target node
 				skipped := len(ancestors) - 2
 				if skipped > 0 {
@@ -161,14 +324,19 @@ func runNodeShow(cmd *cobra.Command, args []string) {
 				}
 			}
 		}
-		printNodeTree(nodes, node.ID, node.ID)
+		comments, err := client.Comments(ctx, node.ID)
+		if err != nil {
+			exitError("failed to get comments: %v", err)
+		}
+		printNodeTree(nodes, comments, node.ID, node.ID)
 	}
 }
 
-// printNodeTree prints nodes as a tree structure.
+// printNodeTree prints nodes as a tree structure, with the comments on
+// each node below it.
 // rootID is the ID of the node to treat as the tree root.
 // highlightID is the node whose ID should be displayed in bold.
-func printNodeTree(nodes []*types.Node, rootID, highlightID string) {
+func printNodeTree(nodes []*types.Node, comments []*types.Comment, rootID, highlightID string) {
 	if len(nodes) == 0 {
 		return
 	}
@@ -176,6 +344,11 @@ func printNodeTree(nodes []*types.Node, rootID, highlightID string) {
 	childrenMap := make(map[string][]*types.Node)
 	var roots []*types.Node
 
+	commentMap := make(map[string][]*types.Comment)
+	for _, c := range comments {
+		commentMap[c.NodeID] = append(commentMap[c.NodeID], c)
+	}
+
 	for _, node := range nodes {
 		if node.ID == rootID {
 			roots = append(roots, node)
@@ -208,6 +381,17 @@ func printNodeTree(nodes []*types.Node, rootID, highlightID string) {
 		fmt.Printf("%s%s ", prefix, connector)
 		printNodeCompact(node, node.ID == highlightID)
 
+		commentPrefix := prefix
+		if hasMoreSiblings {
+			commentPrefix += "│"
+		}
+		if isLeaf || isBranchPoint {
+			commentPrefix += "   "
+		} else {
+			commentPrefix += "│  "
+		}
+		printComments(commentMap[node.ID], commentPrefix)
+
 		if isLeaf {
 			return
 		}
@@ -246,15 +430,71 @@ func runNodeDelete(cmd *cobra.Command, args []string) {
 		exitError("node not found: %s", nodeID)
 	}
 
+	title := node.Title
+	if title == "" {
+		title = truncate(node.Content, 30)
+	}
+	subtree, err := client.GetSubtree(ctx, node.ID)
+	if err != nil {
+		exitError("failed to get tree: %v", err)
+	}
+	question := fmt.Sprintf("Delete node %s (%s)?", shortID(node.ID), title)
+	if descendants := len(subtree) - 1; descendants > 0 {
+		question = fmt.Sprintf("Delete node %s (%s) and its %d descendant node(s)?", shortID(node.ID), title, descendants)
+	}
+	ok, err := confirm(question, rmYes)
+	if err != nil {
+		exitError("%v", err)
+	}
+	if !ok {
+		fmt.Println("Aborted")
+		return
+	}
+
 	if err := client.DeleteNode(ctx, node.ID); err != nil {
 		exitError("failed to delete node: %v", err)
 	}
 
-	title := node.Title
-	if title == "" {
-		title = truncate(node.Content, 30)
+	fmt.Printf("Deleted node: %s (%s)\n", shortID(node.ID), title)
+}
+
+func runNodeClone(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		exitError("%v", err)
+	}
+	defer client.Close()
+
+	node, err := client.Clone(ctx, args[0])
+	if err != nil {
+		exitError("failed to clone: %v", err)
+	}
+	if printFormatted(node) {
+		return
+	}
+	fmt.Printf("Cloned into new conversation %s\n", node.RootID)
+	fmt.Printf("Continue from node %s\n", node.ID)
+}
+
+func runNodeRename(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		exitError("%v", err)
+	}
+	defer client.Close()
+
+	root, err := client.SetTitle(ctx, args[0], strings.Join(args[1:], " "))
+	if err != nil {
+		exitError("failed to rename: %v", err)
 	}
-	fmt.Printf("Deleted node: %s (%s)\n", node.ID[:8], title)
+	if printFormatted(root) {
+		return
+	}
+	fmt.Printf("Renamed %s to %q\n", shortID(root.ID), root.Title)
 }
 
 func printNodeCompact(node *types.Node, bold bool) {
@@ -270,6 +510,9 @@ func printNodeCompact(node *types.Node, bold bool) {
 	if node.Status != "" {
 		info = append(info, node.Status)
 	}
+	if node.NodeType == types.NodeTypeAssistant && node.Model != "" {
+		info = append(info, node.Model)
+	}
 	if node.TokensIn > 0 || node.TokensOut > 0 {
 		info = append(info, fmt.Sprintf("tokens: %d/%d", node.TokensIn, node.TokensOut))
 	}
@@ -282,7 +525,7 @@ func printNodeCompact(node *types.Node, bold bool) {
 		infoStr = " (" + strings.Join(info, ", ") + ")"
 	}
 
-	id := node.ID[:8]
+	id := shortID(node.ID)
 	if bold {
 		id = "\033[1m" + id + "\033[0m"
 	}
@@ -290,6 +533,21 @@ func printNodeCompact(node *types.Node, bold bool) {
 	fmt.Printf("%s [%s]: %s%s\n", id, role, content, infoStr)
 }
 
+// printComments prints the comments on a node, replies indented below the
+// comment they answer.
+func printComments(comments []*types.Comment, prefix string) {
+	var printReplies func(replyTo, indent string)
+	printReplies = func(replyTo, indent string) {
+		for _, c := range comments {
+			if c.ReplyTo == replyTo {
+				fmt.Printf("%s%s💬 %s: %s\n", prefix, indent, c.Author, truncate(c.Body, 60))
+				printReplies(c.ID, indent+"  ")
+			}
+		}
+	}
+	printReplies("", "")
+}
+
 func truncate(s string, max int) string {
 	s = strings.ReplaceAll(s, "\n", " ")
 	if len(s) > max {
@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"langdag.com/langdag/types"
+)
+
+var diffContext int
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <node-a> <node-b>",
+	Short: "Compare two branches",
+	Long: `Show a unified diff of the branches ending at two nodes: the system
+prompt, then each message from the root down, headed by its type and, for
+replies, the model and sampling parameters. The nodes may be in the same
+DAG (e.g. two replies from different models) or in different DAGs (e.g. a
+DAG and its fork).
+
+Examples:
+  langdag diff 7a8b 4e5f
+  langdag diff -U 0 7a8b 4e5f    # changed lines only`,
+	Args: cobra.ExactArgs(2),
+	Run:  runDiff,
+}
+
+func init() {
+	diffCmd.Flags().IntVarP(&diffContext, "unified", "U", 3, "lines of context around each change")
+	rootCmd.AddCommand(diffCmd)
+}
+
+func runDiff(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	client, err := newDAGReader(ctx)
+	if err != nil {
+		exitError("%v", err)
+	}
+	defer client.Close()
+
+	var branches [2][]string
+	var ids [2]string
+	for i, arg := range args {
+		node, err := client.GetNode(ctx, arg)
+		if err != nil {
+			exitError("failed to get node: %v", err)
+		}
+		if node == nil {
+			exitError("node not found: %s", arg)
+		}
+		ancestors, err := client.GetAncestors(ctx, node.ID)
+		if err != nil {
+			exitError("failed to get branch: %v", err)
+		}
+		branches[i], ids[i] = branchLines(ancestors), node.ID
+	}
+
+	diff := unifiedDiff(branches[0], branches[1], "branch "+shortID(ids[0]), "branch "+shortID(ids[1]), max(diffContext, 0))
+	if diff == "" {
+		fmt.Println("The branches are identical.")
+		return
+	}
+	fmt.Print(diff)
+}
+
+// branchLines renders a branch, root first, as lines to diff. Node IDs are
+// left out, so that copies of a message in two DAGs compare equal.
+func branchLines(nodes []*types.Node) []string {
+	var lines []string
+	if len(nodes) > 0 && nodes[0].SystemPrompt != "" {
+		lines = append(lines, "[system]")
+		lines = append(lines, strings.Split(nodes[0].SystemPrompt, "\n")...)
+	}
+	for _, n := range nodes {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, nodeHeader(n))
+		lines = append(lines, strings.Split(n.Content, "\n")...)
+	}
+	return lines
+}
+
+// nodeHeader returns the line heading a node in branchLines: its type and,
+// on replies, its model and sampling parameters.
+func nodeHeader(n *types.Node) string {
+	fields := []string{string(n.NodeType)}
+	if n.Model != "" {
+		fields = append(fields, "model="+n.Model)
+	}
+	if meta, ok, _ := types.AssistantMetadataFromNode(n); ok && meta.Sampling != nil {
+		if t := meta.Sampling.Temperature; t != nil {
+			fields = append(fields, "temperature="+strconv.FormatFloat(*t, 'g', -1, 64))
+		}
+		if meta.Sampling.MaxTokens > 0 {
+			fields = append(fields, "max_tokens="+strconv.Itoa(meta.Sampling.MaxTokens))
+		}
+		if len(meta.Sampling.StopSequences) > 0 {
+			fields = append(fields, "stop="+strconv.Quote(strings.Join(meta.Sampling.StopSequences, ",")))
+		}
+	}
+	if n.Status != "" && n.Status != "completed" {
+		fields = append(fields, "status="+n.Status)
+	}
+	return "[" + strings.Join(fields, " ") + "]"
+}
+
+// diffOp is a line of an edit script: ' ' kept, '-' removed, '+' added.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns the unified diff turning a into b, with context lines
+// around each change, or "" if they are equal.
+func unifiedDiff(a, b []string, nameA, nameB string, context int) string {
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+	changed := false
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		changed = true
+		// A hunk runs from context lines before the change to context
+		// lines after the last change closer than 2*context+1 lines.
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		end = min(end+context, len(ops))
+
+		lineA, lineB := 1, 1
+		for _, op := range ops[:start] {
+			if op.kind != '+' {
+				lineA++
+			}
+			if op.kind != '-' {
+				lineB++
+			}
+		}
+		countA, countB := 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(lineA, countA), hunkRange(lineB, countB))
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			out.WriteByte('\n')
+		}
+		i = end
+	}
+	if !changed {
+		return ""
+	}
+	return out.String()
+}
+
+// hunkRange formats the range of a hunk header. An empty range starts at
+// the line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// diffLines returns a shortest edit script turning a into b, from their
+// longest common subsequence.
+func diffLines(a, b []string) []diffOp {
+	// Common prefix and suffix are kept as is, which keeps the table small
+	// for branches that share most of their history.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	midA, midB := a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	// lcs[i][j] is the length of the LCS of midA[i:] and midB[j:].
+	lcs := make([][]int, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:pre] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	i, j := 0, 0
+	for i < len(midA) || j < len(midB) {
+		switch {
+		case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+			ops = append(ops, diffOp{' ', midA[i]})
+			i++
+			j++
+		case j == len(midB) || (i < len(midA) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', midA[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', midB[j]})
+			j++
+		}
+	}
+	for _, line := range a[len(a)-suf:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
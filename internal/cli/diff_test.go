@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"langdag.com/langdag/types"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+	b := []string{"1", "two", "3", "4", "5", "6", "7", "8", "9", "10", "11"}
+	want := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+@@ -8,3 +8,4 @@
+ 8
+ 9
+ 10
++11
+`
+	if got := unifiedDiff(a, b, "a", "b", 3); got != want {
+		t.Errorf("diff:\n%s\nwant:\n%s", got, want)
+	}
+	if got := unifiedDiff(a, a, "a", "b", 3); got != "" {
+		t.Errorf("diff of equal lines = %q, want empty", got)
+	}
+}
+
+func TestBranchLines(t *testing.T) {
+	temp := 0.2
+	meta, _ := json.Marshal(types.AssistantNodeMetadata{Sampling: &types.SamplingParams{Temperature: &temp}})
+	got := branchLines([]*types.Node{
+		{ID: "r", NodeType: types.NodeTypeUser, Content: "Hi", SystemPrompt: "Be brief."},
+		{ID: "a", ParentID: "r", NodeType: types.NodeTypeAssistant, Content: "Hello\nthere", Model: "gpt-4o", Metadata: meta, Status: "timeout"},
+	})
+	want := []string{"[system]", "Be brief.", "", "[user]", "Hi", "", "[assistant model=gpt-4o temperature=0.2 status=timeout]", "Hello", "there"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lines:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var embedCmd = &cobra.Command{
+	Use:   "embed",
+	Short: "Embed nodes for semantic search",
+	Long: `Embed the content of every node that has no embedding for the model
+configured in the 'embeddings' section of the config yet, oldest first.
+
+With embeddings.enabled, new prompts and replies are embedded in the
+background; use this command for nodes created before, or after changing
+embeddings.model. Search with 'langdag search --semantic <query>'.`,
+	Args: cobra.NoArgs,
+	RunE: runEmbed,
+}
+
+func init() {
+	rootCmd.AddCommand(embedCmd)
+}
+
+func runEmbed(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	n, err := client.EmbedMissing(ctx)
+	if err != nil {
+		return fmt.Errorf("embedded %d node(s) before failing: %w", n, err)
+	}
+	fmt.Printf("Embedded %d node(s)\n", n)
+	return nil
+}
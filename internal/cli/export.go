@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"langdag.com/langdag"
+)
+
+var (
+	exportFormat string
+	exportOutput string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <id>",
+	Short: "Export a DAG as JSON, YAML or Markdown",
+	Long: `Export the whole DAG containing a node (ID, prefix or alias).
+
+JSON and YAML exports keep node IDs, metadata and aliases and can be
+restored with 'langdag import <file>', on this or another machine.
+Markdown is a readable transcript for sharing.
+
+Examples:
+  langdag export abc123 -o conversation.json
+  langdag export abc123 --format markdown > conversation.md`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExport,
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", langdag.ExportJSON, "json, yaml or markdown")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "output file (default stdout)")
+	rootCmd.AddCommand(exportCmd)
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	switch exportFormat {
+	case langdag.ExportJSON, langdag.ExportYAML, langdag.ExportMarkdown:
+	default:
+		return fmt.Errorf("invalid --format %q: want json, yaml or markdown", exportFormat)
+	}
+
+	ctx := context.Background()
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	dag, err := client.Export(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if exportOutput != "" {
+		f, err := os.OpenFile(exportOutput, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return langdag.EncodeExport(w, dag, exportFormat)
+}
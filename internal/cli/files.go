@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var filesPruneDryRun bool
+
+var filesCmd = &cobra.Command{
+	Use:   "files",
+	Short: "Manage the files storing large node content",
+	Long: `Node content larger than storage.file_threshold (default 256 KiB) is
+stored in files in the directory next to the database (langdag.db-files
+for langdag.db), named by the SHA-256 of the content, instead of the
+database. Reading nodes is unaffected.
+
+Files are shared by nodes with the same content and are not removed with
+their nodes: run 'langdag files prune' after deleting or archiving DAGs.`,
+}
+
+var filesPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove files no node references",
+	Long: `Remove the content files that no node references any more, left
+behind by deleted and archived nodes. Files written in the last hour are
+kept, as the node referencing them may not be saved yet.
+
+Examples:
+  langdag files prune --dry-run
+  langdag files prune`,
+	Args: cobra.NoArgs,
+	RunE: runFilesPrune,
+}
+
+func init() {
+	filesPruneCmd.Flags().BoolVar(&filesPruneDryRun, "dry-run", false, "report what would be removed without removing it")
+	filesCmd.AddCommand(filesPruneCmd)
+	rootCmd.AddCommand(filesCmd)
+}
+
+func runFilesPrune(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	result, err := client.PruneFiles(ctx, filesPruneDryRun)
+	if err != nil {
+		return err
+	}
+	if printFormatted(result) {
+		return nil
+	}
+	verb := "Removed"
+	if filesPruneDryRun {
+		verb = "Would remove"
+	}
+	fmt.Printf("%s %d file(s), %s\n", verb, result.Files, formatSize(result.Bytes))
+	return nil
+}
+
+// formatSize formats a number of bytes with a binary unit.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"langdag.com/langdag/types"
+)
+
+// graphLabelLen is the maximum length of the content preview in graph labels.
+const graphLabelLen = 40
+
+// graphLabel returns the label for a node: its short ID, type and a content
+// preview.
+func graphLabel(node *types.Node) string {
+	label := fmt.Sprintf("%s [%s]", shortID(node.ID), node.NodeType)
+	if content := strings.TrimSpace(node.Content); content != "" {
+		label += "\n" + truncate(content, graphLabelLen)
+	}
+	return label
+}
+
+// writeDOT writes nodes as a Graphviz digraph with an edge from each node
+// to its children. Nodes whose parent is not in the list are drawn as roots.
+func writeDOT(w io.Writer, nodes []*types.Node) {
+	ids := graphIDs(nodes)
+	fmt.Fprintln(w, "digraph langdag {")
+	fmt.Fprintln(w, "  node [fontname=\"Helvetica\", fontsize=10];")
+	for _, n := range nodes {
+		shape := "box"
+		style := ""
+		switch n.NodeType {
+		case types.NodeTypeAssistant:
+			style = ", style=rounded"
+		case types.NodeTypeSystem, types.NodeTypeToolCall, types.NodeTypeToolResult, types.NodeTypeSummary:
+			shape = "note"
+		}
+		fmt.Fprintf(w, "  %s [label=%s, shape=%s%s];\n", ids[n.ID], dotQuote(graphLabel(n)), shape, style)
+	}
+	for _, n := range nodes {
+		if parent, ok := ids[n.ParentID]; ok {
+			fmt.Fprintf(w, "  %s -> %s;\n", parent, ids[n.ID])
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// writeMermaid writes nodes as a Mermaid flowchart.
+func writeMermaid(w io.Writer, nodes []*types.Node) {
+	ids := graphIDs(nodes)
+	fmt.Fprintln(w, "flowchart TD")
+	for _, n := range nodes {
+		label := mermaidQuote(graphLabel(n))
+		switch n.NodeType {
+		case types.NodeTypeAssistant:
+			fmt.Fprintf(w, "  %s(%s)\n", ids[n.ID], label)
+		case types.NodeTypeUser:
+			fmt.Fprintf(w, "  %s[%s]\n", ids[n.ID], label)
+		default:
+			fmt.Fprintf(w, "  %s[/%s/]\n", ids[n.ID], label)
+		}
+	}
+	for _, n := range nodes {
+		if parent, ok := ids[n.ParentID]; ok {
+			fmt.Fprintf(w, "  %s --> %s\n", parent, ids[n.ID])
+		}
+	}
+}
+
+// graphIDs assigns short identifiers (n0, n1, ...) that are valid in both
+// DOT and Mermaid, whatever the node IDs look like.
+func graphIDs(nodes []*types.Node) map[string]string {
+	ids := make(map[string]string, len(nodes))
+	for i, n := range nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+	}
+	return ids
+}
+
+// dotQuote returns s as a DOT quoted string.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + strings.ReplaceAll(s, "\n", `\n`) + `"`
+}
+
+// mermaidQuote returns s as a Mermaid quoted label.
+func mermaidQuote(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	return `"` + strings.ReplaceAll(s, "\n", "<br/>") + `"`
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"langdag.com/langdag/types"
+)
+
+func graphTestNodes() []*types.Node {
+	return []*types.Node{
+		{ID: "root-node-1", NodeType: types.NodeTypeUser, Content: `Say "hi"`},
+		{ID: "reply-a", ParentID: "root-node-1", NodeType: types.NodeTypeAssistant, Content: "hi"},
+		{ID: "reply-b", ParentID: "root-node-1", NodeType: types.NodeTypeAssistant, Content: "hello\nthere"},
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	writeDOT(&buf, graphTestNodes())
+	out := buf.String()
+
+	for _, want := range []string{
+		"digraph langdag {",
+		`n0 [label="root-nod [user]\nSay \"hi\"", shape=box];`,
+		`n2 [label="reply-b [assistant]\nhello there", shape=box, style=rounded];`,
+		"n0 -> n1;",
+		"n0 -> n2;",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteMermaid(t *testing.T) {
+	var buf bytes.Buffer
+	writeMermaid(&buf, graphTestNodes())
+	out := buf.String()
+
+	for _, want := range []string{
+		"flowchart TD",
+		`n0["root-nod [user]<br/>Say #quot;hi#quot;"]`,
+		`n1("reply-a [assistant]<br/>hi")`,
+		"n0 --> n1",
+		"n0 --> n2",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Mermaid output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 
+	"langdag.com/langdag"
 	"langdag.com/langdag/internal/migrate/langgraph"
 	"langdag.com/langdag/internal/storage"
 	"langdag.com/langdag/internal/storage/sqlite"
@@ -13,8 +15,17 @@ import (
 )
 
 var importCmd = &cobra.Command{
-	Use:   "import",
-	Short: "Import data from other sources",
+	Use:   "import [file]",
+	Short: "Import a DAG export or data from other sources",
+	Long: `Import a DAG written by 'langdag export' (JSON or YAML; "-" reads
+stdin), keeping its node IDs and aliases. Fails if the DAG is already
+stored.
+
+Examples:
+  langdag import conversation.json
+  langdag import langgraph --file export.json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runImportDAG,
 }
 
 var importLangGraphCmd = &cobra.Command{
@@ -60,6 +71,44 @@ func init() {
 	rootCmd.AddCommand(importCmd)
 }
 
+func runImportDAG(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return cmd.Help()
+	}
+
+	var data []byte
+	var err error
+	if args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return err
+	}
+	dag, err := langdag.DecodeExport(data)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	root, err := client.Import(ctx, dag)
+	if err != nil {
+		return err
+	}
+	if printFormatted(root) {
+		return nil
+	}
+	fmt.Printf("Imported %d nodes as DAG %s\n", len(dag.Nodes), root.ID)
+	return nil
+}
+
 func runImportLangGraph(cmd *cobra.Command, args []string) error {
 	// Exactly one of --file or --sqlite must be provided.
 	if importFile == "" && importSQLite == "" {
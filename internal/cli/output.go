@@ -59,3 +59,12 @@ func printFormatted(v interface{}) bool {
 	}
 	return false
 }
+
+// shortID abbreviates a node ID for display: its first 8 characters, or
+// the whole ID when shorter, as imported DAGs may have any IDs.
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"langdag.com/langdag"
+)
+
+var (
+	pruneOlderThan string
+	pruneStatuses  []string
+	pruneDryRun    bool
+	pruneYes       bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete stale DAGs",
+	Long: `Delete every DAG with no node newer than --older-than, to keep the
+database from growing unbounded. With --status, only DAGs with a node in
+one of the statuses are deleted; "failed" matches cancelled, timeout and
+interrupted replies.
+
+Pruned DAGs are not saved to the trash, and archived DAGs are kept. Run
+'langdag files prune' afterwards to remove the content files they used.
+To prune on a schedule, set server.retention for 'langdag serve'.
+
+Examples:
+  langdag prune --older-than 30d --status failed --dry-run
+  langdag prune --older-than 90d --yes`,
+	RunE: runPrune,
+}
+
+func init() {
+	pruneCmd.Flags().StringVar(&pruneOlderThan, "older-than", "30d", "prune DAGs idle for this long (e.g. 30d, 720h)")
+	pruneCmd.Flags().StringSliceVar(&pruneStatuses, "status", nil, "prune only DAGs with a node in this status (e.g. failed, cancelled); repeatable")
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "list the DAGs that would be deleted without deleting them")
+	pruneCmd.Flags().BoolVarP(&pruneYes, "yes", "y", false, "delete without asking for confirmation")
+	rootCmd.AddCommand(pruneCmd)
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	age, err := parseAge(pruneOlderThan)
+	if err != nil {
+		return fmt.Errorf("invalid --older-than: %w", err)
+	}
+
+	ctx := context.Background()
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	opts := langdag.PruneOptions{Before: time.Now().Add(-age), Statuses: pruneStatuses, DryRun: true}
+	stale, err := client.Prune(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if pruneDryRun {
+		if printFormatted(stale) {
+			return nil
+		}
+		for _, root := range stale {
+			title := root.Title
+			if title == "" {
+				title = truncate(root.Content, 50)
+			}
+			fmt.Printf("%s  %s  %s\n", shortID(root.ID), root.CreatedAt.Format("2006-01-02 15:04"), title)
+		}
+		fmt.Printf("Would delete %d DAG(s)\n", len(stale))
+		return nil
+	}
+	if len(stale) == 0 {
+		fmt.Println("No DAGs to prune.")
+		return nil
+	}
+
+	ok, err := confirm(fmt.Sprintf("Delete %d DAG(s) idle for more than %s?", len(stale), pruneOlderThan), pruneYes)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Aborted")
+		return nil
+	}
+	opts.DryRun = false
+	pruned, err := client.Prune(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if printFormatted(pruned) {
+		return nil
+	}
+	fmt.Printf("Deleted %d DAG(s)\n", len(pruned))
+	return nil
+}
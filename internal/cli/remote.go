@@ -0,0 +1,443 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"langdag.com/langdag"
+	"langdag.com/langdag/internal/api"
+	"langdag.com/langdag/internal/config"
+	"langdag.com/langdag/types"
+)
+
+// remoteURL is set by --remote.
+var remoteURL string
+
+// dagReader reads conversations from the local database (*langdag.Client)
+// or, in remote mode, from a server.
+type dagReader interface {
+	ListConversations(ctx context.Context) ([]*types.Node, error)
+	GetNode(ctx context.Context, id string) (*types.Node, error)
+	GetSubtree(ctx context.Context, id string) ([]*types.Node, error)
+	GetAncestors(ctx context.Context, id string) ([]*types.Node, error)
+	Comments(ctx context.Context, id string) ([]*types.Comment, error)
+	LatestSummary(ctx context.Context, id string) (*types.Node, error)
+	Close() error
+}
+
+// newDAGReader returns the remote client when remote mode is on, and the
+// library client otherwise.
+func newDAGReader(ctx context.Context) (dagReader, error) {
+	remote, err := newRemoteClient()
+	if err != nil || remote != nil {
+		return remote, err
+	}
+	return newLibraryClient(ctx)
+}
+
+// remoteClient talks to a running 'langdag serve' over its REST API.
+type remoteClient struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+	stream  *http.Client // without a timeout, for replies
+}
+
+// newRemoteClient returns a client for the server set by --remote or the
+// remote.url config, or nil when neither is set.
+func newRemoteClient() (*remoteClient, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	serverURL := remoteURL
+	if serverURL == "" {
+		serverURL = cfg.Remote.URL
+	}
+	if serverURL == "" {
+		return nil, nil
+	}
+	if u, err := url.Parse(serverURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid remote URL %q: want http(s)://host[:port]", serverURL)
+	}
+	return &remoteClient{
+		baseURL: strings.TrimRight(serverURL, "/"),
+		apiKey:  cfg.Remote.APIKey,
+		http:    &http.Client{Timeout: 30 * time.Second},
+		stream:  &http.Client{},
+	}, nil
+}
+
+func (c *remoteClient) Close() error { return nil }
+
+// send makes a request and returns the response if it succeeded.
+func (c *remoteClient) send(ctx context.Context, client *http.Client, method, path string, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		var e struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		if e.Error == "" {
+			e.Error = resp.Status
+		}
+		return nil, &remoteError{status: resp.StatusCode, message: e.Error}
+	}
+	return resp, nil
+}
+
+// remoteError is an error answered by the server.
+type remoteError struct {
+	status  int
+	message string
+}
+
+func (e *remoteError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.status, e.message)
+}
+
+// get decodes the JSON answer to a GET of path into out.
+func (c *remoteClient) get(ctx context.Context, path string, out any) error {
+	resp, err := c.send(ctx, c.http, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// ListConversations returns the root nodes visible to the API key.
+func (c *remoteClient) ListConversations(ctx context.Context) ([]*types.Node, error) {
+	var roots []*types.Node
+	err := c.get(ctx, "/nodes", &roots)
+	return roots, err
+}
+
+// GetNode returns a node by ID, ID prefix or alias, or nil if there is
+// none.
+func (c *remoteClient) GetNode(ctx context.Context, id string) (*types.Node, error) {
+	var node types.Node
+	err := c.get(ctx, "/nodes/"+url.PathEscape(id), &node)
+	var re *remoteError
+	if errors.As(err, &re) && re.status == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// tree returns the node id and every node of its DAG.
+func (c *remoteClient) tree(ctx context.Context, id string) (*types.Node, []*types.Node, error) {
+	node, err := c.GetNode(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if node == nil {
+		return nil, nil, fmt.Errorf("node not found: %s", id)
+	}
+	var nodes []*types.Node
+	if err := c.get(ctx, "/nodes/"+url.PathEscape(node.ID)+"/tree", &nodes); err != nil {
+		return nil, nil, err
+	}
+	return node, nodes, nil
+}
+
+// GetSubtree returns a node and all its descendants.
+func (c *remoteClient) GetSubtree(ctx context.Context, id string) ([]*types.Node, error) {
+	node, nodes, err := c.tree(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// The tree is ordered parents first.
+	in := map[string]bool{node.ID: true}
+	var subtree []*types.Node
+	for _, n := range nodes {
+		if in[n.ID] || in[n.ParentID] {
+			in[n.ID] = true
+			subtree = append(subtree, n)
+		}
+	}
+	return subtree, nil
+}
+
+// GetAncestors returns the nodes from the root down to id, included.
+func (c *remoteClient) GetAncestors(ctx context.Context, id string) ([]*types.Node, error) {
+	node, nodes, err := c.tree(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*types.Node, len(nodes))
+	for _, n := range nodes {
+		byID[n.ID] = n
+	}
+	var path []*types.Node
+	for n := byID[node.ID]; n != nil; n = byID[n.ParentID] {
+		path = append(path, n)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+// Comments returns the comments on the DAG containing id.
+func (c *remoteClient) Comments(ctx context.Context, id string) ([]*types.Comment, error) {
+	var comments []*types.Comment
+	err := c.get(ctx, "/nodes/"+url.PathEscape(id)+"/comments", &comments)
+	return comments, err
+}
+
+// LatestSummary returns the latest summary of the conversation at id, or
+// nil if it has none.
+func (c *remoteClient) LatestSummary(ctx context.Context, id string) (*types.Node, error) {
+	node, nodes, err := c.tree(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var latest *types.Node
+	for _, n := range nodes {
+		if n.ParentID == node.ID && n.NodeType == types.NodeTypeSummary && (latest == nil || n.CreatedAt.After(latest.CreatedAt)) {
+			latest = n
+		}
+	}
+	return latest, nil
+}
+
+// activeGenerations returns the generations running in the DAG rootID.
+func (c *remoteClient) activeGenerations(ctx context.Context, rootID string) ([]api.ActiveGeneration, error) {
+	var activity api.ActivityResponse
+	if err := c.get(ctx, "/activity", &activity); err != nil {
+		return nil, err
+	}
+	var active []api.ActiveGeneration
+	for _, g := range activity.Active {
+		if g.RootID == rootID {
+			active = append(active, g)
+		}
+	}
+	return active, nil
+}
+
+// prompt sends req, continuing from parentID when set, and streams the
+// reply like the library's Prompt.
+func (c *remoteClient) prompt(ctx context.Context, parentID string, req api.PromptRequest) (*langdag.PromptResult, error) {
+	path := "/prompt"
+	if parentID != "" {
+		path = "/nodes/" + url.PathEscape(parentID) + "/prompt"
+	}
+	req.Stream = true
+	resp, err := c.send(ctx, c.stream, http.MethodPost, path, req)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan langdag.StreamChunk)
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+		readRemoteStream(ctx, resp.Body, ch)
+	}()
+	return &langdag.PromptResult{Stream: ch}, nil
+}
+
+// readRemoteStream turns the SSE events of a reply into stream chunks.
+func readRemoteStream(ctx context.Context, r io.Reader, ch chan<- langdag.StreamChunk) {
+	emit := func(chunk langdag.StreamChunk) bool {
+		select {
+		case ch <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			if v, ok := strings.CutPrefix(line, "event:"); ok {
+				event = strings.TrimSpace(v)
+			} else if v, ok := strings.CutPrefix(line, "data:"); ok {
+				data = append(data, strings.TrimPrefix(v, " "))
+			}
+			continue
+		}
+
+		payload := strings.Join(data, "\n")
+		name := event
+		event, data = "", data[:0]
+		var chunk langdag.StreamChunk
+		switch name {
+		case "delta":
+			var d struct {
+				Content string `json:"content"`
+			}
+			json.Unmarshal([]byte(payload), &d)
+			chunk.Content = d.Content
+		case "done":
+			var d api.PromptResponse
+			json.Unmarshal([]byte(payload), &d)
+			chunk = langdag.StreamChunk{Done: true, NodeID: d.NodeID}
+		case "timeout":
+			var d struct {
+				NodeID string `json:"node_id"`
+			}
+			json.Unmarshal([]byte(payload), &d)
+			chunk = langdag.StreamChunk{Done: true, NodeID: d.NodeID, TimedOut: true}
+		case "error":
+			chunk.Error = errors.New(payload)
+		default:
+			continue
+		}
+		if !emit(chunk) || chunk.Error != nil {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		emit(langdag.StreamChunk{Error: err})
+	}
+}
+
+// runRemotePrompt is runPrompt in remote mode. Interactive mode sends each
+// line as a message; the chat commands of local mode are not available.
+func runRemotePrompt(ctx context.Context, cmd *cobra.Command, remote *remoteClient, args []string) {
+	var nodeID, message string
+	if len(args) > 0 {
+		node, err := remote.GetNode(ctx, args[0])
+		if err != nil {
+			exitError("%v", err)
+		}
+		if node != nil {
+			nodeID = node.ID
+			args = args[1:]
+		}
+		message = strings.Join(args, " ")
+	}
+
+	req := api.PromptRequest{
+		SystemPrompt: promptSystemPrompt,
+		Preset:       promptPreset,
+		Language:     promptLanguage,
+	}
+	// As in local mode, continuations keep the model of the branch.
+	if (promptPreset == "" && nodeID == "") || cmd.Flags().Changed("model") {
+		req.Model = promptModel
+	}
+
+	if promptStdin {
+		var err error
+		message, err = readStdinMessage(os.Stdin, message)
+		if err != nil {
+			exitError("failed to read stdin: %v", err)
+		}
+		if message == "" {
+			exitError("no message given, and nothing was piped to stdin")
+		}
+	}
+	if message == "" && promptQuiet {
+		exitError("--quiet needs a message: interactive mode is not quiet")
+	}
+	if message == "" && nonInteractive {
+		exitError("no message given, and interactive mode is disabled by --non-interactive")
+	}
+
+	if message != "" {
+		req.Message = message
+		result, err := remote.prompt(ctx, nodeID, req)
+		if err != nil {
+			exitError("prompt failed: %v", err)
+		}
+		printReply(result)
+		return
+	}
+
+	if nodeID != "" {
+		fmt.Printf("Continuing from node %s on %s\n", shortID(nodeID), remote.baseURL)
+	} else {
+		fmt.Printf("Starting new conversation on %s\n", remote.baseURL)
+	}
+	fmt.Println()
+	runRemoteInteractive(ctx, remote, nodeID, req, os.Stdin)
+}
+
+// runRemoteInteractive sends each line read from r as a message, continuing
+// from nodeID when it is set.
+func runRemoteInteractive(ctx context.Context, remote *remoteClient, nodeID string, req api.PromptRequest, r io.Reader) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	scanner := bufio.NewScanner(r)
+	for {
+		fmt.Print("You> ")
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+		input := strings.TrimSpace(scanner.Text())
+		switch {
+		case input == "":
+			continue
+		case input == "/quit" || input == "/exit":
+			fmt.Println("Goodbye!")
+			return
+		case strings.HasPrefix(input, "/"):
+			fmt.Println("\nChat commands are not available in remote mode; /quit exits.")
+			fmt.Println()
+			continue
+		}
+
+		req.Message = input
+		id, _ := streamResult(ctx, interrupt, func(ctx context.Context) (*langdag.PromptResult, error) {
+			return remote.prompt(ctx, nodeID, req)
+		})
+		if id != "" {
+			nodeID = id
+			// The new DAG keeps the system prompt; later messages continue
+			// on the branch's model.
+			req.SystemPrompt, req.Model = "", ""
+		}
+		fmt.Println()
+	}
+}
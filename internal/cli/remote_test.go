@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/api"
+	"langdag.com/langdag/types"
+)
+
+func TestRemoteClient(t *testing.T) {
+	now := time.Now()
+	dag := []*types.Node{
+		{ID: "root0001", NodeType: types.NodeTypeUser, Content: "Hi", CreatedAt: now},
+		{ID: "asst0001", ParentID: "root0001", RootID: "root0001", NodeType: types.NodeTypeAssistant, Content: "Hello", CreatedAt: now},
+		{ID: "user0002", ParentID: "asst0001", RootID: "root0001", NodeType: types.NodeTypeUser, Content: "More", CreatedAt: now},
+		{ID: "summ0001", ParentID: "root0001", RootID: "root0001", NodeType: types.NodeTypeSummary, Content: "A greeting", CreatedAt: now},
+	}
+	var prompted api.PromptRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /nodes", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(dag[:1])
+	})
+	mux.HandleFunc("GET /nodes/{id}", func(w http.ResponseWriter, r *http.Request) {
+		for _, n := range dag {
+			if strings.HasPrefix(n.ID, r.PathValue("id")) {
+				json.NewEncoder(w).Encode(n)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"node not found"}`)
+	})
+	mux.HandleFunc("GET /nodes/{id}/tree", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(dag)
+	})
+	mux.HandleFunc("POST /nodes/{id}/prompt", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&prompted)
+		fmt.Fprint(w, "event: start\ndata: {}\n\n")
+		fmt.Fprint(w, "event: delta\ndata: {\"content\":\"Sure\"}\n\n")
+		fmt.Fprint(w, "id: 3\nevent: done\ndata: {\"node_id\":\"asst0002\",\"content\":\"Sure\"}\n\n")
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid API key"}`)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := &remoteClient{baseURL: srv.URL, apiKey: "secret", http: srv.Client(), stream: srv.Client()}
+
+	roots, err := client.ListConversations(ctx)
+	if err != nil || len(roots) != 1 || roots[0].ID != "root0001" {
+		t.Fatalf("ListConversations = %v, %v", roots, err)
+	}
+	if node, err := client.GetNode(ctx, "missing"); node != nil || err != nil {
+		t.Errorf("GetNode(missing) = %v, %v, want nil, nil", node, err)
+	}
+	subtree, err := client.GetSubtree(ctx, "asst")
+	if err != nil || len(subtree) != 2 || subtree[1].ID != "user0002" {
+		t.Errorf("GetSubtree = %v, %v, want asst0001 and user0002", subtree, err)
+	}
+	ancestors, err := client.GetAncestors(ctx, "user")
+	if err != nil || len(ancestors) != 3 || ancestors[0].ID != "root0001" {
+		t.Errorf("GetAncestors = %v, %v, want root first", ancestors, err)
+	}
+	if summary, err := client.LatestSummary(ctx, "root0001"); err != nil || summary == nil || summary.ID != "summ0001" {
+		t.Errorf("LatestSummary = %v, %v", summary, err)
+	}
+
+	result, err := client.prompt(ctx, "user0002", api.PromptRequest{Message: "Go on"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var content, nodeID string
+	for chunk := range result.Stream {
+		if chunk.Error != nil {
+			t.Fatal(chunk.Error)
+		}
+		content += chunk.Content
+		if chunk.Done {
+			nodeID = chunk.NodeID
+		}
+	}
+	if content != "Sure" || nodeID != "asst0002" {
+		t.Errorf("reply = %q on %q, want Sure on asst0002", content, nodeID)
+	}
+	if prompted.Message != "Go on" || !prompted.Stream {
+		t.Errorf("request = %+v, want a streamed Go on", prompted)
+	}
+
+	client.apiKey = "wrong"
+	if _, err := client.ListConversations(ctx); err == nil || !strings.Contains(err.Error(), "invalid API key") {
+		t.Errorf("err = %v, want the server's error", err)
+	}
+}
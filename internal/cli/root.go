@@ -8,6 +8,9 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// version is the version of langdag, reported by `langdag version`.
+const version = "0.2.0"
+
 var (
 	cfgFile string
 	verbose bool
@@ -28,25 +31,37 @@ Examples:
   langdag prompt <node-id> "More"    # Continue from a node
   langdag ls                         # List all conversations
   langdag show <id>                  # Show node tree
-  langdag rm <id>                    # Delete node + subtree`,
+  langdag search <text>              # Search all conversations
+  langdag rm <id>                    # Delete node + subtree
+
+Destructive commands ask for confirmation; pass --yes to skip it.
+With --non-interactive, commands fail instead of prompting.`,
 }
 
 // Execute runs the root command.
 func Execute() error {
-	return rootCmd.Execute()
+	err := rootCmd.Execute()
+	recordCommand(err)
+	return err
 }
 
 func init() {
+	rootCmd.PersistentPreRun = startCommand
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.config/langdag/config.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
 	rootCmd.PersistentFlags().BoolVar(&outputJSON, "json", false, "output in JSON format")
 	rootCmd.PersistentFlags().BoolVar(&outputYAML, "yaml", false, "output in YAML format")
 	rootCmd.MarkFlagsMutuallyExclusive("json", "yaml")
+	rootCmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false, "never prompt: fail when a command needs confirmation or input (for scripts and CI)")
+	rootCmd.PersistentFlags().StringVar(&remoteURL, "remote", "", "URL of a 'langdag serve' instance to use instead of the local database (ls, show, diff, prompt, watch)")
 
 	// Add subcommands
 	rootCmd.AddCommand(lsCmd)
 	rootCmd.AddCommand(showCmd)
 	rootCmd.AddCommand(rmCmd)
+	rootCmd.AddCommand(cloneCmd)
+	rootCmd.AddCommand(renameCmd)
+	rootCmd.AddCommand(searchCmd)
 	rootCmd.AddCommand(promptCmd)
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(versionCmd)
@@ -57,12 +72,13 @@ var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Show version information",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("langdag version 0.2.0")
+		fmt.Println("langdag version " + version)
 	},
 }
 
 // exitError prints an error message and exits.
 func exitError(msg string, args ...interface{}) {
+	recordCommand(exitErr(msg, args))
 	fmt.Fprintf(os.Stderr, "Error: "+msg+"\n", args...)
 	os.Exit(1)
 }
@@ -10,13 +10,15 @@ import (
 
 	"langdag.com/langdag/internal/api"
 	"langdag.com/langdag/internal/config"
+	"langdag.com/langdag/internal/logging"
 	"github.com/spf13/cobra"
 )
 
 var (
 	servePort   int
 	serveHost   string
-	serveAPIKey string
+	serveAPIKey    string
+	serveEphemeral bool
 )
 
 // serveCmd starts the API server.
@@ -28,11 +30,11 @@ var serveCmd = &cobra.Command{
 The server provides REST endpoints for:
   - Prompting (new tree, continue from node) with SSE streaming
   - Node management (list roots, get, tree, delete)
-  - Workflow management and execution
 
 Example:
   langdag serve --port 8080
-  langdag serve --host 0.0.0.0 --port 3000 --api-key secret`,
+  langdag serve --host 0.0.0.0 --port 3000 --api-key secret
+  langdag serve --ephemeral   # in-memory storage, nothing written to disk`,
 	Run: runServe,
 }
 
@@ -40,6 +42,7 @@ func init() {
 	serveCmd.Flags().IntVarP(&servePort, "port", "p", 8080, "port to listen on")
 	serveCmd.Flags().StringVarP(&serveHost, "host", "H", "127.0.0.1", "host to bind to")
 	serveCmd.Flags().StringVar(&serveAPIKey, "api-key", "", "API key for authentication (optional)")
+	serveCmd.Flags().BoolVar(&serveEphemeral, "ephemeral", false, "keep all data in memory; discarded on exit")
 
 	rootCmd.AddCommand(serveCmd)
 }
@@ -50,12 +53,16 @@ func runServe(cmd *cobra.Command, args []string) {
 	if err != nil {
 		exitError("failed to load config: %v", err)
 	}
+	if err := logging.Setup(cfg.Logging); err != nil {
+		exitError("%v", err)
+	}
 
 	// Create server
 	addr := fmt.Sprintf("%s:%d", serveHost, servePort)
 	serverCfg := &api.Config{
-		Addr:   addr,
-		APIKey: serveAPIKey,
+		Addr:      addr,
+		APIKey:    serveAPIKey,
+		Ephemeral: serveEphemeral,
 	}
 
 	server, err := api.New(serverCfg, cfg)
@@ -84,20 +91,36 @@ func runServe(cmd *cobra.Command, args []string) {
 	fmt.Println()
 	fmt.Println("Endpoints:")
 	fmt.Println("  GET    /health             - Health check")
+	fmt.Println("  GET    /metrics            - Usage metrics (Prometheus format)")
+	fmt.Println("  GET    /activity           - Live activity (used by langdag top)")
+	fmt.Println("  GET    /usage              - Token and cost totals (?since=7d&by=model|day|dag)")
+	fmt.Println("  GET    /features           - Enabled server capabilities")
+	fmt.Println("  GET    /schema/tool        - JSON Schema of tool definitions")
 	fmt.Println("  POST   /prompt             - Start new conversation tree")
 	fmt.Println("  POST   /nodes/{id}/prompt  - Continue from existing node")
+	fmt.Println("  POST   /nodes/{id}/edit    - Edit a user message as a new branch")
+	fmt.Println("  POST   /nodes/{id}/regenerate - New reply next to an assistant node")
 	fmt.Println("  GET    /nodes              - List root nodes")
 	fmt.Println("  GET    /nodes/{id}         - Get a single node")
 	fmt.Println("  GET    /nodes/{id}/tree    - Get full tree from node")
+	fmt.Println("  GET    /nodes/{id}/search  - Search nodes in the node's DAG")
+	fmt.Println("  GET    /search             - Search nodes in all DAGs")
+	fmt.Println("  POST   /ask                - Ask a question about one or all DAGs")
+	fmt.Println("  GET    /nodes/{id}/export  - Export the node's DAG (JSON, YAML or Markdown)")
+	fmt.Println("  POST   /nodes/import       - Import a DAG export")
+	fmt.Println("  POST   /nodes/{id}/cancel  - Cancel generations running in the node's DAG")
+	fmt.Println("  POST   /nodes/{id}/clone   - Copy the path to a node into a new DAG")
+	fmt.Println("  POST   /nodes/{id}/summarize - Summarize the node's DAG or branch")
+	fmt.Println("  PATCH  /nodes/{id}         - Update the node's DAG (title, system prompt, model, tools)")
 	fmt.Println("  DELETE /nodes/{id}         - Delete node and subtree")
-	fmt.Println("  GET    /workflows          - List workflows")
-	fmt.Println("  POST   /workflows          - Create workflow")
-	fmt.Println("  POST   /workflows/{id}/run - Run workflow")
 	fmt.Println()
+	if serveEphemeral {
+		fmt.Println("Storage: In-memory (data is discarded on exit)")
+	}
 	if serveAPIKey != "" {
 		fmt.Println("Authentication: Required (use Authorization: Bearer <key> or X-API-Key header)")
 	} else {
-		fmt.Println("Authentication: Disabled (use --api-key to enable)")
+		fmt.Println("Authentication: Stored keys only (use --api-key or 'langdag apikey create' to add keys)")
 	}
 	fmt.Println()
 	fmt.Println("Press Ctrl+C to stop")
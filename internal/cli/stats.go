@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"langdag.com/langdag"
+)
+
+var statsDays int
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarize the stored DAGs",
+	Long: `Summarize the database: DAG counts by status (of their newest node)
+and source (langdag, fork, or an importer such as langgraph), token
+totals, replies and average latency by model, and the busiest days.
+
+Figures are computed with aggregate queries, so it stays fast on large
+databases. Use 'langdag usage' for costs over a period.
+
+Examples:
+  langdag stats
+  langdag stats --days 10 --json`,
+	Args: cobra.NoArgs,
+	Run:  runStats,
+}
+
+func init() {
+	statsCmd.Flags().IntVar(&statsDays, "days", 5, "number of busiest days to show")
+	rootCmd.AddCommand(statsCmd)
+}
+
+func runStats(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		exitError("%v", err)
+	}
+	defer client.Close()
+
+	stats, err := client.Stats(ctx, max(statsDays, 0))
+	if err != nil {
+		exitError("%v", err)
+	}
+	if !printFormatted(stats) {
+		renderStats(os.Stdout, stats)
+	}
+}
+
+// renderStats prints stats as a summary followed by tables.
+func renderStats(w io.Writer, stats *langdag.Stats) {
+	fmt.Fprintf(w, "DAGs: %d (%d nodes)\n", stats.DAGs, stats.Nodes)
+	fmt.Fprintf(w, "Tokens: %d in, %d out, %d cache read\n", stats.TokensIn, stats.TokensOut, stats.TokensCacheRead)
+	if stats.DAGs == 0 {
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	printCounts := func(title string, counts []langdag.StatsCount) {
+		if len(counts) == 0 {
+			return
+		}
+		fmt.Fprintf(tw, "\n%s\tDAGS\n", title)
+		for _, c := range counts {
+			key := c.Key
+			if key == "" {
+				key = "(none)"
+			}
+			fmt.Fprintf(tw, "%s\t%d\n", key, c.Count)
+		}
+	}
+	printCounts("STATUS", stats.ByStatus)
+	printCounts("SOURCE", stats.BySource)
+	tw.Flush()
+
+	if len(stats.Models) > 0 {
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "\nMODEL\tREPLIES\tINPUT\tOUTPUT\tAVG LATENCY\n")
+		for _, m := range stats.Models {
+			model := m.Model
+			if model == "" {
+				model = "(unknown)"
+			}
+			latency := "-"
+			if m.AvgLatencyMs > 0 {
+				latency = fmt.Sprintf("%.0f ms", m.AvgLatencyMs)
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n", model, m.Replies, m.TokensIn, m.TokensOut, latency)
+		}
+		tw.Flush()
+	}
+
+	if len(stats.BusiestDays) > 0 {
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "\nBUSIEST DAY\tMESSAGES\n")
+		for _, d := range stats.BusiestDays {
+			fmt.Fprintf(tw, "%s\t%d\n", d.Key, d.Count)
+		}
+		tw.Flush()
+	}
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"langdag.com/langdag"
+)
+
+var (
+	summarizeNode  string
+	summarizeModel string
+)
+
+var summarizeCmd = &cobra.Command{
+	Use:   "summarize <dag-id>",
+	Short: "Summarize a conversation or a branch",
+	Long: `Summarize a conversation with the model and store the summary as a
+summary node. Without --node, the summary covers the whole conversation,
+every branch included, and is stored under the root; 'langdag ls
+--summary' shows it. With --node, it covers the path from the root down
+to that node and is stored under it.
+
+Summary nodes are never sent to the model as conversation history.
+
+Examples:
+  langdag summarize abc123
+  langdag summarize abc123 --node def456 -m claude-haiku-4-5`,
+	Args: cobra.ExactArgs(1),
+	Run:  runSummarize,
+}
+
+func init() {
+	summarizeCmd.Flags().StringVar(&summarizeNode, "node", "", "summarize the branch ending at this node")
+	summarizeCmd.Flags().StringVarP(&summarizeModel, "model", "m", "", "model to use (default: the conversation's)")
+	rootCmd.AddCommand(summarizeCmd)
+}
+
+func runSummarize(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		exitError("%v", err)
+	}
+	defer client.Close()
+
+	root, err := client.GetNode(ctx, args[0])
+	if err != nil {
+		exitError("failed to get node: %v", err)
+	}
+	if root == nil {
+		exitError("node not found: %s", args[0])
+	}
+	target := root.RootID
+	if target == "" {
+		target = root.ID
+	}
+	if summarizeNode != "" {
+		node, err := client.GetNode(ctx, summarizeNode)
+		if err != nil {
+			exitError("failed to get node: %v", err)
+		}
+		if node == nil {
+			exitError("node not found: %s", summarizeNode)
+		}
+		if node.RootID != target && node.ID != target {
+			exitError("node %s is not in conversation %s", shortID(node.ID), shortID(target))
+		}
+		target = node.ID
+	}
+
+	var opts []langdag.PromptOption
+	if summarizeModel != "" {
+		opts = append(opts, langdag.WithModel(summarizeModel))
+	}
+	summary, err := client.Summarize(ctx, target, opts...)
+	if err != nil {
+		exitError("summarize failed: %v", err)
+	}
+	if printFormatted(summary) {
+		return
+	}
+	fmt.Println(summary.Content)
+	fmt.Printf("\n(node: %s)\n", shortID(summary.ID))
+}
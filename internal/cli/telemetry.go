@@ -0,0 +1,184 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"langdag.com/langdag/internal/config"
+	"langdag.com/langdag/internal/telemetry"
+)
+
+var telemetryCmd = &cobra.Command{
+	Use:   "telemetry",
+	Short: "Show or change anonymous usage telemetry",
+	Long: `Anonymous usage telemetry is off unless you opt in. When enabled, each
+command sends the command name (e.g. "langdag show"), how long it took,
+the class of error it failed with, the langdag version, OS and
+architecture, and a random installation ID. Arguments, messages,
+conversation content, IDs and paths are never sent. The payload is
+documented in docs/telemetry.md.
+
+telemetry.enabled in the config file (or LANGDAG_TELEMETRY_ENABLED) overrides
+'langdag telemetry enable|disable'; DO_NOT_TRACK=1 always disables it.`,
+}
+
+var telemetryStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether telemetry is enabled",
+	Args:  cobra.NoArgs,
+	RunE:  runTelemetryStatus,
+}
+
+var telemetryEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Opt in to anonymous usage telemetry",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setTelemetry(true)
+	},
+}
+
+var telemetryDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Opt out of anonymous usage telemetry",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setTelemetry(false)
+	},
+}
+
+func init() {
+	telemetryCmd.AddCommand(telemetryStatusCmd)
+	telemetryCmd.AddCommand(telemetryEnableCmd)
+	telemetryCmd.AddCommand(telemetryDisableCmd)
+	rootCmd.AddCommand(telemetryCmd)
+}
+
+func runTelemetryStatus(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	state, err := telemetry.LoadState(telemetry.StatePath())
+	if err != nil {
+		return err
+	}
+	enabled, reason := telemetry.Enabled(cfg.Telemetry.Enabled, state)
+	status := struct {
+		Enabled   bool   `json:"enabled" yaml:"enabled"`
+		Reason    string `json:"reason" yaml:"reason"`
+		Endpoint  string `json:"endpoint" yaml:"endpoint"`
+		InstallID string `json:"install_id,omitempty" yaml:"install_id,omitempty"`
+	}{enabled, reason, telemetryEndpoint(cfg), state.InstallID}
+	if printFormatted(status) {
+		return nil
+	}
+	if enabled {
+		fmt.Printf("Telemetry is enabled (%s)\n", reason)
+		fmt.Printf("Endpoint: %s\n", status.Endpoint)
+		if state.InstallID != "" {
+			fmt.Printf("Installation ID: %s\n", state.InstallID)
+		}
+	} else {
+		fmt.Printf("Telemetry is disabled (%s)\n", reason)
+	}
+	return nil
+}
+
+// setTelemetry saves the opt-in, warning when the configuration or the
+// environment overrides it.
+func setTelemetry(enabled bool) error {
+	path := telemetry.StatePath()
+	state, err := telemetry.LoadState(path)
+	if err != nil {
+		return err
+	}
+	state.Enabled = enabled
+	if !enabled {
+		state.InstallID = "" // a new ID is generated if telemetry is enabled again
+	} else if state.InstallID == "" {
+		state.InstallID = telemetry.NewInstallID()
+	}
+	if err := telemetry.SaveState(path, state); err != nil {
+		return err
+	}
+
+	if enabled {
+		fmt.Println("Telemetry enabled. Thank you! Only command names, durations and error classes are sent; see 'langdag telemetry --help'.")
+	} else {
+		fmt.Println("Telemetry disabled")
+	}
+	if cfg, err := config.Load(); err == nil {
+		if effective, reason := telemetry.Enabled(cfg.Telemetry.Enabled, state); effective != enabled {
+			fmt.Fprintf(os.Stderr, "Warning: this has no effect for now, telemetry is %s\n", reason)
+		}
+	}
+	return nil
+}
+
+// telemetryEndpoint returns the endpoint events are sent to.
+func telemetryEndpoint(cfg *config.Config) string {
+	if cfg.Telemetry.Endpoint != "" {
+		return cfg.Telemetry.Endpoint
+	}
+	return telemetry.DefaultEndpoint
+}
+
+// Command being run, recorded by the root command's PersistentPreRun.
+var (
+	commandPath    string
+	commandStarted time.Time
+)
+
+// startCommand records the command about to run, for telemetry.
+func startCommand(cmd *cobra.Command, args []string) {
+	commandPath, commandStarted = cmd.CommandPath(), time.Now()
+}
+
+// recordCommand sends the telemetry event of the command that ran, if the
+// user opted in. Failures are ignored: telemetry never gets in the way.
+func recordCommand(err error) {
+	if commandPath == "" {
+		return // no command ran (help, unknown command or flag)
+	}
+	path := commandPath
+	commandPath = ""
+	cfg, cfgErr := config.Load()
+	if cfgErr != nil {
+		return
+	}
+	statePath := telemetry.StatePath()
+	state, stateErr := telemetry.LoadState(statePath)
+	if stateErr != nil {
+		return
+	}
+	if enabled, _ := telemetry.Enabled(cfg.Telemetry.Enabled, state); !enabled {
+		return
+	}
+	if state.InstallID == "" { // enabled in the configuration
+		state.InstallID = telemetry.NewInstallID()
+		if telemetry.SaveState(statePath, state) != nil {
+			return
+		}
+	}
+	event := telemetry.NewEvent(state.InstallID, version, path, time.Since(commandStarted), err)
+	if sendErr := telemetry.Send(context.Background(), telemetryEndpoint(cfg), event); sendErr != nil && verbose {
+		fmt.Fprintf(os.Stderr, "telemetry: %v\n", sendErr)
+	}
+}
+
+// exitErr returns the error exitError exits with, keeping the errors among
+// args so that their class can be reported.
+func exitErr(msg string, args []interface{}) error {
+	err := fmt.Errorf(msg, args...)
+	for _, arg := range args {
+		if argErr, ok := arg.(error); ok {
+			return errors.Join(err, argErr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"langdag.com/langdag/internal/api"
+)
+
+var (
+	topServer   string
+	topAPIKey   string
+	topInterval time.Duration
+	topOnce     bool
+)
+
+// topCmd shows a live view of a running server's activity.
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Show live server activity",
+	Long: `Poll a running LangDAG server and show active generations, recent
+token throughput, per-provider latency and recent errors.
+
+The API key is read from --api-key or the LANGDAG_API_KEY environment variable.
+
+Example:
+  langdag top
+  langdag top --server http://10.0.0.5:8080 --interval 5s
+  langdag top --once`,
+	Run: runTop,
+}
+
+func init() {
+	topCmd.Flags().StringVar(&topServer, "server", "http://localhost:8080", "server URL")
+	topCmd.Flags().StringVar(&topAPIKey, "api-key", "", "API key (default $LANGDAG_API_KEY)")
+	topCmd.Flags().DurationVarP(&topInterval, "interval", "n", 2*time.Second, "refresh interval")
+	topCmd.Flags().BoolVar(&topOnce, "once", false, "print one snapshot and exit")
+	rootCmd.AddCommand(topCmd)
+}
+
+func runTop(cmd *cobra.Command, args []string) {
+	apiKey := topAPIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("LANGDAG_API_KEY")
+	}
+	if topInterval <= 0 {
+		exitError("--interval must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	for {
+		activity, err := fetchActivity(ctx, client, topServer, apiKey)
+		if topOnce {
+			if err != nil {
+				exitError("%v", err)
+			}
+			if !printFormatted(activity) {
+				renderActivity(os.Stdout, activity)
+			}
+			return
+		}
+
+		// Clear the screen and home the cursor before each frame.
+		fmt.Print("\033[H\033[2J")
+		fmt.Printf("langdag top - %s - every %s (Ctrl+C to quit)\n\n", topServer, topInterval)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		} else {
+			renderActivity(os.Stdout, activity)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(topInterval):
+		}
+	}
+}
+
+// fetchActivity reads GET /activity from the server.
+func fetchActivity(ctx context.Context, client *http.Client, server, apiKey string) (*api.ActivityResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(server, "/")+"/activity", nil)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		if body.Error == "" {
+			body.Error = resp.Status
+		}
+		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, body.Error)
+	}
+
+	var activity api.ActivityResponse
+	if err := json.NewDecoder(resp.Body).Decode(&activity); err != nil {
+		return nil, fmt.Errorf("failed to decode activity: %w", err)
+	}
+	return &activity, nil
+}
+
+// renderActivity prints an activity snapshot as plain-text tables.
+func renderActivity(out io.Writer, a *api.ActivityResponse) {
+	fmt.Fprintf(out, "Active generations: %d    Tokens/min (last 5m): %.0f\n\n", len(a.Active), a.TokensPerMinute)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if len(a.Active) > 0 {
+		fmt.Fprintf(w, "ROOT\tPARENT\tMODEL\tELAPSED\n")
+		for _, g := range a.Active {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", shortID(g.RootID), shortID(g.ParentID), g.Model,
+				(time.Duration(g.ElapsedMs) * time.Millisecond).Round(100*time.Millisecond))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(a.Providers) > 0 {
+		fmt.Fprintf(w, "PROVIDER\tGENERATIONS\tAVG LATENCY\n")
+		for _, p := range a.Providers {
+			fmt.Fprintf(w, "%s\t%d\t%dms\n", p.Provider, p.Generations, p.AvgLatencyMs)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
+	if len(a.RecentErrors) > 0 {
+		fmt.Fprintln(out, "Recent errors:")
+		for i := len(a.RecentErrors) - 1; i >= 0; i-- {
+			e := a.RecentErrors[i]
+			fmt.Fprintf(out, "  %s  %s\n", e.Time, e.Message)
+		}
+	}
+}
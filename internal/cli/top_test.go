@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"langdag.com/langdag/internal/api"
+)
+
+func TestTopFetchesAndRendersActivity(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/activity" || r.Header.Get("Authorization") != "Bearer k" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"unauthorized"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(api.ActivityResponse{
+			Active:          []api.ActiveGeneration{{RootID: "0123456789ab", ParentID: "fedcba987654", Model: "mock-fast", ElapsedMs: 1500}},
+			TokensPerMinute: 42,
+			Providers:       []api.ProviderActivity{{Provider: "mock", Generations: 3, AvgLatencyMs: 120}},
+			RecentErrors:    []api.ActivityError{{Time: "2026-01-01T00:00:00Z", Message: "boom"}},
+		})
+	}))
+	defer srv.Close()
+
+	if _, err := fetchActivity(context.Background(), srv.Client(), srv.URL, "wrong"); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Fatalf("expected unauthorized error, got %v", err)
+	}
+
+	activity, err := fetchActivity(context.Background(), srv.Client(), srv.URL+"/", "k")
+	if err != nil {
+		t.Fatalf("fetchActivity: %v", err)
+	}
+	var out strings.Builder
+	renderActivity(&out, activity)
+	for _, want := range []string{"Active generations: 1", "Tokens/min (last 5m): 42", "01234567", "mock-fast", "1.5s", "120ms", "boom"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
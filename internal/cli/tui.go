@@ -0,0 +1,535 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/chzyer/readline"
+	"github.com/spf13/cobra"
+	"langdag.com/langdag"
+	"langdag.com/langdag/types"
+)
+
+var (
+	tuiModelName string
+	tuiPreset    string
+)
+
+// tuiCmd browses conversations in a full-screen terminal UI.
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Browse and continue conversations in a terminal UI",
+	Long: `Open a full-screen view of your conversations: pick a DAG, move through
+its branches, and continue from any node. A message sent from a node that
+already has replies starts a new branch next to them.
+
+Keys:
+  Up/Down, j/k   move the selection
+  Enter          open a DAG; in a DAG, write a message from the selected node
+  n              start a new conversation
+  r              reload
+  Esc            stop writing, or go back to the list of DAGs
+  Ctrl+C         stop a reply in progress; otherwise quit (also q)`,
+	Run: runTUI,
+}
+
+func init() {
+	tuiCmd.Flags().StringVarP(&tuiModelName, "model", "m", "claude-sonnet-4-20250514", "model to use (when continuing, default: the branch's model)")
+	tuiCmd.Flags().StringVar(&tuiPreset, "preset", "", "named preset from config (model, system prompt, temperature)")
+	rootCmd.AddCommand(tuiCmd)
+}
+
+func runTUI(cmd *cobra.Command, args []string) {
+	if nonInteractive || !readline.IsTerminal(int(os.Stdin.Fd())) {
+		exitError("langdag tui needs an interactive terminal")
+	}
+	ctx := context.Background()
+
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		exitError("%v", err)
+	}
+	defer client.Close()
+
+	m := newTUIModel(ctx, client)
+	if tuiPreset != "" {
+		m.opts = append(m.opts, langdag.WithPreset(tuiPreset))
+	}
+	// As with 'langdag prompt', continuations keep the model of their
+	// branch unless --model is given.
+	if cmd.Flags().Changed("model") {
+		m.opts = append(m.opts, langdag.WithModel(tuiModelName))
+	} else if tuiPreset == "" {
+		m.newOpts = append(m.newOpts, langdag.WithModel(tuiModelName))
+	}
+
+	if _, err := tea.NewProgram(m, tea.WithAltScreen()).Run(); err != nil {
+		exitError("%v", err)
+	}
+}
+
+type tuiView int
+
+const (
+	tuiViewList tuiView = iota // the DAGs
+	tuiViewTree                // the branches of one DAG
+)
+
+// tuiLine is a node of the open DAG, as laid out in the tree view.
+type tuiLine struct {
+	node   *types.Node
+	prefix string // tree connectors drawn before the node
+}
+
+type (
+	tuiRootsMsg struct {
+		roots []*types.Node
+		err   error
+	}
+	tuiTreeMsg struct {
+		nodes    []*types.Node
+		rootID   string
+		selectID string
+		err      error
+	}
+	tuiStartedMsg struct {
+		result *langdag.PromptResult
+		err    error
+	}
+	tuiChunkMsg struct {
+		chunk langdag.StreamChunk
+		ok    bool // false once the stream is closed
+	}
+)
+
+// tuiModel is the state of langdag tui.
+type tuiModel struct {
+	ctx     context.Context
+	client  *langdag.Client
+	opts    []langdag.PromptOption // for every prompt
+	newOpts []langdag.PromptOption // added for new conversations
+
+	view          tuiView
+	width, height int
+
+	roots      []*types.Node
+	rootCursor int
+
+	rootID string // open DAG; empty for a new conversation
+	lines  []tuiLine
+	cursor int
+
+	typing bool
+	input  []rune
+
+	// The generation in progress, if any.
+	stream   <-chan langdag.StreamChunk
+	cancel   context.CancelFunc
+	reply    strings.Builder
+	replyID  string
+	parentID string
+
+	status string // last error or notice
+}
+
+func newTUIModel(ctx context.Context, client *langdag.Client) *tuiModel {
+	return &tuiModel{ctx: ctx, client: client, width: 80, height: 24}
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return m.loadRoots()
+}
+
+func (m *tuiModel) loadRoots() tea.Cmd {
+	return func() tea.Msg {
+		roots, err := m.client.ListConversations(m.ctx)
+		return tuiRootsMsg{roots: roots, err: err}
+	}
+}
+
+// loadTree loads the DAG containing the node id and selects selectID.
+func (m *tuiModel) loadTree(id, selectID string) tea.Cmd {
+	return func() tea.Msg {
+		ancestors, err := m.client.GetAncestors(m.ctx, id)
+		if err != nil || len(ancestors) == 0 {
+			return tuiTreeMsg{err: fmt.Errorf("node not found: %s", id)}
+		}
+		rootID := ancestors[0].ID
+		nodes, err := m.client.GetSubtree(m.ctx, rootID)
+		return tuiTreeMsg{nodes: nodes, rootID: rootID, selectID: selectID, err: err}
+	}
+}
+
+// send starts a reply to message from the selected node, or a new
+// conversation when no DAG is open.
+func (m *tuiModel) send(message string) tea.Cmd {
+	m.parentID = ""
+	if sel := m.selected(); sel != nil {
+		m.parentID = sel.ID
+	}
+	genCtx, cancel := context.WithCancel(m.ctx)
+	m.cancel = cancel
+	m.reply.Reset()
+	m.replyID = ""
+	m.status = ""
+	parentID := m.parentID
+	return func() tea.Msg {
+		var (
+			result *langdag.PromptResult
+			err    error
+		)
+		if parentID == "" {
+			result, err = m.client.Prompt(genCtx, message, slices.Concat(m.opts, m.newOpts)...)
+		} else {
+			result, err = m.client.PromptFrom(genCtx, parentID, message, m.opts...)
+		}
+		return tuiStartedMsg{result: result, err: err}
+	}
+}
+
+func waitChunk(stream <-chan langdag.StreamChunk) tea.Cmd {
+	return func() tea.Msg {
+		chunk, ok := <-stream
+		return tuiChunkMsg{chunk: chunk, ok: ok}
+	}
+}
+
+func (m *tuiModel) selected() *types.Node {
+	if m.view != tuiViewTree || m.cursor >= len(m.lines) {
+		return nil
+	}
+	return m.lines[m.cursor].node
+}
+
+func (m *tuiModel) generating() bool {
+	return m.cancel != nil
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+
+	case tuiRootsMsg:
+		if msg.err != nil {
+			m.status = "Error: " + msg.err.Error()
+			break
+		}
+		m.roots = msg.roots
+		m.rootCursor = min(m.rootCursor, max(len(m.roots)-1, 0))
+
+	case tuiTreeMsg:
+		if msg.err != nil {
+			m.status = "Error: " + msg.err.Error()
+			break
+		}
+		m.view = tuiViewTree
+		m.rootID = msg.rootID
+		m.lines = tuiTreeLines(msg.nodes, msg.rootID)
+		m.cursor = 0
+		for i, l := range m.lines {
+			if l.node.ID == msg.selectID {
+				m.cursor = i
+			}
+		}
+
+	case tuiStartedMsg:
+		if msg.err != nil {
+			m.finish()
+			m.status = "Error: " + msg.err.Error()
+			break
+		}
+		m.stream = msg.result.Stream
+		return m, waitChunk(m.stream)
+
+	case tuiChunkMsg:
+		if !msg.ok {
+			return m, m.finished()
+		}
+		switch {
+		case msg.chunk.Error != nil:
+			m.status = "Error: " + msg.chunk.Error.Error()
+		case msg.chunk.Done:
+			m.replyID = msg.chunk.NodeID
+		default:
+			m.reply.WriteString(msg.chunk.Content)
+		}
+		return m, waitChunk(m.stream)
+
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	}
+	return m, nil
+}
+
+// finish forgets the generation in progress.
+func (m *tuiModel) finish() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.cancel, m.stream = nil, nil
+}
+
+// finished reloads the DAG once a reply is saved, selecting it.
+func (m *tuiModel) finished() tea.Cmd {
+	m.finish()
+	switch {
+	case m.replyID != "":
+		return m.loadTree(m.replyID, m.replyID)
+	case m.parentID != "":
+		// Cancelled before anything was saved.
+		return m.loadTree(m.parentID, m.parentID)
+	}
+	return nil
+}
+
+func (m *tuiModel) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.Type == tea.KeyCtrlC {
+		if m.generating() {
+			m.cancel()
+			m.status = "Stopped."
+			return m, nil
+		}
+		return m, tea.Quit
+	}
+
+	if m.typing {
+		switch msg.Type {
+		case tea.KeyEsc:
+			m.typing = false
+		case tea.KeyEnter:
+			message := strings.TrimSpace(string(m.input))
+			if message == "" || m.generating() {
+				break
+			}
+			m.input = nil
+			m.typing = false
+			return m, m.send(message)
+		case tea.KeyBackspace:
+			if len(m.input) > 0 {
+				m.input = m.input[:len(m.input)-1]
+			}
+		case tea.KeyCtrlU:
+			m.input = nil
+		case tea.KeySpace:
+			m.input = append(m.input, ' ')
+		case tea.KeyRunes:
+			m.input = append(m.input, msg.Runes...)
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "q":
+		return m, tea.Quit
+	case "up", "k":
+		m.move(-1)
+	case "down", "j":
+		m.move(1)
+	case "r":
+		if m.view == tuiViewTree && m.rootID != "" {
+			return m, m.loadTree(m.rootID, m.selectedID())
+		}
+		return m, m.loadRoots()
+	case "n":
+		if m.generating() {
+			break
+		}
+		m.view = tuiViewTree
+		m.rootID, m.lines, m.cursor = "", nil, 0
+		m.typing = true
+	case "enter":
+		if m.view == tuiViewList {
+			if m.rootCursor < len(m.roots) {
+				id := m.roots[m.rootCursor].ID
+				return m, m.loadTree(id, id)
+			}
+			break
+		}
+		m.typing = true
+	case "esc":
+		if m.view == tuiViewTree && !m.generating() {
+			m.view = tuiViewList
+			m.status = ""
+			return m, m.loadRoots()
+		}
+	}
+	return m, nil
+}
+
+func (m *tuiModel) move(delta int) {
+	if m.view == tuiViewList {
+		m.rootCursor = min(max(m.rootCursor+delta, 0), max(len(m.roots)-1, 0))
+		return
+	}
+	m.cursor = min(max(m.cursor+delta, 0), max(len(m.lines)-1, 0))
+}
+
+func (m *tuiModel) selectedID() string {
+	if sel := m.selected(); sel != nil {
+		return sel.ID
+	}
+	return ""
+}
+
+func (m *tuiModel) View() string {
+	var b strings.Builder
+	if m.view == tuiViewList {
+		m.viewList(&b)
+	} else {
+		m.viewTree(&b)
+	}
+	if m.status != "" {
+		b.WriteString(truncate(m.status, max(m.width, 10)) + "\n")
+	}
+	return b.String()
+}
+
+func (m *tuiModel) viewList(b *strings.Builder) {
+	b.WriteString("Conversations (Enter open, n new, r reload, q quit)\n\n")
+	if len(m.roots) == 0 {
+		b.WriteString("No conversations yet. Press n to start one.\n")
+		return
+	}
+	rows := max(m.height-4, 1)
+	start := scrollStart(m.rootCursor, len(m.roots), rows)
+	for i := start; i < len(m.roots) && i < start+rows; i++ {
+		root := m.roots[i]
+		title := root.Title
+		if title == "" {
+			title = root.Content
+		}
+		line := fmt.Sprintf("%s  %s  %s", shortID(root.ID), root.CreatedAt.Format("2006-01-02 15:04"), title)
+		b.WriteString(cursorLine(truncate(line, max(m.width-2, 10)), i == m.rootCursor) + "\n")
+	}
+}
+
+func (m *tuiModel) viewTree(b *strings.Builder) {
+	if m.rootID == "" {
+		b.WriteString("New conversation (Esc back)\n\n")
+	} else {
+		b.WriteString("Conversation " + shortID(m.rootID) + " (Enter write from the selected node, Esc back)\n\n")
+	}
+
+	// The tree takes what the detail pane and the input leave.
+	pane := max(m.height/3, 3)
+	rows := max(m.height-pane-6, 1)
+	start := scrollStart(m.cursor, len(m.lines), rows)
+	for i := start; i < len(m.lines) && i < start+rows; i++ {
+		l := m.lines[i]
+		line := fmt.Sprintf("%s %s [%s]: %s", l.prefix, shortID(l.node.ID), l.node.NodeType, l.node.Content)
+		b.WriteString(cursorLine(truncate(line, max(m.width-2, 10)), i == m.cursor) + "\n")
+	}
+	b.WriteString(strings.Repeat("─", max(m.width, 1)) + "\n")
+
+	// The reply in progress, or the selected node in full.
+	var text string
+	if m.generating() {
+		text = m.reply.String()
+	} else if sel := m.selected(); sel != nil {
+		text = sel.Content
+	}
+	wrapped := wrapLines(text, max(m.width, 10))
+	if m.generating() && len(wrapped) > pane {
+		wrapped = wrapped[len(wrapped)-pane:] // follow the reply
+	} else if len(wrapped) > pane {
+		wrapped = wrapped[:pane]
+	}
+	for _, l := range wrapped {
+		b.WriteString(l + "\n")
+	}
+	for i := len(wrapped); i < pane; i++ {
+		b.WriteString("\n")
+	}
+
+	switch {
+	case m.generating():
+		b.WriteString("Generating... (Ctrl+C to stop)\n")
+	case m.typing:
+		b.WriteString("> " + string(m.input) + "█\n")
+	default:
+		b.WriteString("Press Enter to write a message.\n")
+	}
+}
+
+// cursorLine marks the selected line of a list.
+func cursorLine(line string, selected bool) string {
+	if selected {
+		return "\033[7m> " + line + "\033[0m"
+	}
+	return "  " + line
+}
+
+// scrollStart returns the first of n items to show in rows lines so that
+// the item at cursor is visible.
+func scrollStart(cursor, n, rows int) int {
+	if n <= rows || cursor < rows/2 {
+		return 0
+	}
+	return min(cursor-rows/2, n-rows)
+}
+
+// wrapLines splits text into lines of at most width runes.
+func wrapLines(text string, width int) []string {
+	var lines []string
+	for _, para := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		runes := []rune(para)
+		for len(runes) > width {
+			cut := width
+			if i := strings.LastIndex(string(runes[:width]), " "); i > 0 {
+				cut = len([]rune(string(runes[:width])[:i])) + 1
+			}
+			lines = append(lines, string(runes[:cut]))
+			runes = runes[cut:]
+		}
+		lines = append(lines, string(runes))
+	}
+	return lines
+}
+
+// tuiTreeLines lays out the nodes of a DAG the way 'langdag show' draws
+// them: a conversation goes straight down, and the replies of a branch
+// point are indented below it.
+func tuiTreeLines(nodes []*types.Node, rootID string) []tuiLine {
+	children := make(map[string][]*types.Node)
+	var root *types.Node
+	for _, n := range nodes {
+		if n.ID == rootID {
+			root = n
+		} else {
+			children[n.ParentID] = append(children[n.ParentID], n)
+		}
+	}
+	if root == nil {
+		return nil
+	}
+
+	var lines []tuiLine
+	var walk func(n *types.Node, prefix string, hasMoreSiblings bool)
+	walk = func(n *types.Node, prefix string, hasMoreSiblings bool) {
+		kids := children[n.ID]
+		connector := "├─"
+		if len(kids) != 1 {
+			connector = "└─"
+		}
+		if hasMoreSiblings {
+			connector = "│" + connector
+		}
+		lines = append(lines, tuiLine{node: n, prefix: prefix + connector})
+		switch len(kids) {
+		case 0:
+		case 1:
+			walk(kids[0], prefix, hasMoreSiblings)
+		default:
+			for i, kid := range kids {
+				walk(kid, prefix+" ", i < len(kids)-1)
+			}
+		}
+	}
+	walk(root, "", false)
+	return lines
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"langdag.com/langdag/types"
+)
+
+func TestTUITreeLines(t *testing.T) {
+	nodes := []*types.Node{
+		{ID: "root", NodeType: types.NodeTypeUser},
+		{ID: "a1", ParentID: "root", NodeType: types.NodeTypeAssistant},
+		{ID: "u2", ParentID: "a1", NodeType: types.NodeTypeUser},
+		{ID: "u3", ParentID: "a1", NodeType: types.NodeTypeUser},
+		{ID: "a3", ParentID: "u3", NodeType: types.NodeTypeAssistant},
+	}
+	var got []string
+	for _, l := range tuiTreeLines(nodes, "root") {
+		got = append(got, l.prefix+" "+l.node.ID)
+	}
+	want := []string{"├─ root", "└─ a1", " │└─ u2", " ├─ u3", " └─ a3"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lines:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTUIContinuesFromSelectedNode(t *testing.T) {
+	client := newMockLibraryClient(t, "Hello!")
+
+	m := newTUIModel(context.Background(), client)
+	run := func(cmd tea.Cmd) {
+		t.Helper()
+		for cmd != nil {
+			_, cmd = m.Update(cmd())
+		}
+	}
+	key := func(k tea.KeyMsg) {
+		t.Helper()
+		_, cmd := m.Update(k)
+		run(cmd)
+	}
+	typeText := func(s string) {
+		t.Helper()
+		key(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)})
+		key(tea.KeyMsg{Type: tea.KeyEnter})
+	}
+
+	run(m.Init())
+	key(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	typeText("Hi")
+	if len(m.lines) != 2 || m.cursor != 1 || m.lines[1].node.Content != "Hello!" {
+		t.Fatalf("after a new conversation: lines = %d, cursor = %d", len(m.lines), m.cursor)
+	}
+
+	// Branch from the first message.
+	key(tea.KeyMsg{Type: tea.KeyUp})
+	key(tea.KeyMsg{Type: tea.KeyEnter})
+	typeText("Hey")
+	if len(m.lines) != 4 {
+		t.Fatalf("after a branch: lines = %d, want 4", len(m.lines))
+	}
+	sel := m.selected()
+	if sel == nil || sel.Content != "Hello!" {
+		t.Fatalf("selected = %+v, want the new reply", sel)
+	}
+	parent, _ := client.GetNode(context.Background(), sel.ParentID)
+	if parent == nil || parent.Content != "Hey" || parent.ParentID != m.rootID {
+		t.Errorf("new reply answers %+v, want Hey from the root", parent)
+	}
+	if view := m.View(); !strings.Contains(view, "Hello!") {
+		t.Errorf("view missing the reply:\n%s", view)
+	}
+
+	key(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.view != tuiViewList || len(m.roots) != 1 {
+		t.Errorf("after Esc: view = %d, roots = %d", m.view, len(m.roots))
+	}
+}
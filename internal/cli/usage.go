@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"langdag.com/langdag"
+)
+
+var (
+	usageSince string
+	usageBy    string
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report token usage and cost",
+	Long: `Report tokens and cost of the replies generated over a period, by
+model, day (UTC) or conversation.
+
+Costs come from the provider, or from the catalog pricing recorded with
+each reply; replies without either are priced from the 'pricing' section of
+the config, if set, and otherwise counted as unpriced.
+
+Examples:
+  langdag usage
+  langdag usage --since 30d --by day
+  langdag usage --since 24h --by dag -o json`,
+	Args: cobra.NoArgs,
+	Run:  runUsage,
+}
+
+func init() {
+	usageCmd.Flags().StringVar(&usageSince, "since", "7d", "report replies from this long ago (e.g. 7d, 24h)")
+	usageCmd.Flags().StringVar(&usageBy, "by", langdag.UsageByModel, "group by model, day or dag")
+	rootCmd.AddCommand(usageCmd)
+}
+
+func runUsage(cmd *cobra.Command, args []string) {
+	age, err := parseAge(usageSince)
+	if err != nil {
+		exitError("invalid --since: %v", err)
+	}
+
+	ctx := context.Background()
+	client, err := newLibraryClient(ctx)
+	if err != nil {
+		exitError("%v", err)
+	}
+	defer client.Close()
+
+	report, err := client.Usage(ctx, time.Now().Add(-age), usageBy)
+	if err != nil {
+		exitError("%v", err)
+	}
+	if !printFormatted(report) {
+		renderUsage(os.Stdout, report)
+	}
+}
+
+// renderUsage prints a usage report as a table with a total line.
+func renderUsage(w io.Writer, report *langdag.UsageReport) {
+	fmt.Fprintf(w, "Usage since %s\n\n", report.Since.Local().Format("2006-01-02 15:04"))
+	if len(report.Rows) == 0 {
+		fmt.Fprintln(w, "No replies in this period.")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tREPLIES\tINPUT\tOUTPUT\tCACHE READ\tCOST\n", strings.ToUpper(report.GroupBy))
+	for _, row := range report.Rows {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\n", usageRowLabel(report.GroupBy, row),
+			row.Generations, row.TokensIn, row.TokensOut, row.TokensCacheRead, formatCost(row))
+	}
+	t := report.Total
+	fmt.Fprintf(tw, "total\t%d\t%d\t%d\t%d\t%s\n", t.Generations, t.TokensIn, t.TokensOut, t.TokensCacheRead, formatCost(t))
+	tw.Flush()
+	if t.Unpriced > 0 {
+		fmt.Fprintf(w, "\n* %d reply(ies) have no known price, or only a partial one; set prices under 'pricing' in the config.\n", t.Unpriced)
+	}
+}
+
+func usageRowLabel(groupBy string, row langdag.UsageRow) string {
+	switch {
+	case groupBy == langdag.UsageByDAG:
+		return fmt.Sprintf("%s %s", row.Key[:min(8, len(row.Key))], truncate(row.Title, 30))
+	case row.Key == "":
+		return "(unknown)"
+	}
+	return row.Key
+}
+
+// formatCost prints a row's cost, one amount per currency, marking rows
+// with unpriced replies.
+func formatCost(row langdag.UsageRow) string {
+	currencies := make([]string, 0, len(row.Cost))
+	for c := range row.Cost {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	var parts []string
+	for _, c := range currencies {
+		if c == "USD" {
+			parts = append(parts, fmt.Sprintf("$%.4f", row.Cost[c]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%.4f %s", row.Cost[c], c))
+		}
+	}
+	cost := strings.Join(parts, " + ")
+	if cost == "" {
+		cost = "-"
+	}
+	if row.Unpriced > 0 {
+		cost += "*"
+	}
+	return cost
+}
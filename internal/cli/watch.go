@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"langdag.com/langdag/internal/api"
+	"langdag.com/langdag/types"
+)
+
+var watchInterval time.Duration
+
+var watchCmd = &cobra.Command{
+	Use:   "watch <node-id>",
+	Short: "Follow a DAG as nodes are added",
+	Long: `Follow the DAG containing a node, printing each node as it is saved,
+and the status of the newest node at the end. The DAG is polled every
+--interval.
+
+In remote mode (--remote), generations running on the server are shown
+as they start, and watch exits once none is left in the DAG. Locally,
+generations run in the process that started them, so watch prints
+replies once they are saved, and runs until Ctrl-C.
+
+Examples:
+  langdag watch 7a8b
+  langdag --remote http://10.0.0.5:8080 watch 7a8b`,
+	Args: cobra.ExactArgs(1),
+	Run:  runWatch,
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", time.Second, "how often to poll the DAG")
+	rootCmd.AddCommand(watchCmd)
+}
+
+func runWatch(cmd *cobra.Command, args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	remote, err := newRemoteClient()
+	if err != nil {
+		exitError("%v", err)
+	}
+	var client dagReader = remote
+	if remote == nil {
+		if client, err = newLibraryClient(ctx); err != nil {
+			exitError("%v", err)
+		}
+	}
+	defer client.Close()
+
+	node, err := client.GetNode(ctx, args[0])
+	if err != nil {
+		exitError("failed to get node: %v", err)
+	}
+	if node == nil {
+		exitError("node not found: %s", args[0])
+	}
+	rootID := dagRootID(node)
+
+	w := newDAGWatcher(os.Stdout)
+	ticker := time.NewTicker(max(watchInterval, 100*time.Millisecond))
+	defer ticker.Stop()
+	for first := true; ; first = false {
+		// Generations are listed before the DAG is read, so that the
+		// reply of one that just ended is in the DAG.
+		var active []api.ActiveGeneration
+		if remote != nil {
+			if active, err = remote.activeGenerations(ctx, rootID); err != nil && ctx.Err() == nil {
+				exitError("failed to get activity: %v", err)
+			}
+		}
+		nodes, err := client.GetSubtree(ctx, rootID)
+		if err != nil && ctx.Err() == nil {
+			exitError("failed to get DAG: %v", err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if first {
+			fmt.Printf("Watching DAG %s (%d nodes)\n", shortID(rootID), len(nodes))
+			w.skip(nodes)
+		}
+		w.update(nodes, active)
+		if remote != nil && len(active) == 0 {
+			break
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	w.finish()
+}
+
+// dagWatcher prints the changes between successive polls of a DAG.
+type dagWatcher struct {
+	out     io.Writer
+	seen    map[string]bool
+	running map[string]bool // parent IDs of the generations in progress
+	newest  *types.Node
+}
+
+func newDAGWatcher(out io.Writer) *dagWatcher {
+	return &dagWatcher{out: out, seen: make(map[string]bool), running: make(map[string]bool)}
+}
+
+// skip marks nodes as seen without printing them.
+func (w *dagWatcher) skip(nodes []*types.Node) {
+	for _, n := range nodes {
+		w.seen[n.ID] = true
+		w.track(n)
+	}
+}
+
+// update prints the generations started and the nodes saved since the
+// last update.
+func (w *dagWatcher) update(nodes []*types.Node, active []api.ActiveGeneration) {
+	running := make(map[string]bool, len(active))
+	for _, g := range active {
+		running[g.ParentID] = true
+		if !w.running[g.ParentID] {
+			model := ""
+			if g.Model != "" {
+				model = " with " + g.Model
+			}
+			fmt.Fprintf(w.out, "%s  generating a reply to %s%s...\n", time.Now().Format("15:04:05"), shortID(g.ParentID), model)
+		}
+	}
+	w.running = running
+
+	for _, n := range nodes {
+		if w.seen[n.ID] {
+			continue
+		}
+		w.seen[n.ID] = true
+		w.track(n)
+		header := fmt.Sprintf("%s  %s [%s]", n.CreatedAt.Local().Format("15:04:05"), shortID(n.ID), n.NodeType)
+		if n.Model != "" {
+			header += " " + n.Model
+		}
+		if n.Status != "" && n.Status != "completed" {
+			header += " (" + n.Status + ")"
+		}
+		fmt.Fprintln(w.out, header)
+		for _, line := range strings.Split(strings.TrimRight(n.Content, "\n"), "\n") {
+			fmt.Fprintf(w.out, "  %s\n", line)
+		}
+	}
+}
+
+// track remembers n if it is the newest node so far.
+func (w *dagWatcher) track(n *types.Node) {
+	if w.newest == nil || !n.CreatedAt.Before(w.newest.CreatedAt) {
+		w.newest = n
+	}
+}
+
+// finish prints the status the DAG was left in.
+func (w *dagWatcher) finish() {
+	if w.newest == nil {
+		return
+	}
+	status := w.newest.Status
+	if status == "" {
+		status = "unknown"
+	}
+	fmt.Fprintf(w.out, "Final status: %s (newest node %s, %d nodes)\n", status, shortID(w.newest.ID), len(w.seen))
+}
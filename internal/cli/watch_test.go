@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/api"
+	"langdag.com/langdag/types"
+)
+
+func TestDAGWatcher(t *testing.T) {
+	now := time.Now()
+	root := &types.Node{ID: "root0001", NodeType: types.NodeTypeUser, Content: "Hi", Status: "completed", CreatedAt: now}
+	user := &types.Node{ID: "user0002", ParentID: "root0001", NodeType: types.NodeTypeUser, Content: "More", Status: "completed", CreatedAt: now.Add(time.Second)}
+	reply := &types.Node{ID: "asst0002", ParentID: "user0002", NodeType: types.NodeTypeAssistant, Model: "m1", Content: "Line 1\nLine 2", Status: "timeout", CreatedAt: now.Add(2 * time.Second)}
+
+	var out bytes.Buffer
+	w := newDAGWatcher(&out)
+	w.skip([]*types.Node{root})
+	w.update([]*types.Node{root, user}, []api.ActiveGeneration{{RootID: "root0001", ParentID: "user0002", Model: "m1"}})
+	w.update([]*types.Node{root, user}, []api.ActiveGeneration{{RootID: "root0001", ParentID: "user0002", Model: "m1"}})
+	w.update([]*types.Node{root, user, reply}, nil)
+	w.finish()
+
+	got := out.String()
+	for _, want := range []string{
+		"user0002 [user]\n  More\n",
+		"generating a reply to user0002 with m1...\n",
+		"asst0002 [assistant] m1 (timeout)\n  Line 1\n  Line 2\n",
+		"Final status: timeout (newest node asst0002, 3 nodes)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "generating") != 1 || strings.Contains(got, "root0001 [user]") {
+		t.Errorf("output repeats a generation or prints a skipped node:\n%s", got)
+	}
+}
@@ -20,6 +20,23 @@ type Config struct {
 	Server      ServerConfig                `mapstructure:"server"`
 	Logging     LoggingConfig               `mapstructure:"logging"`
 	Retry       RetryConfig                 `mapstructure:"retry"`
+	Metadata    MetadataConfig              `mapstructure:"metadata"`
+	Defaults    DefaultsConfig              `mapstructure:"defaults"`
+	Presets     map[string]PresetConfig     `mapstructure:"presets"`
+	Safety      SafetyConfig                `mapstructure:"safety"`
+	Archive     ArchiveConfig               `mapstructure:"archive"`
+	Classifier  ClassifierConfig            `mapstructure:"classifier"`
+	Titles      TitlesConfig                `mapstructure:"titles"`
+	IDs         IDsConfig                   `mapstructure:"ids"`
+	Pricing     map[string]PriceConfig      `mapstructure:"pricing"`
+	Tracing     TracingConfig               `mapstructure:"tracing"`
+	Trash       TrashConfig                 `mapstructure:"trash"`
+	Glossary    []GlossaryEntryConfig       `mapstructure:"glossary"`
+	Speculation SpeculationConfig           `mapstructure:"speculation"`
+	Telemetry   TelemetryConfig             `mapstructure:"telemetry"`
+	Tools       ToolsConfig                 `mapstructure:"tools"`
+	Embeddings  EmbeddingsConfig            `mapstructure:"embeddings"`
+	Remote      RemoteConfig                `mapstructure:"remote"`
 }
 
 // StorageConfig represents storage configuration.
@@ -27,6 +44,10 @@ type StorageConfig struct {
 	Driver     string `mapstructure:"driver"`
 	Path       string `mapstructure:"path"`
 	Connection string `mapstructure:"connection"`
+	// FileThreshold is the size in bytes above which node content is
+	// stored in files next to the SQLite database (default 256 KiB;
+	// negative keeps all content in the database).
+	FileThreshold int `mapstructure:"file_threshold"`
 }
 
 // ProvidersConfig represents provider configurations.
@@ -124,9 +145,52 @@ type MockProviderConfig struct {
 
 // ServerConfig represents server configuration.
 type ServerConfig struct {
-	Host        string   `mapstructure:"host"`
-	Port        int      `mapstructure:"port"`
-	CORSOrigins []string `mapstructure:"cors_origins"`
+	Host        string          `mapstructure:"host"`
+	Port        int             `mapstructure:"port"`
+	CORSOrigins []string        `mapstructure:"cors_origins"`
+	AccessLog   AccessLogConfig `mapstructure:"access_log"`
+	// GenerationTimeout is the longest a generation may run, e.g. "5m";
+	// empty for no limit.
+	GenerationTimeout string          `mapstructure:"generation_timeout"`
+	Guest             GuestConfig     `mapstructure:"guest"`
+	Callbacks         CallbacksConfig `mapstructure:"callbacks"`
+	Retention         RetentionConfig `mapstructure:"retention"`
+}
+
+// RetentionConfig makes the server delete stale DAGs: those with no node
+// newer than MaxAge and, when Statuses is set, a node in one of them.
+// Retention is off unless MaxAge is set.
+type RetentionConfig struct {
+	MaxAge   string   `mapstructure:"max_age"`  // e.g. "720h"
+	Statuses []string `mapstructure:"statuses"` // e.g. ["failed"]; empty for every DAG
+}
+
+// CallbacksConfig lets prompt requests carry a callback_url: the server
+// answers at once and POSTs the reply there when the generation ends,
+// signed with Secret. Callbacks are off unless AllowedHosts is set.
+type CallbacksConfig struct {
+	AllowedHosts []string `mapstructure:"allowed_hosts"` // e.g. "hooks.example.com", "*.example.com"
+	Secret       string   `mapstructure:"secret"`        // HMAC-SHA256 key of the X-Langdag-Signature-256 header; required
+}
+
+// GuestConfig configures guest access: when authentication is enabled,
+// clients without a key may start DAGs, tagged "guest", and use those
+// only. Guest DAGs are deleted once they are older than TTL.
+type GuestConfig struct {
+	Enabled           bool   `mapstructure:"enabled"`
+	TTL               string `mapstructure:"ttl"`                 // default "24h"
+	RequestsPerMinute int    `mapstructure:"requests_per_minute"` // per client address; default 10
+}
+
+// AccessLogConfig configures the API server's access log: one JSON line per
+// request with method, path, status, duration, key ID and sizes, written
+// apart from the application log.
+type AccessLogConfig struct {
+	Enabled    bool     `mapstructure:"enabled"`
+	Output     string   `mapstructure:"output"`      // "stdout" (default), "stderr" or a file path
+	SampleRate float64  `mapstructure:"sample_rate"` // fraction of successful requests logged; 0 = all
+	Bodies     bool     `mapstructure:"bodies"`      // log JSON request bodies, with Redact fields hidden
+	Redact     []string `mapstructure:"redact"`      // fields hidden in logged bodies; default: message text fields
 }
 
 // LoggingConfig represents logging configuration.
@@ -142,6 +206,161 @@ type RetryConfig struct {
 	MaxDelay   string `mapstructure:"max_delay"`
 }
 
+// MetadataConfig controls how per-request metadata (e.g. metadata.user_id)
+// is forwarded to providers.
+type MetadataConfig struct {
+	HashUserIDs bool   `mapstructure:"hash_user_ids"`
+	UserIDSalt  string `mapstructure:"user_id_salt"`
+}
+
+// DefaultsConfig holds request defaults applied when a prompt leaves them
+// unset.
+type DefaultsConfig struct {
+	MaxTokens      int            `mapstructure:"max_tokens"`       // 0 = built-in default
+	ModelMaxTokens map[string]int `mapstructure:"model_max_tokens"` // per-model override of MaxTokens
+	SystemPrompt   string         `mapstructure:"system_prompt"`    // layered before every DAG's system prompt
+	Language       string         `mapstructure:"language"`         // language of replies, unless a request or DAG sets one
+}
+
+// PresetConfig is a named bundle of prompt parameters, selected with
+// `--preset` on the CLI or the "preset" field of API requests.
+type PresetConfig struct {
+	Model       string   `mapstructure:"model"`
+	System      string   `mapstructure:"system"`
+	Temperature *float64 `mapstructure:"temperature"`
+}
+
+// SafetyConfig holds optional safety checks.
+type SafetyConfig struct {
+	InjectionScan InjectionScanConfig `mapstructure:"injection_scan"`
+}
+
+// InjectionScanConfig configures prompt-injection scanning of tool results.
+type InjectionScanConfig struct {
+	Enabled             bool     `mapstructure:"enabled"`
+	RequireConfirmation bool     `mapstructure:"require_confirmation"`
+	Patterns            []string `mapstructure:"patterns"` // extra regular expressions
+}
+
+// ArchiveConfig configures cold storage for archived DAGs.
+type ArchiveConfig struct {
+	// Location is an s3://bucket/prefix, gs://bucket/prefix or local
+	// directory. Archived DAGs are rehydrated from it when accessed.
+	Location string `mapstructure:"location"`
+}
+
+// ClassifierConfig configures automatic topic tagging of DAGs.
+type ClassifierConfig struct {
+	Enabled bool                `mapstructure:"enabled"`
+	Model   string              `mapstructure:"model"` // optional; a cheap model suggesting extra tags
+	Rules   map[string][]string `mapstructure:"rules"` // tag -> keywords
+}
+
+// GlossaryEntryConfig is a term of the project glossary. Terms used in a
+// message have their definition added to the system prompt of the reply.
+type GlossaryEntryConfig struct {
+	Term       string `mapstructure:"term"`
+	Definition string `mapstructure:"definition"` // preferred translation or definition
+}
+
+// SpeculationConfig configures speculative replies (experimental): the
+// server answers likely follow-ups ahead of time as hidden branches.
+type SpeculationConfig struct {
+	Enabled          bool   `mapstructure:"enabled"`
+	Suggestions      int    `mapstructure:"suggestions"`        // follow-ups answered per reply; default 3, at most 5
+	Model            string `mapstructure:"model"`              // suggests the follow-ups; default: cheapest model of the reply's provider
+	MaxTokens        int    `mapstructure:"max_tokens"`         // per speculative reply; default 1024
+	DailyTokenBudget int    `mapstructure:"daily_token_budget"` // default 200000
+	TTL              string `mapstructure:"ttl"`                // unused branches are deleted after; default "1h"
+}
+
+// EmbeddingsConfig configures the embeddings of node content behind
+// semantic search (GET /search/semantic).
+type EmbeddingsConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Model   string `mapstructure:"model"` // required, e.g. "text-embedding-3-small"
+}
+
+// RemoteConfig points the CLI at a running 'langdag serve' instead of the
+// local database (see --remote).
+type RemoteConfig struct {
+	URL    string `mapstructure:"url"`
+	APIKey string `mapstructure:"api_key"`
+}
+
+// ToolsConfig enables the built-in tools that 'langdag serve' runs itself
+// when a model calls them. Each tool is off unless its allow-list is set.
+type ToolsConfig struct {
+	HTTPFetch      HTTPFetchToolConfig `mapstructure:"http_fetch"`
+	Shell          ShellToolConfig     `mapstructure:"shell"`
+	Files          FilesToolConfig     `mapstructure:"files"`
+	MaxOutputBytes int                 `mapstructure:"max_output_bytes"` // truncates results; default 65536
+}
+
+// HTTPFetchToolConfig configures the http_fetch tool (GET requests).
+type HTTPFetchToolConfig struct {
+	AllowedHosts []string `mapstructure:"allowed_hosts"` // e.g. "example.com", "*.example.com"
+	Timeout      string   `mapstructure:"timeout"`       // default "10s"
+}
+
+// ShellToolConfig configures the shell tool, which runs allowed programs
+// without a shell.
+type ShellToolConfig struct {
+	AllowedCommands []string `mapstructure:"allowed_commands"`
+	Dir             string   `mapstructure:"dir"`     // working directory; required
+	Timeout         string   `mapstructure:"timeout"` // default "30s"
+}
+
+// FilesToolConfig configures the file_read and file_write tools.
+type FilesToolConfig struct {
+	Root  string `mapstructure:"root"`  // the files the tools can access
+	Write bool   `mapstructure:"write"` // enables file_write
+}
+
+// TitlesConfig configures model-generated DAG titles.
+type TitlesConfig struct {
+	Generate bool   `mapstructure:"generate"`
+	Model    string `mapstructure:"model"` // default: cheapest model of the DAG's provider
+}
+
+// IDsConfig configures the IDs of new nodes.
+type IDsConfig struct {
+	// Format is "uuid" (default) or "short": 12-character base32 IDs that
+	// sort by creation time. Existing IDs keep working either way.
+	Format string `mapstructure:"format"`
+}
+
+// PriceConfig is the price of a model per million tokens, used by usage
+// reports for generations without a recorded cost.
+type PriceConfig struct {
+	Input     float64 `mapstructure:"input"`
+	Output    float64 `mapstructure:"output"`
+	CacheRead float64 `mapstructure:"cache_read"`
+	Currency  string  `mapstructure:"currency"` // default USD
+}
+
+// TracingConfig configures OpenTelemetry tracing of the API server.
+type TracingConfig struct {
+	Exporter    string  `mapstructure:"exporter"`     // "otlp", "stdout" or "none" (default)
+	Endpoint    string  `mapstructure:"endpoint"`     // OTLP/HTTP endpoint; default from OTEL_EXPORTER_OTLP_* variables
+	SampleRate  float64 `mapstructure:"sample_rate"`  // fraction of traces kept; 0 = all
+	ServiceName string  `mapstructure:"service_name"` // default "langdag"
+}
+
+// TrashConfig configures the JSON exports saved before DAGs are deleted.
+type TrashConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	Dir       string `mapstructure:"dir"`       // default ~/.config/langdag/trash
+	Retention string `mapstructure:"retention"` // e.g. "720h"; default 30 days
+}
+
+// TelemetryConfig configures anonymous CLI usage events. Telemetry is
+// off unless enabled here or with `langdag telemetry enable`.
+type TelemetryConfig struct {
+	Enabled  *bool  `mapstructure:"enabled"`  // nil: as set by `langdag telemetry`
+	Endpoint string `mapstructure:"endpoint"` // default telemetry.DefaultEndpoint
+}
+
 // Load loads the configuration from files and environment variables.
 func Load() (*Config, error) {
 	v := viper.New()
@@ -196,6 +415,14 @@ func Load() (*Config, error) {
 	v.BindEnv("retry.max_retries", "LANGDAG_RETRY_MAX")
 	v.BindEnv("retry.base_delay", "LANGDAG_RETRY_BASE_DELAY")
 	v.BindEnv("retry.max_delay", "LANGDAG_RETRY_MAX_DELAY")
+	v.BindEnv("metadata.hash_user_ids", "LANGDAG_HASH_USER_IDS")
+	v.BindEnv("metadata.user_id_salt", "LANGDAG_USER_ID_SALT")
+	v.BindEnv("defaults.max_tokens", "LANGDAG_MAX_TOKENS")
+	v.BindEnv("defaults.system_prompt", "LANGDAG_SYSTEM_PROMPT")
+	v.BindEnv("archive.location", "LANGDAG_ARCHIVE_LOCATION")
+	v.BindEnv("telemetry.enabled", "LANGDAG_TELEMETRY_ENABLED")
+	v.BindEnv("remote.url", "LANGDAG_REMOTE_URL")
+	v.BindEnv("remote.api_key", "LANGDAG_API_KEY")
 
 	// Provider variant env vars
 	v.BindEnv("providers.anthropic-vertex.project_id", "VERTEX_PROJECT_ID")
@@ -270,6 +497,15 @@ func GetDefaultStoragePath() string {
 	return filepath.Join(homeDir, ".config", "langdag", "langdag.db")
 }
 
+// GetDefaultTrashDir returns the default directory of deleted DAG exports.
+func GetDefaultTrashDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "./langdag-trash"
+	}
+	return filepath.Join(homeDir, ".config", "langdag", "trash")
+}
+
 // EnsureConfigDir ensures the config directory exists.
 func EnsureConfigDir() error {
 	homeDir, err := os.UserHomeDir()
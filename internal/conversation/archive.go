@@ -0,0 +1,183 @@
+package conversation
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"langdag.com/langdag/internal/archive"
+	"langdag.com/langdag/types"
+)
+
+// SetArchiveStore sets the cold storage used by Archive and for rehydrating
+// archived DAGs. Without a store, archived DAGs stay as stubs.
+func (m *Manager) SetArchiveStore(store archive.Store) {
+	m.archive = store
+}
+
+// Archive moves every DAG whose newest node was created before cutoff to the
+// archive store. The root node stays in storage as a stub (no content, with
+// ArchivedURI set) so the DAG is still listed; the rest of the tree is
+// deleted. DAGs with a generation in progress are skipped. It returns the
+// number of DAGs archived.
+func (m *Manager) Archive(ctx context.Context, cutoff time.Time) (int, error) {
+	if m.archive == nil {
+		return 0, fmt.Errorf("no archive store configured")
+	}
+	roots, err := m.storage.ListRootNodes(ctx)
+	if err != nil {
+		return 0, err
+	}
+	archived := 0
+	for _, root := range roots {
+		if root.ArchivedURI != "" || m.hasActiveRun(root.ID) {
+			continue
+		}
+		nodes, err := m.storage.GetSubtree(ctx, root.ID)
+		if err != nil {
+			return archived, err
+		}
+		if !olderThan(nodes, cutoff) {
+			continue
+		}
+		if err := m.archiveDAG(ctx, root); err != nil {
+			return archived, fmt.Errorf("failed to archive %s: %w", root.ID, err)
+		}
+		archived++
+	}
+	return archived, nil
+}
+
+// olderThan reports whether every node was created before cutoff.
+func olderThan(nodes []*types.Node, cutoff time.Time) bool {
+	for _, n := range nodes {
+		if !n.CreatedAt.Before(cutoff) {
+			return false
+		}
+	}
+	return len(nodes) > 0
+}
+
+func (m *Manager) hasActiveRun(rootID string) bool {
+	m.runsMu.Lock()
+	defer m.runsMu.Unlock()
+	return len(m.runs[rootID]) > 0
+}
+
+// archiveDAG uploads a DAG, then turns its root into a stub and deletes the
+// other nodes. The stub is written before the deletion, so an interrupted
+// run never leaves nodes that are neither stored nor reachable.
+func (m *Manager) archiveDAG(ctx context.Context, root *types.Node) error {
+	dag, err := m.Export(ctx, root.ID)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(dag); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	uri, err := m.archive.Put(ctx, root.ID+".json.gz", buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	stub := *root
+	stub.Content = ""
+	stub.ArchivedURI = uri
+	if err := m.storage.UpdateNode(ctx, &stub); err != nil {
+		return err
+	}
+	children, err := m.storage.GetNodeChildren(ctx, root.ID)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := m.storage.DeleteNode(ctx, child.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rehydrate restores an archived DAG from the archive store and returns its
+// restored root. Nodes that already exist are left alone, so a rehydration
+// interrupted part-way can simply be retried.
+func (m *Manager) rehydrate(ctx context.Context, stub *types.Node) (*types.Node, error) {
+	if m.archive == nil {
+		return nil, fmt.Errorf("node %s is archived at %s and no archive store is configured", stub.ID, stub.ArchivedURI)
+	}
+	m.archiveMu.Lock()
+	defer m.archiveMu.Unlock()
+
+	// Another request may have restored the DAG while we waited.
+	current, err := m.storage.GetNode(ctx, stub.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil || current.ArchivedURI == "" {
+		return current, nil
+	}
+
+	data, err := m.archive.Get(ctx, current.ArchivedURI)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive %s: %w", current.ArchivedURI, err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive %s: %w", current.ArchivedURI, err)
+	}
+	var dag DAGExport
+	if err := json.Unmarshal(raw, &dag); err != nil {
+		return nil, fmt.Errorf("invalid archive %s: %w", current.ArchivedURI, err)
+	}
+	if err := validateExport(&dag); err != nil || dag.Nodes[0].ID != current.ID {
+		return nil, fmt.Errorf("archive %s does not hold DAG %s", current.ArchivedURI, current.ID)
+	}
+	if err := m.restoreNodes(ctx, &dag, dag.Nodes[1:]); err != nil {
+		return nil, err
+	}
+
+	root := dag.Nodes[0]
+	root.ArchivedURI = ""
+	if err := m.storage.UpdateNode(ctx, root); err != nil {
+		return nil, err
+	}
+	return m.storage.GetNode(ctx, root.ID)
+}
+
+// extractToolUseIDsFromContent extracts tool_use id values from a stored
+// assistant content string.
+func extractToolUseIDsFromContent(content string) []string {
+	trimmed := strings.TrimSpace(content)
+	if len(trimmed) == 0 || trimmed[0] != '[' || !json.Valid([]byte(trimmed)) {
+		return nil
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}
+	if json.Unmarshal([]byte(trimmed), &blocks) != nil {
+		return nil
+	}
+	var ids []string
+	for _, b := range blocks {
+		if b.Type == "tool_use" && b.ID != "" {
+			ids = append(ids, b.ID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,102 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/archive"
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/types"
+)
+
+func TestArchiveAndRehydrate(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+
+	archiveStore, err := archive.Open(ctx, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetArchiveStore(archiveStore)
+
+	old := time.Now().Add(-100 * 24 * time.Hour)
+	toolUse := `[{"type":"tool_use","id":"toolu_1","name":"lookup","input":{}}]`
+	toolResult := `[{"type":"tool_result","tool_use_id":"toolu_1","content":"42"}]`
+	nodes := []*types.Node{
+		{ID: "root", Sequence: 0, NodeType: types.NodeTypeUser, Content: "Old question", Title: "Old", CreatedAt: old},
+		{ID: "a1", ParentID: "root", RootID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant, Content: toolUse, CreatedAt: old},
+		{ID: "u2", ParentID: "a1", RootID: "root", Sequence: 2, NodeType: types.NodeTypeUser, Content: toolResult, CreatedAt: old},
+		{ID: "fresh", Sequence: 0, NodeType: types.NodeTypeUser, Content: "New question", CreatedAt: time.Now()},
+	}
+	for _, n := range nodes {
+		if err := store.CreateNode(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.CreateAlias(ctx, "u2", "answer"); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := mgr.Archive(ctx, time.Now().Add(-90*24*time.Hour))
+	if err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("archived %d DAGs, want 1", n)
+	}
+
+	stub, _ := store.GetNode(ctx, "root")
+	if stub.ArchivedURI == "" || stub.Content != "" || stub.Title != "Old" {
+		t.Fatalf("unexpected stub: %+v", stub)
+	}
+	if child, _ := store.GetNode(ctx, "a1"); child != nil {
+		t.Fatal("expected archived children to be deleted")
+	}
+	if fresh, _ := store.GetNode(ctx, "fresh"); fresh == nil || fresh.ArchivedURI != "" {
+		t.Fatal("recent DAG should not be archived")
+	}
+
+	// Archiving again is a no-op.
+	if n, err := mgr.Archive(ctx, time.Now()); err != nil || n != 1 {
+		t.Fatalf("second Archive = %d, %v; want only the fresh DAG", n, err)
+	}
+	if fresh, _ := store.GetNode(ctx, "fresh"); fresh.ArchivedURI == "" {
+		t.Fatal("expected fresh DAG to be archived with a later cutoff")
+	}
+
+	root, err := mgr.ResolveNode(ctx, "root")
+	if err != nil {
+		t.Fatalf("ResolveNode: %v", err)
+	}
+	if root.ArchivedURI != "" || root.Content != "Old question" {
+		t.Fatalf("root not restored: %+v", root)
+	}
+	subtree, err := store.GetSubtree(ctx, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subtree) != 3 {
+		t.Fatalf("restored %d nodes, want 3", len(subtree))
+	}
+	if aliased, _ := store.GetNodeByAlias(ctx, "answer"); aliased == nil || aliased.ID != "u2" {
+		t.Error("expected alias to be restored")
+	}
+	// Tool IDs are re-indexed: the tool_use is orphaned without its result.
+	if orphans, _ := store.GetOrphanedToolUses(ctx, []string{"root", "a1"}); len(orphans["a1"]) != 1 {
+		t.Errorf("expected tool_use ID to be re-indexed, got %v", orphans)
+	}
+	if orphans, _ := store.GetOrphanedToolUses(ctx, []string{"root", "a1", "u2"}); len(orphans) != 0 {
+		t.Errorf("expected tool_result ID to be re-indexed, got %v", orphans)
+	}
+}
+
+func TestArchiveRequiresStore(t *testing.T) {
+	mgr, cleanup := newTestManager(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+
+	if _, err := mgr.Archive(context.Background(), time.Now()); err == nil {
+		t.Fatal("expected error without an archive store")
+	}
+}
@@ -0,0 +1,309 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"langdag.com/langdag/types"
+)
+
+// askContextBudget is the number of characters of DAG content included in
+// the context of a question. Smaller DAGs are included whole.
+const askContextBudget = 24000
+
+// askExcerptLimit caps each node excerpt when the DAG has to be condensed.
+const askExcerptLimit = 2000
+
+const askSystemPrompt = `You answer questions about a past conversation. Use only the excerpts given, each labelled with its node ID. Cite the node IDs you rely on in square brackets, like [1a2b3c4d]. If the excerpts do not contain the answer, say so.`
+
+// Ask answers question about the DAG containing nodeID and streams the
+// answer. The context is the DAG itself, condensed to the nodes that share
+// the most words with the question when it is too large. The question and
+// answer are recorded as a side branch off the root, marked with Meta so
+// later questions ignore them. The model defaults to the DAG's.
+func (m *Manager) Ask(ctx context.Context, nodeID, question, model string) (<-chan types.StreamEvent, error) {
+	if strings.TrimSpace(question) == "" {
+		return nil, fmt.Errorf("question is required")
+	}
+	node, err := m.storage.GetNode(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("node not found: %s", nodeID)
+	}
+	rootID := node.RootID
+	if rootID == "" {
+		rootID = node.ID
+	}
+	nodes, err := m.storage.GetSubtree(ctx, rootID)
+	if err != nil {
+		return nil, err
+	}
+	nodes = withoutSpeculative(nodes)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("node not found: %s", rootID)
+	}
+	root := nodes[0]
+	if model == "" {
+		model = root.Model
+	}
+
+	meta, _ := json.Marshal(types.UserNodeMetadata{Meta: true})
+	userNode := &types.Node{
+		ID:        m.newID(),
+		ParentID:  root.ID,
+		RootID:    root.ID,
+		Sequence:  root.Sequence + 1,
+		NodeType:  types.NodeTypeUser,
+		Content:   question,
+		Status:    "completed",
+		Metadata:  meta,
+		CreatedAt: time.Now(),
+	}
+	if err := m.storage.CreateNode(ctx, userNode); err != nil {
+		return nil, fmt.Errorf("failed to create user node: %w", err)
+	}
+
+	prompt := askContext(root, conversationNodes(nodes), question) + "\n\nQuestion: " + question
+	messages := []types.Message{
+		{Role: "user", Content: contentToRawMessage(prompt)},
+	}
+	events, err := m.streamResponse(ctx, userNode, messages, model, "", askSystemPrompt, nil, nil, 0, 0)
+	return m.discardUnanswered(ctx, userNode, events, err)
+}
+
+// askAllSources is the number of nodes AskAll passes to the model.
+const askAllSources = 12
+
+// askAllSearchLimit is the number of matches fetched for each question term.
+const askAllSearchLimit = 50
+
+// ErrNoSources is returned by AskAll when no stored node matches the
+// question.
+var ErrNoSources = errors.New("no conversation matches the question")
+
+// AskAll answers question from every stored DAG. It searches for nodes
+// matching the words of the question, keeps those matching the most
+// words, and streams an answer citing their node IDs. The question and
+// answer are recorded as a new DAG whose root is marked Meta, so they are
+// never used as sources themselves. It returns the nodes used as sources.
+func (m *Manager) AskAll(ctx context.Context, question, model string) ([]SearchMatch, <-chan types.StreamEvent, error) {
+	if strings.TrimSpace(question) == "" {
+		return nil, nil, fmt.Errorf("question is required")
+	}
+	sources, err := m.askSources(ctx, question)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sources) == 0 {
+		return nil, nil, ErrNoSources
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Excerpts from %d messages across past conversations, those most related to the question.\n", len(sources))
+	for _, src := range sources {
+		root, err := m.storage.GetNode(ctx, src.Path[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		text := strings.TrimSpace(markdownContent(src.Node.Content))
+		if len(text) > askExcerptLimit {
+			text = text[:askExcerptLimit] + " [...]"
+		}
+		title := ""
+		if root != nil && root.Title != "" {
+			title = fmt.Sprintf(" in %q", root.Title)
+		}
+		fmt.Fprintf(&b, "\n[%s] %s%s:\n%s\n", shortNodeID(src.Node.ID), nodeTypeLabel(src.Node.NodeType), title, text)
+	}
+	prompt := b.String() + "\n\nQuestion: " + question
+
+	meta, _ := json.Marshal(types.UserNodeMetadata{Meta: true})
+	rootID := m.newID()
+	rootNode := &types.Node{
+		ID:        rootID,
+		RootID:    rootID,
+		NodeType:  types.NodeTypeUser,
+		Content:   question,
+		Model:     model,
+		Status:    "completed",
+		Title:     GenerateTitle(question),
+		Metadata:  meta,
+		CreatedAt: time.Now(),
+	}
+	if err := m.storage.CreateNode(ctx, rootNode); err != nil {
+		return nil, nil, fmt.Errorf("failed to create root node: %w", err)
+	}
+	messages := []types.Message{
+		{Role: "user", Content: contentToRawMessage(prompt)},
+	}
+	events, err := m.streamResponse(ctx, rootNode, messages, model, "", askSystemPrompt, nil, nil, 0, 0)
+	events, err = m.discardUnanswered(ctx, rootNode, events, err)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sources, events, nil
+}
+
+// askSources searches every DAG for each word of question and returns up
+// to askAllSources nodes, those matching the most words first. Nodes on
+// branches recorded by Ask or AskAll, speculative nodes and DAGs not
+// visible to the request are skipped.
+func (m *Manager) askSources(ctx context.Context, question string) ([]SearchMatch, error) {
+	hits := make(map[string]int)
+	byID := make(map[string]*types.Node)
+	var order []string
+	terms := askTerms(question)
+	for _, term := range terms {
+		nodes, err := m.storage.SearchNodes(ctx, term, askAllSearchLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range withoutSpeculative(nodes) {
+			if _, seen := byID[n.ID]; !seen {
+				byID[n.ID] = n
+				order = append(order, n.ID)
+			}
+			hits[n.ID]++
+		}
+	}
+	// Most matched words first; search order breaks ties.
+	sort.SliceStable(order, func(a, b int) bool {
+		return hits[order[a]] > hits[order[b]]
+	})
+
+	var sources []SearchMatch
+	for _, id := range order {
+		if len(sources) == askAllSources {
+			break
+		}
+		ancestors, err := m.storage.GetAncestors(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if len(ancestors) == 0 || len(conversationNodes(ancestors)) < len(ancestors) || !VisibleTo(ctx, ancestors[0]) {
+			continue
+		}
+		path := make([]string, len(ancestors))
+		for i, a := range ancestors {
+			path[i] = a.ID
+		}
+		sources = append(sources, SearchMatch{
+			Node:    byID[id],
+			Path:    path,
+			Snippet: termsSnippet(byID[id].Content, terms),
+		})
+	}
+	return sources, nil
+}
+
+// conversationNodes returns nodes without the branches recorded by Ask and
+// AskAll.
+// nodes must list parents before their children.
+func conversationNodes(nodes []*types.Node) []*types.Node {
+	skipped := make(map[string]bool)
+	var kept []*types.Node
+	for _, n := range nodes {
+		if meta := types.UserMetadataFromNode(n); skipped[n.ParentID] || (meta != nil && meta.Meta) {
+			skipped[n.ID] = true
+			continue
+		}
+		kept = append(kept, n)
+	}
+	return kept
+}
+
+// askContext renders the DAG as labelled excerpts. When the whole DAG does
+// not fit in askContextBudget, it keeps the nodes scoring highest against
+// question, in their original order.
+func askContext(root *types.Node, nodes []*types.Node, question string) string {
+	total := 0
+	for _, n := range nodes {
+		total += len(n.Content)
+	}
+	selected := nodes
+	limit := 0
+	if total > askContextBudget {
+		limit = askExcerptLimit
+		selected = relevantNodes(nodes, question, askContextBudget, limit)
+	}
+
+	var b strings.Builder
+	if root.Title != "" {
+		fmt.Fprintf(&b, "Conversation: %s\n", root.Title)
+	}
+	if len(selected) < len(nodes) {
+		fmt.Fprintf(&b, "Excerpts: %d of %d messages, those most related to the question.\n", len(selected), len(nodes))
+	}
+	for _, n := range selected {
+		text := strings.TrimSpace(markdownContent(n.Content))
+		if limit > 0 && len(text) > limit {
+			text = text[:limit] + " [...]"
+		}
+		fmt.Fprintf(&b, "\n[%s] %s:\n%s\n", shortNodeID(n.ID), nodeTypeLabel(n.NodeType), text)
+	}
+	return b.String()
+}
+
+// relevantNodes picks the nodes sharing the most words with question until
+// budget characters (counting at most limit per node) are used, and returns
+// them in their original order.
+func relevantNodes(nodes []*types.Node, question string, budget, limit int) []*types.Node {
+	terms := askTerms(question)
+	scores := make([]int, len(nodes))
+	order := make([]int, len(nodes))
+	for i, n := range nodes {
+		content := strings.ToLower(n.Content)
+		for _, t := range terms {
+			scores[i] += strings.Count(content, t)
+		}
+		order[i] = i
+	}
+	// Highest score first; among equals, the most recent first.
+	sort.SliceStable(order, func(a, b int) bool {
+		if scores[order[a]] != scores[order[b]] {
+			return scores[order[a]] > scores[order[b]]
+		}
+		return order[a] > order[b]
+	})
+
+	picked := make([]bool, len(nodes))
+	used := 0
+	for _, i := range order {
+		size := min(len(nodes[i].Content), limit)
+		if used+size > budget {
+			continue
+		}
+		picked[i] = true
+		used += size
+	}
+	var selected []*types.Node
+	for i, n := range nodes {
+		if picked[i] {
+			selected = append(selected, n)
+		}
+	}
+	return selected
+}
+
+// askTerms returns the lowercased words of question that are long enough
+// to carry meaning.
+func askTerms(question string) []string {
+	words := strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var terms []string
+	for _, w := range words {
+		if len([]rune(w)) >= 4 {
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
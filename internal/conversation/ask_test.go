@@ -0,0 +1,135 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/types"
+)
+
+func TestAskRecordsMetaSideBranch(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "You picked Redis [abcd]."})
+	defer cleanup()
+	ctx := context.Background()
+
+	events, err := mgr.Prompt(ctx, "Should the cache use Redis or Memcached?", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := savedNodeID(t, events)
+
+	events, err = mgr.Ask(ctx, reply, "what did we decide about the cache?", "")
+	if err != nil {
+		t.Fatalf("Ask: %v", err)
+	}
+	answer, _ := mgr.storage.GetNode(ctx, savedNodeID(t, events))
+	question, _ := mgr.storage.GetNode(ctx, answer.ParentID)
+	if question.ParentID != answer.RootID || question.Content != "what did we decide about the cache?" {
+		t.Errorf("question is not a side branch off the root: %+v", question)
+	}
+	if meta := types.UserMetadataFromNode(question); meta == nil || !meta.Meta {
+		t.Errorf("question not marked meta: %s", question.Metadata)
+	}
+	// Sequence is depth, derived from the parent alone, so concurrent
+	// questions cannot race on it.
+	if question.Sequence != 1 || answer.Sequence != 2 {
+		t.Errorf("sequences = %d, %d; want 1, 2", question.Sequence, answer.Sequence)
+	}
+	if prov.LastRequest.Model != "mock-fast" || !strings.Contains(prov.LastRequest.System, "past conversation") {
+		t.Errorf("unexpected request: model %q, system %q", prov.LastRequest.Model, prov.LastRequest.System)
+	}
+	prompt := string(prov.LastRequest.Messages[0].Content)
+	if !strings.Contains(prompt, "Redis or Memcached") || !strings.Contains(prompt, "["+shortNodeID(reply)+"]") {
+		t.Errorf("context does not include the conversation: %s", prompt)
+	}
+
+	// A second question does not see the first one.
+	events, err = mgr.Ask(ctx, reply, "anything else?", "")
+	if err != nil {
+		t.Fatalf("Ask: %v", err)
+	}
+	_ = drainEvents(t, events, 5*time.Second)
+	if prompt := string(prov.LastRequest.Messages[0].Content); strings.Contains(prompt, "what did we decide") {
+		t.Errorf("context includes an earlier question: %s", prompt)
+	}
+}
+
+func TestRelevantNodesPrefersMatchingContent(t *testing.T) {
+	filler := strings.Repeat("unrelated words ", 20)
+	nodes := []*types.Node{
+		{ID: "n1", Content: filler},
+		{ID: "n2", Content: "We chose Postgres for the database. " + filler},
+		{ID: "n3", Content: filler},
+		{ID: "n4", Content: "The database migration runs nightly. " + filler},
+	}
+	selected := relevantNodes(nodes, "Which database did we choose?", 2*len(nodes[3].Content), askExcerptLimit)
+	if len(selected) != 2 || selected[0].ID != "n2" || selected[1].ID != "n4" {
+		ids := make([]string, len(selected))
+		for i, n := range selected {
+			ids[i] = n.ID
+		}
+		t.Errorf("selected %v, want [n2 n4]", ids)
+	}
+}
+
+func TestAskAllUsesMatchingNodesAsSources(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "The staging cluster runs on GKE."})
+	defer cleanup()
+	ctx := context.Background()
+
+	for _, msg := range []string{"Where does the staging cluster run?", "Plan the team offsite"} {
+		events, err := mgr.Prompt(ctx, msg, "mock-fast", "", nil, nil, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = drainEvents(t, events, 5*time.Second)
+	}
+
+	sources, events, err := mgr.AskAll(ctx, "what about the staging cluster?", "mock-fast")
+	if err != nil {
+		t.Fatalf("AskAll: %v", err)
+	}
+	answer, _ := mgr.storage.GetNode(ctx, savedNodeID(t, events))
+	if len(sources) == 0 {
+		t.Fatal("expected sources")
+	}
+	for _, src := range sources {
+		if strings.Contains(src.Node.Content, "offsite") {
+			t.Errorf("unrelated node used as a source: %q", src.Node.Content)
+		}
+	}
+	prompt := string(prov.LastRequest.Messages[0].Content)
+	if !strings.Contains(prompt, "["+shortNodeID(sources[0].Node.ID)+"]") {
+		t.Errorf("prompt does not label sources: %s", prompt)
+	}
+
+	root, _ := mgr.storage.GetNode(ctx, answer.ParentID)
+	if meta := types.UserMetadataFromNode(root); root.ParentID != "" || meta == nil || !meta.Meta {
+		t.Errorf("question not stored as a meta root: %+v", root)
+	}
+
+	// The recorded question and answer are never sources themselves.
+	sources, events, err = mgr.AskAll(ctx, "staging cluster", "mock-fast")
+	if err != nil {
+		t.Fatalf("AskAll: %v", err)
+	}
+	_ = drainEvents(t, events, 5*time.Second)
+	for _, src := range sources {
+		if src.Path[0] == root.ID {
+			t.Errorf("earlier question used as a source: %q", src.Node.Content)
+		}
+	}
+
+	// Nor are speculative nodes.
+	spec := &types.Node{ID: "spec", RootID: "spec", NodeType: types.NodeTypeUser, Content: "kubernetes upgrades", Status: SpeculativeStatus, CreatedAt: time.Now()}
+	if err := mgr.storage.CreateNode(ctx, spec); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := mgr.AskAll(ctx, "kubernetes upgrades", "mock-fast"); !errors.Is(err, ErrNoSources) {
+		t.Errorf("err = %v, want ErrNoSources", err)
+	}
+}
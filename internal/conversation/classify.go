@@ -0,0 +1,213 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"langdag.com/langdag/types"
+)
+
+// ClassifierOptions configures automatic tagging of DAGs by topic.
+type ClassifierOptions struct {
+	// Enabled tags each DAG in the background after its first completed
+	// reply. Classify can be called explicitly either way.
+	Enabled bool
+
+	// Rules maps a tag to keywords; a DAG whose messages contain any of
+	// the keywords (case-insensitive) gets the tag.
+	Rules map[string][]string
+
+	// Model, when set, is asked for up to maxModelTags topic tags in
+	// addition to the rules. Pick a cheap model.
+	Model string
+}
+
+const (
+	maxModelTags      = 3
+	classifyTextLimit = 8000
+	classifyTimeout   = time.Minute
+)
+
+const classifySystemPrompt = `You label conversations by topic. Reply with 1 to 3 short topic tags for the conversation below, comma-separated, lowercase, one or two words each (for example: databases, billing, go). Reply with the tags only.`
+
+// SetClassifierOptions configures automatic tagging.
+func (m *Manager) SetClassifierOptions(opts ClassifierOptions) {
+	m.classifier = opts
+}
+
+// Classify sets the tags of the DAG containing nodeID from the configured
+// rules and model, replacing any previous tags, and returns the updated
+// root. The reserved GuestTag is kept on guest DAGs and never added to
+// others.
+func (m *Manager) Classify(ctx context.Context, nodeID string) (*types.Node, error) {
+	node, err := m.storage.GetNode(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("node not found: %s", nodeID)
+	}
+	rootID := node.RootID
+	if rootID == "" {
+		rootID = node.ID
+	}
+	nodes, err := m.storage.GetSubtree(ctx, rootID)
+	if err != nil {
+		return nil, err
+	}
+	nodes = withoutSpeculative(nodes)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("node not found: %s", rootID)
+	}
+	root := nodes[0]
+	if root.ArchivedURI != "" {
+		return nil, fmt.Errorf("DAG %s is archived at %s", root.ID, root.ArchivedURI)
+	}
+
+	text := classifyText(nodes)
+	tags := ruleTags(m.classifier.Rules, text)
+	if m.classifier.Model != "" {
+		modelTags, err := m.modelTags(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, modelTags...)
+	}
+	tags = slices.DeleteFunc(normalizeTags(tags), func(tag string) bool { return tag == GuestTag })
+	if IsGuest(root) {
+		tags = normalizeTags(append(tags, GuestTag))
+	}
+	root.Tags = tags
+	if err := m.storage.UpdateNode(ctx, root); err != nil {
+		return nil, fmt.Errorf("failed to save tags: %w", err)
+	}
+	return root, nil
+}
+
+// classifyInBackground tags an untagged DAG after a completed reply, when
+// the classifier is enabled. Failures are logged, not reported.
+func (m *Manager) classifyInBackground(rootID string) {
+	if !m.classifier.Enabled || (len(m.classifier.Rules) == 0 && m.classifier.Model == "") {
+		return
+	}
+	m.background.Add(1)
+	go func() {
+		defer m.background.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), classifyTimeout)
+		defer cancel()
+		root, err := m.storage.GetNode(ctx, rootID)
+		if err != nil || root == nil || len(root.Tags) > 0 {
+			return
+		}
+		if _, err := m.Classify(ctx, rootID); err != nil {
+			slog.WarnContext(ctx, "classifier: failed to classify", "root_id", rootID, "error", err)
+		}
+	}()
+}
+
+// Wait blocks until background work started by the manager, such as
+// classification and title generation, has finished. Call it before closing the storage.
+func (m *Manager) Wait() {
+	m.background.Wait()
+}
+
+// classifyText joins the messages of a DAG, skipping meta branches and
+// summaries, cut to classifyTextLimit bytes.
+func classifyText(nodes []*types.Node) string {
+	var b strings.Builder
+	for _, n := range conversationNodes(nodes) {
+		if b.Len() >= classifyTextLimit {
+			break
+		}
+		if n.NodeType != types.NodeTypeUser && n.NodeType != types.NodeTypeAssistant {
+			continue
+		}
+		if text := strings.TrimSpace(markdownContent(n.Content)); text != "" {
+			fmt.Fprintf(&b, "%s: %s\n\n", n.NodeType, text)
+		}
+	}
+	text := b.String()
+	if len(text) > classifyTextLimit {
+		text = strings.ToValidUTF8(text[:classifyTextLimit], "")
+	}
+	return text
+}
+
+// ruleTags returns the tags whose keywords appear in text.
+func ruleTags(rules map[string][]string, text string) []string {
+	lower := strings.ToLower(text)
+	var tags []string
+	for tag, keywords := range rules {
+		for _, kw := range keywords {
+			if kw = strings.ToLower(strings.TrimSpace(kw)); kw != "" && strings.Contains(lower, kw) {
+				tags = append(tags, tag)
+				break
+			}
+		}
+	}
+	return tags
+}
+
+// modelTags asks the classifier model for topic tags.
+func (m *Manager) modelTags(ctx context.Context, text string) ([]string, error) {
+	model := m.classifier.Model
+	resp, err := m.provider.Complete(ctx, &types.CompletionRequest{
+		Model:     model,
+		System:    classifySystemPrompt,
+		MaxTokens: m.resolveMaxTokens(model, 64),
+		Messages: []types.Message{
+			{Role: "user", Content: contentToRawMessage(text)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to classify: %w", err)
+	}
+	var reply strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			reply.WriteString(block.Text)
+		}
+	}
+	tags := strings.FieldsFunc(reply.String(), func(r rune) bool { return r == ',' || r == '\n' })
+	if len(tags) > maxModelTags {
+		tags = tags[:maxModelTags]
+	}
+	return tags, nil
+}
+
+// normalizeTags lowercases tags, joins words with hyphens, drops other
+// punctuation and duplicates, and sorts the result.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, tag := range tags {
+		words := strings.FieldsFunc(strings.ToLower(tag), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '+' && r != '#'
+		})
+		tag = strings.Trim(strings.Join(words, "-"), "-")
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// HasTag reports whether node carries tag.
+func HasTag(node *types.Node, tag string) bool {
+	tag = strings.ToLower(tag)
+	for _, t := range node.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
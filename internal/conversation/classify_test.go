@@ -0,0 +1,103 @@
+package conversation
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"langdag.com/langdag/internal/provider/mock"
+)
+
+func TestClassifyCombinesRulesAndModelTags(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "Databases, Go Performance,\nbenchmarks, extra"})
+	defer cleanup()
+	ctx := context.Background()
+	mgr.SetClassifierOptions(ClassifierOptions{
+		Rules: map[string][]string{
+			"caching": {"Redis", "memcached"},
+			"billing": {"invoice"},
+		},
+		Model: "mock-fast",
+	})
+
+	events, err := mgr.Prompt(ctx, "Should the cache use Redis?", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := savedNodeID(t, events)
+
+	root, err := mgr.Classify(ctx, reply)
+	if err != nil {
+		t.Fatalf("Classify: %v", err)
+	}
+	want := []string{"benchmarks", "caching", "databases", "go-performance"}
+	if !reflect.DeepEqual(root.Tags, want) {
+		t.Errorf("Tags = %v, want %v", root.Tags, want)
+	}
+	if prov.LastRequest.System != classifySystemPrompt {
+		t.Errorf("unexpected classifier system prompt: %q", prov.LastRequest.System)
+	}
+	stored, _ := mgr.storage.GetNode(ctx, root.ID)
+	if !reflect.DeepEqual(stored.Tags, want) {
+		t.Errorf("stored Tags = %v, want %v", stored.Tags, want)
+	}
+}
+
+func TestClassifierTagsInBackgroundAfterReply(t *testing.T) {
+	mgr, cleanup := newTestManager(t, mock.Config{Mode: "fixed", FixedResponse: "Use Redis."})
+	defer cleanup()
+	ctx := context.Background()
+	mgr.SetClassifierOptions(ClassifierOptions{
+		Enabled: true,
+		Rules:   map[string][]string{"caching": {"redis"}},
+	})
+
+	events, err := mgr.Prompt(ctx, "Should the cache use Redis?", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, _ := mgr.storage.GetNode(ctx, savedNodeID(t, events))
+	mgr.Wait()
+
+	root, _ := mgr.storage.GetNode(ctx, reply.RootID)
+	if !reflect.DeepEqual(root.Tags, []string{"caching"}) {
+		t.Errorf("Tags = %v, want [caching]", root.Tags)
+	}
+	if !HasTag(root, "Caching") || HasTag(root, "billing") {
+		t.Errorf("HasTag does not match Tags %v", root.Tags)
+	}
+}
+
+func TestClassifyReservesGuestTag(t *testing.T) {
+	mgr, cleanup := newTestManager(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+	mgr.SetClassifierOptions(ClassifierOptions{Rules: map[string][]string{
+		"guest":   {"visitor"},
+		"caching": {"redis"},
+	}})
+
+	events, err := mgr.Prompt(ctx, "A visitor asks about Redis", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := mgr.Classify(ctx, savedNodeID(t, events))
+	if err != nil {
+		t.Fatalf("Classify: %v", err)
+	}
+	if !reflect.DeepEqual(root.Tags, []string{"caching"}) {
+		t.Errorf("member DAG tags = %v, want [caching]", root.Tags)
+	}
+
+	events, err = mgr.Prompt(ContextAsGuest(ctx), "How do I configure Redis?", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err = mgr.Classify(ctx, savedNodeID(t, events))
+	if err != nil {
+		t.Fatalf("Classify: %v", err)
+	}
+	if !reflect.DeepEqual(root.Tags, []string{"caching", "guest"}) || !IsGuest(root) {
+		t.Errorf("guest DAG tags = %v, want [caching guest]", root.Tags)
+	}
+}
@@ -0,0 +1,54 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"langdag.com/langdag/types"
+)
+
+// Clone copies the path from the root of nodeID's DAG down to nodeID into a
+// new DAG and returns the copied nodes, root first. Sibling branches are
+// not copied. The new root records the source DAG and node in
+// ForkedFromDAG and ForkedFromNode, and keeps its visibility; the DAG is
+// owned by the context's owner.
+func (m *Manager) Clone(ctx context.Context, nodeID string) ([]*types.Node, error) {
+	ancestors, err := m.storage.GetAncestors(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ancestors) == 0 {
+		return nil, fmt.Errorf("node not found: %s", nodeID)
+	}
+	if ancestors[0].ArchivedURI != "" {
+		return nil, fmt.Errorf("DAG %s is archived at %s", ancestors[0].ID, ancestors[0].ArchivedURI)
+	}
+
+	now := time.Now()
+	rootID := m.newID()
+	copies := make([]*types.Node, len(ancestors))
+	for i, src := range ancestors {
+		n := *src
+		n.ID = m.newID()
+		n.RootID = rootID
+		n.CreatedAt = now
+		if i == 0 {
+			n.ID = rootID
+			n.ForkedFromDAG = src.ID
+			n.ForkedFromNode = nodeID
+			setOwner(&n, contextOwner(ctx))
+		} else {
+			n.ParentID = copies[i-1].ID
+			n.ForkedFromDAG = ""
+			n.ForkedFromNode = ""
+		}
+		copies[i] = &n
+	}
+
+	dag := &DAGExport{Version: exportVersion, Nodes: copies}
+	if err := m.restoreNodes(ctx, dag, copies); err != nil {
+		return nil, err
+	}
+	return copies, nil
+}
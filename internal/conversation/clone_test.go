@@ -0,0 +1,69 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+
+	"langdag.com/langdag/internal/provider/mock"
+)
+
+func TestCloneCopiesPathIntoNewDAG(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+	for _, n := range exportTestNodes() {
+		if err := store.CreateNode(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err := mgr.Clone(ctx, "u2")
+	if err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	if len(path) != 3 {
+		t.Fatalf("cloned %d nodes, want 3", len(path))
+	}
+	root := path[0]
+	if root.ID == "root" || root.ParentID != "" || root.RootID != root.ID {
+		t.Errorf("unexpected new root: %+v", root)
+	}
+	if root.ForkedFromDAG != "root" || root.ForkedFromNode != "u2" {
+		t.Errorf("lineage = %q/%q, want root/u2", root.ForkedFromDAG, root.ForkedFromNode)
+	}
+	if root.Title != "Math" || root.SystemPrompt != "Be brief" {
+		t.Errorf("root fields not copied: %+v", root)
+	}
+
+	nodes, err := store.GetSubtree(ctx, root.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 3 {
+		t.Fatalf("new DAG has %d nodes, want 3 (siblings must not be copied)", len(nodes))
+	}
+	for i, n := range nodes[1:] {
+		if n.ParentID != nodes[i].ID || n.RootID != root.ID {
+			t.Errorf("node %d not chained into the new DAG: %+v", i+1, n)
+		}
+		if n.ForkedFromDAG != "" {
+			t.Errorf("lineage set on non-root node %s", n.ID)
+		}
+	}
+	if nodes[1].Content != exportTestNodes()[1].Content {
+		t.Errorf("assistant content not copied: %q", nodes[1].Content)
+	}
+
+	src, _ := store.GetSubtree(ctx, "root")
+	if len(src) != 4 {
+		t.Errorf("source DAG has %d nodes, want 4", len(src))
+	}
+}
+
+func TestCloneUnknownNode(t *testing.T) {
+	mgr, _, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	if _, err := mgr.Clone(context.Background(), "missing"); err == nil {
+		t.Error("expected an error for an unknown node")
+	}
+}
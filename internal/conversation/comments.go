@@ -0,0 +1,98 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"langdag.com/langdag/types"
+)
+
+// ErrInvalidComment is returned by AddComment for empty comments and
+// replies to comments on other nodes.
+var ErrInvalidComment = errors.New("invalid comment")
+
+// AddComment leaves a comment by author on nodeID. With replyTo, the
+// comment answers that comment, which must be on the same node.
+func (m *Manager) AddComment(ctx context.Context, nodeID, replyTo, author, body string) (*types.Comment, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, fmt.Errorf("%w: body must not be empty", ErrInvalidComment)
+	}
+	node, err := m.storage.GetNode(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("node not found: %s", nodeID)
+	}
+	rootID := node.RootID
+	if rootID == "" {
+		rootID = node.ID
+	}
+	if replyTo != "" {
+		comments, err := m.storage.ListComments(ctx, rootID)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, c := range comments {
+			found = found || (c.ID == replyTo && c.NodeID == node.ID)
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: no comment %s on node %s", ErrInvalidComment, replyTo, node.ID)
+		}
+	}
+
+	comment := &types.Comment{
+		ID:        m.newID(),
+		NodeID:    node.ID,
+		RootID:    rootID,
+		ReplyTo:   replyTo,
+		Author:    author,
+		Body:      body,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := m.storage.CreateComment(ctx, comment); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// Comments returns the comments on nodeID and its descendants, oldest
+// first: on a root, every comment of the DAG.
+func (m *Manager) Comments(ctx context.Context, nodeID string) ([]*types.Comment, error) {
+	node, err := m.storage.GetNode(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("node not found: %s", nodeID)
+	}
+	rootID := node.RootID
+	if rootID == "" {
+		rootID = node.ID
+	}
+	comments, err := m.storage.ListComments(ctx, rootID)
+	if err != nil || rootID == node.ID {
+		return comments, err
+	}
+
+	subtree, err := m.storage.GetSubtree(ctx, node.ID)
+	if err != nil {
+		return nil, err
+	}
+	inSubtree := make(map[string]bool, len(subtree))
+	for _, n := range subtree {
+		inSubtree[n.ID] = true
+	}
+	var kept []*types.Comment
+	for _, c := range comments {
+		if inSubtree[c.NodeID] {
+			kept = append(kept, c)
+		}
+	}
+	return kept, nil
+}
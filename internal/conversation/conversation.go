@@ -4,22 +4,61 @@ package conversation
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"langdag.com/langdag/internal/archive"
 	"langdag.com/langdag/internal/models"
 	"langdag.com/langdag/internal/provider"
 	"langdag.com/langdag/internal/storage"
+	"langdag.com/langdag/internal/toolbox"
+	"langdag.com/langdag/internal/tracing"
 	"langdag.com/langdag/types"
 )
 
 // Manager handles conversation operations using the unified node model.
 type Manager struct {
-	storage  storage.Storage
-	provider provider.Provider
+	storage       storage.Storage
+	provider      provider.Provider
+	metadataOpts  MetadataOptions
+	maxTokensOpts MaxTokensOptions
+	presets       map[string]Preset
+
+	globalSystemPrompt string
+	defaultLanguage    string
+	glossary           []glossaryTerm
+	injectionScan      *injectionScanner
+	classifier         ClassifierOptions
+	titles             TitleOptions
+	speculation        SpeculationOptions
+	embeddings         EmbeddingOptions
+	shortIDs           *shortIDGenerator // nil for UUIDs
+	pricing            map[string]ModelPrice
+
+	toolbox *toolbox.Toolbox // built-in tools; nil for none
+
+	archive   archive.Store
+	archiveMu sync.Mutex // serializes rehydration
+	trash     TrashOptions
+
+	runsMu            sync.Mutex
+	runs              map[string]map[*activeRun]struct{} // root ID -> in-flight generations
+	generationTimeout time.Duration                      // 0 for none
+
+	maxOutputCache sync.Map // model ID -> catalog MaxOutput (int)
+
+	specMu    sync.Mutex
+	specBusy  bool   // a speculation is running
+	specDay   string // UTC day of specSpent
+	specSpent int    // tokens spent on speculation on specDay
+
+	background sync.WaitGroup // background classification, titling and embedding
 }
 
 var (
@@ -45,7 +84,10 @@ func (m *Manager) Prompt(ctx context.Context, message, model, systemPrompt strin
 // PromptWithAPIProtocol starts a new conversation while requesting a specific
 // provider API protocol when the selected provider supports more than one.
 func (m *Manager) PromptWithAPIProtocol(ctx context.Context, message, model, apiProtocolID, systemPrompt string, tools []types.ToolDefinition, think *bool, maxTokens, maxOutputGroupTokens int) (<-chan types.StreamEvent, error) {
-	rootID := uuid.New().String()
+	if err := m.CheckTools(ctx, tools); err != nil {
+		return nil, err
+	}
+	rootID := m.newID()
 	rootNode := &types.Node{
 		ID:           rootID,
 		RootID:       rootID,
@@ -56,8 +98,18 @@ func (m *Manager) PromptWithAPIProtocol(ctx context.Context, message, model, api
 		Status:       "completed",
 		Title:        GenerateTitle(message),
 		SystemPrompt: systemPrompt,
+		Tags:         contextTags(ctx),
 		CreatedAt:    time.Now(),
 	}
+	meta := types.UserNodeMetadata{Language: contextLanguage(ctx), Sampling: requestSampling(ctx, maxTokens), Guest: IsGuestContext(ctx)}
+	if meta.Guest {
+		rootNode.Tags = normalizeTags(append(rootNode.Tags, GuestTag))
+	}
+	if meta.Language != "" || meta.Sampling != nil || meta.Guest {
+		rootNode.Metadata, _ = json.Marshal(meta)
+	}
+	setOwner(rootNode, contextOwner(ctx))
+	m.annotateGlossary(rootNode)
 	if err := m.storage.CreateNode(ctx, rootNode); err != nil {
 		return nil, fmt.Errorf("failed to create root node: %w", err)
 	}
@@ -66,7 +118,8 @@ func (m *Manager) PromptWithAPIProtocol(ctx context.Context, message, model, api
 		{Role: "user", Content: contentToRawMessage(message)},
 	}
 
-	return m.streamResponse(ctx, rootNode, messages, model, apiProtocolID, systemPrompt, tools, think, maxTokens, maxOutputGroupTokens)
+	events, err := m.streamResponse(ctx, rootNode, messages, model, apiProtocolID, systemPrompt, tools, think, maxTokens, maxOutputGroupTokens)
+	return m.discardUnanswered(ctx, rootNode, events, err)
 }
 
 // PromptFrom continues a conversation from an existing node.
@@ -91,30 +144,42 @@ func (m *Manager) PromptFromWithAPIProtocol(ctx context.Context, parentNodeID, m
 	root := ancestors[0]
 	lastNode := ancestors[len(ancestors)-1]
 
-	// Determine model (request override > root default)
+	if reply := m.takeSpeculation(ctx, parentNodeID, message, model, tools); reply != nil {
+		return replayNode(reply), nil
+	}
+
+	// Determine model and tools (request override > branch model > root default)
+	requestedModel := model
 	if model == "" {
-		model = root.Model
+		model = branchModel(ancestors)
+	}
+	if tools == nil {
+		tools = dagTools(root)
+	}
+	if err := m.CheckTools(ctx, tools); err != nil {
+		return nil, err
 	}
 
 	// Create user node as child of parentNode
 	userNode := &types.Node{
-		ID:        uuid.New().String(),
+		ID:        m.newID(),
 		ParentID:  parentNodeID,
 		RootID:    root.ID,
 		Sequence:  lastNode.Sequence + 1,
 		NodeType:  types.NodeTypeUser,
 		Content:   message,
+		Model:     requestedModel,
 		Status:    "completed",
 		CreatedAt: time.Now(),
 	}
-	if err := m.storage.CreateNode(ctx, userNode); err != nil {
-		return nil, fmt.Errorf("failed to create user node: %w", err)
+	if err := m.checkInjection(ctx, userNode, message); err != nil {
+		return nil, err
 	}
-
+	m.annotateGlossary(userNode)
 	// Index any tool_result IDs in the new user message so future queries
 	// can detect orphaned tool_use blocks without parsing JSON content.
-	if resultIDs := extractToolResultIDsFromContent(message); len(resultIDs) > 0 {
-		_ = m.storage.IndexToolIDs(ctx, userNode.ID, resultIDs, "result")
+	if err := m.saveNode(ctx, userNode, extractToolResultIDsFromContent(message), "result"); err != nil {
+		return nil, fmt.Errorf("failed to create user node: %w", err)
 	}
 
 	// Fix orphaned tool_use blocks: query the DB index (not message JSON)
@@ -127,7 +192,7 @@ func (m *Manager) PromptFromWithAPIProtocol(ctx context.Context, parentNodeID, m
 	ancestorIDs[len(ancestors)] = userNode.ID
 	orphans, err := m.storage.GetOrphanedToolUses(ctx, ancestorIDs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check orphaned tool uses: %w", err)
+		return m.discardUnanswered(ctx, userNode, nil, fmt.Errorf("failed to check orphaned tool uses: %w", err))
 	}
 	if len(orphans) > 0 {
 		ancestors = injectSyntheticToolResults(ancestors, orphans)
@@ -147,7 +212,8 @@ func (m *Manager) PromptFromWithAPIProtocol(ctx context.Context, parentNodeID, m
 		})
 	}
 
-	return m.streamResponse(ctx, userNode, messages, model, apiProtocolID, root.SystemPrompt, tools, think, maxTokens, maxOutputGroupTokens)
+	events, err := m.streamResponse(ctx, userNode, messages, model, apiProtocolID, root.SystemPrompt, tools, think, maxTokens, maxOutputGroupTokens)
+	return m.discardUnanswered(ctx, userNode, events, err)
 }
 
 // injectSyntheticToolResults inserts synthetic tool_result nodes into the
@@ -210,42 +276,167 @@ const defaultOutputGroupBudgetMultiplier = 4
 const defaultMaxTokens = 16384
 
 // streamResponse sends messages to the LLM and wraps the provider events,
-// saving the assistant node when the stream completes.
+// saving the assistant node when the stream completes. Built-in tools called
+// by the model are run and answered on the same stream (see SetToolbox).
+func (m *Manager) streamResponse(ctx context.Context, parentNode *types.Node, messages []types.Message, model, apiProtocolID, systemPrompt string, tools []types.ToolDefinition, think *bool, maxTokens, maxOutputGroupTokens int) (<-chan types.StreamEvent, error) {
+	tools, builtin, err := m.resolveTools(ctx, tools)
+	if err != nil {
+		return nil, err
+	}
+	if len(builtin) == 0 {
+		return m.generate(ctx, parentNode, messages, model, apiProtocolID, systemPrompt, tools, think, maxTokens, maxOutputGroupTokens)
+	}
+	// The generation timeout covers the whole tool loop, tools included,
+	// not each of its generations.
+	ctx, stop := m.withGenerationTimeout(ctx)
+	events, err := m.generate(ctx, parentNode, messages, model, apiProtocolID, systemPrompt, tools, think, maxTokens, maxOutputGroupTokens)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	return m.runBuiltinTools(ctx, stop, events, builtin, func(parent *types.Node) (<-chan types.StreamEvent, error) {
+		ancestors, err := m.storage.GetAncestors(ctx, parent.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get ancestors: %w", err)
+		}
+		return m.generate(ctx, parent, buildMessages(ancestors), model, apiProtocolID, systemPrompt, tools, think, maxTokens, maxOutputGroupTokens)
+	}), nil
+}
+
+// discardUnanswered returns the stream of the reply to node, a message a
+// prompt just saved, and deletes node if the generation fails before a
+// reply is saved, so that a failed prompt leaves no unanswered message
+// behind. A cancelled generation keeps node, as the user stopped it.
+// Replies saved without a node_saved event, such as the tool calls
+// answered by built-in tools or the parts of an output group before the
+// failure, are looked up in storage and also keep node.
+func (m *Manager) discardUnanswered(ctx context.Context, node *types.Node, events <-chan types.StreamEvent, err error) (<-chan types.StreamEvent, error) {
+	discard := func() {
+		if err := m.storage.DeleteNode(context.WithoutCancel(ctx), node.ID); err != nil {
+			slog.WarnContext(ctx, "conversation: failed to discard unanswered message", "node_id", node.ID, "error", err)
+		}
+	}
+	if err != nil {
+		discard()
+		return nil, err
+	}
+
+	out := make(chan types.StreamEvent, 100)
+	go func() {
+		defer close(out)
+		var saved, failed bool
+		for event := range events {
+			switch event.Type {
+			case types.StreamEventNodeSaved:
+				saved = true
+			case types.StreamEventError:
+				failed = event.Error != nil && !errors.Is(event.Error, context.Canceled) && !errors.Is(event.Error, ErrCancelled)
+			}
+			out <- event
+		}
+		// The node is deleted before the stream closes, so that callers
+		// reading it to the end see the DAG without it.
+		if failed && !saved && !m.hasChildren(ctx, node.ID) {
+			discard()
+		}
+	}()
+	return out, nil
+}
+
+// hasChildren reports whether anything was saved under the node id. On
+// error it reports true, so that nothing is deleted on a guess.
+func (m *Manager) hasChildren(ctx context.Context, id string) bool {
+	children, err := m.storage.GetNodeChildren(context.WithoutCancel(ctx), id)
+	return err != nil || len(children) > 0
+}
+
+// saveNode creates node and indexes its tool IDs under role in one
+// transaction, so that orphan detection never sees the node unindexed.
+func (m *Manager) saveNode(ctx context.Context, node *types.Node, toolIDs []string, role string) error {
+	return m.storage.WithTx(ctx, func(tx storage.NodeWriter) error {
+		if err := tx.CreateNode(ctx, node); err != nil {
+			return err
+		}
+		return tx.IndexToolIDs(ctx, node.ID, toolIDs, role)
+	})
+}
+
+// requestInfo is what a reply records of the request that generated it.
+type requestInfo struct {
+	lang     string                // reply language, "" for none
+	layered  bool                  // the system prompt differs from the DAG's
+	sampling *types.SamplingParams // parameters to record, nil for none
+}
+
+// buildRequest builds the request answering parent, the last of messages,
+// as every generation does: with the request's sampling parameters or else
+// the DAG's, and the system prompt layered with the reply language and the
+// glossary terms of parent. It returns ctx carrying the sampling
+// parameters used.
+func (m *Manager) buildRequest(ctx context.Context, parent *types.Node, messages []types.Message, model, systemPrompt string, maxTokens int) (context.Context, *types.CompletionRequest, requestInfo) {
+	ctx, maxTokens, sampling := m.dagSampling(ctx, parent, maxTokens)
+	info := requestInfo{sampling: sampling, layered: m.globalSystemPrompt != ""}
+	systemPrompt = m.effectiveSystemPrompt(systemPrompt)
+	info.lang = m.replyLanguage(ctx, parent)
+	if info.lang != "" {
+		systemPrompt = withInstruction(systemPrompt, languageInstruction(info.lang, false))
+		info.layered = true
+	}
+	if glossary := m.glossaryInstruction(parent); glossary != "" {
+		systemPrompt = withInstruction(systemPrompt, glossary)
+		info.layered = true
+	}
+	return ctx, &types.CompletionRequest{
+		Model:       model,
+		Messages:    messages,
+		System:      systemPrompt,
+		MaxTokens:   m.resolveMaxTokens(model, maxTokens),
+		Temperature: requestTemperature(ctx),
+		StopSeqs:    contextStopSequences(ctx),
+		Metadata:    m.requestMetadata(ctx),
+	}, info
+}
+
+// generate sends messages to the LLM and wraps the provider events, saving
+// the assistant node when the stream completes.
 //
-// When the model hits max_tokens with usable text-only content, streamResponse
+// When the model hits max_tokens with usable text-only content, generate
 // automatically continues generation: it saves the partial node with a shared
 // OutputGroupID, issues a continuation call with the accumulated text as
 // assistant prefill, and keeps streaming on the same channel. Each continuation
 // node stores all accumulated content (self-contained). Continuation stops when
 // the model finishes (end_turn/tool_use), when the cumulative output tokens
 // exceed the group budget, or when a continuation produces no new content.
-func (m *Manager) streamResponse(ctx context.Context, parentNode *types.Node, messages []types.Message, model, apiProtocolID, systemPrompt string, tools []types.ToolDefinition, think *bool, maxTokens, maxOutputGroupTokens int) (<-chan types.StreamEvent, error) {
-	if maxTokens <= 0 {
-		maxTokens = defaultMaxTokens
-	}
-	req := &types.CompletionRequest{
-		Model:         model,
-		Messages:      messages,
-		System:        systemPrompt,
-		MaxTokens:     maxTokens,
-		Tools:         tools,
-		Think:         think,
-		APIProtocolID: apiProtocolID,
-	}
-
-	providerEvents, err := m.provider.Stream(ctx, req)
+func (m *Manager) generate(ctx context.Context, parentNode *types.Node, messages []types.Message, model, apiProtocolID, systemPrompt string, tools []types.ToolDefinition, think *bool, maxTokens, maxOutputGroupTokens int) (<-chan types.StreamEvent, error) {
+	ctx, req, info := m.buildRequest(ctx, parentNode, messages, model, systemPrompt, maxTokens)
+	req.Tools, req.Think, req.APIProtocolID = tools, think, apiProtocolID
+	ctx, release := m.trackRun(ctx, parentNode, model)
+	ctx, span := tracing.Tracer().Start(ctx, "conversation.generate", trace.WithAttributes(
+		attribute.String("langdag.root_id", parentNode.RootID),
+		attribute.String("langdag.parent_id", parentNode.ID),
+		attribute.String("gen_ai.request.model", model),
+	))
+	untrack := release
+	release = func() {
+		span.End()
+		untrack()
+	}
+	providerEvents, err := m.streamInLanguage(ctx, req, info.lang)
 	if err != nil {
+		tracing.End(span, err)
+		untrack()
 		return nil, fmt.Errorf("failed to stream response: %w", err)
 	}
 
 	groupBudget := maxOutputGroupTokens
 	if groupBudget <= 0 {
-		groupBudget = maxTokens * defaultOutputGroupBudgetMultiplier
+		groupBudget = req.MaxTokens * defaultOutputGroupBudgetMultiplier
 	}
 
 	events := make(chan types.StreamEvent, 100)
 	go func() {
 		defer close(events)
+		defer release()
 
 		var (
 			groupID                string
@@ -266,6 +457,13 @@ func (m *Manager) streamResponse(ctx context.Context, parentNode *types.Node, me
 
 			for event := range currentStream {
 				switch event.Type {
+				case types.StreamEventError:
+					// A cancelled caller gets the partial text saved below
+					// instead of the provider's cancellation error.
+					if ctx.Err() != nil {
+						continue
+					}
+					slog.WarnContext(ctx, "conversation: generation failed", "root_id", parentNode.RootID, "model", model, "error", event.Error)
 				case types.StreamEventDelta:
 					fullText += event.Content
 				case types.StreamEventDone:
@@ -285,8 +483,24 @@ func (m *Manager) streamResponse(ctx context.Context, parentNode *types.Node, me
 				events <- event
 			}
 
+			// The caller cancelled mid-generation (or CancelTree or the
+			// generation timeout stopped it). Persist whatever was streamed
+			// so far so the output isn't lost.
+			interrupted := response == nil && ctx.Err() != nil
+			timedOut := interrupted && errors.Is(context.Cause(ctx), ErrGenerationTimeout)
+			if interrupted && fullText == "" && lastSavedNodeID == "" {
+				if timedOut {
+					events <- types.StreamEvent{Type: types.StreamEventTimeout}
+				}
+				events <- types.StreamEvent{Type: types.StreamEventError, Error: context.Cause(ctx)}
+				return
+			}
+
 			// Empty stream — nothing to save.
 			if response == nil && fullText == "" {
+				if timedOut {
+					events <- types.StreamEvent{Type: types.StreamEventTimeout, NodeID: lastSavedNodeID}
+				}
 				if lastSavedNodeID != "" {
 					events <- types.StreamEvent{Type: types.StreamEventNodeSaved, NodeID: lastSavedNodeID}
 				}
@@ -320,7 +534,7 @@ func (m *Manager) streamResponse(ctx context.Context, parentNode *types.Node, me
 
 			// Assign a group ID on the first continuation.
 			if shouldContinue && groupID == "" {
-				groupID = uuid.New().String()
+				groupID = m.newID()
 			}
 
 			// Determine stored content. Continuation nodes store accumulated
@@ -334,7 +548,7 @@ func (m *Manager) streamResponse(ctx context.Context, parentNode *types.Node, me
 			}
 
 			assistantNode := &types.Node{
-				ID:            uuid.New().String(),
+				ID:            m.newID(),
 				ParentID:      currentParent.ID,
 				RootID:        currentParent.RootID,
 				Sequence:      currentParent.Sequence + 1,
@@ -346,6 +560,16 @@ func (m *Manager) streamResponse(ctx context.Context, parentNode *types.Node, me
 				LatencyMs:     int(time.Since(startTime).Milliseconds()),
 				CreatedAt:     time.Now(),
 			}
+			// Record the layered prompt this generation actually used; the
+			// root keeps only the DAG-level prompt.
+			if info.layered {
+				assistantNode.SystemPrompt = req.System
+			}
+			saveCtx := ctx
+			if interrupted {
+				assistantNode.Status = interruptedStatus(ctx)
+				saveCtx = context.WithoutCancel(ctx)
+			}
 			if response != nil {
 				assistantNode.Provider = response.Provider
 				assistantNode.StopReason = response.StopReason
@@ -356,30 +580,39 @@ func (m *Manager) streamResponse(ctx context.Context, parentNode *types.Node, me
 				assistantNode.TokensReasoning = response.Usage.ReasoningTokens
 				assistantNode.Metadata = assistantMetadataJSON(response)
 			}
-			if err := m.storage.CreateNode(ctx, assistantNode); err != nil {
-				events <- types.StreamEvent{
-					Type:  types.StreamEventError,
-					Error: fmt.Errorf("failed to save assistant node: %w", err),
-				}
-				return
-			}
-
+			assistantNode.Metadata = withSampling(assistantNode.Metadata, info.sampling)
 			// Index tool_use IDs so orphan detection uses DB queries, not JSON parsing.
+			var toolUseIDs []string
 			if response != nil {
-				var toolUseIDs []string
 				for _, block := range response.Content {
 					if block.Type == "tool_use" && block.ID != "" {
 						toolUseIDs = append(toolUseIDs, block.ID)
 					}
 				}
-				if len(toolUseIDs) > 0 {
-					_ = m.storage.IndexToolIDs(ctx, assistantNode.ID, toolUseIDs, "use")
+			}
+			if err := m.saveNode(saveCtx, assistantNode, toolUseIDs, "use"); err != nil {
+				events <- types.StreamEvent{
+					Type:  types.StreamEventError,
+					Error: fmt.Errorf("failed to save assistant node: %w", err),
 				}
+				return
 			}
 
 			lastSavedNodeID = assistantNode.ID
+			span.SetAttributes(attribute.String("langdag.node_id", assistantNode.ID))
 
 			if !shouldContinue {
+				if timedOut {
+					events <- types.StreamEvent{Type: types.StreamEventTimeout, NodeID: assistantNode.ID}
+				}
+				slog.DebugContext(ctx, "conversation: reply saved", "root_id", assistantNode.RootID,
+					"node_id", assistantNode.ID, "model", model, "interrupted", interrupted)
+				if !interrupted {
+					m.classifyInBackground(assistantNode.RootID)
+					m.titleInBackground(parentNode, assistantNode)
+					m.speculateInBackground(parentNode, assistantNode)
+					m.embedInBackground(parentNode, assistantNode)
+				}
 				events <- types.StreamEvent{
 					Type:   types.StreamEventNodeSaved,
 					NodeID: assistantNode.ID,
@@ -403,11 +636,14 @@ func (m *Manager) streamResponse(ctx context.Context, parentNode *types.Node, me
 			contReq := &types.CompletionRequest{
 				Model:         model,
 				Messages:      contMessages,
-				System:        systemPrompt,
-				MaxTokens:     maxTokens,
+				System:        req.System,
+				MaxTokens:     req.MaxTokens,
+				Temperature:   req.Temperature,
+				StopSeqs:      req.StopSeqs,
 				Tools:         tools,
 				Think:         think,
 				APIProtocolID: apiProtocolID,
+				Metadata:      req.Metadata,
 			}
 
 			var contErr error
@@ -612,6 +848,21 @@ func contentToRawMessage(content string) json.RawMessage {
 
 // ResolveNode finds a node by exact ID, prefix match, or alias.
 func (m *Manager) ResolveNode(ctx context.Context, idOrPrefix string) (*types.Node, error) {
+	node, err := m.LookupNode(ctx, idOrPrefix)
+	if err != nil || node == nil {
+		return node, err
+	}
+	// An archived DAG is restored on first access to its root.
+	if node.ArchivedURI != "" && m.archive != nil {
+		return m.rehydrate(ctx, node)
+	}
+	return node, nil
+}
+
+// LookupNode is ResolveNode without restoring archived DAGs: an archived
+// root is returned as its stub. Access checks use it, so that requests
+// denied access don't restore DAGs.
+func (m *Manager) LookupNode(ctx context.Context, idOrPrefix string) (*types.Node, error) {
 	// Try exact ID
 	node, err := m.storage.GetNode(ctx, idOrPrefix)
 	if err != nil {
@@ -656,11 +907,22 @@ func (m *Manager) ListRoots(ctx context.Context) ([]*types.Node, error) {
 
 // GetSubtree returns a node and all its descendants.
 func (m *Manager) GetSubtree(ctx context.Context, nodeID string) ([]*types.Node, error) {
-	return m.storage.GetSubtree(ctx, nodeID)
+	nodes, err := m.storage.GetSubtree(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	return withoutSpeculative(nodes), nil
 }
 
-// DeleteNode deletes a node and its subtree.
+// DeleteNode deletes a node and its subtree. With a trash directory set,
+// the whole DAG is exported there first, and the deletion is refused if
+// that fails.
 func (m *Manager) DeleteNode(ctx context.Context, id string) error {
+	if m.trash.Dir != "" {
+		if err := m.moveToTrash(ctx, id); err != nil {
+			return fmt.Errorf("failed to save %s to the trash: %w", id, err)
+		}
+	}
 	return m.storage.DeleteNode(ctx, id)
 }
 
@@ -711,3 +973,15 @@ func GenerateTitle(text string) string {
 	}
 	return text
 }
+
+// branchModel returns the model last requested explicitly among ancestors,
+// recorded on the user node of the request, so that a model chosen for one
+// prompt is kept by the following ones; otherwise the DAG's model.
+func branchModel(ancestors []*types.Node) string {
+	for i := len(ancestors) - 1; i > 0; i-- {
+		if n := ancestors[i]; n.NodeType == types.NodeTypeUser && n.Model != "" {
+			return n.Model
+		}
+	}
+	return ancestors[0].Model
+}
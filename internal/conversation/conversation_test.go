@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/internal/storage"
 	"langdag.com/langdag/internal/storage/sqlite"
 	"langdag.com/langdag/types"
 )
@@ -801,6 +802,7 @@ type Storage = interface {
 	GetSubtree(ctx context.Context, nodeID string) ([]*types.Node, error)
 	GetAncestors(ctx context.Context, nodeID string) ([]*types.Node, error)
 	ListRootNodes(ctx context.Context) ([]*types.Node, error)
+	SearchNodes(ctx context.Context, query string, limit int) ([]*types.Node, error)
 	UpdateNode(ctx context.Context, node *types.Node) error
 	DeleteNode(ctx context.Context, id string) error
 	CreateAlias(ctx context.Context, nodeID, alias string) error
@@ -809,6 +811,17 @@ type Storage = interface {
 	ListAliases(ctx context.Context, nodeID string) ([]string, error)
 	IndexToolIDs(ctx context.Context, nodeID string, toolIDs []string, role string) error
 	GetOrphanedToolUses(ctx context.Context, ancestorIDs []string) (map[string][]string, error)
+	CreateAPIKey(ctx context.Context, key *types.APIKey) error
+	GetAPIKeyByHash(ctx context.Context, hash string) (*types.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]*types.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string, at time.Time) error
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+	CreateComment(ctx context.Context, comment *types.Comment) error
+	ListComments(ctx context.Context, rootID string) ([]*types.Comment, error)
+	SaveEmbedding(ctx context.Context, nodeID, model string, vector []float32) error
+	SearchEmbeddings(ctx context.Context, model string, vector []float32, limit int) ([]types.EmbeddingMatch, error)
+	ListUnembeddedNodes(ctx context.Context, model string, limit int) ([]*types.Node, error)
+	WithTx(ctx context.Context, fn func(tx storage.NodeWriter) error) error
 }
 
 func (f *failingStorage) Init(ctx context.Context) error { return f.inner.Init(ctx) }
@@ -849,12 +862,45 @@ func (f *failingStorage) GetNodeByAlias(ctx context.Context, a string) (*types.N
 func (f *failingStorage) ListAliases(ctx context.Context, id string) ([]string, error) {
 	return f.inner.ListAliases(ctx, id)
 }
+func (f *failingStorage) SearchNodes(ctx context.Context, q string, limit int) ([]*types.Node, error) {
+	return f.inner.SearchNodes(ctx, q, limit)
+}
 func (f *failingStorage) IndexToolIDs(ctx context.Context, nodeID string, toolIDs []string, role string) error {
 	return f.inner.IndexToolIDs(ctx, nodeID, toolIDs, role)
 }
 func (f *failingStorage) GetOrphanedToolUses(ctx context.Context, ancestorIDs []string) (map[string][]string, error) {
 	return f.inner.GetOrphanedToolUses(ctx, ancestorIDs)
 }
+func (f *failingStorage) CreateAPIKey(ctx context.Context, key *types.APIKey) error {
+	return f.inner.CreateAPIKey(ctx, key)
+}
+func (f *failingStorage) GetAPIKeyByHash(ctx context.Context, hash string) (*types.APIKey, error) {
+	return f.inner.GetAPIKeyByHash(ctx, hash)
+}
+func (f *failingStorage) ListAPIKeys(ctx context.Context) ([]*types.APIKey, error) {
+	return f.inner.ListAPIKeys(ctx)
+}
+func (f *failingStorage) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	return f.inner.RevokeAPIKey(ctx, id, at)
+}
+func (f *failingStorage) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	return f.inner.TouchAPIKey(ctx, id, at)
+}
+func (f *failingStorage) CreateComment(ctx context.Context, comment *types.Comment) error {
+	return f.inner.CreateComment(ctx, comment)
+}
+func (f *failingStorage) ListComments(ctx context.Context, rootID string) ([]*types.Comment, error) {
+	return f.inner.ListComments(ctx, rootID)
+}
+func (f *failingStorage) SaveEmbedding(ctx context.Context, nodeID, model string, vector []float32) error {
+	return f.inner.SaveEmbedding(ctx, nodeID, model, vector)
+}
+func (f *failingStorage) SearchEmbeddings(ctx context.Context, model string, vector []float32, limit int) ([]types.EmbeddingMatch, error) {
+	return f.inner.SearchEmbeddings(ctx, model, vector, limit)
+}
+func (f *failingStorage) ListUnembeddedNodes(ctx context.Context, model string, limit int) ([]*types.Node, error) {
+	return f.inner.ListUnembeddedNodes(ctx, model, limit)
+}
 
 func (f *failingStorage) CreateNode(ctx context.Context, node *types.Node) error {
 	f.calls++
@@ -864,6 +910,26 @@ func (f *failingStorage) CreateNode(ctx context.Context, node *types.Node) error
 	return f.inner.CreateNode(ctx, node)
 }
 
+// WithTx counts the CreateNode calls made in transactions too.
+func (f *failingStorage) WithTx(ctx context.Context, fn func(tx storage.NodeWriter) error) error {
+	return f.inner.WithTx(ctx, func(tx storage.NodeWriter) error {
+		return fn(&failingTx{NodeWriter: tx, f: f})
+	})
+}
+
+type failingTx struct {
+	storage.NodeWriter
+	f *failingStorage
+}
+
+func (t *failingTx) CreateNode(ctx context.Context, node *types.Node) error {
+	t.f.calls++
+	if t.f.calls > t.f.failAfter {
+		return fmt.Errorf("injected storage failure")
+	}
+	return t.NodeWriter.CreateNode(ctx, node)
+}
+
 func TestStreamResponse_CreateNodeFailure_DoesNotHang(t *testing.T) {
 	dbPath := t.TempDir() + "/test.db"
 	store, err := sqlite.New(dbPath)
@@ -1250,6 +1316,7 @@ type sequenceResponse struct {
 	text       string
 	stopReason string
 	outputToks int
+	toolUse    *types.ContentBlock // a tool_use block after the text
 }
 
 // sequenceProvider returns scripted responses in order, implementing the
@@ -1263,6 +1330,12 @@ func (p *sequenceProvider) Name() string { return "sequence-mock" }
 func (p *sequenceProvider) Models() []types.ModelInfo {
 	return []types.ModelInfo{{ID: "seq-mock", Name: "Sequence Mock", ContextWindow: 200000, MaxOutput: 8192}}
 }
+func (p *sequenceProvider) Capabilities() types.Capabilities {
+	return types.Capabilities{Tools: true, MaxContext: 200000}
+}
+func (p *sequenceProvider) Embed(context.Context, *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	return nil, types.ErrEmbeddingsNotSupported
+}
 func (p *sequenceProvider) Complete(_ context.Context, _ *types.CompletionRequest) (*types.CompletionResponse, error) {
 	return nil, fmt.Errorf("Complete not implemented")
 }
@@ -1292,6 +1365,10 @@ func (p *sequenceProvider) Stream(_ context.Context, _ *types.CompletionRequest)
 		if r.text != "" {
 			blocks = append(blocks, types.ContentBlock{Type: "text", Text: r.text})
 		}
+		if r.toolUse != nil {
+			ch <- types.StreamEvent{Type: types.StreamEventContentDone, ContentBlock: r.toolUse}
+			blocks = append(blocks, *r.toolUse)
+		}
 		ch <- types.StreamEvent{
 			Type: types.StreamEventDone,
 			Response: &types.CompletionResponse{
@@ -1725,6 +1802,95 @@ func TestStreamResponse_StreamEventError_EmittedAndChannelClosed(t *testing.T) {
 	// Channel should be closed (drainEvents completed without timeout).
 }
 
+func TestStreamResponse_CancelledMidStream_SavesPartialOutput(t *testing.T) {
+	// Cancelling the context mid-generation should persist the text streamed
+	// so far as an "interrupted" assistant node rather than dropping it.
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{
+		Mode:          "fixed",
+		FixedResponse: "one two three four five six seven eight",
+		ChunkDelay:    20 * time.Millisecond,
+	})
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := mgr.Prompt(ctx, "hello", "", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+
+	var savedID string
+	var gotDelta bool
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				done = true
+				break
+			}
+			switch ev.Type {
+			case types.StreamEventDelta:
+				if !gotDelta {
+					gotDelta = true
+					cancel()
+				}
+			case types.StreamEventError:
+				t.Fatalf("unexpected error event: %v", ev.Error)
+			case types.StreamEventNodeSaved:
+				savedID = ev.NodeID
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for events")
+		}
+	}
+
+	if savedID == "" {
+		t.Fatal("expected a NodeSaved event for the partial output")
+	}
+	node, err := store.GetNode(context.Background(), savedID)
+	if err != nil || node == nil {
+		t.Fatalf("GetNode: %v (node=%v)", err, node)
+	}
+	if node.Status != "interrupted" {
+		t.Errorf("Status = %q, want interrupted", node.Status)
+	}
+	if node.Content == "" || node.Content == "one two three four five six seven eight" {
+		t.Errorf("Content = %q, want a non-empty partial response", node.Content)
+	}
+}
+
+func TestStreamResponse_CancelledBeforeOutput_EmitsError(t *testing.T) {
+	mgr, cleanup := newTestManager(t, mock.Config{
+		Mode:          "fixed",
+		FixedResponse: "one two three",
+		ChunkDelay:    20 * time.Millisecond,
+	})
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := mgr.Prompt(ctx, "hello", "", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	cancel()
+
+	var gotError bool
+	for _, ev := range drainEvents(t, events, 5*time.Second) {
+		if ev.Type == types.StreamEventNodeSaved && ev.NodeID != "" {
+			// The mock may have emitted a first word before seeing the
+			// cancellation; that is saved as partial output.
+			return
+		}
+		if ev.Type == types.StreamEventError {
+			gotError = true
+		}
+	}
+	if !gotError {
+		t.Error("expected an error event when cancelled before any output")
+	}
+}
+
 // --- 3b: Database failure mid-stream ---
 
 func TestStreamResponse_CreateNodeFailure_DuringContinuation(t *testing.T) {
@@ -2482,3 +2648,96 @@ func TestInjectSyntheticToolResults_OrphansOnMultipleNodes(t *testing.T) {
 		t.Errorf("second synthetic should have t2, got: %+v", blocks2)
 	}
 }
+
+func TestPromptFrom_ModelOverrideSticksToBranch(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+
+	events, err := mgr.Prompt(ctx, "hello", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	first := savedNodeID(t, events)
+
+	events, err = mgr.PromptFrom(ctx, first, "think harder", "mock-slow", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("PromptFrom: %v", err)
+	}
+	switched := savedNodeID(t, events)
+
+	events, err = mgr.PromptFrom(ctx, switched, "go on", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("PromptFrom: %v", err)
+	}
+	next := savedNodeID(t, events)
+	if prov.LastRequest.Model != "mock-slow" {
+		t.Errorf("model after switch = %q, want mock-slow", prov.LastRequest.Model)
+	}
+	for id, want := range map[string]string{first: "mock-fast", switched: "mock-slow", next: "mock-slow"} {
+		if node, _ := mgr.storage.GetNode(ctx, id); node.Model != want {
+			t.Errorf("node %s model = %q, want %q", id, node.Model, want)
+		}
+	}
+
+	// Other branches keep the DAG's model.
+	events, err = mgr.PromptFrom(ctx, first, "other branch", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("PromptFrom: %v", err)
+	}
+	_ = savedNodeID(t, events)
+	if prov.LastRequest.Model != "mock-fast" {
+		t.Errorf("model on other branch = %q, want mock-fast", prov.LastRequest.Model)
+	}
+}
+
+func TestPromptFrom_ProviderErrorDiscardsUserNode(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "hi"})
+	defer cleanup()
+	ctx := context.Background()
+
+	events, err := mgr.Prompt(ctx, "hello", "", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	var replyID string
+	for _, ev := range drainEvents(t, events, 5*time.Second) {
+		if ev.Type == types.StreamEventNodeSaved {
+			replyID = ev.NodeID
+		}
+	}
+
+	// The provider fails before streaming.
+	failing := NewManager(store, mock.New(mock.Config{Mode: "error", Error: fmt.Errorf("provider down")}))
+	if _, err := failing.PromptFrom(ctx, replyID, "again", "", nil, nil, 0, 0); err == nil {
+		t.Fatal("expected PromptFrom to fail")
+	}
+	// The stream fails midway, with nothing saved.
+	failing = NewManager(store, mock.New(mock.Config{Mode: "partial_max_tokens"}))
+	events, err = failing.PromptFrom(ctx, replyID, "and again", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("PromptFrom: %v", err)
+	}
+	drainEvents(t, events, 5*time.Second)
+
+	children, err := store.GetNodeChildren(ctx, replyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(children) != 0 {
+		t.Errorf("failed prompts left %d unanswered message(s)", len(children))
+	}
+
+	// A failed first prompt leaves no DAG.
+	failing = NewManager(store, mock.New(mock.Config{Mode: "error", Error: fmt.Errorf("provider down")}))
+	if _, err := failing.Prompt(ctx, "new", "", "", nil, nil, 0, 0); err == nil {
+		t.Fatal("expected Prompt to fail")
+	}
+	roots, err := store.ListRootNodes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 {
+		t.Errorf("got %d DAGs, want 1", len(roots))
+	}
+}
@@ -26,6 +26,12 @@ func (p *rolloutProvider) Name() string { return p.name }
 
 func (p *rolloutProvider) Models() []types.ModelInfo { return nil }
 
+func (p *rolloutProvider) Capabilities() types.Capabilities { return types.Capabilities{} }
+
+func (p *rolloutProvider) Embed(context.Context, *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	return nil, types.ErrEmbeddingsNotSupported
+}
+
 func (p *rolloutProvider) Complete(_ context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	p.calls++
 	copied := *req
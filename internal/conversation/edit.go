@@ -0,0 +1,124 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"langdag.com/langdag/types"
+)
+
+// ErrNotEditable is returned by Edit for nodes other than user messages.
+var ErrNotEditable = errors.New("only user messages can be edited")
+
+// Edit sends message in place of the user message nodeID and streams the
+// reply. The edited message becomes a sibling of the original, so the
+// original branch is kept. Editing the first message of a DAG starts a new
+// DAG that records the original in ForkedFromDAG and ForkedFromNode.
+func (m *Manager) Edit(ctx context.Context, nodeID, message, model, apiProtocolID string, tools []types.ToolDefinition, think *bool, maxTokens, maxOutputGroupTokens int) (<-chan types.StreamEvent, error) {
+	node, err := m.storage.GetNode(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("node not found: %s", nodeID)
+	}
+	if node.NodeType != types.NodeTypeUser {
+		return nil, fmt.Errorf("%w: node %s is a %s node", ErrNotEditable, node.ID, node.NodeType)
+	}
+	if node.ParentID != "" {
+		return m.PromptFromWithAPIProtocol(ctx, node.ParentID, message, model, apiProtocolID, tools, think, maxTokens, maxOutputGroupTokens)
+	}
+
+	if model == "" {
+		model = node.Model
+	}
+	rootID := m.newID()
+	rootNode := &types.Node{
+		ID:             rootID,
+		RootID:         rootID,
+		NodeType:       types.NodeTypeUser,
+		Content:        message,
+		Model:          model,
+		Status:         "completed",
+		Title:          GenerateTitle(message),
+		SystemPrompt:   node.SystemPrompt,
+		ForkedFromDAG:  node.ID,
+		ForkedFromNode: node.ID,
+		CreatedAt:      time.Now(),
+	}
+	if meta := types.UserMetadataFromNode(node); meta != nil && (len(meta.Tools) > 0 || meta.Language != "" || meta.Visibility != "" || meta.Sampling != nil) {
+		rootNode.Metadata, _ = json.Marshal(types.UserNodeMetadata{Tools: meta.Tools, Language: meta.Language, Visibility: meta.Visibility, Sampling: meta.Sampling})
+		if tools == nil {
+			tools = meta.Tools
+		}
+	}
+	if err := m.CheckTools(ctx, tools); err != nil {
+		return nil, err
+	}
+	setOwner(rootNode, contextOwner(ctx))
+	m.annotateGlossary(rootNode)
+	if err := m.storage.CreateNode(ctx, rootNode); err != nil {
+		return nil, fmt.Errorf("failed to create root node: %w", err)
+	}
+	messages := []types.Message{
+		{Role: "user", Content: contentToRawMessage(message)},
+	}
+	events, err := m.streamResponse(ctx, rootNode, messages, model, apiProtocolID, node.SystemPrompt, tools, think, maxTokens, maxOutputGroupTokens)
+	return m.discardUnanswered(ctx, rootNode, events, err)
+}
+
+// Regenerate streams a new reply to the message that nodeID answers, as a
+// sibling of nodeID. If nodeID is a user message, it streams another reply
+// to it. The model defaults to the one that produced nodeID.
+func (m *Manager) Regenerate(ctx context.Context, nodeID, model, apiProtocolID string, tools []types.ToolDefinition, think *bool, maxTokens, maxOutputGroupTokens int) (<-chan types.StreamEvent, error) {
+	ancestors, err := m.storage.GetAncestors(ctx, nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ancestors: %w", err)
+	}
+	if len(ancestors) == 0 {
+		return nil, fmt.Errorf("node not found: %s", nodeID)
+	}
+	root := ancestors[0]
+
+	// Drop the reply being regenerated, including the continuation nodes of
+	// its output group.
+	replyModel := ""
+	for len(ancestors) > 0 && ancestors[len(ancestors)-1].NodeType == types.NodeTypeAssistant {
+		replyModel = ancestors[len(ancestors)-1].Model
+		ancestors = ancestors[:len(ancestors)-1]
+	}
+	if len(ancestors) == 0 {
+		return nil, fmt.Errorf("node %s does not answer a message", nodeID)
+	}
+	parent := ancestors[len(ancestors)-1]
+
+	if model == "" {
+		model = replyModel
+	}
+	if model == "" {
+		model = root.Model
+	}
+	if tools == nil {
+		tools = dagTools(root)
+	}
+	if err := m.CheckTools(ctx, tools); err != nil {
+		return nil, err
+	}
+
+	ancestorIDs := make([]string, len(ancestors))
+	for i, a := range ancestors {
+		ancestorIDs[i] = a.ID
+	}
+	orphans, err := m.storage.GetOrphanedToolUses(ctx, ancestorIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check orphaned tool uses: %w", err)
+	}
+	if len(orphans) > 0 {
+		ancestors = injectSyntheticToolResults(ancestors, orphans)
+	}
+
+	return m.streamResponse(ctx, parent, buildMessages(ancestors), model, apiProtocolID, root.SystemPrompt, tools, think, maxTokens, maxOutputGroupTokens)
+}
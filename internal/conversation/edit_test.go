@@ -0,0 +1,110 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/types"
+)
+
+func TestEditCreatesSiblingBranch(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+
+	events, err := mgr.Prompt(ctx, "hello", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := savedNodeID(t, events)
+	events, err = mgr.PromptFrom(ctx, first, "what is 2+2?", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, _ := mgr.storage.GetNode(ctx, savedNodeID(t, events))
+	original, _ := mgr.storage.GetNode(ctx, reply.ParentID)
+
+	events, err = mgr.Edit(ctx, original.ID, "what is 3+3?", "", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+	newReply, _ := mgr.storage.GetNode(ctx, savedNodeID(t, events))
+	edited, _ := mgr.storage.GetNode(ctx, newReply.ParentID)
+	if edited.ID == original.ID || edited.ParentID != original.ParentID || edited.Content != "what is 3+3?" {
+		t.Errorf("edited message is not a sibling of the original: %+v", edited)
+	}
+	msgs := prov.LastRequest.Messages
+	if last := string(msgs[len(msgs)-1].Content); last != `"what is 3+3?"` {
+		t.Errorf("last message sent = %s", last)
+	}
+
+	children, _ := mgr.storage.GetNodeChildren(ctx, first)
+	if len(children) != 2 {
+		t.Errorf("assistant has %d children, want 2", len(children))
+	}
+
+	if _, err := mgr.Edit(ctx, reply.ID, "nope", "", "", nil, nil, 0, 0); !errors.Is(err, ErrNotEditable) {
+		t.Errorf("editing an assistant node: err = %v, want ErrNotEditable", err)
+	}
+}
+
+func TestEditRootStartsForkedDAG(t *testing.T) {
+	mgr, cleanup := newTestManager(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+
+	events, err := mgr.Prompt(ctx, "hello", "mock-fast", "Be brief", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, _ := mgr.storage.GetNode(ctx, savedNodeID(t, events))
+
+	events, err = mgr.Edit(ctx, reply.RootID, "hi there", "", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+	newReply, _ := mgr.storage.GetNode(ctx, savedNodeID(t, events))
+	root, _ := mgr.storage.GetNode(ctx, newReply.RootID)
+	if root.ID == reply.RootID || root.Content != "hi there" {
+		t.Fatalf("unexpected new root: %+v", root)
+	}
+	if root.ForkedFromDAG != reply.RootID || root.SystemPrompt != "Be brief" || root.Model != "mock-fast" {
+		t.Errorf("new root does not carry over the original: %+v", root)
+	}
+}
+
+func TestRegenerateCreatesSiblingReply(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+
+	events, err := mgr.Prompt(ctx, "hello", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, _ := mgr.storage.GetNode(ctx, savedNodeID(t, events))
+
+	events, err = mgr.Regenerate(ctx, reply.ID, "", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Regenerate: %v", err)
+	}
+	again, _ := mgr.storage.GetNode(ctx, savedNodeID(t, events))
+	if again.ID == reply.ID || again.ParentID != reply.ParentID || again.NodeType != types.NodeTypeAssistant {
+		t.Errorf("regenerated reply is not a sibling: %+v", again)
+	}
+	if prov.LastRequest.Model != "mock-fast" || len(prov.LastRequest.Messages) != 1 {
+		t.Errorf("unexpected request: model %q, %d messages", prov.LastRequest.Model, len(prov.LastRequest.Messages))
+	}
+
+	// Regenerating from the user message adds another reply to it.
+	events, err = mgr.Regenerate(ctx, reply.ParentID, "", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Regenerate from user node: %v", err)
+	}
+	third, _ := mgr.storage.GetNode(ctx, savedNodeID(t, events))
+	if third.ParentID != reply.ParentID {
+		t.Errorf("reply parent = %s, want %s", third.ParentID, reply.ParentID)
+	}
+}
@@ -0,0 +1,179 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"langdag.com/langdag/types"
+)
+
+// EmbeddingOptions configures the embeddings of node content used by
+// SemanticSearch.
+type EmbeddingOptions struct {
+	// Enabled embeds the prompt and the reply in the background after each
+	// completed reply. EmbedMissing embeds older nodes.
+	Enabled bool
+
+	// Model is the embedding model, e.g. "text-embedding-3-small". Vectors
+	// are stored per model: after changing it, run EmbedMissing again.
+	Model string
+}
+
+// ErrEmbeddingsDisabled is returned by SemanticSearch and EmbedMissing when
+// embeddings are not enabled.
+var ErrEmbeddingsDisabled = errors.New("embeddings are not enabled")
+
+const (
+	// embedTextLimit cuts the text embedded for a node, in bytes, to stay
+	// under the input limits of embedding models.
+	embedTextLimit = 8000
+	// embedBatchSize is the number of nodes embedded per request by
+	// EmbedMissing.
+	embedBatchSize = 64
+	embedTimeout   = 30 * time.Second
+)
+
+// SetEmbeddingOptions configures embeddings. A model is required when they
+// are enabled.
+func (m *Manager) SetEmbeddingOptions(opts EmbeddingOptions) error {
+	if opts.Enabled && opts.Model == "" {
+		return fmt.Errorf("an embedding model is required")
+	}
+	m.embeddings = opts
+	return nil
+}
+
+// embedInBackground embeds nodes after a completed reply, when embeddings
+// are enabled.
+func (m *Manager) embedInBackground(nodes ...*types.Node) {
+	if !m.embeddings.Enabled {
+		return
+	}
+	var pending []*types.Node
+	for _, n := range nodes {
+		// Speculative replies are embedded by EmbedMissing once taken.
+		if n != nil && n.Status != SpeculativeStatus {
+			pending = append(pending, n)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+	m.background.Add(1)
+	go func() {
+		defer m.background.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), embedTimeout)
+		defer cancel()
+		if err := m.embedNodes(ctx, pending); err != nil {
+			slog.WarnContext(ctx, "embeddings: failed to embed", "node_id", pending[len(pending)-1].ID, "error", err)
+		}
+	}()
+}
+
+// EmbedMissing embeds every node that has no embedding for the configured
+// model yet, oldest first, and returns the number of nodes processed.
+func (m *Manager) EmbedMissing(ctx context.Context) (int, error) {
+	if !m.embeddings.Enabled {
+		return 0, ErrEmbeddingsDisabled
+	}
+	count := 0
+	for {
+		nodes, err := m.storage.ListUnembeddedNodes(ctx, m.embeddings.Model, embedBatchSize)
+		if err != nil {
+			return count, err
+		}
+		if len(nodes) == 0 {
+			return count, nil
+		}
+		if err := m.embedNodes(ctx, nodes); err != nil {
+			return count, err
+		}
+		count += len(nodes)
+	}
+}
+
+// embedNodes stores the embeddings of nodes' text. Nodes without text get
+// an empty vector so EmbedMissing does not list them again.
+func (m *Manager) embedNodes(ctx context.Context, nodes []*types.Node) error {
+	var (
+		input    []string
+		embedded []*types.Node
+	)
+	for _, n := range nodes {
+		text := truncateText(strings.TrimSpace(markdownContent(n.Content)), embedTextLimit)
+		if text == "" {
+			if err := m.storage.SaveEmbedding(ctx, n.ID, m.embeddings.Model, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		input = append(input, text)
+		embedded = append(embedded, n)
+	}
+	if len(input) == 0 {
+		return nil
+	}
+
+	resp, err := m.provider.Embed(ctx, &types.EmbeddingRequest{Model: m.embeddings.Model, Input: input})
+	if err != nil {
+		return err
+	}
+	if len(resp.Embeddings) != len(input) {
+		return fmt.Errorf("got %d embeddings for %d inputs", len(resp.Embeddings), len(input))
+	}
+	for i, n := range embedded {
+		if err := m.storage.SaveEmbedding(ctx, n.ID, m.embeddings.Model, resp.Embeddings[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SemanticSearch finds the nodes in every DAG whose content is closest in
+// meaning to query, best matches first, returning at most limit matches
+// (DefaultSearchLimit if limit <= 0). Only embedded nodes are found. DAGs
+// not visible to the request are skipped.
+func (m *Manager) SemanticSearch(ctx context.Context, query string, limit int) ([]SearchMatch, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+	if !m.embeddings.Enabled {
+		return nil, ErrEmbeddingsDisabled
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+
+	resp, err := m.provider.Embed(ctx, &types.EmbeddingRequest{
+		Model: m.embeddings.Model,
+		Input: []string{truncateText(strings.TrimSpace(query), embedTextLimit)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != 1 {
+		return nil, fmt.Errorf("got %d embeddings for 1 input", len(resp.Embeddings))
+	}
+	return collectMatches(limit, func(n int) ([]types.EmbeddingMatch, error) {
+		return m.storage.SearchEmbeddings(ctx, m.embeddings.Model, resp.Embeddings[0], n)
+	}, func(f types.EmbeddingMatch) (*SearchMatch, error) {
+		n, err := m.storage.GetNode(ctx, f.NodeID)
+		if n == nil || err != nil {
+			return nil, err
+		}
+		path, ok, err := m.visiblePath(ctx, n)
+		if !ok || err != nil {
+			return nil, err
+		}
+		return &SearchMatch{
+			Node:    n,
+			Path:    path,
+			Snippet: termsSnippet(markdownContent(n.Content), nil),
+			Score:   f.Score,
+		}, nil
+	})
+}
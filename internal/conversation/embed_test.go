@@ -0,0 +1,103 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/types"
+)
+
+func TestSemanticSearchAfterBackgroundEmbedding(t *testing.T) {
+	mgr, cleanup := newTestManager(t, mock.Config{Mode: "fixed", FixedResponse: "Use a write-through cache."})
+	defer cleanup()
+	ctx := context.Background()
+	if err := mgr.SetEmbeddingOptions(EmbeddingOptions{Enabled: true, Model: "mock-embed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, prompt := range []string{"how should the redis cache expire keys", "plan the team offsite in march"} {
+		events, err := mgr.Prompt(ctx, prompt, "mock-fast", "", nil, nil, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		savedNodeID(t, events)
+	}
+	mgr.Wait()
+
+	matches, err := mgr.SemanticSearch(ctx, "redis cache keys", 1)
+	if err != nil {
+		t.Fatalf("SemanticSearch: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 match, got %d", len(matches))
+	}
+	m := matches[0]
+	if !strings.Contains(m.Node.Content, "redis cache") {
+		t.Errorf("matched %q, want the redis prompt", m.Node.Content)
+	}
+	if m.Score <= 0 || m.Score > 1.0001 {
+		t.Errorf("Score = %v, want in (0, 1]", m.Score)
+	}
+	if len(m.Path) != 1 || m.Path[0] != m.Node.ID {
+		t.Errorf("Path = %v, want the root only", m.Path)
+	}
+}
+
+func TestEmbedMissingBackfillsAndSkipsSpeculative(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+
+	nodes := []*types.Node{
+		{ID: "root", Sequence: 0, NodeType: types.NodeTypeUser, Content: "deploy with kubernetes", CreatedAt: time.Now()},
+		{ID: "spec", ParentID: "root", RootID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant, Content: "kubernetes deploy", Status: SpeculativeStatus, CreatedAt: time.Now()},
+		{ID: "empty", Sequence: 0, NodeType: types.NodeTypeUser, Content: "  ", CreatedAt: time.Now()},
+	}
+	for _, n := range nodes {
+		if err := store.CreateNode(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := mgr.EmbedMissing(ctx); !errors.Is(err, ErrEmbeddingsDisabled) {
+		t.Fatalf("EmbedMissing while disabled: err = %v, want ErrEmbeddingsDisabled", err)
+	}
+	if err := mgr.SetEmbeddingOptions(EmbeddingOptions{Enabled: true}); err == nil {
+		t.Fatal("expected an error without a model")
+	}
+	if err := mgr.SetEmbeddingOptions(EmbeddingOptions{Enabled: true, Model: "mock-embed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := mgr.EmbedMissing(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The speculative node is embedded once taken, if ever.
+	if n != 2 {
+		t.Errorf("EmbedMissing processed %d nodes, want 2", n)
+	}
+	if n, _ := mgr.EmbedMissing(ctx); n != 0 {
+		t.Errorf("second EmbedMissing processed %d nodes, want 0", n)
+	}
+
+	matches, err := mgr.SemanticSearch(ctx, "kubernetes deploy", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Node.ID != "root" {
+		t.Errorf("matches = %v, want only root", matches)
+	}
+
+	nodes[1].Status = "completed"
+	if err := store.UpdateNode(ctx, nodes[1]); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := mgr.EmbedMissing(ctx); n != 1 {
+		t.Errorf("EmbedMissing after the speculation was taken processed %d nodes, want 1", n)
+	}
+}
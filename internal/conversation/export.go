@@ -0,0 +1,349 @@
+package conversation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"langdag.com/langdag/internal/storage"
+	"langdag.com/langdag/types"
+)
+
+// exportVersion is the format version of DAG exports and archive objects.
+const exportVersion = 1
+
+// Export formats accepted by EncodeExport.
+const (
+	ExportJSON     = "json"
+	ExportYAML     = "yaml"
+	ExportMarkdown = "markdown"
+)
+
+// DAGExport is a portable copy of a DAG: every node with its original IDs,
+// plus aliases. It is the format of `langdag export` and of archived DAGs.
+type DAGExport struct {
+	Version int           `json:"version"`
+	Nodes   []*types.Node `json:"nodes"` // root first, in sequence order
+	// Aliases maps node IDs to their aliases.
+	Aliases map[string][]string `json:"aliases,omitempty"`
+}
+
+// ErrDAGExists is returned by Import when the DAG's root is already stored.
+var ErrDAGExists = errors.New("DAG already exists")
+
+// ErrInvalidExport is returned for exports that cannot be imported.
+var ErrInvalidExport = errors.New("invalid export")
+
+// Export returns the whole DAG containing the given node.
+func (m *Manager) Export(ctx context.Context, nodeID string) (*DAGExport, error) {
+	node, err := m.storage.GetNode(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("node not found: %s", nodeID)
+	}
+	rootID := node.RootID
+	if rootID == "" {
+		rootID = node.ID
+	}
+	nodes, err := m.storage.GetSubtree(ctx, rootID)
+	if err != nil {
+		return nil, err
+	}
+	nodes = withoutSpeculative(nodes)
+	if len(nodes) > 0 && nodes[0].ArchivedURI != "" {
+		return nil, fmt.Errorf("DAG %s is archived at %s", rootID, nodes[0].ArchivedURI)
+	}
+
+	dag := &DAGExport{Version: exportVersion, Nodes: nodes}
+	for _, n := range nodes {
+		aliases, err := m.storage.ListAliases(ctx, n.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(aliases) > 0 {
+			if dag.Aliases == nil {
+				dag.Aliases = make(map[string][]string)
+			}
+			dag.Aliases[n.ID] = aliases
+		}
+	}
+	return dag, nil
+}
+
+// Import stores an exported DAG with its original node IDs and returns its
+// root. It returns ErrDAGExists if any of its nodes is already stored.
+// Aliases that are taken by other nodes are skipped. A failed import
+// stores nothing, so it can be retried.
+func (m *Manager) Import(ctx context.Context, dag *DAGExport) (*types.Node, error) {
+	if err := validateExport(dag); err != nil {
+		return nil, err
+	}
+	for _, n := range dag.Nodes {
+		existing, err := m.storage.GetNode(ctx, n.ID)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, fmt.Errorf("%w: node %s is already stored", ErrDAGExists, n.ID)
+		}
+		n.ArchivedURI = ""
+	}
+	if err := m.restoreNodes(ctx, dag, dag.Nodes); err != nil {
+		return nil, err
+	}
+	return m.storage.GetNode(ctx, dag.Nodes[0].ID)
+}
+
+// validateExport checks that dag is a single tree listed parents first.
+func validateExport(dag *DAGExport) error {
+	if dag == nil || len(dag.Nodes) == 0 {
+		return fmt.Errorf("%w: no nodes", ErrInvalidExport)
+	}
+	if dag.Version > exportVersion {
+		return fmt.Errorf("%w: unsupported export version %d", ErrInvalidExport, dag.Version)
+	}
+	root := dag.Nodes[0]
+	if root == nil || root.ID == "" || root.ParentID != "" {
+		return fmt.Errorf("%w: first node must be the DAG root", ErrInvalidExport)
+	}
+	seen := map[string]bool{root.ID: true}
+	for _, n := range dag.Nodes[1:] {
+		if n == nil || n.ID == "" {
+			return fmt.Errorf("%w: node without an ID", ErrInvalidExport)
+		}
+		if seen[n.ID] {
+			return fmt.Errorf("%w: duplicate node %s", ErrInvalidExport, n.ID)
+		}
+		if !seen[n.ParentID] {
+			return fmt.Errorf("%w: node %s appears before its parent %q", ErrInvalidExport, n.ID, n.ParentID)
+		}
+		if n.RootID != root.ID {
+			return fmt.Errorf("%w: node %s belongs to DAG %q, not %s", ErrInvalidExport, n.ID, n.RootID, root.ID)
+		}
+		seen[n.ID] = true
+	}
+	return nil
+}
+
+// restoreNodes creates the given nodes from dag that are not already
+// stored, indexing their tool IDs and restoring their aliases, in one
+// transaction: a failure stores none of them. Aliases taken by other nodes
+// are skipped.
+func (m *Manager) restoreNodes(ctx context.Context, dag *DAGExport, nodes []*types.Node) error {
+	var missing []*types.Node
+	aliases := make(map[string][]string)
+	for _, n := range nodes {
+		existing, err := m.storage.GetNode(ctx, n.ID)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			missing = append(missing, n)
+		}
+		for _, alias := range dag.Aliases[n.ID] {
+			taken, err := m.storage.GetNodeByAlias(ctx, alias)
+			if err != nil {
+				return err
+			}
+			if taken == nil {
+				aliases[n.ID] = append(aliases[n.ID], alias)
+			}
+		}
+	}
+	return m.storage.WithTx(ctx, func(tx storage.NodeWriter) error {
+		for _, n := range missing {
+			if err := tx.CreateNode(ctx, n); err != nil {
+				return err
+			}
+			if err := tx.IndexToolIDs(ctx, n.ID, extractToolResultIDsFromContent(n.Content), "result"); err != nil {
+				return err
+			}
+			if err := tx.IndexToolIDs(ctx, n.ID, extractToolUseIDsFromContent(n.Content), "use"); err != nil {
+				return err
+			}
+		}
+		for _, n := range nodes {
+			for _, alias := range aliases[n.ID] {
+				if err := tx.CreateAlias(ctx, n.ID, alias); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// EncodeExport writes dag to w as JSON, YAML or Markdown. Markdown is meant
+// for reading and sharing; only JSON and YAML can be imported.
+func EncodeExport(w io.Writer, dag *DAGExport, format string) error {
+	switch format {
+	case ExportJSON, "":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(dag)
+	case ExportYAML:
+		// Go through JSON so YAML keys and values match the JSON format.
+		data, err := json.Marshal(dag)
+		if err != nil {
+			return err
+		}
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	case ExportMarkdown, "md":
+		_, err := io.WriteString(w, exportMarkdown(dag))
+		return err
+	default:
+		return fmt.Errorf("unknown export format %q (want json, yaml or markdown)", format)
+	}
+}
+
+// DecodeExport parses a JSON or YAML export.
+func DecodeExport(data []byte) (*DAGExport, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] != '{' {
+		var v interface{}
+		if err := yaml.Unmarshal(trimmed, &v); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+		}
+		converted, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+		}
+		trimmed = converted
+	}
+	var dag DAGExport
+	if err := json.Unmarshal(trimmed, &dag); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+	return &dag, nil
+}
+
+// exportMarkdown renders a DAG as a Markdown transcript. Nodes are listed in
+// sequence order; a node that does not follow its parent names it.
+func exportMarkdown(dag *DAGExport) string {
+	var b strings.Builder
+	root := dag.Nodes[0]
+	title := root.Title
+	if title == "" {
+		title = "Conversation"
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "DAG `%s`, started %s.\n", root.ID, root.CreatedAt.UTC().Format("2006-01-02 15:04 MST"))
+	if root.SystemPrompt != "" {
+		fmt.Fprintf(&b, "\n**System prompt:**\n\n%s\n", quoteMarkdown(root.SystemPrompt))
+	}
+
+	prevID := ""
+	for _, n := range dag.Nodes {
+		heading := nodeTypeLabel(n.NodeType)
+		if n.Model != "" {
+			heading += " · " + n.Model
+		}
+		fmt.Fprintf(&b, "\n## %s `%s`\n\n", heading, shortNodeID(n.ID))
+		if n.ParentID != "" && n.ParentID != prevID {
+			fmt.Fprintf(&b, "*Branch: reply to `%s`*\n\n", shortNodeID(n.ParentID))
+		}
+		b.WriteString(markdownContent(n.Content))
+		prevID = n.ID
+	}
+	return b.String()
+}
+
+func nodeTypeLabel(t types.NodeType) string {
+	switch t {
+	case types.NodeTypeUser:
+		return "User"
+	case types.NodeTypeAssistant:
+		return "Assistant"
+	case types.NodeTypeSystem:
+		return "System"
+	case types.NodeTypeToolCall:
+		return "Tool call"
+	case types.NodeTypeToolResult:
+		return "Tool result"
+	case types.NodeTypeSummary:
+		return "Summary"
+	}
+	return string(t)
+}
+
+func shortNodeID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+func quoteMarkdown(s string) string {
+	return "> " + strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n> ")
+}
+
+// markdownContent renders node content: plain text as is, content blocks
+// as text, tool calls and tool results as fenced code.
+func markdownContent(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if len(trimmed) == 0 || trimmed[0] != '[' || !json.Valid([]byte(trimmed)) {
+		return trimmed + "\n"
+	}
+	var blocks []struct {
+		Type      string          `json:"type"`
+		Text      string          `json:"text"`
+		Name      string          `json:"name"`
+		Input     json.RawMessage `json:"input"`
+		ToolUseID string          `json:"tool_use_id"`
+		Content   json.RawMessage `json:"content"`
+		MediaType string          `json:"media_type"`
+		IsError   bool            `json:"is_error"`
+	}
+	if json.Unmarshal([]byte(trimmed), &blocks) != nil {
+		return trimmed + "\n"
+	}
+	var b strings.Builder
+	for i, blk := range blocks {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		switch blk.Type {
+		case "text":
+			b.WriteString(strings.TrimSpace(blk.Text) + "\n")
+		case "tool_use":
+			fmt.Fprintf(&b, "**Tool call** `%s`\n\n```json\n%s\n```\n", blk.Name, indentJSON(blk.Input))
+		case "tool_result":
+			label := "Tool result"
+			if blk.IsError {
+				label = "Tool error"
+			}
+			var text string
+			if json.Unmarshal(blk.Content, &text) != nil {
+				text = indentJSON(blk.Content)
+			}
+			fmt.Fprintf(&b, "**%s** for `%s`\n\n```\n%s\n```\n", label, blk.ToolUseID, strings.TrimSpace(text))
+		default:
+			fmt.Fprintf(&b, "*[%s]*\n", strings.TrimSpace(blk.Type+" "+blk.MediaType))
+		}
+	}
+	return b.String()
+}
+
+func indentJSON(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if json.Indent(&buf, raw, "", "  ") != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
@@ -0,0 +1,153 @@
+package conversation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/types"
+)
+
+func exportTestNodes() []*types.Node {
+	now := time.Now()
+	return []*types.Node{
+		{ID: "root", Sequence: 0, NodeType: types.NodeTypeUser, Content: "What is 6*7?", Title: "Math", SystemPrompt: "Be brief", CreatedAt: now},
+		{ID: "a1", ParentID: "root", RootID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant, Model: "mock-fast",
+			Content: `[{"type":"text","text":"Let me check."},{"type":"tool_use","id":"toolu_1","name":"calc","input":{"expr":"6*7"}}]`, CreatedAt: now},
+		{ID: "u2", ParentID: "a1", RootID: "root", Sequence: 2, NodeType: types.NodeTypeUser,
+			Content: `[{"type":"tool_result","tool_use_id":"toolu_1","content":"42"}]`, CreatedAt: now},
+		{ID: "u3", ParentID: "root", RootID: "root", Sequence: 3, NodeType: types.NodeTypeUser, Content: "Never mind", CreatedAt: now},
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range []string{ExportJSON, ExportYAML} {
+		t.Run(format, func(t *testing.T) {
+			src, srcStore, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+			defer cleanup()
+			ctx := context.Background()
+			for _, n := range exportTestNodes() {
+				if err := srcStore.CreateNode(ctx, n); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := srcStore.CreateAlias(ctx, "u2", "result"); err != nil {
+				t.Fatal(err)
+			}
+
+			dag, err := src.Export(ctx, "u2")
+			if err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			var buf bytes.Buffer
+			if err := EncodeExport(&buf, dag, format); err != nil {
+				t.Fatalf("EncodeExport: %v", err)
+			}
+
+			decoded, err := DecodeExport(buf.Bytes())
+			if err != nil {
+				t.Fatalf("DecodeExport: %v", err)
+			}
+			dst, dstStore, cleanup2 := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+			defer cleanup2()
+			root, err := dst.Import(ctx, decoded)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if root.ID != "root" || root.Title != "Math" || root.SystemPrompt != "Be brief" {
+				t.Errorf("unexpected root: %+v", root)
+			}
+			nodes, _ := dstStore.GetSubtree(ctx, "root")
+			if len(nodes) != 4 {
+				t.Fatalf("imported %d nodes, want 4", len(nodes))
+			}
+			if nodes[1].Content != exportTestNodes()[1].Content || nodes[1].Model != "mock-fast" {
+				t.Errorf("assistant node not preserved: %+v", nodes[1])
+			}
+			if n, _ := dstStore.GetNodeByAlias(ctx, "result"); n == nil || n.ID != "u2" {
+				t.Error("expected alias to be imported")
+			}
+
+			if _, err := dst.Import(ctx, decoded); !errors.Is(err, ErrDAGExists) {
+				t.Errorf("second Import error = %v, want ErrDAGExists", err)
+			}
+		})
+	}
+}
+
+func TestImportFailureStoresNothing(t *testing.T) {
+	_, store, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+	fs := &failingStorage{inner: store, failAfter: 2}
+	mgr := NewManager(fs, mock.New(mock.Config{Mode: "fixed", FixedResponse: "ok"}))
+	dag := &DAGExport{Version: exportVersion, Nodes: exportTestNodes(), Aliases: map[string][]string{"u2": {"result"}}}
+
+	if _, err := mgr.Import(ctx, dag); err == nil {
+		t.Fatal("expected the injected failure")
+	}
+	if n, _ := store.GetNode(ctx, "root"); n != nil {
+		t.Fatal("a failed import left its root behind")
+	}
+	if n, _ := store.GetNodeByAlias(ctx, "result"); n != nil {
+		t.Fatal("a failed import left its alias behind")
+	}
+
+	// Retrying is not refused with ErrDAGExists.
+	fs.failAfter = 100
+	if _, err := mgr.Import(ctx, dag); err != nil {
+		t.Fatalf("retried Import: %v", err)
+	}
+	if nodes, _ := store.GetSubtree(ctx, "root"); len(nodes) != 4 {
+		t.Errorf("imported %d nodes, want 4", len(nodes))
+	}
+}
+
+func TestExportMarkdown(t *testing.T) {
+	dag := &DAGExport{Version: exportVersion, Nodes: exportTestNodes()}
+	var buf bytes.Buffer
+	if err := EncodeExport(&buf, dag, ExportMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	md := buf.String()
+	for _, want := range []string{
+		"# Math",
+		"> Be brief",
+		"## User `root`",
+		"## Assistant · mock-fast `a1`",
+		"Let me check.",
+		"**Tool call** `calc`",
+		"**Tool result** for `toolu_1`",
+		"*Branch: reply to `root`*",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestImportRejectsInvalidExports(t *testing.T) {
+	mgr, cleanup := newTestManager(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+
+	nodes := exportTestNodes()
+	cases := map[string]*DAGExport{
+		"empty":          {Version: 1},
+		"child first":    {Version: 1, Nodes: []*types.Node{nodes[1], nodes[0]}},
+		"orphan":         {Version: 1, Nodes: []*types.Node{nodes[0], nodes[2]}},
+		"future version": {Version: 99, Nodes: nodes[:1]},
+	}
+	for name, dag := range cases {
+		if _, err := mgr.Import(ctx, dag); !errors.Is(err, ErrInvalidExport) {
+			t.Errorf("%s: error = %v, want ErrInvalidExport", name, err)
+		}
+	}
+	if _, err := DecodeExport([]byte("not: [valid")); !errors.Is(err, ErrInvalidExport) {
+		t.Errorf("DecodeExport error = %v, want ErrInvalidExport", err)
+	}
+}
@@ -0,0 +1,89 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"langdag.com/langdag/types"
+)
+
+// followUpCount is how many follow-up questions SuggestFollowUps asks for.
+const followUpCount = 3
+
+const followUpsSystemPrompt = `Suggest the %d messages the user is most likely to send next in the conversation below. Write each on its own line, as the user would type it, without numbering or quotes.`
+
+// followUps asks model for up to n likely next prompts after the last of
+// ancestors. It returns them with the tokens spent.
+func (m *Manager) followUps(ctx context.Context, ancestors []*types.Node, model string, n int) ([]string, types.Usage, error) {
+	var exchange strings.Builder
+	for _, node := range ancestors[max(0, len(ancestors)-4):] {
+		fmt.Fprintf(&exchange, "%s: %s\n\n", node.NodeType, truncateText(strings.TrimSpace(markdownContent(node.Content)), titleExchangeLimit))
+	}
+	resp, err := m.provider.Complete(ctx, &types.CompletionRequest{
+		Model:     model,
+		System:    fmt.Sprintf(followUpsSystemPrompt, n),
+		MaxTokens: m.resolveMaxTokens(model, 256),
+		Messages: []types.Message{
+			{Role: "user", Content: contentToRawMessage(exchange.String())},
+		},
+	})
+	if err != nil {
+		return nil, types.Usage{}, err
+	}
+
+	var followUps []string
+	seen := map[string]bool{}
+	for _, line := range strings.Split(responseText(resp), "\n") {
+		line = strings.Trim(strings.TrimSpace(line), `"-*• `)
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		followUps = append(followUps, line)
+		if len(followUps) == n {
+			break
+		}
+	}
+	return followUps, resp.Usage, nil
+}
+
+// SuggestFollowUps asks the cheapest model of the reply's provider for
+// follow-up questions to the assistant node nodeID, for quick replies, and
+// records them in the node's metadata ("suggestions").
+func (m *Manager) SuggestFollowUps(ctx context.Context, nodeID string) ([]string, error) {
+	ancestors, err := m.storage.GetAncestors(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ancestors) == 0 {
+		return nil, fmt.Errorf("node not found: %s", nodeID)
+	}
+	reply := ancestors[len(ancestors)-1]
+	if reply.NodeType != types.NodeTypeAssistant {
+		return nil, fmt.Errorf("node %s is not an assistant reply", nodeID)
+	}
+	suggestions, _, err := m.followUps(ctx, ancestors, cheapestModel(reply.Model), followUpCount)
+	if err != nil || len(suggestions) == 0 {
+		return suggestions, err
+	}
+
+	meta, err := types.ParseAssistantNodeMetadata(reply.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		meta = &types.AssistantNodeMetadata{}
+	}
+	meta.Suggestions = suggestions
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	reply.Metadata = data
+	if err := m.storage.UpdateNode(ctx, reply); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,111 @@
+package conversation
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"langdag.com/langdag/types"
+)
+
+// GlossaryEntry is a term of a project's glossary with its preferred
+// translation or definition.
+type GlossaryEntry struct {
+	Term       string
+	Definition string
+}
+
+type glossaryTerm struct {
+	GlossaryEntry
+	re *regexp.Regexp
+}
+
+// SetGlossary sets the project glossary. The entries whose term appears in
+// a user message, as a whole word and ignoring case, are added to the
+// system prompt of the reply, and the matched terms are recorded in the
+// metadata of the message's node. Nil disables the glossary.
+func (m *Manager) SetGlossary(entries []GlossaryEntry) error {
+	terms := make([]glossaryTerm, 0, len(entries))
+	for _, e := range entries {
+		term := strings.TrimSpace(e.Term)
+		if term == "" {
+			return fmt.Errorf("glossary entry without a term")
+		}
+		if strings.TrimSpace(e.Definition) == "" {
+			return fmt.Errorf("glossary term %q has no definition", term)
+		}
+		re := regexp.MustCompile(`(?i)(^|[^\pL\pN_])` + regexp.QuoteMeta(term) + `($|[^\pL\pN_])`)
+		terms = append(terms, glossaryTerm{GlossaryEntry{Term: term, Definition: strings.TrimSpace(e.Definition)}, re})
+	}
+	m.glossary = terms
+	return nil
+}
+
+// annotateGlossary records on node the glossary terms its content uses.
+func (m *Manager) annotateGlossary(node *types.Node) {
+	if len(m.glossary) == 0 {
+		return
+	}
+	text := messageText(node.Content)
+	var matched []string
+	for _, t := range m.glossary {
+		if t.re.MatchString(text) {
+			matched = append(matched, t.Term)
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+	meta := types.UserMetadataFromNode(node)
+	if meta == nil {
+		meta = &types.UserNodeMetadata{}
+	}
+	meta.Glossary = matched
+	node.Metadata, _ = json.Marshal(meta)
+}
+
+// glossaryInstruction returns the system prompt section defining the
+// glossary terms recorded on a user node, or "".
+func (m *Manager) glossaryInstruction(node *types.Node) string {
+	meta := types.UserMetadataFromNode(node)
+	if meta == nil || len(meta.Glossary) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, term := range meta.Glossary {
+		for _, t := range m.glossary {
+			if t.Term == term {
+				fmt.Fprintf(&b, "\n- %s: %s", t.Term, t.Definition)
+				break
+			}
+		}
+	}
+	if b.Len() == 0 {
+		return "" // the terms were removed from the glossary
+	}
+	return "Use these project terms as defined here:" + b.String()
+}
+
+// messageText returns the text typed in a user message: the message
+// itself, or the text blocks of a content block array.
+func messageText(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return content
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal([]byte(trimmed), &blocks) != nil {
+		return content
+	}
+	var texts []string
+	for _, b := range blocks {
+		if b.Type == "text" {
+			texts = append(texts, b.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,61 @@
+package conversation
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/types"
+)
+
+func TestGlossaryTermsAreInjectedAndRecorded(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+	if err := mgr.SetGlossary([]GlossaryEntry{
+		{Term: "DAG", Definition: "a conversation tree"},
+		{Term: "ticket", Definition: "a support request"},
+		{Term: "C++", Definition: "the programming language"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := mgr.Prompt(ctx, "How do I export a dag? I use C++.", "", "Be brief.", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, _ := store.GetNode(ctx, savedNodeID(t, events))
+	const want = "Be brief.\n\nUse these project terms as defined here:\n- DAG: a conversation tree\n- C++: the programming language"
+	if node.SystemPrompt != want {
+		t.Errorf("SystemPrompt = %q, want %q", node.SystemPrompt, want)
+	}
+	root, _ := store.GetNode(ctx, node.RootID)
+	if meta := types.UserMetadataFromNode(root); meta == nil || strings.Join(meta.Glossary, ",") != "DAG,C++" {
+		t.Errorf("root metadata = %s", root.Metadata)
+	}
+
+	// Terms must be whole words, and only the message being answered counts.
+	events, err = mgr.PromptFrom(ctx, node.ID, "What about tickets?", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, _ = store.GetNode(ctx, savedNodeID(t, events))
+	if node.SystemPrompt != "" {
+		t.Errorf("SystemPrompt = %q, want none", node.SystemPrompt)
+	}
+	user, _ := store.GetNode(ctx, node.ParentID)
+	if len(user.Metadata) != 0 {
+		t.Errorf("user metadata = %s, want none", user.Metadata)
+	}
+}
+
+func TestSetGlossaryRejectsIncompleteEntries(t *testing.T) {
+	mgr := NewManager(nil, nil)
+	if err := mgr.SetGlossary([]GlossaryEntry{{Term: " ", Definition: "x"}}); err == nil {
+		t.Error("entry without a term was accepted")
+	}
+	if err := mgr.SetGlossary([]GlossaryEntry{{Term: "DAG"}}); err == nil {
+		t.Error("entry without a definition was accepted")
+	}
+}
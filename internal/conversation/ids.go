@@ -0,0 +1,79 @@
+package conversation
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ID formats accepted by SetIDFormat.
+const (
+	IDFormatUUID  = "uuid"  // random UUIDs (default)
+	IDFormatShort = "short" // 12-character, time-sortable base32 IDs
+)
+
+// shortIDAlphabet is Crockford's base32 alphabet, lowercased: no i, l, o
+// or u, so IDs are easy to read and type.
+const shortIDAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+
+// shortIDEpoch is the zero time of short IDs' timestamps.
+var shortIDEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SetIDFormat selects the format of IDs generated for new nodes and output
+// groups: IDFormatUUID (or "") or IDFormatShort. Nodes are looked up the
+// same way whatever their format, so stores can mix both.
+func (m *Manager) SetIDFormat(format string) error {
+	switch format {
+	case "", IDFormatUUID:
+		m.shortIDs = nil
+	case IDFormatShort:
+		m.shortIDs = &shortIDGenerator{}
+	default:
+		return fmt.Errorf("unknown ID format %q (want %s or %s)", format, IDFormatUUID, IDFormatShort)
+	}
+	return nil
+}
+
+// newID returns an ID for a new node or output group.
+func (m *Manager) newID() string {
+	if m.shortIDs != nil {
+		return m.shortIDs.next(time.Now())
+	}
+	return uuid.New().String()
+}
+
+// shortIDGenerator makes 12-character IDs from 60 bits: 32 bits of seconds
+// since shortIDEpoch, then 28 random bits. An ID that would not sort after
+// the previous one is the previous one plus a random step instead, so IDs
+// from one process are unique, sort in creation order, and still tend to
+// differ within their first 8 characters.
+type shortIDGenerator struct {
+	mu   sync.Mutex
+	last uint64
+}
+
+func (g *shortIDGenerator) next(now time.Time) string {
+	secs := uint64(now.Sub(shortIDEpoch)/time.Second) & (1<<32 - 1)
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	random := uint64(binary.BigEndian.Uint32(b[:])) >> 4
+	v := secs<<28 | random
+
+	g.mu.Lock()
+	if v <= g.last {
+		v = g.last + 1 + random&(1<<24-1)
+	}
+	g.last = v
+	g.mu.Unlock()
+
+	var id [12]byte
+	for i := len(id) - 1; i >= 0; i-- {
+		id[i] = shortIDAlphabet[v&31]
+		v >>= 5
+	}
+	return string(id[:])
+}
@@ -0,0 +1,77 @@
+package conversation
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/provider/mock"
+)
+
+func TestShortIDsAreUniqueAndSorted(t *testing.T) {
+	g := &shortIDGenerator{}
+	now := time.Now()
+	ids := make([]string, 1000)
+	seen := make(map[string]bool)
+	for i := range ids {
+		// Every ten IDs, a second passes.
+		ids[i] = g.next(now.Add(time.Duration(i/10) * time.Second))
+		if len(ids[i]) != 12 {
+			t.Fatalf("ID %q has %d characters, want 12", ids[i], len(ids[i]))
+		}
+		if strings.Trim(ids[i], shortIDAlphabet) != "" {
+			t.Fatalf("ID %q uses characters outside the alphabet", ids[i])
+		}
+		if seen[ids[i]] {
+			t.Fatalf("duplicate ID %q", ids[i])
+		}
+		seen[ids[i]] = true
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Error("IDs do not sort in creation order")
+	}
+}
+
+func TestSetIDFormat(t *testing.T) {
+	mgr, cleanup := newTestManager(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+
+	if err := mgr.SetIDFormat("ulid"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+
+	events, err := mgr.Prompt(ctx, "Hello", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuidReply := savedNodeID(t, events)
+	if len(uuidReply) != 36 {
+		t.Fatalf("default ID %q is not a UUID", uuidReply)
+	}
+
+	if err := mgr.SetIDFormat(IDFormatShort); err != nil {
+		t.Fatalf("SetIDFormat: %v", err)
+	}
+	// Continuing a DAG with UUIDs adds short IDs to it.
+	events, err = mgr.PromptFrom(ctx, uuidReply, "Again", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shortReply := savedNodeID(t, events)
+	if len(shortReply) != 12 {
+		t.Fatalf("ID %q is not a short ID", shortReply)
+	}
+	ancestors, err := mgr.storage.GetAncestors(ctx, shortReply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ancestors) != 4 || ancestors[1].ID != uuidReply || len(ancestors[2].ID) != 12 {
+		t.Errorf("unexpected ancestors: %d nodes", len(ancestors))
+	}
+	if node, err := mgr.ResolveNode(ctx, uuidReply[:8]); err != nil || node == nil || node.ID != uuidReply {
+		t.Errorf("ResolveNode(UUID prefix) = %v, %v", node, err)
+	}
+}
@@ -0,0 +1,167 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"langdag.com/langdag/types"
+)
+
+// InjectionScanOptions configures the prompt-injection scanner applied to
+// tool results before they are sent to the model.
+type InjectionScanOptions struct {
+	// Enabled turns scanning on. Flagged user nodes get status "flagged"
+	// and their warnings recorded in metadata.
+	Enabled bool
+
+	// RequireConfirmation rejects flagged messages with an *InjectionError
+	// unless the prompt context was marked with ContextWithInjectionConfirmed.
+	RequireConfirmation bool
+
+	// Patterns are extra case-insensitive regular expressions checked in
+	// addition to the built-in heuristics.
+	Patterns []string
+}
+
+// injectionPattern is one heuristic with a human-readable label.
+type injectionPattern struct {
+	label string
+	re    *regexp.Regexp
+}
+
+// defaultInjectionPatterns are phrasings commonly used to hijack a model
+// through retrieved content. They are heuristics: expect false positives
+// on text that discusses prompt injection.
+var defaultInjectionPatterns = []injectionPattern{
+	{"instruction override", regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\s+(all\s+|any\s+)?(of\s+)?(the\s+|your\s+)?(previous|prior|above|earlier|preceding|system)\s+(instructions|prompts?|messages|rules|directions)`)},
+	{"new instructions", regexp.MustCompile(`(?i)\bnew\s+(system\s+)?instructions\s*:`)},
+	{"role reassignment", regexp.MustCompile(`(?i)\byou\s+are\s+now\s+(a|an|in|the)\b`)},
+	{"prompt exfiltration", regexp.MustCompile(`(?i)\b(reveal|print|output|repeat|show)\s+(me\s+)?(your|the)\s+(system\s+prompt|hidden\s+prompt|initial\s+instructions|instructions)`)},
+	{"concealment", regexp.MustCompile(`(?i)\bdo\s+not\s+(tell|inform|mention\s+(this\s+)?to)\s+the\s+user\b`)},
+	{"chat template tokens", regexp.MustCompile(`(?i)(<\|im_start\|>|<\|system\|>|\[/?INST\]|<<SYS>>)`)},
+	{"role-prefixed line", regexp.MustCompile(`(?im)^\s*(system|assistant)\s*:\s*\S`)},
+}
+
+// injectionScanner holds the compiled patterns for a configuration.
+type injectionScanner struct {
+	opts     InjectionScanOptions
+	patterns []injectionPattern
+}
+
+// SetInjectionScanOptions configures prompt-injection scanning of tool
+// results. It returns an error if one of opts.Patterns does not compile.
+func (m *Manager) SetInjectionScanOptions(opts InjectionScanOptions) error {
+	if !opts.Enabled {
+		m.injectionScan = nil
+		return nil
+	}
+	patterns := append([]injectionPattern(nil), defaultInjectionPatterns...)
+	for _, p := range opts.Patterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return fmt.Errorf("invalid injection pattern %q: %w", p, err)
+		}
+		patterns = append(patterns, injectionPattern{label: "custom pattern", re: re})
+	}
+	m.injectionScan = &injectionScanner{opts: opts, patterns: patterns}
+	return nil
+}
+
+// InjectionError is returned when a message's tool results look like a
+// prompt-injection attempt and confirmation is required.
+type InjectionError struct {
+	Warnings []string
+}
+
+func (e *InjectionError) Error() string {
+	return "suspected prompt injection in tool results: " + strings.Join(e.Warnings, "; ")
+}
+
+type injectionConfirmedKey struct{}
+
+// ContextWithInjectionConfirmed returns a child context that lets a prompt
+// through even when its tool results were flagged. The node is still
+// marked with the warnings.
+func ContextWithInjectionConfirmed(ctx context.Context) context.Context {
+	return context.WithValue(ctx, injectionConfirmedKey{}, true)
+}
+
+func injectionConfirmed(ctx context.Context) bool {
+	confirmed, _ := ctx.Value(injectionConfirmedKey{}).(bool)
+	return confirmed
+}
+
+// checkInjection scans the tool results in message and, when they are
+// flagged, marks node accordingly. It returns an *InjectionError if the
+// message must be confirmed first.
+func (m *Manager) checkInjection(ctx context.Context, node *types.Node, message string) error {
+	if m.injectionScan == nil {
+		return nil
+	}
+	warnings := m.injectionScan.scan(message)
+	if len(warnings) == 0 {
+		return nil
+	}
+	if m.injectionScan.opts.RequireConfirmation && !injectionConfirmed(ctx) {
+		return &InjectionError{Warnings: warnings}
+	}
+	node.Status = "flagged"
+	node.Metadata, _ = json.Marshal(types.UserNodeMetadata{InjectionWarnings: warnings})
+	return nil
+}
+
+// scan returns a warning for each pattern matched by the tool results in
+// message. Plain text messages typed by the user are not scanned.
+func (s *injectionScanner) scan(message string) []string {
+	var warnings []string
+	for _, text := range toolResultTexts(message) {
+		for _, p := range s.patterns {
+			if match := p.re.FindString(text); match != "" {
+				warnings = append(warnings, fmt.Sprintf("%s: %q", p.label, strings.TrimSpace(match)))
+			}
+		}
+	}
+	return warnings
+}
+
+// toolResultTexts extracts the text of every tool_result block in content.
+// Tool result content may be a string or an array of text blocks.
+func toolResultTexts(content string) []string {
+	trimmed := strings.TrimSpace(content)
+	if len(trimmed) == 0 || trimmed[0] != '[' || !json.Valid([]byte(trimmed)) {
+		return nil
+	}
+	var blocks []struct {
+		Type    string          `json:"type"`
+		Content json.RawMessage `json:"content"`
+	}
+	if json.Unmarshal([]byte(trimmed), &blocks) != nil {
+		return nil
+	}
+	var texts []string
+	for _, b := range blocks {
+		if b.Type != "tool_result" || len(b.Content) == 0 {
+			continue
+		}
+		var s string
+		if json.Unmarshal(b.Content, &s) == nil {
+			texts = append(texts, s)
+			continue
+		}
+		var parts []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		if json.Unmarshal(b.Content, &parts) == nil {
+			for _, p := range parts {
+				if p.Type == "text" {
+					texts = append(texts, p.Text)
+				}
+			}
+		}
+	}
+	return texts
+}
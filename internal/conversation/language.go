@@ -0,0 +1,274 @@
+package conversation
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"langdag.com/langdag/types"
+)
+
+// languageSampleBytes is how much reply text is held back before checking
+// its language. Shorter replies are checked once complete.
+const languageSampleBytes = 400
+
+// languageNames maps the ISO 639-1 codes of the languages the detector
+// knows to their English names.
+var languageNames = map[string]string{
+	"en": "English", "fr": "French", "es": "Spanish", "de": "German",
+	"it": "Italian", "pt": "Portuguese", "nl": "Dutch", "ru": "Russian",
+	"uk": "Ukrainian", "el": "Greek", "ar": "Arabic", "he": "Hebrew",
+	"hi": "Hindi", "th": "Thai", "ja": "Japanese", "zh": "Chinese",
+	"ko": "Korean",
+}
+
+// stopwords are frequent words of the Latin-script languages, used to tell
+// them apart.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "of", "to", "in", "that", "it", "you", "for", "with", "this", "not", "be", "have"},
+	"fr": {"le", "la", "les", "et", "est", "des", "une", "un", "du", "que", "pour", "dans", "pas", "vous", "sont", "avec"},
+	"es": {"el", "la", "los", "las", "y", "es", "que", "de", "en", "un", "una", "por", "para", "con", "no", "del"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "mit", "sie", "den", "von", "auf", "für", "sich"},
+	"it": {"il", "la", "di", "che", "e", "è", "un", "una", "per", "non", "sono", "con", "del", "della", "gli", "le"},
+	"pt": {"o", "a", "os", "as", "e", "é", "que", "de", "um", "uma", "para", "com", "não", "do", "da", "em"},
+	"nl": {"de", "het", "een", "en", "is", "van", "niet", "dat", "op", "te", "zijn", "met", "voor", "ik", "je", "er"},
+}
+
+// ResolveLanguage returns the ISO 639-1 code and English name of a
+// language given by code or English name, case-insensitively. Languages
+// the detector doesn't know are returned as given, with an empty code:
+// replies are asked to use them but not checked.
+func ResolveLanguage(lang string) (code, name string) {
+	lang = strings.TrimSpace(lang)
+	if n, ok := languageNames[strings.ToLower(lang)]; ok {
+		return strings.ToLower(lang), n
+	}
+	for c, n := range languageNames {
+		if strings.EqualFold(n, lang) {
+			return c, n
+		}
+	}
+	return "", lang
+}
+
+// SetDefaultLanguage sets the language replies are written in when
+// neither the request nor the DAG sets one. "" sets none.
+func (m *Manager) SetDefaultLanguage(lang string) {
+	m.defaultLanguage = strings.TrimSpace(lang)
+}
+
+type languageKey struct{}
+
+// ContextWithLanguage returns a child context asking for replies in lang
+// (an ISO 639-1 code or an English name). A DAG started with this context
+// keeps the language for later prompts.
+func ContextWithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, strings.TrimSpace(lang))
+}
+
+func contextLanguage(ctx context.Context) string {
+	lang, _ := ctx.Value(languageKey{}).(string)
+	return lang
+}
+
+// replyLanguage returns the language of a reply below parent: the
+// request's, else the DAG's, else the default.
+func (m *Manager) replyLanguage(ctx context.Context, parent *types.Node) string {
+	if lang := contextLanguage(ctx); lang != "" {
+		return lang
+	}
+	root := parent
+	if parent.RootID != "" && parent.RootID != parent.ID {
+		if r, err := m.storage.GetNode(ctx, parent.RootID); err == nil && r != nil {
+			root = r
+		}
+	}
+	if meta := types.UserMetadataFromNode(root); meta != nil && meta.Language != "" {
+		return meta.Language
+	}
+	return m.defaultLanguage
+}
+
+// languageInstruction returns the system prompt line asking for replies in
+// lang; strict is used for the retry of a reply in another language.
+func languageInstruction(lang string, strict bool) string {
+	_, name := ResolveLanguage(lang)
+	if strict {
+		return "IMPORTANT: Respond only in " + name + ", whatever the language of the messages. Your previous reply was not in " + name + "."
+	}
+	return "Respond in " + name + "."
+}
+
+// withInstruction appends an instruction to a system prompt.
+func withInstruction(system, instruction string) string {
+	if system == "" {
+		return instruction
+	}
+	return system + "\n\n" + instruction
+}
+
+// streamInLanguage streams a reply to req, whose system prompt asks for
+// lang. The start of the reply is held back until its language can be
+// detected; a reply in another language is dropped and asked again, once,
+// with a stricter instruction.
+func (m *Manager) streamInLanguage(ctx context.Context, req *types.CompletionRequest, lang string) (<-chan types.StreamEvent, error) {
+	code, _ := ResolveLanguage(lang)
+	if code == "" {
+		return m.provider.Stream(ctx, req)
+	}
+	attemptCtx, cancel := context.WithCancel(ctx)
+	first, err := m.provider.Stream(attemptCtx, req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	out := make(chan types.StreamEvent, 100)
+	go func() {
+		defer close(out)
+		defer cancel()
+
+		var held []types.StreamEvent
+		var text strings.Builder
+		checked := false
+		for event := range first {
+			if checked {
+				out <- event
+				continue
+			}
+			held = append(held, event)
+			if event.Type == types.StreamEventDelta {
+				text.WriteString(event.Content)
+			}
+			final := event.Type == types.StreamEventDone || event.Type == types.StreamEventError
+			if text.Len() < languageSampleBytes && !final {
+				continue
+			}
+			checked = true
+			if event.Type != types.StreamEventError && !inLanguage(code, text.String()) {
+				cancel()
+				for range first {
+				}
+				slog.InfoContext(ctx, "conversation: reply not in the requested language, retrying", "language", code, "model", req.Model)
+				retry := *req
+				retry.System = withInstruction(req.System, languageInstruction(lang, true))
+				second, err := m.provider.Stream(ctx, &retry)
+				if err != nil {
+					out <- types.StreamEvent{Type: types.StreamEventError, Error: err}
+					return
+				}
+				for event := range second {
+					out <- event
+				}
+				return
+			}
+			for _, e := range held {
+				out <- e
+			}
+		}
+		if !checked {
+			for _, e := range held {
+				out <- e
+			}
+		}
+	}()
+	return out, nil
+}
+
+// inLanguage reports whether text may be in the language with the given
+// code. It is false only when text is clearly in another language.
+func inLanguage(code, text string) bool {
+	detected := detectLanguage(text)
+	switch {
+	case detected == "":
+		return true
+	case code == "ru" || code == "uk":
+		return detected == "cyrillic"
+	case code == "zh":
+		return detected == "zh" || detected == "ja" // kanji-only text
+	default:
+		return detected == code
+	}
+}
+
+// detectLanguage guesses the language of text from its script and, for
+// Latin script, its most frequent words. It returns "" when unsure;
+// Cyrillic text is reported as "cyrillic".
+func detectLanguage(text string) string {
+	counts := map[string]int{}
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			counts["kana"]++
+		case unicode.Is(unicode.Han, r):
+			counts["han"]++
+		case unicode.Is(unicode.Hangul, r):
+			counts["ko"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			counts["cyrillic"]++
+		case unicode.Is(unicode.Arabic, r):
+			counts["ar"]++
+		case unicode.Is(unicode.Hebrew, r):
+			counts["he"]++
+		case unicode.Is(unicode.Greek, r):
+			counts["el"]++
+		case unicode.Is(unicode.Devanagari, r):
+			counts["hi"]++
+		case unicode.Is(unicode.Thai, r):
+			counts["th"]++
+		case unicode.Is(unicode.Latin, r):
+			counts["latin"]++
+		}
+	}
+	if letters < 20 {
+		return ""
+	}
+	if counts["kana"] > 0 && counts["kana"]+counts["han"] > letters/2 {
+		return "ja"
+	}
+	if counts["han"] > letters/2 {
+		return "zh"
+	}
+	for _, script := range []string{"ko", "cyrillic", "ar", "he", "el", "hi", "th"} {
+		if counts[script] > letters/2 {
+			return script
+		}
+	}
+	if counts["latin"] <= letters/2 {
+		return ""
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) < 8 {
+		return ""
+	}
+	freq := map[string]int{}
+	for _, w := range words {
+		freq[w]++
+	}
+	best, bestScore, second := "", 0, 0
+	for code, list := range stopwords {
+		score := 0
+		for _, w := range list {
+			score += freq[w]
+		}
+		if score > bestScore {
+			best, bestScore, second = code, score, bestScore
+		} else if score > second {
+			second = score
+		}
+	}
+	// Require a clear lead, as the stopword lists overlap.
+	if bestScore < 3 || bestScore < 2*second {
+		return ""
+	}
+	return best
+}
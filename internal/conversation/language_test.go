@@ -0,0 +1,95 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+
+	"langdag.com/langdag/types"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"The quick brown fox jumps over the lazy dog, and it is not the first time that this has happened.", "en"},
+		{"Le renard brun saute par-dessus le chien, et ce n'est pas la première fois que cela arrive dans la forêt.", "fr"},
+		{"Der schnelle braune Fuchs springt über den faulen Hund, und das ist nicht das erste Mal, dass sich die Sache so ereignet.", "de"},
+		{"El rápido zorro marrón salta sobre el perro perezoso, y no es la primera vez que esto pasa en el bosque.", "es"},
+		{"Быстрая коричневая лиса прыгает через ленивую собаку.", "cyrillic"},
+		{"素早い茶色の狐がのろまな犬を飛び越えます。これは初めてではありません。", "ja"},
+		{"ok", ""},                             // too short
+		{"func main() { fmt.Println(x) }", ""}, // no stopwords
+	}
+	for _, tt := range tests {
+		if got := detectLanguage(tt.text); got != tt.want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestResolveLanguage(t *testing.T) {
+	if code, name := ResolveLanguage("FR"); code != "fr" || name != "French" {
+		t.Errorf("ResolveLanguage(FR) = %q, %q", code, name)
+	}
+	if code, name := ResolveLanguage("german"); code != "de" || name != "German" {
+		t.Errorf("ResolveLanguage(german) = %q, %q", code, name)
+	}
+	if code, name := ResolveLanguage("Esperanto"); code != "" || name != "Esperanto" {
+		t.Errorf("ResolveLanguage(Esperanto) = %q, %q", code, name)
+	}
+}
+
+func TestLanguageRetriesReplyInAnotherLanguage(t *testing.T) {
+	english := "The answer is that the cache is not invalidated when the file is renamed, and this is why it fails."
+	french := "La réponse est que le cache n'est pas invalidé quand le fichier est renommé, et c'est pour cela que ça échoue."
+	mgr, store, cleanup := newTestManagerWithSequence(t, []sequenceResponse{
+		{text: english},
+		{text: french},
+		{text: english},
+	})
+	defer cleanup()
+
+	ctx := ContextWithLanguage(context.Background(), "French")
+	events, err := mgr.Prompt(ctx, "Pourquoi ça échoue ?", "seq-mock", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodeID, content string
+	for e := range events {
+		switch e.Type {
+		case types.StreamEventDelta:
+			content += e.Content
+		case types.StreamEventNodeSaved:
+			nodeID = e.NodeID
+		case types.StreamEventError:
+			t.Fatal(e.Error)
+		}
+	}
+	if content != french {
+		t.Fatalf("streamed %q, want the retried French reply", content)
+	}
+	node, _ := store.GetNode(context.Background(), nodeID)
+	if node == nil || node.Content != french || node.SystemPrompt != "Respond in French." {
+		t.Fatalf("saved node = %+v", node)
+	}
+
+	// The DAG keeps its language: a reply in English is retried again.
+	root, _ := store.GetNode(context.Background(), node.RootID)
+	if meta := types.UserMetadataFromNode(root); meta == nil || meta.Language != "French" {
+		t.Fatalf("root metadata = %s", root.Metadata)
+	}
+	events, err = mgr.PromptFrom(context.Background(), nodeID, "Et maintenant ?", "seq-mock", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for e := range events {
+		if e.Type == types.StreamEventError {
+			if e.Error == nil {
+				t.Fatal("retry failed without an error")
+			}
+			return // the script has no reply left for the retry
+		}
+	}
+	t.Fatal("the English reply was not retried")
+}
@@ -0,0 +1,59 @@
+package conversation
+
+// MaxTokensOptions configures the max_tokens sent when a prompt does not
+// specify one.
+type MaxTokensOptions struct {
+	// Default applies to every model without a PerModel entry. Zero means
+	// the built-in default (16384).
+	Default int
+
+	// PerModel overrides Default for specific model IDs.
+	PerModel map[string]int
+}
+
+// SetMaxTokensOptions configures default max_tokens resolution.
+func (m *Manager) SetMaxTokensOptions(opts MaxTokensOptions) {
+	m.maxTokensOpts = opts
+}
+
+// resolveMaxTokens picks the max_tokens for a call: the requested value,
+// else the per-model default, else the global default, else
+// defaultMaxTokens. The result is clamped to the model's MaxOutput when the
+// model catalog knows it.
+func (m *Manager) resolveMaxTokens(model string, requested int) int {
+	maxTokens := requested
+	if maxTokens <= 0 {
+		maxTokens = m.maxTokensOpts.PerModel[model]
+	}
+	if maxTokens <= 0 {
+		maxTokens = m.maxTokensOpts.Default
+	}
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+	if limit := m.modelMaxOutput(model); limit > 0 && maxTokens > limit {
+		maxTokens = limit
+	}
+	return maxTokens
+}
+
+// modelMaxOutput returns the catalog MaxOutput for model (native or
+// canonical ID), or 0 when the model is unknown or reports no limit. The
+// catalog is consulted instead of provider.Models() because some providers
+// fetch their model list over the network.
+func (m *Manager) modelMaxOutput(model string) int {
+	if model == "" {
+		return 0
+	}
+	if cached, ok := m.maxOutputCache.Load(model); ok {
+		return cached.(int)
+	}
+	var maxOutput int
+	if catalog := getDefaultCatalog(); catalog != nil {
+		if info, _, ok := catalog.LookupModel(model); ok {
+			maxOutput = info.MaxOutput
+		}
+	}
+	m.maxOutputCache.Store(model, maxOutput)
+	return maxOutput
+}
@@ -0,0 +1,76 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/provider/mock"
+)
+
+func TestPrompt_MaxTokensUsesConfiguredDefaults(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	mgr.SetMaxTokensOptions(MaxTokensOptions{
+		Default:  2048,
+		PerModel: map[string]int{"mock-fast": 1024},
+	})
+
+	cases := []struct {
+		model string
+		want  int
+	}{
+		{"mock-fast", 1024},
+		{"mock-slow", 2048},
+	}
+	for _, tc := range cases {
+		events, err := mgr.Prompt(context.Background(), "hello", tc.model, "", nil, nil, 0, 0)
+		if err != nil {
+			t.Fatalf("Prompt(%s): %v", tc.model, err)
+		}
+		_ = drainEvents(t, events, 5*time.Second)
+		if prov.LastRequest.MaxTokens != tc.want {
+			t.Errorf("%s: MaxTokens = %d, want %d", tc.model, prov.LastRequest.MaxTokens, tc.want)
+		}
+	}
+}
+
+func TestPrompt_MaxTokensRequestOverridesDefaults(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	mgr.SetMaxTokensOptions(MaxTokensOptions{Default: 2048, PerModel: map[string]int{"mock-fast": 1024}})
+
+	events, err := mgr.Prompt(context.Background(), "hello", "mock-fast", "", nil, nil, 4000, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	_ = drainEvents(t, events, 5*time.Second)
+	if prov.LastRequest.MaxTokens != 4000 {
+		t.Errorf("MaxTokens = %d, want 4000", prov.LastRequest.MaxTokens)
+	}
+}
+
+func TestPrompt_MaxTokensClampedToModelMaxOutput(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	mgr.SetMaxTokensOptions(MaxTokensOptions{Default: 100000})
+
+	const model = "gpt-4o-mini"
+	limit := mgr.modelMaxOutput(model)
+	if limit <= 0 {
+		t.Fatalf("expected catalog MaxOutput for %s", model)
+	}
+
+	// Both a configured default and an explicit request above the model's
+	// MaxOutput are clamped.
+	for _, requested := range []int{0, limit + 1} {
+		events, err := mgr.Prompt(context.Background(), "hello", model, "", nil, nil, requested, 0)
+		if err != nil {
+			t.Fatalf("Prompt: %v", err)
+		}
+		_ = drainEvents(t, events, 5*time.Second)
+		if prov.LastRequest.MaxTokens != limit {
+			t.Errorf("requested %d: MaxTokens = %d, want %d", requested, prov.LastRequest.MaxTokens, limit)
+		}
+	}
+}
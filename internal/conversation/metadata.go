@@ -0,0 +1,57 @@
+package conversation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"langdag.com/langdag/types"
+)
+
+// MetadataOptions controls how per-request metadata is forwarded to providers.
+type MetadataOptions struct {
+	// HashUserIDs replaces metadata.user_id with a hex SHA-256 digest before
+	// it leaves the process, so providers never see raw application user IDs.
+	HashUserIDs bool
+
+	// UserIDSalt is prepended to the user ID before hashing. Ignored unless
+	// HashUserIDs is set.
+	UserIDSalt string
+}
+
+type requestMetadataKey struct{}
+
+// ContextWithRequestMetadata returns a child context carrying request
+// metadata. Prompts issued with this context forward the metadata to the
+// provider on every call, including max_tokens continuations.
+func ContextWithRequestMetadata(ctx context.Context, md *types.RequestMetadata) context.Context {
+	if md == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, requestMetadataKey{}, md)
+}
+
+// SetMetadataOptions configures how request metadata is forwarded.
+func (m *Manager) SetMetadataOptions(opts MetadataOptions) {
+	m.metadataOpts = opts
+}
+
+// requestMetadata returns the metadata to attach to provider requests, or nil
+// when ctx carries none.
+func (m *Manager) requestMetadata(ctx context.Context) *types.RequestMetadata {
+	md, _ := ctx.Value(requestMetadataKey{}).(*types.RequestMetadata)
+	if md == nil || md.UserID == "" {
+		return nil
+	}
+	out := *md
+	if m.metadataOpts.HashUserIDs {
+		out.UserID = hashUserID(m.metadataOpts.UserIDSalt, md.UserID)
+	}
+	return &out
+}
+
+// hashUserID returns the hex SHA-256 digest of salt+userID.
+func hashUserID(salt, userID string) string {
+	sum := sha256.Sum256([]byte(salt + userID))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,62 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/types"
+)
+
+func TestPromptForwardsRequestMetadata(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+
+	ctx := ContextWithRequestMetadata(context.Background(), &types.RequestMetadata{UserID: "user-42"})
+	events, err := mgr.Prompt(ctx, "hello", "", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	_ = drainEvents(t, events, 5*time.Second)
+
+	if got := prov.LastRequest.UserID(); got != "user-42" {
+		t.Errorf("UserID = %q, want %q", got, "user-42")
+	}
+}
+
+func TestPromptHashesUserIDWhenConfigured(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	mgr.SetMetadataOptions(MetadataOptions{HashUserIDs: true, UserIDSalt: "pepper"})
+
+	ctx := ContextWithRequestMetadata(context.Background(), &types.RequestMetadata{UserID: "user-42"})
+	events, err := mgr.Prompt(ctx, "hello", "", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	_ = drainEvents(t, events, 5*time.Second)
+
+	got := prov.LastRequest.UserID()
+	if got != hashUserID("pepper", "user-42") {
+		t.Errorf("UserID = %q, want salted hash", got)
+	}
+	if len(got) != 64 {
+		t.Errorf("expected 64-char hex digest, got %d chars", len(got))
+	}
+}
+
+func TestPromptWithoutMetadataSendsNone(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+
+	events, err := mgr.Prompt(context.Background(), "hello", "", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	_ = drainEvents(t, events, 5*time.Second)
+
+	if prov.LastRequest.Metadata != nil {
+		t.Errorf("expected nil Metadata, got %+v", prov.LastRequest.Metadata)
+	}
+}
@@ -0,0 +1,41 @@
+package conversation
+
+import "context"
+
+// Preset is a named bundle of prompt parameters. Empty fields leave the
+// corresponding request value unchanged.
+type Preset struct {
+	Model        string
+	SystemPrompt string
+	Temperature  *float64
+}
+
+// SetPresets configures the named presets available to Preset.
+func (m *Manager) SetPresets(presets map[string]Preset) {
+	m.presets = presets
+}
+
+// Preset returns the named preset.
+func (m *Manager) Preset(name string) (Preset, bool) {
+	p, ok := m.presets[name]
+	return p, ok
+}
+
+type temperatureKey struct{}
+
+// ContextWithTemperature returns a child context carrying a sampling
+// temperature. Prompts issued with this context send it on every provider
+// call, including max_tokens continuations.
+func ContextWithTemperature(ctx context.Context, temperature float64) context.Context {
+	return context.WithValue(ctx, temperatureKey{}, temperature)
+}
+
+// requestTemperature returns the temperature carried by ctx, or nil
+// (provider default) when there is none. An explicit 0 is kept.
+func requestTemperature(ctx context.Context) *float64 {
+	t, ok := ctx.Value(temperatureKey{}).(float64)
+	if !ok {
+		return nil
+	}
+	return &t
+}
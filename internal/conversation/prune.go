@@ -0,0 +1,73 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"langdag.com/langdag/types"
+)
+
+// failedStatuses are the statuses matched by the "failed" prune status:
+// replies that stopped before completing.
+var failedStatuses = []string{"cancelled", "timeout", "interrupted"}
+
+// PruneOptions selects the DAGs deleted by Prune.
+type PruneOptions struct {
+	// Before keeps every DAG with a node created at or after it.
+	Before time.Time
+	// Statuses, when set, limits pruning to DAGs with a node in one of
+	// these statuses. "failed" stands for cancelled, timeout and
+	// interrupted.
+	Statuses []string
+	// DryRun reports the DAGs without deleting them.
+	DryRun bool
+}
+
+// Prune deletes every DAG whose newest node was created before
+// opts.Before and that matches opts.Statuses, and returns their roots.
+// DAGs with a generation in progress and archived DAGs are skipped. Pruned
+// DAGs are not saved to the trash.
+func (m *Manager) Prune(ctx context.Context, opts PruneOptions) ([]*types.Node, error) {
+	roots, err := m.storage.ListRootNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pruned []*types.Node
+	for _, root := range roots {
+		if root.ArchivedURI != "" || m.hasActiveRun(root.ID) {
+			continue
+		}
+		nodes, err := m.storage.GetSubtree(ctx, root.ID)
+		if err != nil {
+			return pruned, err
+		}
+		if !olderThan(nodes, opts.Before) || !hasStatus(nodes, opts.Statuses) {
+			continue
+		}
+		if !opts.DryRun {
+			if err := m.storage.DeleteNode(ctx, root.ID); err != nil {
+				return pruned, fmt.Errorf("failed to prune %s: %w", root.ID, err)
+			}
+		}
+		pruned = append(pruned, root)
+	}
+	return pruned, nil
+}
+
+// hasStatus reports whether a node has one of statuses, or true if there
+// are none.
+func hasStatus(nodes []*types.Node, statuses []string) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	for _, n := range nodes {
+		for _, s := range statuses {
+			if n.Status == s || (s == "failed" && slices.Contains(failedStatuses, n.Status)) {
+				return true
+			}
+		}
+	}
+	return false
+}
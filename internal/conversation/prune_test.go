@@ -0,0 +1,55 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/types"
+)
+
+func TestPrune(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+
+	old := time.Now().Add(-40 * 24 * time.Hour)
+	nodes := []*types.Node{
+		{ID: "done", Sequence: 0, NodeType: types.NodeTypeUser, Content: "Hi", Status: "completed", CreatedAt: old},
+		{ID: "done-a", ParentID: "done", RootID: "done", Sequence: 1, NodeType: types.NodeTypeAssistant, Content: "Hello", Status: "completed", CreatedAt: old},
+		{ID: "failed", Sequence: 0, NodeType: types.NodeTypeUser, Content: "Hi", Status: "completed", CreatedAt: old},
+		{ID: "failed-a", ParentID: "failed", RootID: "failed", Sequence: 1, NodeType: types.NodeTypeAssistant, Content: "Hel", Status: "timeout", CreatedAt: old},
+		{ID: "active", Sequence: 0, NodeType: types.NodeTypeUser, Content: "Hi", Status: "completed", CreatedAt: old},
+		{ID: "active-a", ParentID: "active", RootID: "active", Sequence: 1, NodeType: types.NodeTypeAssistant, Content: "Hel", Status: "interrupted", CreatedAt: time.Now()},
+	}
+	for _, n := range nodes {
+		if err := store.CreateNode(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cutoff := time.Now().Add(-30 * 24 * time.Hour)
+
+	pruned, err := mgr.Prune(ctx, PruneOptions{Before: cutoff, Statuses: []string{"failed"}, DryRun: true})
+	if err != nil || len(pruned) != 1 || pruned[0].ID != "failed" {
+		t.Fatalf("dry run = %v, %v, want the failed DAG", pruned, err)
+	}
+	if node, _ := store.GetNode(ctx, "failed"); node == nil {
+		t.Fatal("dry run deleted the DAG")
+	}
+
+	if _, err := mgr.Prune(ctx, PruneOptions{Before: cutoff, Statuses: []string{"failed"}}); err != nil {
+		t.Fatal(err)
+	}
+	if node, _ := store.GetNode(ctx, "failed-a"); node != nil {
+		t.Error("failed DAG was not deleted")
+	}
+
+	pruned, err = mgr.Prune(ctx, PruneOptions{Before: cutoff})
+	if err != nil || len(pruned) != 1 || pruned[0].ID != "done" {
+		t.Fatalf("Prune = %v, %v, want the completed DAG only", pruned, err)
+	}
+	if node, _ := store.GetNode(ctx, "active"); node == nil {
+		t.Error("DAG with a recent node was deleted")
+	}
+}
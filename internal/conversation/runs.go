@@ -0,0 +1,136 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"langdag.com/langdag/types"
+)
+
+// ErrCancelled is the cause attached to generations stopped by CancelTree.
+var ErrCancelled = errors.New("generation cancelled")
+
+// ErrGenerationTimeout is the cause attached to generations stopped by the
+// generation timeout.
+var ErrGenerationTimeout = errors.New("generation timed out")
+
+// RunInfo describes a generation in progress.
+type RunInfo struct {
+	RootID    string
+	ParentID  string // node the generation is answering
+	Model     string
+	StartedAt time.Time
+}
+
+// activeRun is one in-flight generation.
+type activeRun struct {
+	info   RunInfo
+	cancel context.CancelCauseFunc
+}
+
+// trackRun registers a cancellable generation answering parent. The
+// returned release func must be called once the generation has finished.
+func (m *Manager) trackRun(ctx context.Context, parent *types.Node, model string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	ctx, stopTimeout := m.withGenerationTimeout(ctx)
+	rootID := parent.RootID
+	run := &activeRun{
+		info:   RunInfo{RootID: rootID, ParentID: parent.ID, Model: model, StartedAt: time.Now()},
+		cancel: cancel,
+	}
+
+	m.runsMu.Lock()
+	if m.runs == nil {
+		m.runs = make(map[string]map[*activeRun]struct{})
+	}
+	if m.runs[rootID] == nil {
+		m.runs[rootID] = make(map[*activeRun]struct{})
+	}
+	m.runs[rootID][run] = struct{}{}
+	m.runsMu.Unlock()
+
+	return ctx, func() {
+		m.runsMu.Lock()
+		delete(m.runs[rootID], run)
+		if len(m.runs[rootID]) == 0 {
+			delete(m.runs, rootID)
+		}
+		m.runsMu.Unlock()
+		stopTimeout()
+		cancel(nil)
+	}
+}
+
+// withGenerationTimeout returns a child context of ctx stopped with
+// ErrGenerationTimeout once the generation timeout has elapsed, and the
+// func releasing it. A deadline already set by ctx is kept when earlier.
+func (m *Manager) withGenerationTimeout(ctx context.Context) (context.Context, func()) {
+	if m.generationTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, m.generationTimeout, ErrGenerationTimeout)
+}
+
+// SetGenerationTimeout sets the longest a generation may run, including
+// its continuations and, for a prompt answered by built-in tools, every
+// round of the tool loop. A generation still running is stopped like by
+// CancelTree, with its partial reply saved with status "timeout". 0, the
+// default, sets no limit.
+func (m *Manager) SetGenerationTimeout(d time.Duration) {
+	m.generationTimeout = d
+}
+
+// ActiveRuns returns the generations currently in progress, oldest first.
+func (m *Manager) ActiveRuns() []RunInfo {
+	m.runsMu.Lock()
+	defer m.runsMu.Unlock()
+	var runs []RunInfo
+	for _, set := range m.runs {
+		for run := range set {
+			runs = append(runs, run.info)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.Before(runs[j].StartedAt) })
+	return runs
+}
+
+// CancelTree stops every generation running in the DAG containing nodeID
+// and returns how many were cancelled. Cancellation is cooperative: each
+// generation saves the text streamed so far as an assistant node with
+// status "cancelled", then ends its stream.
+func (m *Manager) CancelTree(ctx context.Context, nodeID string) (int, error) {
+	node, err := m.storage.GetNode(ctx, nodeID)
+	if err != nil {
+		return 0, err
+	}
+	if node == nil {
+		return 0, fmt.Errorf("node not found: %s", nodeID)
+	}
+	rootID := node.RootID
+	if rootID == "" {
+		rootID = node.ID
+	}
+
+	m.runsMu.Lock()
+	defer m.runsMu.Unlock()
+	for run := range m.runs[rootID] {
+		run.cancel(ErrCancelled)
+	}
+	return len(m.runs[rootID]), nil
+}
+
+// interruptedStatus returns the status for a node saved after ctx was
+// cancelled: "cancelled" when stopped by CancelTree, "timeout" when stopped
+// by the generation timeout, "interrupted" when the caller went away.
+func interruptedStatus(ctx context.Context) string {
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, ErrCancelled):
+		return "cancelled"
+	case errors.Is(cause, ErrGenerationTimeout):
+		return "timeout"
+	}
+	return "interrupted"
+}
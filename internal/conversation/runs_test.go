@@ -0,0 +1,197 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/internal/toolbox"
+	"langdag.com/langdag/types"
+)
+
+func TestCancelTreeStopsGenerationAndMarksNodeCancelled(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{
+		Mode:          "fixed",
+		FixedResponse: "one two three four five six seven eight",
+		ChunkDelay:    20 * time.Millisecond,
+	})
+	defer cleanup()
+	ctx := context.Background()
+
+	events, err := mgr.Prompt(ctx, "hello", "", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+
+	var savedID string
+	var cancelled bool
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				done = true
+				break
+			}
+			switch ev.Type {
+			case types.StreamEventDelta:
+				if !cancelled {
+					cancelled = true
+					roots, err := mgr.ListRoots(ctx)
+					if err != nil || len(roots) != 1 {
+						t.Fatalf("ListRoots: %v (%d roots)", err, len(roots))
+					}
+					if runs := mgr.ActiveRuns(); len(runs) != 1 || runs[0].RootID != roots[0].ID || runs[0].ParentID != roots[0].ID {
+						t.Errorf("ActiveRuns = %+v, want one run answering the root", runs)
+					}
+					n, err := mgr.CancelTree(ctx, roots[0].ID)
+					if err != nil {
+						t.Fatalf("CancelTree: %v", err)
+					}
+					if n != 1 {
+						t.Errorf("CancelTree cancelled %d generations, want 1", n)
+					}
+				}
+			case types.StreamEventError:
+				t.Fatalf("unexpected error event: %v", ev.Error)
+			case types.StreamEventNodeSaved:
+				savedID = ev.NodeID
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for events")
+		}
+	}
+
+	node, err := store.GetNode(ctx, savedID)
+	if err != nil || node == nil {
+		t.Fatalf("GetNode: %v (node=%v)", err, node)
+	}
+	if node.Status != "cancelled" {
+		t.Errorf("Status = %q, want cancelled", node.Status)
+	}
+
+	// Nothing is left running in the DAG.
+	if runs := mgr.ActiveRuns(); len(runs) != 0 {
+		t.Errorf("ActiveRuns after completion = %+v, want none", runs)
+	}
+	if n, err := mgr.CancelTree(ctx, savedID); err != nil || n != 0 {
+		t.Errorf("CancelTree after completion = %d, %v; want 0, nil", n, err)
+	}
+}
+
+func TestCancelTreeUnknownNode(t *testing.T) {
+	mgr, cleanup := newTestManager(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+
+	if _, err := mgr.CancelTree(context.Background(), "missing"); err == nil {
+		t.Fatal("expected error for unknown node")
+	}
+}
+
+func TestGenerationTimeoutSavesPartialReply(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{
+		Mode:          "fixed",
+		FixedResponse: "one two three four five six seven eight",
+		ChunkDelay:    30 * time.Millisecond,
+	})
+	defer cleanup()
+	mgr.SetGenerationTimeout(80 * time.Millisecond)
+	ctx := context.Background()
+
+	events, err := mgr.Prompt(ctx, "hello", "", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	var timeoutID, savedID string
+	for ev := range events {
+		switch ev.Type {
+		case types.StreamEventTimeout:
+			timeoutID = ev.NodeID
+		case types.StreamEventError:
+			t.Fatalf("unexpected error event: %v", ev.Error)
+		case types.StreamEventNodeSaved:
+			savedID = ev.NodeID
+		}
+	}
+	if timeoutID == "" || timeoutID != savedID {
+		t.Fatalf("timeout event node = %q, saved node = %q; want the same partial node", timeoutID, savedID)
+	}
+
+	node, err := store.GetNode(ctx, savedID)
+	if err != nil || node == nil {
+		t.Fatalf("GetNode: %v (node=%v)", err, node)
+	}
+	if node.Status != "timeout" {
+		t.Errorf("Status = %q, want timeout", node.Status)
+	}
+	if node.Content == "" || node.Content == "one two three four five six seven eight" {
+		t.Errorf("Content = %q, want a partial reply", node.Content)
+	}
+	if runs := mgr.ActiveRuns(); len(runs) != 0 {
+		t.Errorf("ActiveRuns = %+v, want none", runs)
+	}
+}
+
+func TestGenerationTimeoutCoversToolLoop(t *testing.T) {
+	// Each round is well under the timeout, but the rounds of the tool
+	// loop together are not.
+	mgr, _, cleanup := newTestManagerWithStore(t, mock.Config{
+		Mode:      "tool_use",
+		Delay:     40 * time.Millisecond,
+		ToolCalls: []mock.ToolCallConfig{{Name: "file_read", Input: json.RawMessage(`{"path":"notes.txt"}`)}},
+	})
+	defer cleanup()
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "notes.txt"), []byte("hello"), 0644)
+	tb, err := toolbox.New(toolbox.Options{Files: toolbox.FilesOptions{Root: root}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetToolbox(tb)
+	mgr.SetGenerationTimeout(150 * time.Millisecond)
+
+	events, err := mgr.Prompt(context.Background(), "hello", "", "", []types.ToolDefinition{{Name: "file_read"}}, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	rounds, stopped := 0, false
+	for _, ev := range drainEvents(t, events, 5*time.Second) {
+		switch ev.Type {
+		case types.StreamEventContentDone:
+			if ev.ContentBlock.Type == "tool_result" {
+				rounds++
+			}
+		case types.StreamEventTimeout, types.StreamEventError:
+			stopped = true
+		}
+	}
+	if !stopped || rounds >= maxToolRounds {
+		t.Errorf("stopped = %v after %d rounds, want the tool loop stopped by the timeout", stopped, rounds)
+	}
+}
+
+func TestGenerationTimeoutNotReached(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	mgr.SetGenerationTimeout(5 * time.Second)
+
+	events, err := mgr.Prompt(context.Background(), "hello", "", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	for ev := range events {
+		if ev.Type == types.StreamEventTimeout {
+			t.Fatal("unexpected timeout event")
+		}
+		if ev.Type == types.StreamEventNodeSaved {
+			node, _ := store.GetNode(context.Background(), ev.NodeID)
+			if node == nil || node.Status != "completed" {
+				t.Errorf("node = %+v, want completed", node)
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+
+	"langdag.com/langdag/types"
+)
+
+type stopSequencesKey struct{}
+
+// ContextWithStopSequences returns a child context carrying stop
+// sequences: generation stops before any of them. Like the temperature, a
+// DAG started with this context keeps them for later prompts.
+func ContextWithStopSequences(ctx context.Context, stops []string) context.Context {
+	return context.WithValue(ctx, stopSequencesKey{}, stops)
+}
+
+func contextStopSequences(ctx context.Context) []string {
+	stops, _ := ctx.Value(stopSequencesKey{}).([]string)
+	return stops
+}
+
+// requestSampling returns the sampling parameters set by the request: those
+// carried by ctx and maxTokens.
+func requestSampling(ctx context.Context, maxTokens int) *types.SamplingParams {
+	p := &types.SamplingParams{Temperature: requestTemperature(ctx), MaxTokens: maxTokens, StopSequences: contextStopSequences(ctx)}
+	if p.IsZero() {
+		return nil
+	}
+	return p
+}
+
+// dagSampling completes the request's sampling parameters with those the
+// DAG containing parent was started with. It returns ctx carrying the
+// result, the max tokens to use (0 for the default) and the parameters to
+// record on the reply.
+func (m *Manager) dagSampling(ctx context.Context, parent *types.Node, maxTokens int) (context.Context, int, *types.SamplingParams) {
+	p := requestSampling(ctx, maxTokens)
+	if p == nil {
+		p = &types.SamplingParams{}
+	}
+	root := parent
+	if parent.RootID != "" && parent.RootID != parent.ID {
+		if r, err := m.storage.GetNode(ctx, parent.RootID); err == nil && r != nil {
+			root = r
+		}
+	}
+	if meta := types.UserMetadataFromNode(root); meta != nil && meta.Sampling != nil {
+		if p.Temperature == nil && meta.Sampling.Temperature != nil {
+			p.Temperature = meta.Sampling.Temperature
+			ctx = ContextWithTemperature(ctx, *p.Temperature)
+		}
+		if p.MaxTokens == 0 {
+			p.MaxTokens = meta.Sampling.MaxTokens
+		}
+		if p.StopSequences == nil && meta.Sampling.StopSequences != nil {
+			p.StopSequences = meta.Sampling.StopSequences
+			ctx = ContextWithStopSequences(ctx, p.StopSequences)
+		}
+	}
+	if p.IsZero() {
+		return ctx, p.MaxTokens, nil
+	}
+	return ctx, p.MaxTokens, p
+}
+
+// withSampling returns assistant node metadata recording the sampling
+// parameters of the reply.
+func withSampling(metadata json.RawMessage, p *types.SamplingParams) json.RawMessage {
+	if p == nil {
+		return metadata
+	}
+	var meta types.AssistantNodeMetadata
+	if len(metadata) > 0 && json.Unmarshal(metadata, &meta) != nil {
+		return metadata
+	}
+	meta.Sampling = p
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return metadata
+	}
+	return data
+}
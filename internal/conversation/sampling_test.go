@@ -0,0 +1,73 @@
+package conversation
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/types"
+)
+
+func TestPromptFrom_KeepsDAGSampling(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := ContextWithStopSequences(ContextWithTemperature(context.Background(), 0.3), []string{"END"})
+
+	events, err := mgr.Prompt(ctx, "hello", "mock-fast", "", nil, nil, 500, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	first := savedNodeID(t, events)
+
+	events, err = mgr.PromptFrom(context.Background(), first, "again", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("PromptFrom: %v", err)
+	}
+	reply, _ := mgr.storage.GetNode(context.Background(), savedNodeID(t, events))
+	req := prov.LastRequest
+	if req.Temperature == nil || *req.Temperature != 0.3 || req.MaxTokens != 500 || !reflect.DeepEqual(req.StopSeqs, []string{"END"}) {
+		t.Errorf("request = temperature %v, max_tokens %d, stop %v; want the DAG's 0.3, 500, [END]", req.Temperature, req.MaxTokens, req.StopSeqs)
+	}
+	meta, err := types.ParseAssistantNodeMetadata(reply.Metadata)
+	if err != nil || meta.Sampling == nil {
+		t.Fatalf("reply metadata = %s, want sampling", reply.Metadata)
+	}
+	if meta.Sampling.Temperature == nil || *meta.Sampling.Temperature != 0.3 || meta.Sampling.MaxTokens != 500 {
+		t.Errorf("sampling = %+v", meta.Sampling)
+	}
+
+	// Parameters set by a later prompt win over the DAG's.
+	events, err = mgr.PromptFrom(ContextWithStopSequences(context.Background(), []string{"STOP"}), reply.ID, "more", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("PromptFrom: %v", err)
+	}
+	_ = drainEvents(t, events, 5*time.Second)
+	if got := prov.LastRequest.StopSeqs; !reflect.DeepEqual(got, []string{"STOP"}) {
+		t.Errorf("StopSeqs = %v, want [STOP]", got)
+	}
+}
+
+func TestPrompt_SendsExplicitZeroTemperature(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+
+	events, err := mgr.Prompt(ContextWithTemperature(context.Background(), 0), "hello", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	savedNodeID(t, events)
+	if got := prov.LastRequest.Temperature; got == nil || *got != 0 {
+		t.Errorf("Temperature = %v, want an explicit 0", got)
+	}
+
+	events, err = mgr.Prompt(context.Background(), "hello", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	savedNodeID(t, events)
+	if got := prov.LastRequest.Temperature; got != nil {
+		t.Errorf("Temperature = %v, want unset", *got)
+	}
+}
@@ -0,0 +1,223 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"langdag.com/langdag/types"
+)
+
+// snippetRadius is the number of runes kept on each side of a match.
+const snippetRadius = 40
+
+// SearchMatch is a node whose content matched a search.
+type SearchMatch struct {
+	Node *types.Node
+	// Path lists node IDs from the DAG root down to the matching node,
+	// identifying which branch the match lives on.
+	Path []string
+	// Snippet is the matched text with some surrounding context.
+	Snippet string
+	// Score is the cosine similarity of SemanticSearch matches to the
+	// query, up to 1. It is 0 for text searches.
+	Score float64
+}
+
+// SearchTree searches the content of every node in the DAG containing nodeID
+// for query (case-insensitive). Matches are returned in sequence order.
+func (m *Manager) SearchTree(ctx context.Context, nodeID, query string) ([]SearchMatch, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+
+	node, err := m.storage.GetNode(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("node not found: %s", nodeID)
+	}
+	rootID := node.RootID
+	if rootID == "" {
+		rootID = node.ID
+	}
+
+	nodes, err := m.storage.GetSubtree(ctx, rootID)
+	if err != nil {
+		return nil, err
+	}
+	nodes = withoutSpeculative(nodes)
+
+	byID := make(map[string]*types.Node, len(nodes))
+	for _, n := range nodes {
+		byID[n.ID] = n
+	}
+
+	needle := strings.ToLower(query)
+	var matches []SearchMatch
+	for _, n := range nodes {
+		snippet, ok := matchSnippet(n.Content, needle)
+		if !ok {
+			continue
+		}
+		matches = append(matches, SearchMatch{
+			Node:    n,
+			Path:    pathFromRoot(byID, n),
+			Snippet: snippet,
+		})
+	}
+	return matches, nil
+}
+
+// DefaultSearchLimit is the number of matches Search returns when no limit
+// is given.
+const DefaultSearchLimit = 20
+
+// Search finds nodes in every DAG whose content contains each term of query,
+// best matches first, returning at most limit matches (DefaultSearchLimit
+// if limit <= 0). DAGs not visible to the request are skipped.
+func (m *Manager) Search(ctx context.Context, query string, limit int) ([]SearchMatch, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+
+	terms := strings.Fields(strings.ToLower(query))
+	return collectMatches(limit, func(n int) ([]*types.Node, error) {
+		return m.storage.SearchNodes(ctx, query, n)
+	}, func(n *types.Node) (*SearchMatch, error) {
+		path, ok, err := m.visiblePath(ctx, n)
+		if !ok || err != nil {
+			return nil, err
+		}
+		return &SearchMatch{Node: n, Path: path, Snippet: termsSnippet(n.Content, terms)}, nil
+	})
+}
+
+// maxSearchFetch bounds how many candidates a search fetches while looking
+// for matches the request may see.
+const maxSearchFetch = 2000
+
+// collectMatches returns up to limit matches among the candidates returned
+// by fetch, best first, as made by match (nil to skip one). Skipped
+// candidates, such as nodes of DAGs hidden from the request, would leave
+// fewer than limit matches, so fetch is called again with a larger limit
+// until there are enough or no more candidates.
+func collectMatches[T any](limit int, fetch func(n int) ([]T, error), match func(T) (*SearchMatch, error)) ([]SearchMatch, error) {
+	for n := limit; ; n *= 4 {
+		candidates, err := fetch(n)
+		if err != nil {
+			return nil, err
+		}
+		matches := make([]SearchMatch, 0, limit)
+		for _, c := range candidates {
+			if len(matches) == limit {
+				break
+			}
+			sm, err := match(c)
+			if err != nil {
+				return nil, err
+			}
+			if sm != nil {
+				matches = append(matches, *sm)
+			}
+		}
+		if len(matches) == limit || len(candidates) < n || n >= maxSearchFetch {
+			return matches, nil
+		}
+	}
+}
+
+// visiblePath returns the path from the root to n, and false if n is
+// speculative or its DAG is hidden from the request of ctx.
+func (m *Manager) visiblePath(ctx context.Context, n *types.Node) ([]string, bool, error) {
+	if n.Status == SpeculativeStatus {
+		return nil, false, nil
+	}
+	ancestors, err := m.storage.GetAncestors(ctx, n.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(ancestors) > 0 && !VisibleTo(ctx, ancestors[0]) {
+		return nil, false, nil
+	}
+	path := make([]string, len(ancestors))
+	for i, a := range ancestors {
+		path[i] = a.ID
+	}
+	return path, true, nil
+}
+
+// termsSnippet returns context around the first term found in content, or
+// the start of content if no term appears verbatim (full-text matching can
+// match on word prefixes only).
+func termsSnippet(content string, terms []string) string {
+	if snippet, ok := matchSnippet(content, strings.Join(terms, " ")); ok {
+		return snippet
+	}
+	for _, t := range terms {
+		if snippet, ok := matchSnippet(content, t); ok {
+			return snippet
+		}
+	}
+	runes := []rune(content)
+	if len(runes) > 2*snippetRadius {
+		return strings.Join(strings.Fields(string(runes[:2*snippetRadius])), " ") + "…"
+	}
+	return strings.Join(strings.Fields(content), " ")
+}
+
+// pathFromRoot walks parent links from n up to the root and returns the IDs
+// root-first.
+func pathFromRoot(byID map[string]*types.Node, n *types.Node) []string {
+	var path []string
+	for cur := n; cur != nil; cur = byID[cur.ParentID] {
+		path = append(path, cur.ID)
+		if cur.ParentID == "" {
+			break
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// matchSnippet reports whether content contains needle (already lowercased)
+// and returns the first occurrence with surrounding context.
+func matchSnippet(content, needle string) (string, bool) {
+	// Work on the lowercased text so byte offsets line up with the match.
+	lower := strings.ToLower(content)
+	byteIdx := strings.Index(lower, needle)
+	if byteIdx < 0 {
+		return "", false
+	}
+
+	runes := []rune(lower)
+	if n := []rune(content); len(n) == len(runes) {
+		runes = n
+	}
+	idx := utf8.RuneCountInString(lower[:byteIdx])
+
+	start := idx - snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	end := idx + utf8.RuneCountInString(needle) + snippetRadius
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	snippet := strings.Join(strings.Fields(string(runes[start:end])), " ")
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet, true
+}
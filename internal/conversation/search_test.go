@@ -0,0 +1,153 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/types"
+)
+
+func TestSearchTreeReturnsMatchesWithBranchPath(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+
+	// root → a1 → u2a (mentions "Kubernetes")
+	//         └→ u2b
+	// other (separate DAG, also mentions "Kubernetes")
+	nodes := []*types.Node{
+		{ID: "root", Sequence: 0, NodeType: types.NodeTypeUser, Content: "Let's plan the deploy", CreatedAt: time.Now()},
+		{ID: "a1", ParentID: "root", RootID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant, Content: "Sure.", CreatedAt: time.Now()},
+		{ID: "u2a", ParentID: "a1", RootID: "root", Sequence: 2, NodeType: types.NodeTypeUser, Content: "Should we use kubernetes here?", CreatedAt: time.Now()},
+		{ID: "u2b", ParentID: "a1", RootID: "root", Sequence: 3, NodeType: types.NodeTypeUser, Content: "What about VMs?", CreatedAt: time.Now()},
+		{ID: "other", Sequence: 0, NodeType: types.NodeTypeUser, Content: "Kubernetes is great", CreatedAt: time.Now()},
+	}
+	for _, n := range nodes {
+		if err := store.CreateNode(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Searching from any node in the DAG covers the whole DAG.
+	matches, err := mgr.SearchTree(ctx, "u2b", "KUBERNETES")
+	if err != nil {
+		t.Fatalf("SearchTree: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 match, got %d", len(matches))
+	}
+	m := matches[0]
+	if m.Node.ID != "u2a" {
+		t.Errorf("matched node = %q, want u2a", m.Node.ID)
+	}
+	if strings.Join(m.Path, ",") != "root,a1,u2a" {
+		t.Errorf("Path = %v, want [root a1 u2a]", m.Path)
+	}
+	if !strings.Contains(m.Snippet, "kubernetes") {
+		t.Errorf("Snippet %q does not contain the match", m.Snippet)
+	}
+}
+
+func TestSearchTreeRequiresQuery(t *testing.T) {
+	mgr, cleanup := newTestManager(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+
+	if _, err := mgr.SearchTree(context.Background(), "anything", "  "); err == nil {
+		t.Fatal("expected error for empty query")
+	}
+}
+
+func TestMatchSnippetTrimsLongContent(t *testing.T) {
+	content := strings.Repeat("a", 100) + "needle" + strings.Repeat("b", 100)
+	snippet, ok := matchSnippet(content, "needle")
+	if !ok {
+		t.Fatal("expected a match")
+	}
+	want := "…" + strings.Repeat("a", snippetRadius) + "needle" + strings.Repeat("b", snippetRadius) + "…"
+	if snippet != want {
+		t.Errorf("snippet = %q, want %q", snippet, want)
+	}
+}
+
+func TestSearchFindsNodesAcrossDAGs(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+
+	nodes := []*types.Node{
+		{ID: "root", Sequence: 0, NodeType: types.NodeTypeUser, Content: "Let's plan the deploy", CreatedAt: time.Now()},
+		{ID: "a1", ParentID: "root", RootID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant, Content: "Should we use Kubernetes clusters?", CreatedAt: time.Now()},
+		{ID: "other", Sequence: 0, NodeType: types.NodeTypeUser, Content: "kubernetes is great", CreatedAt: time.Now()},
+		{ID: "vm", Sequence: 0, NodeType: types.NodeTypeUser, Content: "What about VMs?", CreatedAt: time.Now()},
+	}
+	for _, n := range nodes {
+		if err := store.CreateNode(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	matches, err := mgr.Search(ctx, "Kubernetes", 0)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	got := map[string]string{}
+	for _, m := range matches {
+		got[m.Node.ID] = strings.Join(m.Path, ",")
+	}
+	if len(got) != 2 || got["a1"] != "root,a1" || got["other"] != "other" {
+		t.Errorf("matches = %v, want a1 (root,a1) and other", got)
+	}
+
+	// Terms are ANDed and may match word prefixes.
+	matches, err = mgr.Search(ctx, "kube cluster", 0)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Node.ID != "a1" {
+		t.Fatalf("expected only a1, got %d matches", len(matches))
+	}
+	if !strings.Contains(matches[0].Snippet, "Kubernetes") {
+		t.Errorf("Snippet %q does not contain the match", matches[0].Snippet)
+	}
+
+	// Query syntax characters are treated as text.
+	if _, err := mgr.Search(ctx, `"VMs?" OR (`, 0); err != nil {
+		t.Errorf("Search with punctuation: %v", err)
+	}
+	if _, err := mgr.Search(ctx, " ", 0); err == nil {
+		t.Error("expected error for empty query")
+	}
+}
+
+func TestSearchSkipsHiddenDAGsBeforeTheLimit(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+
+	private, _ := json.Marshal(types.UserNodeMetadata{Owner: "k1", Visibility: types.VisibilityPrivate})
+	// The private DAGs rank first: shorter content matches better.
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("private%d", i)
+		n := &types.Node{ID: id, RootID: id, NodeType: types.NodeTypeUser, Content: "deploy", Metadata: private, CreatedAt: time.Now()}
+		if err := store.CreateNode(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	team := &types.Node{ID: "team", RootID: "team", NodeType: types.NodeTypeUser, Content: "we deploy the service on Friday after the review", CreatedAt: time.Now()}
+	if err := store.CreateNode(ctx, team); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := mgr.Search(ContextWithAPIKey(ctx, "k2"), "deploy", 1)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Node.ID != "team" {
+		t.Errorf("matches = %+v, want the team DAG", matches)
+	}
+}
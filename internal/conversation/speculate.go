@@ -0,0 +1,348 @@
+package conversation
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"langdag.com/langdag/internal/storage"
+	"langdag.com/langdag/types"
+)
+
+// SpeculativeStatus is the status of the nodes of a speculative branch: a
+// follow-up suggested by the model and its reply, generated ahead of time
+// and hidden until the follow-up is prompted.
+const SpeculativeStatus = types.NodeStatusSpeculative
+
+// SpeculationOptions configures speculative replies (experimental). After
+// each reply, the model suggests likely follow-up prompts and replies to
+// them are generated in the background, one at a time, as hidden branches
+// of the reply. Prompting one of the suggestions from the reply returns
+// its reply at once.
+type SpeculationOptions struct {
+	Enabled bool
+
+	// Suggestions is how many follow-ups are answered ahead of time
+	// (default 3, at most 5).
+	Suggestions int
+
+	// Model suggests the follow-ups; by default the cheapest model of the
+	// reply's provider. Speculative replies use the reply's model.
+	Model string
+
+	// MaxTokens bounds each speculative reply (default 1024). Replies that
+	// do not fit are discarded.
+	MaxTokens int
+
+	// DailyTokenBudget bounds the input and output tokens spent on
+	// speculation per UTC day (default 200000).
+	DailyTokenBudget int
+
+	// TTL is how long unused speculative branches are kept before
+	// PurgeSpeculative deletes them (default 1h).
+	TTL time.Duration
+}
+
+const (
+	defaultSpeculativeSuggestions = 3
+	maxSpeculativeSuggestions     = 5
+	defaultSpeculativeMaxTokens   = 1024
+	defaultSpeculationBudget      = 200000
+	defaultSpeculationTTL         = time.Hour
+	speculationTimeout            = 2 * time.Minute
+)
+
+// SetSpeculationOptions configures speculative replies, filling in
+// defaults.
+func (m *Manager) SetSpeculationOptions(opts SpeculationOptions) {
+	if opts.Suggestions <= 0 {
+		opts.Suggestions = defaultSpeculativeSuggestions
+	}
+	opts.Suggestions = min(opts.Suggestions, maxSpeculativeSuggestions)
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = defaultSpeculativeMaxTokens
+	}
+	if opts.DailyTokenBudget <= 0 {
+		opts.DailyTokenBudget = defaultSpeculationBudget
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = defaultSpeculationTTL
+	}
+	m.speculation = opts
+}
+
+// SpeculationTTL returns how long unused speculative branches are kept.
+func (m *Manager) SpeculationTTL() time.Duration {
+	return m.speculation.TTL
+}
+
+// spendSpeculation records tokens spent on speculation and reports whether
+// budget is left for more.
+func (m *Manager) spendSpeculation(tokens int) bool {
+	m.specMu.Lock()
+	defer m.specMu.Unlock()
+	if day := time.Now().UTC().Format(time.DateOnly); day != m.specDay {
+		m.specDay, m.specSpent = day, 0
+	}
+	m.specSpent += tokens
+	return m.specSpent < m.speculation.DailyTokenBudget
+}
+
+// speculateInBackground generates speculative branches below reply, when
+// speculation is enabled and no other speculation is running. Failures
+// are logged, not reported.
+func (m *Manager) speculateInBackground(parent, reply *types.Node) {
+	if !m.speculation.Enabled || reply.StopReason == "tool_use" || reply.StopReason == "max_tokens" {
+		return
+	}
+	if meta := types.UserMetadataFromNode(parent); meta != nil && meta.Meta {
+		return
+	}
+	m.specMu.Lock()
+	if m.specBusy {
+		m.specMu.Unlock()
+		return // low priority: skip rather than queue
+	}
+	m.specBusy = true
+	m.specMu.Unlock()
+
+	m.background.Add(1)
+	go func() {
+		defer m.background.Done()
+		defer func() {
+			m.specMu.Lock()
+			m.specBusy = false
+			m.specMu.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), speculationTimeout)
+		defer cancel()
+		if err := m.speculate(ctx, reply); err != nil {
+			slog.WarnContext(ctx, "speculation: failed to speculate", "node_id", reply.ID, "error", err)
+		}
+	}()
+}
+
+// speculate asks for follow-ups to reply and answers them.
+func (m *Manager) speculate(ctx context.Context, reply *types.Node) error {
+	if !m.spendSpeculation(0) {
+		return nil
+	}
+	ancestors, err := m.storage.GetAncestors(ctx, reply.ID)
+	if err != nil || len(ancestors) == 0 {
+		return err
+	}
+	root := ancestors[0]
+	if len(dagTools(root)) > 0 {
+		return nil // replies would depend on tool calls
+	}
+
+	followUps, err := m.suggestFollowUps(ctx, ancestors)
+	if err != nil {
+		return err
+	}
+	history := buildMessages(ancestors)
+	for _, followUp := range followUps {
+		user := &types.Node{
+			ID:        m.newID(),
+			ParentID:  reply.ID,
+			RootID:    reply.RootID,
+			Sequence:  reply.Sequence + 1,
+			NodeType:  types.NodeTypeUser,
+			Content:   followUp,
+			Status:    SpeculativeStatus,
+			CreatedAt: time.Now(),
+		}
+		m.annotateGlossary(user)
+		// Built as for a prompt, so that the reply fits when it is taken.
+		messages := append(history[:len(history):len(history)], types.Message{Role: "user", Content: contentToRawMessage(followUp)})
+		_, req, info := m.buildRequest(ctx, user, messages, reply.Model, root.SystemPrompt, m.speculation.MaxTokens)
+		start := time.Now()
+		resp, err := m.provider.Complete(ctx, req)
+		if err != nil {
+			return err
+		}
+		budgetLeft := m.spendSpeculation(resp.Usage.InputTokens + resp.Usage.OutputTokens)
+		text := responseText(resp)
+		if resp.StopReason != "max_tokens" && !hasNonTextBlocks(resp.Content) && strings.TrimSpace(text) != "" {
+			if err := m.saveSpeculation(ctx, user, req, info, text, resp, time.Since(start)); err != nil {
+				return err
+			}
+		}
+		if !budgetLeft {
+			slog.InfoContext(ctx, "speculation: daily token budget spent", "budget", m.speculation.DailyTokenBudget)
+			return nil
+		}
+	}
+	return nil
+}
+
+// suggestFollowUps asks the suggestion model for likely next prompts.
+func (m *Manager) suggestFollowUps(ctx context.Context, ancestors []*types.Node) ([]string, error) {
+	model := m.speculation.Model
+	if model == "" {
+		model = cheapestModel(ancestors[len(ancestors)-1].Model)
+	}
+	followUps, usage, err := m.followUps(ctx, ancestors, model, m.speculation.Suggestions)
+	if err != nil {
+		return nil, err
+	}
+	m.spendSpeculation(usage.InputTokens + usage.OutputTokens)
+	return followUps, nil
+}
+
+// saveSpeculation stores user, a speculative follow-up, and the reply
+// generated by req as a hidden branch.
+func (m *Manager) saveSpeculation(ctx context.Context, user *types.Node, req *types.CompletionRequest, info requestInfo, text string, resp *types.CompletionResponse, latency time.Duration) error {
+	answer := &types.Node{
+		ID:                  m.newID(),
+		ParentID:            user.ID,
+		RootID:              user.RootID,
+		Sequence:            user.Sequence + 1,
+		NodeType:            types.NodeTypeAssistant,
+		Content:             text,
+		Provider:            resp.Provider,
+		Model:               req.Model,
+		TokensIn:            resp.Usage.InputTokens,
+		TokensOut:           resp.Usage.OutputTokens,
+		TokensCacheRead:     resp.Usage.CacheReadInputTokens,
+		TokensCacheCreation: resp.Usage.CacheCreationInputTokens,
+		TokensReasoning:     resp.Usage.ReasoningTokens,
+		StopReason:          resp.StopReason,
+		Status:              SpeculativeStatus,
+		LatencyMs:           int(latency.Milliseconds()),
+		Metadata:            withSampling(assistantMetadataJSON(resp), info.sampling),
+		CreatedAt:           time.Now(),
+	}
+	if info.layered {
+		answer.SystemPrompt = req.System
+	}
+	// Both are saved or neither, so no follow-up is left without a reply.
+	return m.storage.WithTx(ctx, func(tx storage.NodeWriter) error {
+		if err := tx.CreateNode(ctx, user); err != nil {
+			return err
+		}
+		return tx.CreateNode(ctx, answer)
+	})
+}
+
+// takeSpeculation returns the speculative reply to message below parentID,
+// made visible, as a stream. Speculative branches of parentID not taken are
+// deleted, as the conversation went on without them. It returns nil when
+// no speculative reply fits the request.
+func (m *Manager) takeSpeculation(ctx context.Context, parentID, message, model string, tools []types.ToolDefinition) *types.Node {
+	if !m.speculation.Enabled {
+		return nil
+	}
+	children, err := m.storage.GetNodeChildren(ctx, parentID)
+	if err != nil {
+		return nil
+	}
+	// Per-request settings the speculative reply was not generated with.
+	compatible := len(tools) == 0 && requestTemperature(ctx) == nil && len(contextStopSequences(ctx)) == 0 && contextLanguage(ctx) == ""
+	var taken *types.Node
+	for _, child := range children {
+		if child.Status != SpeculativeStatus {
+			continue
+		}
+		if compatible && taken == nil && child.Content == strings.TrimSpace(message) {
+			answers, err := m.storage.GetNodeChildren(ctx, child.ID)
+			if err == nil && len(answers) == 1 && (model == "" || model == answers[0].Model) {
+				child.Status, answers[0].Status = "completed", "completed"
+				if m.storage.UpdateNode(ctx, child) == nil && m.storage.UpdateNode(ctx, answers[0]) == nil {
+					taken = answers[0]
+					m.speculateInBackground(child, taken)
+					continue
+				}
+			}
+		}
+		if err := m.storage.DeleteNode(ctx, child.ID); err != nil {
+			slog.WarnContext(ctx, "speculation: failed to delete unused branch", "node_id", child.ID, "error", err)
+		}
+	}
+	return taken
+}
+
+// replayNode streams a saved reply as if it were being generated.
+func replayNode(node *types.Node) <-chan types.StreamEvent {
+	events := make(chan types.StreamEvent, 4)
+	events <- types.StreamEvent{Type: types.StreamEventStart}
+	events <- types.StreamEvent{Type: types.StreamEventDelta, Content: node.Content}
+	events <- types.StreamEvent{Type: types.StreamEventDone, Response: &types.CompletionResponse{
+		Model:      node.Model,
+		Provider:   node.Provider,
+		Content:    []types.ContentBlock{{Type: "text", Text: node.Content}},
+		StopReason: node.StopReason,
+		Usage:      types.Usage{InputTokens: node.TokensIn, OutputTokens: node.TokensOut},
+	}}
+	events <- types.StreamEvent{Type: types.StreamEventNodeSaved, NodeID: node.ID}
+	close(events)
+	return events
+}
+
+// PurgeSpeculative deletes the speculative branches created before cutoff
+// that were not taken, and returns how many were deleted.
+func (m *Manager) PurgeSpeculative(ctx context.Context, cutoff time.Time) (int, error) {
+	roots, err := m.storage.ListRootNodes(ctx)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, root := range roots {
+		if root.ArchivedURI != "" {
+			continue
+		}
+		nodes, err := m.storage.GetSubtree(ctx, root.ID)
+		if err != nil {
+			return purged, err
+		}
+		for _, n := range nodes {
+			if n.Status != SpeculativeStatus || n.NodeType != types.NodeTypeUser || !n.CreatedAt.Before(cutoff) {
+				continue
+			}
+			if err := m.storage.DeleteNode(ctx, n.ID); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// withoutSpeculative returns nodes without the speculative branches.
+func withoutSpeculative(nodes []*types.Node) []*types.Node {
+	visible := nodes[:0:0]
+	for _, n := range nodes {
+		if n.Status != SpeculativeStatus {
+			visible = append(visible, n)
+		}
+	}
+	return visible
+}
+
+// responseText returns the text blocks of a response.
+func responseText(resp *types.CompletionResponse) string {
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String()
+}
+
+// Suggestions returns the follow-ups to nodeID whose replies are ready:
+// prompting one of them from nodeID returns its reply at once.
+func (m *Manager) Suggestions(ctx context.Context, nodeID string) ([]string, error) {
+	children, err := m.storage.GetNodeChildren(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := []string{}
+	for _, child := range children {
+		if child.Status == SpeculativeStatus && child.NodeType == types.NodeTypeUser {
+			suggestions = append(suggestions, child.Content)
+		}
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,93 @@
+package conversation
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/provider/mock"
+)
+
+func TestSpeculativeReplyIsHiddenUntilTaken(t *testing.T) {
+	// The fixed response doubles as the suggested follow-up.
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "Tell me more"})
+	defer cleanup()
+	ctx := context.Background()
+	mgr.SetSpeculationOptions(SpeculationOptions{Enabled: true})
+
+	events, err := mgr.Prompt(ctx, "Hello", "", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyID := savedNodeID(t, events)
+	mgr.Wait()
+
+	suggestions, err := mgr.Suggestions(ctx, replyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) != 1 || suggestions[0] != "Tell me more" {
+		t.Fatalf("suggestions = %q", suggestions)
+	}
+	reply, _ := store.GetNode(ctx, replyID)
+	tree, err := mgr.GetSubtree(ctx, reply.RootID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree) != 2 {
+		t.Errorf("tree has %d nodes, want the 2 visible ones", len(tree))
+	}
+
+	events, err = mgr.PromptFrom(ctx, replyID, "Tell me more", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taken, _ := store.GetNode(ctx, savedNodeID(t, events))
+	if taken.Status != "completed" || taken.Content != "Tell me more" {
+		t.Errorf("taken reply = %+v", taken)
+	}
+	mgr.Wait()
+	if tree, _ = mgr.GetSubtree(ctx, reply.RootID); len(tree) != 4 {
+		t.Errorf("tree has %d nodes after the follow-up, want 4", len(tree))
+	}
+
+	// Unused branches are purged once older than the cutoff.
+	n, err := mgr.PurgeSpeculative(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("purged %d branches, want 1", n)
+	}
+	if suggestions, _ = mgr.Suggestions(ctx, taken.ID); len(suggestions) != 0 {
+		t.Errorf("suggestions after purge = %q", suggestions)
+	}
+}
+
+func TestSpeculativeRequestIsBuiltLikeAPrompt(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "Tell me more"})
+	defer cleanup()
+	mgr.SetSpeculationOptions(SpeculationOptions{Enabled: true})
+	if err := mgr.SetGlossary([]GlossaryEntry{{Term: "more", Definition: "the next part"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := ContextWithStopSequences(ContextWithTemperature(context.Background(), 0), []string{"END"})
+	events, err := mgr.Prompt(ctx, "Hello", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedNodeID(t, events)
+	mgr.Wait()
+
+	// The last request answered the speculative follow-up.
+	req := prov.LastRequest
+	if req.Temperature == nil || *req.Temperature != 0 || !reflect.DeepEqual(req.StopSeqs, []string{"END"}) {
+		t.Errorf("request = temperature %v, stop %v; want the DAG's 0 and [END]", req.Temperature, req.StopSeqs)
+	}
+	if !strings.Contains(req.System, "the next part") {
+		t.Errorf("system prompt %q lacks the glossary of the follow-up", req.System)
+	}
+}
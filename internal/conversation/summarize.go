@@ -0,0 +1,124 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"langdag.com/langdag/types"
+)
+
+const summarySystemPrompt = `Summarize the conversation below for someone who will pick it up later: the goal, decisions made, facts established, and open questions. Be concise (at most 200 words) and write plain prose or short bullet points.`
+
+// Summarize generates a summary and stores it as a summary node under
+// nodeID. When nodeID is a DAG root, the summary covers the whole DAG,
+// branches included; otherwise it covers the branch from the root down to
+// nodeID. The model defaults to the DAG's.
+func (m *Manager) Summarize(ctx context.Context, nodeID, model string) (*types.Node, error) {
+	node, err := m.storage.GetNode(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("node not found: %s", nodeID)
+	}
+
+	var nodes []*types.Node
+	if node.ParentID == "" {
+		nodes, err = m.storage.GetSubtree(ctx, node.ID)
+	} else {
+		nodes, err = m.storage.GetAncestors(ctx, node.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	nodes = withoutSpeculative(nodes)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("node not found: %s", nodeID)
+	}
+	if nodes[0].ArchivedURI != "" {
+		return nil, fmt.Errorf("DAG %s is archived at %s", nodes[0].ID, nodes[0].ArchivedURI)
+	}
+	if model == "" {
+		model = nodes[0].Model
+	}
+
+	var transcript []*types.Node
+	for _, n := range conversationNodes(nodes) {
+		if n.NodeType != types.NodeTypeSummary {
+			transcript = append(transcript, n)
+		}
+	}
+	req := &types.CompletionRequest{
+		Model:     model,
+		System:    summarySystemPrompt,
+		MaxTokens: m.resolveMaxTokens(model, 0),
+		Messages: []types.Message{
+			{Role: "user", Content: contentToRawMessage(exportMarkdown(&DAGExport{Nodes: transcript}))},
+		},
+		Metadata: m.requestMetadata(ctx),
+	}
+	start := time.Now()
+	resp, err := m.provider.Complete(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate summary: %w", err)
+	}
+	m.enrichCompletionResponse(resp, model)
+
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if strings.TrimSpace(text.String()) == "" {
+		return nil, fmt.Errorf("failed to generate summary: empty response")
+	}
+
+	rootID := node.RootID
+	if rootID == "" {
+		rootID = node.ID
+	}
+	summary := &types.Node{
+		ID:                  m.newID(),
+		ParentID:            node.ID,
+		RootID:              rootID,
+		Sequence:            node.Sequence + 1,
+		NodeType:            types.NodeTypeSummary,
+		Content:             strings.TrimSpace(text.String()),
+		Provider:            resp.Provider,
+		Model:               model,
+		TokensIn:            resp.Usage.InputTokens,
+		TokensOut:           resp.Usage.OutputTokens,
+		TokensCacheRead:     resp.Usage.CacheReadInputTokens,
+		TokensCacheCreation: resp.Usage.CacheCreationInputTokens,
+		TokensReasoning:     resp.Usage.ReasoningTokens,
+		LatencyMs:           int(time.Since(start).Milliseconds()),
+		StopReason:          resp.StopReason,
+		Status:              "completed",
+		Metadata:            assistantMetadataJSON(resp),
+		CreatedAt:           time.Now(),
+	}
+	if err := m.storage.CreateNode(ctx, summary); err != nil {
+		return nil, fmt.Errorf("failed to save summary: %w", err)
+	}
+	return summary, nil
+}
+
+// LatestSummary returns the newest summary stored directly under nodeID
+// (for a root, the newest summary of the whole DAG), or nil if there is
+// none.
+func (m *Manager) LatestSummary(ctx context.Context, nodeID string) (*types.Node, error) {
+	children, err := m.storage.GetNodeChildren(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	var latest *types.Node
+	for _, c := range children {
+		if c.NodeType == types.NodeTypeSummary && (latest == nil || c.CreatedAt.After(latest.CreatedAt)) {
+			latest = c
+		}
+	}
+	return latest, nil
+}
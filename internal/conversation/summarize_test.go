@@ -0,0 +1,62 @@
+package conversation
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/types"
+)
+
+func TestSummarizeStoresSummaryNode(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "We chose Redis."})
+	defer cleanup()
+	ctx := context.Background()
+
+	events, err := mgr.Prompt(ctx, "Should the cache use Redis or Memcached?", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, _ := mgr.storage.GetNode(ctx, savedNodeID(t, events))
+	events, err = mgr.PromptFrom(ctx, reply.RootID, "What about sessions?", "mock-fast", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sibling := savedNodeID(t, events)
+
+	summary, err := mgr.Summarize(ctx, reply.RootID, "")
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if summary.NodeType != types.NodeTypeSummary || summary.ParentID != reply.RootID || summary.Content != "We chose Redis." || summary.Model != "mock-fast" {
+		t.Errorf("unexpected summary node: %+v", summary)
+	}
+	prompt := string(prov.LastRequest.Messages[0].Content)
+	if !strings.Contains(prompt, "Redis or Memcached") || !strings.Contains(prompt, "What about sessions?") {
+		t.Errorf("DAG summary does not cover every branch: %s", prompt)
+	}
+	if latest, err := mgr.LatestSummary(ctx, reply.RootID); err != nil || latest == nil || latest.ID != summary.ID {
+		t.Errorf("LatestSummary = %v, %v; want %s", latest, err, summary.ID)
+	}
+
+	// A branch summary covers only the path to the node.
+	if _, err := mgr.Summarize(ctx, sibling, ""); err != nil {
+		t.Fatalf("Summarize branch: %v", err)
+	}
+	if prompt := string(prov.LastRequest.Messages[0].Content); strings.Contains(prompt, shortNodeID(reply.ID)) || !strings.Contains(prompt, "What about sessions?") {
+		t.Errorf("branch summary includes a sibling branch: %s", prompt)
+	}
+
+	// Summaries are not sent as conversation history.
+	events, err = mgr.PromptFrom(ctx, summary.ID, "Go on", "mock-fast", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedNodeID(t, events)
+	for _, msg := range prov.LastRequest.Messages {
+		if strings.Contains(string(msg.Content), "We chose Redis.") {
+			t.Errorf("summary sent as history: %s", msg.Content)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package conversation
+
+import "strings"
+
+// SetGlobalSystemPrompt sets a system prompt that is layered before every
+// DAG's own system prompt, e.g. to enforce organization-wide instructions.
+// Empty disables layering.
+func (m *Manager) SetGlobalSystemPrompt(prompt string) {
+	m.globalSystemPrompt = strings.TrimSpace(prompt)
+}
+
+// effectiveSystemPrompt returns the system prompt sent to the provider: the
+// global prompt followed by the DAG-level prompt, separated by a blank line.
+func (m *Manager) effectiveSystemPrompt(dagPrompt string) string {
+	if m.globalSystemPrompt == "" {
+		return dagPrompt
+	}
+	if strings.TrimSpace(dagPrompt) == "" {
+		return m.globalSystemPrompt
+	}
+	return m.globalSystemPrompt + "\n\n" + dagPrompt
+}
@@ -0,0 +1,80 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/types"
+)
+
+func TestGlobalSystemPromptIsLayeredAndRecorded(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	mgr.SetGlobalSystemPrompt("Be safe.")
+	ctx := context.Background()
+
+	events, err := mgr.Prompt(ctx, "hello", "", "Be brief.", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	nodeID := savedNodeID(t, events)
+
+	const want = "Be safe.\n\nBe brief."
+	node, err := store.GetNode(ctx, nodeID)
+	if err != nil || node == nil {
+		t.Fatalf("GetNode: %v (node=%v)", err, node)
+	}
+	if node.SystemPrompt != want {
+		t.Errorf("assistant SystemPrompt = %q, want %q", node.SystemPrompt, want)
+	}
+	root, err := store.GetNode(ctx, node.RootID)
+	if err != nil || root == nil {
+		t.Fatalf("GetNode(root): %v (node=%v)", err, root)
+	}
+	if root.SystemPrompt != "Be brief." {
+		t.Errorf("root SystemPrompt = %q, want the DAG-level prompt only", root.SystemPrompt)
+	}
+
+	// Continuations layer the global prompt over the root's prompt too.
+	events, err = mgr.PromptFrom(ctx, nodeID, "again", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("PromptFrom: %v", err)
+	}
+	next, err := store.GetNode(ctx, savedNodeID(t, events))
+	if err != nil || next == nil {
+		t.Fatalf("GetNode: %v (node=%v)", err, next)
+	}
+	if next.SystemPrompt != want {
+		t.Errorf("continuation SystemPrompt = %q, want %q", next.SystemPrompt, want)
+	}
+}
+
+func TestEffectiveSystemPrompt(t *testing.T) {
+	m := &Manager{}
+	if got := m.effectiveSystemPrompt("dag"); got != "dag" {
+		t.Errorf("no global: got %q, want %q", got, "dag")
+	}
+	m.SetGlobalSystemPrompt("  global  ")
+	if got := m.effectiveSystemPrompt(""); got != "global" {
+		t.Errorf("no DAG prompt: got %q, want %q", got, "global")
+	}
+	if got := m.effectiveSystemPrompt("dag"); got != "global\n\ndag" {
+		t.Errorf("layered: got %q, want %q", got, "global\n\ndag")
+	}
+}
+
+func savedNodeID(t *testing.T, events <-chan types.StreamEvent) string {
+	t.Helper()
+	for _, ev := range drainEvents(t, events, 5*time.Second) {
+		if ev.Type == types.StreamEventError {
+			t.Fatalf("unexpected error event: %v", ev.Error)
+		}
+		if ev.Type == types.StreamEventNodeSaved {
+			return ev.NodeID
+		}
+	}
+	t.Fatal("no NodeSaved event")
+	return ""
+}
@@ -0,0 +1,42 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type tagsKey struct{}
+
+// ContextWithTags returns a child context carrying tags. DAGs started by
+// Prompt with this context are created with the tags.
+func ContextWithTags(ctx context.Context, tags ...string) context.Context {
+	return context.WithValue(ctx, tagsKey{}, normalizeTags(tags))
+}
+
+// contextTags returns the tags carried by ctx, or nil.
+func contextTags(ctx context.Context) []string {
+	tags, _ := ctx.Value(tagsKey{}).([]string)
+	return append([]string(nil), tags...)
+}
+
+// PurgeGuests deletes every guest DAG whose root was created before
+// cutoff, skipping DAGs with a generation in progress. It returns the
+// number of DAGs deleted. Purged DAGs are not saved to the trash.
+func (m *Manager) PurgeGuests(ctx context.Context, cutoff time.Time) (int, error) {
+	roots, err := m.storage.ListRootNodes(ctx)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, root := range roots {
+		if !IsGuest(root) || !root.CreatedAt.Before(cutoff) || m.hasActiveRun(root.ID) {
+			continue
+		}
+		if err := m.storage.DeleteNode(ctx, root.ID); err != nil {
+			return purged, fmt.Errorf("failed to purge %s: %w", root.ID, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
@@ -0,0 +1,57 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/provider/mock"
+)
+
+func TestPurgeGuests(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+
+	seen := map[string]bool{}
+	start := func(ctx context.Context) string {
+		t.Helper()
+		events, err := mgr.Prompt(ctx, "Hello", "", "", nil, nil, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range events {
+		}
+		roots, _ := store.ListRootNodes(ctx)
+		for _, r := range roots {
+			if !seen[r.ID] {
+				seen[r.ID] = true
+				return r.ID
+			}
+		}
+		t.Fatal("no new DAG")
+		return ""
+	}
+	guest := start(ContextAsGuest(ctx))
+	// The tag alone does not make a guest DAG.
+	member := start(ContextWithTags(ctx, "Guest"))
+
+	root, _ := store.GetNode(ctx, guest)
+	if len(root.Tags) != 1 || root.Tags[0] != "guest" {
+		t.Fatalf("tags = %v, want [guest]", root.Tags)
+	}
+
+	if n, err := mgr.PurgeGuests(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Fatalf("PurgeGuests before the TTL = %d, %v", n, err)
+	}
+	n, err := mgr.PurgeGuests(ctx, time.Now().Add(time.Second))
+	if err != nil || n != 1 {
+		t.Fatalf("PurgeGuests = %d, %v, want 1", n, err)
+	}
+	if node, _ := store.GetNode(ctx, guest); node != nil {
+		t.Error("guest DAG was not deleted")
+	}
+	if node, _ := store.GetNode(ctx, member); node == nil {
+		t.Error("member DAG was deleted")
+	}
+}
@@ -0,0 +1,147 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"langdag.com/langdag/types"
+)
+
+// TitleOptions configures model-generated DAG titles.
+type TitleOptions struct {
+	// Generate asks the model for a title in the background after the
+	// first exchange of a DAG. Until then, and when it fails, the DAG keeps
+	// the title from GenerateTitle. Titles set with UpdateTitle are kept.
+	Generate bool
+
+	// Model overrides the model used; by default the cheapest priced model
+	// from the DAG model's provider, or the DAG's model when the catalog
+	// does not know it.
+	Model string
+}
+
+const (
+	titleExchangeLimit = 2000
+	titleMaxLen        = 100
+	titleTimeout       = 30 * time.Second
+)
+
+const titleSystemPrompt = `Write a title of 5 to 8 words for the conversation below. Reply with the title only, without quotes or final punctuation.`
+
+// SetTitleOptions configures title generation.
+func (m *Manager) SetTitleOptions(opts TitleOptions) {
+	m.titles = opts
+}
+
+// titleInBackground asks the model for a title after the first reply to a
+// DAG's root, when title generation is enabled.
+func (m *Manager) titleInBackground(parent, reply *types.Node) {
+	if !m.titles.Generate || parent.ParentID != "" {
+		return
+	}
+	m.background.Add(1)
+	go func() {
+		defer m.background.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+		defer cancel()
+		if err := m.generateTitle(ctx, parent.ID, reply); err != nil {
+			slog.WarnContext(ctx, "titles: failed to title", "root_id", parent.ID, "error", err)
+		}
+	}()
+}
+
+// generateTitle replaces the root's default title with one written by the
+// model from the first exchange. It leaves titles set by hand alone.
+func (m *Manager) generateTitle(ctx context.Context, rootID string, reply *types.Node) error {
+	root, err := m.storage.GetNode(ctx, rootID)
+	if err != nil || root == nil || root.Title != GenerateTitle(root.Content) {
+		return err
+	}
+
+	model := m.titles.Model
+	if model == "" {
+		model = cheapestModel(reply.Model)
+	}
+	exchange := fmt.Sprintf("user: %s\n\nassistant: %s",
+		truncateText(strings.TrimSpace(markdownContent(root.Content)), titleExchangeLimit),
+		truncateText(strings.TrimSpace(markdownContent(reply.Content)), titleExchangeLimit))
+	resp, err := m.provider.Complete(ctx, &types.CompletionRequest{
+		Model:     model,
+		System:    titleSystemPrompt,
+		MaxTokens: m.resolveMaxTokens(model, 64),
+		Messages: []types.Message{
+			{Role: "user", Content: contentToRawMessage(exchange)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	title := cleanTitle(text.String())
+	if title == "" {
+		return fmt.Errorf("empty title")
+	}
+
+	// The title may have been set by hand while the model was answering.
+	root, err = m.storage.GetNode(ctx, rootID)
+	if err != nil || root == nil || root.Title != GenerateTitle(root.Content) {
+		return err
+	}
+	root.Title = title
+	return m.storage.UpdateNode(ctx, root)
+}
+
+// cleanTitle keeps the first line of a model reply, without surrounding
+// quotes or final punctuation, cut to titleMaxLen bytes.
+func cleanTitle(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimPrefix(strings.TrimSpace(s), "Title:")
+	s = strings.Trim(strings.TrimSpace(s), `"'*`+"`")
+	s = strings.TrimRight(s, ".!")
+	return truncateText(strings.TrimSpace(s), titleMaxLen)
+}
+
+// truncateText cuts s to at most limit bytes on a rune boundary.
+func truncateText(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return strings.ToValidUTF8(s[:limit], "")
+}
+
+// cheapestModel returns the priced model with the lowest token prices from
+// the same provider as model, or model itself when the catalog does not
+// know it.
+func cheapestModel(model string) string {
+	catalog := getDefaultCatalog()
+	if catalog == nil {
+		return model
+	}
+	_, provider, ok := catalog.LookupModel(model)
+	if !ok {
+		return model
+	}
+	best, bestPrice := model, -1.0
+	for _, m := range catalog.ForProvider(provider) {
+		price := m.InputPricePer1M + m.OutputPricePer1M
+		if m.Free || price <= 0 {
+			continue
+		}
+		// Prefer aliases over dated IDs at the same price.
+		if bestPrice < 0 || price < bestPrice || (price == bestPrice && len(m.ID) < len(best)) {
+			best, bestPrice = m.ID, price
+		}
+	}
+	return best
+}
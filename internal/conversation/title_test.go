@@ -0,0 +1,85 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+
+	"langdag.com/langdag/internal/provider/mock"
+)
+
+func TestTitleGeneratedAfterFirstExchange(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: `"Choosing a cache backend."`})
+	defer cleanup()
+	ctx := context.Background()
+	mgr.SetTitleOptions(TitleOptions{Generate: true})
+
+	events, err := mgr.Prompt(ctx, "Should the cache use Redis or Memcached? We run on a single host.", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, _ := mgr.storage.GetNode(ctx, savedNodeID(t, events))
+	mgr.Wait()
+
+	root, _ := mgr.storage.GetNode(ctx, reply.RootID)
+	if root.Title != "Choosing a cache backend" {
+		t.Errorf("Title = %q, want %q", root.Title, "Choosing a cache backend")
+	}
+	if prov.LastRequest.System != titleSystemPrompt || prov.LastRequest.Model != "mock-fast" {
+		t.Errorf("unexpected title request: model %q, system %q", prov.LastRequest.Model, prov.LastRequest.System)
+	}
+}
+
+func TestTitleSetByHandIsKept(t *testing.T) {
+	mgr, cleanup := newTestManager(t, mock.Config{Mode: "fixed", FixedResponse: "Generated title"})
+	defer cleanup()
+	ctx := context.Background()
+
+	events, err := mgr.Prompt(ctx, "Should the cache use Redis?", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, _ := mgr.storage.GetNode(ctx, savedNodeID(t, events))
+	if err := mgr.UpdateTitle(ctx, reply.RootID, "Cache design"); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr.SetTitleOptions(TitleOptions{Generate: true})
+	events, err = mgr.PromptFrom(ctx, reply.RootID, "And for sessions?", "mock-fast", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedNodeID(t, events)
+	mgr.Wait()
+
+	root, _ := mgr.storage.GetNode(ctx, reply.RootID)
+	if root.Title != "Cache design" {
+		t.Errorf("Title = %q, want the title set by hand", root.Title)
+	}
+}
+
+func TestCheapestModel(t *testing.T) {
+	if got := cheapestModel("mock-fast"); got != "mock-fast" {
+		t.Errorf("cheapestModel(unknown) = %q, want it unchanged", got)
+	}
+	catalog := getDefaultCatalog()
+	opus, _, ok := catalog.LookupModel("claude-opus-4-7")
+	if !ok {
+		t.Skip("claude-opus-4-7 not in the catalog")
+	}
+	cheap, provider, ok := catalog.LookupModel(cheapestModel(opus.ID))
+	if !ok || provider != "anthropic" || cheap.InputPricePer1M+cheap.OutputPricePer1M >= opus.InputPricePer1M+opus.OutputPricePer1M {
+		t.Errorf("cheapestModel(%s) = %s (%s), want a cheaper anthropic model", opus.ID, cheap.ID, provider)
+	}
+}
+
+func TestCleanTitle(t *testing.T) {
+	for in, want := range map[string]string{
+		`"Choosing a cache backend."`:      "Choosing a cache backend",
+		"Title: Redis vs Memcached\nextra": "Redis vs Memcached",
+		"**Cache design**":                 "Cache design",
+	} {
+		if got := cleanTitle(in); got != want {
+			t.Errorf("cleanTitle(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"langdag.com/langdag/internal/toolbox"
+	"langdag.com/langdag/types"
+)
+
+// builtinToolNames are the names reserved for built-in tools.
+var builtinToolNames = []string{"http_fetch", "shell", "file_read", "file_write"}
+
+// maxToolRounds bounds how many times built-in tools are run for one
+// prompt, for models that keep calling them.
+const maxToolRounds = 10
+
+// SetToolbox sets the built-in tools. Requests and DAGs list a built-in
+// tool by its name alone, without description or input schema. When the
+// model calls only built-in tools, they are run, their results are saved
+// as a user node and the next reply is generated on the same stream.
+func (m *Manager) SetToolbox(b *toolbox.Toolbox) {
+	m.toolbox = b
+}
+
+type builtinToolsDeniedKey struct{}
+
+// ContextWithoutBuiltinTools returns a child context for a request that
+// may not run built-in tools, such as a guest's or one made with a stored
+// API key without the tools scope.
+func ContextWithoutBuiltinTools(ctx context.Context) context.Context {
+	return context.WithValue(ctx, builtinToolsDeniedKey{}, true)
+}
+
+func builtinToolsDenied(ctx context.Context) bool {
+	denied, _ := ctx.Value(builtinToolsDeniedKey{}).(bool)
+	return denied
+}
+
+// CheckTools returns an error if tools list a built-in tool that is not
+// enabled, or that the request of ctx may not run.
+func (m *Manager) CheckTools(ctx context.Context, tools []types.ToolDefinition) error {
+	_, _, err := m.resolveTools(ctx, tools)
+	return err
+}
+
+// CheckDAGTools is CheckTools for a generation in the DAG of node: without
+// tools, the DAG's default tools, which the generation would use, are
+// checked.
+func (m *Manager) CheckDAGTools(ctx context.Context, node *types.Node, tools []types.ToolDefinition) error {
+	if tools == nil {
+		root := node
+		if node.RootID != "" && node.RootID != node.ID {
+			var err error
+			if root, err = m.storage.GetNode(ctx, node.RootID); err != nil {
+				return err
+			}
+			if root == nil {
+				return fmt.Errorf("node not found: %s", node.RootID)
+			}
+		}
+		tools = dagTools(root)
+	}
+	return m.CheckTools(ctx, tools)
+}
+
+// resolveTools replaces the built-in tools listed by name in tools with
+// their definitions, and returns them by name. Listing a built-in tool that
+// is not enabled, or that the request of ctx may not run, is an error.
+func (m *Manager) resolveTools(ctx context.Context, tools []types.ToolDefinition) ([]types.ToolDefinition, map[string]toolbox.Tool, error) {
+	var builtin map[string]toolbox.Tool
+	var resolved []types.ToolDefinition
+	for i, def := range tools {
+		if def.Description != "" || len(def.InputSchema) > 0 || !slices.Contains(builtinToolNames, def.Name) {
+			continue
+		}
+		if builtinToolsDenied(ctx) {
+			return nil, nil, &ToolDeniedError{Tool: def.Name}
+		}
+		tool, ok := m.toolbox.Get(def.Name)
+		if !ok {
+			return nil, nil, fmt.Errorf("built-in tool %s is not enabled in the server configuration", def.Name)
+		}
+		if builtin == nil {
+			builtin = make(map[string]toolbox.Tool)
+			resolved = slices.Clone(tools)
+		}
+		builtin[def.Name] = tool
+		resolved[i] = tool.Definition()
+	}
+	if builtin == nil {
+		return tools, nil, nil
+	}
+	return resolved, builtin, nil
+}
+
+// ToolDeniedError is returned when a request that may not run built-in
+// tools lists one.
+type ToolDeniedError struct {
+	Tool string
+}
+
+func (e *ToolDeniedError) Error() string {
+	return "built-in tool " + e.Tool + " is not allowed for this request"
+}
+
+// runBuiltinTools forwards events, running the built-in tools called by the
+// saved reply and forwarding the events of next, the reply to their
+// results, in its place. Tool results are sent as content_done events.
+// Only the node_saved event of the last reply is forwarded. stop is called
+// once the loop has ended.
+func (m *Manager) runBuiltinTools(ctx context.Context, stop func(), events <-chan types.StreamEvent, builtin map[string]toolbox.Tool, next func(*types.Node) (<-chan types.StreamEvent, error)) <-chan types.StreamEvent {
+	out := make(chan types.StreamEvent, 100)
+	go func() {
+		defer close(out)
+		defer stop()
+		for round := 0; ; round++ {
+			var saved *types.StreamEvent
+			for event := range events {
+				if event.Type == types.StreamEventNodeSaved {
+					saved = &event
+					continue
+				}
+				out <- event
+			}
+			if saved == nil {
+				return
+			}
+			reply, err := m.storage.GetNode(ctx, saved.NodeID)
+			var calls []types.ContentBlock
+			if err == nil && reply != nil && round < maxToolRounds && ctx.Err() == nil {
+				calls = builtinCalls(reply, builtin)
+			}
+			if len(calls) == 0 {
+				out <- *saved
+				return
+			}
+
+			results := make([]types.ContentBlock, len(calls))
+			for i, call := range calls {
+				results[i] = runTool(ctx, builtin[call.Name], call)
+				out <- types.StreamEvent{Type: types.StreamEventContentDone, ContentBlock: &results[i]}
+			}
+			if ctx.Err() != nil {
+				out <- types.StreamEvent{Type: types.StreamEventError, Error: context.Cause(ctx)}
+				return
+			}
+			user, err := m.saveToolResults(ctx, reply, results)
+			if err == nil {
+				events, err = next(user)
+			}
+			if err != nil {
+				out <- types.StreamEvent{Type: types.StreamEventError, Error: err}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// builtinCalls returns the tool calls of reply if they are all calls to
+// built-in tools; calls to other tools are left to the client.
+func builtinCalls(reply *types.Node, builtin map[string]toolbox.Tool) []types.ContentBlock {
+	if reply.StopReason != "tool_use" {
+		return nil
+	}
+	var blocks []types.ContentBlock
+	if json.Unmarshal([]byte(reply.Content), &blocks) != nil {
+		return nil
+	}
+	var calls []types.ContentBlock
+	for _, b := range blocks {
+		if b.Type != "tool_use" {
+			continue
+		}
+		if _, ok := builtin[b.Name]; !ok {
+			return nil
+		}
+		calls = append(calls, b)
+	}
+	return calls
+}
+
+// runTool runs a built-in tool call and returns its tool_result block.
+func runTool(ctx context.Context, tool toolbox.Tool, call types.ContentBlock) types.ContentBlock {
+	start := time.Now()
+	output, err := tool.Run(ctx, call.Input)
+	result := types.ContentBlock{
+		Type:       "tool_result",
+		ToolUseID:  call.ID,
+		Content:    output,
+		DurationMs: int(time.Since(start).Milliseconds()),
+	}
+	if err != nil {
+		slog.InfoContext(ctx, "conversation: built-in tool failed", "tool", call.Name, "error", err)
+		result.Content, result.IsError = err.Error(), true
+	}
+	return result
+}
+
+// saveToolResults stores results as a user node answering reply. Results
+// are scanned for prompt injection like those sent by clients.
+func (m *Manager) saveToolResults(ctx context.Context, reply *types.Node, results []types.ContentBlock) (*types.Node, error) {
+	content, err := json.Marshal(results)
+	if err != nil {
+		return nil, err
+	}
+	user := &types.Node{
+		ID:        m.newID(),
+		ParentID:  reply.ID,
+		RootID:    reply.RootID,
+		Sequence:  reply.Sequence + 1,
+		NodeType:  types.NodeTypeUser,
+		Content:   string(content),
+		Status:    "completed",
+		CreatedAt: time.Now(),
+	}
+	if err := m.checkInjection(ctx, user, user.Content); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ToolUseID
+	}
+	if err := m.saveNode(ctx, user, ids, "result"); err != nil {
+		return nil, fmt.Errorf("failed to create tool result node: %w", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,136 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/toolbox"
+	"langdag.com/langdag/types"
+)
+
+func TestBuiltinToolsRunOnTheStream(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithSequence(t, []sequenceResponse{
+		{stopReason: "tool_use", toolUse: &types.ContentBlock{Type: "tool_use", ID: "toolu_1", Name: "file_read", Input: json.RawMessage(`{"path":"notes.txt"}`)}},
+		{text: "The notes say hello."},
+	})
+	defer cleanup()
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "notes.txt"), []byte("hello"), 0644)
+	tb, err := toolbox.New(toolbox.Options{Files: toolbox.FilesOptions{Root: root}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetToolbox(tb)
+
+	ctx := context.Background()
+	events, err := mgr.Prompt(ctx, "What do my notes say?", "seq-mock", "", []types.ToolDefinition{{Name: "file_read"}}, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	var saved []string
+	var result *types.ContentBlock
+	for _, ev := range drainEvents(t, events, 5*time.Second) {
+		switch ev.Type {
+		case types.StreamEventNodeSaved:
+			saved = append(saved, ev.NodeID)
+		case types.StreamEventContentDone:
+			if ev.ContentBlock.Type == "tool_result" {
+				result = ev.ContentBlock
+			}
+		case types.StreamEventError:
+			t.Fatalf("error event: %v", ev.Error)
+		}
+	}
+	if result == nil || result.ToolUseID != "toolu_1" || result.Content != "hello" || result.IsError {
+		t.Fatalf("tool_result = %+v", result)
+	}
+	if len(saved) != 1 {
+		t.Fatalf("node_saved events = %v, want only the final reply", saved)
+	}
+
+	ancestors, err := store.GetAncestors(ctx, saved[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// question, tool call, tool result, answer
+	if len(ancestors) != 4 || !strings.Contains(ancestors[2].Content, `"tool_use_id":"toolu_1"`) || ancestors[3].Content != "The notes say hello." {
+		t.Errorf("unexpected branch: %d nodes", len(ancestors))
+	}
+}
+
+func TestBuiltinToolNotEnabled(t *testing.T) {
+	mgr, _, cleanup := newTestManagerWithSequence(t, nil)
+	defer cleanup()
+
+	_, err := mgr.Prompt(context.Background(), "hi", "seq-mock", "", []types.ToolDefinition{{Name: "shell"}}, nil, 0, 0)
+	if err == nil || !strings.Contains(err.Error(), "not enabled") {
+		t.Fatalf("err = %v, want built-in tool not enabled", err)
+	}
+}
+
+func TestBuiltinToolDenied(t *testing.T) {
+	mgr, _, cleanup := newTestManagerWithSequence(t, nil)
+	defer cleanup()
+	tb, err := toolbox.New(toolbox.Options{Files: toolbox.FilesOptions{Root: t.TempDir()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetToolbox(tb)
+
+	ctx := ContextWithoutBuiltinTools(context.Background())
+	_, err = mgr.Prompt(ctx, "hi", "seq-mock", "", []types.ToolDefinition{{Name: "file_read"}}, nil, 0, 0)
+	var denied *ToolDeniedError
+	if !errors.As(err, &denied) || denied.Tool != "file_read" {
+		t.Fatalf("err = %v, want file_read denied", err)
+	}
+}
+
+func TestBuiltinToolsFailedRoundKeepsSavedNodes(t *testing.T) {
+	// The reply to the tool result fails: the tool call and its result
+	// were saved, so the question must not be discarded with them.
+	mgr, store, cleanup := newTestManagerWithSequence(t, []sequenceResponse{
+		{stopReason: "tool_use", toolUse: &types.ContentBlock{Type: "tool_use", ID: "toolu_1", Name: "file_read", Input: json.RawMessage(`{"path":"notes.txt"}`)}},
+	})
+	defer cleanup()
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "notes.txt"), []byte("hello"), 0644)
+	tb, err := toolbox.New(toolbox.Options{Files: toolbox.FilesOptions{Root: root}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetToolbox(tb)
+
+	ctx := context.Background()
+	events, err := mgr.Prompt(ctx, "What do my notes say?", "seq-mock", "", []types.ToolDefinition{{Name: "file_read"}}, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	var failed bool
+	for _, ev := range drainEvents(t, events, 5*time.Second) {
+		if ev.Type == types.StreamEventError {
+			failed = true
+		}
+	}
+	if !failed {
+		t.Fatal("no error event for the failed second round")
+	}
+
+	roots, err := store.ListRootNodes(ctx)
+	if err != nil || len(roots) != 1 {
+		t.Fatalf("roots = %d, %v; want the question kept", len(roots), err)
+	}
+	nodes, err := store.GetSubtree(ctx, roots[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// question, tool call, tool result
+	if len(nodes) != 3 {
+		t.Errorf("DAG has %d nodes, want 3", len(nodes))
+	}
+}
@@ -0,0 +1,105 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultTrashRetention is how long trash exports are kept when
+// TrashOptions.Retention is 0.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// TrashOptions configures the exports written before deletions.
+type TrashOptions struct {
+	// Dir receives a JSON export of the DAG of each deleted node. Empty
+	// disables the trash.
+	Dir string
+	// Retention is how long exports are kept; older ones are removed
+	// after each deletion. 0 means DefaultTrashRetention.
+	Retention time.Duration
+}
+
+// SetTrashOptions sets where DeleteNode saves a copy of what it deletes.
+// A saved DAG can be restored with Import (`langdag import`) after
+// deleting whatever is left of it.
+func (m *Manager) SetTrashOptions(opts TrashOptions) {
+	m.trash = opts
+}
+
+// moveToTrash writes the DAG containing nodeID to the trash directory, so
+// a deletion can be undone, and removes expired exports. Archived DAGs are
+// not exported: the archive store keeps them.
+func (m *Manager) moveToTrash(ctx context.Context, nodeID string) error {
+	node, err := m.storage.GetNode(ctx, nodeID)
+	if err != nil || node == nil {
+		return err // deleting a missing node is not an error
+	}
+	rootID := node.RootID
+	if rootID == "" {
+		rootID = node.ID
+	}
+	root, err := m.storage.GetNode(ctx, rootID)
+	if err != nil {
+		return err
+	}
+	if root == nil || root.ArchivedURI != "" {
+		return nil
+	}
+	dag, err := m.Export(ctx, rootID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.trash.Dir, 0700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.json", rootID, time.Now().UTC().Format("20060102T150405.000000000Z"))
+	f, err := os.OpenFile(filepath.Join(m.trash.Dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if err := EncodeExport(f, dag, ExportJSON); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	retention := m.trash.Retention
+	if retention <= 0 {
+		retention = DefaultTrashRetention
+	}
+	if err := pruneTrash(m.trash.Dir, time.Now().Add(-retention)); err != nil {
+		slog.WarnContext(ctx, "conversation: failed to clean the trash", "dir", m.trash.Dir, "error", err)
+	}
+	return nil
+}
+
+// pruneTrash removes the exports in dir last written before cutoff.
+func pruneTrash(dir string, cutoff time.Time) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed concurrently
+		}
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(filepath.Join(dir, e.Name())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package conversation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/types"
+)
+
+func TestDeleteNodeMovesDAGToTrash(t *testing.T) {
+	mgr, store, cleanup := newTestManagerWithStore(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+
+	dir := filepath.Join(t.TempDir(), "trash")
+	mgr.SetTrashOptions(TrashOptions{Dir: dir, Retention: time.Hour})
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	expired := filepath.Join(dir, "old-20000101T000000.000000000Z.json")
+	if err := os.WriteFile(expired, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(expired, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := []*types.Node{
+		{ID: "root", Sequence: 0, NodeType: types.NodeTypeUser, Content: "Question"},
+		{ID: "a1", ParentID: "root", RootID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant, Content: "Answer"},
+	}
+	for _, n := range nodes {
+		if err := store.CreateNode(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := mgr.DeleteNode(ctx, "a1"); err != nil {
+		t.Fatalf("DeleteNode: %v", err)
+	}
+	if n, _ := store.GetNode(ctx, "a1"); n != nil {
+		t.Fatal("node was not deleted")
+	}
+
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Error("expired export was not removed")
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "root-*.json"))
+	if len(files) != 1 {
+		t.Fatalf("trash has %d exports of root, want 1", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag, err := DecodeExport(data)
+	if err != nil {
+		t.Fatalf("DecodeExport: %v", err)
+	}
+	if len(dag.Nodes) != 2 || dag.Nodes[1].Content != "Answer" {
+		t.Fatalf("export = %+v, want the whole DAG", dag.Nodes)
+	}
+
+	// The saved DAG can be restored once what is left of it is deleted.
+	mgr.SetTrashOptions(TrashOptions{})
+	if err := mgr.DeleteNode(ctx, "root"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.Import(ctx, dag); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if n, _ := store.GetNode(ctx, "a1"); n == nil || n.Content != "Answer" {
+		t.Fatalf("restored node = %+v", n)
+	}
+}
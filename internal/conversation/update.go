@@ -0,0 +1,100 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"langdag.com/langdag/types"
+)
+
+// DAGUpdate lists the DAG settings to change; nil fields are left as they
+// are.
+type DAGUpdate struct {
+	Title        *string
+	SystemPrompt *string // "" removes the system prompt
+	Model        *string // default model of later prompts
+	Tools        *[]types.ToolDefinition
+	Language     *string // language of later replies; "" for the default
+	Visibility   *string // a types.Visibility* level
+}
+
+// ErrInvalidUpdate is returned by UpdateDAG for empty or invalid updates.
+var ErrInvalidUpdate = errors.New("invalid DAG update")
+
+// UpdateDAG changes the settings stored on the root of the DAG containing
+// nodeID and returns the updated root. Existing nodes are not changed; the
+// new system prompt, model, tools and language apply to later prompts;
+// the visibility is enforced by the API server.
+func (m *Manager) UpdateDAG(ctx context.Context, nodeID string, update DAGUpdate) (*types.Node, error) {
+	if update.Title == nil && update.SystemPrompt == nil && update.Model == nil && update.Tools == nil && update.Language == nil && update.Visibility == nil {
+		return nil, fmt.Errorf("%w: nothing to update", ErrInvalidUpdate)
+	}
+	if update.Title != nil && strings.TrimSpace(*update.Title) == "" {
+		return nil, fmt.Errorf("%w: title must not be empty", ErrInvalidUpdate)
+	}
+	if update.Model != nil && strings.TrimSpace(*update.Model) == "" {
+		return nil, fmt.Errorf("%w: model must not be empty", ErrInvalidUpdate)
+	}
+	if update.Visibility != nil && !ValidVisibility(*update.Visibility) {
+		return nil, fmt.Errorf("%w: visibility must be %s, %s or %s", ErrInvalidUpdate, types.VisibilityPrivate, types.VisibilityTeam, types.VisibilityPublicReadOnly)
+	}
+
+	node, err := m.storage.GetNode(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("node not found: %s", nodeID)
+	}
+	root := node
+	if node.RootID != "" && node.RootID != node.ID {
+		root, err = m.storage.GetNode(ctx, node.RootID)
+		if err != nil {
+			return nil, err
+		}
+		if root == nil {
+			return nil, fmt.Errorf("node not found: %s", node.RootID)
+		}
+	}
+
+	if update.Title != nil {
+		root.Title = strings.TrimSpace(*update.Title)
+	}
+	if update.SystemPrompt != nil {
+		root.SystemPrompt = *update.SystemPrompt
+	}
+	if update.Model != nil {
+		root.Model = strings.TrimSpace(*update.Model)
+	}
+	if update.Tools != nil || update.Language != nil || update.Visibility != nil {
+		meta := types.UserMetadataFromNode(root)
+		if meta == nil {
+			meta = &types.UserNodeMetadata{}
+		}
+		if update.Tools != nil {
+			meta.Tools = *update.Tools
+		}
+		if update.Language != nil {
+			meta.Language = strings.TrimSpace(*update.Language)
+		}
+		if update.Visibility != nil {
+			meta.Visibility = *update.Visibility
+		}
+		root.Metadata, _ = json.Marshal(meta)
+	}
+	if err := m.storage.UpdateNode(ctx, root); err != nil {
+		return nil, fmt.Errorf("failed to update DAG: %w", err)
+	}
+	return root, nil
+}
+
+// dagTools returns the default tools stored on a DAG's root.
+func dagTools(root *types.Node) []types.ToolDefinition {
+	if meta := types.UserMetadataFromNode(root); meta != nil {
+		return meta.Tools
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/types"
+)
+
+func TestUpdateDAGAppliesToLaterPrompts(t *testing.T) {
+	mgr, prov, cleanup := newTestManagerWithMock(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+
+	events, err := mgr.Prompt(ctx, "Hello", "mock-fast", "Be terse.", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := savedNodeID(t, events)
+
+	title, system, model := "Greetings", "Be verbose.", "mock-slow"
+	tools := []types.ToolDefinition{{Name: "lookup", Description: "Look things up"}}
+	root, err := mgr.UpdateDAG(ctx, reply, DAGUpdate{Title: &title, SystemPrompt: &system, Model: &model, Tools: &tools})
+	if err != nil {
+		t.Fatalf("UpdateDAG: %v", err)
+	}
+	if root.ParentID != "" || root.Title != title || root.SystemPrompt != system || root.Model != model {
+		t.Errorf("unexpected root: %+v", root)
+	}
+
+	events, err = mgr.PromptFrom(ctx, reply, "Again", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedNodeID(t, events)
+	req := prov.LastRequest
+	if req.Model != model || req.System != system || len(req.Tools) != 1 || req.Tools[0].Name != "lookup" {
+		t.Errorf("later prompt does not use the new settings: model %q, system %q, tools %v", req.Model, req.System, req.Tools)
+	}
+
+	// Removing the system prompt and tools.
+	empty, none := "", []types.ToolDefinition{}
+	root, err = mgr.UpdateDAG(ctx, root.ID, DAGUpdate{SystemPrompt: &empty, Tools: &none})
+	if err != nil {
+		t.Fatalf("UpdateDAG: %v", err)
+	}
+	if root.SystemPrompt != "" || len(dagTools(root)) != 0 || root.Title != title {
+		t.Errorf("unexpected root after removal: %+v", root)
+	}
+
+	if _, err := mgr.UpdateDAG(ctx, root.ID, DAGUpdate{}); !errors.Is(err, ErrInvalidUpdate) {
+		t.Errorf("empty update: err = %v, want ErrInvalidUpdate", err)
+	}
+	if _, err := mgr.UpdateDAG(ctx, root.ID, DAGUpdate{Title: &empty}); !errors.Is(err, ErrInvalidUpdate) {
+		t.Errorf("empty title: err = %v, want ErrInvalidUpdate", err)
+	}
+}
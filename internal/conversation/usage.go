@@ -0,0 +1,203 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"langdag.com/langdag/types"
+)
+
+// Groupings accepted by Usage.
+const (
+	UsageByDAG   = "dag"
+	UsageByDay   = "day"
+	UsageByModel = "model"
+)
+
+// ModelPrice is a configured price for a model, per million tokens. It
+// prices generations that have no cost recorded, such as those of models
+// the catalog does not know.
+type ModelPrice struct {
+	InputPer1M     float64
+	OutputPer1M    float64
+	CacheReadPer1M float64 // 0 = not priced
+	Currency       string  // default "USD"
+}
+
+// UsageRow is the usage of one DAG, day or model.
+type UsageRow struct {
+	// Key is the DAG ID, the day (YYYY-MM-DD, UTC) or the model.
+	Key string
+	// Title is the DAG's title, for rows grouped by DAG.
+	Title string
+
+	Generations         int
+	TokensIn            int64
+	TokensOut           int64
+	TokensCacheRead     int64
+	TokensCacheCreation int64
+	TokensReasoning     int64
+
+	// Cost is the known cost, by currency.
+	Cost map[string]float64
+	// Unpriced counts generations whose cost is unknown or partly known.
+	Unpriced int
+}
+
+// UsageReport rolls up the usage of generations since a time.
+type UsageReport struct {
+	Since   time.Time
+	GroupBy string
+	// Rows are sorted by day, or by descending cost then tokens.
+	Rows  []UsageRow
+	Total UsageRow
+}
+
+// SetPricing sets the configured price of models, by model ID.
+func (m *Manager) SetPricing(pricing map[string]ModelPrice) {
+	m.pricing = pricing
+}
+
+// Usage returns token and cost totals of the assistant replies created
+// since the given time, grouped by groupBy (UsageByDAG, UsageByDay or
+// UsageByModel). Archived DAGs and DAGs not visible to the request are not
+// counted.
+func (m *Manager) Usage(ctx context.Context, since time.Time, groupBy string) (*UsageReport, error) {
+	switch groupBy {
+	case UsageByDAG, UsageByDay, UsageByModel:
+	case "":
+		groupBy = UsageByModel
+	default:
+		return nil, fmt.Errorf("unknown usage grouping %q (want %s, %s or %s)", groupBy, UsageByDAG, UsageByDay, UsageByModel)
+	}
+
+	roots, err := m.storage.ListRootNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &UsageReport{Since: since, GroupBy: groupBy, Total: UsageRow{Key: "total"}}
+	rows := make(map[string]*UsageRow)
+	for _, root := range roots {
+		if root.ArchivedURI != "" || !VisibleTo(ctx, root) {
+			continue
+		}
+		nodes, err := m.storage.GetSubtree(ctx, root.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nodes {
+			if n.NodeType != types.NodeTypeAssistant || n.CreatedAt.Before(since) {
+				continue
+			}
+			var key string
+			switch groupBy {
+			case UsageByDAG:
+				key = root.ID
+			case UsageByDay:
+				key = n.CreatedAt.UTC().Format("2006-01-02")
+			case UsageByModel:
+				key = n.Model
+			}
+			row := rows[key]
+			if row == nil {
+				row = &UsageRow{Key: key}
+				if groupBy == UsageByDAG {
+					row.Title = root.Title
+				}
+				rows[key] = row
+			}
+			cost := m.nodeCost(n)
+			row.add(n, cost)
+			report.Total.add(n, cost)
+		}
+	}
+
+	for _, row := range rows {
+		report.Rows = append(report.Rows, *row)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		if groupBy == UsageByDay {
+			return a.Key < b.Key
+		}
+		if ca, cb := totalCost(a.Cost), totalCost(b.Cost); ca != cb {
+			return ca > cb
+		}
+		if ta, tb := a.TokensIn+a.TokensOut, b.TokensIn+b.TokensOut; ta != tb {
+			return ta > tb
+		}
+		return a.Key < b.Key
+	})
+	return report, nil
+}
+
+func (r *UsageRow) add(n *types.Node, cost types.CostResult) {
+	r.Generations++
+	r.TokensIn += int64(n.TokensIn)
+	r.TokensOut += int64(n.TokensOut)
+	r.TokensCacheRead += int64(n.TokensCacheRead)
+	r.TokensCacheCreation += int64(n.TokensCacheCreation)
+	r.TokensReasoning += int64(n.TokensReasoning)
+	switch cost.Status {
+	case types.CostStatusKnown, types.CostStatusPartial:
+		if cost.Total > 0 && cost.Currency != "" {
+			if r.Cost == nil {
+				r.Cost = make(map[string]float64)
+			}
+			r.Cost[cost.Currency] += cost.Total
+		}
+		if cost.Status == types.CostStatusPartial {
+			r.Unpriced++
+		}
+	case types.CostStatusUnknown:
+		r.Unpriced++
+	}
+}
+
+// totalCost adds up costs across currencies, for sorting only.
+func totalCost(cost map[string]float64) float64 {
+	var total float64
+	for _, v := range cost {
+		total += v
+	}
+	return total
+}
+
+// nodeCost returns the cost of an assistant node: the cost reported by the
+// provider or computed from the pricing recorded with the node, or else
+// from the configured price of its model.
+func (m *Manager) nodeCost(n *types.Node) types.CostResult {
+	meta, _, err := types.AssistantMetadataFromNode(n)
+	if err == nil && meta != nil {
+		var usage types.NormalizedUsage
+		if meta.NormalizedUsage != nil {
+			usage = *meta.NormalizedUsage
+		}
+		if cost := types.ComputeCost(meta.ProviderCost, meta.PricingSnapshot, usage); cost.Status != types.CostStatusUnknown {
+			return cost
+		}
+	}
+	p, ok := m.pricing[n.Model]
+	if !ok {
+		return types.CostResult{Status: types.CostStatusUnknown}
+	}
+	currency := p.Currency
+	if currency == "" {
+		currency = "USD"
+	}
+	rates := map[string]float64{
+		"input_tokens":  p.InputPer1M,
+		"output_tokens": p.OutputPer1M,
+	}
+	if p.CacheReadPer1M > 0 {
+		rates["cache_read_input_tokens"] = p.CacheReadPer1M
+	}
+	return types.ComputeCostFromPricingSnapshot(types.PricingSnapshot{
+		Status:     types.CostStatusKnown,
+		Currency:   currency,
+		Source:     types.CostSourceConfig,
+		RatesPer1M: rates,
+	}, types.NormalizedUsageFromNode(n))
+}
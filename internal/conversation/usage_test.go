@@ -0,0 +1,111 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/provider/mock"
+	"langdag.com/langdag/types"
+)
+
+func TestUsage(t *testing.T) {
+	mgr, cleanup := newTestManager(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+	mgr.SetPricing(map[string]ModelPrice{"mock-fast": {InputPer1M: 1_000_000, OutputPer1M: 2_000_000}})
+
+	start := time.Now().Add(-time.Second)
+	var replies []string
+	for _, model := range []string{"mock-fast", "mock-fast", "mock-slow"} {
+		events, err := mgr.Prompt(ctx, "Hello", model, "", nil, nil, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, savedNodeID(t, events))
+	}
+
+	report, err := mgr.Usage(ctx, start, UsageByModel)
+	if err != nil {
+		t.Fatalf("Usage: %v", err)
+	}
+	if len(report.Rows) != 2 || report.Total.Generations != 3 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	fast := report.Rows[0]
+	if fast.Key != "mock-fast" || fast.Generations != 2 || fast.Unpriced != 0 {
+		t.Errorf("unexpected mock-fast row: %+v", fast)
+	}
+	node, err := mgr.storage.GetNode(ctx, replies[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 2 * float64(node.TokensIn+2*node.TokensOut)
+	if node.TokensIn == 0 || fast.Cost["USD"] != want {
+		t.Errorf("mock-fast cost = %v, want %v (tokens %d/%d)", fast.Cost, want, node.TokensIn, node.TokensOut)
+	}
+	if slow := report.Rows[1]; slow.Key != "mock-slow" || slow.Unpriced != 1 || len(slow.Cost) != 0 {
+		t.Errorf("unexpected mock-slow row: %+v", slow)
+	}
+
+	report, err = mgr.Usage(ctx, start, UsageByDAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Rows) != 3 || report.Rows[0].Title == "" {
+		t.Errorf("unexpected report by DAG: %+v", report.Rows)
+	}
+	report, err = mgr.Usage(ctx, start, UsageByDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Rows) != 1 || report.Rows[0].Key != time.Now().UTC().Format("2006-01-02") {
+		t.Errorf("unexpected report by day: %+v", report.Rows)
+	}
+
+	report, err = mgr.Usage(ctx, time.Now().Add(time.Hour), UsageByModel)
+	if err != nil || len(report.Rows) != 0 || report.Total.Generations != 0 {
+		t.Errorf("future since: %+v, %v", report, err)
+	}
+	if _, err := mgr.Usage(ctx, start, "provider"); err == nil {
+		t.Error("expected an error for an unknown grouping")
+	}
+}
+
+func TestUsageSkipsHiddenDAGs(t *testing.T) {
+	mgr, cleanup := newTestManager(t, mock.Config{Mode: "fixed", FixedResponse: "ok"})
+	defer cleanup()
+	ctx := context.Background()
+	start := time.Now().Add(-time.Second)
+
+	events, err := mgr.Prompt(ContextWithAPIKey(ctx, "k1"), "Secret plans", "mock-fast", "", nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private := types.VisibilityPrivate
+	if _, err := mgr.UpdateDAG(ctx, savedNodeID(t, events), DAGUpdate{Visibility: &private}); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]int{"k1": 1, "k2": 0} {
+		report, err := mgr.Usage(ContextWithAPIKey(ctx, key), start, UsageByDAG)
+		if err != nil {
+			t.Fatalf("Usage: %v", err)
+		}
+		if len(report.Rows) != want || report.Total.Generations != want {
+			t.Errorf("key %s: report = %+v, want %d DAGs", key, report, want)
+		}
+	}
+}
+
+func TestNodeCostPrefersRecordedPricing(t *testing.T) {
+	mgr := &Manager{pricing: map[string]ModelPrice{"m": {InputPer1M: 100, OutputPer1M: 100}}}
+	node := &types.Node{NodeType: types.NodeTypeAssistant, Model: "m", TokensIn: 1_000_000, TokensOut: 1_000_000}
+	if cost := mgr.nodeCost(node); cost.Total != 200 || cost.Source != types.CostSourceConfig {
+		t.Errorf("configured cost = %+v", cost)
+	}
+	node.Metadata = []byte(`{"provider_cost":{"total":1.5,"currency":"USD","source":"provider_response"}}`)
+	if cost := mgr.nodeCost(node); cost.Total != 1.5 || cost.Source != types.CostSourceProviderResponse {
+		t.Errorf("recorded cost = %+v", cost)
+	}
+}
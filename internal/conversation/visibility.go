@@ -0,0 +1,101 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+
+	"langdag.com/langdag/types"
+)
+
+type apiKeyKey struct{}
+
+// ContextWithAPIKey returns a child context for a request made with the
+// stored API key keyID, or "" for requests without a key (guests, public
+// readers). DAGs started, edited into or cloned with this context are
+// owned by the key, and private DAGs of other keys are left out of
+// searches. Without it, as for the server key, every DAG is reachable.
+func ContextWithAPIKey(ctx context.Context, keyID string) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, keyID)
+}
+
+func contextOwner(ctx context.Context) string {
+	owner, _ := ctx.Value(apiKeyKey{}).(string)
+	return owner
+}
+
+// GuestTag is the tag shown on guest DAGs. It is reserved: Classify keeps
+// it on guest DAGs and never adds it to others. Guest access itself is
+// decided by IsGuest.
+const GuestTag = "guest"
+
+type guestKey struct{}
+
+// ContextAsGuest returns a child context for a request made by a guest of
+// the API server. DAGs started with this context are guest DAGs, tagged
+// GuestTag.
+func ContextAsGuest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, guestKey{}, true)
+}
+
+// IsGuestContext reports whether ctx is the context of a guest request,
+// as returned by ContextAsGuest.
+func IsGuestContext(ctx context.Context) bool {
+	guest, _ := ctx.Value(guestKey{}).(bool)
+	return guest
+}
+
+// IsGuest reports whether the DAG whose root is root was started by a
+// guest.
+func IsGuest(root *types.Node) bool {
+	meta := types.UserMetadataFromNode(root)
+	return meta != nil && meta.Guest
+}
+
+// VisibleTo reports whether the DAG whose root is root may be reached by
+// the request of ctx: private DAGs only by their owner's key.
+func VisibleTo(ctx context.Context, root *types.Node) bool {
+	keyID, restricted := ctx.Value(apiKeyKey{}).(string)
+	if !restricted || Visibility(root) != types.VisibilityPrivate {
+		return true
+	}
+	return keyID != "" && keyID == Owner(root)
+}
+
+// ValidVisibility reports whether v is a DAG visibility level.
+func ValidVisibility(v string) bool {
+	switch v {
+	case types.VisibilityPrivate, types.VisibilityTeam, types.VisibilityPublicReadOnly:
+		return true
+	}
+	return false
+}
+
+// Visibility returns the visibility level of the DAG whose root is root.
+func Visibility(root *types.Node) string {
+	if meta := types.UserMetadataFromNode(root); meta != nil && meta.Visibility != "" {
+		return meta.Visibility
+	}
+	return types.VisibilityTeam
+}
+
+// Owner returns the ID of the API key that started the DAG whose root is
+// root, or "" when it was started without a stored key.
+func Owner(root *types.Node) string {
+	if meta := types.UserMetadataFromNode(root); meta != nil {
+		return meta.Owner
+	}
+	return ""
+}
+
+// setOwner records owner on a new root.
+func setOwner(root *types.Node, owner string) {
+	meta := types.UserMetadataFromNode(root)
+	if meta == nil {
+		if owner == "" {
+			return
+		}
+		meta = &types.UserNodeMetadata{}
+	}
+	meta.Owner = owner
+	root.Metadata, _ = json.Marshal(meta)
+}
@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"langdag.com/langdag/internal/config"
+)
+
+func TestNew_JSONWithRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, config.LoggingConfig{Level: "info", Format: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.DebugContext(ctx, "hidden")
+	logger.With("component", "test").InfoContext(ctx, "shown", "n", 3)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1 (debug is below info): %q", len(lines), buf.String())
+	}
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec["msg"] != "shown" || rec["request_id"] != "req-1" || rec["component"] != "test" || rec["n"] != 3.0 {
+		t.Errorf("record = %v", rec)
+	}
+}
+
+func TestNew_TextDebug(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, config.LoggingConfig{Level: "debug", Format: "text"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("detail", "key", "value")
+	if got := buf.String(); !strings.Contains(got, "level=DEBUG") || !strings.Contains(got, "key=value") || strings.Contains(got, "request_id") {
+		t.Errorf("output = %q", got)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, config.LoggingConfig{Level: "loud"}); err == nil {
+		t.Error("expected error for unknown level")
+	}
+	if _, err := New(&bytes.Buffer{}, config.LoggingConfig{Format: "xml"}); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestSetup_Default(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	if err := Setup(config.LoggingConfig{Level: "warn", Format: "json"}); err != nil {
+		t.Fatal(err)
+	}
+	if slog.Default().Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("info should be disabled at warn level")
+	}
+}
@@ -75,7 +75,7 @@ func TestBuildParams(t *testing.T) {
 		Messages:    []types.Message{{Role: "user", Content: json.RawMessage(`"Hello"`)}},
 		System:      "You are helpful.",
 		MaxTokens:   1024,
-		Temperature: 0.7,
+		Temperature: floatPtr(0.7),
 		StopSeqs:    []string{"END"},
 	}
 
@@ -43,6 +43,16 @@ func (p *BedrockProvider) Models() []types.ModelInfo {
 	}
 }
 
+// Embed is not supported: Anthropic has no embeddings API.
+func (p *BedrockProvider) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	return nil, types.ErrEmbeddingsNotSupported
+}
+
+// Capabilities reports what the provider can accept.
+func (p *BedrockProvider) Capabilities() types.Capabilities {
+	return capabilities(p.Models())
+}
+
 // Complete performs a basic completion request.
 func (p *BedrockProvider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	params, err := buildParams(req)
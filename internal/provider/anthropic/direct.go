@@ -35,6 +35,16 @@ func (p *Provider) Models() []types.ModelInfo {
 	}
 }
 
+// Embed is not supported: Anthropic has no embeddings API.
+func (p *Provider) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	return nil, types.ErrEmbeddingsNotSupported
+}
+
+// Capabilities reports what the provider can accept.
+func (p *Provider) Capabilities() types.Capabilities {
+	return capabilities(p.Models())
+}
+
 // Complete performs a basic completion request.
 func (p *Provider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	params, err := buildParams(req)
@@ -47,14 +47,18 @@ func buildParams(req *types.CompletionRequest) (anthropic.MessageNewParams, erro
 		}
 		// Temperature must NOT be set when thinking is enabled.
 		// Skip the temperature block below.
-	} else if req.Temperature > 0 {
-		params.Temperature = param.NewOpt(req.Temperature)
+	} else if req.Temperature != nil {
+		params.Temperature = param.NewOpt(*req.Temperature)
 	}
 
 	if len(req.StopSeqs) > 0 {
 		params.StopSequences = req.StopSeqs
 	}
 
+	if userID := req.UserID(); userID != "" {
+		params.Metadata = anthropic.MetadataParam{UserID: param.NewOpt(userID)}
+	}
+
 	// Set cache control breakpoint on the last content block of the
 	// second-to-last message. This caches the entire conversation prefix
 	// (system + tools + messages up to that point). On the next turn, only the
@@ -88,6 +92,16 @@ func buildParams(req *types.CompletionRequest) (anthropic.MessageNewParams, erro
 	return params, nil
 }
 
+// capabilities returns the capabilities shared by all Anthropic-protocol
+// variants. Anthropic has no native JSON output mode.
+func capabilities(models []types.ModelInfo) types.Capabilities {
+	return types.Capabilities{
+		Tools:      true,
+		Vision:     true,
+		MaxContext: types.MaxContextWindow(models),
+	}
+}
+
 // convertMessages converts types.Message to anthropic message params.
 func convertMessages(messages []types.Message) ([]anthropic.MessageParam, error) {
 	result := make([]anthropic.MessageParam, 0, len(messages))
@@ -273,6 +287,22 @@ func normalizedUsagePtr(usage types.Usage) *types.NormalizedUsage {
 	return &normalized
 }
 
+// mergeDeltaUsage copies the counts of a message_delta event into usage.
+// They are cumulative; input and cache counts are only present when the
+// API updates them after message_start.
+func mergeDeltaUsage(usage *types.Usage, delta anthropic.MessageDeltaUsage) {
+	usage.OutputTokens = int(delta.OutputTokens)
+	if delta.JSON.InputTokens.Valid() {
+		usage.InputTokens = int(delta.InputTokens)
+	}
+	if delta.JSON.CacheReadInputTokens.Valid() {
+		usage.CacheReadInputTokens = int(delta.CacheReadInputTokens)
+	}
+	if delta.JSON.CacheCreationInputTokens.Valid() {
+		usage.CacheCreationInputTokens = int(delta.CacheCreationInputTokens)
+	}
+}
+
 // processStreamEvents reads from an Anthropic SDK stream and converts events
 // to types.StreamEvent, sending them on the provided channel.
 func processStreamEvents(stream *ssestream.Stream[anthropic.MessageStreamEventUnion], events chan<- types.StreamEvent) {
@@ -355,7 +385,7 @@ func processStreamEvents(stream *ssestream.Stream[anthropic.MessageStreamEventUn
 		case "message_delta":
 			if fullResponse != nil {
 				fullResponse.StopReason = string(event.Delta.StopReason)
-				fullResponse.Usage.OutputTokens = int(event.Usage.OutputTokens)
+				mergeDeltaUsage(&fullResponse.Usage, event.Usage)
 				fullResponse.NormalizedUsage = normalizedUsagePtr(fullResponse.Usage)
 			}
 
@@ -223,12 +223,14 @@ func TestBuildParams_SystemAndToolsBothCached(t *testing.T) {
 
 func boolPtr(v bool) *bool { return &v }
 
+func floatPtr(v float64) *float64 { return &v }
+
 func TestBuildParams_ThinkEnabled(t *testing.T) {
 	req := &types.CompletionRequest{
 		Model:       "claude-sonnet-4-20250514",
 		Messages:    []types.Message{{Role: "user", Content: json.RawMessage(`"Hello"`)}},
 		MaxTokens:   1024,
-		Temperature: 0.7,
+		Temperature: floatPtr(0.7),
 		Think:       boolPtr(true),
 	}
 	params, err := buildParams(req)
@@ -278,7 +280,7 @@ func TestBuildParams_ThinkFalse(t *testing.T) {
 		Model:       "claude-sonnet-4-20250514",
 		Messages:    []types.Message{{Role: "user", Content: json.RawMessage(`"Hello"`)}},
 		MaxTokens:   1024,
-		Temperature: 0.5,
+		Temperature: floatPtr(0.5),
 		Think:       boolPtr(false),
 	}
 	params, err := buildParams(req)
@@ -302,12 +304,29 @@ func TestBuildParams_ThinkFalse(t *testing.T) {
 	}
 }
 
+func TestBuildParams_ZeroTemperature(t *testing.T) {
+	req := &types.CompletionRequest{
+		Model:       "claude-sonnet-4-20250514",
+		Messages:    []types.Message{{Role: "user", Content: json.RawMessage(`"Hello"`)}},
+		MaxTokens:   1024,
+		Temperature: floatPtr(0),
+	}
+	params, err := buildParams(req)
+	if err != nil {
+		t.Fatalf("buildParams failed: %v", err)
+	}
+	// An explicit 0 is sent, unlike an unset temperature.
+	if !params.Temperature.Valid() || params.Temperature.Value != 0 {
+		t.Errorf("Temperature = %+v, want an explicit 0", params.Temperature)
+	}
+}
+
 func TestBuildParams_ThinkNil(t *testing.T) {
 	req := &types.CompletionRequest{
 		Model:       "claude-sonnet-4-20250514",
 		Messages:    []types.Message{{Role: "user", Content: json.RawMessage(`"Hello"`)}},
 		MaxTokens:   2048,
-		Temperature: 0.9,
+		Temperature: floatPtr(0.9),
 	}
 	params, err := buildParams(req)
 	if err != nil {
@@ -1636,3 +1655,58 @@ func TestProcessStreamEvents_NoMessageStart(t *testing.T) {
 		t.Error("should not emit Done when fullResponse is nil (no message_start)")
 	}
 }
+
+// ---------------------------------------------------------------------------
+// buildParams metadata
+// ---------------------------------------------------------------------------
+
+func TestBuildParams_ForwardsUserID(t *testing.T) {
+	req := &types.CompletionRequest{
+		Model:     "claude-sonnet-4-20250514",
+		Messages:  []types.Message{{Role: "user", Content: json.RawMessage(`"Hello"`)}},
+		MaxTokens: 1024,
+		Metadata:  &types.RequestMetadata{UserID: "user-42"},
+	}
+	params, err := buildParams(req)
+	if err != nil {
+		t.Fatalf("buildParams: %v", err)
+	}
+	if got := params.Metadata.UserID.Value; got != "user-42" {
+		t.Errorf("metadata.user_id = %q, want %q", got, "user-42")
+	}
+}
+
+func TestProcessStreamEvents_CacheUsageFromMessageDelta(t *testing.T) {
+	dec := &mockDecoder{events: []ssestream.Event{
+		makeEvent("message_start", map[string]interface{}{
+			"type": "message_start",
+			"message": map[string]interface{}{
+				"id":    "msg_cache",
+				"model": "claude-sonnet-4-20250514",
+				"usage": map[string]interface{}{"input_tokens": 12, "output_tokens": 1, "cache_read_input_tokens": 0},
+			},
+		}),
+		makeEvent("message_delta", map[string]interface{}{
+			"type":  "message_delta",
+			"delta": map[string]interface{}{"stop_reason": "end_turn"},
+			"usage": map[string]interface{}{"output_tokens": 7, "cache_read_input_tokens": 2048, "cache_creation_input_tokens": 300},
+		}),
+		makeEvent("message_stop", map[string]interface{}{"type": "message_stop"}),
+	}}
+	events := make(chan types.StreamEvent, 10)
+	processStreamEvents(ssestream.NewStream[anthropic.MessageStreamEventUnion](dec, nil), events)
+	close(events)
+
+	var usage *types.Usage
+	for ev := range events {
+		if ev.Type == types.StreamEventDone {
+			usage = &ev.Response.Usage
+		}
+	}
+	if usage == nil {
+		t.Fatal("no done event")
+	}
+	if usage.InputTokens != 12 || usage.OutputTokens != 7 || usage.CacheReadInputTokens != 2048 || usage.CacheCreationInputTokens != 300 {
+		t.Errorf("usage = %+v", *usage)
+	}
+}
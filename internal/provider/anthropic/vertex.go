@@ -39,6 +39,16 @@ func (p *VertexProvider) Models() []types.ModelInfo {
 	}
 }
 
+// Embed is not supported: Anthropic has no embeddings API.
+func (p *VertexProvider) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	return nil, types.ErrEmbeddingsNotSupported
+}
+
+// Capabilities reports what the provider can accept.
+func (p *VertexProvider) Capabilities() types.Capabilities {
+	return capabilities(p.Models())
+}
+
 // Complete performs a basic completion request.
 func (p *VertexProvider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	params, err := buildParams(req)
@@ -3,7 +3,7 @@ package provider
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/rand"
 	"sort"
 	"strings"
@@ -110,6 +110,11 @@ func (r *DeploymentRouter) Name() string {
 	return "deployment-router"
 }
 
+// Deployments returns the sorted IDs of the configured deployments.
+func (r *DeploymentRouter) Deployments() []string {
+	return sortedDeploymentIDs(r.deployments)
+}
+
 // Models returns canonical model rows that at least one configured deployment
 // can serve.
 func (r *DeploymentRouter) Models() []types.ModelInfo {
@@ -145,6 +150,26 @@ func (r *DeploymentRouter) Models() []types.ModelInfo {
 	return out
 }
 
+// Embed uses the first deployment, in ID order, whose provider has an
+// embeddings API, passing req.Model through unchanged: embedding models
+// are not in the catalog.
+func (r *DeploymentRouter) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	var providers []Provider
+	for _, deploymentID := range sortedDeploymentIDs(r.deployments) {
+		providers = append(providers, r.deployments[deploymentID].Provider)
+	}
+	return embedFirst(ctx, req, providers)
+}
+
+// Capabilities returns the union of the configured deployments' capabilities.
+func (r *DeploymentRouter) Capabilities() types.Capabilities {
+	var caps types.Capabilities
+	for _, deploymentID := range sortedDeploymentIDs(r.deployments) {
+		caps = caps.Union(r.deployments[deploymentID].Provider.Capabilities())
+	}
+	return caps
+}
+
 // Complete resolves the request model to a routeable offering, calls the
 // selected deployment adapter with its native model ID, and attaches served
 // identity/pricing metadata to the response.
@@ -172,7 +197,7 @@ func (r *DeploymentRouter) Complete(ctx context.Context, req *types.CompletionRe
 				return resp, nil
 			}
 			lastErr = err
-			log.Printf("deployment router: deployment %q failed for %q: %v", choice.DeploymentID, target.CanonicalModelID, err)
+			slog.WarnContext(ctx, "deployment router: deployment failed", "deployment", choice.DeploymentID, "model", target.CanonicalModelID, "error", err)
 		}
 	}
 	if lastErr == nil {
@@ -215,7 +240,7 @@ func (r *DeploymentRouter) Stream(ctx context.Context, req *types.CompletionRequ
 					out <- types.StreamEvent{Type: types.StreamEventError, Error: err}
 					return
 				}
-				log.Printf("deployment router: deployment %q stream failed before output for %q: %v", choice.DeploymentID, target.CanonicalModelID, err)
+				slog.WarnContext(ctx, "deployment router: deployment stream failed before output", "deployment", choice.DeploymentID, "model", target.CanonicalModelID, "error", err)
 			}
 		}
 		if lastErr == nil {
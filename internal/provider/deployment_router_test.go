@@ -26,6 +26,14 @@ func (p *captureProvider) Models() []types.ModelInfo {
 	return append([]types.ModelInfo(nil), p.models...)
 }
 
+func (p *captureProvider) Capabilities() types.Capabilities {
+	return types.Capabilities{Tools: true, MaxContext: types.MaxContextWindow(p.models)}
+}
+
+func (p *captureProvider) Embed(context.Context, *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	return nil, types.ErrEmbeddingsNotSupported
+}
+
 func (p *captureProvider) Complete(_ context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	p.calls++
 	copied := *req
@@ -31,8 +31,13 @@ func WithServerToolFilter(p Provider) Provider {
 	return &filterProvider{inner: p, modelTools: modelTools}
 }
 
-func (f *filterProvider) Name() string              { return f.inner.Name() }
-func (f *filterProvider) Models() []types.ModelInfo  { return f.inner.Models() }
+func (f *filterProvider) Name() string                     { return f.inner.Name() }
+func (f *filterProvider) Models() []types.ModelInfo        { return f.inner.Models() }
+func (f *filterProvider) Capabilities() types.Capabilities { return f.inner.Capabilities() }
+
+func (f *filterProvider) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	return f.inner.Embed(ctx, req)
+}
 
 func (f *filterProvider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	return f.inner.Complete(ctx, f.filterTools(req))
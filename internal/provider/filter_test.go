@@ -17,6 +17,13 @@ type stubProvider struct {
 
 func (s *stubProvider) Name() string              { return "stub" }
 func (s *stubProvider) Models() []types.ModelInfo  { return s.models }
+func (s *stubProvider) Capabilities() types.Capabilities {
+	return types.Capabilities{MaxContext: types.MaxContextWindow(s.models)}
+}
+
+func (s *stubProvider) Embed(context.Context, *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	return nil, types.ErrEmbeddingsNotSupported
+}
 
 func (s *stubProvider) Complete(_ context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	s.lastReq = req
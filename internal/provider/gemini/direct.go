@@ -66,6 +66,25 @@ func applyCaps(m *types.ModelInfo) {
 	}
 }
 
+// Embed returns embeddings from the batchEmbedContents endpoint, with
+// gemini-embedding-001 by default.
+func (p *Provider) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	model := embeddingModel(req)
+	url := fmt.Sprintf("%s/models/%s:batchEmbedContents?key=%s", p.baseURL, model, p.apiKey)
+
+	respBody, err := doHTTPRequest(ctx, p.client, url, buildBatchEmbedRequest(model, req.Input), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer respBody.Close()
+	return decodeBatchEmbedResponse(respBody, model, len(req.Input))
+}
+
+// Capabilities reports what the provider can accept.
+func (p *Provider) Capabilities() types.Capabilities {
+	return capabilities(p.Models())
+}
+
 // Complete performs a synchronous completion request.
 func (p *Provider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	body, err := buildRequest(req)
@@ -0,0 +1,105 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"langdag.com/langdag/types"
+)
+
+// defaultEmbeddingModel is used when an embedding request has no model.
+const defaultEmbeddingModel = "gemini-embedding-001"
+
+type embeddingValues struct {
+	Values []float32 `json:"values"`
+}
+
+// AI Studio batchEmbedContents.
+
+type batchEmbedRequest struct {
+	Requests []embedContentRequest `json:"requests"`
+}
+
+type embedContentRequest struct {
+	Model   string         `json:"model"`
+	Content geminiEmbedded `json:"content"`
+}
+
+type geminiEmbedded struct {
+	Parts []map[string]string `json:"parts"`
+}
+
+type batchEmbedResponse struct {
+	Embeddings []embeddingValues `json:"embeddings"`
+}
+
+// Vertex AI predict.
+
+type vertexEmbedRequest struct {
+	Instances []map[string]string `json:"instances"`
+}
+
+type vertexEmbedResponse struct {
+	Predictions []struct {
+		Embeddings embeddingValues `json:"embeddings"`
+	} `json:"predictions"`
+}
+
+func embeddingModel(req *types.EmbeddingRequest) string {
+	if req.Model == "" {
+		return defaultEmbeddingModel
+	}
+	return req.Model
+}
+
+func buildBatchEmbedRequest(model string, input []string) []byte {
+	r := batchEmbedRequest{Requests: make([]embedContentRequest, len(input))}
+	for i, text := range input {
+		r.Requests[i] = embedContentRequest{
+			Model:   "models/" + model,
+			Content: geminiEmbedded{Parts: []map[string]string{{"text": text}}},
+		}
+	}
+	body, _ := json.Marshal(r)
+	return body
+}
+
+func decodeBatchEmbedResponse(body io.Reader, model string, inputs int) (*types.EmbeddingResponse, error) {
+	var resp batchEmbedResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("gemini: failed to decode embeddings: %w", err)
+	}
+	out := &types.EmbeddingResponse{Model: model}
+	for _, e := range resp.Embeddings {
+		out.Embeddings = append(out.Embeddings, e.Values)
+	}
+	if len(out.Embeddings) != inputs {
+		return nil, fmt.Errorf("gemini: got %d embeddings for %d inputs", len(out.Embeddings), inputs)
+	}
+	return out, nil
+}
+
+func buildVertexEmbedRequest(input []string) []byte {
+	r := vertexEmbedRequest{Instances: make([]map[string]string, len(input))}
+	for i, text := range input {
+		r.Instances[i] = map[string]string{"content": text}
+	}
+	body, _ := json.Marshal(r)
+	return body
+}
+
+func decodeVertexEmbedResponse(body io.Reader, model string, inputs int) (*types.EmbeddingResponse, error) {
+	var resp vertexEmbedResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("gemini-vertex: failed to decode embeddings: %w", err)
+	}
+	out := &types.EmbeddingResponse{Model: model}
+	for _, p := range resp.Predictions {
+		out.Embeddings = append(out.Embeddings, p.Embeddings.Values)
+	}
+	if len(out.Embeddings) != inputs {
+		return nil, fmt.Errorf("gemini-vertex: got %d embeddings for %d inputs", len(out.Embeddings), inputs)
+	}
+	return out, nil
+}
@@ -277,8 +277,8 @@ func buildRequest(req *types.CompletionRequest) ([]byte, error) {
 		gc.MaxOutputTokens = req.MaxTokens
 		hasConfig = true
 	}
-	if req.Temperature > 0 {
-		gc.Temperature = &req.Temperature
+	if req.Temperature != nil {
+		gc.Temperature = req.Temperature
 		hasConfig = true
 	}
 	if len(req.StopSeqs) > 0 {
@@ -301,6 +301,23 @@ func buildRequest(req *types.CompletionRequest) ([]byte, error) {
 	return body, nil
 }
 
+// capabilities derives provider capabilities from the models' modelCaps
+// entries. All Gemini-family models accept images and JSON output mode.
+func capabilities(models []types.ModelInfo) types.Capabilities {
+	c := types.Capabilities{
+		Vision:     true,
+		JSONMode:   true,
+		MaxContext: types.MaxContextWindow(models),
+	}
+	for _, m := range models {
+		if m.SupportsFunctionCalling {
+			c.Tools = true
+			break
+		}
+	}
+	return c
+}
+
 func convertMessages(messages []types.Message) []content {
 	var result []content
 	// Map of tool call ID → function name, built from tool_use blocks
@@ -55,6 +55,25 @@ func (p *VertexProvider) Models() []types.ModelInfo {
 	return base
 }
 
+// Embed returns embeddings from the Vertex predict endpoint of the
+// embedding model, gemini-embedding-001 by default.
+func (p *VertexProvider) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	model := embeddingModel(req)
+	url := fmt.Sprintf("%s/publishers/google/models/%s:predict", p.baseURL, model)
+
+	respBody, err := doHTTPRequest(ctx, p.client, url, buildVertexEmbedRequest(req.Input), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer respBody.Close()
+	return decodeVertexEmbedResponse(respBody, model, len(req.Input))
+}
+
+// Capabilities reports what the provider can accept.
+func (p *VertexProvider) Capabilities() types.Capabilities {
+	return capabilities(p.Models())
+}
+
 // Complete performs a synchronous completion request.
 func (p *VertexProvider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	body, err := buildRequest(req)
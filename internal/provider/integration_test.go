@@ -22,6 +22,11 @@ type configurableProvider struct {
 
 func (p *configurableProvider) Name() string             { return p.name }
 func (p *configurableProvider) Models() []types.ModelInfo { return []types.ModelInfo{{ID: p.name + "-model", Name: p.name}} }
+func (p *configurableProvider) Capabilities() types.Capabilities { return types.Capabilities{} }
+
+func (p *configurableProvider) Embed(context.Context, *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	return nil, types.ErrEmbeddingsNotSupported
+}
 
 func (p *configurableProvider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	n := atomic.AddInt32(&p.callCount, 1)
@@ -5,9 +5,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"math/rand"
 	"strings"
 	"time"
+	"unicode"
 
 	"langdag.com/langdag/types"
 )
@@ -66,6 +68,37 @@ func (p *Provider) Models() []types.ModelInfo {
 	}
 }
 
+// EmbeddingDimensions is the size of the mock's embeddings.
+const EmbeddingDimensions = 64
+
+// Embed returns deterministic bag-of-words embeddings: each lowercased
+// word adds to a dimension picked by its hash, so texts sharing words are
+// similar. The model is always "mock-embed".
+func (p *Provider) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	resp := &types.EmbeddingResponse{Model: "mock-embed", Embeddings: make([][]float32, len(req.Input))}
+	for i, text := range req.Input {
+		v := make([]float32, EmbeddingDimensions)
+		for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			v[h.Sum32()%EmbeddingDimensions]++
+			resp.TokensIn++
+		}
+		resp.Embeddings[i] = v
+	}
+	return resp, nil
+}
+
+// Capabilities reports what the mock can accept.
+func (p *Provider) Capabilities() types.Capabilities {
+	return types.Capabilities{
+		Tools:      true,
+		MaxContext: types.MaxContextWindow(p.Models()),
+	}
+}
+
 // shouldFail increments the call counter and returns true if the current call
 // should return an error (either because Mode is "error" or because the call
 // is within the FailUntilCall transient-failure window).
@@ -49,11 +49,36 @@ func (p *AzureProvider) Models() []types.ModelInfo {
 	}
 }
 
+// Capabilities reports what the provider can accept.
+func (p *AzureProvider) Capabilities() types.Capabilities {
+	return types.Capabilities{
+		Tools:      true,
+		Vision:     true,
+		JSONMode:   true,
+		MaxContext: types.MaxContextWindow(p.Models()),
+	}
+}
+
+// Embed returns embeddings from the deployment named by req.Model, which
+// is required.
+func (p *AzureProvider) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	if req.Model == "" {
+		return nil, fmt.Errorf("openai-azure: an embedding deployment is required as model")
+	}
+	model, body := buildEmbeddingRequest(req, "")
+	respBody, err := p.doRequest(ctx, model, "embeddings", body)
+	if err != nil {
+		return nil, err
+	}
+	defer respBody.Close()
+	return decodeEmbeddingResponse("openai-azure", respBody, model, len(req.Input))
+}
+
 // Complete performs a synchronous completion request.
 func (p *AzureProvider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	body := buildRequest(req, false, openAIServerTools)
 
-	respBody, err := p.doRequest(ctx, req.Model, body)
+	respBody, err := p.doRequest(ctx, req.Model, "chat/completions", body)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +96,7 @@ func (p *AzureProvider) Complete(ctx context.Context, req *types.CompletionReque
 func (p *AzureProvider) Stream(ctx context.Context, req *types.CompletionRequest) (<-chan types.StreamEvent, error) {
 	body := buildRequest(req, true, openAIServerTools)
 
-	respBody, err := p.doRequest(ctx, req.Model, body)
+	respBody, err := p.doRequest(ctx, req.Model, "chat/completions", body)
 	if err != nil {
 		return nil, err
 	}
@@ -86,9 +111,9 @@ func (p *AzureProvider) Stream(ctx context.Context, req *types.CompletionRequest
 	return events, nil
 }
 
-func (p *AzureProvider) doRequest(ctx context.Context, model string, body []byte) (io.ReadCloser, error) {
-	// Azure URL: {endpoint}/openai/deployments/{model}/chat/completions?api-version={version}
-	url := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s", p.endpoint, model, p.apiVersion)
+func (p *AzureProvider) doRequest(ctx context.Context, model, operation string, body []byte) (io.ReadCloser, error) {
+	// Azure URL: {endpoint}/openai/deployments/{model}/{operation}?api-version={version}
+	url := fmt.Sprintf("%s/openai/deployments/%s/%s?api-version=%s", p.endpoint, model, operation, p.apiVersion)
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
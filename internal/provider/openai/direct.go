@@ -81,6 +81,28 @@ func (p *Provider) Models() []types.ModelInfo {
 	}
 }
 
+// Capabilities reports what the provider can accept.
+func (p *Provider) Capabilities() types.Capabilities {
+	return types.Capabilities{
+		Tools:      true,
+		Vision:     true,
+		JSONMode:   true,
+		MaxContext: types.MaxContextWindow(p.Models()),
+	}
+}
+
+// Embed returns embeddings from the /embeddings endpoint, with
+// text-embedding-3-small by default.
+func (p *Provider) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	model, body := buildEmbeddingRequest(req, defaultEmbeddingModel)
+	respBody, err := p.doRequest(ctx, "/embeddings", body)
+	if err != nil {
+		return nil, err
+	}
+	defer respBody.Close()
+	return decodeEmbeddingResponse("openai", respBody, model, len(req.Input))
+}
+
 // Complete performs a synchronous completion request.
 func (p *Provider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	switch openAIProtocolForRequest(req) {
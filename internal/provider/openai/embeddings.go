@@ -0,0 +1,62 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"langdag.com/langdag/types"
+)
+
+// Default embedding models of the OpenAI-protocol variants.
+const (
+	defaultEmbeddingModel       = "text-embedding-3-small"
+	defaultOllamaEmbeddingModel = "nomic-embed-text"
+)
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage *usage `json:"usage,omitempty"`
+}
+
+// buildEmbeddingRequest builds an /embeddings request body, using
+// defaultModel when req has no model.
+func buildEmbeddingRequest(req *types.EmbeddingRequest, defaultModel string) (string, []byte) {
+	model := req.Model
+	if model == "" {
+		model = defaultModel
+	}
+	body, _ := json.Marshal(embeddingRequest{Model: model, Input: req.Input})
+	return model, body
+}
+
+// decodeEmbeddingResponse reads an /embeddings response, ordering the
+// embeddings like the inputs. name prefixes errors.
+func decodeEmbeddingResponse(name string, body io.Reader, model string, inputs int) (*types.EmbeddingResponse, error) {
+	var resp embeddingResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("%s: failed to decode embeddings: %w", name, err)
+	}
+	if len(resp.Data) != inputs {
+		return nil, fmt.Errorf("%s: got %d embeddings for %d inputs", name, len(resp.Data), inputs)
+	}
+	sort.Slice(resp.Data, func(i, j int) bool { return resp.Data[i].Index < resp.Data[j].Index })
+	out := &types.EmbeddingResponse{Model: model, Embeddings: make([][]float32, len(resp.Data))}
+	for i, d := range resp.Data {
+		out.Embeddings[i] = d.Embedding
+	}
+	if resp.Usage != nil {
+		out.TokensIn = resp.Usage.PromptTokens
+	}
+	return out, nil
+}
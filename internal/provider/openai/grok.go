@@ -48,6 +48,21 @@ func (p *GrokProvider) Models() []types.ModelInfo {
 	}
 }
 
+// Embed is not supported.
+func (p *GrokProvider) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	return nil, types.ErrEmbeddingsNotSupported
+}
+
+// Capabilities reports what the provider can accept.
+func (p *GrokProvider) Capabilities() types.Capabilities {
+	return types.Capabilities{
+		Tools:      true,
+		Vision:     true,
+		JSONMode:   true,
+		MaxContext: types.MaxContextWindow(p.Models()),
+	}
+}
+
 // Complete performs a synchronous completion request using the Responses API.
 func (p *GrokProvider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	body := buildResponsesRequest(req, false)
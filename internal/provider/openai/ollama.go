@@ -134,11 +134,30 @@ func (p *OllamaProvider) Models() []types.ModelInfo {
 	return models
 }
 
+// Capabilities reports what the provider can accept. Vision support and
+// context size depend on the locally pulled model, so they are not claimed
+// here; use Models() for per-model context windows.
+func (p *OllamaProvider) Capabilities() types.Capabilities {
+	return types.Capabilities{Tools: true, JSONMode: true}
+}
+
+// Embed returns embeddings from the OpenAI-compatible /v1/embeddings
+// endpoint, with nomic-embed-text by default. The model must be pulled.
+func (p *OllamaProvider) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	model, body := buildEmbeddingRequest(req, defaultOllamaEmbeddingModel)
+	respBody, err := p.doRequest(ctx, "/v1/embeddings", body)
+	if err != nil {
+		return nil, err
+	}
+	defer respBody.Close()
+	return decodeEmbeddingResponse("ollama", respBody, model, len(req.Input))
+}
+
 // Complete performs a synchronous completion request.
 func (p *OllamaProvider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	body := buildRequest(req, false, nil)
 
-	respBody, err := p.doRequest(ctx, body)
+	respBody, err := p.doRequest(ctx, "/v1/chat/completions", body)
 	if err != nil {
 		return nil, err
 	}
@@ -156,7 +175,7 @@ func (p *OllamaProvider) Complete(ctx context.Context, req *types.CompletionRequ
 func (p *OllamaProvider) Stream(ctx context.Context, req *types.CompletionRequest) (<-chan types.StreamEvent, error) {
 	body := buildRequest(req, true, nil)
 
-	respBody, err := p.doRequest(ctx, body)
+	respBody, err := p.doRequest(ctx, "/v1/chat/completions", body)
 	if err != nil {
 		return nil, err
 	}
@@ -171,8 +190,8 @@ func (p *OllamaProvider) Stream(ctx context.Context, req *types.CompletionReques
 	return events, nil
 }
 
-func (p *OllamaProvider) doRequest(ctx context.Context, body []byte) (io.ReadCloser, error) {
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/v1/chat/completions", bytes.NewReader(body))
+func (p *OllamaProvider) doRequest(ctx context.Context, path string, body []byte) (io.ReadCloser, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+path, bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("ollama: failed to create request: %w", err)
 	}
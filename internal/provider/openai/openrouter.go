@@ -6,7 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 	"sync"
@@ -68,7 +68,7 @@ func (p *OpenRouterProvider) Models() []types.ModelInfo {
 		p.modelCache = models
 		p.modelsFetched = true
 	} else {
-		log.Printf("openrouter: failed to fetch models: %v", err)
+		slog.Warn("openrouter: failed to fetch models", "error", err)
 	}
 	return p.modelCache
 }
@@ -132,6 +132,24 @@ func (p *OpenRouterProvider) fetchModels() ([]types.ModelInfo, error) {
 	return models, nil
 }
 
+// Embed is not supported.
+func (p *OpenRouterProvider) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	return nil, types.ErrEmbeddingsNotSupported
+}
+
+// Capabilities reports what the provider can accept. It never fetches the
+// model catalog: MaxContext stays 0 (unknown) until Models has cached it.
+func (p *OpenRouterProvider) Capabilities() types.Capabilities {
+	p.modelsMu.Lock()
+	defer p.modelsMu.Unlock()
+	return types.Capabilities{
+		Tools:      true,
+		Vision:     true,
+		JSONMode:   true,
+		MaxContext: types.MaxContextWindow(p.modelCache),
+	}
+}
+
 // Complete performs a synchronous completion request.
 // We pass openAIServerTools here, but Models() returns entries with empty
 // ServerTools, so upstream filterProvider strips them for non-OpenAI models.
@@ -122,6 +122,35 @@ func TestOpenRouterModels_Cached(t *testing.T) {
 	}
 }
 
+func TestOpenRouterCapabilitiesUseCachedModels(t *testing.T) {
+	callCount := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+		json.NewEncoder(w).Encode(openRouterModelsResponse{
+			Data: []openRouterModel{
+				{ID: "openai/gpt-4o", Name: "GPT-4o", ContextLength: 128000},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	p := NewOpenRouter("test-key", srv.URL)
+	if caps := p.Capabilities(); !caps.Tools || caps.MaxContext != 0 {
+		t.Errorf("capabilities before fetch = %+v, want tools and unknown max context", caps)
+	}
+	if callCount != 0 {
+		t.Fatalf("Capabilities made %d HTTP calls, want 0", callCount)
+	}
+
+	p.Models()
+	if caps := p.Capabilities(); caps.MaxContext != 128000 {
+		t.Errorf("max context after fetch = %d, want 128000", caps.MaxContext)
+	}
+	if callCount != 1 {
+		t.Errorf("expected 1 HTTP call, got %d", callCount)
+	}
+}
+
 func TestOpenRouterRequiredHeaders(t *testing.T) {
 	var gotReferer, gotTitle string
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -529,7 +558,7 @@ func TestOpenRouterComplete_RequestBodyFields(t *testing.T) {
 		Model:       "openai/gpt-4o",
 		Messages:    []types.Message{{Role: "user", Content: json.RawMessage(`"hi"`)}},
 		MaxTokens:   512,
-		Temperature: temp,
+		Temperature: &temp,
 		StopSeqs:    []string{"STOP"},
 	})
 
@@ -27,6 +27,7 @@ type chatCompletionRequest struct {
 	StreamOptions       *streamOptions   `json:"stream_options,omitempty"`
 	Think               *bool            `json:"think,omitempty"`
 	ReasoningEffort     string           `json:"reasoning_effort,omitempty"`
+	User                string           `json:"user,omitempty"`
 }
 
 type streamOptions struct {
@@ -166,8 +167,8 @@ func buildChatCompletionRequestWithOptions(req *types.CompletionRequest, stream
 			cr.MaxTokens = req.MaxTokens
 		}
 	}
-	if req.Temperature > 0 {
-		cr.Temperature = &req.Temperature
+	if req.Temperature != nil {
+		cr.Temperature = req.Temperature
 	}
 	if len(req.StopSeqs) > 0 {
 		cr.Stop = req.StopSeqs
@@ -181,6 +182,7 @@ func buildChatCompletionRequestWithOptions(req *types.CompletionRequest, stream
 	if opts.IncludeReasoningEffort {
 		cr.ReasoningEffort = openAIResponsesReasoningEffort(req)
 	}
+	cr.User = req.UserID()
 	if stream {
 		cr.StreamOptions = &streamOptions{IncludeUsage: true}
 	}
@@ -359,3 +359,23 @@ func TestConvertResponsesTools_WebSearchWithSchemaIsClientTool(t *testing.T) {
 		t.Errorf("name = %v, want %q", m["name"], "web_search")
 	}
 }
+
+func TestBuildRequest_ForwardsUserID(t *testing.T) {
+	req := &types.CompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []types.Message{{Role: "user", Content: json.RawMessage(`"Hello"`)}},
+		Metadata: &types.RequestMetadata{UserID: "user-42"},
+	}
+	for name, body := range map[string][]byte{
+		"chat":      buildOpenAIChatCompletionRequest(req, false, nil),
+		"responses": buildOpenAIResponsesRequest(req, false),
+	} {
+		var m map[string]interface{}
+		if err := json.Unmarshal(body, &m); err != nil {
+			t.Fatalf("%s: unmarshal: %v", name, err)
+		}
+		if m["user"] != "user-42" {
+			t.Errorf("%s: user = %v, want %q", name, m["user"], "user-42")
+		}
+	}
+}
@@ -28,6 +28,7 @@ type responsesRequest struct {
 	Reasoning       *responsesReasoning `json:"reasoning,omitempty"`
 	Stream          bool                `json:"stream"`
 	Store           bool                `json:"store"`
+	User            string              `json:"user,omitempty"`
 }
 
 type responsesReasoning struct {
@@ -201,13 +202,14 @@ func buildResponsesRequestWithOptions(req *types.CompletionRequest, stream bool,
 		Instructions: instructions,
 		Stream:       stream,
 		Store:        false,
+		User:         req.UserID(),
 	}
 
 	if req.MaxTokens > 0 {
 		rr.MaxOutputTokens = req.MaxTokens
 	}
-	if req.Temperature > 0 {
-		rr.Temperature = &req.Temperature
+	if req.Temperature != nil {
+		rr.Temperature = req.Temperature
 	}
 	if len(req.Tools) > 0 {
 		rr.Tools = convertResponsesTools(req.Tools)
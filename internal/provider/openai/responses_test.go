@@ -11,6 +11,7 @@ import (
 )
 
 func TestBuildResponsesRequest_Basic(t *testing.T) {
+	temp := 0.7
 	req := &types.CompletionRequest{
 		Model:  "grok-3",
 		System: "You are helpful.",
@@ -18,7 +19,7 @@ func TestBuildResponsesRequest_Basic(t *testing.T) {
 			{Role: "user", Content: json.RawMessage(`"Hello"`)},
 		},
 		MaxTokens:   1024,
-		Temperature: 0.7,
+		Temperature: &temp,
 	}
 
 	body := buildResponsesRequest(req, false)
@@ -20,4 +20,12 @@ type Provider interface {
 
 	// Models returns the available models.
 	Models() []types.ModelInfo
+
+	// Capabilities reports what the provider can accept.
+	Capabilities() types.Capabilities
+
+	// Embed returns vector embeddings of req.Input, with the provider's
+	// default embedding model when req.Model is empty. Providers without an
+	// embeddings API return types.ErrEmbeddingsNotSupported.
+	Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error)
 }
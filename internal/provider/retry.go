@@ -5,7 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"math"
 	"math/rand"
 	"net"
@@ -38,6 +38,7 @@ type RetryEvent struct {
 	MaxRetries int
 	Delay      time.Duration
 }
+
 // RetryConfig configures retry behavior for provider calls.
 type RetryConfig struct {
 	MaxRetries int
@@ -71,8 +72,9 @@ func WithRetry(p Provider, cfg RetryConfig) Provider {
 	return &retryProvider{inner: p, config: cfg}
 }
 
-func (r *retryProvider) Name() string          { return r.inner.Name() }
-func (r *retryProvider) Models() []types.ModelInfo { return r.inner.Models() }
+func (r *retryProvider) Name() string                     { return r.inner.Name() }
+func (r *retryProvider) Models() []types.ModelInfo        { return r.inner.Models() }
+func (r *retryProvider) Capabilities() types.Capabilities { return r.inner.Capabilities() }
 
 func (r *retryProvider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	var lastErr error
@@ -100,6 +102,32 @@ func (r *retryProvider) Complete(ctx context.Context, req *types.CompletionReque
 	return nil, fmt.Errorf("max retries exceeded: %w", lastErr)
 }
 
+func (r *retryProvider) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	var lastErr error
+	for attempt := 0; attempt <= r.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := r.retryDelay(attempt, lastErr)
+			r.notifyRetry(ctx, lastErr, attempt, delay)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		resp, err := r.inner.Embed(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+
+		if !isTransient(err) {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("max retries exceeded: %w", lastErr)
+}
+
 func (r *retryProvider) Stream(ctx context.Context, req *types.CompletionRequest) (<-chan types.StreamEvent, error) {
 	var lastErr error
 	for attempt := 0; attempt <= r.config.MaxRetries; attempt++ {
@@ -127,7 +155,7 @@ func (r *retryProvider) Stream(ctx context.Context, req *types.CompletionRequest
 }
 
 // notifyRetry calls the per-call context callback, the config-level OnRetry,
-// or falls back to log.Printf.
+// or falls back to logging a warning.
 func (r *retryProvider) notifyRetry(ctx context.Context, err error, attempt int, delay time.Duration) {
 	ev := RetryEvent{
 		Err:        err,
@@ -140,7 +168,7 @@ func (r *retryProvider) notifyRetry(ctx context.Context, err error, attempt int,
 	} else if r.config.OnRetry != nil {
 		r.config.OnRetry(ev)
 	} else {
-		log.Printf("Retry %d/%d after %v: %v", attempt, r.config.MaxRetries, delay, err)
+		slog.WarnContext(ctx, "provider: retrying", "attempt", attempt, "max_retries", r.config.MaxRetries, "delay", delay, "error", err)
 	}
 }
 
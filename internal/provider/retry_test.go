@@ -38,6 +38,11 @@ func (p *failProvider) Stream(ctx context.Context, req *types.CompletionRequest)
 
 func (p *failProvider) Name() string             { return "fail-provider" }
 func (p *failProvider) Models() []types.ModelInfo { return nil }
+func (p *failProvider) Capabilities() types.Capabilities { return types.Capabilities{} }
+
+func (p *failProvider) Embed(context.Context, *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	return nil, types.ErrEmbeddingsNotSupported
+}
 
 func TestRetryComplete_TransientThenSuccess(t *testing.T) {
 	inner := &failProvider{failCount: 2, failErr: fmt.Errorf("status 503: service unavailable")}
@@ -2,9 +2,11 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/rand"
+	"sort"
 
 	"langdag.com/langdag/types"
 )
@@ -48,6 +50,27 @@ func (r *Router) Name() string {
 	return "router"
 }
 
+// Providers returns the sorted names of the providers the router can use,
+// from both weighted entries and the fallback chain.
+func (r *Router) Providers() []string {
+	seen := map[string]bool{}
+	var names []string
+	add := func(p Provider) {
+		if name := p.Name(); !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, e := range r.entries {
+		add(e.Provider)
+	}
+	for _, p := range r.fallbackOrder {
+		add(p)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Models returns the union of all provider models.
 func (r *Router) Models() []types.ModelInfo {
 	seen := map[string]bool{}
@@ -71,21 +94,63 @@ func (r *Router) Models() []types.ModelInfo {
 	return models
 }
 
+// Embed uses the first provider with an embeddings API, weighted entries
+// first, then the fallback chain, moving on to the next one on failure.
+// Embeddings from different models cannot be compared, so set a model
+// only the intended provider serves.
+func (r *Router) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	providers := make([]Provider, 0, len(r.entries)+len(r.fallbackOrder))
+	for _, e := range r.entries {
+		providers = append(providers, e.Provider)
+	}
+	providers = append(providers, r.fallbackOrder...)
+	return embedFirst(ctx, req, providers)
+}
+
+// embedFirst returns the embeddings of the first provider that supports
+// embeddings and succeeds, or the last error.
+func embedFirst(ctx context.Context, req *types.EmbeddingRequest, providers []Provider) (*types.EmbeddingResponse, error) {
+	lastErr := types.ErrEmbeddingsNotSupported
+	for _, p := range providers {
+		resp, err := p.Embed(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		if !errors.Is(err, types.ErrEmbeddingsNotSupported) {
+			slog.WarnContext(ctx, "router: embedding failed", "provider", p.Name(), "error", err)
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
+
+// Capabilities returns the union of all provider capabilities.
+func (r *Router) Capabilities() types.Capabilities {
+	var caps types.Capabilities
+	for _, e := range r.entries {
+		caps = caps.Union(e.Provider.Capabilities())
+	}
+	for _, p := range r.fallbackOrder {
+		caps = caps.Union(p.Capabilities())
+	}
+	return caps
+}
+
 // Complete routes the request to a weighted-random provider, falling back
 // through the fallback chain on failure.
 func (r *Router) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	primary := r.selectProvider()
 	if primary != nil {
-		log.Printf("router: selected provider %q for completion", primary.Name())
+		slog.DebugContext(ctx, "router: selected provider", "provider", primary.Name(), "stream", false)
 		resp, err := primary.Complete(ctx, req)
 		if err == nil {
 			resp.Provider = primary.Name()
 			return resp, nil
 		}
-		log.Printf("router: provider %q failed, trying fallback chain: %v", primary.Name(), err)
+		slog.WarnContext(ctx, "router: provider failed, trying fallback chain", "provider", primary.Name(), "error", err)
 		return r.completeFallback(ctx, req, primary, err)
 	}
-	log.Printf("router: no weighted providers, trying fallback chain")
+	slog.WarnContext(ctx, "router: no weighted providers, trying fallback chain")
 	return r.completeFallback(ctx, req, nil, fmt.Errorf("router: no weighted providers available"))
 }
 
@@ -94,15 +159,15 @@ func (r *Router) Complete(ctx context.Context, req *types.CompletionRequest) (*t
 func (r *Router) Stream(ctx context.Context, req *types.CompletionRequest) (<-chan types.StreamEvent, error) {
 	primary := r.selectProvider()
 	if primary != nil {
-		log.Printf("router: selected provider %q for streaming", primary.Name())
+		slog.DebugContext(ctx, "router: selected provider", "provider", primary.Name(), "stream", true)
 		ch, err := primary.Stream(ctx, req)
 		if err == nil {
 			return tagStreamProvider(ch, primary.Name()), nil
 		}
-		log.Printf("router: provider %q failed, trying fallback chain: %v", primary.Name(), err)
+		slog.WarnContext(ctx, "router: provider failed, trying fallback chain", "provider", primary.Name(), "error", err)
 		return r.streamFallback(ctx, req, primary, err)
 	}
-	log.Printf("router: no weighted providers, trying fallback chain")
+	slog.WarnContext(ctx, "router: no weighted providers, trying fallback chain")
 	return r.streamFallback(ctx, req, nil, fmt.Errorf("router: no weighted providers available"))
 }
 
@@ -131,17 +196,17 @@ func (r *Router) completeFallback(ctx context.Context, req *types.CompletionRequ
 		if skip != nil && p.Name() == skip.Name() {
 			continue
 		}
-		log.Printf("router: trying fallback provider %q", p.Name())
+		slog.DebugContext(ctx, "router: trying fallback provider", "provider", p.Name(), "stream", false)
 		resp, err := p.Complete(ctx, req)
 		if err == nil {
-			log.Printf("router: fallback provider %q succeeded", p.Name())
+			slog.InfoContext(ctx, "router: fallback provider succeeded", "provider", p.Name())
 			resp.Provider = p.Name()
 			return resp, nil
 		}
-		log.Printf("router: fallback provider %q failed: %v", p.Name(), err)
+		slog.WarnContext(ctx, "router: fallback provider failed", "provider", p.Name(), "error", err)
 		lastErr = err
 	}
-	log.Printf("router: all providers failed")
+	slog.ErrorContext(ctx, "router: all providers failed")
 	return nil, fmt.Errorf("router: all providers failed, last error: %w", lastErr)
 }
 
@@ -150,16 +215,16 @@ func (r *Router) streamFallback(ctx context.Context, req *types.CompletionReques
 		if skip != nil && p.Name() == skip.Name() {
 			continue
 		}
-		log.Printf("router: trying fallback provider %q for streaming", p.Name())
+		slog.DebugContext(ctx, "router: trying fallback provider", "provider", p.Name(), "stream", true)
 		ch, err := p.Stream(ctx, req)
 		if err == nil {
-			log.Printf("router: fallback provider %q succeeded", p.Name())
+			slog.InfoContext(ctx, "router: fallback provider succeeded", "provider", p.Name())
 			return tagStreamProvider(ch, p.Name()), nil
 		}
-		log.Printf("router: fallback provider %q failed: %v", p.Name(), err)
+		slog.WarnContext(ctx, "router: fallback provider failed", "provider", p.Name(), "error", err)
 		lastErr = err
 	}
-	log.Printf("router: all providers failed")
+	slog.ErrorContext(ctx, "router: all providers failed")
 	return nil, fmt.Errorf("router: all providers failed, last error: %w", lastErr)
 }
 
@@ -3,6 +3,7 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"testing"
@@ -26,6 +27,13 @@ func (p *testProvider) Models() []types.ModelInfo {
 	}
 	return []types.ModelInfo{{ID: "test-model", Name: "Test"}}
 }
+func (p *testProvider) Capabilities() types.Capabilities {
+	return types.Capabilities{Tools: true, MaxContext: types.MaxContextWindow(p.Models())}
+}
+
+func (p *testProvider) Embed(context.Context, *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	return nil, types.ErrEmbeddingsNotSupported
+}
 
 func (p *testProvider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	p.calls++
@@ -458,3 +466,58 @@ func TestRouterStreamConcurrentWithFallback(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestRouterCapabilitiesUnion(t *testing.T) {
+	p1 := &testProvider{name: "p1", models: []types.ModelInfo{{ID: "a", ContextWindow: 8000}}}
+	p2 := &testProvider{name: "p2", models: []types.ModelInfo{{ID: "b", ContextWindow: 200000}}}
+	router, err := NewRouter([]RouteEntry{{Provider: p1, Weight: 1}}, []Provider{p2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	caps := router.Capabilities()
+	if !caps.Tools {
+		t.Error("expected Tools capability")
+	}
+	if caps.MaxContext != 200000 {
+		t.Errorf("MaxContext = %d, want 200000", caps.MaxContext)
+	}
+}
+
+// embeddingProvider is a testProvider that supports embeddings.
+type embeddingProvider struct {
+	testProvider
+}
+
+func (p *embeddingProvider) Embed(_ context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	p.calls++
+	resp := &types.EmbeddingResponse{Model: p.name + "-embed"}
+	for range req.Input {
+		resp.Embeddings = append(resp.Embeddings, []float32{1, 0})
+	}
+	return resp, nil
+}
+
+func TestRouterEmbedSkipsUnsupportedProviders(t *testing.T) {
+	chat := &testProvider{name: "chat"}
+	embed := &embeddingProvider{testProvider{name: "embed"}}
+	r, err := NewRouter([]RouteEntry{{Provider: chat, Weight: 100}}, []Provider{embed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := r.Embed(context.Background(), &types.EmbeddingRequest{Input: []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Model != "embed-embed" || len(resp.Embeddings) != 2 {
+		t.Errorf("got model %q with %d embeddings, want embed-embed with 2", resp.Model, len(resp.Embeddings))
+	}
+
+	r, err = NewRouter([]RouteEntry{{Provider: chat, Weight: 100}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Embed(context.Background(), &types.EmbeddingRequest{Input: []string{"a"}}); !errors.Is(err, types.ErrEmbeddingsNotSupported) {
+		t.Errorf("err = %v, want ErrEmbeddingsNotSupported", err)
+	}
+}
@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"langdag.com/langdag/internal/tracing"
+	"langdag.com/langdag/types"
+)
+
+// tracedProvider records a span for each completion.
+type tracedProvider struct {
+	inner Provider
+}
+
+// WithTracing wraps a Provider so each Complete, Stream and Embed call is a
+// span carrying the model, token usage and, for streams, the time to
+// the first token.
+func WithTracing(p Provider) Provider {
+	return &tracedProvider{inner: p}
+}
+
+func (t *tracedProvider) Name() string                     { return t.inner.Name() }
+func (t *tracedProvider) Models() []types.ModelInfo        { return t.inner.Models() }
+func (t *tracedProvider) Capabilities() types.Capabilities { return t.inner.Capabilities() }
+
+func (t *tracedProvider) start(ctx context.Context, op string, req *types.CompletionRequest) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "provider."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("gen_ai.system", t.inner.Name()),
+			attribute.String("gen_ai.request.model", req.Model),
+			attribute.Int("gen_ai.request.max_tokens", req.MaxTokens),
+			attribute.Int("langdag.messages", len(req.Messages)),
+		))
+}
+
+func (t *tracedProvider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+	ctx, span := t.start(ctx, "complete", req)
+	resp, err := t.inner.Complete(ctx, req)
+	if resp != nil {
+		span.SetAttributes(responseAttributes(resp)...)
+	}
+	tracing.End(span, err)
+	return resp, err
+}
+
+func (t *tracedProvider) Embed(ctx context.Context, req *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "provider.embed",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("gen_ai.system", t.inner.Name()),
+			attribute.String("gen_ai.request.model", req.Model),
+			attribute.Int("langdag.inputs", len(req.Input)),
+		))
+	resp, err := t.inner.Embed(ctx, req)
+	if resp != nil {
+		span.SetAttributes(
+			attribute.String("gen_ai.response.model", resp.Model),
+			attribute.Int("gen_ai.usage.input_tokens", resp.TokensIn))
+	}
+	tracing.End(span, err)
+	return resp, err
+}
+
+// Stream ends its span when the stream closes.
+func (t *tracedProvider) Stream(ctx context.Context, req *types.CompletionRequest) (<-chan types.StreamEvent, error) {
+	ctx, span := t.start(ctx, "stream", req)
+	start := time.Now()
+	inner, err := t.inner.Stream(ctx, req)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+
+	out := make(chan types.StreamEvent, cap(inner))
+	go func() {
+		defer close(out)
+		var streamErr error
+		firstToken := true
+		for event := range inner {
+			switch event.Type {
+			case types.StreamEventDelta:
+				if firstToken {
+					firstToken = false
+					span.AddEvent("first_token")
+					span.SetAttributes(attribute.Int64("langdag.time_to_first_token_ms", time.Since(start).Milliseconds()))
+				}
+			case types.StreamEventDone:
+				if event.Response != nil {
+					span.SetAttributes(responseAttributes(event.Response)...)
+				}
+			case types.StreamEventError:
+				streamErr = event.Error
+			}
+			out <- event
+		}
+		tracing.End(span, streamErr)
+	}()
+	return out, nil
+}
+
+func responseAttributes(resp *types.CompletionResponse) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("gen_ai.response.model", resp.Model),
+		attribute.String("gen_ai.response.finish_reason", resp.StopReason),
+		attribute.Int("gen_ai.usage.input_tokens", resp.Usage.InputTokens),
+		attribute.Int("gen_ai.usage.output_tokens", resp.Usage.OutputTokens),
+	}
+	// Routers report the provider that served the request.
+	if resp.Provider != "" {
+		attrs = append(attrs, attribute.String("langdag.provider", resp.Provider))
+	}
+	return attrs
+}
@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"langdag.com/langdag/types"
+)
+
+// streamProvider streams fixed events.
+type streamProvider struct {
+	events []types.StreamEvent
+}
+
+func (p *streamProvider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *streamProvider) Stream(ctx context.Context, req *types.CompletionRequest) (<-chan types.StreamEvent, error) {
+	ch := make(chan types.StreamEvent, len(p.events))
+	for _, e := range p.events {
+		ch <- e
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (p *streamProvider) Name() string                     { return "stream-provider" }
+func (p *streamProvider) Models() []types.ModelInfo        { return nil }
+func (p *streamProvider) Capabilities() types.Capabilities { return types.Capabilities{} }
+
+func (p *streamProvider) Embed(context.Context, *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	return nil, types.ErrEmbeddingsNotSupported
+}
+
+// recordSpans installs a tracer provider recording spans for the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return recorder
+}
+
+func spanAttrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestWithTracing_Stream(t *testing.T) {
+	recorder := recordSpans(t)
+	p := WithTracing(&streamProvider{events: []types.StreamEvent{
+		{Type: types.StreamEventStart},
+		{Type: types.StreamEventDelta, Content: "Hel"},
+		{Type: types.StreamEventDelta, Content: "lo"},
+		{Type: types.StreamEventDone, Response: &types.CompletionResponse{
+			Model:      "model-1",
+			StopReason: "end_turn",
+			Usage:      types.Usage{InputTokens: 12, OutputTokens: 3},
+		}},
+	}})
+
+	events, err := p.Stream(context.Background(), &types.CompletionRequest{Model: "model-1", MaxTokens: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for range events {
+		n++
+	}
+	if n != 4 {
+		t.Errorf("got %d events, want 4", n)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "provider.stream" {
+		t.Errorf("span name = %q", span.Name())
+	}
+	attrs := spanAttrs(span)
+	if got := attrs["gen_ai.request.model"].AsString(); got != "model-1" {
+		t.Errorf("request model = %q", got)
+	}
+	if got := attrs["gen_ai.usage.input_tokens"].AsInt64(); got != 12 {
+		t.Errorf("input tokens = %d", got)
+	}
+	if got := attrs["gen_ai.usage.output_tokens"].AsInt64(); got != 3 {
+		t.Errorf("output tokens = %d", got)
+	}
+	if _, ok := attrs["langdag.time_to_first_token_ms"]; !ok {
+		t.Error("missing time to first token")
+	}
+	if len(span.Events()) != 1 || span.Events()[0].Name != "first_token" {
+		t.Errorf("events = %v, want one first_token", span.Events())
+	}
+}
+
+func TestWithTracing_StreamError(t *testing.T) {
+	recorder := recordSpans(t)
+	p := WithTracing(&streamProvider{events: []types.StreamEvent{
+		{Type: types.StreamEventError, Error: errors.New("overloaded")},
+	}})
+
+	events, err := p.Stream(context.Background(), &types.CompletionRequest{Model: "model-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range events {
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if status := spans[0].Status(); status.Code != codes.Error || status.Description != "overloaded" {
+		t.Errorf("status = %+v, want error", status)
+	}
+}
+
+func TestWithTracing_CompleteError(t *testing.T) {
+	recorder := recordSpans(t)
+	p := WithTracing(&streamProvider{})
+
+	if _, err := p.Complete(context.Background(), &types.CompletionRequest{Model: "model-1"}); err == nil {
+		t.Fatal("expected error")
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "provider.complete" || spans[0].Status().Code != codes.Error {
+		t.Fatalf("spans = %v", spans)
+	}
+}
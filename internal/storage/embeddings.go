@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"math"
+	"sort"
+
+	"langdag.com/langdag/types"
+)
+
+// RankEmbeddings returns the IDs of the vectors most similar to query by
+// cosine similarity, best first, at most limit of them. Vectors of another
+// dimension, and empty ones, are skipped.
+func RankEmbeddings(query []float32, vectors map[string][]float32, limit int) []types.EmbeddingMatch {
+	qNorm := norm(query)
+	if qNorm == 0 {
+		return nil
+	}
+	var matches []types.EmbeddingMatch
+	for id, v := range vectors {
+		if len(v) != len(query) {
+			continue
+		}
+		vNorm := norm(v)
+		if vNorm == 0 {
+			continue
+		}
+		var dot float64
+		for i := range v {
+			dot += float64(v[i]) * float64(query[i])
+		}
+		matches = append(matches, types.EmbeddingMatch{NodeID: id, Score: dot / (qNorm * vNorm)})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].NodeID < matches[j].NodeID
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+func norm(v []float32) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,684 @@
+// Package memory provides an in-memory implementation of the storage
+// interface. Data lives only as long as the process; it is intended for
+// ephemeral servers and tests.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"langdag.com/langdag/internal/storage"
+	"langdag.com/langdag/types"
+)
+
+var _ storage.Storage = (*MemoryStorage)(nil)
+
+// storedNode is a node plus its insertion order, which stands in for the
+// SQLite rowid when results have no explicit ordering.
+type storedNode struct {
+	node  types.Node
+	order int
+}
+
+type toolIDKey struct {
+	nodeID string
+	toolID string
+	role   string
+}
+
+// MemoryStorage implements the Storage interface in memory.
+type MemoryStorage struct {
+	mu        sync.RWMutex
+	nodes     map[string]*storedNode
+	children  map[string][]string // parent ID -> child IDs
+	aliases   map[string]string   // alias -> node ID
+	toolIDs   map[toolIDKey]struct{}
+	apiKeys   map[string]*types.APIKey // ID -> key
+	comments  []*types.Comment         // oldest first
+	nextOrder int
+
+	embeddings map[string]map[string][]float32 // model -> node ID -> vector
+}
+
+// New creates a new, empty in-memory storage instance.
+func New() *MemoryStorage {
+	return &MemoryStorage{
+		nodes:    make(map[string]*storedNode),
+		children: make(map[string][]string),
+		aliases:  make(map[string]string),
+		toolIDs:  make(map[toolIDKey]struct{}),
+		apiKeys:  make(map[string]*types.APIKey),
+
+		embeddings: make(map[string]map[string][]float32),
+	}
+}
+
+// Init is a no-op; there is no schema to migrate.
+func (s *MemoryStorage) Init(ctx context.Context) error {
+	return nil
+}
+
+// Close is a no-op.
+func (s *MemoryStorage) Close() error {
+	return nil
+}
+
+// =============================================================================
+// Node Operations
+// =============================================================================
+
+// copyNode returns a deep copy of n so callers cannot mutate stored state.
+func copyNode(n *types.Node) *types.Node {
+	out := *n
+	if n.Metadata != nil {
+		out.Metadata = append(json.RawMessage(nil), n.Metadata...)
+	}
+	if n.Tags != nil {
+		out.Tags = append([]string(nil), n.Tags...)
+	}
+	return &out
+}
+
+// sortedNodes copies the stored nodes for ids, ordered by sequence and then
+// insertion order.
+func (s *MemoryStorage) sortedNodes(ids []string) []*types.Node {
+	stored := make([]*storedNode, 0, len(ids))
+	for _, id := range ids {
+		if sn, ok := s.nodes[id]; ok {
+			stored = append(stored, sn)
+		}
+	}
+	sort.SliceStable(stored, func(i, j int) bool {
+		if stored[i].node.Sequence != stored[j].node.Sequence {
+			return stored[i].node.Sequence < stored[j].node.Sequence
+		}
+		return stored[i].order < stored[j].order
+	})
+	if len(stored) == 0 {
+		return nil
+	}
+	nodes := make([]*types.Node, len(stored))
+	for i, sn := range stored {
+		nodes[i] = copyNode(&sn.node)
+	}
+	return nodes
+}
+
+// CreateNode creates a new node.
+func (s *MemoryStorage) CreateNode(ctx context.Context, node *types.Node) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.createNode(node)
+}
+
+// createNode creates a node. The caller must hold s.mu.
+func (s *MemoryStorage) createNode(node *types.Node) error {
+	if _, exists := s.nodes[node.ID]; exists {
+		return fmt.Errorf("failed to create node: node %s already exists", node.ID)
+	}
+	s.nodes[node.ID] = &storedNode{node: *copyNode(node), order: s.nextOrder}
+	s.nextOrder++
+	if node.ParentID != "" {
+		s.children[node.ParentID] = append(s.children[node.ParentID], node.ID)
+	}
+	return nil
+}
+
+// GetNode retrieves a node by ID.
+func (s *MemoryStorage) GetNode(ctx context.Context, id string) (*types.Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sn, ok := s.nodes[id]
+	if !ok {
+		return nil, nil
+	}
+	return copyNode(&sn.node), nil
+}
+
+// GetNodeByPrefix retrieves a node by ID prefix. Matching is ASCII
+// case-insensitive, like SQLite's NOCASE.
+func (s *MemoryStorage) GetNodeByPrefix(ctx context.Context, prefix string) (*types.Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var match *storedNode
+	for id, sn := range s.nodes {
+		if !hasPrefixFold(id, prefix) {
+			continue
+		}
+		if match != nil {
+			return nil, fmt.Errorf("%w: %q matches several nodes", storage.ErrAmbiguousPrefix, prefix)
+		}
+		match = sn
+	}
+	if match == nil {
+		return nil, nil
+	}
+	return copyNode(&match.node), nil
+}
+
+// hasPrefixFold reports whether s begins with prefix, ignoring ASCII case.
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// GetNodeChildren retrieves direct children of a node.
+func (s *MemoryStorage) GetNodeChildren(ctx context.Context, parentID string) ([]*types.Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sortedNodes(s.children[parentID]), nil
+}
+
+// GetSubtree retrieves a node and all its descendants.
+func (s *MemoryStorage) GetSubtree(ctx context.Context, nodeID string) ([]*types.Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sortedNodes(s.subtreeIDs(nodeID)), nil
+}
+
+// subtreeIDs returns nodeID and the IDs of all its descendants. The caller
+// must hold s.mu.
+func (s *MemoryStorage) subtreeIDs(nodeID string) []string {
+	if _, ok := s.nodes[nodeID]; !ok {
+		return nil
+	}
+	ids := []string{nodeID}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, s.children[ids[i]]...)
+	}
+	return ids
+}
+
+// GetAncestors retrieves the path from root to the given node (inclusive), ordered root-first.
+func (s *MemoryStorage) GetAncestors(ctx context.Context, nodeID string) ([]*types.Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ids []string
+	seen := make(map[string]bool)
+	for id := nodeID; id != "" && !seen[id]; {
+		sn, ok := s.nodes[id]
+		if !ok {
+			break
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		id = sn.node.ParentID
+	}
+	return s.sortedNodes(ids), nil
+}
+
+// ListRootNodes returns all root nodes (nodes with no parent), ordered by creation time.
+func (s *MemoryStorage) ListRootNodes(ctx context.Context) ([]*types.Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var roots []*storedNode
+	for _, sn := range s.nodes {
+		if sn.node.ParentID == "" {
+			roots = append(roots, sn)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool {
+		if !roots[i].node.CreatedAt.Equal(roots[j].node.CreatedAt) {
+			return roots[i].node.CreatedAt.After(roots[j].node.CreatedAt)
+		}
+		return roots[i].order < roots[j].order
+	})
+	var nodes []*types.Node
+	for _, sn := range roots {
+		nodes = append(nodes, copyNode(&sn.node))
+	}
+	return nodes, nil
+}
+
+// SearchNodes returns up to limit nodes whose content contains every term
+// of query (case-insensitive), newest first.
+func (s *MemoryStorage) SearchNodes(ctx context.Context, query string, limit int) ([]*types.Node, error) {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found []*storedNode
+	for _, sn := range s.nodes {
+		content := strings.ToLower(sn.node.Content)
+		matched := true
+		for _, t := range terms {
+			if !strings.Contains(content, t) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			found = append(found, sn)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].node.CreatedAt.Equal(found[j].node.CreatedAt) {
+			return found[i].node.CreatedAt.After(found[j].node.CreatedAt)
+		}
+		return found[i].order > found[j].order
+	})
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+	var nodes []*types.Node
+	for _, sn := range found {
+		nodes = append(nodes, copyNode(&sn.node))
+	}
+	return nodes, nil
+}
+
+// UpdateNode updates an existing node. Only the fields the SQLite backend
+// updates are changed; structural fields (parent, root, sequence, type,
+// created_at) are left as stored.
+func (s *MemoryStorage) UpdateNode(ctx context.Context, node *types.Node) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sn, ok := s.nodes[node.ID]
+	if !ok {
+		return nil
+	}
+	updated := copyNode(node)
+	n := &sn.node
+	if n.Content != updated.Content {
+		for _, vectors := range s.embeddings {
+			delete(vectors, n.ID)
+		}
+	}
+	n.Content = updated.Content
+	n.Provider = updated.Provider
+	n.Model = updated.Model
+	n.TokensIn = updated.TokensIn
+	n.TokensOut = updated.TokensOut
+	n.TokensCacheRead = updated.TokensCacheRead
+	n.TokensCacheCreation = updated.TokensCacheCreation
+	n.TokensReasoning = updated.TokensReasoning
+	n.LatencyMs = updated.LatencyMs
+	n.Status = updated.Status
+	n.Title = updated.Title
+	n.SystemPrompt = updated.SystemPrompt
+	n.Metadata = updated.Metadata
+	n.ArchivedURI = updated.ArchivedURI
+	n.Tags = updated.Tags
+	return nil
+}
+
+// DeleteNode deletes a node and all its descendants, along with their
+// aliases and tool ID index entries.
+func (s *MemoryStorage) DeleteNode(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteNode(id)
+	return nil
+}
+
+// deleteNode deletes a node and all its descendants. The caller must hold
+// s.mu.
+func (s *MemoryStorage) deleteNode(id string) {
+	ids := s.subtreeIDs(id)
+	if len(ids) == 0 {
+		return
+	}
+	deleted := make(map[string]bool, len(ids))
+	for _, nodeID := range ids {
+		deleted[nodeID] = true
+	}
+
+	if parentID := s.nodes[id].node.ParentID; parentID != "" {
+		siblings := s.children[parentID]
+		kept := siblings[:0]
+		for _, childID := range siblings {
+			if childID != id {
+				kept = append(kept, childID)
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.children, parentID)
+		} else {
+			s.children[parentID] = kept
+		}
+	}
+	for _, nodeID := range ids {
+		delete(s.nodes, nodeID)
+		delete(s.children, nodeID)
+	}
+	for alias, nodeID := range s.aliases {
+		if deleted[nodeID] {
+			delete(s.aliases, alias)
+		}
+	}
+	for key := range s.toolIDs {
+		if deleted[key.nodeID] {
+			delete(s.toolIDs, key)
+		}
+	}
+	kept := s.comments[:0]
+	for _, c := range s.comments {
+		if !deleted[c.NodeID] {
+			kept = append(kept, c)
+		}
+	}
+	clear(s.comments[len(kept):])
+	s.comments = kept
+	for _, vectors := range s.embeddings {
+		for nodeID := range vectors {
+			if deleted[nodeID] {
+				delete(vectors, nodeID)
+			}
+		}
+	}
+}
+
+// =============================================================================
+// Alias Operations
+// =============================================================================
+
+// CreateAlias creates an alias for a node.
+func (s *MemoryStorage) CreateAlias(ctx context.Context, nodeID, alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.aliases[alias]; exists {
+		return fmt.Errorf("failed to create alias: alias %s already exists", alias)
+	}
+	s.aliases[alias] = nodeID
+	return nil
+}
+
+// DeleteAlias removes an alias.
+func (s *MemoryStorage) DeleteAlias(ctx context.Context, alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.aliases, alias)
+	return nil
+}
+
+// GetNodeByAlias retrieves a node by its alias.
+func (s *MemoryStorage) GetNodeByAlias(ctx context.Context, alias string) (*types.Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nodeID, ok := s.aliases[alias]
+	if !ok {
+		return nil, nil
+	}
+	sn, ok := s.nodes[nodeID]
+	if !ok {
+		return nil, nil
+	}
+	return copyNode(&sn.node), nil
+}
+
+// ListAliases returns all aliases for a node.
+func (s *MemoryStorage) ListAliases(ctx context.Context, nodeID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var aliases []string
+	for alias, id := range s.aliases {
+		if id == nodeID {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases, nil
+}
+
+// =============================================================================
+// Tool ID Index Operations
+// =============================================================================
+
+// IndexToolIDs saves tool_use or tool_result IDs for a node.
+// role must be "use" or "result".
+func (s *MemoryStorage) IndexToolIDs(ctx context.Context, nodeID string, toolIDs []string, role string) error {
+	if len(toolIDs) == 0 {
+		return nil
+	}
+	if err := checkToolIDRole(role); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.indexToolIDs(nodeID, toolIDs, role)
+	return nil
+}
+
+func checkToolIDRole(role string) error {
+	if role != "use" && role != "result" {
+		return fmt.Errorf("failed to index tool IDs: invalid role %q", role)
+	}
+	return nil
+}
+
+// indexToolIDs records toolIDs. The caller must hold s.mu.
+func (s *MemoryStorage) indexToolIDs(nodeID string, toolIDs []string, role string) {
+	for _, id := range toolIDs {
+		s.toolIDs[toolIDKey{nodeID: nodeID, toolID: id, role: role}] = struct{}{}
+	}
+}
+
+// GetOrphanedToolUses returns tool_use IDs among the given ancestor node IDs
+// that have no matching tool_result in the same ancestor path.
+// Returns map[node_id][]orphaned_tool_use_id.
+func (s *MemoryStorage) GetOrphanedToolUses(ctx context.Context, ancestorIDs []string) (map[string][]string, error) {
+	if len(ancestorIDs) == 0 {
+		return nil, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	inPath := make(map[string]bool, len(ancestorIDs))
+	for _, id := range ancestorIDs {
+		inPath[id] = true
+	}
+	results := make(map[string]bool)
+	for key := range s.toolIDs {
+		if key.role == "result" && inPath[key.nodeID] {
+			results[key.toolID] = true
+		}
+	}
+
+	result := make(map[string][]string)
+	for key := range s.toolIDs {
+		if key.role == "use" && inPath[key.nodeID] && !results[key.toolID] {
+			result[key.nodeID] = append(result[key.nodeID], key.toolID)
+		}
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	for _, ids := range result {
+		sort.Strings(ids)
+	}
+	return result, nil
+}
+
+// =============================================================================
+// API Key Operations
+// =============================================================================
+
+func copyAPIKey(k *types.APIKey) *types.APIKey {
+	c := *k
+	if k.LastUsedAt != nil {
+		t := *k.LastUsedAt
+		c.LastUsedAt = &t
+	}
+	if k.RevokedAt != nil {
+		t := *k.RevokedAt
+		c.RevokedAt = &t
+	}
+	return &c
+}
+
+// CreateAPIKey stores a new API key.
+func (s *MemoryStorage) CreateAPIKey(ctx context.Context, key *types.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range s.apiKeys {
+		if k.ID == key.ID || k.Hash == key.Hash {
+			return fmt.Errorf("failed to create API key: key %s already exists", key.ID)
+		}
+	}
+	s.apiKeys[key.ID] = copyAPIKey(key)
+	return nil
+}
+
+// GetAPIKeyByHash retrieves the API key whose secret has the given hash.
+func (s *MemoryStorage) GetAPIKeyByHash(ctx context.Context, hash string) (*types.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, k := range s.apiKeys {
+		if k.Hash == hash {
+			return copyAPIKey(k), nil
+		}
+	}
+	return nil, nil
+}
+
+// ListAPIKeys returns all API keys, oldest first.
+func (s *MemoryStorage) ListAPIKeys(ctx context.Context) ([]*types.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []*types.APIKey
+	for _, k := range s.apiKeys {
+		keys = append(keys, copyAPIKey(k))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+// RevokeAPIKey marks an API key as revoked. Revoking a revoked key keeps
+// its original revocation time.
+func (s *MemoryStorage) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.apiKeys[id]
+	if !ok {
+		return fmt.Errorf("API key not found: %s", id)
+	}
+	if k.RevokedAt == nil {
+		k.RevokedAt = &at
+	}
+	return nil
+}
+
+// TouchAPIKey records that an API key was used at the given time.
+func (s *MemoryStorage) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if k, ok := s.apiKeys[id]; ok {
+		k.LastUsedAt = &at
+	}
+	return nil
+}
+
+// =============================================================================
+// Comment Operations
+// =============================================================================
+
+// CreateComment stores a new comment.
+func (s *MemoryStorage) CreateComment(ctx context.Context, comment *types.Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := *comment
+	s.comments = append(s.comments, &c)
+	return nil
+}
+
+// ListComments returns the comments on the nodes of a DAG, oldest first.
+func (s *MemoryStorage) ListComments(ctx context.Context, rootID string) ([]*types.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var comments []*types.Comment
+	for _, c := range s.comments {
+		if c.RootID == rootID {
+			copied := *c
+			comments = append(comments, &copied)
+		}
+	}
+	return comments, nil
+}
+
+// =============================================================================
+// Embedding Operations
+// =============================================================================
+
+// SaveEmbedding stores the embedding of a node by model.
+func (s *MemoryStorage) SaveEmbedding(ctx context.Context, nodeID, model string, vector []float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.nodes[nodeID]; !ok {
+		return nil
+	}
+	vectors := s.embeddings[model]
+	if vectors == nil {
+		vectors = make(map[string][]float32)
+		s.embeddings[model] = vectors
+	}
+	vectors[nodeID] = append([]float32{}, vector...)
+	return nil
+}
+
+// SearchEmbeddings returns the nodes embedded by model most similar to
+// vector.
+func (s *MemoryStorage) SearchEmbeddings(ctx context.Context, model string, vector []float32, limit int) ([]types.EmbeddingMatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return storage.RankEmbeddings(vector, s.embeddings[model], limit), nil
+}
+
+// ListUnembeddedNodes returns the oldest nodes with no embedding by model.
+func (s *MemoryStorage) ListUnembeddedNodes(ctx context.Context, model string, limit int) ([]*types.Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found []*storedNode
+	for id, sn := range s.nodes {
+		if _, ok := s.embeddings[model][id]; !ok && sn.node.Status != types.NodeStatusSpeculative {
+			found = append(found, sn)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].node.CreatedAt.Equal(found[j].node.CreatedAt) {
+			return found[i].node.CreatedAt.Before(found[j].node.CreatedAt)
+		}
+		return found[i].order < found[j].order
+	})
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+	nodes := make([]*types.Node, len(found))
+	for i, sn := range found {
+		nodes[i] = copyNode(&sn.node)
+	}
+	return nodes, nil
+}
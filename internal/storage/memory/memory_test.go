@@ -0,0 +1,371 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/internal/storage"
+	"langdag.com/langdag/types"
+)
+
+func setupTestStore(t *testing.T) *MemoryStorage {
+	t.Helper()
+	store := New()
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func createNodes(t *testing.T, store *MemoryStorage, nodes ...*types.Node) {
+	t.Helper()
+	for _, n := range nodes {
+		if err := store.CreateNode(context.Background(), n); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCreateAndGetNode(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	node := &types.Node{
+		ID:        "node-1",
+		NodeType:  types.NodeTypeUser,
+		Content:   "Hello, world!",
+		Metadata:  json.RawMessage(`{"k":"v"}`),
+		CreatedAt: time.Now(),
+	}
+	createNodes(t, store, node)
+
+	got, err := store.GetNode(ctx, "node-1")
+	if err != nil {
+		t.Fatalf("GetNode: %v", err)
+	}
+	if got == nil || got.Content != "Hello, world!" {
+		t.Fatalf("GetNode = %+v", got)
+	}
+
+	// Mutating the returned node must not change stored state.
+	got.Content = "changed"
+	got.Metadata[2] = 'X'
+	again, _ := store.GetNode(ctx, "node-1")
+	if again.Content != "Hello, world!" || string(again.Metadata) != `{"k":"v"}` {
+		t.Errorf("stored node was mutated through returned copy: %+v", again)
+	}
+
+	if err := store.CreateNode(ctx, node); err == nil {
+		t.Error("expected error creating duplicate node")
+	}
+}
+
+func TestGetNodeNotFound(t *testing.T) {
+	store := setupTestStore(t)
+	got, err := store.GetNode(context.Background(), "missing")
+	if err != nil {
+		t.Fatalf("GetNode: %v", err)
+	}
+	if got != nil {
+		t.Errorf("expected nil, got %+v", got)
+	}
+}
+
+func TestGetNodeByPrefix(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	createNodes(t, store,
+		&types.Node{ID: "abcdef-1234-5678", NodeType: types.NodeTypeUser, CreatedAt: time.Now()},
+		&types.Node{ID: "abcxyz-0000", NodeType: types.NodeTypeUser, CreatedAt: time.Now()},
+	)
+
+	got, err := store.GetNodeByPrefix(ctx, "abcdef")
+	if err != nil {
+		t.Fatalf("GetNodeByPrefix: %v", err)
+	}
+	if got == nil || got.ID != "abcdef-1234-5678" {
+		t.Fatalf("GetNodeByPrefix = %+v", got)
+	}
+
+	// Like SQLite LIKE, matching ignores ASCII case.
+	got, _ = store.GetNodeByPrefix(ctx, "ABCDEF")
+	if got == nil || got.ID != "abcdef-1234-5678" {
+		t.Errorf("case-insensitive prefix: got %+v", got)
+	}
+
+	// Ambiguous prefixes are an error rather than an arbitrary match.
+	got, err = store.GetNodeByPrefix(ctx, "abc")
+	if !errors.Is(err, storage.ErrAmbiguousPrefix) || got != nil {
+		t.Errorf("ambiguous prefix: got %+v, %v", got, err)
+	}
+
+	got, _ = store.GetNodeByPrefix(ctx, "zzz")
+	if got != nil {
+		t.Errorf("expected nil for unmatched prefix, got %+v", got)
+	}
+}
+
+func TestListRootNodes(t *testing.T) {
+	store := setupTestStore(t)
+	now := time.Now()
+	createNodes(t, store,
+		&types.Node{ID: "old", NodeType: types.NodeTypeUser, CreatedAt: now.Add(-time.Hour)},
+		&types.Node{ID: "new", NodeType: types.NodeTypeUser, CreatedAt: now},
+		&types.Node{ID: "child", ParentID: "new", Sequence: 1, NodeType: types.NodeTypeAssistant, CreatedAt: now},
+	)
+
+	roots, err := store.ListRootNodes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 2 || roots[0].ID != "new" || roots[1].ID != "old" {
+		t.Fatalf("roots = %v, want [new old]", nodeIDs(roots))
+	}
+}
+
+func TestSubtreeAncestorsAndChildren(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	createNodes(t, store,
+		&types.Node{ID: "root", Sequence: 0, NodeType: types.NodeTypeUser},
+		&types.Node{ID: "a1", ParentID: "root", RootID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant},
+		&types.Node{ID: "u2", ParentID: "a1", RootID: "root", Sequence: 2, NodeType: types.NodeTypeUser},
+		&types.Node{ID: "a1b", ParentID: "root", RootID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant},
+	)
+
+	children, _ := store.GetNodeChildren(ctx, "root")
+	if got := nodeIDs(children); len(got) != 2 || got[0] != "a1" || got[1] != "a1b" {
+		t.Errorf("children = %v, want [a1 a1b]", got)
+	}
+
+	subtree, _ := store.GetSubtree(ctx, "root")
+	if got := nodeIDs(subtree); len(got) != 4 || got[0] != "root" || got[3] != "u2" {
+		t.Errorf("subtree = %v", got)
+	}
+
+	ancestors, _ := store.GetAncestors(ctx, "u2")
+	if got := nodeIDs(ancestors); len(got) != 3 || got[0] != "root" || got[1] != "a1" || got[2] != "u2" {
+		t.Errorf("ancestors = %v, want [root a1 u2]", got)
+	}
+
+	if missing, _ := store.GetSubtree(ctx, "missing"); missing != nil {
+		t.Errorf("expected nil subtree for missing node, got %v", nodeIDs(missing))
+	}
+}
+
+func TestUpdateNode(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	createNodes(t, store, &types.Node{ID: "n", Sequence: 0, NodeType: types.NodeTypeUser, Content: "before"})
+
+	if err := store.UpdateNode(ctx, &types.Node{ID: "n", Sequence: 99, Content: "after", Title: "T"}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := store.GetNode(ctx, "n")
+	if got.Content != "after" || got.Title != "T" {
+		t.Errorf("update not applied: %+v", got)
+	}
+	if got.Sequence != 0 || got.NodeType != types.NodeTypeUser {
+		t.Errorf("structural fields should be unchanged: %+v", got)
+	}
+}
+
+func TestDeleteNodeCascades(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	createNodes(t, store,
+		&types.Node{ID: "root", Sequence: 0, NodeType: types.NodeTypeUser},
+		&types.Node{ID: "child1", ParentID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant},
+		&types.Node{ID: "grandchild", ParentID: "child1", Sequence: 2, NodeType: types.NodeTypeUser},
+		&types.Node{ID: "child2", ParentID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant},
+	)
+	if err := store.CreateAlias(ctx, "grandchild", "gc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.IndexToolIDs(ctx, "child1", []string{"tool-1"}, "use"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.DeleteNode(ctx, "child1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"child1", "grandchild"} {
+		if got, _ := store.GetNode(ctx, id); got != nil {
+			t.Errorf("%s still exists", id)
+		}
+	}
+	for _, id := range []string{"root", "child2"} {
+		if got, _ := store.GetNode(ctx, id); got == nil {
+			t.Errorf("%s was deleted", id)
+		}
+	}
+	if got, _ := store.GetNodeByAlias(ctx, "gc"); got != nil {
+		t.Error("alias still resolves after node deletion")
+	}
+	if children, _ := store.GetNodeChildren(ctx, "root"); len(children) != 1 || children[0].ID != "child2" {
+		t.Errorf("children after delete = %v, want [child2]", nodeIDs(children))
+	}
+	if orphans, _ := store.GetOrphanedToolUses(ctx, []string{"child1"}); orphans != nil {
+		t.Errorf("tool IDs should be removed with the node, got %v", orphans)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	createNodes(t, store, &types.Node{ID: "n", NodeType: types.NodeTypeUser})
+
+	for _, alias := range []string{"beta", "alpha"} {
+		if err := store.CreateAlias(ctx, "n", alias); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.CreateAlias(ctx, "n", "alpha"); err == nil {
+		t.Error("expected error creating duplicate alias")
+	}
+
+	aliases, _ := store.ListAliases(ctx, "n")
+	if len(aliases) != 2 || aliases[0] != "alpha" || aliases[1] != "beta" {
+		t.Errorf("aliases = %v, want [alpha beta]", aliases)
+	}
+
+	if got, _ := store.GetNodeByAlias(ctx, "alpha"); got == nil || got.ID != "n" {
+		t.Errorf("GetNodeByAlias = %+v", got)
+	}
+	if err := store.DeleteAlias(ctx, "alpha"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.GetNodeByAlias(ctx, "alpha"); got != nil {
+		t.Error("deleted alias still resolves")
+	}
+}
+
+func TestIndexToolIDs_AndGetOrphaned(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	if err := store.IndexToolIDs(ctx, "a1", []string{"t1", "t2"}, "use"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.IndexToolIDs(ctx, "a1", []string{"t1"}, "use"); err != nil {
+		t.Fatalf("duplicate index should be idempotent: %v", err)
+	}
+	if err := store.IndexToolIDs(ctx, "u2", []string{"t1"}, "result"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.IndexToolIDs(ctx, "x", []string{"t9"}, "bogus"); err == nil {
+		t.Error("expected error for invalid role")
+	}
+
+	orphans, err := store.GetOrphanedToolUses(ctx, []string{"root", "a1", "u2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 1 || len(orphans["a1"]) != 1 || orphans["a1"][0] != "t2" {
+		t.Errorf("orphans = %v, want map[a1:[t2]]", orphans)
+	}
+
+	// Results outside the ancestor path do not satisfy a tool_use.
+	orphans, _ = store.GetOrphanedToolUses(ctx, []string{"a1"})
+	if len(orphans["a1"]) != 2 {
+		t.Errorf("orphans without result node = %v, want both tool uses", orphans)
+	}
+
+	if orphans, _ := store.GetOrphanedToolUses(ctx, nil); orphans != nil {
+		t.Errorf("expected nil for empty ancestors, got %v", orphans)
+	}
+}
+
+func nodeIDs(nodes []*types.Node) []string {
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.ID
+	}
+	return ids
+}
+
+func TestSearchNodes(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	now := time.Now()
+	createNodes(t, store,
+		&types.Node{ID: "old", Sequence: 0, NodeType: types.NodeTypeUser, Content: "Kubernetes clusters", CreatedAt: now.Add(-time.Hour)},
+		&types.Node{ID: "new", Sequence: 0, NodeType: types.NodeTypeUser, Content: "more kubernetes", CreatedAt: now},
+		&types.Node{ID: "vm", Sequence: 0, NodeType: types.NodeTypeUser, Content: "virtual machines", CreatedAt: now},
+	)
+
+	found, _ := store.SearchNodes(ctx, "KUBERNETES", 10)
+	if len(found) != 2 || found[0].ID != "new" || found[1].ID != "old" {
+		t.Errorf("expected [new old], got %v", found)
+	}
+	if found, _ := store.SearchNodes(ctx, "kubernetes cluster", 10); len(found) != 1 || found[0].ID != "old" {
+		t.Errorf("expected terms to be ANDed, got %v", found)
+	}
+	if found, _ := store.SearchNodes(ctx, "kubernetes", 1); len(found) != 1 {
+		t.Errorf("expected limit to apply, got %d", len(found))
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	root := &types.Node{ID: "root", NodeType: types.NodeTypeUser, Content: "root", CreatedAt: time.Now()}
+	if err := store.CreateNode(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	reply := &types.Node{ID: "reply", ParentID: "root", RootID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant, Content: "reply", CreatedAt: time.Now()}
+
+	// A failed transaction saves nothing.
+	err := store.WithTx(ctx, func(tx storage.NodeWriter) error {
+		if err := tx.CreateNode(ctx, reply); err != nil {
+			return err
+		}
+		if err := tx.IndexToolIDs(ctx, "reply", []string{"call_1"}, "use"); err != nil {
+			return err
+		}
+		return fmt.Errorf("injected failure")
+	})
+	if err == nil || !strings.Contains(err.Error(), "injected failure") {
+		t.Fatalf("WithTx error = %v, want the injected failure", err)
+	}
+	if got, _ := store.GetNode(ctx, "reply"); got != nil {
+		t.Error("node of a failed transaction was saved")
+	}
+	if orphans, _ := store.GetOrphanedToolUses(ctx, []string{"root", "reply"}); len(orphans) != 0 {
+		t.Errorf("tool IDs of a failed transaction were indexed: %v", orphans)
+	}
+
+	// A successful one saves every write.
+	err = store.WithTx(ctx, func(tx storage.NodeWriter) error {
+		if err := tx.CreateNode(ctx, reply); err != nil {
+			return err
+		}
+		return tx.IndexToolIDs(ctx, "reply", []string{"call_1"}, "use")
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if got, _ := store.GetNode(ctx, "reply"); got == nil || got.Content != "reply" {
+		t.Errorf("GetNode(reply) = %+v", got)
+	}
+	if orphans, _ := store.GetOrphanedToolUses(ctx, []string{"root", "reply"}); len(orphans["reply"]) != 1 {
+		t.Errorf("orphans = %v, want call_1 on reply", orphans)
+	}
+
+	err = store.WithTx(ctx, func(tx storage.NodeWriter) error {
+		return tx.DeleteNode(ctx, "root")
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if got, _ := store.GetNode(ctx, "reply"); got != nil {
+		t.Error("DeleteNode in a transaction kept the subtree")
+	}
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"langdag.com/langdag/internal/storage"
+	"langdag.com/langdag/types"
+)
+
+// WithTx runs fn with a NodeWriter that buffers its writes, and applies
+// them together, under one lock, if fn returns nil.
+func (s *MemoryStorage) WithTx(ctx context.Context, fn func(tx storage.NodeWriter) error) error {
+	tx := &nodeTx{s: s, created: make(map[string]bool), aliases: make(map[string]string)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Check again for nodes created since they were buffered, so that the
+	// writes apply entirely or not at all.
+	for id := range tx.created {
+		if _, exists := s.nodes[id]; exists {
+			return fmt.Errorf("failed to create node: node %s already exists", id)
+		}
+	}
+	for alias, nodeID := range tx.aliases {
+		if s.aliasTaken(alias, nodeID) {
+			return fmt.Errorf("failed to create alias: alias %s already exists", alias)
+		}
+	}
+	for _, write := range tx.writes {
+		write()
+	}
+	return nil
+}
+
+// nodeTx is the NodeWriter of a WithTx transaction.
+type nodeTx struct {
+	s       *MemoryStorage
+	created map[string]bool
+	aliases map[string]string // alias to node ID
+	writes  []func()          // run with s.mu held
+}
+
+func (t *nodeTx) CreateNode(ctx context.Context, node *types.Node) error {
+	t.s.mu.RLock()
+	_, exists := t.s.nodes[node.ID]
+	t.s.mu.RUnlock()
+	if exists || t.created[node.ID] {
+		return fmt.Errorf("failed to create node: node %s already exists", node.ID)
+	}
+	t.created[node.ID] = true
+	node = copyNode(node)
+	t.writes = append(t.writes, func() { _ = t.s.createNode(node) })
+	return nil
+}
+
+func (t *nodeTx) IndexToolIDs(ctx context.Context, nodeID string, toolIDs []string, role string) error {
+	if len(toolIDs) == 0 {
+		return nil
+	}
+	if err := checkToolIDRole(role); err != nil {
+		return err
+	}
+	toolIDs = append([]string(nil), toolIDs...)
+	t.writes = append(t.writes, func() { t.s.indexToolIDs(nodeID, toolIDs, role) })
+	return nil
+}
+
+func (t *nodeTx) DeleteNode(ctx context.Context, id string) error {
+	t.writes = append(t.writes, func() { t.s.deleteNode(id) })
+	return nil
+}
+
+// CreateAlias replaces an alias left behind by a deleted node; an alias
+// of another stored node is an error.
+func (t *nodeTx) CreateAlias(ctx context.Context, nodeID, alias string) error {
+	t.s.mu.RLock()
+	taken := t.s.aliasTaken(alias, nodeID)
+	t.s.mu.RUnlock()
+	if _, buffered := t.aliases[alias]; taken || buffered {
+		return fmt.Errorf("failed to create alias: alias %s already exists", alias)
+	}
+	t.aliases[alias] = nodeID
+	t.writes = append(t.writes, func() { t.s.aliases[alias] = nodeID })
+	return nil
+}
+
+// aliasTaken reports whether alias names a stored node other than nodeID.
+// s.mu must be held.
+func (s *MemoryStorage) aliasTaken(alias, nodeID string) bool {
+	current, ok := s.aliases[alias]
+	if !ok || current == nodeID {
+		return false
+	}
+	_, exists := s.nodes[current]
+	return exists
+}
@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"langdag.com/langdag/types"
+)
+
+const apiKeyColumns = `id, name, hash, scope, created_at, last_used_at, revoked_at`
+
+func scanAPIKey(scanner interface{ Scan(...any) error }) (*types.APIKey, error) {
+	var key types.APIKey
+	var name sql.NullString
+	var lastUsed, revoked sql.NullTime
+	if err := scanner.Scan(&key.ID, &name, &key.Hash, &key.Scope, &key.CreatedAt, &lastUsed, &revoked); err != nil {
+		return nil, err
+	}
+	key.Name = name.String
+	if lastUsed.Valid {
+		key.LastUsedAt = &lastUsed.Time
+	}
+	if revoked.Valid {
+		key.RevokedAt = &revoked.Time
+	}
+	return &key, nil
+}
+
+// CreateAPIKey stores a new API key.
+func (s *SQLiteStorage) CreateAPIKey(ctx context.Context, key *types.APIKey) error {
+	err := s.exec(ctx, `
+		INSERT INTO api_keys (id, name, hash, scope, created_at) VALUES (?, ?, ?, ?, ?)
+	`, key.ID, nullString(key.Name), key.Hash, key.Scope, key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKeyByHash retrieves the API key whose secret has the given hash.
+func (s *SQLiteStorage) GetAPIKeyByHash(ctx context.Context, hash string) (*types.APIKey, error) {
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, `
+		SELECT `+apiKeyColumns+` FROM api_keys WHERE hash = ?
+	`, hash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys returns all API keys, oldest first.
+func (s *SQLiteStorage) ListAPIKeys(ctx context.Context) ([]*types.APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*types.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey marks an API key as revoked. Revoking a revoked key keeps
+// its original revocation time.
+func (s *SQLiteStorage) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	return s.writer.submit(ctx, func(ctx context.Context) error {
+		res, err := s.db.ExecContext(ctx, `
+			UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?
+		`, at, id)
+		if err != nil {
+			return fmt.Errorf("failed to revoke API key: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("API key not found: %s", id)
+		}
+		return nil
+	})
+}
+
+// TouchAPIKey records that an API key was used at the given time.
+func (s *SQLiteStorage) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	err := s.exec(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, at, id)
+	if err != nil {
+		return fmt.Errorf("failed to update API key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// blobHash returns the content_blobs key for content, or "" when content is
+// empty.
+func blobHash(content string) string {
+	if content == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// putBlob stores content under hash unless it is already stored. It must
+// run on the writer.
+func putBlob(ctx context.Context, db execer, hash, content string) error {
+	if hash == "" {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO content_blobs (hash, content) VALUES (?, ?)
+	`, hash, content)
+	return err
+}
+
+// compactSystemPrompts moves system prompts stored inline on nodes (rows
+// written before content_blobs existed) into content_blobs.
+func (s *SQLiteStorage) compactSystemPrompts(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT system_prompt FROM nodes WHERE system_prompt IS NOT NULL
+	`)
+	if err != nil {
+		return err
+	}
+	var prompts []string
+	for rows.Next() {
+		var prompt string
+		if err := rows.Scan(&prompt); err != nil {
+			rows.Close()
+			return err
+		}
+		prompts = append(prompts, prompt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return s.writer.submit(ctx, func(ctx context.Context) error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		for _, prompt := range prompts {
+			hash := blobHash(prompt)
+			if hash != "" {
+				if _, err := tx.ExecContext(ctx, `
+					INSERT OR IGNORE INTO content_blobs (hash, content) VALUES (?, ?)
+				`, hash, prompt); err != nil {
+					return fmt.Errorf("failed to store system prompt: %w", err)
+				}
+			}
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE nodes SET system_prompt = NULL, system_prompt_hash = ? WHERE system_prompt = ?
+			`, nullString(hash), prompt); err != nil {
+				return fmt.Errorf("failed to update nodes: %w", err)
+			}
+		}
+		return tx.Commit()
+	})
+}
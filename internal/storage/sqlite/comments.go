@@ -0,0 +1,44 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"langdag.com/langdag/types"
+)
+
+// CreateComment stores a new comment.
+func (s *SQLiteStorage) CreateComment(ctx context.Context, c *types.Comment) error {
+	err := s.exec(ctx, `
+		INSERT INTO comments (id, node_id, root_id, reply_to, author, body, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, c.ID, c.NodeID, c.RootID, nullString(c.ReplyTo), c.Author, c.Body, c.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create comment: %w", err)
+	}
+	return nil
+}
+
+// ListComments returns the comments on the nodes of a DAG, oldest first.
+func (s *SQLiteStorage) ListComments(ctx context.Context, rootID string) ([]*types.Comment, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, node_id, root_id, reply_to, author, body, created_at
+		FROM comments WHERE root_id = ? ORDER BY created_at, id
+	`, rootID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	defer rows.Close()
+
+	var comments []*types.Comment
+	for rows.Next() {
+		var c types.Comment
+		var replyTo sql.NullString
+		if err := rows.Scan(&c.ID, &c.NodeID, &c.RootID, &replyTo, &c.Author, &c.Body, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		c.ReplyTo = replyTo.String
+		comments = append(comments, &c)
+	}
+	return comments, rows.Err()
+}
@@ -0,0 +1,87 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"langdag.com/langdag/internal/storage"
+	"langdag.com/langdag/types"
+)
+
+// SaveEmbedding stores the embedding of a node by model, replacing any
+// previous one.
+func (s *SQLiteStorage) SaveEmbedding(ctx context.Context, nodeID, model string, vector []float32) error {
+	err := s.exec(ctx, `
+		INSERT OR REPLACE INTO node_embeddings (node_id, model, vector)
+		SELECT id, ?, ? FROM nodes WHERE id = ?
+	`, model, encodeVector(vector), nodeID)
+	if err != nil {
+		return fmt.Errorf("failed to save embedding: %w", err)
+	}
+	return nil
+}
+
+// SearchEmbeddings compares vector with every embedding by model and
+// returns the most similar nodes.
+func (s *SQLiteStorage) SearchEmbeddings(ctx context.Context, model string, vector []float32, limit int) ([]types.EmbeddingMatch, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT node_id, vector FROM node_embeddings WHERE model = ?
+	`, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	vectors := make(map[string][]float32)
+	for rows.Next() {
+		var nodeID string
+		var data []byte
+		if err := rows.Scan(&nodeID, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan embedding: %w", err)
+		}
+		vectors[nodeID] = decodeVector(data)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return storage.RankEmbeddings(vector, vectors, limit), nil
+}
+
+// ListUnembeddedNodes returns the oldest nodes with no embedding by model,
+// except speculative ones.
+func (s *SQLiteStorage) ListUnembeddedNodes(ctx context.Context, model string, limit int) ([]*types.Node, error) {
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+nodeColumnsQ("n")+` FROM nodes n
+		WHERE NOT EXISTS (SELECT 1 FROM node_embeddings e WHERE e.node_id = n.id AND e.model = ?)
+		AND n.status IS NOT ?
+		ORDER BY n.created_at ASC, n.rowid ASC
+		LIMIT ?
+	`, model, types.NodeStatusSpeculative, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unembedded nodes: %w", err)
+	}
+	defer rows.Close()
+	return s.scanNodes(rows)
+}
+
+// encodeVector stores a vector as little-endian float32 values.
+func encodeVector(v []float32) []byte {
+	data := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(x))
+	}
+	return data
+}
+
+func decodeVector(data []byte) []float32 {
+	v := make([]float32, len(data)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return v
+}
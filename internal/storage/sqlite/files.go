@@ -0,0 +1,148 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultFileThreshold is the content size above which SetFileThreshold(0)
+// stores node content in files.
+const DefaultFileThreshold = 256 << 10
+
+// pruneGrace keeps recent files from PruneFiles: a file is written before
+// the node referencing it is inserted.
+const pruneGrace = time.Hour
+
+// FilesDir returns the directory next to the database at path where large
+// node content is stored.
+func FilesDir(path string) string {
+	return path + "-files"
+}
+
+// SetFileThreshold stores the content of nodes larger than n bytes in
+// files under FilesDir, named by the SHA-256 of the content, instead of
+// the nodes table. 0 uses DefaultFileThreshold and a negative n keeps all
+// content in the database. Content already stored in files is read either
+// way.
+func (s *SQLiteStorage) SetFileThreshold(n int) {
+	if n == 0 {
+		n = DefaultFileThreshold
+	}
+	s.fileThreshold = n
+}
+
+// storeContent returns the content column and content_file hash for
+// content, writing it to a file when it is over the threshold.
+func (s *SQLiteStorage) storeContent(content string) (string, string, error) {
+	if s.fileThreshold <= 0 || len(content) <= s.fileThreshold {
+		return content, "", nil
+	}
+	hash := blobHash(content)
+	path := s.contentPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		// Refresh the time so PruneFiles spares it until the node is saved.
+		now := time.Now()
+		return "", hash, os.Chtimes(path, now, now)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create content directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to write content file: %w", err)
+	}
+	_, err = tmp.WriteString(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", "", fmt.Errorf("failed to write content file: %w", err)
+	}
+	return "", hash, nil
+}
+
+// loadContent returns the content stored in the file named hash.
+func (s *SQLiteStorage) loadContent(hash string) (string, error) {
+	data, err := os.ReadFile(s.contentPath(hash))
+	if err != nil {
+		return "", fmt.Errorf("failed to read content file: %w", err)
+	}
+	return string(data), nil
+}
+
+// contentPath returns the path of the file named hash, in a subdirectory
+// named by its first two characters.
+func (s *SQLiteStorage) contentPath(hash string) string {
+	return filepath.Join(FilesDir(s.path), hash[:2], hash)
+}
+
+// PruneResult reports the content files removed by PruneFiles.
+type PruneResult struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// PruneFiles removes the content files no node references any more, left
+// behind by deleted and archived nodes. Files written in the last hour are
+// kept, as their node may not be saved yet. With dryRun, it only reports
+// what would be removed.
+func (s *SQLiteStorage) PruneFiles(ctx context.Context, dryRun bool) (PruneResult, error) {
+	var result PruneResult
+	referenced := make(map[string]bool)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT content_file FROM nodes WHERE content_file IS NOT NULL
+	`)
+	if err != nil {
+		return result, fmt.Errorf("failed to list content files: %w", err)
+	}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			rows.Close()
+			return result, err
+		}
+		referenced[hash] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	cutoff := time.Now().Add(-pruneGrace)
+	err = filepath.WalkDir(FilesDir(s.path), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || referenced[d.Name()] {
+			return ctx.Err()
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return err
+		}
+		if !dryRun {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+		result.Files++
+		result.Bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to prune content files: %w", err)
+	}
+	return result, nil
+}
@@ -133,4 +133,148 @@ var migrations = []string{
 	CREATE INDEX IF NOT EXISTS idx_nodes_output_group ON nodes(output_group_id) WHERE output_group_id IS NOT NULL;
 	UPDATE schema_version SET version = 9;
 	`,
+
+	// Migration 10: Full-text index over node content for SearchNodes.
+	// The index keeps its own copy of the content, keyed by node ID, so it
+	// does not depend on rowids staying stable across VACUUM.
+	`
+	CREATE VIRTUAL TABLE IF NOT EXISTS nodes_fts USING fts5(node_id UNINDEXED, content);
+
+	CREATE TRIGGER IF NOT EXISTS nodes_fts_insert AFTER INSERT ON nodes BEGIN
+		INSERT INTO nodes_fts (node_id, content) VALUES (new.id, new.content);
+	END;
+	CREATE TRIGGER IF NOT EXISTS nodes_fts_delete AFTER DELETE ON nodes BEGIN
+		DELETE FROM nodes_fts WHERE node_id = old.id;
+	END;
+	CREATE TRIGGER IF NOT EXISTS nodes_fts_update AFTER UPDATE OF content ON nodes BEGIN
+		UPDATE nodes_fts SET content = new.content WHERE node_id = new.id;
+	END;
+
+	-- Backfill existing nodes.
+	INSERT INTO nodes_fts (node_id, content) SELECT id, content FROM nodes;
+
+	UPDATE schema_version SET version = 10;
+	`,
+
+	// Migration 11: Add archived_uri column for DAGs moved to cold storage
+	`
+	ALTER TABLE nodes ADD COLUMN archived_uri TEXT;
+	UPDATE schema_version SET version = 11;
+	`,
+
+	// Migration 12: Content-addressed storage for repeated values. System
+	// prompts are stored once in content_blobs and referenced from nodes by
+	// SHA-256; existing prompts are moved by compactSystemPrompts.
+	`
+	CREATE TABLE IF NOT EXISTS content_blobs (
+		hash TEXT PRIMARY KEY,
+		content TEXT NOT NULL
+	);
+	ALTER TABLE nodes ADD COLUMN system_prompt_hash TEXT;
+	UPDATE schema_version SET version = 12;
+	`,
+
+	// Migration 13: Add lineage columns for DAGs cloned from another DAG
+	`
+	ALTER TABLE nodes ADD COLUMN forked_from_dag TEXT;
+	ALTER TABLE nodes ADD COLUMN forked_from_node TEXT;
+	CREATE INDEX IF NOT EXISTS idx_nodes_forked_from ON nodes(forked_from_dag) WHERE forked_from_dag IS NOT NULL;
+	UPDATE schema_version SET version = 13;
+	`,
+
+	// Migration 14: Add tags column (JSON array) for classified DAGs
+	`
+	ALTER TABLE nodes ADD COLUMN tags TEXT;
+	UPDATE schema_version SET version = 14;
+	`,
+
+	// Migration 15: API keys for the server, stored by SHA-256 of the secret
+	`
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id TEXT PRIMARY KEY,
+		name TEXT,
+		hash TEXT NOT NULL UNIQUE,
+		scope TEXT NOT NULL CHECK(scope IN ('read', 'write')),
+		created_at TIMESTAMP NOT NULL,
+		last_used_at TIMESTAMP,
+		revoked_at TIMESTAMP
+	);
+	UPDATE schema_version SET version = 15;
+	`,
+
+	// Migration 16: Comments on nodes, removed with their node
+	`
+	CREATE TABLE IF NOT EXISTS comments (
+		id TEXT PRIMARY KEY,
+		node_id TEXT NOT NULL,
+		root_id TEXT NOT NULL,
+		reply_to TEXT,
+		author TEXT NOT NULL,
+		body TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_comments_root ON comments(root_id);
+
+	CREATE TRIGGER IF NOT EXISTS comments_node_delete AFTER DELETE ON nodes BEGIN
+		DELETE FROM comments WHERE node_id = old.id;
+	END;
+
+	UPDATE schema_version SET version = 16;
+	`,
+
+	// Migration 17: Content over the file threshold is stored in a file
+	// named by its SHA-256 (content_file), with an empty content column
+	`
+	ALTER TABLE nodes ADD COLUMN content_file TEXT;
+	UPDATE schema_version SET version = 17;
+	`,
+
+	// Migration 18: Embeddings of node content per model (little-endian
+	// float32 vectors), removed with their node or when its content changes
+	`
+	CREATE TABLE IF NOT EXISTS node_embeddings (
+		node_id TEXT NOT NULL,
+		model TEXT NOT NULL,
+		vector BLOB NOT NULL,
+		PRIMARY KEY (node_id, model)
+	);
+	CREATE INDEX IF NOT EXISTS idx_node_embeddings_model ON node_embeddings(model);
+
+	CREATE TRIGGER IF NOT EXISTS node_embeddings_node_delete AFTER DELETE ON nodes BEGIN
+		DELETE FROM node_embeddings WHERE node_id = old.id;
+	END;
+	CREATE TRIGGER IF NOT EXISTS node_embeddings_content_update AFTER UPDATE OF content, content_file ON nodes
+	WHEN old.content IS NOT new.content OR old.content_file IS NOT new.content_file BEGIN
+		DELETE FROM node_embeddings WHERE node_id = new.id;
+	END;
+
+	UPDATE schema_version SET version = 18;
+	`,
+
+	// Migration 19: Case-insensitive index on node IDs for prefix lookups
+	`
+	CREATE INDEX IF NOT EXISTS idx_nodes_id_nocase ON nodes(id COLLATE NOCASE);
+	UPDATE schema_version SET version = 19;
+	`,
+
+	// Migration 20: API keys may have the tools scope, which may also run
+	// built-in tools; SQLite can't alter a CHECK, so the table is rebuilt
+	`
+	CREATE TABLE api_keys_new (
+		id TEXT PRIMARY KEY,
+		name TEXT,
+		hash TEXT NOT NULL UNIQUE,
+		scope TEXT NOT NULL CHECK(scope IN ('read', 'write', 'tools')),
+		created_at TIMESTAMP NOT NULL,
+		last_used_at TIMESTAMP,
+		revoked_at TIMESTAMP
+	);
+	INSERT INTO api_keys_new SELECT id, name, hash, scope, created_at, last_used_at, revoked_at FROM api_keys;
+	DROP TABLE api_keys;
+	ALTER TABLE api_keys_new RENAME TO api_keys;
+	UPDATE schema_version SET version = 20;
+	`,
 }
+
+// contentBlobsVersion is the schema version that introduced content_blobs.
+const contentBlobsVersion = 12
@@ -7,23 +7,33 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
+	"langdag.com/langdag/internal/storage"
 	"langdag.com/langdag/types"
 	_ "modernc.org/sqlite"
 )
 
-// nodeColumns is the column list for node queries (unqualified).
-const nodeColumns = `id, parent_id, root_id, sequence, node_type, content, provider, model, tokens_in, tokens_out, tokens_cache_read, tokens_cache_creation, tokens_reasoning, latency_ms, stop_reason, output_group_id, status, title, system_prompt, created_at, metadata`
+// nodeColumns is the column list for node inserts and for selecting from
+// CTEs built with nodeColumnsQ (unqualified).
+const nodeColumns = `id, parent_id, root_id, sequence, node_type, content, provider, model, tokens_in, tokens_out, tokens_cache_read, tokens_cache_creation, tokens_reasoning, latency_ms, stop_reason, output_group_id, status, title, system_prompt, created_at, metadata, archived_uri, forked_from_dag, forked_from_node, tags, content_file`
 
-// nodeColumnsQ returns the column list qualified with a table alias.
+// nodeColumnsQ returns the column list for selecting from a nodes table
+// alias. The system prompt is resolved from content_blobs when the row
+// stores it by hash; content stored in a file is read by scanNode.
 func nodeColumnsQ(alias string) string {
-	return alias + `.id, ` + alias + `.parent_id, ` + alias + `.root_id, ` + alias + `.sequence, ` + alias + `.node_type, ` + alias + `.content, ` + alias + `.provider, ` + alias + `.model, ` + alias + `.tokens_in, ` + alias + `.tokens_out, ` + alias + `.tokens_cache_read, ` + alias + `.tokens_cache_creation, ` + alias + `.tokens_reasoning, ` + alias + `.latency_ms, ` + alias + `.stop_reason, ` + alias + `.output_group_id, ` + alias + `.status, ` + alias + `.title, ` + alias + `.system_prompt, ` + alias + `.created_at, ` + alias + `.metadata`
+	return alias + `.id, ` + alias + `.parent_id, ` + alias + `.root_id, ` + alias + `.sequence, ` + alias + `.node_type, ` + alias + `.content, ` + alias + `.provider, ` + alias + `.model, ` + alias + `.tokens_in, ` + alias + `.tokens_out, ` + alias + `.tokens_cache_read, ` + alias + `.tokens_cache_creation, ` + alias + `.tokens_reasoning, ` + alias + `.latency_ms, ` + alias + `.stop_reason, ` + alias + `.output_group_id, ` + alias + `.status, ` + alias + `.title, ` +
+		`COALESCE(` + alias + `.system_prompt, (SELECT b.content FROM content_blobs b WHERE b.hash = ` + alias + `.system_prompt_hash)) AS system_prompt, ` +
+		alias + `.created_at, ` + alias + `.metadata, ` + alias + `.archived_uri, ` + alias + `.forked_from_dag, ` + alias + `.forked_from_node, ` + alias + `.tags, ` + alias + `.content_file`
 }
 
 // SQLiteStorage implements the Storage interface using SQLite.
 type SQLiteStorage struct {
-	db   *sql.DB
-	path string
+	db     *sql.DB
+	path   string
+	writer *writeQueue
+
+	fileThreshold int // content larger than this is stored in files; 0 disables
 }
 
 // New creates a new SQLite storage instance.
@@ -34,8 +44,9 @@ func New(path string) (*SQLiteStorage, error) {
 	}
 
 	return &SQLiteStorage{
-		db:   db,
-		path: path,
+		db:     db,
+		path:   path,
+		writer: newWriteQueue(),
 	}, nil
 }
 
@@ -55,29 +66,55 @@ func (s *SQLiteStorage) Init(ctx context.Context) error {
 			return fmt.Errorf("failed to run migration %d: %w", i+1, err)
 		}
 	}
+	if version < contentBlobsVersion {
+		if err := s.compactSystemPrompts(ctx); err != nil {
+			return fmt.Errorf("failed to move system prompts to content_blobs: %w", err)
+		}
+	}
 	return nil
 }
 
-// Close closes the database connection.
+// Close stops the writer and closes the database connection.
 func (s *SQLiteStorage) Close() error {
+	s.writer.close()
 	return s.db.Close()
 }
 
+// WriteQueueStats returns metrics for the single-writer queue.
+func (s *SQLiteStorage) WriteQueueStats() WriteQueueStats {
+	return s.writer.stats()
+}
+
+// execer is a *sql.DB or a *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// exec runs a write statement through the single-writer queue.
+func (s *SQLiteStorage) exec(ctx context.Context, query string, args ...any) error {
+	return s.writer.submit(ctx, func(ctx context.Context) error {
+		_, err := s.db.ExecContext(ctx, query, args...)
+		return err
+	})
+}
+
 // =============================================================================
 // Node Operations
 // =============================================================================
 
-// scanNode scans a node from a SQL row.
-func scanNode(scanner interface{ Scan(...any) error }) (*types.Node, error) {
+// scanNode scans a node from a SQL row, reading its content from its file
+// when it is stored in one.
+func (s *SQLiteStorage) scanNode(scanner interface{ Scan(...any) error }) (*types.Node, error) {
 	var node types.Node
-	var parentID, rootID, providerName, model, stopReason, outputGroupID, status, title, systemPrompt, metadata sql.NullString
+	var parentID, rootID, providerName, model, stopReason, outputGroupID, status, title, systemPrompt, metadata, archivedURI, forkedFromDAG, forkedFromNode, tags, contentFile sql.NullString
 	var tokensIn, tokensOut, tokensCacheRead, tokensCacheCreation, tokensReasoning, latencyMs sql.NullInt64
 
 	err := scanner.Scan(
 		&node.ID, &parentID, &rootID, &node.Sequence, &node.NodeType, &node.Content,
 		&providerName, &model, &tokensIn, &tokensOut, &tokensCacheRead, &tokensCacheCreation, &tokensReasoning,
 		&latencyMs, &stopReason, &outputGroupID, &status,
-		&title, &systemPrompt, &node.CreatedAt, &metadata,
+		&title, &systemPrompt, &node.CreatedAt, &metadata, &archivedURI, &forkedFromDAG, &forkedFromNode, &tags, &contentFile,
 	)
 	if err != nil {
 		return nil, err
@@ -98,18 +135,31 @@ func scanNode(scanner interface{ Scan(...any) error }) (*types.Node, error) {
 	node.Status = status.String
 	node.Title = title.String
 	node.SystemPrompt = systemPrompt.String
+	node.ArchivedURI = archivedURI.String
+	node.ForkedFromDAG = forkedFromDAG.String
+	node.ForkedFromNode = forkedFromNode.String
+	if tags.Valid && tags.String != "" {
+		if err := json.Unmarshal([]byte(tags.String), &node.Tags); err != nil {
+			return nil, fmt.Errorf("invalid tags for node %s: %w", node.ID, err)
+		}
+	}
 	if metadata.Valid && metadata.String != "" {
 		node.Metadata = json.RawMessage(metadata.String)
 	}
+	if contentFile.Valid {
+		if node.Content, err = s.loadContent(contentFile.String); err != nil {
+			return nil, fmt.Errorf("node %s: %w", node.ID, err)
+		}
+	}
 
 	return &node, nil
 }
 
 // scanNodes scans multiple nodes from SQL rows.
-func scanNodes(rows *sql.Rows) ([]*types.Node, error) {
+func (s *SQLiteStorage) scanNodes(rows *sql.Rows) ([]*types.Node, error) {
 	var nodes []*types.Node
 	for rows.Next() {
-		node, err := scanNode(rows)
+		node, err := s.scanNode(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan node: %w", err)
 		}
@@ -118,25 +168,48 @@ func scanNodes(rows *sql.Rows) ([]*types.Node, error) {
 	return nodes, rows.Err()
 }
 
-// CreateNode creates a new node.
+// CreateNode creates a new node. Its system prompt is stored once in
+// content_blobs and referenced by hash, and content over the file threshold
+// is stored in a file.
 func (s *SQLiteStorage) CreateNode(ctx context.Context, node *types.Node) error {
-	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO nodes (`+nodeColumns+`)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, node.ID, nullString(node.ParentID), nullString(node.RootID), node.Sequence, node.NodeType, node.Content,
-		nullString(node.Provider), nullString(node.Model), node.TokensIn, node.TokensOut, node.TokensCacheRead, node.TokensCacheCreation, node.TokensReasoning,
-		node.LatencyMs, nullString(node.StopReason), nullString(node.OutputGroupID), nullString(node.Status),
-		nullString(node.Title), nullString(node.SystemPrompt), node.CreatedAt, nullRawMessage(node.Metadata))
+	content, contentFile, err := s.storeContent(node.Content)
+	if err != nil {
+		return fmt.Errorf("failed to create node: %w", err)
+	}
+	err = s.writer.submit(ctx, func(ctx context.Context) error {
+		return insertNode(ctx, s.db, node, content, contentFile)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create node: %w", err)
 	}
 	return nil
 }
 
+// insertNode inserts node, with content as returned by storeContent. It
+// must run on the writer.
+func insertNode(ctx context.Context, db execer, node *types.Node, content, contentFile string) error {
+	hash := blobHash(node.SystemPrompt)
+	if err := putBlob(ctx, db, hash, node.SystemPrompt); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO nodes (`+nodeColumns+`, system_prompt_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULL, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, node.ID, nullString(node.ParentID), nullString(node.RootID), node.Sequence, node.NodeType, content,
+		nullString(node.Provider), nullString(node.Model), node.TokensIn, node.TokensOut, node.TokensCacheRead, node.TokensCacheCreation, node.TokensReasoning,
+		node.LatencyMs, nullString(node.StopReason), nullString(node.OutputGroupID), nullString(node.Status),
+		nullString(node.Title), node.CreatedAt, nullRawMessage(node.Metadata), nullString(node.ArchivedURI),
+		nullString(node.ForkedFromDAG), nullString(node.ForkedFromNode), nullTags(node.Tags), nullString(contentFile), nullString(hash))
+	if err != nil {
+		return err
+	}
+	return indexContent(ctx, db, node.ID, node.Content, contentFile)
+}
+
 // GetNode retrieves a node by ID.
 func (s *SQLiteStorage) GetNode(ctx context.Context, id string) (*types.Node, error) {
-	node, err := scanNode(s.db.QueryRowContext(ctx, `
-		SELECT `+nodeColumns+` FROM nodes WHERE id = ?
+	node, err := s.scanNode(s.db.QueryRowContext(ctx, `
+		SELECT `+nodeColumnsQ("nodes")+` FROM nodes WHERE id = ?
 	`, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -147,24 +220,35 @@ func (s *SQLiteStorage) GetNode(ctx context.Context, id string) (*types.Node, er
 	return node, nil
 }
 
-// GetNodeByPrefix retrieves a node by ID prefix.
+// GetNodeByPrefix retrieves a node by ID prefix. The prefix is matched as
+// a range of idx_nodes_id_nocase, which LIKE cannot use.
 func (s *SQLiteStorage) GetNodeByPrefix(ctx context.Context, prefix string) (*types.Node, error) {
-	node, err := scanNode(s.db.QueryRowContext(ctx, `
-		SELECT `+nodeColumns+` FROM nodes WHERE id LIKE ? || '%' LIMIT 1
-	`, prefix))
-	if err == sql.ErrNoRows {
-		return nil, nil
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+nodeColumnsQ("nodes")+` FROM nodes
+		WHERE id >= ? COLLATE NOCASE AND id < ? COLLATE NOCASE
+		LIMIT 2
+	`, prefix, prefix+string(utf8.MaxRune))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node by prefix: %w", err)
 	}
+	defer rows.Close()
+	nodes, err := s.scanNodes(rows)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get node by prefix: %w", err)
 	}
-	return node, nil
+	switch len(nodes) {
+	case 0:
+		return nil, nil
+	case 1:
+		return nodes[0], nil
+	}
+	return nil, fmt.Errorf("%w: %q matches several nodes", storage.ErrAmbiguousPrefix, prefix)
 }
 
 // GetNodeChildren retrieves direct children of a node.
 func (s *SQLiteStorage) GetNodeChildren(ctx context.Context, parentID string) ([]*types.Node, error) {
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT `+nodeColumns+` FROM nodes
+		SELECT `+nodeColumnsQ("nodes")+` FROM nodes
 		WHERE parent_id = ?
 		ORDER BY sequence ASC
 	`, parentID)
@@ -172,14 +256,14 @@ func (s *SQLiteStorage) GetNodeChildren(ctx context.Context, parentID string) ([
 		return nil, fmt.Errorf("failed to get node children: %w", err)
 	}
 	defer rows.Close()
-	return scanNodes(rows)
+	return s.scanNodes(rows)
 }
 
 // GetSubtree retrieves a node and all its descendants.
 func (s *SQLiteStorage) GetSubtree(ctx context.Context, nodeID string) ([]*types.Node, error) {
 	rows, err := s.db.QueryContext(ctx, `
 		WITH RECURSIVE subtree AS (
-			SELECT `+nodeColumns+` FROM nodes WHERE id = ?
+			SELECT `+nodeColumnsQ("nodes")+` FROM nodes WHERE id = ?
 			UNION ALL
 			SELECT `+nodeColumnsQ("n")+` FROM nodes n
 			JOIN subtree s ON n.parent_id = s.id
@@ -190,14 +274,14 @@ func (s *SQLiteStorage) GetSubtree(ctx context.Context, nodeID string) ([]*types
 		return nil, fmt.Errorf("failed to get subtree: %w", err)
 	}
 	defer rows.Close()
-	return scanNodes(rows)
+	return s.scanNodes(rows)
 }
 
 // GetAncestors retrieves the path from root to the given node (inclusive), ordered root-first.
 func (s *SQLiteStorage) GetAncestors(ctx context.Context, nodeID string) ([]*types.Node, error) {
 	rows, err := s.db.QueryContext(ctx, `
 		WITH RECURSIVE ancestors AS (
-			SELECT `+nodeColumns+` FROM nodes WHERE id = ?
+			SELECT `+nodeColumnsQ("nodes")+` FROM nodes WHERE id = ?
 			UNION ALL
 			SELECT `+nodeColumnsQ("n")+` FROM nodes n
 			JOIN ancestors a ON n.id = a.parent_id
@@ -208,13 +292,13 @@ func (s *SQLiteStorage) GetAncestors(ctx context.Context, nodeID string) ([]*typ
 		return nil, fmt.Errorf("failed to get ancestors: %w", err)
 	}
 	defer rows.Close()
-	return scanNodes(rows)
+	return s.scanNodes(rows)
 }
 
 // ListRootNodes returns all root nodes (nodes with no parent), ordered by creation time.
 func (s *SQLiteStorage) ListRootNodes(ctx context.Context) ([]*types.Node, error) {
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT `+nodeColumns+` FROM nodes
+		SELECT `+nodeColumnsQ("nodes")+` FROM nodes
 		WHERE parent_id IS NULL
 		ORDER BY created_at DESC
 	`)
@@ -222,29 +306,98 @@ func (s *SQLiteStorage) ListRootNodes(ctx context.Context) ([]*types.Node, error
 		return nil, fmt.Errorf("failed to list root nodes: %w", err)
 	}
 	defer rows.Close()
-	return scanNodes(rows)
+	return s.scanNodes(rows)
+}
+
+// SearchNodes returns up to limit nodes matching every term of query,
+// ranked by the full-text index. Terms match as word prefixes.
+func (s *SQLiteStorage) SearchNodes(ctx context.Context, query string, limit int) ([]*types.Node, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+nodeColumnsQ("n")+` FROM nodes_fts f
+		JOIN nodes n ON n.id = f.node_id
+		WHERE nodes_fts MATCH ?
+		ORDER BY f.rank
+		LIMIT ?
+	`, match, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search nodes: %w", err)
+	}
+	defer rows.Close()
+	return s.scanNodes(rows)
+}
+
+// ftsQuery turns free text into an FTS5 query: each term is quoted so
+// punctuation is not parsed as query syntax, and matched as a prefix.
+func ftsQuery(query string) string {
+	terms := strings.Fields(query)
+	for i, t := range terms {
+		terms[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"*`
+	}
+	return strings.Join(terms, " ")
 }
 
 // UpdateNode updates an existing node.
 func (s *SQLiteStorage) UpdateNode(ctx context.Context, node *types.Node) error {
-	_, err := s.db.ExecContext(ctx, `
-		UPDATE nodes SET content = ?, provider = ?, model = ?, tokens_in = ?, tokens_out = ?,
-			tokens_cache_read = ?, tokens_cache_creation = ?, tokens_reasoning = ?,
-			latency_ms = ?, status = ?, title = ?, system_prompt = ?, metadata = ?
-		WHERE id = ?
-	`, node.Content, nullString(node.Provider), nullString(node.Model), node.TokensIn, node.TokensOut,
-		node.TokensCacheRead, node.TokensCacheCreation, node.TokensReasoning,
-		node.LatencyMs, nullString(node.Status), nullString(node.Title), nullString(node.SystemPrompt),
-		nullRawMessage(node.Metadata), node.ID)
+	hash := blobHash(node.SystemPrompt)
+	content, contentFile, err := s.storeContent(node.Content)
+	if err != nil {
+		return fmt.Errorf("failed to update node: %w", err)
+	}
+	err = s.writer.submit(ctx, func(ctx context.Context) error {
+		if err := putBlob(ctx, s.db, hash, node.SystemPrompt); err != nil {
+			return err
+		}
+		_, err := s.db.ExecContext(ctx, `
+			UPDATE nodes SET content = ?, content_file = ?, provider = ?, model = ?, tokens_in = ?, tokens_out = ?,
+				tokens_cache_read = ?, tokens_cache_creation = ?, tokens_reasoning = ?,
+				latency_ms = ?, status = ?, title = ?, system_prompt = NULL, system_prompt_hash = ?,
+				metadata = ?, archived_uri = ?, tags = ?
+			WHERE id = ?
+		`, content, nullString(contentFile), nullString(node.Provider), nullString(node.Model), node.TokensIn, node.TokensOut,
+			node.TokensCacheRead, node.TokensCacheCreation, node.TokensReasoning,
+			node.LatencyMs, nullString(node.Status), nullString(node.Title), nullString(hash),
+			nullRawMessage(node.Metadata), nullString(node.ArchivedURI), nullTags(node.Tags), node.ID)
+		if err != nil {
+			return err
+		}
+		return indexContent(ctx, s.db, node.ID, node.Content, contentFile)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to update node: %w", err)
 	}
 	return nil
 }
 
+// indexContent adds content stored in a file to the full-text index, which
+// the nodes_fts triggers fill from the (empty) content column. It must run
+// on the writer.
+func indexContent(ctx context.Context, db execer, nodeID, content, contentFile string) error {
+	if contentFile == "" {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, `UPDATE nodes_fts SET content = ? WHERE node_id = ?`, content, nodeID)
+	return err
+}
+
 // DeleteNode deletes a node and all its descendants.
 func (s *SQLiteStorage) DeleteNode(ctx context.Context, id string) error {
-	_, err := s.db.ExecContext(ctx, `
+	err := s.writer.submit(ctx, func(ctx context.Context) error {
+		return deleteNode(ctx, s.db, id)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete node: %w", err)
+	}
+	return nil
+}
+
+// deleteNode deletes a node and all its descendants. It must run on the
+// writer.
+func deleteNode(ctx context.Context, db execer, id string) error {
+	_, err := db.ExecContext(ctx, `
 		WITH RECURSIVE subtree AS (
 			SELECT id FROM nodes WHERE id = ?
 			UNION ALL
@@ -252,10 +405,7 @@ func (s *SQLiteStorage) DeleteNode(ctx context.Context, id string) error {
 		)
 		DELETE FROM nodes WHERE id IN (SELECT id FROM subtree)
 	`, id)
-	if err != nil {
-		return fmt.Errorf("failed to delete node: %w", err)
-	}
-	return nil
+	return err
 }
 
 // =============================================================================
@@ -264,7 +414,7 @@ func (s *SQLiteStorage) DeleteNode(ctx context.Context, id string) error {
 
 // CreateAlias creates an alias for a node.
 func (s *SQLiteStorage) CreateAlias(ctx context.Context, nodeID, alias string) error {
-	_, err := s.db.ExecContext(ctx, `
+	err := s.exec(ctx, `
 		INSERT INTO node_aliases (alias, node_id) VALUES (?, ?)
 	`, alias, nodeID)
 	if err != nil {
@@ -275,7 +425,7 @@ func (s *SQLiteStorage) CreateAlias(ctx context.Context, nodeID, alias string) e
 
 // DeleteAlias removes an alias.
 func (s *SQLiteStorage) DeleteAlias(ctx context.Context, alias string) error {
-	_, err := s.db.ExecContext(ctx, `DELETE FROM node_aliases WHERE alias = ?`, alias)
+	err := s.exec(ctx, `DELETE FROM node_aliases WHERE alias = ?`, alias)
 	if err != nil {
 		return fmt.Errorf("failed to delete alias: %w", err)
 	}
@@ -284,7 +434,7 @@ func (s *SQLiteStorage) DeleteAlias(ctx context.Context, alias string) error {
 
 // GetNodeByAlias retrieves a node by its alias.
 func (s *SQLiteStorage) GetNodeByAlias(ctx context.Context, alias string) (*types.Node, error) {
-	node, err := scanNode(s.db.QueryRowContext(ctx, `
+	node, err := s.scanNode(s.db.QueryRowContext(ctx, `
 		SELECT `+nodeColumnsQ("n")+` FROM nodes n
 		JOIN node_aliases a ON n.id = a.node_id
 		WHERE a.alias = ?
@@ -329,12 +479,22 @@ func (s *SQLiteStorage) IndexToolIDs(ctx context.Context, nodeID string, toolIDs
 	if len(toolIDs) == 0 {
 		return nil
 	}
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin tx: %w", err)
-	}
-	defer tx.Rollback() //nolint:errcheck
-	stmt, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO node_tool_ids (node_id, tool_id, role) VALUES (?, ?, ?)`)
+	return s.writer.submit(ctx, func(ctx context.Context) error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin tx: %w", err)
+		}
+		defer tx.Rollback() //nolint:errcheck
+		if err := indexToolIDs(ctx, tx, nodeID, toolIDs, role); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// indexToolIDs records toolIDs in node_tool_ids. It must run on the writer.
+func indexToolIDs(ctx context.Context, db execer, nodeID string, toolIDs []string, role string) error {
+	stmt, err := db.PrepareContext(ctx, `INSERT OR IGNORE INTO node_tool_ids (node_id, tool_id, role) VALUES (?, ?, ?)`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare insert: %w", err)
 	}
@@ -344,7 +504,7 @@ func (s *SQLiteStorage) IndexToolIDs(ctx context.Context, nodeID string, toolIDs
 			return fmt.Errorf("failed to index tool ID %s: %w", id, err)
 		}
 	}
-	return tx.Commit()
+	return nil
 }
 
 // GetOrphanedToolUses returns tool_use IDs among the given ancestor node IDs
@@ -415,3 +575,12 @@ func nullRawMessage(m json.RawMessage) sql.NullString {
 	}
 	return sql.NullString{String: string(m), Valid: true}
 }
+
+// nullTags stores tags as a JSON array, or NULL when there are none.
+func nullTags(tags []string) sql.NullString {
+	if len(tags) == 0 {
+		return sql.NullString{}
+	}
+	data, _ := json.Marshal(tags)
+	return sql.NullString{String: string(data), Valid: true}
+}
@@ -2,10 +2,16 @@ package sqlite
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"langdag.com/langdag/internal/storage"
 	"langdag.com/langdag/types"
 )
 
@@ -109,6 +115,22 @@ func TestGetNodeByPrefix(t *testing.T) {
 	if got.ID != "abcdef-1234-5678" {
 		t.Errorf("ID = %q, want %q", got.ID, "abcdef-1234-5678")
 	}
+
+	// Matching ignores ASCII case.
+	if got, _ := store.GetNodeByPrefix(ctx, "ABCDEF"); got == nil || got.ID != node.ID {
+		t.Errorf("case-insensitive prefix: got %+v", got)
+	}
+
+	other := &types.Node{ID: "abcxyz-0000", NodeType: types.NodeTypeUser, CreatedAt: time.Now()}
+	if err := store.CreateNode(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.GetNodeByPrefix(ctx, "abc"); !errors.Is(err, storage.ErrAmbiguousPrefix) || got != nil {
+		t.Errorf("ambiguous prefix: got %+v, %v", got, err)
+	}
+	if got, err := store.GetNodeByPrefix(ctx, "zzz"); err != nil || got != nil {
+		t.Errorf("unmatched prefix: got %+v, %v", got, err)
+	}
 }
 
 func TestListRootNodes(t *testing.T) {
@@ -266,6 +288,7 @@ func TestUpdateNode(t *testing.T) {
 	node.Title = "Updated Title"
 	node.Content = "updated content"
 	node.Status = "completed"
+	node.Tags = []string{"caching", "databases"}
 	if err := store.UpdateNode(ctx, node); err != nil {
 		t.Fatalf("UpdateNode: %v", err)
 	}
@@ -283,6 +306,9 @@ func TestUpdateNode(t *testing.T) {
 	if got.Status != "completed" {
 		t.Errorf("Status = %q, want %q", got.Status, "completed")
 	}
+	if len(got.Tags) != 2 || got.Tags[0] != "caching" || got.Tags[1] != "databases" {
+		t.Errorf("Tags = %v, want [caching databases]", got.Tags)
+	}
 }
 
 func TestDeleteNode(t *testing.T) {
@@ -571,6 +597,19 @@ func TestBackfillMigration_IndexesExistingNodes(t *testing.T) {
 	store.db.ExecContext(ctx, "ALTER TABLE nodes DROP COLUMN stop_reason")
 	store.db.ExecContext(ctx, "DROP INDEX IF EXISTS idx_nodes_output_group")
 	store.db.ExecContext(ctx, "ALTER TABLE nodes DROP COLUMN output_group_id")
+	store.db.ExecContext(ctx, "DROP TRIGGER nodes_fts_insert")
+	store.db.ExecContext(ctx, "DROP TRIGGER nodes_fts_delete")
+	store.db.ExecContext(ctx, "DROP TRIGGER nodes_fts_update")
+	store.db.ExecContext(ctx, "DROP TABLE nodes_fts")
+	store.db.ExecContext(ctx, "ALTER TABLE nodes DROP COLUMN archived_uri")
+	store.db.ExecContext(ctx, "ALTER TABLE nodes DROP COLUMN system_prompt_hash")
+	store.db.ExecContext(ctx, "DROP TABLE content_blobs")
+	store.db.ExecContext(ctx, "DROP INDEX idx_nodes_forked_from")
+	store.db.ExecContext(ctx, "ALTER TABLE nodes DROP COLUMN forked_from_dag")
+	store.db.ExecContext(ctx, "ALTER TABLE nodes DROP COLUMN forked_from_node")
+	store.db.ExecContext(ctx, "ALTER TABLE nodes DROP COLUMN tags")
+	store.db.ExecContext(ctx, "DROP TRIGGER node_embeddings_content_update")
+	store.db.ExecContext(ctx, "ALTER TABLE nodes DROP COLUMN content_file")
 	store.db.ExecContext(ctx, "UPDATE schema_version SET version = 6")
 	store.Close()
 
@@ -634,3 +673,369 @@ func TestDeleteNodePartialSubtree(t *testing.T) {
 		t.Error("child2 was deleted")
 	}
 }
+
+func TestConcurrentWritesAreSerialized(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+
+	const writers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- store.CreateNode(ctx, &types.Node{
+				ID:        fmt.Sprintf("concurrent-%d", i),
+				NodeType:  types.NodeTypeUser,
+				Content:   "hi",
+				CreatedAt: time.Now(),
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("CreateNode: %v", err)
+		}
+	}
+
+	roots, err := store.ListRootNodes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != writers {
+		t.Errorf("got %d root nodes, want %d", len(roots), writers)
+	}
+
+	stats := store.WriteQueueStats()
+	if stats.Depth != 0 {
+		t.Errorf("Depth = %d, want 0", stats.Depth)
+	}
+	if stats.Completed < writers {
+		t.Errorf("Completed = %d, want >= %d", stats.Completed, writers)
+	}
+	if stats.MaxDepth < 1 {
+		t.Errorf("MaxDepth = %d, want >= 1", stats.MaxDepth)
+	}
+}
+
+func TestWriteAfterCloseFails(t *testing.T) {
+	store := setupTestDB(t)
+	store.Close()
+
+	err := store.CreateNode(context.Background(), &types.Node{ID: "late", NodeType: types.NodeTypeUser, CreatedAt: time.Now()})
+	if err == nil {
+		t.Fatal("expected error writing to closed storage")
+	}
+}
+
+func TestSearchNodesTracksUpdatesAndDeletes(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+
+	for _, n := range []*types.Node{
+		{ID: "root", Sequence: 0, NodeType: types.NodeTypeUser, Content: "deploying to Kubernetes", CreatedAt: time.Now()},
+		{ID: "child", ParentID: "root", RootID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant, Content: "ok", CreatedAt: time.Now()},
+	} {
+		if err := store.CreateNode(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := store.SearchNodes(ctx, "kubernetes", 10)
+	if err != nil {
+		t.Fatalf("SearchNodes: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != "root" {
+		t.Fatalf("expected root, got %d nodes", len(found))
+	}
+
+	if err := store.UpdateNode(ctx, &types.Node{ID: "child", Content: "Kubernetes it is"}); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := store.SearchNodes(ctx, "kubernetes", 10); len(found) != 2 {
+		t.Errorf("after update: expected 2 matches, got %d", len(found))
+	}
+	if found, _ := store.SearchNodes(ctx, "kubernetes", 1); len(found) != 1 {
+		t.Errorf("limit: expected 1 match, got %d", len(found))
+	}
+
+	if err := store.DeleteNode(ctx, "root"); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := store.SearchNodes(ctx, "kubernetes", 10); len(found) != 0 {
+		t.Errorf("after delete: expected no matches, got %d", len(found))
+	}
+}
+
+func TestSystemPromptsStoredOnce(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+
+	prompt := strings.Repeat("You are a careful assistant. ", 200)
+	for _, n := range []*types.Node{
+		{ID: "root", Sequence: 0, NodeType: types.NodeTypeUser, Content: "hi", SystemPrompt: prompt, CreatedAt: time.Now()},
+		{ID: "a1", ParentID: "root", RootID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant, Content: "hello", SystemPrompt: prompt, CreatedAt: time.Now()},
+		{ID: "other", Sequence: 0, NodeType: types.NodeTypeUser, Content: "hey", SystemPrompt: prompt, CreatedAt: time.Now()},
+	} {
+		if err := store.CreateNode(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var blobs, inline int
+	store.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM content_blobs").Scan(&blobs)
+	store.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM nodes WHERE system_prompt IS NOT NULL").Scan(&inline)
+	if blobs != 1 || inline != 0 {
+		t.Errorf("content_blobs = %d rows, inline prompts = %d; want 1 and 0", blobs, inline)
+	}
+
+	// Every read path resolves the prompt.
+	node, _ := store.GetNode(ctx, "a1")
+	if node.SystemPrompt != prompt {
+		t.Error("GetNode did not resolve the system prompt")
+	}
+	subtree, _ := store.GetSubtree(ctx, "root")
+	ancestors, _ := store.GetAncestors(ctx, "a1")
+	roots, _ := store.ListRootNodes(ctx)
+	for _, nodes := range [][]*types.Node{subtree, ancestors, roots} {
+		for _, n := range nodes {
+			if n.SystemPrompt != prompt {
+				t.Errorf("node %s: system prompt not resolved", n.ID)
+			}
+		}
+	}
+
+	node.SystemPrompt = "Be brief."
+	if err := store.UpdateNode(ctx, node); err != nil {
+		t.Fatal(err)
+	}
+	if node, _ := store.GetNode(ctx, "a1"); node.SystemPrompt != "Be brief." {
+		t.Errorf("after update: system prompt = %q", node.SystemPrompt)
+	}
+	node.SystemPrompt = ""
+	store.UpdateNode(ctx, node)
+	if node, _ := store.GetNode(ctx, "a1"); node.SystemPrompt != "" {
+		t.Errorf("after clearing: system prompt = %q", node.SystemPrompt)
+	}
+}
+
+func TestContentBlobsMigrationMovesInlinePrompts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "langdag.db")
+	store, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := store.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate rows written before migration 12.
+	for _, id := range []string{"r1", "r2"} {
+		if err := store.CreateNode(ctx, &types.Node{ID: id, NodeType: types.NodeTypeUser, Content: "hi", CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.db.ExecContext(ctx, "UPDATE nodes SET system_prompt = 'Legacy prompt'")
+	store.db.ExecContext(ctx, "ALTER TABLE nodes DROP COLUMN system_prompt_hash")
+	store.db.ExecContext(ctx, "DROP TABLE content_blobs")
+	store.db.ExecContext(ctx, "DROP INDEX idx_nodes_forked_from")
+	store.db.ExecContext(ctx, "ALTER TABLE nodes DROP COLUMN forked_from_dag")
+	store.db.ExecContext(ctx, "ALTER TABLE nodes DROP COLUMN forked_from_node")
+	store.db.ExecContext(ctx, "ALTER TABLE nodes DROP COLUMN tags")
+	store.db.ExecContext(ctx, "DROP TRIGGER node_embeddings_content_update")
+	store.db.ExecContext(ctx, "ALTER TABLE nodes DROP COLUMN content_file")
+	store.db.ExecContext(ctx, "UPDATE schema_version SET version = 11")
+	store.Close()
+
+	store, err = New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	var blobs, inline int
+	store.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM content_blobs").Scan(&blobs)
+	store.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM nodes WHERE system_prompt IS NOT NULL").Scan(&inline)
+	if blobs != 1 || inline != 0 {
+		t.Errorf("content_blobs = %d rows, inline prompts = %d; want 1 and 0", blobs, inline)
+	}
+	if node, _ := store.GetNode(ctx, "r2"); node.SystemPrompt != "Legacy prompt" {
+		t.Errorf("system prompt = %q, want Legacy prompt", node.SystemPrompt)
+	}
+}
+
+func TestLargeContentStoredInFiles(t *testing.T) {
+	store := setupTestDB(t)
+	t.Cleanup(func() { os.RemoveAll(FilesDir(store.path)) })
+	store.SetFileThreshold(100)
+	ctx := context.Background()
+
+	large := strings.Repeat("lorem ipsum ", 20) + "zanzibar"
+	for _, id := range []string{"f1", "f2"} {
+		if err := store.CreateNode(ctx, &types.Node{ID: id, NodeType: types.NodeTypeUser, Content: large, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("CreateNode: %v", err)
+		}
+	}
+	if err := store.CreateNode(ctx, &types.Node{ID: "small", NodeType: types.NodeTypeUser, Content: "hi", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	var inline string
+	store.db.QueryRowContext(ctx, "SELECT content FROM nodes WHERE id = 'f1'").Scan(&inline)
+	if inline != "" {
+		t.Errorf("content column = %d bytes, want empty", len(inline))
+	}
+	files, _ := filepath.Glob(filepath.Join(FilesDir(store.path), "*", "*"))
+	if len(files) != 1 {
+		t.Errorf("content files = %v, want one shared by both nodes", files)
+	}
+	if node, err := store.GetNode(ctx, "f2"); err != nil || node.Content != large {
+		t.Errorf("GetNode = %v, %v; want the file content", node, err)
+	}
+	if node, _ := store.GetNode(ctx, "small"); node.Content != "hi" {
+		t.Errorf("small content = %q", node.Content)
+	}
+	if found, _ := store.SearchNodes(ctx, "zanzibar", 10); len(found) != 2 || found[0].Content != large {
+		t.Errorf("search found %d nodes, want 2 with their content", len(found))
+	}
+
+	// Shrinking a node moves its content back inline.
+	node, _ := store.GetNode(ctx, "f2")
+	node.Content = "short"
+	if err := store.UpdateNode(ctx, node); err != nil {
+		t.Fatal(err)
+	}
+	if node, _ := store.GetNode(ctx, "f2"); node.Content != "short" {
+		t.Errorf("updated content = %q", node.Content)
+	}
+
+	// The file is kept while f1 references it, and when it is recent.
+	if err := store.DeleteNode(ctx, "f1"); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := store.PruneFiles(ctx, false); err != nil || result.Files != 0 {
+		t.Errorf("PruneFiles of a recent file = %+v, %v; want nothing removed", result, err)
+	}
+	old := time.Now().Add(-2 * pruneGrace)
+	os.Chtimes(files[0], old, old)
+	if result, err := store.PruneFiles(ctx, true); err != nil || result.Files != 1 || result.Bytes != int64(len(large)) {
+		t.Errorf("dry run = %+v, %v; want 1 file", result, err)
+	}
+	if _, err := os.Stat(files[0]); err != nil {
+		t.Errorf("dry run removed the file: %v", err)
+	}
+	if result, err := store.PruneFiles(ctx, false); err != nil || result.Files != 1 {
+		t.Errorf("PruneFiles = %+v, %v; want 1 file removed", result, err)
+	}
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Errorf("unreferenced file still exists: %v", err)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	key := &types.APIKey{ID: "k1", Name: "ci", Hash: "abc", Scope: types.APIKeyScopeRead, CreatedAt: created}
+	if err := store.CreateAPIKey(ctx, key); err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	if err := store.CreateAPIKey(ctx, &types.APIKey{ID: "k2", Hash: "abc", Scope: types.APIKeyScopeWrite, CreatedAt: created}); err == nil {
+		t.Error("expected an error for a duplicate hash")
+	}
+
+	got, err := store.GetAPIKeyByHash(ctx, "abc")
+	if err != nil || got == nil {
+		t.Fatalf("GetAPIKeyByHash: %v, %v", got, err)
+	}
+	if got.ID != "k1" || got.Name != "ci" || got.Scope != "read" || !got.CreatedAt.Equal(created) || got.LastUsedAt != nil {
+		t.Errorf("got %+v", got)
+	}
+	if missing, err := store.GetAPIKeyByHash(ctx, "nope"); err != nil || missing != nil {
+		t.Errorf("unknown hash: %v, %v", missing, err)
+	}
+
+	used := created.Add(time.Hour)
+	if err := store.TouchAPIKey(ctx, "k1", used); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeAPIKey(ctx, "k1", used.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeAPIKey(ctx, "missing", used); err == nil {
+		t.Error("expected an error revoking an unknown key")
+	}
+
+	keys, err := store.ListAPIKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].LastUsedAt == nil || !keys[0].LastUsedAt.Equal(used) || keys[0].RevokedAt == nil {
+		t.Fatalf("keys = %+v", keys)
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+	root := &types.Node{ID: "root", NodeType: types.NodeTypeUser, Content: "root", CreatedAt: time.Now()}
+	if err := store.CreateNode(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	reply := &types.Node{ID: "reply", ParentID: "root", RootID: "root", Sequence: 1, NodeType: types.NodeTypeAssistant, Content: "reply", CreatedAt: time.Now()}
+
+	// A failed transaction saves nothing.
+	err := store.WithTx(ctx, func(tx storage.NodeWriter) error {
+		if err := tx.CreateNode(ctx, reply); err != nil {
+			return err
+		}
+		if err := tx.IndexToolIDs(ctx, "reply", []string{"call_1"}, "use"); err != nil {
+			return err
+		}
+		return fmt.Errorf("injected failure")
+	})
+	if err == nil || !strings.Contains(err.Error(), "injected failure") {
+		t.Fatalf("WithTx error = %v, want the injected failure", err)
+	}
+	if got, _ := store.GetNode(ctx, "reply"); got != nil {
+		t.Error("node of a failed transaction was saved")
+	}
+	if orphans, _ := store.GetOrphanedToolUses(ctx, []string{"root", "reply"}); len(orphans) != 0 {
+		t.Errorf("tool IDs of a failed transaction were indexed: %v", orphans)
+	}
+
+	// A successful one saves every write.
+	err = store.WithTx(ctx, func(tx storage.NodeWriter) error {
+		if err := tx.CreateNode(ctx, reply); err != nil {
+			return err
+		}
+		return tx.IndexToolIDs(ctx, "reply", []string{"call_1"}, "use")
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if got, _ := store.GetNode(ctx, "reply"); got == nil || got.Content != "reply" {
+		t.Errorf("GetNode(reply) = %+v", got)
+	}
+	if orphans, _ := store.GetOrphanedToolUses(ctx, []string{"root", "reply"}); len(orphans["reply"]) != 1 {
+		t.Errorf("orphans = %v, want call_1 on reply", orphans)
+	}
+
+	err = store.WithTx(ctx, func(tx storage.NodeWriter) error {
+		return tx.DeleteNode(ctx, "root")
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if got, _ := store.GetNode(ctx, "reply"); got != nil {
+		t.Error("DeleteNode in a transaction kept the subtree")
+	}
+}
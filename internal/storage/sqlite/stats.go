@@ -0,0 +1,116 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Stats are aggregate figures over the stored DAGs.
+type Stats struct {
+	DAGs            int          `json:"dags"`
+	Nodes           int          `json:"nodes"`
+	TokensIn        int          `json:"tokens_in"`
+	TokensOut       int          `json:"tokens_out"`
+	TokensCacheRead int          `json:"tokens_cache_read"`
+	ByStatus        []StatsCount `json:"by_status"`    // DAGs by the status of their newest node
+	BySource        []StatsCount `json:"by_source"`    // DAGs by origin: langdag, fork or an importer such as langgraph
+	Models          []ModelStats `json:"models"`       // replies by model, most used first
+	BusiestDays     []StatsCount `json:"busiest_days"` // days with the most messages
+}
+
+// StatsCount is a count in Stats.
+type StatsCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// ModelStats are the reply totals of a model.
+type ModelStats struct {
+	Model        string  `json:"model"`
+	Replies      int     `json:"replies"`
+	TokensIn     int     `json:"tokens_in"`
+	TokensOut    int     `json:"tokens_out"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"` // over the replies with a recorded latency
+}
+
+// Stats computes Stats with aggregate queries, without loading nodes. Days
+// are as recorded in created_at, in the time zone of the writer; at most
+// days of them are returned.
+func (s *SQLiteStorage) Stats(ctx context.Context, days int) (*Stats, error) {
+	stats := &Stats{}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE parent_id IS NULL),
+			COALESCE(SUM(tokens_in), 0), COALESCE(SUM(tokens_out), 0), COALESCE(SUM(tokens_cache_read), 0)
+		FROM nodes
+	`).Scan(&stats.Nodes, &stats.DAGs, &stats.TokensIn, &stats.TokensOut, &stats.TokensCacheRead)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute stats: %w", err)
+	}
+
+	if stats.ByStatus, err = s.statsCounts(ctx, `
+		SELECT COALESCE(status, ''), COUNT(*) FROM (
+			SELECT status, ROW_NUMBER() OVER (
+				PARTITION BY COALESCE(root_id, id) ORDER BY created_at DESC, rowid DESC
+			) AS n FROM nodes
+		) WHERE n = 1
+		GROUP BY 1 ORDER BY 2 DESC, 1
+	`); err != nil {
+		return nil, err
+	}
+	if stats.BySource, err = s.statsCounts(ctx, `
+		SELECT COALESCE(
+			CASE WHEN json_valid(metadata) THEN json_extract(metadata, '$.source') END,
+			CASE WHEN forked_from_dag IS NOT NULL THEN 'fork' ELSE 'langdag' END
+		), COUNT(*)
+		FROM nodes WHERE parent_id IS NULL
+		GROUP BY 1 ORDER BY 2 DESC, 1
+	`); err != nil {
+		return nil, err
+	}
+	if stats.BusiestDays, err = s.statsCounts(ctx, `
+		SELECT substr(created_at, 1, 10), COUNT(*) FROM nodes
+		WHERE node_type IN ('user', 'assistant')
+		GROUP BY 1 ORDER BY 2 DESC, 1 DESC LIMIT ?
+	`, days); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT COALESCE(model, ''), COUNT(*), COALESCE(SUM(tokens_in), 0), COALESCE(SUM(tokens_out), 0), AVG(NULLIF(latency_ms, 0))
+		FROM nodes WHERE node_type = 'assistant'
+		GROUP BY 1 ORDER BY 2 DESC, 1
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute stats: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m ModelStats
+		var latency sql.NullFloat64
+		if err := rows.Scan(&m.Model, &m.Replies, &m.TokensIn, &m.TokensOut, &latency); err != nil {
+			return nil, fmt.Errorf("failed to compute stats: %w", err)
+		}
+		m.AvgLatencyMs = latency.Float64
+		stats.Models = append(stats.Models, m)
+	}
+	return stats, rows.Err()
+}
+
+// statsCounts runs a query returning (key, count) rows.
+func (s *SQLiteStorage) statsCounts(ctx context.Context, query string, args ...any) ([]StatsCount, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute stats: %w", err)
+	}
+	defer rows.Close()
+	var counts []StatsCount
+	for rows.Next() {
+		var c StatsCount
+		if err := rows.Scan(&c.Key, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to compute stats: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,62 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"langdag.com/langdag/types"
+)
+
+func TestStats(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+
+	day1 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	nodes := []*types.Node{
+		{ID: "r1", NodeType: types.NodeTypeUser, Content: "Hi", Status: "completed", CreatedAt: day1},
+		{ID: "a1", ParentID: "r1", RootID: "r1", Sequence: 1, NodeType: types.NodeTypeAssistant, Model: "m1", TokensIn: 10, TokensOut: 5, LatencyMs: 100, Status: "completed", CreatedAt: day1},
+		{ID: "u2", ParentID: "a1", RootID: "r1", Sequence: 2, NodeType: types.NodeTypeUser, Content: "More", Status: "completed", CreatedAt: day2},
+		{ID: "a2", ParentID: "u2", RootID: "r1", Sequence: 3, NodeType: types.NodeTypeAssistant, Model: "m1", TokensIn: 20, TokensOut: 5, LatencyMs: 300, Status: "timeout", CreatedAt: day2},
+		{ID: "r2", NodeType: types.NodeTypeUser, Content: "Fork", Status: "completed", ForkedFromDAG: "r1", CreatedAt: day2},
+		{ID: "r3", NodeType: types.NodeTypeUser, Content: "Imported", Status: "completed", Metadata: json.RawMessage(`{"source":"langgraph"}`), CreatedAt: day2},
+		{ID: "a3", ParentID: "r3", RootID: "r3", Sequence: 1, NodeType: types.NodeTypeAssistant, Model: "m2", TokensIn: 1, TokensOut: 1, Status: "completed", CreatedAt: day2},
+	}
+	for _, n := range nodes {
+		if err := store.CreateNode(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := store.Stats(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.DAGs != 3 || stats.Nodes != 7 || stats.TokensIn != 31 || stats.TokensOut != 11 {
+		t.Errorf("totals = %+v", stats)
+	}
+	wantCounts := func(name string, got []StatsCount, want ...StatsCount) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Errorf("%s = %v, want %v", name, got, want)
+			return
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s = %v, want %v", name, got, want)
+				return
+			}
+		}
+	}
+	wantCounts("ByStatus", stats.ByStatus, StatsCount{"completed", 2}, StatsCount{"timeout", 1})
+	wantCounts("BySource", stats.BySource, StatsCount{"fork", 1}, StatsCount{"langdag", 1}, StatsCount{"langgraph", 1})
+	wantCounts("BusiestDays", stats.BusiestDays, StatsCount{"2026-03-02", 5})
+	if len(stats.Models) != 2 || stats.Models[0].Model != "m1" || stats.Models[0].Replies != 2 || stats.Models[0].AvgLatencyMs != 200 {
+		t.Errorf("Models = %+v", stats.Models)
+	}
+	if stats.Models[1].AvgLatencyMs != 0 {
+		t.Errorf("m2 latency = %v, want 0 without a recorded latency", stats.Models[1].AvgLatencyMs)
+	}
+}
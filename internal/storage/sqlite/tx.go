@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"langdag.com/langdag/internal/storage"
+	"langdag.com/langdag/types"
+)
+
+// WithTx runs fn in a transaction on the writer. Content files written by
+// a transaction that rolls back are left for PruneFiles to remove.
+func (s *SQLiteStorage) WithTx(ctx context.Context, fn func(tx storage.NodeWriter) error) error {
+	return s.writer.submit(ctx, func(ctx context.Context) error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin tx: %w", err)
+		}
+		defer tx.Rollback() //nolint:errcheck
+		if err := fn(&nodeTx{s: s, tx: tx}); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// nodeTx is the NodeWriter of a WithTx transaction. Its methods run on the
+// writer already, so they use tx directly instead of submitting.
+type nodeTx struct {
+	s  *SQLiteStorage
+	tx *sql.Tx
+}
+
+func (t *nodeTx) CreateNode(ctx context.Context, node *types.Node) error {
+	content, contentFile, err := t.s.storeContent(node.Content)
+	if err == nil {
+		err = insertNode(ctx, t.tx, node, content, contentFile)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create node: %w", err)
+	}
+	return nil
+}
+
+func (t *nodeTx) IndexToolIDs(ctx context.Context, nodeID string, toolIDs []string, role string) error {
+	if len(toolIDs) == 0 {
+		return nil
+	}
+	return indexToolIDs(ctx, t.tx, nodeID, toolIDs, role)
+}
+
+func (t *nodeTx) DeleteNode(ctx context.Context, id string) error {
+	if err := deleteNode(ctx, t.tx, id); err != nil {
+		return fmt.Errorf("failed to delete node: %w", err)
+	}
+	return nil
+}
+
+// CreateAlias replaces an alias left behind by a deleted node; an alias
+// of another stored node is an error.
+func (t *nodeTx) CreateAlias(ctx context.Context, nodeID, alias string) error {
+	res, err := t.tx.ExecContext(ctx, `
+		INSERT INTO node_aliases (alias, node_id) VALUES (?, ?)
+		ON CONFLICT(alias) DO UPDATE SET node_id = excluded.node_id
+		WHERE node_aliases.node_id = excluded.node_id OR node_aliases.node_id NOT IN (SELECT id FROM nodes)
+	`, alias, nodeID)
+	if err != nil {
+		return fmt.Errorf("failed to create alias: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("failed to create alias: alias %s already exists", alias)
+	}
+	return nil
+}
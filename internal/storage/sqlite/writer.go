@@ -0,0 +1,111 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// errWriterClosed is returned for writes submitted after Close.
+var errWriterClosed = errors.New("sqlite writer is closed")
+
+// WriteQueueStats reports the state of the single-writer queue.
+type WriteQueueStats struct {
+	// Depth is the number of writes currently waiting for the writer.
+	Depth int64 `json:"depth"`
+	// MaxDepth is the highest Depth observed since the storage was opened.
+	MaxDepth int64 `json:"max_depth"`
+	// Completed is the number of writes the writer has executed.
+	Completed uint64 `json:"completed"`
+}
+
+// writeRequest is a unit of work executed by the writer goroutine.
+type writeRequest struct {
+	ctx  context.Context
+	fn   func(ctx context.Context) error
+	done chan error
+}
+
+// writeQueue serializes all writes through one goroutine. SQLite allows a
+// single writer at a time; funnelling writes through a queue avoids
+// "database is locked" errors under concurrent load, while reads keep using
+// the connection pool concurrently thanks to WAL mode.
+type writeQueue struct {
+	reqs chan writeRequest
+	quit chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+
+	depth     atomic.Int64
+	maxDepth  atomic.Int64
+	completed atomic.Uint64
+}
+
+// newWriteQueue starts the writer goroutine.
+func newWriteQueue() *writeQueue {
+	q := &writeQueue{
+		reqs: make(chan writeRequest),
+		quit: make(chan struct{}),
+	}
+	q.wg.Add(1)
+	go q.run()
+	return q
+}
+
+func (q *writeQueue) run() {
+	defer q.wg.Done()
+	for {
+		select {
+		case req := <-q.reqs:
+			q.depth.Add(-1)
+			err := req.ctx.Err()
+			if err == nil {
+				err = req.fn(req.ctx)
+			}
+			q.completed.Add(1)
+			req.done <- err
+		case <-q.quit:
+			return
+		}
+	}
+}
+
+// submit enqueues fn and waits for the writer to execute it.
+func (q *writeQueue) submit(ctx context.Context, fn func(ctx context.Context) error) error {
+	req := writeRequest{ctx: ctx, fn: fn, done: make(chan error, 1)}
+
+	depth := q.depth.Add(1)
+	for {
+		max := q.maxDepth.Load()
+		if depth <= max || q.maxDepth.CompareAndSwap(max, depth) {
+			break
+		}
+	}
+
+	select {
+	case q.reqs <- req:
+	case <-ctx.Done():
+		q.depth.Add(-1)
+		return ctx.Err()
+	case <-q.quit:
+		q.depth.Add(-1)
+		return errWriterClosed
+	}
+	return <-req.done
+}
+
+// stats returns a snapshot of the queue metrics.
+func (q *writeQueue) stats() WriteQueueStats {
+	return WriteQueueStats{
+		Depth:     q.depth.Load(),
+		MaxDepth:  q.maxDepth.Load(),
+		Completed: q.completed.Load(),
+	}
+}
+
+// close stops the writer goroutine after the in-flight write finishes.
+func (q *writeQueue) close() {
+	q.once.Do(func() { close(q.quit) })
+	q.wg.Wait()
+}
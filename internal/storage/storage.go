@@ -3,10 +3,16 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"langdag.com/langdag/types"
 )
 
+// ErrAmbiguousPrefix is returned by GetNodeByPrefix when several nodes
+// match the prefix.
+var ErrAmbiguousPrefix = errors.New("ambiguous ID prefix")
+
 // Storage defines the interface for persisting nodes.
 type Storage interface {
 	// Initialize the storage (run migrations, etc.)
@@ -18,11 +24,18 @@ type Storage interface {
 	// Node operations
 	CreateNode(ctx context.Context, node *types.Node) error
 	GetNode(ctx context.Context, id string) (*types.Node, error)
+	// GetNodeByPrefix returns the node whose ID starts with prefix,
+	// ignoring ASCII case: nil if there is none, and ErrAmbiguousPrefix if
+	// there are several.
 	GetNodeByPrefix(ctx context.Context, prefix string) (*types.Node, error)
 	GetNodeChildren(ctx context.Context, parentID string) ([]*types.Node, error)
 	GetSubtree(ctx context.Context, nodeID string) ([]*types.Node, error)
 	GetAncestors(ctx context.Context, nodeID string) ([]*types.Node, error)
 	ListRootNodes(ctx context.Context) ([]*types.Node, error)
+	// SearchNodes returns up to limit nodes whose content matches every
+	// whitespace-separated term of query (case-insensitive), best matches
+	// first.
+	SearchNodes(ctx context.Context, query string, limit int) ([]*types.Node, error)
 	UpdateNode(ctx context.Context, node *types.Node) error
 	DeleteNode(ctx context.Context, id string) error
 
@@ -35,4 +48,42 @@ type Storage interface {
 	// Tool ID index operations
 	IndexToolIDs(ctx context.Context, nodeID string, toolIDs []string, role string) error
 	GetOrphanedToolUses(ctx context.Context, ancestorIDs []string) (map[string][]string, error)
+
+	// API key operations. GetAPIKeyByHash returns nil if no key has the
+	// hash; revoked keys are returned with RevokedAt set. TouchAPIKey
+	// records the last use of a key.
+	CreateAPIKey(ctx context.Context, key *types.APIKey) error
+	GetAPIKeyByHash(ctx context.Context, hash string) (*types.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]*types.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string, at time.Time) error
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+
+	// Comment operations. ListComments returns the comments on the nodes
+	// of a DAG, oldest first. Comments are deleted with their node.
+	CreateComment(ctx context.Context, comment *types.Comment) error
+	ListComments(ctx context.Context, rootID string) ([]*types.Comment, error)
+
+	// Embedding operations. SaveEmbedding stores the embedding of a node's
+	// content by model, replacing any previous one; an empty vector marks
+	// a node with nothing to embed. Embeddings are deleted with their node
+	// and when its content changes. SearchEmbeddings returns up to limit
+	// nodes embedded by model, most similar to vector first.
+	// ListUnembeddedNodes returns up to limit nodes, oldest first, with no
+	// embedding by model; speculative nodes are left out until taken.
+	SaveEmbedding(ctx context.Context, nodeID, model string, vector []float32) error
+	SearchEmbeddings(ctx context.Context, model string, vector []float32, limit int) ([]types.EmbeddingMatch, error)
+	ListUnembeddedNodes(ctx context.Context, model string, limit int) ([]*types.Node, error)
+
+	// WithTx runs fn in a transaction: the writes fn makes through tx are
+	// saved together if it returns nil, and none is saved if it returns an
+	// error. fn must write only through tx.
+	WithTx(ctx context.Context, fn func(tx NodeWriter) error) error
+}
+
+// NodeWriter is the subset of Storage available in a WithTx transaction.
+type NodeWriter interface {
+	CreateNode(ctx context.Context, node *types.Node) error
+	IndexToolIDs(ctx context.Context, nodeID string, toolIDs []string, role string) error
+	DeleteNode(ctx context.Context, id string) error
+	CreateAlias(ctx context.Context, nodeID, alias string) error
 }
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"langdag.com/langdag/internal/tracing"
+	"langdag.com/langdag/types"
+)
+
+// tracedStorage records a span for each storage call.
+type tracedStorage struct {
+	inner Storage
+}
+
+// WithTracing wraps a Storage so each call is a span named after the
+// method, such as "storage.GetSubtree".
+func WithTracing(s Storage) Storage {
+	return &tracedStorage{inner: s}
+}
+
+func (t *tracedStorage) start(ctx context.Context, op string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "storage."+op, trace.WithAttributes(attrs...))
+}
+
+func nodeIDAttr(id string) attribute.KeyValue {
+	return attribute.String("langdag.node_id", id)
+}
+
+func (t *tracedStorage) Init(ctx context.Context) (err error) {
+	ctx, span := t.start(ctx, "Init")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.Init(ctx)
+}
+
+func (t *tracedStorage) Close() error { return t.inner.Close() }
+
+func (t *tracedStorage) CreateNode(ctx context.Context, node *types.Node) (err error) {
+	ctx, span := t.start(ctx, "CreateNode", nodeIDAttr(node.ID))
+	defer func() { tracing.End(span, err) }()
+	return t.inner.CreateNode(ctx, node)
+}
+
+func (t *tracedStorage) GetNode(ctx context.Context, id string) (_ *types.Node, err error) {
+	ctx, span := t.start(ctx, "GetNode", nodeIDAttr(id))
+	defer func() { tracing.End(span, err) }()
+	return t.inner.GetNode(ctx, id)
+}
+
+func (t *tracedStorage) GetNodeByPrefix(ctx context.Context, prefix string) (_ *types.Node, err error) {
+	ctx, span := t.start(ctx, "GetNodeByPrefix")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.GetNodeByPrefix(ctx, prefix)
+}
+
+func (t *tracedStorage) GetNodeChildren(ctx context.Context, parentID string) (_ []*types.Node, err error) {
+	ctx, span := t.start(ctx, "GetNodeChildren", nodeIDAttr(parentID))
+	defer func() { tracing.End(span, err) }()
+	return t.inner.GetNodeChildren(ctx, parentID)
+}
+
+func (t *tracedStorage) GetSubtree(ctx context.Context, nodeID string) (nodes []*types.Node, err error) {
+	ctx, span := t.start(ctx, "GetSubtree", nodeIDAttr(nodeID))
+	defer func() {
+		span.SetAttributes(attribute.Int("langdag.nodes", len(nodes)))
+		tracing.End(span, err)
+	}()
+	return t.inner.GetSubtree(ctx, nodeID)
+}
+
+func (t *tracedStorage) GetAncestors(ctx context.Context, nodeID string) (nodes []*types.Node, err error) {
+	ctx, span := t.start(ctx, "GetAncestors", nodeIDAttr(nodeID))
+	defer func() {
+		span.SetAttributes(attribute.Int("langdag.nodes", len(nodes)))
+		tracing.End(span, err)
+	}()
+	return t.inner.GetAncestors(ctx, nodeID)
+}
+
+func (t *tracedStorage) ListRootNodes(ctx context.Context) (nodes []*types.Node, err error) {
+	ctx, span := t.start(ctx, "ListRootNodes")
+	defer func() {
+		span.SetAttributes(attribute.Int("langdag.nodes", len(nodes)))
+		tracing.End(span, err)
+	}()
+	return t.inner.ListRootNodes(ctx)
+}
+
+func (t *tracedStorage) SearchNodes(ctx context.Context, query string, limit int) (_ []*types.Node, err error) {
+	ctx, span := t.start(ctx, "SearchNodes")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.SearchNodes(ctx, query, limit)
+}
+
+func (t *tracedStorage) UpdateNode(ctx context.Context, node *types.Node) (err error) {
+	ctx, span := t.start(ctx, "UpdateNode", nodeIDAttr(node.ID))
+	defer func() { tracing.End(span, err) }()
+	return t.inner.UpdateNode(ctx, node)
+}
+
+func (t *tracedStorage) DeleteNode(ctx context.Context, id string) (err error) {
+	ctx, span := t.start(ctx, "DeleteNode", nodeIDAttr(id))
+	defer func() { tracing.End(span, err) }()
+	return t.inner.DeleteNode(ctx, id)
+}
+
+func (t *tracedStorage) CreateAlias(ctx context.Context, nodeID, alias string) (err error) {
+	ctx, span := t.start(ctx, "CreateAlias", nodeIDAttr(nodeID))
+	defer func() { tracing.End(span, err) }()
+	return t.inner.CreateAlias(ctx, nodeID, alias)
+}
+
+func (t *tracedStorage) DeleteAlias(ctx context.Context, alias string) (err error) {
+	ctx, span := t.start(ctx, "DeleteAlias")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.DeleteAlias(ctx, alias)
+}
+
+func (t *tracedStorage) GetNodeByAlias(ctx context.Context, alias string) (_ *types.Node, err error) {
+	ctx, span := t.start(ctx, "GetNodeByAlias")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.GetNodeByAlias(ctx, alias)
+}
+
+func (t *tracedStorage) ListAliases(ctx context.Context, nodeID string) (_ []string, err error) {
+	ctx, span := t.start(ctx, "ListAliases", nodeIDAttr(nodeID))
+	defer func() { tracing.End(span, err) }()
+	return t.inner.ListAliases(ctx, nodeID)
+}
+
+func (t *tracedStorage) IndexToolIDs(ctx context.Context, nodeID string, toolIDs []string, role string) (err error) {
+	ctx, span := t.start(ctx, "IndexToolIDs", nodeIDAttr(nodeID))
+	defer func() { tracing.End(span, err) }()
+	return t.inner.IndexToolIDs(ctx, nodeID, toolIDs, role)
+}
+
+func (t *tracedStorage) GetOrphanedToolUses(ctx context.Context, ancestorIDs []string) (_ map[string][]string, err error) {
+	ctx, span := t.start(ctx, "GetOrphanedToolUses")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.GetOrphanedToolUses(ctx, ancestorIDs)
+}
+
+func (t *tracedStorage) CreateAPIKey(ctx context.Context, key *types.APIKey) (err error) {
+	ctx, span := t.start(ctx, "CreateAPIKey")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.CreateAPIKey(ctx, key)
+}
+
+func (t *tracedStorage) GetAPIKeyByHash(ctx context.Context, hash string) (_ *types.APIKey, err error) {
+	ctx, span := t.start(ctx, "GetAPIKeyByHash")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.GetAPIKeyByHash(ctx, hash)
+}
+
+func (t *tracedStorage) ListAPIKeys(ctx context.Context) (_ []*types.APIKey, err error) {
+	ctx, span := t.start(ctx, "ListAPIKeys")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.ListAPIKeys(ctx)
+}
+
+func (t *tracedStorage) RevokeAPIKey(ctx context.Context, id string, at time.Time) (err error) {
+	ctx, span := t.start(ctx, "RevokeAPIKey")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.RevokeAPIKey(ctx, id, at)
+}
+
+func (t *tracedStorage) TouchAPIKey(ctx context.Context, id string, at time.Time) (err error) {
+	ctx, span := t.start(ctx, "TouchAPIKey")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.TouchAPIKey(ctx, id, at)
+}
+
+func (t *tracedStorage) CreateComment(ctx context.Context, comment *types.Comment) (err error) {
+	ctx, span := t.start(ctx, "CreateComment")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.CreateComment(ctx, comment)
+}
+
+func (t *tracedStorage) ListComments(ctx context.Context, rootID string) (_ []*types.Comment, err error) {
+	ctx, span := t.start(ctx, "ListComments")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.ListComments(ctx, rootID)
+}
+
+func (t *tracedStorage) SaveEmbedding(ctx context.Context, nodeID, model string, vector []float32) (err error) {
+	ctx, span := t.start(ctx, "SaveEmbedding", nodeIDAttr(nodeID))
+	defer func() { tracing.End(span, err) }()
+	return t.inner.SaveEmbedding(ctx, nodeID, model, vector)
+}
+
+func (t *tracedStorage) SearchEmbeddings(ctx context.Context, model string, vector []float32, limit int) (_ []types.EmbeddingMatch, err error) {
+	ctx, span := t.start(ctx, "SearchEmbeddings")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.SearchEmbeddings(ctx, model, vector, limit)
+}
+
+func (t *tracedStorage) ListUnembeddedNodes(ctx context.Context, model string, limit int) (_ []*types.Node, err error) {
+	ctx, span := t.start(ctx, "ListUnembeddedNodes")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.ListUnembeddedNodes(ctx, model, limit)
+}
+
+func (t *tracedStorage) WithTx(ctx context.Context, fn func(tx NodeWriter) error) (err error) {
+	ctx, span := t.start(ctx, "WithTx")
+	defer func() { tracing.End(span, err) }()
+	return t.inner.WithTx(ctx, fn)
+}
@@ -0,0 +1,187 @@
+// Package telemetry sends anonymous CLI usage events, when the user opted
+// in: the command run, how long it took and the class of error it failed
+// with. Events never carry arguments, messages, node IDs, paths or other
+// content. The payload is documented in docs/telemetry.md.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of the Event payload.
+const SchemaVersion = 1
+
+// DefaultEndpoint receives events unless telemetry.endpoint is set.
+const DefaultEndpoint = "https://telemetry.langdag.com/v1/events"
+
+// sendTimeout bounds how long the CLI waits for the endpoint on exit.
+const sendTimeout = 2 * time.Second
+
+// Event is the payload sent after each command, as JSON.
+type Event struct {
+	Schema     int       `json:"schema"`
+	InstallID  string    `json:"install_id"` // random, see NewInstallID
+	Version    string    `json:"version"`    // langdag version
+	OS         string    `json:"os"`
+	Arch       string    `json:"arch"`
+	Command    string    `json:"command"` // e.g. "langdag apikey create"
+	DurationMs int64     `json:"duration_ms"`
+	ErrorClass string    `json:"error_class,omitempty"` // see Classify; empty on success
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// State is the opt-in recorded by `langdag telemetry enable|disable`.
+type State struct {
+	Enabled   bool   `json:"enabled"`
+	InstallID string `json:"install_id,omitempty"`
+}
+
+// StatePath returns the file holding the opt-in state.
+func StatePath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".config", "langdag", "telemetry.json")
+}
+
+// LoadState reads the opt-in state at path; a missing file is the default
+// state, opted out.
+func LoadState(path string) (State, error) {
+	var state State
+	if path == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("invalid telemetry state %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// SaveState writes the opt-in state to path.
+func SaveState(path string, state State) error {
+	if path == "" {
+		return errors.New("no home directory to store the telemetry setting")
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// NewInstallID returns a random installation ID, unrelated to the user or
+// the machine.
+func NewInstallID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Enabled reports whether events are sent, and why. The configured
+// setting (telemetry.enabled or LANGDAG_TELEMETRY_ENABLED) wins over the state
+// saved by the telemetry command; DO_NOT_TRACK=1 disables telemetry
+// whatever the rest says.
+func Enabled(configured *bool, state State) (bool, string) {
+	if v := os.Getenv("DO_NOT_TRACK"); v != "" && v != "0" {
+		return false, "DO_NOT_TRACK is set"
+	}
+	if configured != nil {
+		if *configured {
+			return true, "enabled in the configuration"
+		}
+		return false, "disabled in the configuration"
+	}
+	if state.Enabled {
+		return true, "enabled with 'langdag telemetry enable'"
+	}
+	return false, "off by default"
+}
+
+// NewEvent returns the event for a command that ran for duration and
+// failed with err, or succeeded if err is nil.
+func NewEvent(installID, version, command string, duration time.Duration, err error) Event {
+	return Event{
+		Schema:     SchemaVersion,
+		InstallID:  installID,
+		Version:    version,
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		Command:    command,
+		DurationMs: duration.Milliseconds(),
+		ErrorClass: Classify(err),
+		Timestamp:  time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// Classify returns the class of err reported in events: "timeout",
+// "canceled", "network", "not_found", "permission" or "error"; empty for
+// nil. The error's message is never sent.
+func Classify(err error) string {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	case errors.Is(err, os.ErrNotExist), strings.Contains(err.Error(), "not found"):
+		return "not_found"
+	case errors.Is(err, os.ErrPermission):
+		return "permission"
+	}
+	return "error"
+}
+
+// Send posts event to endpoint, waiting at most a couple of seconds.
+func Send(ctx context.Context, endpoint string, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint answered %s", resp.Status)
+	}
+	return nil
+}
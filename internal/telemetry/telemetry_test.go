@@ -0,0 +1,82 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnabledPrecedence(t *testing.T) {
+	t.Setenv("DO_NOT_TRACK", "")
+	on, off := true, false
+
+	if enabled, _ := Enabled(nil, State{}); enabled {
+		t.Error("telemetry is enabled by default")
+	}
+	if enabled, _ := Enabled(nil, State{Enabled: true}); !enabled {
+		t.Error("opt-in was ignored")
+	}
+	if enabled, _ := Enabled(&off, State{Enabled: true}); enabled {
+		t.Error("configuration did not override the opt-in")
+	}
+	if enabled, _ := Enabled(&on, State{}); !enabled {
+		t.Error("configuration did not enable telemetry")
+	}
+	t.Setenv("DO_NOT_TRACK", "1")
+	if enabled, _ := Enabled(&on, State{Enabled: true}); enabled {
+		t.Error("DO_NOT_TRACK was ignored")
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "langdag", "telemetry.json")
+	state, err := LoadState(path)
+	if err != nil || state.Enabled {
+		t.Fatalf("missing state = %+v, %v; want disabled", state, err)
+	}
+	want := State{Enabled: true, InstallID: NewInstallID()}
+	if err := SaveState(path, want); err != nil {
+		t.Fatal(err)
+	}
+	if state, err = LoadState(path); err != nil || state != want {
+		t.Errorf("state = %+v, %v; want %+v", state, err, want)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	for err, want := range map[error]string{
+		nil:                                    "",
+		context.DeadlineExceeded:               "timeout",
+		fmt.Errorf("read: %w", os.ErrNotExist): "not_found",
+		errors.New("node not found: 1234"):     "not_found",
+		errors.New("invalid --older-than"):     "error",
+	} {
+		if got := Classify(err); got != want {
+			t.Errorf("Classify(%v) = %q, want %q", err, got, want)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	event := NewEvent("abc", "0.2.0", "langdag show", 1500*time.Millisecond, errors.New("node not found: secret-id"))
+	if err := Send(context.Background(), srv.URL, event); err != nil {
+		t.Fatal(err)
+	}
+	if got.Schema != SchemaVersion || got.Command != "langdag show" || got.DurationMs != 1500 || got.ErrorClass != "not_found" {
+		t.Errorf("received %+v", got)
+	}
+}
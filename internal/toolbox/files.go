@@ -0,0 +1,128 @@
+package toolbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"langdag.com/langdag/types"
+)
+
+// fileRoot resolves paths given by the model inside a root directory.
+type fileRoot struct {
+	root      string // absolute, symlinks resolved
+	maxOutput int
+}
+
+func newFileTools(opts FilesOptions, maxOutput int) (Tool, Tool, error) {
+	root, err := filepath.Abs(opts.Root)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("tools.files.root: %w", err)
+	}
+	r := &fileRoot{root: root, maxOutput: maxOutput}
+	if !opts.Write {
+		return fileRead{r}, nil, nil
+	}
+	return fileRead{r}, fileWrite{r}, nil
+}
+
+// resolve returns the absolute path of name, relative to the root, or an
+// error if it leaves the root, through ".." or a symbolic link.
+func (r *fileRoot) resolve(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("%w: path is required", errInput)
+	}
+	path := filepath.Join(r.root, filepath.FromSlash(strings.TrimPrefix(name, "/")))
+	// Resolve links in the deepest existing ancestor, for new files.
+	existing, rest := path, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			path = filepath.Join(resolved, rest)
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) || existing == r.root {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = filepath.Dir(existing)
+	}
+	if rel, err := filepath.Rel(r.root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside the allowed directory", name)
+	}
+	return path, nil
+}
+
+// fileRead reads a file under the root.
+type fileRead struct{ *fileRoot }
+
+func (fileRead) Definition() types.ToolDefinition {
+	return types.ToolDefinition{
+		Name:        "file_read",
+		Description: "Read a text file from the workspace directory.",
+		InputSchema: schema("path", "Path of the file, relative to the workspace directory"),
+	}
+}
+
+func (t fileRead) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var in struct {
+		Path string `json:"path"`
+	}
+	if err := decodeInput(input, &in); err != nil {
+		return "", err
+	}
+	path, err := t.resolve(in.Path)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s: %w", in.Path, errors.Unwrap(err))
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, int64(t.maxOutput)+1))
+	if err != nil {
+		return "", err
+	}
+	return truncate(string(data), t.maxOutput), nil
+}
+
+// fileWrite writes a file under the root, creating its directories.
+type fileWrite struct{ *fileRoot }
+
+func (fileWrite) Definition() types.ToolDefinition {
+	return types.ToolDefinition{
+		Name:        "file_write",
+		Description: "Write a text file in the workspace directory, replacing it if it exists.",
+		InputSchema: schema("path", "Path of the file, relative to the workspace directory", "content", "Content of the file"),
+	}
+}
+
+func (t fileWrite) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var in struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}
+	if err := decodeInput(input, &in); err != nil {
+		return "", err
+	}
+	path, err := t.resolve(in.Path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(in.Content), 0644); err != nil {
+		return "", fmt.Errorf("cannot write %s: %w", in.Path, errors.Unwrap(err))
+	}
+	return fmt.Sprintf("wrote %d bytes to %s", len(in.Content), in.Path), nil
+}
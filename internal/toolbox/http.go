@@ -0,0 +1,95 @@
+package toolbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"langdag.com/langdag/types"
+)
+
+// httpFetch GETs URLs of the allowed hosts.
+type httpFetch struct {
+	allowed   []string
+	client    *http.Client
+	maxOutput int
+}
+
+func newHTTPFetch(opts HTTPFetchOptions, maxOutput int) *httpFetch {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultFetchTimeout
+	}
+	t := &httpFetch{allowed: opts.AllowedHosts, maxOutput: maxOutput}
+	t.client = &http.Client{
+		Timeout: opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return t.check(req.URL)
+		},
+	}
+	return t
+}
+
+func (t *httpFetch) Definition() types.ToolDefinition {
+	return types.ToolDefinition{
+		Name:        "http_fetch",
+		Description: "Fetch a web page or API response with an HTTP GET request. Only some hosts are allowed.",
+		InputSchema: schema("url", "The http or https URL to fetch"),
+	}
+}
+
+func (t *httpFetch) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var in struct {
+		URL string `json:"url"`
+	}
+	if err := decodeInput(input, &in); err != nil {
+		return "", err
+	}
+	u, err := url.Parse(in.URL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errInput, err)
+	}
+	if err := t.check(u); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(t.maxOutput)+1))
+	if err != nil {
+		return "", err
+	}
+	text := truncate(string(body), t.maxOutput)
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("%s: %s", resp.Status, text)
+	}
+	return text, nil
+}
+
+// check returns an error unless u is an http(s) URL of an allowed host.
+func (t *httpFetch) check(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: only http and https URLs can be fetched", errInput)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range t.allowed {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %q is not allowed", host)
+}
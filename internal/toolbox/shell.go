@@ -0,0 +1,126 @@
+package toolbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"langdag.com/langdag/types"
+)
+
+// shell runs allowed programs without a shell, so that the model cannot
+// chain commands, redirect output or expand variables. Arguments naming a
+// path outside its directory are rejected; other arguments are passed
+// unchecked, so programs that run others (find -exec, git -c, xargs) must
+// not be allowed.
+type shell struct {
+	allowed   []string
+	opts      ShellOptions
+	dir       *fileRoot // opts.Dir, to check path arguments
+	maxOutput int
+}
+
+func newShell(opts ShellOptions, maxOutput int) (*shell, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("tools.shell.dir is required")
+	}
+	if info, err := os.Stat(opts.Dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("tools.shell.dir %s is not a directory", opts.Dir)
+	}
+	dir, err := filepath.Abs(opts.Dir)
+	if err == nil {
+		dir, err = filepath.EvalSymlinks(dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tools.shell.dir: %w", err)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultShellTimeout
+	}
+	return &shell{allowed: opts.AllowedCommands, opts: opts, dir: &fileRoot{root: dir}, maxOutput: maxOutput}, nil
+}
+
+func (t *shell) Definition() types.ToolDefinition {
+	data, _ := json.Marshal(map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"command": map[string]any{"type": "string", "enum": t.allowed, "description": "Program to run"},
+			"args":    map[string]any{"type": "array", "items": map[string]string{"type": "string"}, "description": "Arguments, passed as is (no shell expansion); paths must be relative and stay in the working directory, and option values containing a path must be separate arguments"},
+		},
+		"required": []string{"command"},
+	})
+	return types.ToolDefinition{
+		Name:        "shell",
+		Description: "Run a program in the working directory and return its output. Pipes, redirections and variables are not supported.",
+		InputSchema: data,
+	}
+}
+
+func (t *shell) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var in struct {
+		Command string   `json:"command"`
+		Args    []string `json:"args"`
+	}
+	if err := decodeInput(input, &in); err != nil {
+		return "", err
+	}
+	if !slices.Contains(t.allowed, in.Command) {
+		return "", fmt.Errorf("command %q is not allowed", in.Command)
+	}
+	for _, arg := range in.Args {
+		if t.escapesDir(arg) {
+			return "", fmt.Errorf("argument %q is outside the working directory", arg)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, t.opts.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, in.Command, in.Args...)
+	cmd.Dir = t.opts.Dir
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HOME=" + t.opts.Dir, "LANG=C.UTF-8"}
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	err := cmd.Run()
+	text := truncate(out.String(), t.maxOutput)
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("timed out after %s: %s", t.opts.Timeout, text)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, text)
+	}
+	return text, nil
+}
+
+// escapesDir reports whether arg names a path that leaves the working
+// directory: an absolute path, a home-relative one, or one climbing out
+// with ".." or through a symbolic link. Each part of arg separated by "=",
+// ":" or "," is checked, so that --opt=/etc and key=/etc are caught too.
+// Options can't carry a path attached (-o/etc/x): it would be taken for
+// the option's name, so any slash in one is rejected.
+func (t *shell) escapesDir(arg string) bool {
+	if strings.Contains(arg, "~") {
+		return true
+	}
+	parts := strings.FieldsFunc(arg, func(r rune) bool { return r == '=' || r == ':' || r == ',' })
+	for i, part := range parts {
+		if i == 0 && strings.HasPrefix(part, "-") {
+			if strings.ContainsAny(part, `/\`) {
+				return true
+			}
+			continue
+		}
+		if filepath.IsAbs(part) || strings.HasPrefix(part, "/") {
+			return true
+		}
+		if _, err := t.dir.resolve(part); err != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+// Package toolbox implements the built-in tools the server runs itself when
+// a model calls them: http_fetch, shell, file_read and file_write. Each one
+// is off unless its allow-list is configured.
+package toolbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"langdag.com/langdag/types"
+)
+
+// Tool is a built-in tool.
+type Tool interface {
+	// Definition is the definition sent to the model.
+	Definition() types.ToolDefinition
+	// Run calls the tool with the input generated by the model and returns
+	// its result. Errors are reported to the model as failed tool results.
+	Run(ctx context.Context, input json.RawMessage) (string, error)
+}
+
+// Options enables built-in tools. A tool is enabled when its allow-list is
+// set.
+type Options struct {
+	HTTPFetch HTTPFetchOptions
+	Shell     ShellOptions
+	Files     FilesOptions
+
+	// MaxOutputBytes truncates tool results (default 64 KiB).
+	MaxOutputBytes int
+}
+
+// HTTPFetchOptions configures http_fetch, which GETs a URL.
+type HTTPFetchOptions struct {
+	// AllowedHosts are the hosts that may be fetched, e.g. "example.com"
+	// or "*.example.com" for its subdomains.
+	AllowedHosts []string
+	Timeout      time.Duration // default 10s
+}
+
+// ShellOptions configures shell, which runs a command without a shell, in
+// Dir, with a minimal environment. Path arguments must stay in Dir; other
+// arguments are not checked.
+type ShellOptions struct {
+	// AllowedCommands are the programs that may be run, by name.
+	AllowedCommands []string
+	Dir             string        // working directory; required
+	Timeout         time.Duration // default 30s
+}
+
+// FilesOptions configures file_read and file_write, which access the files
+// under Root.
+type FilesOptions struct {
+	Root  string
+	Write bool // enables file_write
+}
+
+const (
+	defaultMaxOutputBytes = 64 << 10
+	defaultFetchTimeout   = 10 * time.Second
+	defaultShellTimeout   = 30 * time.Second
+)
+
+// Toolbox holds the enabled built-in tools.
+type Toolbox struct {
+	tools map[string]Tool
+}
+
+// New returns the toolbox of the tools enabled by opts.
+func New(opts Options) (*Toolbox, error) {
+	if opts.MaxOutputBytes <= 0 {
+		opts.MaxOutputBytes = defaultMaxOutputBytes
+	}
+	b := &Toolbox{tools: make(map[string]Tool)}
+	if len(opts.HTTPFetch.AllowedHosts) > 0 {
+		b.tools["http_fetch"] = newHTTPFetch(opts.HTTPFetch, opts.MaxOutputBytes)
+	}
+	if len(opts.Shell.AllowedCommands) > 0 {
+		shell, err := newShell(opts.Shell, opts.MaxOutputBytes)
+		if err != nil {
+			return nil, err
+		}
+		b.tools["shell"] = shell
+	}
+	if opts.Files.Root != "" {
+		read, write, err := newFileTools(opts.Files, opts.MaxOutputBytes)
+		if err != nil {
+			return nil, err
+		}
+		b.tools["file_read"] = read
+		if write != nil {
+			b.tools["file_write"] = write
+		}
+	}
+	return b, nil
+}
+
+// Get returns the enabled tool called name.
+func (b *Toolbox) Get(name string) (Tool, bool) {
+	if b == nil {
+		return nil, false
+	}
+	t, ok := b.tools[name]
+	return t, ok
+}
+
+// Names returns the names of the enabled tools, sorted.
+func (b *Toolbox) Names() []string {
+	if b == nil {
+		return nil
+	}
+	names := make([]string, 0, len(b.tools))
+	for name := range b.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// errInput is returned for tool input that does not match the tool's
+// schema.
+var errInput = errors.New("invalid input")
+
+// decodeInput decodes the tool input into v.
+func decodeInput(input json.RawMessage, v any) error {
+	if err := json.Unmarshal(input, v); err != nil {
+		return fmt.Errorf("%w: %v", errInput, err)
+	}
+	return nil
+}
+
+// truncate cuts s to max bytes, saying so.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + fmt.Sprintf("\n[truncated: %d more bytes]", len(s)-max)
+}
+
+// schema returns a JSON schema for an object with required string
+// properties, described by props (name, description pairs).
+func schema(props ...string) json.RawMessage {
+	properties := make(map[string]any)
+	var required []string
+	for i := 0; i+1 < len(props); i += 2 {
+		properties[props[i]] = map[string]string{"type": "string", "description": props[i+1]}
+		required = append(required, props[i])
+	}
+	data, _ := json.Marshal(map[string]any{"type": "object", "properties": properties, "required": required})
+	return data
+}
@@ -0,0 +1,173 @@
+package toolbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, b *Toolbox, name, input string) (string, error) {
+	t.Helper()
+	tool, ok := b.Get(name)
+	if !ok {
+		t.Fatalf("tool %s is not enabled", name)
+	}
+	return tool.Run(context.Background(), json.RawMessage(input))
+}
+
+func TestNewEnablesConfiguredTools(t *testing.T) {
+	b, err := New(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := b.Names(); len(names) != 0 {
+		t.Errorf("no allow-list: tools = %v, want none", names)
+	}
+
+	dir := t.TempDir()
+	b, err = New(Options{
+		HTTPFetch: HTTPFetchOptions{AllowedHosts: []string{"example.com"}},
+		Shell:     ShellOptions{AllowedCommands: []string{"echo"}, Dir: dir},
+		Files:     FilesOptions{Root: dir, Write: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(b.Names(), ","); got != "file_read,file_write,http_fetch,shell" {
+		t.Errorf("tools = %s", got)
+	}
+	for _, name := range b.Names() {
+		tool, _ := b.Get(name)
+		if def := tool.Definition(); def.Name != name || def.Description == "" || !json.Valid(def.InputSchema) {
+			t.Errorf("definition of %s = %+v", name, def)
+		}
+	}
+
+	if _, err := New(Options{Shell: ShellOptions{AllowedCommands: []string{"ls"}}}); err == nil {
+		t.Error("shell without dir: expected an error")
+	}
+}
+
+func TestHTTPFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "hello from the server")
+	}))
+	defer server.Close()
+	host := mustHost(t, server.URL)
+
+	b, err := New(Options{HTTPFetch: HTTPFetchOptions{AllowedHosts: []string{host}}, MaxOutputBytes: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := run(t, b, "http_fetch", fmt.Sprintf(`{"url":%q}`, server.URL))
+	if err != nil || !strings.HasPrefix(out, "hello from") || !strings.Contains(out, "[truncated") {
+		t.Errorf("fetch = %q, %v; want a truncated body", out, err)
+	}
+	if _, err := run(t, b, "http_fetch", fmt.Sprintf(`{"url":%q}`, server.URL+"/missing")); err == nil {
+		t.Error("404: expected an error")
+	}
+	for _, u := range []string{"http://example.com/", "file:///etc/passwd"} {
+		if _, err := run(t, b, "http_fetch", fmt.Sprintf(`{"url":%q}`, u)); err == nil {
+			t.Errorf("%s: expected an error", u)
+		}
+	}
+}
+
+func TestHostAllowList(t *testing.T) {
+	f := newHTTPFetch(HTTPFetchOptions{AllowedHosts: []string{"example.com", "*.wikipedia.org"}}, 100)
+	for rawURL, allowed := range map[string]bool{
+		"https://example.com/a":          true,
+		"https://EXAMPLE.com/a":          true,
+		"https://api.example.com/":       false,
+		"https://en.wikipedia.org/wiki/": true,
+		"https://wikipedia.org/":         false,
+		"https://evilwikipedia.org/":     false,
+		"ftp://example.com/":             false,
+	} {
+		u, _ := url.Parse(rawURL)
+		if err := f.check(u); (err == nil) != allowed {
+			t.Errorf("%s: allowed = %v, want %v", rawURL, err == nil, allowed)
+		}
+	}
+}
+
+func TestShell(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), nil, 0644)
+	os.Symlink(t.TempDir(), filepath.Join(dir, "link"))
+	b, err := New(Options{Shell: ShellOptions{AllowedCommands: []string{"echo", "ls"}, Dir: dir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := run(t, b, "shell", `{"command":"echo","args":["$HOME; rm -rf /"]}`); err != nil || out != "$HOME; rm -rf /\n" {
+		t.Errorf("echo = %q, %v; want the argument as is", out, err)
+	}
+	if out, err := run(t, b, "shell", `{"command":"ls","args":["a.txt"]}`); err != nil || out != "a.txt\n" {
+		t.Errorf("ls = %q, %v; want the working directory", out, err)
+	}
+	if out, err := run(t, b, "shell", `{"command":"ls","args":["-a","./a.txt"]}`); err != nil || out != "./a.txt\n" {
+		t.Errorf("ls ./a.txt = %q, %v", out, err)
+	}
+	for _, arg := range []string{"/etc", "..", "sub/../../x", "~/x", "--directory=/etc", "-o/etc/x", "-I../..", "if=/etc/passwd", "link", "link/x"} {
+		if _, err := run(t, b, "shell", fmt.Sprintf(`{"command":"ls","args":[%q]}`, arg)); err == nil || !strings.Contains(err.Error(), "outside the working directory") {
+			t.Errorf("ls %s: err = %v, want outside the working directory", arg, err)
+		}
+	}
+	if _, err := run(t, b, "shell", `{"command":"rm","args":["a.txt"]}`); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("rm: err = %v, want not allowed", err)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644)
+	os.Symlink(outside, filepath.Join(root, "link"))
+
+	b, err := New(Options{Files: FilesOptions{Root: root, Write: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(t, b, "file_write", `{"path":"notes/todo.md","content":"- test"}`); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if out, err := run(t, b, "file_read", `{"path":"/notes/todo.md"}`); err != nil || out != "- test" {
+		t.Errorf("read = %q, %v", out, err)
+	}
+	for _, path := range []string{"../secret.txt", "link/secret.txt", "link/new.txt", "notes/../../x"} {
+		if _, err := run(t, b, "file_read", fmt.Sprintf(`{"path":%q}`, path)); err == nil {
+			t.Errorf("read %s: expected an error", path)
+		}
+		if _, err := run(t, b, "file_write", fmt.Sprintf(`{"path":%q,"content":"x"}`, path)); err == nil {
+			t.Errorf("write %s: expected an error", path)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); err == nil {
+		t.Error("file written outside the root")
+	}
+
+	readOnly, _ := New(Options{Files: FilesOptions{Root: root}})
+	if _, ok := readOnly.Get("file_write"); ok {
+		t.Error("file_write enabled without write")
+	}
+}
+
+func mustHost(t *testing.T, rawURL string) string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Hostname()
+}
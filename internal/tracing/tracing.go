@@ -0,0 +1,109 @@
+// Package tracing sets up OpenTelemetry tracing for langdag.
+//
+// The API server, the conversation manager, storage and providers create
+// spans with Tracer. Until Setup (or the embedding program) installs a
+// tracer provider, the spans are no-ops.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"langdag.com/langdag/internal/config"
+)
+
+// instrumentationName names the tracer of every langdag span.
+const instrumentationName = "langdag.com/langdag"
+
+// Exporters accepted by Setup.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Tracer returns the tracer langdag's spans are created with.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Enabled reports whether cfg exports spans.
+func Enabled(cfg config.TracingConfig) bool {
+	return cfg.Exporter != "" && cfg.Exporter != ExporterNone
+}
+
+// Setup installs a global tracer provider exporting spans as cfg says, and
+// the W3C trace context propagator, so incoming traceparent headers are
+// continued. It returns a function flushing and stopping the provider; when
+// tracing is disabled, it installs nothing and the function does nothing.
+func Setup(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if !Enabled(cfg) {
+		return noop, nil
+	}
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		return noop, fmt.Errorf("tracing.sample_rate must be between 0 and 1, got %v", cfg.SampleRate)
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if strings.Contains(cfg.Endpoint, "://") {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		} else if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return noop, fmt.Errorf("unknown tracing exporter %q (want %s, %s or %s)", cfg.Exporter, ExporterOTLP, ExporterStdout, ExporterNone)
+	}
+	if err != nil {
+		return noop, fmt.Errorf("failed to create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "langdag"
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return noop, err
+	}
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRate > 0 && cfg.SampleRate < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRate)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp.Shutdown, nil
+}
+
+// End records err on span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
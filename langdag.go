@@ -5,13 +5,14 @@ package langdag
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"langdag.com/langdag/internal/archive"
 	"langdag.com/langdag/internal/conversation"
 	"langdag.com/langdag/internal/models"
 	internalprovider "langdag.com/langdag/internal/provider"
@@ -20,6 +21,7 @@ import (
 	openaiprovider "langdag.com/langdag/internal/provider/openai"
 	internalstorage "langdag.com/langdag/internal/storage"
 	"langdag.com/langdag/internal/storage/sqlite"
+	"langdag.com/langdag/internal/toolbox"
 	"langdag.com/langdag/types"
 )
 
@@ -107,6 +109,13 @@ type Config struct {
 	// Defaults to "$HOME/.config/langdag/langdag.db"
 	StoragePath string
 
+	// FileThreshold is the size in bytes above which node content is
+	// stored in files, in the directory "<StoragePath>-files", instead of
+	// the database. Reads are unaffected; PruneFiles removes the files of
+	// deleted nodes. Defaults to 256 KiB; negative keeps all content in
+	// the database.
+	FileThreshold int
+
 	// Provider is the default LLM provider to use.
 	// Valid values: "anthropic", "openai", "gemini", "grok", "openrouter", "ollama",
 	// "anthropic-vertex", "anthropic-bedrock", "openai-azure", "gemini-vertex"
@@ -176,6 +185,136 @@ type Config struct {
 
 	// RetryConfig configures retry behavior.
 	RetryConfig *RetryConfig
+
+	// DefaultMaxTokens is the max_tokens used when a prompt does not set
+	// WithMaxTokens. Defaults to 16384.
+	DefaultMaxTokens int
+
+	// ModelMaxTokens overrides DefaultMaxTokens for specific model IDs.
+	ModelMaxTokens map[string]int
+
+	// GlobalSystemPrompt is layered before every conversation's own system
+	// prompt (separated by a blank line) on each generation. Assistant nodes
+	// record the combined prompt they were generated with.
+	GlobalSystemPrompt string
+
+	// Language is the language replies are asked to be written in when
+	// neither the prompt (WithLanguage) nor the conversation sets one, as
+	// an ISO 639-1 code or an English name. Replies detected to be in
+	// another language are generated again, once, with a stricter
+	// instruction.
+	Language string
+
+	// Metadata controls how per-request metadata set with WithUserID is
+	// forwarded to providers (optional).
+	Metadata *MetadataConfig
+
+	// Presets defines named model/system prompt/temperature bundles that
+	// prompts can select with WithPreset.
+	Presets map[string]Preset
+
+	// InjectionScan enables scanning tool results for suspected prompt
+	// injection before they are sent to the model (optional).
+	InjectionScan *InjectionScanConfig
+
+	// ArchiveLocation is where Archive moves old DAGs and where archived
+	// DAGs are rehydrated from: an s3://bucket/prefix, gs://bucket/prefix or
+	// local directory (optional).
+	ArchiveLocation string
+
+	// Classifier enables tagging DAGs by topic in the background after
+	// their first reply (optional).
+	Classifier *ClassifierConfig
+
+	// Titles enables asking the model for a title after the first exchange
+	// of each conversation (optional).
+	Titles *TitleConfig
+
+	// BuiltinTools enables built-in tools, listed by name in WithTools
+	// (e.g. ToolDefinition{Name: "http_fetch"}) and run by the client
+	// itself when the model calls them (optional).
+	BuiltinTools *BuiltinToolsConfig
+
+	// IDFormat is the format of new node IDs: "uuid" (default) or "short",
+	// 12-character base32 IDs that sort by creation time. Existing IDs of
+	// either format are accepted.
+	IDFormat string
+
+	// Pricing sets the price of models by ID, used by Usage for
+	// generations without a recorded cost (optional).
+	Pricing map[string]ModelPrice
+
+	// Tracing records OpenTelemetry spans for generations, provider calls
+	// and storage calls, using the global tracer provider the program
+	// installs (optional).
+	Tracing bool
+
+	// GenerationTimeout is the longest a generation may run; the tool loop
+	// of a prompt answered by built-in tools counts as one generation. A
+	// generation still running is stopped and its partial reply saved with
+	// status "timeout" (optional; 0 for no limit).
+	GenerationTimeout time.Duration
+
+	// TrashDir, when set, receives a JSON export of the DAG of every node
+	// deleted with DeleteNode, so deletions can be undone with an import
+	// (optional). Exports older than TrashRetention (default 30 days) are
+	// removed after each deletion.
+	TrashDir       string
+	TrashRetention time.Duration
+
+	// Glossary defines project terms. The definitions of the terms used in
+	// a message are added to the system prompt of its reply, and the
+	// matched terms are recorded in the message's metadata (optional).
+	Glossary []GlossaryEntry
+
+	// Embeddings enables embedding prompts and replies in the background,
+	// for SemanticSearch (optional).
+	Embeddings *EmbeddingConfig
+}
+
+// EmbeddingConfig configures the embeddings of node content. Model is
+// required when Enabled is set.
+type EmbeddingConfig = conversation.EmbeddingOptions
+
+// ErrEmbeddingsDisabled is returned by SemanticSearch and EmbedMissing
+// when Config.Embeddings is not enabled.
+var ErrEmbeddingsDisabled = conversation.ErrEmbeddingsDisabled
+
+// TitleConfig configures model-generated conversation titles.
+type TitleConfig = conversation.TitleOptions
+
+// BuiltinToolsConfig enables the built-in tools http_fetch, shell,
+// file_read and file_write, each one off unless its allow-list is set.
+type BuiltinToolsConfig = toolbox.Options
+
+// ClassifierConfig configures automatic topic tagging: keyword rules and an
+// optional cheap model. Tagged roots have Tags set.
+type ClassifierConfig = conversation.ClassifierOptions
+
+// InjectionScanConfig configures the prompt-injection scanner. Flagged user
+// nodes get status "flagged" with the warnings in their metadata. With
+// RequireConfirmation, PromptFrom returns an *InjectionError unless the
+// prompt uses WithInjectionConfirmed.
+type InjectionScanConfig = conversation.InjectionScanOptions
+
+// InjectionError is returned when tool results look like a prompt-injection
+// attempt and confirmation is required.
+type InjectionError = conversation.InjectionError
+
+// GlossaryEntry is a glossary term with its preferred translation or
+// definition.
+type GlossaryEntry = conversation.GlossaryEntry
+
+// Preset is a named bundle of prompt parameters. Options passed explicitly
+// alongside WithPreset take precedence over the preset's values.
+type Preset = conversation.Preset
+
+// MetadataConfig controls how per-request metadata is forwarded to providers.
+type MetadataConfig struct {
+	// HashUserIDs replaces user IDs with a hex SHA-256 digest of
+	// UserIDSalt+userID before they are sent to the provider.
+	HashUserIDs bool
+	UserIDSalt  string
 }
 
 // RemoteModelCatalogConfig configures an explicit runtime fetch of the
@@ -305,6 +444,7 @@ func New(cfg Config) (*Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("langdag: failed to open storage: %w", err)
 	}
+	store.SetFileThreshold(cfg.FileThreshold)
 
 	if err := store.Init(ctx); err != nil {
 		store.Close()
@@ -318,7 +458,71 @@ func New(cfg Config) (*Client, error) {
 		return nil, fmt.Errorf("langdag: failed to create provider: %w", err)
 	}
 
-	convMgr := conversation.NewManager(store, prov)
+	var managerStore internalstorage.Storage = store
+	managerProv := prov
+	if cfg.Tracing {
+		managerStore = internalstorage.WithTracing(managerStore)
+		managerProv = internalprovider.WithTracing(managerProv)
+	}
+	convMgr := conversation.NewManager(managerStore, managerProv)
+	if cfg.Metadata != nil {
+		convMgr.SetMetadataOptions(conversation.MetadataOptions{
+			HashUserIDs: cfg.Metadata.HashUserIDs,
+			UserIDSalt:  cfg.Metadata.UserIDSalt,
+		})
+	}
+	convMgr.SetMaxTokensOptions(conversation.MaxTokensOptions{
+		Default:  cfg.DefaultMaxTokens,
+		PerModel: cfg.ModelMaxTokens,
+	})
+	convMgr.SetPresets(cfg.Presets)
+	convMgr.SetGlobalSystemPrompt(cfg.GlobalSystemPrompt)
+	convMgr.SetDefaultLanguage(cfg.Language)
+	if err := convMgr.SetGlossary(cfg.Glossary); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("langdag: %w", err)
+	}
+	if cfg.InjectionScan != nil {
+		if err := convMgr.SetInjectionScanOptions(*cfg.InjectionScan); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("langdag: %w", err)
+		}
+	}
+	if cfg.Classifier != nil {
+		convMgr.SetClassifierOptions(*cfg.Classifier)
+	}
+	if cfg.Titles != nil {
+		convMgr.SetTitleOptions(*cfg.Titles)
+	}
+	if cfg.Embeddings != nil {
+		if err := convMgr.SetEmbeddingOptions(*cfg.Embeddings); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("langdag: %w", err)
+		}
+	}
+	if cfg.BuiltinTools != nil {
+		builtinTools, err := toolbox.New(*cfg.BuiltinTools)
+		if err != nil {
+			store.Close()
+			return nil, fmt.Errorf("langdag: %w", err)
+		}
+		convMgr.SetToolbox(builtinTools)
+	}
+	convMgr.SetPricing(cfg.Pricing)
+	convMgr.SetGenerationTimeout(cfg.GenerationTimeout)
+	convMgr.SetTrashOptions(conversation.TrashOptions{Dir: cfg.TrashDir, Retention: cfg.TrashRetention})
+	if err := convMgr.SetIDFormat(cfg.IDFormat); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("langdag: %w", err)
+	}
+	if cfg.ArchiveLocation != "" {
+		archiveStore, err := archive.Open(ctx, cfg.ArchiveLocation)
+		if err != nil {
+			store.Close()
+			return nil, fmt.Errorf("langdag: failed to open archive: %w", err)
+		}
+		convMgr.SetArchiveStore(archiveStore)
+	}
 
 	return &Client{
 		store:   store,
@@ -346,6 +550,7 @@ func WithRetry(p Provider, cfg RetryConfig) Provider {
 
 // Close releases all resources held by the client.
 func (c *Client) Close() error {
+	c.convMgr.Wait()
 	return c.store.Close()
 }
 
@@ -359,6 +564,12 @@ func (c *Client) Provider() Provider {
 	return c.prov
 }
 
+// SetPresets replaces the presets available to WithPreset. Clients built
+// with New get them from Config.Presets; this is mainly for NewWithDeps.
+func (c *Client) SetPresets(presets map[string]Preset) {
+	c.convMgr.SetPresets(presets)
+}
+
 // PromptOption configures a prompt request.
 type PromptOption func(*promptOptions)
 
@@ -371,6 +582,11 @@ type promptOptions struct {
 	maxTurns             int
 	tools                []types.ToolDefinition
 	think                *bool
+	userID               string
+	temperature          *float64
+	language             string
+	preset               string
+	injectionConfirmed   bool
 }
 
 // WithModel sets the model for the prompt.
@@ -396,7 +612,9 @@ func WithSystemPrompt(prompt string) PromptOption {
 	}
 }
 
-// WithMaxTokens sets the max tokens for the response.
+// WithMaxTokens sets the max tokens for the response, overriding
+// Config.DefaultMaxTokens and Config.ModelMaxTokens. The value is clamped to
+// the model's maximum output when the provider reports one.
 func WithMaxTokens(n int) PromptOption {
 	return func(o *promptOptions) {
 		o.maxTokens = n
@@ -442,6 +660,49 @@ func WithThink(enabled bool) PromptOption {
 	}
 }
 
+// WithUserID attaches an end-user identifier to the request. It is forwarded
+// to providers that support it (Anthropic metadata.user_id, OpenAI user) for
+// abuse monitoring, hashed first when Config.Metadata.HashUserIDs is set.
+func WithUserID(userID string) PromptOption {
+	return func(o *promptOptions) {
+		o.userID = userID
+	}
+}
+
+// WithTemperature sets the sampling temperature. Omitting this option leaves
+// it to the provider default.
+func WithTemperature(t float64) PromptOption {
+	return func(o *promptOptions) {
+		o.temperature = &t
+	}
+}
+
+// WithLanguage asks for a reply in lang, an ISO 639-1 code or an English
+// name. A conversation started with it keeps the language for later
+// prompts.
+func WithLanguage(lang string) PromptOption {
+	return func(o *promptOptions) {
+		o.language = lang
+	}
+}
+
+// WithPreset applies the named preset from Config.Presets. Model, system
+// prompt and temperature options given explicitly override the preset.
+// Prompting with an unknown preset returns an error.
+func WithPreset(name string) PromptOption {
+	return func(o *promptOptions) {
+		o.preset = name
+	}
+}
+
+// WithInjectionConfirmed sends the message even if the prompt-injection
+// scanner flags its tool results. The node is still marked as flagged.
+func WithInjectionConfirmed() PromptOption {
+	return func(o *promptOptions) {
+		o.injectionConfirmed = true
+	}
+}
+
 // PromptResult holds the result of a prompt call.
 //
 // The NodeID and Content fields are written by a background goroutine as the
@@ -509,6 +770,11 @@ type StreamChunk struct {
 	// Set when Done=true.
 	StopReason string
 
+	// TimedOut reports, when Done=true, that Config.GenerationTimeout cut
+	// the generation short. The partial reply, if any, is saved with status
+	// "timeout"; otherwise Error is ErrGenerationTimeout.
+	TimedOut bool
+
 	Usage           *types.Usage
 	ModelResolution *types.ModelResolutionMetadata
 	NormalizedUsage *types.NormalizedUsage
@@ -519,7 +785,11 @@ type StreamChunk struct {
 // Prompt starts a new conversation with the given message.
 // Returns a PromptResult with the streaming response.
 func (c *Client) Prompt(ctx context.Context, message string, opts ...PromptOption) (*PromptResult, error) {
-	o := applyOptions(opts)
+	o, err := c.applyOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx = o.context(ctx)
 	events, err := c.convMgr.PromptWithAPIProtocol(ctx, message, o.model, o.apiProtocolID, o.systemPrompt, o.tools, o.think, o.maxTokens, o.maxOutputGroupTokens)
 	if err != nil {
 		return nil, err
@@ -529,9 +799,15 @@ func (c *Client) Prompt(ctx context.Context, message string, opts ...PromptOptio
 	return result, nil
 }
 
-// PromptFrom continues a conversation from an existing node.
+// PromptFrom continues a conversation from an existing node. The model
+// defaults to the conversation's; WithModel switches to another one, which
+// later prompts on the branch keep.
 func (c *Client) PromptFrom(ctx context.Context, nodeID string, message string, opts ...PromptOption) (*PromptResult, error) {
-	o := applyOptions(opts)
+	o, err := c.resolveOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx = o.context(ctx)
 	events, err := c.convMgr.PromptFromWithAPIProtocol(ctx, nodeID, message, o.model, o.apiProtocolID, o.tools, o.think, o.maxTokens, o.maxOutputGroupTokens)
 	if err != nil {
 		return nil, err
@@ -541,12 +817,165 @@ func (c *Client) PromptFrom(ctx context.Context, nodeID string, message string,
 	return result, nil
 }
 
+// Edit sends message in place of the user message nodeID, as a new branch
+// next to the original. Editing the first message starts a new
+// conversation whose root records the original in ForkedFromDAG. The model
+// defaults as for PromptFrom.
+func (c *Client) Edit(ctx context.Context, nodeID string, message string, opts ...PromptOption) (*PromptResult, error) {
+	o, err := c.resolveOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx = o.context(ctx)
+	events, err := c.convMgr.Edit(ctx, nodeID, message, o.model, o.apiProtocolID, o.tools, o.think, o.maxTokens, o.maxOutputGroupTokens)
+	if err != nil {
+		return nil, err
+	}
+	result := buildResult(events)
+	result.MaxTurns = o.maxTurns
+	return result, nil
+}
+
+// Regenerate generates a new reply next to the assistant node nodeID, or
+// another reply to the user message nodeID. The model defaults to the one
+// that produced the original reply.
+func (c *Client) Regenerate(ctx context.Context, nodeID string, opts ...PromptOption) (*PromptResult, error) {
+	o, err := c.resolveOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx = o.context(ctx)
+	events, err := c.convMgr.Regenerate(ctx, nodeID, o.model, o.apiProtocolID, o.tools, o.think, o.maxTokens, o.maxOutputGroupTokens)
+	if err != nil {
+		return nil, err
+	}
+	result := buildResult(events)
+	result.MaxTurns = o.maxTurns
+	return result, nil
+}
+
+// Ask answers a question about the conversation containing nodeID and
+// streams the answer. The question and answer are stored as a side branch
+// off the root, marked as meta (see types.UserNodeMetadata). The model
+// defaults to the conversation's; WithModel overrides it.
+func (c *Client) Ask(ctx context.Context, nodeID, question string, opts ...PromptOption) (*PromptResult, error) {
+	o, err := c.resolveOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	node, err := c.convMgr.ResolveNode(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("langdag: node not found: %s", nodeID)
+	}
+	events, err := c.convMgr.Ask(o.context(ctx), node.ID, question, o.model)
+	if err != nil {
+		return nil, err
+	}
+	return buildResult(events), nil
+}
+
+// AskAll answers a question from every stored conversation and streams
+// the answer, which cites node IDs. It returns the nodes given to the
+// model as sources, or ErrNoSources if none matches. The question and
+// answer are stored as a new conversation marked as meta.
+func (c *Client) AskAll(ctx context.Context, question string, opts ...PromptOption) ([]SearchMatch, *PromptResult, error) {
+	o, err := c.applyOptions(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	sources, events, err := c.convMgr.AskAll(o.context(ctx), question, o.model)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sources, buildResult(events), nil
+}
+
+// Summarize summarizes the conversation containing nodeID and stores the
+// summary as a summary node under nodeID. For a root, the summary covers
+// every branch; otherwise it covers the path from the root to nodeID. The
+// model defaults to the conversation's; WithModel overrides it.
+func (c *Client) Summarize(ctx context.Context, nodeID string, opts ...PromptOption) (*types.Node, error) {
+	o, err := c.resolveOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	node, err := c.convMgr.ResolveNode(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("langdag: node not found: %s", nodeID)
+	}
+	return c.convMgr.Summarize(o.context(ctx), node.ID, o.model)
+}
+
+// LatestSummary returns the newest summary stored under nodeID, or nil if
+// it has none.
+func (c *Client) LatestSummary(ctx context.Context, nodeID string) (*types.Node, error) {
+	return c.convMgr.LatestSummary(ctx, nodeID)
+}
+
+// DAGUpdate lists the conversation settings to change with UpdateDAG; nil
+// fields are left as they are.
+type DAGUpdate = conversation.DAGUpdate
+
+// ErrInvalidUpdate is returned by UpdateDAG for empty or invalid updates.
+var ErrInvalidUpdate = conversation.ErrInvalidUpdate
+
+// UpdateDAG changes the title, system prompt, default model or default
+// tools of the conversation containing nodeID and returns its root. The
+// changes apply to later prompts; existing nodes are kept.
+func (c *Client) UpdateDAG(ctx context.Context, nodeID string, update DAGUpdate) (*types.Node, error) {
+	node, err := c.convMgr.ResolveNode(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("langdag: node not found: %s", nodeID)
+	}
+	return c.convMgr.UpdateDAG(ctx, node.ID, update)
+}
+
+// SetTitle sets the title of the conversation containing nodeID and
+// returns its root.
+func (c *Client) SetTitle(ctx context.Context, nodeID, title string) (*types.Node, error) {
+	return c.UpdateDAG(ctx, nodeID, DAGUpdate{Title: &title})
+}
+
+// Classify tags the conversation containing nodeID using the configured
+// classifier rules and model, replacing its previous tags, and returns the
+// updated root.
+func (c *Client) Classify(ctx context.Context, nodeID string) (*types.Node, error) {
+	node, err := c.convMgr.ResolveNode(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("langdag: node not found: %s", nodeID)
+	}
+	return c.convMgr.Classify(ctx, node.ID)
+}
+
+// ErrNoSources is returned by AskAll when no stored node matches the
+// question.
+var ErrNoSources = conversation.ErrNoSources
+
+// ErrNotEditable is returned by Edit for nodes other than user messages.
+var ErrNotEditable = conversation.ErrNotEditable
+
+// ErrAmbiguousPrefix is returned when an ID prefix matches several nodes.
+var ErrAmbiguousPrefix = internalstorage.ErrAmbiguousPrefix
+
 // ListConversations returns all root conversation nodes.
 func (c *Client) ListConversations(ctx context.Context) ([]*types.Node, error) {
 	return c.convMgr.ListRoots(ctx)
 }
 
-// GetNode returns a node by ID or ID prefix.
+// GetNode returns a node by ID, ID prefix or alias. A prefix matching
+// several nodes is an ErrAmbiguousPrefix error.
 func (c *Client) GetNode(ctx context.Context, id string) (*types.Node, error) {
 	return c.convMgr.ResolveNode(ctx, id)
 }
@@ -575,6 +1004,216 @@ func (c *Client) GetAncestors(ctx context.Context, id string) ([]*types.Node, er
 	return c.store.GetAncestors(ctx, node.ID)
 }
 
+// Comments returns the comments on a node and its descendants, oldest
+// first; on a root, every comment of the DAG.
+func (c *Client) Comments(ctx context.Context, id string) ([]*types.Comment, error) {
+	node, err := c.convMgr.ResolveNode(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("langdag: node not found: %s", id)
+	}
+	return c.convMgr.Comments(ctx, node.ID)
+}
+
+// SearchMatch is a node matched by SearchTree, along with the path of node
+// IDs from the root that locates its branch.
+type SearchMatch = conversation.SearchMatch
+
+// SearchTree searches the content of every node in the DAG containing the
+// given node (case-insensitive) and returns matches in sequence order.
+func (c *Client) SearchTree(ctx context.Context, id, query string) ([]SearchMatch, error) {
+	node, err := c.convMgr.ResolveNode(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("langdag: node not found: %s", id)
+	}
+	return c.convMgr.SearchTree(ctx, node.ID, query)
+}
+
+// Search finds nodes in every DAG whose content contains each term of query
+// (case-insensitive), best matches first. At most limit matches are
+// returned; limit <= 0 uses a default of 20.
+func (c *Client) Search(ctx context.Context, query string, limit int) ([]SearchMatch, error) {
+	return c.convMgr.Search(ctx, query, limit)
+}
+
+// SemanticSearch finds the nodes in every DAG closest in meaning to query,
+// using embeddings of their content (see Config.Embeddings), best matches
+// first with their Score. Nodes created before embeddings were enabled are
+// found once EmbedMissing has run. limit <= 0 uses a default of 20.
+func (c *Client) SemanticSearch(ctx context.Context, query string, limit int) ([]SearchMatch, error) {
+	return c.convMgr.SemanticSearch(ctx, query, limit)
+}
+
+// EmbedMissing embeds the content of every node that has no embedding for
+// the configured model yet and returns the number of nodes processed.
+func (c *Client) EmbedMissing(ctx context.Context) (int, error) {
+	return c.convMgr.EmbedMissing(ctx)
+}
+
+// DAGExport is a portable copy of a DAG with its original node IDs.
+type DAGExport = conversation.DAGExport
+
+// Export formats accepted by EncodeExport. Only JSON and YAML exports can be
+// imported; Markdown is a readable transcript.
+const (
+	ExportJSON     = conversation.ExportJSON
+	ExportYAML     = conversation.ExportYAML
+	ExportMarkdown = conversation.ExportMarkdown
+)
+
+// ErrDAGExists is returned by Import when the DAG is already stored.
+var ErrDAGExists = conversation.ErrDAGExists
+
+// ErrInvalidExport is returned by DecodeExport and Import for malformed
+// exports.
+var ErrInvalidExport = conversation.ErrInvalidExport
+
+// EncodeExport writes an export as JSON, YAML or Markdown.
+var EncodeExport = conversation.EncodeExport
+
+// DecodeExport parses a JSON or YAML export.
+var DecodeExport = conversation.DecodeExport
+
+// Export returns the whole DAG containing the given node, for saving with
+// EncodeExport and restoring elsewhere with Import.
+func (c *Client) Export(ctx context.Context, id string) (*DAGExport, error) {
+	node, err := c.convMgr.ResolveNode(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("langdag: node not found: %s", id)
+	}
+	return c.convMgr.Export(ctx, node.ID)
+}
+
+// Import stores an exported DAG, keeping its node IDs, and returns its root.
+// It fails with ErrDAGExists if the DAG is already stored.
+func (c *Client) Import(ctx context.Context, dag *DAGExport) (*types.Node, error) {
+	return c.convMgr.Import(ctx, dag)
+}
+
+// Archive moves every DAG whose newest node was created before cutoff to
+// the configured ArchiveLocation and returns how many were archived. Each
+// archived DAG keeps a root stub with ArchivedURI set; resolving the root
+// (by ID, prefix or alias) restores the full DAG.
+func (c *Client) Archive(ctx context.Context, cutoff time.Time) (int, error) {
+	return c.convMgr.Archive(ctx, cutoff)
+}
+
+// PruneOptions selects the DAGs deleted by Prune: those with no node newer
+// than Before and, when Statuses is set, a node in one of them ("failed"
+// matches cancelled, timeout and interrupted replies).
+type PruneOptions = conversation.PruneOptions
+
+// Prune deletes the DAGs selected by opts, or only lists them with
+// opts.DryRun, and returns their roots. Pruned DAGs are not saved to the
+// trash; run PruneFiles afterwards to remove their content files.
+func (c *Client) Prune(ctx context.Context, opts PruneOptions) ([]*types.Node, error) {
+	return c.convMgr.Prune(ctx, opts)
+}
+
+// PruneResult reports the content files removed by PruneFiles.
+type PruneResult = sqlite.PruneResult
+
+// PruneFiles removes the files of node content (see Config.FileThreshold)
+// that no node references any more, such as those of deleted or archived
+// DAGs. Files written in the last hour are kept. With dryRun, it only
+// reports what would be removed. It does nothing for storage other than
+// the SQLite storage of New.
+func (c *Client) PruneFiles(ctx context.Context, dryRun bool) (PruneResult, error) {
+	store, ok := c.store.(*sqlite.SQLiteStorage)
+	if !ok {
+		return PruneResult{}, nil
+	}
+	return store.PruneFiles(ctx, dryRun)
+}
+
+// Stats, StatsCount and ModelStats are the aggregate figures returned by
+// Stats.
+type (
+	Stats      = sqlite.Stats
+	StatsCount = sqlite.StatsCount
+	ModelStats = sqlite.ModelStats
+)
+
+// Stats returns DAG counts by status and source, token totals, reply
+// figures by model and the days days with the most messages. It is
+// computed in SQL and only available with the SQLite storage of New.
+func (c *Client) Stats(ctx context.Context, days int) (*Stats, error) {
+	store, ok := c.store.(*sqlite.SQLiteStorage)
+	if !ok {
+		return nil, fmt.Errorf("langdag: stats need SQLite storage")
+	}
+	return store.Stats(ctx, days)
+}
+
+// ModelPrice is the price of a model per million tokens, for Config.Pricing.
+type ModelPrice = conversation.ModelPrice
+
+// UsageReport and UsageRow are token and cost totals returned by Usage.
+type (
+	UsageReport = conversation.UsageReport
+	UsageRow    = conversation.UsageRow
+)
+
+// Groupings accepted by Usage.
+const (
+	UsageByDAG   = conversation.UsageByDAG
+	UsageByDay   = conversation.UsageByDay
+	UsageByModel = conversation.UsageByModel
+)
+
+// Usage returns token and cost totals of the replies generated since the
+// given time, grouped by DAG, day (UTC) or model. Costs come from the
+// provider or the catalog pricing recorded with each reply, or else from
+// Config.Pricing.
+func (c *Client) Usage(ctx context.Context, since time.Time, groupBy string) (*UsageReport, error) {
+	return c.convMgr.Usage(ctx, since, groupBy)
+}
+
+// ErrGenerationTimeout ends the stream of a generation stopped by
+// Config.GenerationTimeout before it produced any text.
+var ErrGenerationTimeout = conversation.ErrGenerationTimeout
+
+// CancelTree stops every generation running in the DAG containing the given
+// node and returns how many were cancelled. Each cancelled prompt saves its
+// partial output as a node with status "cancelled" and ends its stream.
+func (c *Client) CancelTree(ctx context.Context, id string) (int, error) {
+	node, err := c.convMgr.ResolveNode(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if node == nil {
+		return 0, fmt.Errorf("langdag: node not found: %s", id)
+	}
+	return c.convMgr.CancelTree(ctx, node.ID)
+}
+
+// Clone copies the path from the root down to the given node into a new
+// DAG and returns the copy of that node; continue the conversation from it
+// with PromptFrom. The new root's ForkedFromDAG and ForkedFromNode record
+// where it was cloned from.
+func (c *Client) Clone(ctx context.Context, id string) (*types.Node, error) {
+	node, err := c.convMgr.ResolveNode(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("langdag: node not found: %s", id)
+	}
+	path, err := c.convMgr.Clone(ctx, node.ID)
+	if err != nil {
+		return nil, err
+	}
+	return path[len(path)-1], nil
+}
+
 // DeleteNode deletes a node and all its descendants.
 func (c *Client) DeleteNode(ctx context.Context, id string) error {
 	node, err := c.convMgr.ResolveNode(ctx, id)
@@ -588,14 +1227,59 @@ func (c *Client) DeleteNode(ctx context.Context, id string) error {
 }
 
 // applyOptions applies prompt options and returns the resulting promptOptions.
-func applyOptions(opts []PromptOption) *promptOptions {
-	o := &promptOptions{
-		model: "claude-sonnet-4-20250514",
+// Values from a preset selected with WithPreset fill in whatever the other
+// options left unset.
+func (c *Client) applyOptions(opts []PromptOption) (*promptOptions, error) {
+	o, err := c.resolveOptions(opts)
+	if err != nil {
+		return nil, err
 	}
+	if o.model == "" {
+		o.model = "claude-sonnet-4-20250514"
+	}
+	return o, nil
+}
+
+// resolveOptions applies opts and their preset without defaulting the model.
+func (c *Client) resolveOptions(opts []PromptOption) (*promptOptions, error) {
+	o := &promptOptions{}
 	for _, opt := range opts {
 		opt(o)
 	}
-	return o
+	if o.preset != "" {
+		p, ok := c.convMgr.Preset(o.preset)
+		if !ok {
+			return nil, fmt.Errorf("langdag: unknown preset: %s", o.preset)
+		}
+		if o.model == "" {
+			o.model = p.Model
+		}
+		if o.systemPrompt == "" {
+			o.systemPrompt = p.SystemPrompt
+		}
+		if o.temperature == nil {
+			o.temperature = p.Temperature
+		}
+	}
+	return o, nil
+}
+
+// context returns ctx annotated with the per-request settings in o that the
+// conversation manager reads from the context.
+func (o *promptOptions) context(ctx context.Context) context.Context {
+	if o.userID != "" {
+		ctx = conversation.ContextWithRequestMetadata(ctx, &types.RequestMetadata{UserID: o.userID})
+	}
+	if o.temperature != nil {
+		ctx = conversation.ContextWithTemperature(ctx, *o.temperature)
+	}
+	if o.language != "" {
+		ctx = conversation.ContextWithLanguage(ctx, o.language)
+	}
+	if o.injectionConfirmed {
+		ctx = conversation.ContextWithInjectionConfirmed(ctx)
+	}
+	return ctx
 }
 
 // buildResult converts a channel of types.StreamEvent into a PromptResult with a StreamChunk channel.
@@ -610,7 +1294,7 @@ func buildResult(events <-chan types.StreamEvent) *PromptResult {
 		var accumulated string
 		var stopReason string
 		var doneResponse *types.CompletionResponse
-		var terminated bool
+		var terminated, timedOut bool
 		for event := range events {
 			switch event.Type {
 			case types.StreamEventDelta:
@@ -623,8 +1307,10 @@ func buildResult(events <-chan types.StreamEvent) *PromptResult {
 					stopReason = event.Response.StopReason
 					doneResponse = event.Response
 				}
+			case types.StreamEventTimeout:
+				timedOut = true
 			case types.StreamEventError:
-				ch <- StreamChunk{Error: event.Error, Done: true}
+				ch <- StreamChunk{Error: event.Error, Done: true, TimedOut: timedOut}
 				terminated = true
 				return
 			case types.StreamEventNodeSaved:
@@ -632,7 +1318,9 @@ func buildResult(events <-chan types.StreamEvent) *PromptResult {
 				result.NodeID = event.NodeID
 				result.Content = accumulated
 				result.mu.Unlock()
-				ch <- streamDoneChunk(event.NodeID, stopReason, doneResponse)
+				chunk := streamDoneChunk(event.NodeID, stopReason, doneResponse)
+				chunk.TimedOut = timedOut
+				ch <- chunk
 				terminated = true
 			}
 		}
@@ -738,11 +1426,11 @@ func buildDeploymentAwareProvider(ctx context.Context, cfg Config, catalog *mode
 			if !routingConfigured && len(deploymentIDs) == 1 {
 				return nil, err
 			}
-			log.Printf("langdag: skipping unavailable deployment %q: %v", deploymentID, err)
+			slog.Warn("langdag: skipping unavailable deployment", "deployment", deploymentID, "error", err)
 			continue
 		}
 		adapters[deploymentID] = adapter
-		log.Printf("langdag: configured deployment: %s", deploymentID)
+		slog.Info("langdag: configured deployment", "deployment", deploymentID)
 	}
 	if len(adapters) == 0 {
 		return nil, fmt.Errorf("langdag: no configured deployments are available")
@@ -1186,7 +1874,7 @@ func buildRouter(ctx context.Context, cfg Config, globalRetry internalprovider.R
 		p, err := createSingleProvider(ctx, name, cfg)
 		if err != nil {
 			// Silently drop unavailable providers in routing (consistent with api/server.go)
-			log.Printf("langdag: skipping unavailable provider %q: %v", name, err)
+			slog.Warn("langdag: skipping unavailable provider", "provider", name, "error", err)
 			return nil, nil //nolint:nilerr
 		}
 		providerCache[name] = p
@@ -1206,7 +1894,7 @@ func buildRouter(ctx context.Context, cfg Config, globalRetry internalprovider.R
 		entryCfg := resolveRetryConfig(re.Retry)
 		wrapped := internalprovider.WithRetry(internalprovider.WithServerToolFilter(p), entryCfg)
 		entries = append(entries, internalprovider.RouteEntry{Provider: wrapped, Weight: re.Weight})
-		log.Printf("langdag: routing: %s (weight=%d)", re.Provider, re.Weight)
+		slog.Info("langdag: routing", "provider", re.Provider, "weight", re.Weight)
 	}
 
 	// Build fallback chain
@@ -1221,7 +1909,7 @@ func buildRouter(ctx context.Context, cfg Config, globalRetry internalprovider.R
 		}
 		wrapped := internalprovider.WithRetry(internalprovider.WithServerToolFilter(p), globalRetry)
 		fallbackProviders = append(fallbackProviders, wrapped)
-		log.Printf("langdag: fallback: %s", name)
+		slog.Info("langdag: fallback", "provider", name)
 	}
 
 	return internalprovider.NewRouter(entries, fallbackProviders)
@@ -2390,6 +2390,71 @@ func TestWithMaxTokens_DefaultFallback(t *testing.T) {
 	}
 }
 
+// --- WithPreset tests ---
+
+func TestWithPreset_AppliesModelSystemAndTemperature(t *testing.T) {
+	client, prov := newTestClientWithProvider(t, "ok")
+	temp := 0.2
+	client.SetPresets(map[string]langdag.Preset{
+		"reviewer": {Model: "claude-opus-4-20250514", SystemPrompt: "Review carefully.", Temperature: &temp},
+	})
+	ctx := context.Background()
+
+	result, err := client.Prompt(ctx, "hello", langdag.WithPreset("reviewer"))
+	if err != nil {
+		t.Fatalf("Prompt with WithPreset: %v", err)
+	}
+	drainStream(t, result)
+
+	req := prov.LastRequest
+	if req == nil {
+		t.Fatal("expected provider to receive a request")
+	}
+	if req.Model != "claude-opus-4-20250514" {
+		t.Errorf("Model = %q, want preset model", req.Model)
+	}
+	if req.System != "Review carefully." {
+		t.Errorf("System = %q, want preset system prompt", req.System)
+	}
+	if req.Temperature == nil || *req.Temperature != 0.2 {
+		t.Errorf("Temperature = %v, want 0.2", req.Temperature)
+	}
+}
+
+func TestWithPreset_ExplicitOptionsOverride(t *testing.T) {
+	client, prov := newTestClientWithProvider(t, "ok")
+	temp := 0.2
+	client.SetPresets(map[string]langdag.Preset{
+		"reviewer": {Model: "claude-opus-4-20250514", Temperature: &temp},
+	})
+	ctx := context.Background()
+
+	result, err := client.Prompt(ctx, "hello",
+		langdag.WithPreset("reviewer"),
+		langdag.WithModel("gpt-4o"),
+		langdag.WithTemperature(0.9),
+	)
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	drainStream(t, result)
+
+	if prov.LastRequest.Model != "gpt-4o" {
+		t.Errorf("Model = %q, want gpt-4o", prov.LastRequest.Model)
+	}
+	if prov.LastRequest.Temperature == nil || *prov.LastRequest.Temperature != 0.9 {
+		t.Errorf("Temperature = %v, want 0.9", prov.LastRequest.Temperature)
+	}
+}
+
+func TestWithPreset_Unknown(t *testing.T) {
+	client, _ := newTestClientWithProvider(t, "ok")
+
+	if _, err := client.Prompt(context.Background(), "hello", langdag.WithPreset("missing")); err == nil {
+		t.Fatal("expected error for unknown preset")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Phase 6: Reproduce original max_tokens crash chain
 // ---------------------------------------------------------------------------
@@ -2402,8 +2467,12 @@ type callSequenceProvider struct {
 	callIdx int
 }
 
-func (p *callSequenceProvider) Name() string              { return "mock-sequence" }
-func (p *callSequenceProvider) Models() []types.ModelInfo { return nil }
+func (p *callSequenceProvider) Name() string                     { return "mock-sequence" }
+func (p *callSequenceProvider) Models() []types.ModelInfo        { return nil }
+func (p *callSequenceProvider) Capabilities() types.Capabilities { return types.Capabilities{} }
+func (p *callSequenceProvider) Embed(context.Context, *types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	return nil, types.ErrEmbeddingsNotSupported
+}
 
 func (p *callSequenceProvider) Complete(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	p.mu.Lock()
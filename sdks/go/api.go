@@ -0,0 +1,46 @@
+package langdag
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// API is the set of operations of a LangDAG client. *Client implements it;
+// code that depends on API instead of *Client can be given a fake, or a
+// decorator adding retries, metrics or logging around a *Client.
+//
+// Methods of the returned nodes, trees and streams, such as Node.Prompt,
+// go through the *Client that fetched them, not through a decorator.
+type API interface {
+	Health(ctx context.Context) (*HealthResponse, error)
+	Features(ctx context.Context) (*Features, error)
+	Usage(ctx context.Context, since time.Time, by string) (*Usage, error)
+
+	Prompt(ctx context.Context, message string, opts ...PromptOption) (*Node, error)
+	PromptStream(ctx context.Context, message string, opts ...PromptOption) (*Stream, error)
+	PromptTo(ctx context.Context, message string, w io.Writer, opts ...PromptOption) (*Node, error)
+	Ask(ctx context.Context, dagID, question string, opts ...PromptOption) (*AskResult, error)
+	Summarize(ctx context.Context, id string, opts ...PromptOption) (*Node, error)
+
+	GetNode(ctx context.Context, id string) (*Node, error)
+	GetTree(ctx context.Context, id string) (*Tree, error)
+	ListRoots(ctx context.Context) ([]Node, error)
+	ListRootsByTag(ctx context.Context, tag string) ([]Node, error)
+	SearchTree(ctx context.Context, id, query string) ([]SearchMatch, error)
+	Search(ctx context.Context, query string, limit int) ([]SearchMatch, error)
+
+	UpdateDAG(ctx context.Context, id string, update DAGUpdate) (*Node, error)
+	SetTitle(ctx context.Context, id, title string) (*Node, error)
+	CancelTree(ctx context.Context, id string) (*CancelResult, error)
+	Clone(ctx context.Context, id string) (*CloneResult, error)
+	DeleteNode(ctx context.Context, id string) error
+	ExportDAG(ctx context.Context, id, format string) (io.ReadCloser, error)
+	ImportDAG(ctx context.Context, r io.Reader) (*Node, error)
+
+	CreateAlias(ctx context.Context, nodeID, alias string) error
+	DeleteAlias(ctx context.Context, alias string) error
+	ListAliases(ctx context.Context, nodeID string) ([]string, error)
+}
+
+var _ API = (*Client)(nil)
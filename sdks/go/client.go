@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,6 +19,12 @@ type Client struct {
 	httpClient  *http.Client
 	apiKey      string
 	bearerToken string
+	trees       *treeCache // nil unless WithTreeCache
+	middleware  []Middleware
+
+	toolsMu   sync.RWMutex
+	tools     map[string]registeredTool // set with RegisterTool
+	toolOrder []string
 }
 
 // Option is a function that configures the Client.
@@ -68,6 +76,32 @@ func WithTimeout(timeout time.Duration) Option {
 	}
 }
 
+// Middleware intercepts the requests of a Client, e.g. to add tracing
+// headers or log payloads. It calls next to send req, possibly modified,
+// and may inspect or replace the response. Streamed responses are returned
+// before their body is read.
+type Middleware func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error)
+
+// WithMiddleware adds middleware to every request of the client. The first
+// one added is the outermost: it sees the request first and the response
+// last.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(c *Client) {
+		c.middleware = append(c.middleware, mw...)
+	}
+}
+
+// WithTreeCache makes GetTree keep the last response for up to size trees
+// and revalidate it with the server's ETag, so trees that have not changed
+// are not downloaded again.
+func WithTreeCache(size int) Option {
+	return func(c *Client) {
+		if size > 0 {
+			c.trees = &treeCache{size: size, entries: make(map[string]treeCacheEntry)}
+		}
+	}
+}
+
 // Health checks the server health.
 func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
 	var resp HealthResponse
@@ -77,6 +111,37 @@ func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
 	return &resp, nil
 }
 
+// Features returns the capabilities enabled on the server.
+func (c *Client) Features(ctx context.Context) (*Features, error) {
+	var resp Features
+	if err := c.doRequest(ctx, http.MethodGet, "/features", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Usage returns token and cost totals of the replies generated since the
+// given time (the last 7 days if zero), grouped by "model" (default), "day"
+// or "dag".
+func (c *Client) Usage(ctx context.Context, since time.Time, by string) (*Usage, error) {
+	q := url.Values{}
+	if !since.IsZero() {
+		q.Set("since", since.UTC().Format(time.RFC3339))
+	}
+	if by != "" {
+		q.Set("by", by)
+	}
+	path := "/usage"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	var resp Usage
+	if err := c.doRequest(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
 // Prompt starts a new conversation tree with the given message.
 func (c *Client) Prompt(ctx context.Context, message string, opts ...PromptOption) (*Node, error) {
 	o := &promptOptions{}
@@ -88,7 +153,13 @@ func (c *Client) Prompt(ctx context.Context, message string, opts ...PromptOptio
 		Message:      message,
 		Model:        o.model,
 		SystemPrompt: o.systemPrompt,
-		Tools:        o.tools,
+		Tools:        c.withRegisteredTools(o.tools),
+		Metadata:     o.metadata(),
+		MaxTokens:    o.maxTokens,
+		Temperature:  o.temperature,
+		Stop:         o.stop,
+		Preset:       o.preset,
+		Language:     o.language,
 	}
 
 	var resp PromptResponse
@@ -96,7 +167,7 @@ func (c *Client) Prompt(ctx context.Context, message string, opts ...PromptOptio
 		return nil, err
 	}
 
-	return nodeFromPromptResponse(&resp, c, ""), nil
+	return c.runTools(ctx, nodeFromPromptResponse(&resp, c, ""), o)
 }
 
 // PromptStream starts a new conversation tree with streaming.
@@ -112,21 +183,61 @@ func (c *Client) PromptStream(ctx context.Context, message string, opts ...Promp
 		SystemPrompt: o.systemPrompt,
 		Stream:       true,
 		Tools:        o.tools,
+		Metadata:     o.metadata(),
+		MaxTokens:    o.maxTokens,
+		Temperature:  o.temperature,
+		Stop:         o.stop,
+		Preset:       o.preset,
+		Language:     o.language,
+		StreamOpts:   o.streamOptions(),
 	}
 
 	return c.doStreamRequest(ctx, http.MethodPost, "/prompt", req)
 }
 
-// promptFrom continues a conversation from an existing node (non-streaming).
-func (c *Client) promptFrom(ctx context.Context, nodeID, message string, o *promptOptions) (*Node, error) {
+// PromptTo starts a new conversation tree, writing the reply to w as it
+// streams, and returns the resulting node:
+//
+//	node, err := client.PromptTo(ctx, "Write a haiku", os.Stdout)
+func (c *Client) PromptTo(ctx context.Context, message string, w io.Writer, opts ...PromptOption) (*Node, error) {
+	stream, err := c.PromptStream(ctx, message, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stream.WriteTo(w); err != nil {
+		return nil, err
+	}
+	return stream.Node()
+}
+
+// promptFrom continues a conversation from an existing node (non-streaming),
+// answering the calls of registered tools. action is the node endpoint to
+// call: "prompt" or "edit".
+func (c *Client) promptFrom(ctx context.Context, nodeID, action, message string, o *promptOptions) (*Node, error) {
+	reply, err := c.sendPrompt(ctx, nodeID, action, message, o)
+	if err != nil {
+		return nil, err
+	}
+	return c.runTools(ctx, reply, o)
+}
+
+// sendPrompt makes the request of promptFrom.
+func (c *Client) sendPrompt(ctx context.Context, nodeID, action, message string, o *promptOptions) (*Node, error) {
 	req := promptRequest{
-		Message: message,
-		Model:   o.model,
-		Tools:   o.tools,
+		Message:     message,
+		Model:       o.model,
+		Tools:       c.withRegisteredTools(o.tools),
+		Metadata:    o.metadata(),
+		MaxTokens:   o.maxTokens,
+		Temperature: o.temperature,
+		Stop:        o.stop,
+		Preset:      o.preset,
+		Language:    o.language,
+		Confirm:     o.confirm,
 	}
 
 	var resp PromptResponse
-	if err := c.doRequest(ctx, http.MethodPost, fmt.Sprintf("/nodes/%s/prompt", nodeID), req, &resp); err != nil {
+	if err := c.doRequest(ctx, http.MethodPost, fmt.Sprintf("/nodes/%s/%s", nodeID, action), req, &resp); err != nil {
 		return nil, err
 	}
 
@@ -134,15 +245,60 @@ func (c *Client) promptFrom(ctx context.Context, nodeID, message string, o *prom
 }
 
 // promptStreamFrom continues a conversation from an existing node with streaming.
-func (c *Client) promptStreamFrom(ctx context.Context, nodeID, message string, o *promptOptions) (*Stream, error) {
+func (c *Client) promptStreamFrom(ctx context.Context, nodeID, action, message string, o *promptOptions) (*Stream, error) {
 	req := promptRequest{
-		Message: message,
-		Model:   o.model,
-		Stream:  true,
-		Tools:   o.tools,
+		Message:     message,
+		Model:       o.model,
+		Stream:      true,
+		Tools:       o.tools,
+		Metadata:    o.metadata(),
+		MaxTokens:   o.maxTokens,
+		Temperature: o.temperature,
+		Stop:        o.stop,
+		Preset:      o.preset,
+		Language:    o.language,
+		Confirm:     o.confirm,
+		StreamOpts:  o.streamOptions(),
+	}
+
+	return c.doStreamRequest(ctx, http.MethodPost, fmt.Sprintf("/nodes/%s/%s", nodeID, action), req)
+}
+
+// regenerate asks for a new reply next to an existing node (non-streaming).
+func (c *Client) regenerate(ctx context.Context, nodeID string, o *promptOptions) (*Node, error) {
+	req := regenerateRequest{
+		Model:       o.model,
+		Tools:       c.withRegisteredTools(o.tools),
+		Metadata:    o.metadata(),
+		MaxTokens:   o.maxTokens,
+		Temperature: o.temperature,
+		Stop:        o.stop,
+		Language:    o.language,
+	}
+
+	var resp PromptResponse
+	if err := c.doRequest(ctx, http.MethodPost, fmt.Sprintf("/nodes/%s/regenerate", nodeID), req, &resp); err != nil {
+		return nil, err
 	}
 
-	return c.doStreamRequest(ctx, http.MethodPost, fmt.Sprintf("/nodes/%s/prompt", nodeID), req)
+	return c.runTools(ctx, nodeFromPromptResponse(&resp, c, ""), o)
+}
+
+// regenerateStream asks for a new reply next to an existing node with streaming.
+func (c *Client) regenerateStream(ctx context.Context, nodeID string, o *promptOptions) (*Stream, error) {
+	req := regenerateRequest{
+		Model:       o.model,
+		Stream:      true,
+		Tools:       o.tools,
+		Metadata:    o.metadata(),
+		MaxTokens:   o.maxTokens,
+		Temperature: o.temperature,
+		Stop:        o.stop,
+		Language:    o.language,
+		StreamOpts:  o.streamOptions(),
+	}
+
+	return c.doStreamRequest(ctx, http.MethodPost, fmt.Sprintf("/nodes/%s/regenerate", nodeID), req)
 }
 
 // GetNode retrieves a single node by ID.
@@ -155,16 +311,163 @@ func (c *Client) GetNode(ctx context.Context, id string) (*Node, error) {
 	return &node, nil
 }
 
-// GetTree retrieves a node and its full subtree.
+// GetTree retrieves a node and its full subtree. With WithTreeCache, a
+// cached copy is revalidated with the server instead of downloaded again.
 func (c *Client) GetTree(ctx context.Context, id string) (*Tree, error) {
-	var nodes []Node
-	if err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf("/nodes/%s/tree", id), nil, &nodes); err != nil {
+	cached, _ := c.trees.get(id)
+	nodes, etag, err := c.fetchTree(ctx, id, cached.etag)
+	if err != nil {
 		return nil, err
 	}
+	if nodes == nil {
+		nodes, etag = append([]Node(nil), cached.nodes...), cached.etag
+	} else {
+		c.trees.put(id, treeCacheEntry{etag: etag, nodes: append([]Node(nil), nodes...)})
+	}
 	for i := range nodes {
 		nodes[i].client = c
 	}
-	return &Tree{Nodes: nodes}, nil
+	return &Tree{Nodes: nodes, client: c, id: id, etag: etag}, nil
+}
+
+// SearchTree searches the content of every node in the DAG containing the
+// given node (case-insensitive).
+func (c *Client) SearchTree(ctx context.Context, id, query string) ([]SearchMatch, error) {
+	var matches []SearchMatch
+	path := fmt.Sprintf("/nodes/%s/search?q=%s", id, url.QueryEscape(query))
+	if err := c.doRequest(ctx, http.MethodGet, path, nil, &matches); err != nil {
+		return nil, err
+	}
+	for i := range matches {
+		matches[i].Node.client = c
+	}
+	return matches, nil
+}
+
+// Search finds nodes in every DAG whose content contains each term of
+// query, best matches first. A limit <= 0 uses the server default.
+func (c *Client) Search(ctx context.Context, query string, limit int) ([]SearchMatch, error) {
+	var matches []SearchMatch
+	path := "/search?q=" + url.QueryEscape(query)
+	if limit > 0 {
+		path += fmt.Sprintf("&limit=%d", limit)
+	}
+	if err := c.doRequest(ctx, http.MethodGet, path, nil, &matches); err != nil {
+		return nil, err
+	}
+	for i := range matches {
+		matches[i].Node.client = c
+	}
+	return matches, nil
+}
+
+// SemanticSearch finds the nodes in every DAG closest in meaning to query,
+// best matches first. The server must have embeddings enabled. A limit <= 0
+// uses the server default.
+func (c *Client) SemanticSearch(ctx context.Context, query string, limit int) ([]SearchMatch, error) {
+	var matches []SearchMatch
+	path := "/search/semantic?q=" + url.QueryEscape(query)
+	if limit > 0 {
+		path += fmt.Sprintf("&limit=%d", limit)
+	}
+	if err := c.doRequest(ctx, http.MethodGet, path, nil, &matches); err != nil {
+		return nil, err
+	}
+	for i := range matches {
+		matches[i].Node.client = c
+	}
+	return matches, nil
+}
+
+// Ask answers a question about the DAG containing dagID, or about every DAG
+// when dagID is empty. The answer cites node IDs by their first 8
+// characters. WithModel selects the model.
+func (c *Client) Ask(ctx context.Context, dagID, question string, opts ...PromptOption) (*AskResult, error) {
+	o := &promptOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	req := askRequest{
+		Question: question,
+		DAGID:    dagID,
+		Model:    o.model,
+		Metadata: o.metadata(),
+	}
+	var result AskResult
+	if err := c.doRequest(ctx, http.MethodPost, "/ask", req, &result); err != nil {
+		return nil, err
+	}
+	for i := range result.Sources {
+		result.Sources[i].Node.client = c
+	}
+	return &result, nil
+}
+
+// ExportDAG returns the whole DAG containing the given node, encoded as
+// "json" (the default when format is empty), "yaml" or "markdown", as it is
+// downloaded. JSON and YAML exports can be restored with ImportDAG. The
+// caller must close it.
+func (c *Client) ExportDAG(ctx context.Context, id, format string) (io.ReadCloser, error) {
+	path := fmt.Sprintf("/nodes/%s/export", id)
+	if format != "" {
+		path += "?format=" + url.QueryEscape(format)
+	}
+	return c.openRequest(ctx, http.MethodGet, path, nil)
+}
+
+// ImportDAG stores the JSON or YAML DAG export read from r, e.g. a backup
+// file, keeping its node IDs, and returns its root node.
+func (c *Client) ImportDAG(ctx context.Context, r io.Reader) (*Node, error) {
+	body, err := c.openRequest(ctx, http.MethodPost, "/nodes/import", r)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var node Node
+	if err := json.NewDecoder(body).Decode(&node); err != nil {
+		return nil, fmt.Errorf("langdag: failed to decode response: %w", err)
+	}
+	node.client = c
+	return &node, nil
+}
+
+// CancelTree stops every generation running in the DAG containing the given
+// node. Partial output is saved with status "cancelled".
+func (c *Client) CancelTree(ctx context.Context, id string) (*CancelResult, error) {
+	var result CancelResult
+	if err := c.doRequest(ctx, http.MethodPost, fmt.Sprintf("/nodes/%s/cancel", id), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Clone copies the path from the root down to the given node into a new
+// DAG. The new root records the source in ForkedFromDAG and ForkedFromNode.
+func (c *Client) Clone(ctx context.Context, id string) (*CloneResult, error) {
+	var result CloneResult
+	if err := c.doRequest(ctx, http.MethodPost, fmt.Sprintf("/nodes/%s/clone", id), nil, &result); err != nil {
+		return nil, err
+	}
+	result.Root.client = c
+	result.Node.client = c
+	return &result, nil
+}
+
+// Summarize asks the model for a summary of the DAG (when id is a root) or
+// of the branch ending at id, and returns the stored summary node.
+// WithModel overrides the DAG's model.
+func (c *Client) Summarize(ctx context.Context, id string, opts ...PromptOption) (*Node, error) {
+	o := &promptOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	req := summarizeRequest{Model: o.model, Metadata: o.metadata()}
+	var node Node
+	if err := c.doRequest(ctx, http.MethodPost, fmt.Sprintf("/nodes/%s/summarize", id), req, &node); err != nil {
+		return nil, err
+	}
+	node.client = c
+	return &node, nil
 }
 
 // ListRoots returns all root nodes (conversation trees).
@@ -179,6 +482,37 @@ func (c *Client) ListRoots(ctx context.Context) ([]Node, error) {
 	return nodes, nil
 }
 
+// ListRootsByTag returns the root nodes the server's classifier tagged
+// with tag.
+func (c *Client) ListRootsByTag(ctx context.Context, tag string) ([]Node, error) {
+	var nodes []Node
+	if err := c.doRequest(ctx, http.MethodGet, "/nodes?tag="+url.QueryEscape(tag), nil, &nodes); err != nil {
+		return nil, err
+	}
+	for i := range nodes {
+		nodes[i].client = c
+	}
+	return nodes, nil
+}
+
+// UpdateDAG changes the title, system prompt, default model or default
+// tools of the DAG containing the given node and returns its root. Nil
+// fields of update are left unchanged.
+func (c *Client) UpdateDAG(ctx context.Context, id string, update DAGUpdate) (*Node, error) {
+	var node Node
+	if err := c.doRequest(ctx, http.MethodPatch, fmt.Sprintf("/nodes/%s", id), update, &node); err != nil {
+		return nil, err
+	}
+	node.client = c
+	return &node, nil
+}
+
+// SetTitle sets the title of the DAG containing the given node and returns
+// its root.
+func (c *Client) SetTitle(ctx context.Context, id, title string) (*Node, error) {
+	return c.UpdateDAG(ctx, id, DAGUpdate{Title: &title})
+}
+
 // DeleteNode deletes a node and its subtree.
 func (c *Client) DeleteNode(ctx context.Context, id string) error {
 	return c.doRequest(ctx, http.MethodDelete, fmt.Sprintf("/nodes/%s", id), nil, nil)
@@ -205,6 +539,30 @@ func (c *Client) ListAliases(ctx context.Context, nodeID string) ([]string, erro
 	return resp.Aliases, nil
 }
 
+// ListComments returns the comments on a node and its descendants, oldest
+// first; on a root node, every comment of the DAG.
+func (c *Client) ListComments(ctx context.Context, nodeID string) ([]Comment, error) {
+	var comments []Comment
+	if err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf("/nodes/%s/comments", nodeID), nil, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// AddComment comments on a node. replyTo is the ID of the comment on the
+// same node it answers, or empty.
+func (c *Client) AddComment(ctx context.Context, nodeID, body, replyTo string) (*Comment, error) {
+	req := struct {
+		Body    string `json:"body"`
+		ReplyTo string `json:"reply_to,omitempty"`
+	}{body, replyTo}
+	var comment Comment
+	if err := c.doRequest(ctx, http.MethodPost, fmt.Sprintf("/nodes/%s/comments", nodeID), req, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
 // doRequest performs an HTTP request and decodes the JSON response.
 func (c *Client) doRequest(ctx context.Context, method, path string, body, result interface{}) error {
 	var bodyReader io.Reader
@@ -226,7 +584,7 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body, resul
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(c.httpClient, req)
 	if err != nil {
 		return &ConnectionError{Err: err}
 	}
@@ -245,6 +603,26 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body, resul
 	return nil
 }
 
+// openRequest performs an HTTP request with a raw body and returns the
+// response body, which the caller must close.
+func (c *Client) openRequest(ctx context.Context, method, path string, body io.Reader) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("langdag: failed to create request: %w", err)
+	}
+	c.setHeaders(req)
+
+	resp, err := c.do(c.httpClient, req)
+	if err != nil {
+		return nil, &ConnectionError{Err: err}
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, c.parseError(resp)
+	}
+	return resp.Body, nil
+}
+
 // doStreamRequest performs an HTTP request and returns a Stream for SSE events.
 func (c *Client) doStreamRequest(ctx context.Context, method, path string, body interface{}) (*Stream, error) {
 	var bodyReader io.Reader
@@ -272,7 +650,7 @@ func (c *Client) doStreamRequest(ctx context.Context, method, path string, body
 		Transport: c.httpClient.Transport,
 	}
 
-	resp, err := client.Do(req)
+	resp, err := c.do(client, req)
 	if err != nil {
 		return nil, &ConnectionError{Err: err}
 	}
@@ -282,7 +660,24 @@ func (c *Client) doStreamRequest(ctx context.Context, method, path string, body
 		return nil, c.parseError(resp)
 	}
 
-	return newStream(resp.Body, c), nil
+	s := newStream(resp.Body, c)
+	// Stop reading when ctx is done, even if no one receives the events.
+	stop := context.AfterFunc(ctx, func() { s.close(ctx.Err()) })
+	go func() {
+		s.done.Wait()
+		stop()
+	}()
+	return s, nil
+}
+
+// do sends req with client through the middleware.
+func (c *Client) do(client *http.Client, req *http.Request) (*http.Response, error) {
+	next := client.Do
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		mw, inner := c.middleware[i], next
+		next = func(req *http.Request) (*http.Response, error) { return mw(req, inner) }
+	}
+	return next(req)
 }
 
 // setHeaders sets common headers on a request.
@@ -299,7 +694,8 @@ func (c *Client) setHeaders(req *http.Request) {
 // parseError parses an error response from the API.
 func (c *Client) parseError(resp *http.Response) error {
 	var errResp struct {
-		Error string `json:"error"`
+		Error     string `json:"error"`
+		RequestID string `json:"request_id"`
 	}
 
 	body, _ := io.ReadAll(resp.Body)
@@ -310,8 +706,13 @@ func (c *Client) parseError(resp *http.Response) error {
 		}
 	}
 
+	requestID := errResp.RequestID
+	if requestID == "" {
+		requestID = resp.Header.Get("X-Request-ID")
+	}
 	return &APIError{
 		StatusCode: resp.StatusCode,
 		Message:    errResp.Error,
+		RequestID:  requestID,
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -101,6 +102,53 @@ func TestSetHeaders_BearerToken(t *testing.T) {
 	}
 }
 
+func TestWithMiddleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Traceparent"); got != "00-abc-01" {
+			t.Errorf("expected Traceparent header, got %q", got)
+		}
+		if r.URL.Path == "/prompt" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "event: done\ndata: {\"node_id\":\"n-1\"}\n\n")
+			return
+		}
+		json.NewEncoder(w).Encode(HealthResponse{Status: "ok"})
+	}))
+	defer server.Close()
+
+	var calls []string
+	c := NewClient(server.URL,
+		WithMiddleware(func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+			calls = append(calls, "outer "+req.URL.Path)
+			req.Header.Set("Traceparent", "00-abc-01")
+			return next(req)
+		}),
+		WithMiddleware(func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+			calls = append(calls, "inner "+req.Header.Get("Traceparent"))
+			resp, err := next(req)
+			if err == nil {
+				calls = append(calls, "status "+resp.Status)
+			}
+			return resp, err
+		}),
+	)
+	if _, err := c.Health(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stream, err := c.PromptStream(context.Background(), "Hi")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := stream.Node(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"outer /health", "inner 00-abc-01", "status 200 OK", "outer /prompt", "inner 00-abc-01", "status 200 OK"}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
 func TestAPIError_401(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -305,6 +353,56 @@ func TestNodePrompt(t *testing.T) {
 	}
 }
 
+func TestNodeEdit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/nodes/user-1/edit" {
+			t.Errorf("expected POST /nodes/user-1/edit, got %s %s", r.Method, r.URL.Path)
+		}
+		var req promptRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Message != "reworded" {
+			t.Errorf("expected message reworded, got %q", req.Message)
+		}
+		json.NewEncoder(w).Encode(PromptResponse{NodeID: "reply-2", Content: "new answer"})
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	node := &Node{ID: "user-1", client: c}
+	result, err := node.Edit(context.Background(), "reworded")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ID != "reply-2" || result.Content != "new answer" {
+		t.Errorf("unexpected reply: %+v", result)
+	}
+}
+
+func TestNodeRegenerate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/nodes/reply-1/regenerate" {
+			t.Errorf("expected POST /nodes/reply-1/regenerate, got %s %s", r.Method, r.URL.Path)
+		}
+		var req regenerateRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "mock-slow" || req.Temperature == nil || *req.Temperature != 0.9 || len(req.Stop) != 1 || req.Stop[0] != "END" {
+			t.Errorf("unexpected request: %+v", req)
+		}
+		json.NewEncoder(w).Encode(PromptResponse{NodeID: "reply-2", Content: "another answer"})
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	node := &Node{ID: "reply-1", client: c}
+	result, err := node.Regenerate(context.Background(), WithModel("mock-slow"), WithTemperature(0.9), WithStopSequences("END"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ID != "reply-2" || result.Content != "another answer" {
+		t.Errorf("unexpected reply: %+v", result)
+	}
+}
+
 func TestPromptMapsResponseFieldsToNode(t *testing.T) {
 	resp := fullPromptResponse()
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -502,6 +600,315 @@ func TestGetTree(t *testing.T) {
 	}
 }
 
+func TestSearchTree(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/nodes/root-1/search" {
+			t.Errorf("expected /nodes/root-1/search, got %s", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("q"); got != "a b&c" {
+			t.Errorf("expected q=%q, got %q", "a b&c", got)
+		}
+		json.NewEncoder(w).Encode([]SearchMatch{
+			{Node: Node{ID: "child-1", Type: NodeTypeUser, Content: "a b&c"}, Path: []string{"root-1", "child-1"}, Snippet: "a b&c"},
+		})
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	matches, err := c.SearchTree(context.Background(), "root-1", "a b&c")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 match, got %d", len(matches))
+	}
+	if len(matches[0].Path) != 2 {
+		t.Errorf("expected path of 2 nodes, got %v", matches[0].Path)
+	}
+	if matches[0].Node.client == nil {
+		t.Error("expected client to be set on matched node")
+	}
+}
+
+func TestCancelTree(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/nodes/child-1/cancel" {
+			t.Errorf("expected POST /nodes/child-1/cancel, got %s %s", r.Method, r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(CancelResult{RootID: "root-1", Cancelled: 1})
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	result, err := c.CancelTree(context.Background(), "child-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.RootID != "root-1" || result.Cancelled != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestExportImportDAGStreams(t *testing.T) {
+	const exported = `{"version":1,"nodes":[]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/nodes/root-1/export":
+			if got := r.URL.Query().Get("format"); got != "json" {
+				t.Errorf("expected format=json, got %q", got)
+			}
+			w.Write([]byte(exported))
+		case r.Method == http.MethodGet && r.URL.Path == "/nodes/missing/export":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"node not found"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/nodes/import":
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != exported {
+				t.Errorf("unexpected import body %q", body)
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"root-1","node_type":"user"}`))
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	export, err := c.ExportDAG(context.Background(), "root-1", "json")
+	if err != nil {
+		t.Fatalf("ExportDAG: %v", err)
+	}
+	defer export.Close()
+	root, err := c.ImportDAG(context.Background(), export)
+	if err != nil {
+		t.Fatalf("ImportDAG: %v", err)
+	}
+	if root.ID != "root-1" || root.client == nil {
+		t.Errorf("unexpected root: %+v", root)
+	}
+
+	_, err = c.ExportDAG(context.Background(), "missing", "")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsNotFound() {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/nodes/leaf-1/clone" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"root":{"id":"new-root","forked_from_dag":"root-1","forked_from_node":"leaf-1"},"node":{"id":"new-leaf","parent_id":"new-mid","root_id":"new-root"}}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	result, err := c.Clone(context.Background(), "leaf-1")
+	if err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	if result.Root.ForkedFromDAG != "root-1" || result.Root.ForkedFromNode != "leaf-1" {
+		t.Errorf("unexpected lineage: %+v", result.Root)
+	}
+	if result.Node.ID != "new-leaf" || result.Node.client == nil {
+		t.Errorf("unexpected node: %+v", result.Node)
+	}
+}
+
+func TestListRootsByTag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/nodes" || r.URL.Query().Get("tag") != "databases" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+		}
+		w.Write([]byte(`[{"id":"root-1","tags":["databases","go"]}]`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	nodes, err := c.ListRootsByTag(context.Background(), "databases")
+	if err != nil {
+		t.Fatalf("ListRootsByTag: %v", err)
+	}
+	if len(nodes) != 1 || len(nodes[0].Tags) != 2 || nodes[0].client == nil {
+		t.Errorf("unexpected nodes: %+v", nodes)
+	}
+}
+
+func TestSetTitle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/nodes/leaf-1" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["title"] != "Cache design" {
+			t.Errorf("unexpected request body: %v", req)
+		}
+		w.Write([]byte(`{"id":"root-1","title":"Cache design"}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	root, err := c.SetTitle(context.Background(), "leaf-1", "Cache design")
+	if err != nil {
+		t.Fatalf("SetTitle: %v", err)
+	}
+	if root.ID != "root-1" || root.Title != "Cache design" || root.client == nil {
+		t.Errorf("unexpected root: %+v", root)
+	}
+}
+
+func TestUpdateDAG(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/nodes/root-1" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		var req map[string]json.RawMessage
+		json.NewDecoder(r.Body).Decode(&req)
+		if string(req["system_prompt"]) != `""` || string(req["model"]) != `"claude-haiku-4-5"` || req["title"] != nil {
+			t.Errorf("unexpected request body: %s", req)
+		}
+		w.Write([]byte(`{"id":"root-1","model":"claude-haiku-4-5"}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	empty, model := "", "claude-haiku-4-5"
+	root, err := c.UpdateDAG(context.Background(), "root-1", DAGUpdate{SystemPrompt: &empty, Model: &model})
+	if err != nil {
+		t.Fatalf("UpdateDAG: %v", err)
+	}
+	if root.Model != model || root.client == nil {
+		t.Errorf("unexpected root: %+v", root)
+	}
+}
+
+func TestComments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/nodes/node-1/comments" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if r.Method == http.MethodPost {
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			if req["body"] != "Wrong figure" || req["reply_to"] != "c-1" {
+				t.Errorf("unexpected request body: %v", req)
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"c-2","node_id":"node-1","reply_to":"c-1","author":"alice","body":"Wrong figure"}`))
+			return
+		}
+		w.Write([]byte(`[{"id":"c-1","node_id":"node-1","author":"bob","body":"Check this"}]`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	comment, err := c.AddComment(context.Background(), "node-1", "Wrong figure", "c-1")
+	if err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+	if comment.ID != "c-2" || comment.Author != "alice" {
+		t.Errorf("unexpected comment: %+v", comment)
+	}
+	comments, err := c.ListComments(context.Background(), "node-1")
+	if err != nil {
+		t.Fatalf("ListComments: %v", err)
+	}
+	if len(comments) != 1 || comments[0].Body != "Check this" {
+		t.Errorf("unexpected comments: %+v", comments)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/nodes/root-1/summarize" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		var req summarizeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "claude-haiku-4-5" {
+			t.Errorf("unexpected request body: %+v", req)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"summary-1","parent_id":"root-1","root_id":"root-1","node_type":"summary","content":"We chose Redis."}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	node, err := c.Summarize(context.Background(), "root-1", WithModel("claude-haiku-4-5"))
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if node.Type != NodeTypeSummary || node.Content != "We chose Redis." || node.client == nil {
+		t.Errorf("unexpected summary node: %+v", node)
+	}
+}
+
+func TestAsk(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/ask" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		var req askRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Question != "where is staging?" || req.DAGID != "" {
+			t.Errorf("unexpected request body: %+v", req)
+		}
+		w.Write([]byte(`{"node_id":"answer-1","answer":"On GKE [node-1]","sources":[{"node":{"id":"node-1"},"path":["root-1","node-1"],"snippet":"staging"}]}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	result, err := c.Ask(context.Background(), "", "where is staging?")
+	if err != nil {
+		t.Fatalf("Ask: %v", err)
+	}
+	if result.Answer != "On GKE [node-1]" || len(result.Sources) != 1 || result.Sources[0].Node.client == nil {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" || r.URL.Query().Get("q") != "staging cluster" || r.URL.Query().Get("limit") != "5" {
+			t.Errorf("unexpected request: %s", r.URL.String())
+		}
+		w.Write([]byte(`[{"node":{"id":"n1","node_type":"user"},"path":["n1"],"snippet":"the staging cluster"}]`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	matches, err := c.Search(context.Background(), "staging cluster", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Node.ID != "n1" || matches[0].Snippet != "the staging cluster" {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/features" {
+			t.Errorf("expected GET /features, got %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"providers":["anthropic"],"auth":"none","storage":"sqlite","tools":true,"presets":[],"workflows":false}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	features, err := c.Features(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(features.Providers) != 1 || features.Providers[0] != "anthropic" || !features.Tools || features.Auth != "none" {
+		t.Errorf("unexpected features: %+v", features)
+	}
+}
+
 func TestDeleteNode(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "DELETE" {
@@ -927,3 +1334,323 @@ func TestParseError_UnknownStatusCode(t *testing.T) {
 		t.Error("expected all convenience methods to return false for 503")
 	}
 }
+
+func TestUsage(t *testing.T) {
+	since := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/usage" || q.Get("since") != "2025-03-01T00:00:00Z" || q.Get("by") != "day" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+		}
+		w.Write([]byte(`{"since":"2025-03-01T00:00:00Z","group_by":"day","rows":[{"key":"2025-03-02","generations":2,"tokens_in":30,"tokens_out":12,"cost":{"USD":0.25}}],"total":{"key":"total","generations":2,"tokens_in":30,"tokens_out":12,"cost":{"USD":0.25}}}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	usage, err := c.Usage(context.Background(), since, "day")
+	if err != nil {
+		t.Fatalf("Usage: %v", err)
+	}
+	if len(usage.Rows) != 1 || usage.Rows[0].Key != "2025-03-02" || usage.Total.Cost["USD"] != 0.25 {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+}
+
+// treeServer serves /nodes/{id}/tree with an ETag, counting full responses.
+func treeServer(t *testing.T, trees *[]string, fullResponses *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/nodes/root-1/tree" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+		}
+		version := len(*trees) - 1
+		etag := fmt.Sprintf(`"v%d"`, version)
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		*fullResponses++
+		w.Write([]byte((*trees)[version]))
+	}))
+}
+
+func TestGetTreeCache(t *testing.T) {
+	trees := []string{`[{"id":"root-1","content":"Hi"},{"id":"reply-1","parent_id":"root-1"}]`}
+	full := 0
+	server := treeServer(t, &trees, &full)
+	defer server.Close()
+
+	c := NewClient(server.URL, WithTreeCache(10))
+	for i := 0; i < 3; i++ {
+		tree, err := c.GetTree(context.Background(), "root-1")
+		if err != nil {
+			t.Fatalf("GetTree: %v", err)
+		}
+		if len(tree.Nodes) != 2 || tree.Nodes[0].Content != "Hi" || tree.Nodes[1].client == nil {
+			t.Fatalf("unexpected tree: %+v", tree.Nodes)
+		}
+	}
+	if full != 1 {
+		t.Errorf("full responses = %d, want 1", full)
+	}
+
+	// Without the cache, every call downloads the tree.
+	c = NewClient(server.URL)
+	c.GetTree(context.Background(), "root-1")
+	c.GetTree(context.Background(), "root-1")
+	if full != 3 {
+		t.Errorf("full responses = %d, want 3", full)
+	}
+}
+
+func TestTreeRefresh(t *testing.T) {
+	trees := []string{`[{"id":"root-1"},{"id":"reply-1","parent_id":"root-1","status":"streaming"}]`}
+	full := 0
+	server := treeServer(t, &trees, &full)
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	tree, err := c.GetTree(context.Background(), "root-1")
+	if err != nil {
+		t.Fatalf("GetTree: %v", err)
+	}
+	added, err := tree.Refresh(context.Background())
+	if err != nil || len(added) != 0 || len(tree.Nodes) != 2 || full != 1 {
+		t.Fatalf("unchanged refresh: added %v, err %v, %d full responses", added, err, full)
+	}
+
+	trees = append(trees, `[{"id":"root-1"},{"id":"reply-1","parent_id":"root-1","status":"completed"},{"id":"user-2","parent_id":"reply-1"}]`)
+	added, err = tree.Refresh(context.Background())
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if len(added) != 1 || added[0].ID != "user-2" || added[0].client == nil {
+		t.Errorf("added = %+v", added)
+	}
+	if len(tree.Nodes) != 3 || tree.Nodes[1].Status != "completed" {
+		t.Errorf("unexpected tree after refresh: %+v", tree.Nodes)
+	}
+
+	if _, err := (&Tree{}).Refresh(context.Background()); err == nil {
+		t.Error("expected an error refreshing a tree not fetched with GetTree")
+	}
+}
+
+func TestPromptTo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("event: start\ndata: {}\n\n"))
+		w.Write([]byte("event: delta\ndata: {\"content\":\"Hello\"}\n\n"))
+		w.Write([]byte("event: delta\ndata: {\"content\":\", world\"}\n\n"))
+		w.Write([]byte("event: done\ndata: {\"node_id\":\"node-1\",\"content\":\"Hello, world\"}\n\n"))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	var out strings.Builder
+	node, err := c.PromptTo(context.Background(), "hi", &out)
+	if err != nil {
+		t.Fatalf("PromptTo: %v", err)
+	}
+	if out.String() != "Hello, world" {
+		t.Errorf("wrote %q, want %q", out.String(), "Hello, world")
+	}
+	if node.ID != "node-1" {
+		t.Errorf("node ID = %q, want node-1", node.ID)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestStreamWriteTo_WriterError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("event: delta\ndata: {\"content\":\"Hello\"}\n\n"))
+		w.Write([]byte("event: done\ndata: {\"node_id\":\"node-1\"}\n\n"))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	stream, err := c.PromptStream(context.Background(), "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.WriteTo(failingWriter{}); err == nil || err.Error() != "disk full" {
+		t.Errorf("WriteTo error = %v, want the writer's", err)
+	}
+}
+
+func TestStreamWriteTo_StreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("event: delta\ndata: {\"content\":\"partial\"}\n\n"))
+		w.Write([]byte("event: error\ndata: provider crashed\n\n"))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	var out strings.Builder
+	_, err := c.PromptTo(context.Background(), "hi", &out)
+	var streamErr *StreamError
+	if !errors.As(err, &streamErr) {
+		t.Fatalf("expected *StreamError, got %T: %v", err, err)
+	}
+	if out.String() != "partial" {
+		t.Errorf("wrote %q, want the partial reply", out.String())
+	}
+}
+
+func TestAPIError_RequestID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "req-42")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"boom","request_id":"req-42"}`))
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL).GetNode(context.Background(), "abc")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T", err)
+	}
+	if apiErr.RequestID != "req-42" || !strings.Contains(apiErr.Error(), "req-42") {
+		t.Errorf("error = %v, request ID %q", apiErr, apiErr.RequestID)
+	}
+}
+
+func TestPromptStreamStopsOnContextCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for {
+			if _, err := w.Write([]byte("event: delta\ndata: {\"content\":\"x\"}\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := NewClient(server.URL).PromptStream(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Receive nothing: the reader must stop anyway.
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		for range stream.Events() {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not end after the context was cancelled")
+	}
+	if err := stream.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Err = %v, want context.Canceled", err)
+	}
+}
+
+func TestRegisteredToolLoop(t *testing.T) {
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/prompt":
+			var req promptRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if len(req.Tools) != 2 || req.Tools[0].Name != "web_search" || req.Tools[1].Name != "get_weather" {
+				t.Errorf("tools = %+v, want web_search then get_weather", req.Tools)
+			}
+			json.NewEncoder(w).Encode(PromptResponse{NodeID: "call-1", Content: "Checking."})
+		case r.Method == http.MethodGet && r.URL.Path == "/nodes/call-1":
+			json.NewEncoder(w).Encode(Node{
+				ID:         "call-1",
+				StopReason: "tool_use",
+				Content:    `[{"type":"text","text":"Checking."},{"type":"tool_use","id":"tu-1","name":"get_weather","input":{"city":"Paris"}},{"type":"tool_use","id":"tu-2","name":"get_weather","input":{"city":"?"}}]`,
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/nodes/call-1/prompt":
+			var req promptRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			sent = append(sent, req.Message)
+			json.NewEncoder(w).Encode(PromptResponse{NodeID: "final", Content: "Sunny in Paris."})
+		case r.Method == http.MethodGet && r.URL.Path == "/nodes/final":
+			json.NewEncoder(w).Encode(Node{ID: "final", StopReason: "end_turn", Content: "Sunny in Paris."})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	c.RegisterTool("get_weather", "Current weather of a city", json.RawMessage(`{"type":"object"}`),
+		func(ctx context.Context, input json.RawMessage) (string, error) {
+			var in struct{ City string }
+			json.Unmarshal(input, &in)
+			if in.City == "?" {
+				return "", errors.New("unknown city")
+			}
+			return "sunny", nil
+		})
+
+	node, err := c.Prompt(context.Background(), "Weather in Paris?", WithTools([]ToolDefinition{{Name: "web_search"}}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if node.ID != "final" || node.Content != "Sunny in Paris." {
+		t.Errorf("node = %+v, want the final reply", node)
+	}
+	want := `[{"type":"tool_result","tool_use_id":"tu-1","content":"sunny"},{"type":"tool_result","tool_use_id":"tu-2","content":"unknown city","is_error":true}]`
+	if len(sent) != 1 || sent[0] != want {
+		t.Errorf("tool results = %q, want %q", sent, want)
+	}
+}
+
+func TestRegisteredToolLoopStopsOnUnregisteredTool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/nodes/node-1/prompt":
+			json.NewEncoder(w).Encode(PromptResponse{NodeID: "call-1"})
+		case "/nodes/call-1":
+			json.NewEncoder(w).Encode(Node{
+				ID:         "call-1",
+				StopReason: "tool_use",
+				Content:    `[{"type":"tool_use","id":"tu-1","name":"lookup","input":{}}]`,
+			})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	c.RegisterTool("get_weather", "", nil, func(ctx context.Context, input json.RawMessage) (string, error) {
+		t.Error("handler should not run")
+		return "", nil
+	})
+	node := &Node{ID: "node-1", client: c}
+	result, err := node.Prompt(context.Background(), "more")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ID != "call-1" {
+		t.Errorf("expected the tool call reply, got %s", result.ID)
+	}
+}
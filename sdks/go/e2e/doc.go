@@ -0,0 +1,8 @@
+// Package e2e holds end-to-end tests that build `langdag`, start
+// `langdag serve` on a random port with the mock provider (or against the
+// mockllm server), and run SDK and CLI flows against it, checking the DAGs
+// the server stored.
+//
+// The tests are skipped unless LANGDAG_E2E=1; run them with `make e2e`,
+// or `make e2e-mockllm` to go through the Anthropic provider and mockllm.
+package e2e
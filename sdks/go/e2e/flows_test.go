@@ -0,0 +1,163 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	langdag "langdag.com/langdag-go"
+)
+
+// TestChatForkDelete chats, branches, streams and clones through the SDK,
+// then checks what the server stored, read back by the CLI.
+func TestChatForkDelete(t *testing.T) {
+	s := startServer(t)
+	c := langdag.NewClient(s.URL)
+	ctx := context.Background()
+
+	reply, err := c.Prompt(ctx, "Hello from the e2e harness")
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	if !strings.Contains(reply.Content, "Hello from the e2e harness") {
+		t.Errorf("echoed reply = %q", reply.Content)
+	}
+	next, err := reply.Prompt(ctx, "Second message")
+	if err != nil {
+		t.Fatalf("continue: %v", err)
+	}
+	// Fork: a second branch from the first reply.
+	if _, err := reply.Prompt(ctx, "Alternative second message"); err != nil {
+		t.Fatalf("fork: %v", err)
+	}
+	stream, err := next.PromptStream(ctx, "Streamed message")
+	if err != nil {
+		t.Fatalf("PromptStream: %v", err)
+	}
+	for range stream.Events() {
+	}
+	streamed, err := stream.Node()
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if !strings.Contains(stream.Content(), "Streamed message") {
+		t.Errorf("streamed content = %q", stream.Content())
+	}
+
+	rootID := dagRoot(t, c, reply.ID)
+	stored := s.storedDAG(t, rootID)
+	if len(stored) != 8 {
+		t.Fatalf("stored %d nodes, want 8: %+v", len(stored), stored)
+	}
+	children := map[string]int{}
+	ids := map[string]bool{}
+	for _, n := range stored {
+		children[n.ParentID]++
+		ids[n.ID] = true
+	}
+	if children[reply.ID] != 2 {
+		t.Errorf("first reply has %d children in the store, want 2 (fork)", children[reply.ID])
+	}
+	if !ids[streamed.ID] {
+		t.Errorf("streamed reply %s was not stored", streamed.ID)
+	}
+	tree, err := c.GetTree(ctx, rootID)
+	if err != nil {
+		t.Fatalf("GetTree: %v", err)
+	}
+	if len(tree.Nodes) != len(stored) {
+		t.Errorf("GetTree returned %d nodes, the store has %d", len(tree.Nodes), len(stored))
+	}
+
+	clone, err := c.Clone(ctx, next.ID)
+	if err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	if got := len(s.storedDAG(t, clone.Root.ID)); got != 4 {
+		t.Errorf("clone stored %d nodes, want the 4 on the path", got)
+	}
+
+	if err := c.DeleteNode(ctx, clone.Root.ID); err != nil {
+		t.Fatalf("DeleteNode: %v", err)
+	}
+	roots := s.storedRoots(t)
+	if roots[clone.Root.ID] || !roots[rootID] {
+		t.Errorf("stored roots after delete = %v, want %s only", roots, rootID)
+	}
+}
+
+// TestCLIDeletesServerDAG deletes a DAG started through the API with the
+// CLI, checking that the server no longer has it.
+func TestCLIDeletesServerDAG(t *testing.T) {
+	s := startServer(t)
+	c := langdag.NewClient(s.URL)
+	ctx := context.Background()
+
+	reply, err := c.Prompt(ctx, "Started through the API")
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	rootID := dagRoot(t, c, reply.ID)
+	if !s.storedRoots(t)[rootID] {
+		t.Fatalf("`langdag ls` does not list %s", rootID)
+	}
+	s.cli(t, "--non-interactive", "rm", rootID, "--yes")
+	_, err = c.GetNode(ctx, rootID)
+	var apiErr *langdag.APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsNotFound() {
+		t.Errorf("GetNode after `langdag rm` = %v, want 404", err)
+	}
+}
+
+// TestCLIContinuesServerDAG continues a DAG started through the API with
+// `langdag prompt`. The CLI has no built-in mock provider, so this needs
+// mockllm.
+func TestCLIContinuesServerDAG(t *testing.T) {
+	if !useMockLLM() {
+		t.Skip("needs LANGDAG_E2E_PROVIDER=mockllm")
+	}
+	s := startServer(t)
+	c := langdag.NewClient(s.URL)
+	ctx := context.Background()
+
+	reply, err := c.Prompt(ctx, "Started through the API")
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	if out := s.cli(t, "prompt", reply.ID, "Continued from the CLI"); !strings.Contains(out, "Continued from the CLI") {
+		t.Errorf("CLI reply = %q", out)
+	}
+	tree, err := c.GetTree(ctx, dagRoot(t, c, reply.ID))
+	if err != nil {
+		t.Fatalf("GetTree: %v", err)
+	}
+	if len(tree.Nodes) != 4 || tree.Nodes[2].Content != "Continued from the CLI" {
+		t.Errorf("server does not see the CLI's messages: %+v", tree.Nodes)
+	}
+}
+
+// dagRoot returns the root ID of the DAG containing nodeID.
+func dagRoot(t *testing.T, c *langdag.Client, nodeID string) string {
+	t.Helper()
+	node, err := c.GetNode(context.Background(), nodeID)
+	if err != nil {
+		t.Fatalf("GetNode: %v", err)
+	}
+	return node.RootID
+}
+
+// storedRoots returns the IDs of the stored DAGs, listed by the CLI.
+func (s *server) storedRoots(t *testing.T) map[string]bool {
+	t.Helper()
+	var roots []storedNode
+	if err := json.Unmarshal([]byte(s.cli(t, "ls", "--json")), &roots); err != nil {
+		t.Fatalf("decode `langdag ls --json`: %v", err)
+	}
+	ids := make(map[string]bool, len(roots))
+	for _, r := range roots {
+		ids[r.ID] = true
+	}
+	return ids
+}
@@ -0,0 +1,206 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Binaries built by TestMain.
+var (
+	langdagBin string
+	mockllmBin string
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("LANGDAG_E2E") != "1" {
+		fmt.Println("LANGDAG_E2E not set to 1, skipping end-to-end tests")
+		os.Exit(m.Run())
+	}
+	dir, err := os.MkdirTemp("", "langdag-e2e-bin")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := func() int {
+		defer os.RemoveAll(dir)
+		root, err := filepath.Abs(filepath.Join("..", "..", ".."))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		langdagBin = filepath.Join(dir, "langdag")
+		if err := goBuild(root, langdagBin, "./cmd/langdag"); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if useMockLLM() {
+			mockllmBin = filepath.Join(dir, "mockllm")
+			if err := goBuild(filepath.Join(root, "tools", "mockllm"), mockllmBin, "."); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+		}
+		return m.Run()
+	}()
+	os.Exit(code)
+}
+
+// useMockLLM reports whether the server talks to mockllm through the
+// Anthropic provider rather than using the built-in mock provider.
+func useMockLLM() bool {
+	return os.Getenv("LANGDAG_E2E_PROVIDER") == "mockllm"
+}
+
+func goBuild(dir, out, pkg string) error {
+	cmd := exec.Command("go", "build", "-o", out, pkg)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("go build %s: %v\n%s", pkg, err, output)
+	}
+	return nil
+}
+
+// server is a running `langdag serve`, with the environment its CLI
+// commands share: the same database, config directory and provider.
+type server struct {
+	URL    string
+	env    []string
+	cliEnv []string
+	dir    string
+}
+
+// startServer starts `langdag serve` in a fresh directory with its own
+// database, and stops it when the test ends.
+func startServer(t *testing.T) *server {
+	t.Helper()
+	if langdagBin == "" {
+		t.Skip("LANGDAG_E2E not set to 1")
+	}
+	dir := t.TempDir()
+	s := &server{dir: dir, env: []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + dir, // no user config, history or telemetry
+		"DO_NOT_TRACK=1",
+		"LANGDAG_STORAGE_PATH=" + filepath.Join(dir, "langdag.db"),
+	}}
+	if useMockLLM() {
+		port := freePort(t)
+		start(t, dir, nil, mockllmBin, "--port", fmt.Sprint(port), "--mode", "echo", "--chunk-delay", "0")
+		waitHealthy(t, fmt.Sprintf("http://127.0.0.1:%d", port))
+		s.env = append(s.env,
+			"LANGDAG_PROVIDER=anthropic",
+			"ANTHROPIC_API_KEY=e2e",
+			fmt.Sprintf("ANTHROPIC_BASE_URL=http://127.0.0.1:%d", port),
+		)
+		s.cliEnv = s.env
+	} else {
+		// The CLI has no mock provider; its commands used here that do not
+		// prompt never call the provider.
+		s.cliEnv = append(s.env[:len(s.env):len(s.env)], "LANGDAG_PROVIDER=anthropic", "ANTHROPIC_API_KEY=unused")
+		s.env = append(s.env, "LANGDAG_PROVIDER=mock", "LANGDAG_MOCK_MODE=echo")
+	}
+
+	port := freePort(t)
+	s.URL = fmt.Sprintf("http://127.0.0.1:%d", port)
+	start(t, dir, s.env, langdagBin, "serve", "--host", "127.0.0.1", "--port", fmt.Sprint(port))
+	waitHealthy(t, s.URL)
+	return s
+}
+
+// start runs a long-lived process until the test ends.
+func start(t *testing.T, dir string, env []string, bin string, args ...string) {
+	t.Helper()
+	var logs bytes.Buffer
+	cmd := exec.Command(bin, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout, cmd.Stderr = &logs, &logs
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start %s: %v", filepath.Base(bin), err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Signal(os.Interrupt)
+		done := make(chan struct{})
+		go func() { cmd.Wait(); close(done) }()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			cmd.Process.Kill()
+			<-done
+		}
+		if t.Failed() {
+			t.Logf("%s output:\n%s", filepath.Base(bin), logs.String())
+		}
+	})
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func waitHealthy(t *testing.T, url string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	for ctx.Err() == nil {
+		if resp, err := http.Get(url + "/health"); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("%s did not become healthy", url)
+}
+
+// cli runs a langdag command against the server's database and returns
+// its standard output.
+func (s *server) cli(t *testing.T, args ...string) string {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(langdagBin, args...)
+	cmd.Dir = s.dir
+	cmd.Env = s.cliEnv
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("langdag %v: %v\n%s", args, err, stderr.String())
+	}
+	return stdout.String()
+}
+
+// storedNode is a node as printed by `langdag show --json`.
+type storedNode struct {
+	ID       string `json:"id"`
+	ParentID string `json:"parent_id"`
+	RootID   string `json:"root_id"`
+	NodeType string `json:"node_type"`
+	Content  string `json:"content"`
+}
+
+// storedDAG returns the nodes stored below id, read by the CLI from the
+// database rather than through the server.
+func (s *server) storedDAG(t *testing.T, id string) []storedNode {
+	t.Helper()
+	var nodes []storedNode
+	if err := json.Unmarshal([]byte(s.cli(t, "show", id, "--json")), &nodes); err != nil {
+		t.Fatalf("decode `langdag show --json`: %v", err)
+	}
+	return nodes
+}
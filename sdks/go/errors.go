@@ -8,9 +8,14 @@ import (
 type APIError struct {
 	StatusCode int
 	Message    string
+	// RequestID identifies the request in the server's logs.
+	RequestID string
 }
 
 func (e *APIError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("langdag: API error (status %d, request %s): %s", e.StatusCode, e.RequestID, e.Message)
+	}
 	return fmt.Sprintf("langdag: API error (status %d): %s", e.StatusCode, e.Message)
 }
 
@@ -0,0 +1,313 @@
+// Package langdagtest provides an in-process fake LangDAG server for testing
+// code built on the Go SDK without a real server or model.
+//
+// Prompts are answered with scripted replies, in order, and every request
+// is recorded:
+//
+//	srv := langdagtest.NewServer(t, langdagtest.Reply{Content: "Hi!"})
+//	client := srv.Client()
+//	node, err := client.Prompt(ctx, "Hello")
+//	// node.Content == "Hi!", srv.Calls()[0].Message == "Hello"
+//
+// The fake keeps the nodes it creates, so Node.Prompt, Edit, Regenerate,
+// GetNode, GetTree, ListRoots and DeleteNode work as against a server.
+// Other endpoints answer 404.
+package langdagtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	langdag "langdag.com/langdag-go"
+)
+
+// Reply is a scripted answer to a prompt.
+type Reply struct {
+	Content   string
+	Model     string
+	TokensIn  int
+	TokensOut int
+	// Chunks splits a streamed reply into deltas; by default it is sent
+	// as one delta. Their concatenation should equal Content.
+	Chunks []string
+	// Error fails the prompt with this message, as an API error with
+	// Status (default 500), or as an SSE error event when streaming.
+	Error  string
+	Status int
+}
+
+// Call is a request received by the fake server.
+type Call struct {
+	Method string
+	Path   string
+	// NodeID is the node in the path, if any.
+	NodeID string
+	// Message, Model, SystemPrompt and Stream are read from prompt bodies.
+	Message      string
+	Model        string
+	SystemPrompt string
+	Stream       bool
+}
+
+// Server is a fake LangDAG server. Its methods are safe for concurrent use.
+type Server struct {
+	// URL is the base URL of the server.
+	URL string
+
+	t   testing.TB
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	replies []Reply
+	calls   []Call
+	nodes   map[string]*langdag.Node
+	order   []string // node IDs in creation order, including deleted ones
+}
+
+// NewServer starts a fake server answering prompts with replies, in order.
+// It is closed when the test ends.
+func NewServer(t testing.TB, replies ...Reply) *Server {
+	t.Helper()
+	s := &Server{
+		t:       t,
+		replies: append([]Reply(nil), replies...),
+		nodes:   make(map[string]*langdag.Node),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+// Client returns a client of the server.
+func (s *Server) Client(opts ...langdag.Option) *langdag.Client {
+	return langdag.NewClient(s.URL, opts...)
+}
+
+// Reply queues more scripted replies. A prompt with no reply left fails
+// the test.
+func (s *Server) Reply(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, replies...)
+}
+
+// Calls returns the requests received so far, oldest first.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// Nodes returns the stored nodes in creation order.
+func (s *Server) Nodes() []langdag.Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedNodes(func(*langdag.Node) bool { return true })
+}
+
+// promptBody is the union of the prompt and regenerate request bodies.
+type promptBody struct {
+	Message      string `json:"message"`
+	Model        string `json:"model"`
+	SystemPrompt string `json:"system_prompt"`
+	Stream       bool   `json:"stream"`
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	call := Call{Method: r.Method, Path: r.URL.Path}
+	if len(parts) >= 2 && parts[0] == "nodes" {
+		call.NodeID = parts[1]
+	}
+	var body promptBody
+	if r.Method == http.MethodPost {
+		json.NewDecoder(r.Body).Decode(&body)
+		call.Message, call.Model, call.SystemPrompt, call.Stream = body.Message, body.Model, body.SystemPrompt, body.Stream
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/health":
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	case r.Method == http.MethodPost && r.URL.Path == "/prompt":
+		user := s.addNode(nil, langdag.NodeTypeUser, body.Message, "")
+		user.SystemPrompt = body.SystemPrompt
+		s.reply(w, user, body)
+	case r.Method == http.MethodGet && r.URL.Path == "/nodes":
+		writeJSON(w, http.StatusOK, s.sortedNodes(func(n *langdag.Node) bool { return n.ParentID == "" }))
+	case call.NodeID == "":
+		s.notSupported(w, r)
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		if node := s.node(w, call.NodeID); node != nil {
+			writeJSON(w, http.StatusOK, node)
+		}
+	case len(parts) == 2 && r.Method == http.MethodDelete:
+		if node := s.node(w, call.NodeID); node != nil {
+			s.deleteSubtree(node.ID)
+			writeJSON(w, http.StatusOK, map[string]string{"status": "deleted", "id": node.ID})
+		}
+	case len(parts) == 3 && r.Method == http.MethodGet && parts[2] == "tree":
+		if node := s.node(w, call.NodeID); node != nil {
+			writeJSON(w, http.StatusOK, s.sortedNodes(func(n *langdag.Node) bool { return n.RootID == node.RootID }))
+		}
+	case len(parts) == 3 && r.Method == http.MethodPost && parts[2] == "prompt":
+		if node := s.node(w, call.NodeID); node != nil {
+			s.reply(w, s.addNode(node, langdag.NodeTypeUser, body.Message, ""), body)
+		}
+	case len(parts) == 3 && r.Method == http.MethodPost && parts[2] == "edit":
+		node := s.node(w, call.NodeID)
+		if node == nil {
+			return
+		}
+		if node.Type != langdag.NodeTypeUser || node.ParentID == "" {
+			writeError(w, http.StatusBadRequest, "only user messages below the root can be edited")
+			return
+		}
+		s.reply(w, s.addNode(s.nodes[node.ParentID], langdag.NodeTypeUser, body.Message, ""), body)
+	case len(parts) == 3 && r.Method == http.MethodPost && parts[2] == "regenerate":
+		node := s.node(w, call.NodeID)
+		if node == nil {
+			return
+		}
+		if node.Type == langdag.NodeTypeAssistant {
+			node = s.nodes[node.ParentID]
+		}
+		s.reply(w, node, body)
+	default:
+		s.notSupported(w, r)
+	}
+}
+
+func (s *Server) notSupported(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, fmt.Sprintf("langdagtest: %s %s is not supported", r.Method, r.URL.Path))
+}
+
+// node returns the stored node id, or writes a 404.
+func (s *Server) node(w http.ResponseWriter, id string) *langdag.Node {
+	node, ok := s.nodes[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "node not found")
+		return nil
+	}
+	return node
+}
+
+// reply answers a prompt below parent with the next scripted reply.
+func (s *Server) reply(w http.ResponseWriter, parent *langdag.Node, body promptBody) {
+	if len(s.replies) == 0 {
+		s.t.Errorf("langdagtest: no scripted reply left for prompt %q", body.Message)
+		writeError(w, http.StatusInternalServerError, "langdagtest: no scripted reply")
+		return
+	}
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+
+	if reply.Error != "" && !body.Stream {
+		status := reply.Status
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		writeError(w, status, reply.Error)
+		return
+	}
+	model := reply.Model
+	if model == "" {
+		model = body.Model
+	}
+
+	if !body.Stream {
+		node := s.addNode(parent, langdag.NodeTypeAssistant, reply.Content, model)
+		node.TokensIn, node.TokensOut = reply.TokensIn, reply.TokensOut
+		writeJSON(w, http.StatusOK, promptResponse(node))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "event: start\ndata: {}\n\n")
+	chunks := reply.Chunks
+	if chunks == nil && reply.Content != "" {
+		chunks = []string{reply.Content}
+	}
+	for _, chunk := range chunks {
+		data, _ := json.Marshal(map[string]string{"content": chunk})
+		fmt.Fprintf(w, "event: delta\ndata: %s\n\n", data)
+	}
+	if reply.Error != "" {
+		fmt.Fprintf(w, "event: error\ndata: %s\n\n", reply.Error)
+		return
+	}
+	node := s.addNode(parent, langdag.NodeTypeAssistant, reply.Content, model)
+	node.TokensIn, node.TokensOut = reply.TokensIn, reply.TokensOut
+	data, _ := json.Marshal(promptResponse(node))
+	fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+}
+
+func promptResponse(node *langdag.Node) langdag.PromptResponse {
+	return langdag.PromptResponse{
+		NodeID:    node.ID,
+		Content:   node.Content,
+		TokensIn:  node.TokensIn,
+		TokensOut: node.TokensOut,
+	}
+}
+
+// addNode stores a new node below parent, or a new root when parent is nil.
+func (s *Server) addNode(parent *langdag.Node, typ langdag.NodeType, content, model string) *langdag.Node {
+	node := &langdag.Node{
+		ID:        fmt.Sprintf("node-%d", len(s.order)+1),
+		Type:      typ,
+		Content:   content,
+		Model:     model,
+		CreatedAt: time.Now().UTC(),
+	}
+	node.RootID = node.ID
+	if parent != nil {
+		node.ParentID = parent.ID
+		node.RootID = parent.RootID
+		node.Sequence = parent.Sequence + 1
+	}
+	s.nodes[node.ID] = node
+	s.order = append(s.order, node.ID)
+	return node
+}
+
+func (s *Server) deleteSubtree(id string) {
+	for _, n := range s.nodes {
+		if n.ParentID == id {
+			s.deleteSubtree(n.ID)
+		}
+	}
+	delete(s.nodes, id)
+}
+
+// sortedNodes returns copies of the nodes matching keep, oldest first.
+func (s *Server) sortedNodes(keep func(*langdag.Node) bool) []langdag.Node {
+	nodes := []langdag.Node{}
+	for _, id := range s.order {
+		if n, ok := s.nodes[id]; ok && keep(n) {
+			nodes = append(nodes, *n)
+		}
+	}
+	return nodes
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
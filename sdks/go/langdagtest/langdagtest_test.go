@@ -0,0 +1,115 @@
+package langdagtest_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	langdag "langdag.com/langdag-go"
+	"langdag.com/langdag-go/langdagtest"
+)
+
+func TestServer_Conversation(t *testing.T) {
+	srv := langdagtest.NewServer(t,
+		langdagtest.Reply{Content: "Hi!", Model: "fake-model", TokensIn: 5, TokensOut: 2},
+		langdagtest.Reply{Content: "Fine, thanks.", Chunks: []string{"Fine, ", "thanks."}},
+	)
+	client := srv.Client()
+	ctx := context.Background()
+
+	first, err := client.Prompt(ctx, "Hello", langdag.WithSystem("Be brief."))
+	if err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	if first.Content != "Hi!" || first.TokensOut != 2 {
+		t.Errorf("first reply = %+v", first)
+	}
+
+	var out strings.Builder
+	stream, err := first.PromptStream(ctx, "How are you?")
+	if err != nil {
+		t.Fatalf("PromptStream: %v", err)
+	}
+	if _, err := stream.WriteTo(&out); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	second, err := stream.Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Fine, thanks." {
+		t.Errorf("streamed %q", out.String())
+	}
+
+	tree, err := client.GetTree(ctx, second.ID)
+	if err != nil {
+		t.Fatalf("GetTree: %v", err)
+	}
+	if len(tree.Nodes) != 4 {
+		t.Fatalf("tree has %d nodes, want 4", len(tree.Nodes))
+	}
+	root := tree.Nodes[0]
+	if root.Content != "Hello" || root.SystemPrompt != "Be brief." || root.ParentID != "" {
+		t.Errorf("root = %+v", root)
+	}
+	if tree.Nodes[3].ParentID != tree.Nodes[2].ID || tree.Nodes[3].RootID != root.ID {
+		t.Errorf("last reply is not below the second message: %+v", tree.Nodes[3])
+	}
+
+	calls := srv.Calls()
+	if len(calls) != 3 {
+		t.Fatalf("got %d calls, want 3", len(calls))
+	}
+	if calls[1].Path != "/nodes/"+first.ID+"/prompt" || calls[1].Message != "How are you?" || !calls[1].Stream {
+		t.Errorf("second call = %+v", calls[1])
+	}
+}
+
+func TestServer_ReplyError(t *testing.T) {
+	srv := langdagtest.NewServer(t, langdagtest.Reply{Error: "overloaded", Status: 503})
+
+	_, err := srv.Client().Prompt(context.Background(), "Hello")
+	var apiErr *langdag.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 503 || apiErr.Message != "overloaded" {
+		t.Fatalf("err = %v, want a 503 API error", err)
+	}
+}
+
+func TestServer_RegenerateAndDelete(t *testing.T) {
+	srv := langdagtest.NewServer(t)
+	srv.Reply(langdagtest.Reply{Content: "one"}, langdagtest.Reply{Content: "two"})
+	client := srv.Client()
+	ctx := context.Background()
+
+	reply, err := client.Prompt(ctx, "Pick a number")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := client.GetNode(ctx, reply.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := fetched.Regenerate(ctx)
+	if err != nil {
+		t.Fatalf("Regenerate: %v", err)
+	}
+	if other.Content != "two" {
+		t.Errorf("regenerated %q, want two", other.Content)
+	}
+	nodes := srv.Nodes()
+	if len(nodes) != 3 || nodes[1].ParentID != nodes[0].ID || nodes[2].ParentID != nodes[0].ID {
+		t.Fatalf("nodes = %+v, want a root with two replies", nodes)
+	}
+
+	if err := client.DeleteNode(ctx, nodes[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := client.ListRoots(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 0 || len(srv.Nodes()) != 0 {
+		t.Errorf("nodes left after deleting the root: %+v", srv.Nodes())
+	}
+}
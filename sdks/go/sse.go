@@ -2,6 +2,7 @@ package langdag
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"io"
 	"strings"
@@ -10,11 +11,14 @@ import (
 
 // SSEEvent represents a Server-Sent Event.
 type SSEEvent struct {
-	Type     string
-	Content  string // For delta events
-	NodeID   string // For done events
-	Error    string // For error events
-	Response *PromptResponse
+	Type        string
+	Content     string      // For delta events
+	NodeID      string      // For done, timeout and suggestions events
+	Error       string      // For error and timeout events
+	Suggestions []string    // For suggestions events
+	ToolCall    *ToolCall   // For tool_call events
+	ToolResult  *ToolResult // For tool_result events
+	Response    *PromptResponse
 }
 
 // Stream wraps an SSE response and provides a channel-based API.
@@ -27,6 +31,10 @@ type Stream struct {
 	err      error
 	content  strings.Builder
 	done     sync.WaitGroup
+
+	closed    chan struct{} // closed by Close
+	closeOnce sync.Once
+	closeErr  error // the stream error after Close, e.g. the context's
 }
 
 // newStream creates a new Stream from an HTTP response body.
@@ -35,20 +43,63 @@ func newStream(body io.ReadCloser, client *Client) *Stream {
 		events: make(chan SSEEvent, 64),
 		body:   body,
 		client: client,
+		closed: make(chan struct{}),
 	}
 	s.done.Add(1)
 	go s.read()
 	return s
 }
 
-// Events returns a channel that yields SSE events.
+// Events returns a channel that yields SSE events. It is closed when the
+// stream ends or is closed.
 func (s *Stream) Events() <-chan SSEEvent {
 	return s.events
 }
 
-// Node waits for the stream to complete and returns the resulting node.
-// Must be called after draining Events().
+// Next returns the next event, waiting until one arrives or ctx is done.
+// After the last event, it returns the stream error if there is one (see
+// Err), or else io.EOF. Next and Events yield the same events: use one or
+// the other.
+func (s *Stream) Next(ctx context.Context) (SSEEvent, error) {
+	select {
+	case event, ok := <-s.events:
+		if !ok {
+			if s.err != nil {
+				return SSEEvent{}, s.err
+			}
+			return SSEEvent{}, io.EOF
+		}
+		return event, nil
+	case <-ctx.Done():
+		return SSEEvent{}, ctx.Err()
+	}
+}
+
+// Close stops reading the stream and releases its connection. Events not
+// yet received are discarded. It is safe to call Close more than once, and
+// after the stream ended. The generation goes on on the server; cancel it
+// with CancelTree.
+func (s *Stream) Close() error {
+	s.close(nil)
+	return nil
+}
+
+// close stops the reader, which reports err as the stream error, and waits
+// for it to return.
+func (s *Stream) close(err error) {
+	s.closeOnce.Do(func() {
+		s.closeErr = err
+		close(s.closed)
+		s.body.Close()
+	})
+	s.done.Wait()
+}
+
+// Node waits for the stream to end, discarding the events not yet
+// received, and returns the resulting node.
 func (s *Stream) Node() (*Node, error) {
+	for range s.events {
+	}
 	if s.err != nil {
 		return nil, s.err
 	}
@@ -71,6 +122,31 @@ func (s *Stream) Content() string {
 	return s.content.String()
 }
 
+// WriteTo writes the text of delta events to w as they arrive, until the
+// stream ends, and returns the number of bytes written. It returns the
+// stream error, if any; call Node afterwards for the resulting node. If w
+// fails, WriteTo stops reading the stream and returns w's error.
+func (s *Stream) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	var writeErr error
+	for event := range s.events {
+		if event.Type != "delta" || writeErr != nil {
+			continue
+		}
+		n, err := io.WriteString(w, event.Content)
+		written += int64(n)
+		if err != nil {
+			writeErr = err
+			// Stop the reader; the remaining events are discarded.
+			s.Close()
+		}
+	}
+	if writeErr != nil {
+		return written, writeErr
+	}
+	return written, s.err
+}
+
 // Err returns the stream-level error, if any. This includes errors from
 // SSE error events and I/O errors from the underlying connection.
 // Safe to call after draining Events().
@@ -83,6 +159,11 @@ func (s *Stream) read() {
 	defer s.done.Done()
 	defer close(s.events)
 	defer s.body.Close()
+	defer func() {
+		if s.isClosed() && s.closeErr != nil {
+			s.err = s.closeErr
+		}
+	}()
 
 	scanner := bufio.NewScanner(s.body)
 	var eventType string
@@ -93,18 +174,9 @@ func (s *Stream) read() {
 
 		if line == "" {
 			if eventType != "" && len(dataLines) > 0 {
-				event := s.parseEvent(eventType, strings.Join(dataLines, "\n"))
-				if event.Type == "delta" {
-					s.content.WriteString(event.Content)
-				}
-				if event.Type == "done" {
-					s.nodeID = event.NodeID
-					s.doneResp = event.Response
-				}
-				if event.Type == "error" {
-					s.err = &StreamError{Message: event.Error}
+				if !s.emit(eventType, strings.Join(dataLines, "\n")) {
+					return
 				}
-				s.events <- event
 			}
 			eventType = ""
 			dataLines = nil
@@ -124,25 +196,52 @@ func (s *Stream) read() {
 
 	// Handle any remaining event without trailing newline
 	if eventType != "" && len(dataLines) > 0 {
-		event := s.parseEvent(eventType, strings.Join(dataLines, "\n"))
-		if event.Type == "delta" {
-			s.content.WriteString(event.Content)
+		if !s.emit(eventType, strings.Join(dataLines, "\n")) {
+			return
 		}
-		if event.Type == "done" {
-			s.nodeID = event.NodeID
-			s.doneResp = event.Response
-		}
-		if event.Type == "error" {
-			s.err = &StreamError{Message: event.Error}
-		}
-		s.events <- event
 	}
 
-	if err := scanner.Err(); err != nil {
+	// After Close, the read error is Close's doing.
+	if err := scanner.Err(); err != nil && !s.isClosed() {
 		s.err = err
 	}
 }
 
+// isClosed reports whether Close was called.
+func (s *Stream) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// emit records an event's effect on the stream and sends it on the
+// channel. It reports false if the stream was closed instead.
+func (s *Stream) emit(eventType, data string) bool {
+	event := s.parseEvent(eventType, data)
+	if event.Type == "delta" {
+		s.content.WriteString(event.Content)
+	}
+	if event.Type == "done" {
+		s.nodeID = event.NodeID
+		s.doneResp = event.Response
+	}
+	if event.Type == "error" {
+		s.err = &StreamError{Message: event.Error}
+	}
+	if s.isClosed() {
+		return false
+	}
+	select {
+	case s.events <- event:
+		return true
+	case <-s.closed:
+		return false
+	}
+}
+
 // parseEvent converts raw SSE data into a typed SSEEvent.
 func (s *Stream) parseEvent(eventType, data string) SSEEvent {
 	event := SSEEvent{Type: eventType}
@@ -163,6 +262,34 @@ func (s *Stream) parseEvent(eventType, data string) SSEEvent {
 			event.NodeID = d.NodeID
 			event.Response = &d
 		}
+	case "timeout":
+		var d struct {
+			NodeID string `json:"node_id"`
+			Error  string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &d); err == nil {
+			event.NodeID = d.NodeID
+			event.Error = d.Error
+		}
+	case "suggestions":
+		var d struct {
+			NodeID      string   `json:"node_id"`
+			Suggestions []string `json:"suggestions"`
+		}
+		if err := json.Unmarshal([]byte(data), &d); err == nil {
+			event.NodeID = d.NodeID
+			event.Suggestions = d.Suggestions
+		}
+	case "tool_call":
+		var d ToolCall
+		if err := json.Unmarshal([]byte(data), &d); err == nil {
+			event.ToolCall = &d
+		}
+	case "tool_result":
+		var d ToolResult
+		if err := json.Unmarshal([]byte(data), &d); err == nil {
+			event.ToolResult = &d
+		}
 	case "error":
 		event.Error = data
 	}
@@ -1,6 +1,7 @@
 package langdag
 
 import (
+	"context"
 	"errors"
 	"io"
 	"strings"
@@ -557,3 +558,150 @@ func (r *errorReader) Read(p []byte) (int, error) {
 	}
 	return n, nil
 }
+
+func TestStream_SuggestionsAfterDone(t *testing.T) {
+	input := "event: done\ndata: {\"node_id\":\"n-1\"}\n\n" +
+		"event: suggestions\ndata: {\"node_id\":\"n-1\",\"suggestions\":[\"Why?\",\"How?\"]}\n\n"
+	stream := newStream(io.NopCloser(strings.NewReader(input)), nil)
+
+	var events []SSEEvent
+	for event := range stream.Events() {
+		events = append(events, event)
+	}
+	if len(events) != 2 || events[1].Type != "suggestions" {
+		t.Fatalf("events = %+v", events)
+	}
+	if got := strings.Join(events[1].Suggestions, "|"); got != "Why?|How?" || events[1].NodeID != "n-1" {
+		t.Errorf("suggestions event = %+v", events[1])
+	}
+	if node, err := stream.Node(); err != nil || node.ID != "n-1" {
+		t.Errorf("Node() = %v, %v", node, err)
+	}
+}
+
+func TestStream_ToolEvents(t *testing.T) {
+	input := "event: tool_result\ndata: {\"tool_use_id\":\"toolu_1\",\"content\":\"sunny\"}\n\n" +
+		"event: tool_call\ndata: {\"id\":\"toolu_2\",\"name\":\"lookup\",\"input\":{\"q\":\"rain\"}}\n\n" +
+		"event: done\ndata: {\"node_id\":\"n-1\"}\n\n"
+	stream := newStream(io.NopCloser(strings.NewReader(input)), nil)
+
+	var events []SSEEvent
+	for event := range stream.Events() {
+		events = append(events, event)
+	}
+	if len(events) != 3 {
+		t.Fatalf("events = %+v", events)
+	}
+	if r := events[0].ToolResult; r == nil || r.ToolUseID != "toolu_1" || string(r.Content) != `"sunny"` {
+		t.Errorf("tool_result event = %+v", events[0])
+	}
+	if c := events[1].ToolCall; c == nil || c.ID != "toolu_2" || c.Name != "lookup" || string(c.Input) != `{"q":"rain"}` {
+		t.Errorf("tool_call event = %+v", events[1])
+	}
+}
+
+func TestStream_Next(t *testing.T) {
+	input := `event: start
+data: {}
+
+event: delta
+data: {"content":"Hi"}
+
+event: done
+data: {"node_id":"node-1"}
+
+`
+	stream := newStream(io.NopCloser(strings.NewReader(input)), nil)
+
+	var types []string
+	for {
+		event, err := stream.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		types = append(types, event.Type)
+	}
+	if strings.Join(types, ",") != "start,delta,done" {
+		t.Errorf("events = %v, want start, delta, done", types)
+	}
+	if _, err := stream.Next(context.Background()); err != io.EOF {
+		t.Errorf("Next after the end = %v, want io.EOF", err)
+	}
+}
+
+func TestStream_NextReturnsStreamError(t *testing.T) {
+	input := "event: error\ndata: provider crashed\n\n"
+	stream := newStream(io.NopCloser(strings.NewReader(input)), nil)
+
+	if event, err := stream.Next(context.Background()); err != nil || event.Type != "error" {
+		t.Fatalf("Next = %+v, %v; want the error event", event, err)
+	}
+	var sErr *StreamError
+	if _, err := stream.Next(context.Background()); !errors.As(err, &sErr) {
+		t.Errorf("Next after the error event = %v, want *StreamError", err)
+	}
+}
+
+func TestStream_NextContextDone(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	stream := newStream(r, nil)
+	defer stream.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := stream.Next(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Next = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestStream_CloseStopsUnreadStream(t *testing.T) {
+	r, w := io.Pipe()
+	go func() {
+		// More events than the channel buffers, none of them received.
+		for {
+			if _, err := io.WriteString(w, "event: delta\ndata: {\"content\":\"x\"}\n\n"); err != nil {
+				return
+			}
+		}
+	}()
+	stream := newStream(r, nil)
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		stream.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not stop the reader")
+	}
+	for range stream.Events() {
+	}
+	if err := stream.Err(); err != nil {
+		t.Errorf("Err after Close = %v, want nil", err)
+	}
+	stream.Close()
+}
+
+func TestStream_NodeWithoutDraining(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 100; i++ {
+		input.WriteString("event: delta\ndata: {\"content\":\"x\"}\n\n")
+	}
+	input.WriteString("event: done\ndata: {\"node_id\":\"node-1\"}\n\n")
+	stream := newStream(io.NopCloser(strings.NewReader(input.String())), nil)
+
+	node, err := stream.Node()
+	if err != nil {
+		t.Fatalf("Node: %v", err)
+	}
+	if node.ID != "node-1" || len(node.Content) != 100 {
+		t.Errorf("node = %s with %d bytes, want node-1 with 100", node.ID, len(node.Content))
+	}
+}
@@ -0,0 +1,152 @@
+package langdag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ToolHandler runs a tool registered with RegisterTool. input is the JSON
+// input the model called the tool with; the returned text is sent back as
+// the tool result. An error is sent as an error result, for the model to
+// recover from.
+type ToolHandler func(ctx context.Context, input json.RawMessage) (string, error)
+
+// maxToolRounds bounds the tool calls answered for a single prompt.
+const maxToolRounds = 10
+
+// ErrToolRounds is returned when the model still calls tools after
+// maxToolRounds rounds of results.
+var ErrToolRounds = errors.New("langdag: too many tool call rounds")
+
+type registeredTool struct {
+	def     ToolDefinition
+	handler ToolHandler
+}
+
+// toolBlock is a tool_use or tool_result content block.
+type toolBlock struct {
+	Type      string          `json:"type"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+// RegisterTool makes a tool available to every non-streaming prompt of the
+// client (Prompt, Node.Prompt, Node.Edit and Node.Regenerate). When a reply
+// calls registered tools, the client runs their handlers and sends the
+// results as the next prompt, until the model answers without calling
+// tools; the final reply is returned. Registering a name again replaces
+// the tool.
+func (c *Client) RegisterTool(name, description string, inputSchema json.RawMessage, handler ToolHandler) {
+	c.toolsMu.Lock()
+	defer c.toolsMu.Unlock()
+	if c.tools == nil {
+		c.tools = make(map[string]registeredTool)
+	}
+	if _, ok := c.tools[name]; !ok {
+		c.toolOrder = append(c.toolOrder, name)
+	}
+	c.tools[name] = registeredTool{
+		def:     ToolDefinition{Name: name, Description: description, InputSchema: inputSchema},
+		handler: handler,
+	}
+}
+
+// withRegisteredTools returns the tools of a request: tools, followed by
+// the registered tools they do not already define.
+func (c *Client) withRegisteredTools(tools []ToolDefinition) []ToolDefinition {
+	c.toolsMu.RLock()
+	defer c.toolsMu.RUnlock()
+	if len(c.toolOrder) == 0 {
+		return tools
+	}
+	out := append([]ToolDefinition(nil), tools...)
+	for _, name := range c.toolOrder {
+		defined := false
+		for _, t := range tools {
+			if t.Name == name {
+				defined = true
+				break
+			}
+		}
+		if !defined {
+			out = append(out, c.tools[name].def)
+		}
+	}
+	return out
+}
+
+// toolHandler returns the handler registered for name, if any.
+func (c *Client) toolHandler(name string) (ToolHandler, bool) {
+	c.toolsMu.RLock()
+	defer c.toolsMu.RUnlock()
+	t, ok := c.tools[name]
+	return t.handler, ok
+}
+
+// runTools answers the registered tool calls of reply, and of the replies
+// that follow, and returns the first reply that calls none. A reply that
+// also calls a tool that is not registered is returned as is.
+func (c *Client) runTools(ctx context.Context, reply *Node, o *promptOptions) (*Node, error) {
+	if !c.hasTools() {
+		return reply, nil
+	}
+	for round := 0; ; round++ {
+		node, err := c.GetNode(ctx, reply.ID)
+		if err != nil {
+			return nil, err
+		}
+		if node.StopReason != "tool_use" {
+			return reply, nil
+		}
+		var blocks []toolBlock
+		if err := json.Unmarshal([]byte(node.Content), &blocks); err != nil {
+			return reply, nil
+		}
+		var calls []toolBlock
+		for _, b := range blocks {
+			if b.Type != "tool_use" {
+				continue
+			}
+			if _, ok := c.toolHandler(b.Name); !ok {
+				return reply, nil
+			}
+			calls = append(calls, b)
+		}
+		if len(calls) == 0 {
+			return reply, nil
+		}
+		if round == maxToolRounds {
+			return reply, ErrToolRounds
+		}
+		results := make([]toolBlock, len(calls))
+		for i, call := range calls {
+			handler, _ := c.toolHandler(call.Name)
+			results[i] = toolBlock{Type: "tool_result", ToolUseID: call.ID}
+			if content, err := handler(ctx, call.Input); err != nil {
+				results[i].Content, results[i].IsError = err.Error(), true
+			} else {
+				results[i].Content = content
+			}
+		}
+		message, err := json.Marshal(results)
+		if err != nil {
+			return nil, fmt.Errorf("langdag: encode tool results: %w", err)
+		}
+		reply, err = c.sendPrompt(ctx, reply.ID, "prompt", string(message), o)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) hasTools() bool {
+	c.toolsMu.RLock()
+	defer c.toolsMu.RUnlock()
+	return len(c.toolOrder) > 0
+}
@@ -0,0 +1,115 @@
+package langdag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Refresh updates the tree if it changed on the server since it was
+// fetched, and returns the nodes that were added. The request carries the
+// tree's ETag, so an unchanged tree costs a response without a body.
+// Nodes already in the tree are updated in place and nodes deleted on the
+// server are removed.
+func (t *Tree) Refresh(ctx context.Context) ([]Node, error) {
+	if t.client == nil {
+		return nil, errors.New("langdag: Refresh needs a tree returned by GetTree")
+	}
+	nodes, etag, err := t.client.fetchTree(ctx, t.id, t.etag)
+	if err != nil || nodes == nil {
+		return nil, err
+	}
+	t.client.trees.put(t.id, treeCacheEntry{etag: etag, nodes: append([]Node(nil), nodes...)})
+
+	known := make(map[string]bool, len(t.Nodes))
+	for _, n := range t.Nodes {
+		known[n.ID] = true
+	}
+	var added []Node
+	for i := range nodes {
+		nodes[i].client = t.client
+		if !known[nodes[i].ID] {
+			added = append(added, nodes[i])
+		}
+	}
+	t.Nodes, t.etag = nodes, etag
+	return added, nil
+}
+
+// fetchTree gets the tree containing id. With a non-empty etag, it returns
+// nil nodes when the tree still matches it.
+func (c *Client) fetchTree(ctx context.Context, id, etag string) ([]Node, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+fmt.Sprintf("/nodes/%s/tree", id), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("langdag: failed to create request: %w", err)
+	}
+	c.setHeaders(req)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := c.do(c.httpClient, req)
+	if err != nil {
+		return nil, "", &ConnectionError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && etag != "" {
+		return nil, etag, nil
+	}
+	if resp.StatusCode >= 400 {
+		return nil, "", c.parseError(resp)
+	}
+	var nodes []Node
+	if err := json.NewDecoder(resp.Body).Decode(&nodes); err != nil {
+		return nil, "", fmt.Errorf("langdag: failed to decode response: %w", err)
+	}
+	if nodes == nil {
+		nodes = []Node{}
+	}
+	return nodes, resp.Header.Get("ETag"), nil
+}
+
+// treeCacheEntry is the last GetTree response for a node ID.
+type treeCacheEntry struct {
+	etag  string
+	nodes []Node
+}
+
+// treeCache keeps GetTree responses that have an ETag, dropping the oldest
+// beyond size. A nil treeCache caches nothing.
+type treeCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]treeCacheEntry
+	order   []string // oldest first
+}
+
+func (tc *treeCache) get(id string) (treeCacheEntry, bool) {
+	if tc == nil {
+		return treeCacheEntry{}, false
+	}
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	e, ok := tc.entries[id]
+	return e, ok
+}
+
+func (tc *treeCache) put(id string, e treeCacheEntry) {
+	if tc == nil || e.etag == "" {
+		return
+	}
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if _, ok := tc.entries[id]; !ok {
+		tc.order = append(tc.order, id)
+	}
+	tc.entries[id] = e
+	for len(tc.order) > tc.size {
+		delete(tc.entries, tc.order[0])
+		tc.order = tc.order[1:]
+	}
+}
@@ -15,6 +15,14 @@ const (
 	NodeTypeAssistant  NodeType = "assistant"
 	NodeTypeToolCall   NodeType = "tool_call"
 	NodeTypeToolResult NodeType = "tool_result"
+	NodeTypeSummary    NodeType = "summary"
+)
+
+// Visibility levels of a DAG, set with UpdateDAG.
+const (
+	VisibilityPrivate        = "private"         // only the API key that started it
+	VisibilityTeam           = "team"            // every API key (default)
+	VisibilityPublicReadOnly = "public-readonly" // also readable without a key
 )
 
 // Node represents a node in a conversation tree.
@@ -39,10 +47,19 @@ type Node struct {
 	Status              string                 `json:"status,omitempty"`
 	Title               string                 `json:"title,omitempty"`
 	SystemPrompt        string                 `json:"system_prompt,omitempty"`
+	ArchivedURI         string                 `json:"archived_uri,omitempty"`
+	ForkedFromDAG       string                 `json:"forked_from_dag,omitempty"`
+	ForkedFromNode      string                 `json:"forked_from_node,omitempty"`
+	Tags                []string               `json:"tags,omitempty"`       // topic tags set on roots by the classifier
+	Owner               string                 `json:"owner,omitempty"`      // ID of the API key that started the DAG (roots only)
+	Visibility          string                 `json:"visibility,omitempty"` // roots only
 	CreatedAt           time.Time              `json:"created_at"`
 	Usage               *NormalizedUsage       `json:"usage,omitempty"`
 	Metadata            *AssistantNodeMetadata `json:"metadata,omitempty"`
 	Cost                *CostResult            `json:"cost,omitempty"`
+	InjectionWarnings   []string               `json:"injection_warnings,omitempty"`
+	Meta                bool                   `json:"meta,omitempty"`     // question asked about the DAG (langdag ask)
+	Glossary            []string               `json:"glossary,omitempty"` // glossary terms used in a user message
 
 	client *Client // unexported — enables Prompt()
 }
@@ -53,7 +70,7 @@ func (n *Node) Prompt(ctx context.Context, message string, opts ...PromptOption)
 	for _, opt := range opts {
 		opt(o)
 	}
-	return n.client.promptFrom(ctx, n.ID, message, o)
+	return n.client.promptFrom(ctx, n.ID, "prompt", message, o)
 }
 
 // PromptStream continues the conversation from this node with streaming.
@@ -62,21 +79,134 @@ func (n *Node) PromptStream(ctx context.Context, message string, opts ...PromptO
 	for _, opt := range opts {
 		opt(o)
 	}
-	return n.client.promptStreamFrom(ctx, n.ID, message, o)
+	return n.client.promptStreamFrom(ctx, n.ID, "prompt", message, o)
+}
+
+// Edit sends message in place of this user message, as a new branch next
+// to it, and returns the reply.
+func (n *Node) Edit(ctx context.Context, message string, opts ...PromptOption) (*Node, error) {
+	o := &promptOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return n.client.promptFrom(ctx, n.ID, "edit", message, o)
+}
+
+// EditStream is like Edit but streams the reply.
+func (n *Node) EditStream(ctx context.Context, message string, opts ...PromptOption) (*Stream, error) {
+	o := &promptOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return n.client.promptStreamFrom(ctx, n.ID, "edit", message, o)
+}
+
+// Regenerate asks for a new reply next to this assistant node (or another
+// reply to this user message). WithModel and WithTemperature override the
+// settings; the model defaults to the one of the original reply.
+func (n *Node) Regenerate(ctx context.Context, opts ...PromptOption) (*Node, error) {
+	o := &promptOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return n.client.regenerate(ctx, n.ID, o)
+}
+
+// RegenerateStream is like Regenerate but streams the reply.
+func (n *Node) RegenerateStream(ctx context.Context, opts ...PromptOption) (*Stream, error) {
+	o := &promptOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return n.client.regenerateStream(ctx, n.ID, o)
 }
 
 // Tree represents a tree of nodes.
 type Tree struct {
 	Nodes []Node `json:"nodes"`
+
+	client *Client // unexported — enables Refresh()
+	id     string  // node ID the tree was fetched with
+	etag   string
+}
+
+// SearchMatch is a node matched by SearchTree or Search.
+type SearchMatch struct {
+	Node Node `json:"node"`
+	// Path lists node IDs from the DAG root down to the matching node.
+	Path    []string `json:"path"`
+	Snippet string   `json:"snippet"`
+	// Score is the similarity to the query of SemanticSearch matches, up
+	// to 1.
+	Score float64 `json:"score,omitempty"`
 }
 
-// ToolDefinition describes a tool that the model can use.
+// Comment is a note left on a node by a reviewer. Comments are not sent
+// to the model.
+type Comment struct {
+	ID        string    `json:"id"`
+	NodeID    string    `json:"node_id"`
+	RootID    string    `json:"root_id"`
+	ReplyTo   string    `json:"reply_to,omitempty"` // comment on the same node this one replies to
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AskResult is the answer to a question asked with Ask.
+type AskResult struct {
+	NodeID string `json:"node_id"` // the stored answer
+	Answer string `json:"answer"`
+	// Sources are the nodes the answer was drawn from (questions across
+	// all DAGs only).
+	Sources []SearchMatch `json:"sources,omitempty"`
+}
+
+type askRequest struct {
+	Question string           `json:"question"`
+	DAGID    string           `json:"dag_id,omitempty"`
+	Model    string           `json:"model,omitempty"`
+	Metadata *requestMetadata `json:"metadata,omitempty"`
+}
+
+// CancelResult reports the generations stopped by CancelTree.
+type CancelResult struct {
+	RootID    string `json:"root_id"`
+	Cancelled int    `json:"cancelled"`
+}
+
+// CloneResult is the result of cloning a conversation into a new DAG.
+type CloneResult struct {
+	Root Node `json:"root"` // root of the new DAG
+	Node Node `json:"node"` // copy of the node cloned from; continue from it
+}
+
+// ToolDefinition describes a tool that the model can use. A definition
+// with only the name of one of the server's built-in tools (see
+// Features.BuiltinTools) selects it: the server runs it when the model calls
+// it and streams the reply to its results.
 type ToolDefinition struct {
 	Name        string          `json:"name"`
 	Description string          `json:"description,omitempty"`
 	InputSchema json.RawMessage `json:"input_schema,omitempty"`
 }
 
+// ToolCall is a tool the model asked to call, from a tool_call stream
+// event. Send its result with the next prompt, as a tool_result block.
+type ToolCall struct {
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// ToolResult is a tool result carried by the prompt being answered, from a
+// tool_result stream event.
+type ToolResult struct {
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content,omitempty"` // a JSON string, or structured content
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
 // PromptOption configures a prompt request.
 type PromptOption func(*promptOptions)
 
@@ -84,6 +214,14 @@ type promptOptions struct {
 	model        string
 	systemPrompt string
 	tools        []ToolDefinition
+	userID       string
+	maxTokens    int
+	temperature  *float64
+	stop         []string
+	language     string
+	preset       string
+	confirm      bool
+	suggestions  bool
 }
 
 // WithSystem sets the system prompt (only for new trees via client.Prompt).
@@ -100,13 +238,97 @@ func WithTools(tools []ToolDefinition) PromptOption {
 	}
 }
 
-// WithModel sets the model for the prompt.
+// WithModel sets the model for the prompt. A model set to continue a
+// conversation is kept by later prompts on the same branch.
 func WithModel(model string) PromptOption {
 	return func(o *promptOptions) {
 		o.model = model
 	}
 }
 
+// WithUserID attaches an end-user identifier that the server forwards to the
+// provider for abuse monitoring.
+func WithUserID(userID string) PromptOption {
+	return func(o *promptOptions) {
+		o.userID = userID
+	}
+}
+
+// WithMaxTokens overrides the server's default max_tokens for the prompt.
+func WithMaxTokens(n int) PromptOption {
+	return func(o *promptOptions) {
+		o.maxTokens = n
+	}
+}
+
+// WithTemperature sets the sampling temperature for the prompt.
+func WithTemperature(t float64) PromptOption {
+	return func(o *promptOptions) {
+		o.temperature = &t
+	}
+}
+
+// WithStopSequences stops the reply at the first of stops (at most 4). A
+// conversation started with it keeps them, as it keeps WithTemperature and
+// WithMaxTokens, for prompts that do not set their own.
+func WithStopSequences(stops ...string) PromptOption {
+	return func(o *promptOptions) {
+		o.stop = stops
+	}
+}
+
+// WithLanguage asks for replies in lang, an ISO 639-1 code or an English
+// name. A conversation started with it keeps the language.
+func WithLanguage(lang string) PromptOption {
+	return func(o *promptOptions) {
+		o.language = lang
+	}
+}
+
+// WithPreset selects a preset defined in the server's config. Options set
+// explicitly alongside it take precedence over the preset's values.
+func WithPreset(name string) PromptOption {
+	return func(o *promptOptions) {
+		o.preset = name
+	}
+}
+
+// WithInjectionConfirmed sends the message even if the server's
+// prompt-injection scanner flags its tool results (continuations only).
+func WithInjectionConfirmed() PromptOption {
+	return func(o *promptOptions) {
+		o.confirm = true
+	}
+}
+
+// WithSuggestions asks streams for 2-3 follow-up questions to the reply,
+// in a suggestions event after the done event. They are also recorded in
+// the reply's metadata.
+func WithSuggestions() PromptOption {
+	return func(o *promptOptions) {
+		o.suggestions = true
+	}
+}
+
+// streamOptions returns the stream_options object sent with streaming
+// requests.
+func (o *promptOptions) streamOptions() *streamOptions {
+	if !o.suggestions {
+		return nil
+	}
+	return &streamOptions{Suggestions: true}
+}
+
+// streamOptions is the stream_options object sent with streaming requests.
+type streamOptions struct {
+	Suggestions bool `json:"suggestions,omitempty"`
+}
+
+// requestMetadata is the metadata object sent with prompt requests.
+type requestMetadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
 // promptRequest is the JSON body sent to /prompt and /nodes/{id}/prompt.
 type promptRequest struct {
 	Message      string           `json:"message"`
@@ -114,6 +336,52 @@ type promptRequest struct {
 	SystemPrompt string           `json:"system_prompt,omitempty"`
 	Stream       bool             `json:"stream,omitempty"`
 	Tools        []ToolDefinition `json:"tools,omitempty"`
+	Metadata     *requestMetadata `json:"metadata,omitempty"`
+	MaxTokens    int              `json:"max_tokens,omitempty"`
+	Temperature  *float64         `json:"temperature,omitempty"`
+	Stop         []string         `json:"stop_sequences,omitempty"`
+	Preset       string           `json:"preset,omitempty"`
+	Language     string           `json:"language,omitempty"`
+	Confirm      bool             `json:"confirm_injection,omitempty"`
+	StreamOpts   *streamOptions   `json:"stream_options,omitempty"`
+}
+
+// regenerateRequest is the JSON body for POST /nodes/{id}/regenerate.
+type regenerateRequest struct {
+	Model       string           `json:"model,omitempty"`
+	Stream      bool             `json:"stream,omitempty"`
+	Tools       []ToolDefinition `json:"tools,omitempty"`
+	Metadata    *requestMetadata `json:"metadata,omitempty"`
+	MaxTokens   int              `json:"max_tokens,omitempty"`
+	Temperature *float64         `json:"temperature,omitempty"`
+	Stop        []string         `json:"stop_sequences,omitempty"`
+	Language    string           `json:"language,omitempty"`
+	StreamOpts  *streamOptions   `json:"stream_options,omitempty"`
+}
+
+// DAGUpdate lists the DAG settings to change with UpdateDAG. Nil fields
+// are left unchanged; an empty SystemPrompt removes it, and an empty Tools
+// slice removes the default tools.
+type DAGUpdate struct {
+	Title        *string           `json:"title,omitempty"`
+	SystemPrompt *string           `json:"system_prompt,omitempty"`
+	Model        *string           `json:"model,omitempty"`
+	Tools        *[]ToolDefinition `json:"tools,omitempty"`
+	Language     *string           `json:"language,omitempty"`
+	Visibility   *string           `json:"visibility,omitempty"` // owner only
+}
+
+type summarizeRequest struct {
+	Model    string           `json:"model,omitempty"`
+	Metadata *requestMetadata `json:"metadata,omitempty"`
+}
+
+// metadata returns the request metadata for o, or nil when none is set.
+func (o *promptOptions) metadata() *requestMetadata {
+	if o.userID == "" {
+		return nil
+	}
+	return &requestMetadata{UserID: o.userID}
 }
 
 // PromptResponse is the JSON body returned from /prompt and /nodes/{id}/prompt.
@@ -221,6 +489,21 @@ type AssistantNodeMetadata struct {
 	NormalizedUsage *NormalizedUsage         `json:"normalized_usage,omitempty"`
 	PricingSnapshot *PricingSnapshot         `json:"pricing_snapshot,omitempty"`
 	ProviderCost    *ProviderCost            `json:"provider_cost,omitempty"`
+
+	// Suggestions are follow-up questions to the reply, when asked for
+	// with WithSuggestions.
+	Suggestions []string `json:"suggestions,omitempty"`
+
+	// Sampling holds the sampling parameters the reply was generated
+	// with, set by the request or kept by the DAG.
+	Sampling *SamplingParams `json:"sampling,omitempty"`
+}
+
+// SamplingParams are the sampling parameters set explicitly for a reply.
+type SamplingParams struct {
+	Temperature   *float64 `json:"temperature,omitempty"`
+	MaxTokens     int      `json:"max_tokens,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
 }
 
 // HealthResponse represents the health check response.
@@ -228,6 +511,45 @@ type HealthResponse struct {
 	Status string `json:"status"`
 }
 
+// Features lists the capabilities of a server, as returned by Features.
+type Features struct {
+	Providers     []string `json:"providers"`
+	Auth          string   `json:"auth"` // "api_key" or "none"
+	Storage       string   `json:"storage"`
+	Tools         bool     `json:"tools"`
+	Vision        bool     `json:"vision"`
+	JSONMode      bool     `json:"json_mode"`
+	MaxContext    int      `json:"max_context,omitempty"`
+	BuiltinTools  []string `json:"builtin_tools"` // e.g. "http_fetch", "shell"
+	Presets       []string `json:"presets"`
+	InjectionScan bool     `json:"injection_scan"`
+	MultiUser     bool     `json:"multi_user"`
+	Embeddings    bool     `json:"embeddings"`
+	Workflows     bool     `json:"workflows"`
+}
+
+// Usage is a usage report, as returned by Usage.
+type Usage struct {
+	Since   string     `json:"since"`
+	GroupBy string     `json:"group_by"`
+	Rows    []UsageRow `json:"rows"`
+	Total   UsageRow   `json:"total"`
+}
+
+// UsageRow is the usage of one model, day or DAG.
+type UsageRow struct {
+	Key                 string             `json:"key"` // model, day (YYYY-MM-DD) or DAG root ID
+	Title               string             `json:"title,omitempty"`
+	Generations         int                `json:"generations"`
+	TokensIn            int64              `json:"tokens_in"`
+	TokensOut           int64              `json:"tokens_out"`
+	TokensCacheRead     int64              `json:"tokens_cache_read"`
+	TokensCacheCreation int64              `json:"tokens_cache_creation"`
+	TokensReasoning     int64              `json:"tokens_reasoning"`
+	Cost                map[string]float64 `json:"cost,omitempty"` // by currency
+	Unpriced            int                `json:"unpriced,omitempty"`
+}
+
 // DeleteResponse represents a delete response.
 type DeleteResponse struct {
 	Status string `json:"status"`
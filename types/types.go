@@ -4,6 +4,7 @@ package types
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"time"
 )
 
@@ -69,8 +70,18 @@ const (
 	NodeTypeSystem     NodeType = "system"
 	NodeTypeToolCall   NodeType = "tool_call"
 	NodeTypeToolResult NodeType = "tool_result"
+
+	// NodeTypeSummary holds a summary of the branch leading to it (or of
+	// the whole DAG when its parent is the root). Summaries are never sent
+	// to the model as conversation history.
+	NodeTypeSummary NodeType = "summary"
 )
 
+// NodeStatusSpeculative is the status of the nodes of a speculative branch,
+// generated ahead of time and hidden until taken (see
+// conversation.SpeculativeStatus).
+const NodeStatusSpeculative = "speculative"
+
 // Node represents a node in the conversation/workflow tree.
 // Root nodes (ParentID == "") define the start of a tree and carry
 // metadata like Title and SystemPrompt.
@@ -95,10 +106,24 @@ type Node struct {
 	OutputGroupID       string `json:"output_group_id,omitempty"`
 	Status              string `json:"status,omitempty"`
 
-	// Root node metadata (empty on non-root nodes)
+	// Root node metadata (empty on non-root nodes). Assistant nodes also
+	// record the layered SystemPrompt when a global system prompt is set.
 	Title        string `json:"title,omitempty"`
 	SystemPrompt string `json:"system_prompt,omitempty"`
 
+	// ArchivedURI is set on the root of a DAG whose nodes were moved to
+	// cold storage. The root is kept as a stub until the DAG is restored.
+	ArchivedURI string `json:"archived_uri,omitempty"`
+
+	// ForkedFromDAG and ForkedFromNode are set on the root of a DAG created
+	// by cloning: the source DAG's root ID and the node it was cloned from.
+	ForkedFromDAG  string `json:"forked_from_dag,omitempty"`
+	ForkedFromNode string `json:"forked_from_node,omitempty"`
+
+	// Tags are topics set on the root by the classifier (see
+	// conversation.ClassifierOptions), lowercase and sorted.
+	Tags []string `json:"tags,omitempty"`
+
 	CreatedAt time.Time       `json:"created_at"`
 	Metadata  json.RawMessage `json:"metadata,omitempty"`
 }
@@ -109,6 +134,47 @@ type Tree struct {
 	Nodes []Node `json:"nodes"`
 }
 
+// API key scopes. A read key may only make GET requests; a write key may
+// make any request, and a tools key may also run built-in server tools.
+const (
+	APIKeyScopeRead  = "read"
+	APIKeyScopeWrite = "write"
+	APIKeyScopeTools = "tools"
+)
+
+// APIKey is a stored API server key. Only the SHA-256 of the secret is
+// kept.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name,omitempty"`
+	Hash       string     `json:"-"`
+	Scope      string     `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// DAG visibility levels, stored on the root. A private DAG is only
+// reachable with its owner's API key (or the server key); a team DAG with
+// any key; a public-readonly DAG may also be read without a key.
+const (
+	VisibilityPrivate        = "private"
+	VisibilityTeam           = "team"
+	VisibilityPublicReadOnly = "public-readonly"
+)
+
+// Comment is a remark left on a node, for reviewing a conversation. A
+// comment answering another one carries its ID in ReplyTo.
+type Comment struct {
+	ID        string    `json:"id"`
+	NodeID    string    `json:"node_id"`
+	RootID    string    `json:"root_id"`
+	ReplyTo   string    `json:"reply_to,omitempty"`
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // ToolDefinition represents a tool that can be used in a completion request.
 type ToolDefinition struct {
 	Name        string          `json:"name"`
@@ -145,11 +211,29 @@ type CompletionRequest struct {
 	Messages      []Message        `json:"messages"`
 	System        string           `json:"system,omitempty"`
 	MaxTokens     int              `json:"max_tokens,omitempty"`
-	Temperature   float64          `json:"temperature,omitempty"`
+	Temperature   *float64         `json:"temperature,omitempty"` // nil = provider default
 	StopSeqs      []string         `json:"stop_sequences,omitempty"`
 	Tools         []ToolDefinition `json:"tools,omitempty"`
 	Think         *bool            `json:"think,omitempty"`           // nil = provider default, true = enable, false = disable
 	APIProtocolID string           `json:"api_protocol_id,omitempty"` // optional provider API surface override, e.g. openai-responses
+	Metadata      *RequestMetadata `json:"metadata,omitempty"`
+}
+
+// RequestMetadata carries caller-supplied metadata that is forwarded to
+// providers which accept it (e.g. Anthropic's metadata.user_id).
+type RequestMetadata struct {
+	// UserID is an opaque identifier for the end user on whose behalf the
+	// request is made. Providers use it for abuse monitoring; it should not
+	// contain personal information such as names or email addresses.
+	UserID string `json:"user_id,omitempty"`
+}
+
+// UserID returns the end-user identifier in r's metadata, or "".
+func (r *CompletionRequest) UserID() string {
+	if r == nil || r.Metadata == nil {
+		return ""
+	}
+	return r.Metadata.UserID
 }
 
 // CompletionResponse represents a response from an LLM provider.
@@ -170,6 +254,32 @@ type CompletionResponse struct {
 	ProviderCost    *ProviderCost            `json:"provider_cost,omitempty"`
 }
 
+// EmbeddingRequest asks a provider for vector embeddings of texts.
+type EmbeddingRequest struct {
+	Model string   `json:"model,omitempty"` // provider default when empty
+	Input []string `json:"input"`
+}
+
+// EmbeddingResponse holds one embedding per input text, in order. Model is
+// the model that produced them: only embeddings of the same model can be
+// compared.
+type EmbeddingResponse struct {
+	Model      string      `json:"model"`
+	Embeddings [][]float32 `json:"embeddings"`
+	TokensIn   int         `json:"tokens_in,omitempty"`
+}
+
+// ErrEmbeddingsNotSupported is returned by Embed for providers without an
+// embeddings API.
+var ErrEmbeddingsNotSupported = errors.New("provider does not support embeddings")
+
+// EmbeddingMatch is a node found by an embedding search, with the cosine
+// similarity of its embedding to the query (1 for identical direction).
+type EmbeddingMatch struct {
+	NodeID string  `json:"node_id"`
+	Score  float64 `json:"score"`
+}
+
 // Usage represents token usage information.
 type Usage struct {
 	InputTokens              int              `json:"input_tokens"`
@@ -343,6 +453,7 @@ const (
 	CostSourceCatalog          CostSource = "catalog"
 	CostSourceProviderResponse CostSource = "provider_response"
 	CostSourceHistorical       CostSource = "historical"
+	CostSourceConfig           CostSource = "config"
 )
 
 // PricingSnapshot is copied onto assistant-node metadata so historical cost
@@ -484,6 +595,77 @@ type AssistantNodeMetadata struct {
 	NormalizedUsage *NormalizedUsage         `json:"normalized_usage,omitempty"`
 	PricingSnapshot *PricingSnapshot         `json:"pricing_snapshot,omitempty"`
 	ProviderCost    *ProviderCost            `json:"provider_cost,omitempty"`
+
+	// Suggestions are follow-up questions to the reply, generated on
+	// request for quick replies.
+	Suggestions []string `json:"suggestions,omitempty"`
+
+	// Sampling holds the sampling parameters set for the reply, by the
+	// request or the DAG.
+	Sampling *SamplingParams `json:"sampling,omitempty"`
+}
+
+// UserNodeMetadata is the shape stored in Node.Metadata for user nodes.
+type UserNodeMetadata struct {
+	// InjectionWarnings lists suspected prompt-injection patterns found in
+	// the tool results carried by the node.
+	InjectionWarnings []string `json:"injection_warnings,omitempty"`
+
+	// Meta marks a question asked about the DAG itself (langdag ask). It
+	// starts a side branch that is not part of the conversation.
+	Meta bool `json:"meta,omitempty"`
+
+	// Tools, on a root, are the DAG's default tools, used by prompts that
+	// do not set their own.
+	Tools []ToolDefinition `json:"tools,omitempty"`
+
+	// Language, on a root, is the language replies in the DAG are asked
+	// to be written in.
+	Language string `json:"language,omitempty"`
+
+	// Glossary lists the project glossary terms found in the message;
+	// their definitions were added to the system prompt of the reply.
+	Glossary []string `json:"glossary,omitempty"`
+
+	// Owner, on a root, is the ID of the API key that started the DAG.
+	Owner string `json:"owner,omitempty"`
+
+	// Guest, on a root, marks a DAG started by a guest of the API server:
+	// the only DAGs guests may reach, deleted after the guest TTL.
+	Guest bool `json:"guest,omitempty"`
+
+	// Visibility, on a root, is who may reach the DAG (a Visibility*
+	// constant). Empty means team.
+	Visibility string `json:"visibility,omitempty"`
+
+	// Sampling, on a root, holds the sampling parameters the DAG was
+	// started with, used by later prompts that do not set their own.
+	Sampling *SamplingParams `json:"sampling,omitempty"`
+}
+
+// SamplingParams are the generation parameters a request set explicitly.
+type SamplingParams struct {
+	Temperature   *float64 `json:"temperature,omitempty"`
+	MaxTokens     int      `json:"max_tokens,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+}
+
+// IsZero reports whether p sets no parameter.
+func (p *SamplingParams) IsZero() bool {
+	return p == nil || (p.Temperature == nil && p.MaxTokens == 0 && len(p.StopSequences) == 0)
+}
+
+// UserMetadataFromNode decodes the metadata of a user node. It returns nil
+// for other node types or when no metadata is stored.
+func UserMetadataFromNode(node *Node) *UserNodeMetadata {
+	if node == nil || node.NodeType != NodeTypeUser || len(node.Metadata) == 0 {
+		return nil
+	}
+	var meta UserNodeMetadata
+	if json.Unmarshal(node.Metadata, &meta) != nil {
+		return nil
+	}
+	return &meta
 }
 
 func (r *CompletionResponse) EnsureNormalizedUsage() {
@@ -558,6 +740,10 @@ const (
 	StreamEventDone        StreamEventType = "done"
 	StreamEventError       StreamEventType = "error"
 	StreamEventNodeSaved   StreamEventType = "node_saved"
+	// StreamEventTimeout reports that the generation hit the generation
+	// timeout. NodeID is the partial reply saved, if any; a node_saved or
+	// error event follows.
+	StreamEventTimeout StreamEventType = "timeout"
 )
 
 // StreamEvent represents an event during streaming completion.
@@ -567,7 +753,7 @@ type StreamEvent struct {
 	ContentBlock *ContentBlock       `json:"content_block,omitempty"` // For content_done events
 	Response     *CompletionResponse `json:"response,omitempty"`      // For done events
 	Error        error               `json:"-"`                       // For error events
-	NodeID       string              `json:"node_id,omitempty"`       // For node_saved events
+	NodeID       string              `json:"node_id,omitempty"`       // For node_saved and timeout events
 }
 
 // ModelInfo represents information about a model.
@@ -588,3 +774,44 @@ type ModelInfo struct {
 	// explicit budget configuration (e.g. Gemma 4).
 	SupportsExplicitThinkingBudget bool `json:"supports_explicit_thinking_budget,omitempty"`
 }
+
+// Capabilities summarizes what a provider can accept, so context management,
+// validation, and routing can make decisions without per-model tables.
+// Per-model details remain on ModelInfo.
+type Capabilities struct {
+	Tools    bool `json:"tools"`     // accepts client-side function tools
+	Vision   bool `json:"vision"`    // accepts image content blocks
+	JSONMode bool `json:"json_mode"` // can constrain output to valid JSON
+
+	// MaxContext is the largest context window (in tokens) among the
+	// provider's models. 0 means unknown.
+	MaxContext int `json:"max_context,omitempty"`
+}
+
+// Union returns capabilities supported by either c or other, keeping the
+// larger context window. Routers use it to describe the set of providers
+// they can dispatch to.
+func (c Capabilities) Union(other Capabilities) Capabilities {
+	out := Capabilities{
+		Tools:      c.Tools || other.Tools,
+		Vision:     c.Vision || other.Vision,
+		JSONMode:   c.JSONMode || other.JSONMode,
+		MaxContext: c.MaxContext,
+	}
+	if other.MaxContext > out.MaxContext {
+		out.MaxContext = other.MaxContext
+	}
+	return out
+}
+
+// MaxContextWindow returns the largest ContextWindow among models, or 0 when
+// none is known.
+func MaxContextWindow(models []ModelInfo) int {
+	max := 0
+	for _, m := range models {
+		if m.ContextWindow > max {
+			max = m.ContextWindow
+		}
+	}
+	return max
+}
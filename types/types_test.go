@@ -192,3 +192,23 @@ func TestContentBlock_ToolResultContent_StringWithSpecialChars(t *testing.T) {
 		t.Errorf("round-trip string = %q, want %q", s, b.Content)
 	}
 }
+
+func TestCapabilitiesUnion(t *testing.T) {
+	a := Capabilities{Tools: true, MaxContext: 8000}
+	b := Capabilities{Vision: true, JSONMode: true, MaxContext: 200000}
+	got := a.Union(b)
+	want := Capabilities{Tools: true, Vision: true, JSONMode: true, MaxContext: 200000}
+	if got != want {
+		t.Errorf("Union() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMaxContextWindow(t *testing.T) {
+	models := []ModelInfo{{ID: "a", ContextWindow: 1000}, {ID: "b", ContextWindow: 4000}, {ID: "c"}}
+	if got := MaxContextWindow(models); got != 4000 {
+		t.Errorf("MaxContextWindow() = %d, want 4000", got)
+	}
+	if got := MaxContextWindow(nil); got != 0 {
+		t.Errorf("MaxContextWindow(nil) = %d, want 0", got)
+	}
+}